@@ -0,0 +1,918 @@
+// Code generated by sdkgen from api/openapi.yaml. DO NOT EDIT.
+
+export interface ErrorResponse {
+  error: ErrorDetail;
+}
+
+export interface ErrorDetail {
+  /** Machine-readable reason, the status' (not_found, bad_request...) or a more specific one (like the reason a clip was rejected for) */
+  code: string;
+  message: string;
+  /** The request's ID, also sent as X-Request-ID and found in the server's logs */
+  request_id?: string;
+}
+
+export interface BuildInfo {
+  /** Release version, dev for builds without one */
+  version: string;
+  /** Git commit built */
+  commit?: string;
+  /** Build (or commit) date, RFC 3339 */
+  date?: string;
+  /** Built from a working tree with uncommitted changes */
+  modified?: boolean;
+  go_version: string;
+  /** GOOS/GOARCH */
+  platform: string;
+}
+
+export interface RefreshPayload {
+  refresh_token: string;
+}
+
+export interface ExtensionExchangePayload {
+  /** From the redirect of the OAuth callback */
+  code: string;
+  /** Whose base64url SHA-256 was the code_challenge */
+  code_verifier: string;
+}
+
+export interface DeviceCodeResponse {
+  /** Secret of the client, to poll with */
+  device_code: string;
+  /** To show the user, as XXXX-XXXX */
+  user_code: string;
+  verification_uri: string;
+  /** The verification_uri with the user code filled in */
+  verification_uri_complete: string;
+  /** Seconds until the codes expire */
+  expires_in: number;
+  /** Seconds to wait between polls */
+  interval: number;
+}
+
+export interface DeviceTokenPayload {
+  device_code: string;
+}
+
+export interface TokenResponse {
+  access_token: string;
+  refresh_token: string;
+  /** Expiry of the access token, in Unix seconds */
+  expires_at: number;
+}
+
+export interface LogoutResponse {
+  success: boolean;
+}
+
+export interface UploadStartPayload {
+  /** application/json, or multipart/form-data with its boundary */
+  content_type: string;
+}
+
+export interface UploadSession {
+  id: string;
+  /** Bytes received; the next chunk starts here */
+  offset: number;
+  max_chunk_bytes: number;
+  max_bytes: number;
+  expires_at: string;
+}
+
+export interface OffsetMismatch {
+  error: ErrorDetail;
+  /** The session's offset, to resume from */
+  offset: number;
+}
+
+export interface FetchClipPayload {
+  url: string;
+  /** Overrides the extracted title */
+  title?: string;
+  /** article (default) or bookmark */
+  mode?: string;
+  tags?: string[];
+  notes?: string;
+}
+
+export interface ClipVersion {
+  version: number;
+  title: string;
+  mode: string;
+  /** Relative to the user's clip directory */
+  path: string;
+  current: boolean;
+  created_at: string;
+}
+
+export interface ListVersionsResponse {
+  clip_id: string;
+  versions: ClipVersion[];
+}
+
+export interface DiffResponse {
+  clip_id: string;
+  from: number;
+  to: number;
+  /** Unified diff of the markdown; empty when identical */
+  diff: string;
+}
+
+export interface HighlightPayload {
+  text: string;
+  /** Anchor in the page (CSS selector or XPath) */
+  selector?: string;
+  start_offset?: number;
+  end_offset?: number;
+  /** Defaults to yellow */
+  color?: string;
+  comment?: string;
+}
+
+export interface Highlight {
+  id: string;
+  clip_id: string;
+  text: string;
+  selector?: string;
+  start_offset: number;
+  end_offset: number;
+  color: string;
+  comment?: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface ListHighlightsResponse {
+  highlights: Highlight[];
+}
+
+export interface Delivery {
+  id: string;
+  clip_id: string;
+  device_email: string;
+  status: string;
+  error?: string;
+  sent_at?: string;
+  created_at: string;
+}
+
+export interface ConfigResponse {
+  clipDirectory: string;
+  defaultFormat: ClipMode;
+  /** Added to every new clip */
+  defaultTags: string[];
+  /** The user's folder template; empty for clips.folder_template or the built-in names */
+  folderTemplate: string;
+  images: ImagesConfig;
+}
+
+export interface ConfigPayload {
+  /** Mode of clips sent without one (a ClipMode); empty for article */
+  defaultFormat?: string;
+  defaultTags?: string[];
+  /** Go template of new clips' folders, as in PUT /api/v1/templates/naming */
+  folderTemplate?: string;
+  images?: ImagesPreferences;
+}
+
+/** Omitted fields use the server's images settings */
+export interface ImagesPreferences {
+  convertToWebp?: boolean | null;
+  preserveOriginal?: boolean | null;
+  /** At most the server's images.max_dimension_px */
+  maxDimensionPx?: number;
+}
+
+export interface ImagesConfig {
+  maxSizeBytes: number;
+  maxDimensionPx: number;
+  maxTotalBytes: number;
+  /** PNG and JPEG images are stored as WebP (images.convert_to_webp) */
+  convertToWebp: boolean;
+  /** Resized and converted images keep their original in media/originals (images.preserve_original) */
+  preserveOriginal: boolean;
+  /** Image formats accepted, recognized by content (images.allowed_formats); others are rejected with 415 */
+  allowedFormats: string[];
+  /** Images may be sent with an empty data and only their originalUrl, for the server to download (images.fetch_remote) */
+  fetchRemote: boolean;
+}
+
+export type ClipMode = 'article' | 'bookmark' | 'screenshot' | 'selection' | 'fullpage' | 'pdf';
+
+export interface ClipPayload {
+  title: string;
+  url: string;
+  markdown: string;
+  /** Page HTML, for fullpage mode */
+  html?: string;
+  tags: string[];
+  notes: string;
+  images: ImagePayload[];
+  mode?: ClipMode;
+  /** "reject" (default) or "merge" when the URL was just clipped */
+  dedupe?: string;
+  /** Add a table of contents of the page's headings (default markdown.toc.enabled) */
+  toc?: boolean;
+  /** Icon the page declares, tried before /favicon.ico (favicons.enabled) */
+  faviconUrl?: string;
+  latitude?: number;
+  longitude?: number;
+  place?: string;
+}
+
+export interface ImagePayload {
+  filename: string;
+  /** Base64 image data; may be empty when the server fetches remote images */
+  data: string;
+  /** Where the image was found; downloaded by the server when data is empty (images.fetch_remote) */
+  originalUrl: string;
+}
+
+export interface ClipResponse {
+  success: boolean;
+  path?: string;
+  id?: string;
+  /** Version number when the URL was clipped before */
+  version?: number;
+  /** Set on 409 when the URL was just clipped */
+  duplicate?: boolean;
+  /** Set on 202, the job processing the clip */
+  job_id?: string;
+  error?: ErrorDetail;
+}
+
+export interface ListClipsResponse {
+  clips: ClipSummary[];
+  /** Omitted when paginating with after= */
+  page?: number;
+  per_page: number;
+  total: number;
+  total_pages: number;
+  /** Pass as after= for the next page */
+  next_cursor?: string;
+}
+
+export interface ClipSummary {
+  id: string;
+  title: string;
+  url: string;
+  domain: string;
+  mode: string;
+  tags: string[] | null;
+  notes?: string;
+  excerpt?: string;
+  location?: ClipLocation;
+  collection_id?: string;
+  /** Icon of the clip's site, once fetched (favicons.enabled) */
+  favicon_url?: string;
+  metadata?: ClipMetadata;
+  /** ISO 639-1 code of the content language, declared by the page or detected */
+  language?: string;
+  archived_at?: string;
+  read_at?: string;
+  /** Set when an admin took the clip down from share links, feeds and the mirror */
+  disabled_at?: string;
+  /** Relevance to the words of q, summed over the fields they are found in (title 8, highlights 4, notes 2, body 1) */
+  score?: number;
+  created_at: string;
+}
+
+/** What the clipped page declares about itself (OpenGraph, meta tags, JSON-LD), for fullpage and server-fetched clips */
+export interface ClipMetadata {
+  title?: string;
+  description?: string;
+  author?: string;
+  /** Publication date, RFC 3339 */
+  published?: string;
+  site_name?: string;
+  image?: string;
+  type?: string;
+  language?: string;
+}
+
+export interface ClipLocation {
+  latitude: number;
+  longitude: number;
+  place?: string;
+}
+
+export interface ClipDetail extends ClipSummary {
+  path: string;
+  /** Markdown content */
+  content?: string;
+  images?: ClipImage[];
+}
+
+export interface RecentView extends ClipSummary {
+  /** Last time the clip was opened */
+  viewed_at: string;
+  /** Times the clip was opened */
+  views: number;
+}
+
+export interface ListRecentViewsResponse {
+  clips: RecentView[];
+}
+
+export interface ClipImage {
+  filename: string;
+  /** Relative path for serving */
+  path: string;
+  mime_type: string;
+}
+
+export interface BulkPayload {
+  operation: 'delete' | 'add-tags' | 'remove-tags' | 'move-to-collection' | 'archive' | 'unarchive' | 'mark-read' | 'mark-unread';
+  ids: string[];
+  /** For add-tags and remove-tags */
+  tags?: string[];
+  /** For move-to-collection; empty removes clips from their collection */
+  collection_id?: string;
+}
+
+export interface BulkResponse {
+  operation: string;
+  /** False when any item failed and nothing was changed */
+  applied: boolean;
+  results: BulkItemResult[];
+}
+
+export interface BulkUpdatePayload {
+  ids: string[];
+  add_tags?: string[];
+  remove_tags?: string[];
+  /** Moves the clips to this collection; empty removes them from their collection */
+  collection_id?: string | null;
+  archived?: boolean | null;
+  read?: boolean | null;
+}
+
+export interface BulkUpdateResponse {
+  /** Bulk operations applied to each clip, in order */
+  operations: string[];
+  /** False when any item failed and nothing was changed */
+  applied: boolean;
+  results: BulkItemResult[];
+}
+
+export interface BulkItemResult {
+  id: string;
+  success: boolean;
+  error?: string;
+}
+
+export interface CollectionPayload {
+  name: string;
+  /** Where clips moved to the collection are stored, a subpath of the clip directory or, for admins, an absolute path under admin.allowed_paths outside storage.base_path */
+  storage_path?: string;
+  /** Published by the read-only mirror */
+  public?: boolean;
+}
+
+export interface CollectionUpdatePayload {
+  name?: string;
+  public?: boolean;
+}
+
+export interface Collection {
+  id: string;
+  name: string;
+  storage_path?: string;
+  public: boolean;
+  created_at: string;
+}
+
+export interface ListCollectionsResponse {
+  collections: Collection[];
+}
+
+export interface Share {
+  id: string;
+  clip_id: string;
+  /** Public link, built on public.base_url */
+  url: string;
+  /** Set when an admin disabled the link */
+  disabled_at?: string;
+  expires_at?: string;
+  max_views?: number;
+  /** Page views so far */
+  views: number;
+  /** Past expires_at or out of views */
+  expired: boolean;
+  /** Visitors must enter a passphrase */
+  protected: boolean;
+  created_at: string;
+}
+
+/** Limits of a new share link; without any it never expires */
+export interface SharePayload {
+  expires_in_hours?: number;
+  max_views?: number;
+  /** Expire the link after a single view (max_views 1) */
+  burn_after_reading?: boolean;
+  /** Passphrase visitors must enter; only its hash is stored */
+  password?: string;
+}
+
+export interface ListSharesResponse {
+  shares: Share[];
+}
+
+export interface Job {
+  id: string;
+  type: string;
+  status: 'queued' | 'running' | 'succeeded' | 'failed';
+  attempts: number;
+  /** Set once succeeded; depends on the job type */
+  result?: Record<string, unknown>;
+  /** Error of the last failed attempt */
+  error?: string;
+  created_at: string;
+  started_at?: string;
+  finished_at?: string;
+}
+
+export interface UsageMinute {
+  /** Start of the minute */
+  at: string;
+  requests: number;
+  /** 4xx and 5xx responses, but 429 */
+  errors: number;
+  /** 429 responses */
+  throttled: number;
+}
+
+export interface TokenUsage {
+  id: string;
+  name: string;
+  prefix: string;
+  revoked: boolean;
+  expires_at?: string;
+  last_used_at?: string;
+  /** Requests made with the token in the window */
+  requests: number;
+}
+
+export interface APIUsage {
+  /** Start of the counts (the window, or the server start if later) */
+  since: string;
+  window_minutes: number;
+  requests: number;
+  errors: number;
+  throttled: number;
+  /** Requests made with a login session rather than a service token */
+  session_requests: number;
+  /** Minutes with requests, oldest first */
+  minutes: UsageMinute[];
+  rate_limit: Record<string, unknown>;
+  /** The user's service tokens, most recently used first */
+  tokens: TokenUsage[];
+}
+
+export interface Profile {
+  id: string;
+  email: string;
+  name: string;
+  /** The user may call /api/v1/admin (admin.emails) */
+  admin: boolean;
+  created_at: string;
+  storage: StorageStatus;
+  usage: DiskUsage;
+  limits: ProfileLimits;
+  token: ProfileToken;
+}
+
+export interface StorageStatus {
+  /** The user's clip directory */
+  path: string;
+  /** Set by an admin, instead of storage.base_path */
+  custom: boolean;
+  status: 'ok' | 'failed';
+  /** Why the directory can't be written to */
+  detail?: string;
+}
+
+/** The server's limits, 0 when there is none */
+export interface ProfileLimits {
+  max_clip_bytes: number;
+  max_image_bytes: number;
+  /** All the images of a clip */
+  max_images_bytes: number;
+  max_upload_bytes: number;
+  /** Per credential, when rate limiting is enabled */
+  requests_per_minute: number;
+}
+
+/** The credential of the request; id, name, prefix and created_at are set for service tokens */
+export interface ProfileToken {
+  type: 'jwt' | 'service_token' | 'dev_mode';
+  expires_at?: string;
+  id?: string;
+  name?: string;
+  prefix?: string;
+  created_at?: string;
+  last_used_at?: string;
+}
+
+export interface DiskUsage {
+  email: string;
+  /** Clips not in the trash */
+  clips: number;
+  bytes: number;
+  trashed_clips: number;
+  trashed_bytes: number;
+  /** Clips not in the trash, by mode (each with clips and bytes) */
+  modes: Record<string, unknown>;
+  /** When the files were last walked */
+  computed_at: string;
+}
+
+export interface ListClipsParams {
+  page?: number;
+  perPage?: number;
+  /** Cursor from next_cursor; takes precedence over page */
+  after?: string;
+  /** Search query: words and "phrases" that must all match, field terms (tag:, site:, mode:, lang:, before:, after:, is:read|unread|archived), - to negate any term */
+  q?: string;
+  /** Comma-separated fields the words of q are searched in (title, highlights, notes, body); all by default */
+  fields?: string;
+  /** date (newest first, the default) or relevance (highest score first; page with page=, not after=) */
+  sort?: string;
+  tag?: string;
+  mode?: string;
+  domain?: string;
+  /** ISO 639-1 language code, e.g. "fr" */
+  lang?: string;
+  collection?: string;
+  /** "true", "false" (default) or "all" */
+  archived?: string;
+  /** "true" or "false" keeps only read or unread clips */
+  read?: string;
+}
+
+export interface CreateClipParams {
+  /** Retries with the same key replay the saved clip instead of creating another one */
+  idempotencyKey?: string;
+  /** "respond-async" saves a new clip and returns 202 with the job extracting its text (OCR of screenshots, PDF text layer). Only text extraction is deferred: remote images are fetched, and images downscaled and converted to WebP, before the response. Re-clips of a saved URL are answered synchronously. */
+  prefer?: string;
+}
+
+export interface ListRecentViewsParams {
+  /** Number of clips, 1 to 100 (default 20) */
+  limit?: number;
+}
+
+export interface DeleteClipParams {
+  /** "false" keeps the files in place */
+  deleteFiles?: string;
+}
+
+export interface ExportClipParams {
+  /** epub (default) or markdown */
+  format?: string;
+  /** Add a table of contents (true/false); defaults to the user's setting */
+  toc?: string;
+}
+
+export interface DiffClipVersionsParams {
+  /** Version to compare from (default the one before to) */
+  from?: number;
+  /** Version to compare to (default the latest) */
+  to?: number;
+}
+
+export interface GetDiskUsageParams {
+  /** Measure again rather than use the cached figures */
+  refresh?: boolean;
+}
+
+export interface ClientOptions {
+  /** Server URL, e.g. http://localhost:8080 */
+  baseUrl: string;
+  /** OAuth access token or service token */
+  token?: string;
+  fetch?: typeof fetch;
+}
+
+interface RequestOptions {
+  body?: unknown;
+  query?: Record<string, string | number | boolean | undefined>;
+  headers?: Record<string, string | undefined>;
+}
+
+/** Error status returned by the server, with the code and request ID of its error envelope */
+export class ApiError extends Error {
+  constructor(
+    public readonly status: number,
+    message: string,
+    public readonly body: unknown,
+    public readonly code?: string,
+    public readonly requestId?: string
+  ) {
+    super(message);
+    this.name = 'ApiError';
+  }
+}
+
+class BaseClient {
+  constructor(protected readonly options: ClientOptions) {}
+
+  protected async request<T>(method: string, path: string, opts: RequestOptions = {}): Promise<T> {
+    const url = new URL(path, this.options.baseUrl);
+    for (const [key, value] of Object.entries(opts.query ?? {})) {
+      if (value !== undefined && value !== '') {
+        url.searchParams.set(key, String(value));
+      }
+    }
+
+    const headers: Record<string, string> = { Accept: 'application/json' };
+    if (this.options.token) {
+      headers.Authorization = `Bearer ${this.options.token}`;
+    }
+    if (opts.body !== undefined) {
+      headers['Content-Type'] = 'application/json';
+    }
+    for (const [key, value] of Object.entries(opts.headers ?? {})) {
+      if (value !== undefined) {
+        headers[key] = value;
+      }
+    }
+
+    const doFetch = this.options.fetch ?? fetch;
+    const response = await doFetch(url.toString(), {
+      method,
+      headers,
+      body: opts.body === undefined ? undefined : JSON.stringify(opts.body),
+    });
+
+    const text = await response.text();
+    let data: unknown = undefined;
+    try {
+      data = text ? JSON.parse(text) : undefined;
+    } catch {
+      data = text; // Error pages from proxies are not JSON
+    }
+    if (!response.ok) {
+      // {"error": {code, message, request_id}}, or {"error": message} from older servers
+      const error = (data as { error?: string | { code?: string; message?: string; request_id?: string } } | undefined)
+        ?.error;
+      if (error && typeof error === 'object') {
+        throw new ApiError(response.status, error.message ?? `HTTP ${response.status}`, data, error.code, error.request_id);
+      }
+      throw new ApiError(response.status, error ?? `HTTP ${response.status}`, data);
+    }
+    return data as T;
+  }
+}
+
+export class WebClipperClient extends BaseClient {
+  /** Get the user's settings and the server's limits (GET /api/v1/config) */
+  getConfig(): Promise<ConfigResponse> {
+    return this.request<ConfigResponse>('GET', '/api/v1/config');
+  }
+
+  /** Replace the user's settings (PUT /api/v1/config) */
+  updateConfig(body: ConfigPayload): Promise<ConfigResponse> {
+    return this.request<ConfigResponse>('PUT', '/api/v1/config', { body });
+  }
+
+  /** Get the server's build (GET /api/v1/version) */
+  getVersion(): Promise<BuildInfo> {
+    return this.request<BuildInfo>('GET', '/api/v1/version');
+  }
+
+  /** Exchange a refresh token for new tokens (POST /auth/refresh) */
+  refreshToken(body: RefreshPayload): Promise<TokenResponse> {
+    return this.request<TokenResponse>('POST', '/auth/refresh', { body });
+  }
+
+  /** Exchange the extension's login code for tokens (POST /auth/extension/exchange) */
+  exchangeExtensionCode(body: ExtensionExchangePayload): Promise<TokenResponse> {
+    return this.request<TokenResponse>('POST', '/auth/extension/exchange', { body });
+  }
+
+  /** Start a device login (POST /auth/device/code) */
+  requestDeviceCode(): Promise<DeviceCodeResponse> {
+    return this.request<DeviceCodeResponse>('POST', '/auth/device/code');
+  }
+
+  /** Poll for the tokens of a device login (POST /auth/device/token) */
+  deviceToken(body: DeviceTokenPayload): Promise<TokenResponse> {
+    return this.request<TokenResponse>('POST', '/auth/device/token', { body });
+  }
+
+  /** Log out (POST /auth/logout) */
+  logout(): Promise<LogoutResponse> {
+    return this.request<LogoutResponse>('POST', '/auth/logout');
+  }
+
+  /** List clips, newest first (GET /api/v1/clips) */
+  listClips(params: ListClipsParams = {}): Promise<ListClipsResponse> {
+    return this.request<ListClipsResponse>('GET', '/api/v1/clips', {
+      query: {
+        page: params.page,
+        per_page: params.perPage,
+        after: params.after,
+        q: params.q,
+        fields: params.fields,
+        sort: params.sort,
+        tag: params.tag,
+        mode: params.mode,
+        domain: params.domain,
+        lang: params.lang,
+        collection: params.collection,
+        archived: params.archived,
+        read: params.read,
+      },
+    });
+  }
+
+  /** Save a clip (POST /api/v1/clips) */
+  createClip(body: ClipPayload, params: CreateClipParams = {}): Promise<ClipResponse> {
+    return this.request<ClipResponse>('POST', '/api/v1/clips', {
+      body,
+      headers: {
+        'Idempotency-Key': params.idempotencyKey,
+        Prefer: params.prefer,
+      },
+    });
+  }
+
+  /** Start a chunked upload of a clip (POST /api/v1/uploads) */
+  startUpload(body: UploadStartPayload): Promise<UploadSession> {
+    return this.request<UploadSession>('POST', '/api/v1/uploads', { body });
+  }
+
+  /** Get an upload session, to resume at its offset (GET /api/v1/uploads/{id}) */
+  getUpload(id: string): Promise<UploadSession> {
+    return this.request<UploadSession>('GET', `/api/v1/uploads/${encodeURIComponent(id)}`);
+  }
+
+  /** Abort an upload (DELETE /api/v1/uploads/{id}) */
+  abortUpload(id: string): Promise<void> {
+    return this.request<void>('DELETE', `/api/v1/uploads/${encodeURIComponent(id)}`);
+  }
+
+  /** Save the uploaded body as a clip (POST /api/v1/uploads/{id}/finalize) */
+  finalizeUpload(id: string): Promise<ClipResponse> {
+    return this.request<ClipResponse>('POST', `/api/v1/uploads/${encodeURIComponent(id)}/finalize`);
+  }
+
+  /** Apply one operation to many clips, all or nothing (POST /api/v1/clips/bulk) */
+  bulkClips(body: BulkPayload): Promise<BulkResponse> {
+    return this.request<BulkResponse>('POST', '/api/v1/clips/bulk', { body });
+  }
+
+  /** Apply several changes to many clips, all or nothing (POST /api/v1/clips/bulk-update) */
+  bulkUpdateClips(body: BulkUpdatePayload): Promise<BulkUpdateResponse> {
+    return this.request<BulkUpdateResponse>('POST', '/api/v1/clips/bulk-update', { body });
+  }
+
+  /** Fetch a page on the server and save it as a clip (POST /api/v1/clips/fetch) */
+  fetchClip(body: FetchClipPayload): Promise<ClipResponse> {
+    return this.request<ClipResponse>('POST', '/api/v1/clips/fetch', { body });
+  }
+
+  /** List the clips the user opened last, most recent first (GET /api/v1/clips/recent-views) */
+  listRecentViews(params: ListRecentViewsParams = {}): Promise<ListRecentViewsResponse> {
+    return this.request<ListRecentViewsResponse>('GET', '/api/v1/clips/recent-views', {
+      query: {
+        limit: params.limit,
+      },
+    });
+  }
+
+  /** Get a clip with its content (GET /api/v1/clips/{id}) */
+  getClip(id: string): Promise<ClipDetail> {
+    return this.request<ClipDetail>('GET', `/api/v1/clips/${encodeURIComponent(id)}`);
+  }
+
+  /** Move a clip to the trash (DELETE /api/v1/clips/{id}) */
+  deleteClip(id: string, params: DeleteClipParams = {}): Promise<void> {
+    return this.request<void>('DELETE', `/api/v1/clips/${encodeURIComponent(id)}`, {
+      query: {
+        delete_files: params.deleteFiles,
+      },
+    });
+  }
+
+  /** Get the icon of a clip's site (GET /api/v1/clips/{id}/favicon) */
+  getClipFavicon(id: string): Promise<void> {
+    return this.request<void>('GET', `/api/v1/clips/${encodeURIComponent(id)}/favicon`);
+  }
+
+  /** List the public links to a clip (GET /api/v1/clips/{id}/shares) */
+  listShares(id: string): Promise<ListSharesResponse> {
+    return this.request<ListSharesResponse>('GET', `/api/v1/clips/${encodeURIComponent(id)}/shares`);
+  }
+
+  /** Create a public link to a clip (POST /api/v1/clips/{id}/shares) */
+  createShare(id: string, body: SharePayload): Promise<Share> {
+    return this.request<Share>('POST', `/api/v1/clips/${encodeURIComponent(id)}/shares`, { body });
+  }
+
+  /** Get a media file of a clip (GET /api/v1/clips/{id}/media/{filename}) */
+  getClipMedia(id: string, filename: string): Promise<void> {
+    return this.request<void>('GET', `/api/v1/clips/${encodeURIComponent(id)}/media/${encodeURIComponent(filename)}`);
+  }
+
+  /** Download a clip as EPUB or markdown (GET /api/v1/clips/{id}/export) */
+  exportClip(id: string, params: ExportClipParams = {}): Promise<void> {
+    return this.request<void>('GET', `/api/v1/clips/${encodeURIComponent(id)}/export`, {
+      query: {
+        format: params.format,
+        toc: params.toc,
+      },
+    });
+  }
+
+  /** Email a clip's EPUB to the user's Kindle address (POST /api/v1/clips/{id}/send-to-kindle) */
+  sendClipToKindle(id: string): Promise<Delivery> {
+    return this.request<Delivery>('POST', `/api/v1/clips/${encodeURIComponent(id)}/send-to-kindle`);
+  }
+
+  /** List the captures of a clip (GET /api/v1/clips/{id}/versions) */
+  listClipVersions(id: string): Promise<ListVersionsResponse> {
+    return this.request<ListVersionsResponse>('GET', `/api/v1/clips/${encodeURIComponent(id)}/versions`);
+  }
+
+  /** Compare the markdown of two captures of a clip (GET /api/v1/clips/{id}/versions/diff) */
+  diffClipVersions(id: string, params: DiffClipVersionsParams = {}): Promise<DiffResponse> {
+    return this.request<DiffResponse>('GET', `/api/v1/clips/${encodeURIComponent(id)}/versions/diff`, {
+      query: {
+        from: params.from,
+        to: params.to,
+      },
+    });
+  }
+
+  /** List the highlights of a clip (GET /api/v1/clips/{id}/highlights) */
+  listHighlights(id: string): Promise<ListHighlightsResponse> {
+    return this.request<ListHighlightsResponse>('GET', `/api/v1/clips/${encodeURIComponent(id)}/highlights`);
+  }
+
+  /** Highlight or annotate a clip (POST /api/v1/clips/{id}/highlights) */
+  createHighlight(id: string, body: HighlightPayload): Promise<Highlight> {
+    return this.request<Highlight>('POST', `/api/v1/clips/${encodeURIComponent(id)}/highlights`, { body });
+  }
+
+  /** Replace a highlight's anchor, color and comment (PUT /api/v1/clips/{id}/highlights/{highlight_id}) */
+  updateHighlight(id: string, highlightId: string, body: HighlightPayload): Promise<Highlight> {
+    return this.request<Highlight>('PUT', `/api/v1/clips/${encodeURIComponent(id)}/highlights/${encodeURIComponent(highlightId)}`, { body });
+  }
+
+  /** Remove a highlight (DELETE /api/v1/clips/{id}/highlights/{highlight_id}) */
+  deleteHighlight(id: string, highlightId: string): Promise<void> {
+    return this.request<void>('DELETE', `/api/v1/clips/${encodeURIComponent(id)}/highlights/${encodeURIComponent(highlightId)}`);
+  }
+
+  /** Revoke a share link (DELETE /api/v1/shares/{id}) */
+  deleteShare(id: string): Promise<void> {
+    return this.request<void>('DELETE', `/api/v1/shares/${encodeURIComponent(id)}`);
+  }
+
+  /** List collections (GET /api/v1/collections) */
+  listCollections(): Promise<ListCollectionsResponse> {
+    return this.request<ListCollectionsResponse>('GET', '/api/v1/collections');
+  }
+
+  /** Create a collection (POST /api/v1/collections) */
+  createCollection(body: CollectionPayload): Promise<Collection> {
+    return this.request<Collection>('POST', '/api/v1/collections', { body });
+  }
+
+  /** Rename a collection or change whether it is public (PUT /api/v1/collections/{id}) */
+  updateCollection(id: string, body: CollectionUpdatePayload): Promise<Collection> {
+    return this.request<Collection>('PUT', `/api/v1/collections/${encodeURIComponent(id)}`, { body });
+  }
+
+  /** Delete a collection, keeping its clips (DELETE /api/v1/collections/{id}) */
+  deleteCollection(id: string): Promise<void> {
+    return this.request<void>('DELETE', `/api/v1/collections/${encodeURIComponent(id)}`);
+  }
+
+  /** Get the status of a background job (GET /api/v1/jobs/{id}) */
+  getJob(id: string): Promise<Job> {
+    return this.request<Job>('GET', `/api/v1/jobs/${encodeURIComponent(id)}`);
+  }
+
+  /** Get the user's API requests of the last hour (GET /api/v1/usage/api) */
+  getApiUsage(): Promise<APIUsage> {
+    return this.request<APIUsage>('GET', '/api/v1/usage/api');
+  }
+
+  /** Get the authenticated user's account (GET /api/v1/me) */
+  getProfile(): Promise<Profile> {
+    return this.request<Profile>('GET', '/api/v1/me');
+  }
+
+  /** Get the storage taken by the user's clips (GET /api/v1/me/usage) */
+  getDiskUsage(params: GetDiskUsageParams = {}): Promise<DiskUsage> {
+    return this.request<DiskUsage>('GET', '/api/v1/me/usage', {
+      query: {
+        refresh: params.refresh,
+      },
+    });
+  }
+}
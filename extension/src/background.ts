@@ -8,6 +8,7 @@ import {
   ScreenshotResult,
   CaptureEmbedPayload,
   CaptureEmbedResponse,
+  UploadSession,
 } from './types';
 import { compressScreenshot } from './capture/screenshot';
 
@@ -30,6 +31,10 @@ let pendingSelection: {
 // Capture lock to prevent concurrent captures
 let captureInProgress = false;
 
+// Clip bodies larger than this are sent in chunks through an upload session,
+// so proxies with request size limits don't reject them with a 413
+const CHUNKED_UPLOAD_THRESHOLD = 4 * 1024 * 1024;
+
 // Initialize on install
 chrome.runtime.onInstalled.addListener(async () => {
   const stored = await chrome.storage.local.get(['authState', 'serverConfig']);
@@ -275,133 +280,75 @@ async function devLogin(payload: { serverUrl: string }): Promise<{ success: bool
   }
 }
 
-// Initiate OAuth login via popup window
+// Base64url without padding, as PKCE expects
+function base64Url(bytes: Uint8Array): string {
+  return btoa(String.fromCharCode(...bytes))
+    .replace(/\+/g, '-')
+    .replace(/\//g, '_')
+    .replace(/=+$/, '');
+}
+
+// Initiate OAuth login with PKCE: the server redirects to the extension's
+// redirect URL with a one-time code, which only this verifier can exchange
 async function initiateLogin(payload: { serverUrl: string }): Promise<{ success: boolean } | { error: string }> {
   try {
     authState.serverUrl = payload.serverUrl;
     await chrome.storage.local.set({ authState });
 
-    // Build login URL with redirect flag (server will show success page)
-    const loginUrl = `${payload.serverUrl}/auth/login?redirect=extension`;
+    const verifier = base64Url(crypto.getRandomValues(new Uint8Array(32)));
+    const challenge = base64Url(
+      new Uint8Array(await crypto.subtle.digest('SHA-256', new TextEncoder().encode(verifier)))
+    );
+    const state = base64Url(crypto.getRandomValues(new Uint8Array(16)));
+    const serverUrl = payload.serverUrl.replace(/\/$/, '');
+    const startUrl = new URL(`${serverUrl}/auth/extension/start`);
+    startUrl.search = new URLSearchParams({
+      code_challenge: challenge,
+      code_challenge_method: 'S256',
+      redirect_uri: chrome.identity.getRedirectURL(),
+      state,
+    }).toString();
+
+    let redirect: string | undefined;
+    try {
+      redirect = await chrome.identity.launchWebAuthFlow({ url: startUrl.toString(), interactive: true });
+    } catch {
+      return { error: 'Login cancelled' };
+    }
+    if (!redirect) {
+      return { error: 'Login cancelled' };
+    }
 
-    // Open popup window for OAuth
-    const popup = await chrome.windows.create({
-      url: loginUrl,
-      type: 'popup',
-      width: 500,
-      height: 600,
-    });
+    const params = new URL(redirect).searchParams;
+    if (params.get('state') !== state) {
+      return { error: 'Login failed: state mismatch' };
+    }
+    const code = params.get('code');
+    if (!code) {
+      return { error: `Login failed: ${params.get('error_description') || params.get('error') || 'no code'}` };
+    }
 
-    const popupId = popup?.id;
-    if (!popupId) {
-      return { error: 'Failed to open login window' };
+    const response = await fetch(`${serverUrl}/auth/extension/exchange`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({ code, code_verifier: verifier }),
+    });
+    if (!response.ok) {
+      return { error: `Login failed: HTTP ${response.status}` };
     }
 
-    // Monitor the popup for auth completion
-    return new Promise((resolve) => {
-      let popupTabId: number | undefined;
-
-      const checkInterval = setInterval(async () => {
-        try {
-          // Check if popup still exists
-          const windows = await chrome.windows.getAll({ populate: true });
-          const popupWindow = windows.find((w) => w.id === popupId);
-
-          if (!popupWindow) {
-            clearInterval(checkInterval);
-            // Check if we got authenticated while popup was open
-            if (authState.accessToken) {
-              resolve({ success: true });
-            } else {
-              resolve({ error: 'Login cancelled' });
-            }
-            return;
-          }
-
-          // Get the tab ID from the popup window
-          if (popupWindow.tabs && popupWindow.tabs[0]?.id) {
-            popupTabId = popupWindow.tabs[0].id;
-          }
-
-          // Try to read tokens from the popup page
-          if (popupTabId) {
-            try {
-              // First check the URL to see if we're on the success page
-              const tab = await chrome.tabs.get(popupTabId);
-              const tabUrl = tab.url || '';
-
-              console.log('Web Clipper: Checking popup URL:', tabUrl);
-              console.log('Web Clipper: Server URL:', authState.serverUrl);
-
-              // Only try to read tokens if we're back on our server (not Google's OAuth page)
-              // Normalize URLs for comparison
-              const normalizedTabUrl = tabUrl.replace(/\/$/, '');
-              const normalizedServerUrl = authState.serverUrl.replace(/\/$/, '');
-
-              if (normalizedTabUrl.startsWith(normalizedServerUrl)) {
-                console.log('Web Clipper: On server page, attempting to read tokens...');
-
-                const results = await chrome.scripting.executeScript({
-                  target: { tabId: popupTabId },
-                  world: 'MAIN', // Access page's JavaScript context, not isolated world
-                  func: () => {
-                    // eslint-disable-next-line @typescript-eslint/no-explicit-any
-                    const auth = (window as any).webClipperAuth;
-                    console.log('Web Clipper (injected): Found auth object:', auth);
-                    return auth;
-                  },
-                });
-
-                console.log('Web Clipper: Script execution results:', results);
-
-                if (results[0]?.result) {
-                  const tokens = results[0].result;
-                  console.log('Web Clipper: Got tokens:', {
-                    hasAccessToken: !!tokens.accessToken,
-                    hasRefreshToken: !!tokens.refreshToken,
-                    expiresAt: tokens.expiresAt,
-                  });
-
-                  clearInterval(checkInterval);
-
-                  // Save tokens
-                  authState.accessToken = tokens.accessToken;
-                  authState.refreshToken = tokens.refreshToken;
-                  authState.expiresAt = tokens.expiresAt;
-                  await chrome.storage.local.set({ authState });
-
-                  console.log('Web Clipper: Auth tokens saved to storage');
-
-                  // Fetch server config
-                  await fetchServerConfig();
-
-                  // Close popup
-                  chrome.windows.remove(popupId);
-
-                  resolve({ success: true });
-                } else {
-                  console.log('Web Clipper: No tokens found in page yet');
-                }
-              }
-            } catch (e) {
-              // Script execution failed (different origin, page not ready, etc.)
-              // This is expected during OAuth redirect, continue polling
-              console.log('Web Clipper: Script execution error (may be normal during OAuth):', e);
-            }
-          }
-        } catch {
-          // Window check failed, popup was closed
-          clearInterval(checkInterval);
-          resolve({ error: 'Login window closed' });
-        }
-      }, 500);
+    const tokens = await response.json();
+    authState.accessToken = tokens.access_token;
+    authState.refreshToken = tokens.refresh_token;
+    authState.expiresAt = tokens.expires_at;
+    await chrome.storage.local.set({ authState });
 
-      // Timeout after 5 minutes
-      setTimeout(() => {
-        clearInterval(checkInterval);
-        resolve({ error: 'Login timeout' });
-      }, 5 * 60 * 1000);
-    });
+    // Fetch server config after successful auth
+    await fetchServerConfig();
+
+    return { success: true };
   } catch (err) {
     return { error: `Failed to initiate login: ${err}` };
   }
@@ -474,21 +421,38 @@ async function fetchServerConfig(): Promise<ServerConfig | { error: string }> {
   }
 }
 
-// Submit clip to server
-async function submitClip(payload: ClipPayload): Promise<ClipResponse> {
+// Submit clip to server. The idempotency key is reused when the request is
+// retried, so the server never saves the same capture twice.
+async function submitClip(
+  payload: ClipPayload,
+  idempotencyKey: string = crypto.randomUUID()
+): Promise<ClipResponse> {
   if (!authState.accessToken || !authState.serverUrl) {
     return { success: false, error: 'Not authenticated' };
   }
 
   try {
-    const response = await fetch(`${authState.serverUrl}/api/v1/clips`, {
-      method: 'POST',
-      headers: {
-        Authorization: `Bearer ${authState.accessToken}`,
-        'Content-Type': 'application/json',
-      },
-      body: JSON.stringify(payload),
-    });
+    const headers: Record<string, string> = {
+      Authorization: `Bearer ${authState.accessToken}`,
+      'Idempotency-Key': idempotencyKey,
+    };
+    let body: Blob;
+    if (payload.images.length > 0) {
+      // Multipart lets the server stream images to disk instead of decoding
+      // base64 in memory; serializing the form gives its multipart type
+      body = await new Response(clipFormData(payload)).blob();
+    } else {
+      body = new Blob([JSON.stringify(payload)], { type: 'application/json' });
+    }
+
+    const response =
+      body.size > CHUNKED_UPLOAD_THRESHOLD
+        ? await uploadInChunks(body, headers)
+        : await fetch(`${authState.serverUrl}/api/v1/clips`, {
+            method: 'POST',
+            headers: { ...headers, 'Content-Type': body.type },
+            body,
+          });
 
     if (!response.ok) {
       if (response.status === 401) {
@@ -496,12 +460,14 @@ async function submitClip(payload: ClipPayload): Promise<ClipResponse> {
         if (!refreshed) {
           return { success: false, error: 'Authentication expired' };
         }
-        return submitClip(payload);
+        return submitClip(payload, idempotencyKey);
       }
+      // {"error": {code, message, request_id}}; older servers sent the message as error
       const errorData = await response.json().catch(() => ({}));
+      const message = typeof errorData.error === 'string' ? errorData.error : errorData.error?.message;
       return {
         success: false,
-        error: errorData.error || `Server error: ${response.status}`,
+        error: message || `Server error: ${response.status}`,
       };
     }
 
@@ -511,6 +477,56 @@ async function submitClip(payload: ClipPayload): Promise<ClipResponse> {
   }
 }
 
+// Send a clip body through an upload session: start it, PUT the body in
+// chunks, then finalize it, which answers like POST /api/v1/clips. A 409
+// on a chunk carries the offset the server expects next.
+async function uploadInChunks(body: Blob, headers: Record<string, string>): Promise<Response> {
+  const uploads = `${authState.serverUrl}/api/v1/uploads`;
+  const auth = { Authorization: headers.Authorization };
+
+  const start = await fetch(uploads, {
+    method: 'POST',
+    headers: { ...auth, 'Content-Type': 'application/json' },
+    body: JSON.stringify({ content_type: body.type }),
+  });
+  if (!start.ok) {
+    return start;
+  }
+  const session: UploadSession = await start.json();
+
+  let offset = session.offset;
+  while (offset < body.size) {
+    const response = await fetch(`${uploads}/${session.id}?offset=${offset}`, {
+      method: 'PUT',
+      headers: { ...auth, 'Content-Type': 'application/octet-stream' },
+      body: body.slice(offset, offset + session.max_chunk_bytes),
+    });
+    if (!response.ok && response.status !== 409) {
+      return response;
+    }
+    offset = (await response.json()).offset;
+  }
+
+  return fetch(`${uploads}/${session.id}/finalize`, { method: 'POST', headers });
+}
+
+// Build a multipart clip upload: the payload as JSON in the "clip" part, with
+// images listed without data, and each image as an "images" file part
+function clipFormData(payload: ClipPayload): FormData {
+  const form = new FormData();
+  const images = payload.images.map(({ filename, originalUrl }) => ({ filename, data: '', originalUrl }));
+  form.append('clip', JSON.stringify({ ...payload, images }));
+  for (const image of payload.images) {
+    const binary = atob(image.data);
+    const bytes = new Uint8Array(binary.length);
+    for (let i = 0; i < binary.length; i++) {
+      bytes[i] = binary.charCodeAt(i);
+    }
+    form.append('images', new Blob([bytes]), image.filename);
+  }
+  return form;
+}
+
 // Refresh access token
 async function refreshToken(): Promise<boolean> {
   if (!authState.refreshToken || !authState.serverUrl) {
@@ -346,6 +346,7 @@ clipBtn.addEventListener('click', async () => {
       notes: clipNotesInput.value,
       images: captureResult.images || [],
       mode: currentMode,
+      faviconUrl: tab.favIconUrl,
     };
 
     // Add screenshot as image if present
@@ -1,16 +1,13 @@
-// Server configuration (from GET /api/v1/config)
-export interface ServerConfig {
-  clipDirectory: string;
-  defaultFormat: string;
-  images: ImageConfig;
-}
+import type {
+  ClipResponse as ApiClipResponse,
+  ConfigResponse,
+  ImagePayload as ApiImagePayload,
+  ImagesConfig,
+} from '../api/client.gen';
 
-export interface ImageConfig {
-  maxSizeBytes: number;
-  maxDimensionPx: number;
-  maxTotalBytes: number;
-  convertToWebp: boolean;
-}
+// Server configuration (from GET /api/v1/config), generated from the API spec
+export type ServerConfig = ConfigResponse;
+export type ImageConfig = ImagesConfig;
 
 // Clip modes
 export type ClipMode = 'article' | 'bookmark' | 'screenshot' | 'selection' | 'fullpage';
@@ -25,19 +22,22 @@ export interface ClipPayload {
   notes: string;
   images: ImagePayload[];
   mode?: ClipMode;
+  faviconUrl?: string; // Icon the tab shows, fetched by the server
 }
 
-export interface ImagePayload {
-  filename: string;
-  data: string; // base64
-  originalUrl: string;
-}
+export type ImagePayload = ApiImagePayload;
 
-// Clip response
-export interface ClipResponse {
-  success: boolean;
-  path?: string;
-  error?: string;
+// Clip response, with the message of the server's error (or of one that
+// happened sending the clip) as error
+export type ClipResponse = Omit<ApiClipResponse, 'error'> & { error?: string };
+
+// Chunked upload session (POST /api/v1/uploads)
+export interface UploadSession {
+  id: string;
+  offset: number; // Bytes received; the next chunk starts here
+  max_chunk_bytes: number;
+  max_bytes: number;
+  expires_at: string;
 }
 
 // Auth state
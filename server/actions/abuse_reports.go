@@ -0,0 +1,293 @@
+package actions
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Visitors report shared clips from their page; reports wait in a queue for
+// an admin, who can disable the share link or take the clip down entirely
+// (hidden from every share link, feed and the mirror), before or when
+// resolving them. Share links with public.auto_disable_reports open reports
+// are disabled pending review. Admin actions are logged with the admin's
+// email.
+
+// ReportPayload is the body of a report, sent as JSON or by the share page's form
+type ReportPayload struct {
+	Reason  string `json:"reason" form:"reason"` // spam, copyright, illegal, harassment or other
+	Details string `json:"details" form:"details"`
+	Email   string `json:"email" form:"email"` // To contact the reporter (optional)
+}
+
+// ReportResponse is the admin view of an abuse report
+type ReportResponse struct {
+	ID             string     `json:"id"`
+	ShareID        string     `json:"share_id"`
+	ShareURL       string     `json:"share_url,omitempty"`
+	ShareDisabled  bool       `json:"share_disabled"`
+	ClipID         string     `json:"clip_id"`
+	ClipTitle      string     `json:"clip_title,omitempty"`
+	ClipURL        string     `json:"clip_url,omitempty"`
+	ClipDisabled   bool       `json:"clip_disabled"`
+	OwnerEmail     string     `json:"owner_email,omitempty"`
+	Reason         string     `json:"reason"`
+	Details        string     `json:"details,omitempty"`
+	ReporterEmail  string     `json:"reporter_email,omitempty"`
+	Status         string     `json:"status"`
+	ResolutionNote string     `json:"resolution_note,omitempty"`
+	ResolvedBy     string     `json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// reportShare records a visitor's report of a shared clip
+func reportShare(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	share, clip, _, err := sharedClip(c)
+	if err != nil {
+		return err
+	}
+
+	var req ReportPayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+	report := &models.AbuseReport{
+		ID:           uuid.Must(uuid.NewV4()),
+		ShareID:      share.ID,
+		ClipID:       clip.ID,
+		Reason:       strings.ToLower(strings.TrimSpace(req.Reason)),
+		Status:       models.ReportOpen,
+		ReporterHash: reporterHash(requestIP(c)),
+	}
+	if details := strings.TrimSpace(req.Details); details != "" {
+		report.Details = nulls.NewString(details)
+	}
+	if email := strings.TrimSpace(req.Email); email != "" {
+		report.ReporterEmail = nulls.NewString(email)
+	}
+	verrs, err := tx.ValidateAndCreate(report)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+	c.Logger().WithFields(map[string]interface{}{
+		"report": report.ID, "share": share.ID, "clip": clip.ID, "reason": report.Reason,
+	}).Warn("abuse report received")
+
+	// Reports from enough different addresses take the link down until an
+	// admin looks at it; one visitor reporting again counts once
+	if threshold := GetConfig().Public.AutoDisableReports; threshold > 0 {
+		open, err := models.CountOpenReportersByShare(tx, share.ID)
+		if err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+		if open >= threshold {
+			share.DisabledAt = nulls.NewTime(time.Now())
+			if err := tx.UpdateColumns(share, "disabled_at", "updated_at"); err != nil {
+				return c.Error(http.StatusInternalServerError, err)
+			}
+			c.Logger().WithFields(map[string]interface{}{"share": share.ID, "reporters": open}).
+				Warn("share disabled pending review")
+		}
+	}
+
+	if strings.HasPrefix(c.Request().Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		var html strings.Builder
+		if err := mirrorTemplates.ExecuteTemplate(&html, "report-sent", nil); err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+		c.Response().Header().Set("Content-Type", "text/html; charset=utf-8")
+		c.Response().WriteHeader(http.StatusCreated)
+		c.Response().Write([]byte(html.String()))
+		return nil
+	}
+	return c.Render(http.StatusCreated, r.JSON(map[string]string{"id": report.ID.String(), "status": report.Status}))
+}
+
+// reporterHash identifies the address of a reporter without storing it: an
+// HMAC keyed with jwt.secret, which can't be reversed by hashing every
+// address
+func reporterHash(ip string) string {
+	mac := hmac.New(sha256.New, []byte(GetConfig().JWT.Secret))
+	mac.Write([]byte("abuse-report:" + ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// adminListReports returns the abuse reports with ?status= (default open, "all")
+func adminListReports(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	status := c.Param("status")
+	switch status {
+	case "":
+		status = models.ReportOpen
+	case "all":
+		status = ""
+	case models.ReportOpen, models.ReportDismissed, models.ReportActioned:
+	default:
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid status %q", status))
+	}
+
+	reports, err := models.FindAbuseReports(tx, status)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	resp := make([]ReportResponse, len(reports))
+	for i := range reports {
+		resp[i] = reportToResponse(tx, &reports[i])
+	}
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{"reports": resp}))
+}
+
+// reportToResponse describes a report with the current state of its share
+// and clip, which may have been deleted since
+func reportToResponse(tx *pop.Connection, report *models.AbuseReport) ReportResponse {
+	resp := ReportResponse{
+		ID:             report.ID.String(),
+		ShareID:        report.ShareID.String(),
+		ClipID:         report.ClipID.String(),
+		Reason:         report.Reason,
+		Details:        report.Details.String,
+		ReporterEmail:  report.ReporterEmail.String,
+		Status:         report.Status,
+		ResolutionNote: report.ResolutionNote.String,
+		ResolvedBy:     report.ResolvedBy.String,
+		CreatedAt:      report.CreatedAt,
+	}
+	if report.ResolvedAt.Valid {
+		resp.ResolvedAt = &report.ResolvedAt.Time
+	}
+
+	share := &models.Share{}
+	if err := tx.Find(share, report.ShareID); err == nil {
+		resp.ShareURL = shareURL(share)
+		resp.ShareDisabled = share.DisabledAt.Valid
+	}
+	clip := &models.Clip{}
+	if err := tx.Find(clip, report.ClipID); err == nil {
+		resp.ClipTitle = clip.Title
+		resp.ClipURL = clip.URL
+		resp.ClipDisabled = clip.DisabledAt.Valid
+		owner := &models.User{}
+		if err := tx.Find(owner, clip.UserID); err == nil {
+			resp.OwnerEmail = owner.Email
+		}
+	}
+	return resp
+}
+
+// adminResolveReport closes a report as dismissed or actioned
+func adminResolveReport(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	reportID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid report ID"))
+	}
+	var req struct {
+		Status string `json:"status"` // dismissed or actioned
+		Note   string `json:"note"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+	if req.Status != models.ReportDismissed && req.Status != models.ReportActioned {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("status must be %q or %q", models.ReportDismissed, models.ReportActioned))
+	}
+
+	report := &models.AbuseReport{}
+	if err := tx.Find(report, reportID); err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("report not found"))
+	}
+	admin, _ := c.Value("user_email").(string)
+	report.Status = req.Status
+	report.ResolutionNote = nulls.String{}
+	if note := strings.TrimSpace(req.Note); note != "" {
+		report.ResolutionNote = nulls.NewString(note)
+	}
+	report.ResolvedBy = nulls.NewString(admin)
+	report.ResolvedAt = nulls.NewTime(time.Now())
+	if err := tx.UpdateColumns(report, "status", "resolution_note", "resolved_by", "resolved_at", "updated_at"); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	adminLogger{c}.Info("abuse report resolved", "report", report.ID, "status", report.Status)
+
+	return c.Render(http.StatusOK, r.JSON(reportToResponse(tx, report)))
+}
+
+// adminDisableShare disables a share link, e.g. pending review
+func adminDisableShare(c buffalo.Context) error {
+	return adminSetShareDisabled(c, true)
+}
+
+// adminEnableShare re-enables a disabled share link
+func adminEnableShare(c buffalo.Context) error {
+	return adminSetShareDisabled(c, false)
+}
+
+func adminSetShareDisabled(c buffalo.Context, disabled bool) error {
+	tx := c.Value("tx").(*pop.Connection)
+	shareID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid share ID"))
+	}
+	share := &models.Share{}
+	if err := tx.Find(share, shareID); err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("share not found"))
+	}
+
+	share.DisabledAt = nulls.Time{}
+	if disabled {
+		share.DisabledAt = nulls.NewTime(time.Now())
+	}
+	if err := tx.UpdateColumns(share, "disabled_at", "updated_at"); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	adminLogger{c}.Info("share link updated", "share", share.ID, "clip", share.ClipID, "disabled", disabled)
+	return c.Render(http.StatusNoContent, nil)
+}
+
+// adminDisableClip takes a clip down from every public route
+func adminDisableClip(c buffalo.Context) error {
+	return adminSetClipDisabled(c, true)
+}
+
+// adminEnableClip makes a taken down clip public again
+func adminEnableClip(c buffalo.Context) error {
+	return adminSetClipDisabled(c, false)
+}
+
+func adminSetClipDisabled(c buffalo.Context, disabled bool) error {
+	tx := c.Value("tx").(*pop.Connection)
+	clipID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid clip ID"))
+	}
+	clip := &models.Clip{}
+	if err := tx.Find(clip, clipID); err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("clip not found"))
+	}
+
+	clip.DisabledAt = nulls.Time{}
+	if disabled {
+		clip.DisabledAt = nulls.NewTime(time.Now())
+	}
+	if err := tx.UpdateColumns(clip, "disabled_at", "updated_at"); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	adminLogger{c}.Info("clip takedown updated", "clip", clip.ID, "user", clip.UserID, "disabled", disabled)
+	return c.Render(http.StatusNoContent, nil)
+}
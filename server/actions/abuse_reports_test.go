@@ -0,0 +1,133 @@
+package actions
+
+import (
+	"net/http"
+	"strings"
+
+	"server/internal/testkit"
+	"server/models"
+)
+
+func (as *ActionSuite) Test_AbuseReports() {
+	kit := testkit.New(as.T())
+	kit.Config.Public.BaseURL = "https://share.example.com"
+	kit.Config.Public.ReportsPerMinute = 10 // One visitor sends them all
+	owner := kit.CreateUser()
+	admin := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{admin.Email}
+	app := newKitApp(kit)
+	client := kit.Client(app, owner)
+	admins := kit.Client(app, admin)
+	visitor := kit.Client(newPublicApp(kit.DB), owner)
+	visitor.Token = ""
+
+	clip := kit.CreateClip(owner, testkit.WithContent("# Reported"))
+	var share ShareResponse
+	client.Post("/api/v1/clips/"+clip.ID.String()+"/shares", nil).JSON(&share)
+	path := strings.TrimPrefix(share.URL, "https://share.example.com")
+
+	// Share pages link to the report form
+	res := visitor.Get(path)
+	as.Contains(res.Body.String(), `action="`+share.URL+`/report"`)
+
+	res = visitor.Post(path+"/report", ReportPayload{Reason: "spam", Details: "Ads everywhere"})
+	as.Equal(http.StatusCreated, res.Code, res.Body.String())
+	res = visitor.Upload(path+"/report", strings.NewReader("reason=copyright&email=rights%40example.com"), "application/x-www-form-urlencoded")
+	as.Equal(http.StatusCreated, res.Code, res.Body.String())
+	as.Contains(res.Body.String(), "Thank you")
+	as.Equal(http.StatusUnprocessableEntity, visitor.Post(path+"/report", ReportPayload{Reason: "boring"}).Code)
+
+	// Reports wait for an admin
+	as.Equal(http.StatusForbidden, client.Get("/api/v1/admin/reports").Code)
+	var queue struct {
+		Reports []ReportResponse `json:"reports"`
+	}
+	res = admins.Get("/api/v1/admin/reports")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&queue)
+	as.Len(queue.Reports, 2)
+	as.Equal("spam", queue.Reports[0].Reason)
+	as.Equal("Ads everywhere", queue.Reports[0].Details)
+	as.Equal(share.URL, queue.Reports[0].ShareURL)
+	as.Equal(owner.Email, queue.Reports[0].OwnerEmail)
+	as.Equal("rights@example.com", queue.Reports[1].ReporterEmail)
+
+	// The share is disabled pending review, then the clip taken down
+	as.Equal(http.StatusNoContent, admins.Post("/api/v1/admin/shares/"+share.ID+"/disable", nil).Code)
+	as.Equal(http.StatusGone, visitor.Get(path).Code)
+	var shares struct {
+		Shares []ShareResponse `json:"shares"`
+	}
+	client.Get("/api/v1/clips/" + clip.ID.String() + "/shares").JSON(&shares)
+	as.NotNil(shares.Shares[0].DisabledAt)
+	as.Equal(http.StatusNoContent, admins.Post("/api/v1/admin/shares/"+share.ID+"/enable", nil).Code)
+	as.Equal(http.StatusOK, visitor.Get(path).Code)
+
+	as.Equal(http.StatusNoContent, admins.Post("/api/v1/admin/clips/"+clip.ID.String()+"/disable", nil).Code)
+	as.Equal(http.StatusGone, visitor.Get(path).Code)
+	as.Equal(http.StatusForbidden, client.Post("/api/v1/clips/"+clip.ID.String()+"/shares", nil).Code)
+	var detail ClipSummary
+	client.Get("/api/v1/clips/" + clip.ID.String()).JSON(&detail)
+	as.NotNil(detail.DisabledAt)
+
+	var resolved ReportResponse
+	res = admins.Post("/api/v1/admin/reports/"+queue.Reports[0].ID+"/resolve", map[string]string{"status": "actioned", "note": "Spam"})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&resolved)
+	as.Equal(models.ReportActioned, resolved.Status)
+	as.Equal(admin.Email, resolved.ResolvedBy)
+	as.True(resolved.ClipDisabled)
+	as.Equal(http.StatusBadRequest, admins.Post("/api/v1/admin/reports/"+queue.Reports[1].ID+"/resolve", map[string]string{"status": "open"}).Code)
+
+	queue.Reports = nil
+	admins.Get("/api/v1/admin/reports").JSON(&queue)
+	as.Len(queue.Reports, 1)
+	queue.Reports = nil
+	admins.Get("/api/v1/admin/reports?status=all").JSON(&queue)
+	as.Len(queue.Reports, 2)
+}
+
+func (as *ActionSuite) Test_AbuseReports_AutoDisable() {
+	kit := testkit.New(as.T())
+	kit.Config.Public.AutoDisableReports = 2
+	kit.Config.Logging.TrustProxy = true
+	owner := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), owner)
+	app := newPublicApp(kit.DB)
+	visitor := func(ip string) *testkit.Client {
+		v := kit.Client(app, owner)
+		v.Token = ""
+		v.Header = http.Header{"X-Forwarded-For": {ip}}
+		return v
+	}
+
+	clip := kit.CreateClip(owner)
+	var share ShareResponse
+	client.Post("/api/v1/clips/"+clip.ID.String()+"/shares", nil).JSON(&share)
+
+	// One visitor reporting again, whatever email they give, counts once
+	first := visitor("198.51.100.7")
+	as.Equal(http.StatusCreated, first.Post(share.URL+"/report", ReportPayload{Reason: "spam", Email: "a@example.com"}).Code)
+	as.Equal(http.StatusCreated, first.Post(share.URL+"/report", ReportPayload{Reason: "illegal", Email: "b@example.com"}).Code)
+	as.Equal(http.StatusOK, first.Get(share.URL).Code)
+
+	as.Equal(http.StatusCreated, visitor("203.0.113.9").Post(share.URL+"/report", ReportPayload{Reason: "illegal"}).Code)
+	as.Equal(http.StatusGone, first.Get(share.URL).Code)
+}
+
+func (as *ActionSuite) Test_AbuseReports_RateLimited() {
+	kit := testkit.New(as.T())
+	kit.Config.Public.ReportsPerMinute = 2
+	owner := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), owner)
+	visitor := kit.Client(newPublicApp(kit.DB), owner)
+	visitor.Token = ""
+	var share ShareResponse
+	client.Post("/api/v1/clips/"+kit.CreateClip(owner).ID.String()+"/shares", nil).JSON(&share)
+
+	as.Equal(http.StatusCreated, visitor.Post(share.URL+"/report", ReportPayload{Reason: "spam"}).Code)
+	as.Equal(http.StatusCreated, visitor.Post(share.URL+"/report", ReportPayload{Reason: "spam"}).Code)
+	res := visitor.Post(share.URL+"/report", ReportPayload{Reason: "spam"})
+	as.Equal(http.StatusTooManyRequests, res.Code)
+	as.NotEmpty(res.Header().Get("Retry-After"))
+}
@@ -0,0 +1,279 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"server/internal/config"
+	"server/internal/repository"
+	"server/internal/services"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+)
+
+// adminMiddleware restricts a route group to the users listed in admin.emails
+func adminMiddleware(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		email, _ := c.Value("user_email").(string)
+		if !isAdminEmail(GetConfig(), email) {
+			return c.Error(http.StatusForbidden, fmt.Errorf("admin access required"))
+		}
+		return next(c)
+	}
+}
+
+// isAdminEmail reports whether the email is allowed to use the admin API
+func isAdminEmail(cfg *config.Config, email string) bool {
+	if cfg == nil || email == "" {
+		return false
+	}
+	for _, allowed := range cfg.Admin.Emails {
+		if strings.EqualFold(strings.TrimSpace(allowed), email) {
+			return true
+		}
+	}
+	return false
+}
+
+// adminLogger adapts the request logger to services.Logger
+type adminLogger struct {
+	c buffalo.Context
+}
+
+func (l adminLogger) with(args []interface{}) buffalo.Logger {
+	fields := map[string]interface{}{"admin": l.c.Value("user_email")}
+	for i := 0; i+1 < len(args); i += 2 {
+		fields[fmt.Sprint(args[i])] = args[i+1]
+	}
+	return l.c.Logger().WithFields(fields)
+}
+
+func (l adminLogger) Info(msg string, args ...interface{})  { l.with(args).Info(msg) }
+func (l adminLogger) Warn(msg string, args ...interface{})  { l.with(args).Warn(msg) }
+func (l adminLogger) Error(msg string, args ...interface{}) { l.with(args).Error(msg) }
+
+// adminUserService builds a UserService bound to the request transaction
+func adminUserService(c buffalo.Context) services.UserService {
+	tx := c.Value("tx").(*pop.Connection)
+	logger := adminLogger{c}
+	return services.NewUserService(
+		repository.NewPopUserRepository(tx),
+		services.NewStorageService(GetConfig(), logger),
+		logger,
+	)
+}
+
+// adminTokenService builds a TokenService bound to the request transaction
+func adminTokenService(c buffalo.Context) services.TokenService {
+	tx := c.Value("tx").(*pop.Connection)
+	return services.NewTokenService(
+		repository.NewPopApiTokenRepository(tx),
+		repository.NewPopUserRepository(tx),
+		adminLogger{c},
+	)
+}
+
+// adminError maps service errors to HTTP statuses
+func adminError(c buffalo.Context, err error) error {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		return c.Error(http.StatusNotFound, err)
+	case errors.Is(err, services.ErrUserAlreadyDisabled), errors.Is(err, services.ErrUserAlreadyEnabled):
+		return c.Error(http.StatusConflict, err)
+	default:
+		return c.Error(http.StatusBadRequest, err)
+	}
+}
+
+// adminListUsers returns all users
+func adminListUsers(c buffalo.Context) error {
+	users, err := adminUserService(c).List(c)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{"users": users}))
+}
+
+// adminGetUser returns a single user by email
+func adminGetUser(c buffalo.Context) error {
+	user, err := adminUserService(c).Get(c, c.Param("email"))
+	if err != nil {
+		return adminError(c, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(user))
+}
+
+// adminSetStoragePath sets or resets (empty path) a user's storage path
+func adminSetStoragePath(c buffalo.Context) error {
+	var req struct {
+		Path   string `json:"path"`
+		DryRun bool   `json:"dry_run"` // Only validate; the user is returned unchanged
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	svc := adminUserService(c)
+	update := svc.SetStoragePath
+	if req.DryRun {
+		update = svc.CheckStoragePath
+	}
+	if err := update(c, c.Param("email"), req.Path); err != nil {
+		return adminError(c, err)
+	}
+	if !req.DryRun {
+		if err := recordAudit(c, c.Value("tx").(*pop.Connection), auditUserStorage, "email", c.Param("email"), "path", req.Path); err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+	}
+	return adminGetUser(c)
+}
+
+// adminSetKindleEmail sets or clears (empty address) a user's Kindle address
+func adminSetKindleEmail(c buffalo.Context) error {
+	var req struct {
+		Address string `json:"address"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+	if err := adminUserService(c).SetKindleEmail(c, c.Param("email"), req.Address); err != nil {
+		return adminError(c, err)
+	}
+	return adminGetUser(c)
+}
+
+// adminDisableUser disables a user account
+func adminDisableUser(c buffalo.Context) error {
+	if err := adminUserService(c).Disable(c, c.Param("email")); err != nil {
+		return adminError(c, err)
+	}
+	if err := recordAudit(c, c.Value("tx").(*pop.Connection), auditUserDisable, "email", c.Param("email")); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return adminGetUser(c)
+}
+
+// adminEnableUser re-enables a user account
+func adminEnableUser(c buffalo.Context) error {
+	if err := adminUserService(c).Enable(c, c.Param("email")); err != nil {
+		return adminError(c, err)
+	}
+	if err := recordAudit(c, c.Value("tx").(*pop.Connection), auditUserEnable, "email", c.Param("email")); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return adminGetUser(c)
+}
+
+// adminListTokens returns a user's service tokens
+func adminListTokens(c buffalo.Context) error {
+	tokens, err := adminTokenService(c).List(c, c.Param("email"))
+	if err != nil {
+		return adminError(c, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{"tokens": tokens}))
+}
+
+// adminCreateToken creates a service token; the full token is only returned here
+func adminCreateToken(c buffalo.Context) error {
+	var req struct {
+		Name   string `json:"name"`
+		Expiry string `json:"expiry"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+	if req.Name == "" {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("name is required"))
+	}
+
+	token, err := adminTokenService(c).Create(c, c.Param("email"), req.Name, req.Expiry)
+	if err != nil {
+		return adminError(c, err)
+	}
+	if err := recordAudit(c, c.Value("tx").(*pop.Connection), auditTokenCreate, "email", c.Param("email"), "name", req.Name); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusCreated, r.JSON(map[string]string{"token": token}))
+}
+
+// adminRevokeToken revokes a service token by ID
+func adminRevokeToken(c buffalo.Context) error {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+	if req.Reason == "" {
+		req.Reason = "Revoked via admin API"
+	}
+
+	if err := adminTokenService(c).Revoke(c, c.Param("id"), req.Reason); err != nil {
+		return adminError(c, err)
+	}
+	if err := recordAudit(c, c.Value("tx").(*pop.Connection), auditTokenRevoke, "token_id", c.Param("id")); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusNoContent, nil)
+}
+
+// adminListClips returns a page of a user's clips
+func adminListClips(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	user := &models.User{}
+	if err := tx.Where("email = ?", c.Param("email")).First(user); err != nil {
+		return c.Error(http.StatusNotFound, services.ErrUserNotFound)
+	}
+
+	page, perPage := paginationParams(c)
+	return renderClipPage(c, tx, tx.Where("user_id = ? AND deleted_at IS NULL", user.ID), nil, page, perPage)
+}
+
+// PurgedClip is a clip removed (or, in a dry run, selected) by the trash retention sweep
+type PurgedClip struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Title     string    `json:"title"`
+	Path      string    `json:"path"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// adminPurgeTrash runs the trash retention sweep now (dry_run=true only lists what it would purge)
+func adminPurgeTrash(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	dryRun := isDryRun(c)
+
+	clips, err := PurgeExpiredTrash(tx, dryRun)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if !dryRun {
+		if err := recordAudit(c, tx, auditTrashPurge, "clips", len(clips)); err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+	}
+
+	purged := make([]PurgedClip, len(clips))
+	for i, clip := range clips {
+		purged[i] = PurgedClip{
+			ID:        clip.ID.String(),
+			UserID:    clip.UserID.String(),
+			Title:     clip.Title,
+			Path:      clip.Path,
+			DeletedAt: clip.DeletedAt.Time,
+		}
+	}
+
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"dry_run":        dryRun,
+		"retention_days": GetConfig().Storage.TrashRetentionDays,
+		"clips":          purged,
+	}))
+}
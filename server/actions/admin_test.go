@@ -0,0 +1,20 @@
+package actions
+
+import (
+	"net/http"
+
+	"server/internal/config"
+)
+
+func (as *ActionSuite) Test_AdminListUsers_Unauthorized() {
+	res := as.JSON("/api/v1/admin/users").Get()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_IsAdminEmail() {
+	cfg := &config.Config{Admin: config.AdminConfig{Emails: []string{"Admin@Example.com"}}}
+	as.True(isAdminEmail(cfg, "admin@example.com"))
+	as.False(isAdminEmail(cfg, "user@example.com"))
+	as.False(isAdminEmail(cfg, ""))
+	as.False(isAdminEmail(&config.Config{}, "admin@example.com"))
+}
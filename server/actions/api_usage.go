@@ -0,0 +1,250 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Authenticated API requests are counted per user and per credential (each
+// service token, and the login session of the extension) in one-minute
+// buckets over the last hour, so users can see their own traffic at
+// GET /api/v1/usage/api and tell why a client is throttled without asking
+// an admin. Counts are kept in memory and reset with the process, like the
+// metrics.
+
+// usageWindow is how many minutes of requests are kept
+const usageWindow = 60
+
+// sessionCredential counts requests made with a login session (JWT)
+const sessionCredential = ""
+
+// usageBucket counts the requests of one minute
+type usageBucket struct {
+	minute    int64 // Unix time / 60
+	requests  int
+	errors    int // Responses with a 4xx or 5xx status, but 429
+	throttled int // 429 responses
+}
+
+// usageCounts is a ring of the buckets of the last usageWindow minutes
+type usageCounts [usageWindow]usageBucket
+
+// add counts a request answered with status at now
+func (u *usageCounts) add(now time.Time, status int) {
+	minute := now.Unix() / 60
+	b := &u[minute%usageWindow]
+	if b.minute != minute {
+		*b = usageBucket{minute: minute}
+	}
+	b.requests++
+	switch {
+	case status == http.StatusTooManyRequests:
+		b.throttled++
+	case status >= 400:
+		b.errors++
+	}
+}
+
+// recent returns the buckets of the window ending at now, oldest first
+func (u *usageCounts) recent(now time.Time) []usageBucket {
+	minute := now.Unix() / 60
+	var buckets []usageBucket
+	for _, b := range u {
+		if b.requests > 0 && b.minute > minute-usageWindow && b.minute <= minute {
+			buckets = append(buckets, b)
+		}
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].minute < buckets[j].minute })
+	return buckets
+}
+
+// apiUsage holds the counts of each user, by credential
+var apiUsage = struct {
+	mu    sync.Mutex
+	users map[string]map[string]*usageCounts
+}{users: map[string]map[string]*usageCounts{}}
+
+// recordAPIUsage counts a request of the user with a credential
+func recordAPIUsage(userID, credential string, now time.Time, status int) {
+	apiUsage.mu.Lock()
+	defer apiUsage.mu.Unlock()
+	credentials := apiUsage.users[userID]
+	if credentials == nil {
+		credentials = map[string]*usageCounts{}
+		apiUsage.users[userID] = credentials
+	}
+	counts := credentials[credential]
+	if counts == nil {
+		counts = &usageCounts{}
+		credentials[credential] = counts
+	}
+	counts.add(now, status)
+}
+
+// userAPIUsage returns the recent buckets of each of the user's credentials
+func userAPIUsage(userID string, now time.Time) map[string][]usageBucket {
+	apiUsage.mu.Lock()
+	defer apiUsage.mu.Unlock()
+	usage := map[string][]usageBucket{}
+	for credential, counts := range apiUsage.users[userID] {
+		if buckets := counts.recent(now); len(buckets) > 0 {
+			usage[credential] = buckets
+		}
+	}
+	return usage
+}
+
+// apiUsageMiddleware counts the requests authMiddleware let through, with
+// the status they were answered with
+func apiUsageMiddleware(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		err := next(c)
+
+		userID, _ := c.Value("user_id").(string)
+		if userID == "" {
+			return err
+		}
+		status := http.StatusOK
+		var herr buffalo.HTTPError
+		if errors.As(err, &herr) {
+			status = herr.Status
+		} else if err != nil {
+			status = http.StatusInternalServerError
+		} else if res, ok := c.Response().(*buffalo.Response); ok && res.Status != 0 {
+			status = res.Status
+		}
+		tokenID, _ := c.Value("token_id").(string)
+		recordAPIUsage(userID, tokenID, time.Now(), status)
+		return err
+	}
+}
+
+// UsageMinute counts the requests of one minute
+type UsageMinute struct {
+	At        time.Time `json:"at"` // Start of the minute
+	Requests  int       `json:"requests"`
+	Errors    int       `json:"errors"`    // 4xx and 5xx responses, but 429
+	Throttled int       `json:"throttled"` // 429 responses
+}
+
+// RateLimitStatus tells whether requests are rate limited, and how many
+// each credential (token, or login sessions) may make
+type RateLimitStatus struct {
+	Enabled           bool `json:"enabled"`
+	RequestsPerMinute int  `json:"requests_per_minute,omitempty"`
+	Burst             int  `json:"burst,omitempty"`
+}
+
+// TokenUsage is the activity of one of the user's service tokens
+type TokenUsage struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Revoked    bool       `json:"revoked"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Requests   int        `json:"requests"` // In the window
+}
+
+// APIUsage is the user's recent API traffic
+type APIUsage struct {
+	Since           time.Time       `json:"since"` // Start of the window, or the server start if later
+	WindowMinutes   int             `json:"window_minutes"`
+	Requests        int             `json:"requests"` // In the window
+	Errors          int             `json:"errors"`
+	Throttled       int             `json:"throttled"`
+	SessionRequests int             `json:"session_requests"` // Made with a login session rather than a token
+	Minutes         []UsageMinute   `json:"minutes"`          // Minutes with requests, oldest first
+	RateLimit       RateLimitStatus `json:"rate_limit"`
+	Tokens          []TokenUsage    `json:"tokens"` // Most recently used first
+}
+
+// getAPIUsage returns the user's requests of the last hour, by minute and
+// by token, with their rate limit status
+func getAPIUsage(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	now := time.Now()
+	since := metricsStarted
+	if since.Before(now.Add(-usageWindow * time.Minute)) {
+		since = now.Add(-usageWindow * time.Minute)
+	}
+	usage := APIUsage{
+		Since:         since.UTC(),
+		WindowMinutes: usageWindow,
+		Minutes:       []UsageMinute{},
+		Tokens:        []TokenUsage{},
+	}
+	if rl := GetConfig().RateLimit; rl.Enabled {
+		usage.RateLimit = RateLimitStatus{Enabled: true, RequestsPerMinute: rl.PerUser.RequestsPerMinute, Burst: rl.PerUser.Burst}
+		if usage.RateLimit.Burst == 0 {
+			usage.RateLimit.Burst = rl.PerUser.RequestsPerMinute
+		}
+	}
+
+	minutes := map[int64]*UsageMinute{}
+	byCredential := userAPIUsage(userIDStr, now)
+	for credential, buckets := range byCredential {
+		for _, b := range buckets {
+			m := minutes[b.minute]
+			if m == nil {
+				m = &UsageMinute{At: time.Unix(b.minute*60, 0).UTC()}
+				minutes[b.minute] = m
+			}
+			m.Requests += b.requests
+			m.Errors += b.errors
+			m.Throttled += b.throttled
+
+			usage.Requests += b.requests
+			usage.Errors += b.errors
+			usage.Throttled += b.throttled
+			if credential == sessionCredential {
+				usage.SessionRequests += b.requests
+			}
+		}
+	}
+	for _, m := range minutes {
+		usage.Minutes = append(usage.Minutes, *m)
+	}
+	sort.Slice(usage.Minutes, func(i, j int) bool { return usage.Minutes[i].At.Before(usage.Minutes[j].At) })
+
+	tokens := models.ApiTokens{}
+	if err := tx.Where("user_id = ?", userID).Order("last_used_at DESC, created_at DESC").All(&tokens); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	for _, token := range tokens {
+		tu := TokenUsage{
+			ID:      token.ID.String(),
+			Name:    token.Name,
+			Prefix:  token.Prefix,
+			Revoked: token.Revoked,
+		}
+		if token.ExpiresAt.Valid {
+			tu.ExpiresAt = &token.ExpiresAt.Time
+		}
+		if token.LastUsedAt.Valid {
+			tu.LastUsedAt = &token.LastUsedAt.Time
+		}
+		for _, b := range byCredential[tu.ID] {
+			tu.Requests += b.requests
+		}
+		usage.Tokens = append(usage.Tokens, tu)
+	}
+
+	return c.Render(http.StatusOK, r.JSON(usage))
+}
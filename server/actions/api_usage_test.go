@@ -0,0 +1,76 @@
+package actions
+
+import (
+	"net/http"
+	"time"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_APIUsage() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+
+	usage := func() APIUsage {
+		res := client.Get("/api/v1/usage/api")
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		var u APIUsage
+		res.JSON(&u)
+		return u
+	}
+	as.Equal(0, usage().Requests)
+
+	as.Equal(http.StatusOK, client.Get("/api/v1/clips").Code)
+	as.Equal(http.StatusNotFound, client.Get("/api/v1/clips/"+kit.CreateClip(kit.CreateUser()).ID.String()).Code)
+
+	// The extension's login session is counted apart from tokens
+	tokens, err := generateTokens(user)
+	as.NoError(err)
+	token := client.Token
+	client.Token = tokens.AccessToken
+	as.Equal(http.StatusOK, client.Get("/api/v1/clips").Code)
+	client.Token = token
+
+	u := usage()
+	as.Equal(usageWindow, u.WindowMinutes)
+	as.False(u.RateLimit.Enabled)
+	// The earlier usage request counts too
+	as.Equal(4, u.Requests)
+	as.Equal(1, u.Errors)
+	as.Equal(0, u.Throttled)
+	as.Equal(1, u.SessionRequests)
+	as.NotEmpty(u.Minutes)
+	as.Require().Len(u.Tokens, 1)
+	as.Equal(3, u.Tokens[0].Requests)
+	as.False(u.Tokens[0].Revoked)
+
+	// Other users see their own usage only
+	other := kit.Client(newKitApp(kit), kit.CreateUser())
+	res := other.Get("/api/v1/usage/api")
+	var theirs APIUsage
+	res.JSON(&theirs)
+	as.Equal(0, theirs.Requests)
+}
+
+func (as *ActionSuite) Test_UsageCountsWindow() {
+	var counts usageCounts
+	start := time.Date(2026, 10, 16, 12, 0, 30, 0, time.UTC)
+	counts.add(start, http.StatusOK)
+	counts.add(start.Add(time.Minute), http.StatusTooManyRequests)
+	counts.add(start.Add(time.Minute), http.StatusBadRequest)
+
+	buckets := counts.recent(start.Add(2 * time.Minute))
+	as.Require().Len(buckets, 2)
+	as.Equal(1, buckets[0].requests)
+	as.Equal(2, buckets[1].requests)
+	as.Equal(1, buckets[1].throttled)
+	as.Equal(1, buckets[1].errors)
+
+	// An hour later, the first minute is out of the window and its slot reused
+	as.Len(counts.recent(start.Add(usageWindow*time.Minute)), 1)
+	counts.add(start.Add(usageWindow*time.Minute), http.StatusOK)
+	buckets = counts.recent(start.Add(usageWindow * time.Minute))
+	as.Require().Len(buckets, 2)
+	as.Equal(1, buckets[1].requests)
+}
@@ -1,16 +1,23 @@
 package actions
 
 import (
+	"fmt"
 	"log"
+	"log/slog"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"server/internal/config"
+	"server/internal/logging"
 	"server/models"
 
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/buffalo-pop/v3/pop/popmw"
 	"github.com/gobuffalo/envy"
+	"github.com/gobuffalo/pop/v6"
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/github"
 	"github.com/markbates/goth/providers/openidConnect"
 )
 
@@ -19,9 +26,10 @@ import (
 var ENV = envy.Get("GO_ENV", "development")
 
 var (
-	app     *buffalo.App
-	appOnce sync.Once
-	cfg     *config.Config
+	app        *buffalo.App
+	appOnce    sync.Once
+	appConfig  atomic.Pointer[config.Config] // Swapped by ReloadConfig
+	configPath string                        // Where appConfig was loaded from
 )
 
 // App is where all routes and middleware for buffalo
@@ -30,108 +38,328 @@ var (
 func App() *buffalo.App {
 	appOnce.Do(func() {
 		// Find and load configuration
-		configPath, err := config.FindConfigPath()
+		var cfg *config.Config
+		path, err := config.FindConfigPath()
 		if err != nil {
 			log.Printf("Warning: %v", err)
 			log.Println("Using default configuration")
 			cfg = &config.Config{}
 		} else {
-			cfg, err = config.Load(configPath)
+			cfg, err = config.Load(path)
 			if err != nil {
-				log.Printf("Warning: Could not load config from %s: %v", configPath, err)
+				log.Printf("Warning: Could not load config from %s: %v", path, err)
 				cfg = &config.Config{}
+			} else {
+				configPath = path
 			}
 		}
+		appConfig.Store(cfg)
+		logging.Setup(cfg.Logging)
 
 		// Log dev mode status
-		if cfg.DevMode.Enabled {
+		if cfg.DevMode.Enabled && !devBuild {
+			log.Println("Warning: dev_mode is ignored by production builds")
+		} else if cfg.DevMode.Enabled {
 			log.Println("WARNING: Dev mode is ENABLED - authentication is bypassed!")
 		}
+		clipFS = chaosFS(cfg)
+		if _, err := clipKey(); err != nil {
+			log.Fatalf("Storage encryption: %v", err) // Rather than writing clips in plaintext
+		}
 
 		// Setup OAuth provider (only if configured and not in dev mode)
 		if cfg.OAuth.ClientID != "" && cfg.OAuth.ClientSecret != "" {
 			setupOAuth()
-		} else if !cfg.DevMode.Enabled {
+		} else if !devModeEnabled(cfg) {
 			log.Println("Warning: OAuth not configured, auth endpoints will not work")
 		}
 
-		app = buffalo.New(buffalo.Options{
-			Env:         ENV,
-			SessionName: "_clipper_session",
-		})
-
-		// CORS middleware
-		app.Use(corsMiddleware)
-
-		// Wraps each request in a transaction.
-		app.Use(popmw.Transaction(models.DB))
-
-		// Routes
-		app.GET("/health", healthCheck)
-
-		// Auth routes
-		auth := app.Group("/auth")
-		auth.GET("/login", authLogin)
-		auth.GET("/callback", authCallback)
-		auth.POST("/refresh", authRefresh)
-		auth.POST("/logout", authLogout)
-		auth.GET("/dev-token", authDevToken) // Dev mode only
-		auth.GET("/test-success", authTestSuccess) // Test success page rendering
-
-		// API routes (protected)
-		api := app.Group("/api/v1")
-		api.Use(authMiddleware)
-		api.GET("/config", getConfig)
-		api.POST("/clips", createClip)
-		api.GET("/clips", listClips)
-		api.GET("/clips/{id}", getClip)
-		api.GET("/clips/{id}/media/{filename}", getClipMedia)
-		api.DELETE("/clips/{id}", deleteClip)
+		app = newApp(models.DB)
 	})
 
 	return app
 }
 
-// setupOAuth configures the OpenID Connect provider based on config
-func setupOAuth() {
-	var discoveryURL string
-	var providerName string
+// newApp builds the middleware and routes on top of db. App uses the
+// configured database; tests can pass an isolated one from internal/testkit.
+func newApp(db *pop.Connection) *buffalo.App {
+	app := buffalo.New(buffalo.Options{
+		Env:            ENV,
+		SessionName:    "_clipper_session",
+		MethodOverride: methodOverride,
+		Logger:         logging.Buffalo(slog.Default()),
+	})
 
-	switch cfg.OAuth.Provider {
-	case "google":
-		discoveryURL = "https://accounts.google.com/.well-known/openid-configuration"
-		providerName = "google"
-	case "keycloak":
-		discoveryURL = cfg.OAuth.Keycloak.BaseURL +
-			"/realms/" + cfg.OAuth.Keycloak.Realm +
-			"/.well-known/openid-configuration"
-		providerName = "keycloak"
-	default:
-		log.Printf("Warning: Unknown OAuth provider: %s", cfg.OAuth.Provider)
+	// Requests get an ID; errors are answered as {"error": {code, message, request_id}}
+	useErrorEnvelope(app)
+
+	// Panics and 5xx responses go to error_reporting.dsn
+	if reporter := newErrorReporter(); reporter != nil {
+		app.Use(errorReportingMiddleware(reporter))
+	}
+
+	// CORS middleware
+	app.Use(corsMiddleware)
+
+	// Bodies over server.max_body_bytes (max_clip_body_bytes for clips) get a 413
+	app.Use(bodyLimitMiddleware)
+
+	// Undoes file writes when a request's transaction is rolled back
+	app.Use(rollbackMiddleware)
+
+	// Wraps each request in a transaction.
+	app.Use(popmw.Transaction(db))
+
+	// Work that outlives the request (webhook deliveries) uses the same database
+	app.Use(dbMiddleware(db))
+
+	// Routes
+	app.GET("/health", healthCheck)
+	app.GET("/healthz", healthCheck)
+	app.GET("/health/ready", healthReady)
+	app.GET("/readyz", healthReady)
+	// Probes answer whether the database does; readiness queries it directly
+	app.Middleware.Skip(popmw.Transaction(db), healthCheck, healthReady, serveOpenAPI)
+	app.GET("/metrics", serveMetrics)
+	app.GET("/openapi.json", serveOpenAPI)
+
+	// rate_limit: by client address on /auth, /api/v1 and the public routes,
+	// then by credential
+	limiter := newRateLimiter()
+
+	// Public routes: share links and collection feeds (no auth)
+	registerPublicRoutes(app, limiter)
+
+	// Auth routes
+	auth := app.Group("/auth")
+	if limiter != nil {
+		auth.Use(limiter.byIP)
+	}
+	auth.GET("/login", authLogin)
+	auth.GET("/callback", authCallback)
+	auth.GET("/extension/start", authExtensionStart)
+	auth.POST("/extension/exchange", authExtensionExchange)
+	auth.POST("/device/code", authDeviceCode)
+	auth.GET("/device", authDevice)
+	auth.POST("/device", authDeviceConfirm)
+	auth.POST("/device/token", authDeviceToken)
+	auth.Middleware.Skip(popmw.Transaction(db), authDeviceToken) // Saves polls along with errors
+	auth.POST("/refresh", authRefresh)
+	auth.POST("/logout", authLogout)
+	auth.GET("/cloud/{provider}/callback", cloudCallback)
+	registerDevRoutes(app, auth)
+
+	// API routes (protected)
+	api := app.Group("/api/v1")
+	if limiter != nil {
+		api.Use(limiter.byIP)
+	}
+	api.Use(ingestionAuthMiddleware) // Counts clip attempts authMiddleware rejects
+	api.Use(authMiddleware)
+	api.Use(apiUsageMiddleware) // Counts the 429s of byCredential
+	if limiter != nil {
+		api.Use(limiter.byCredential)
+	}
+	api.GET("/config", getConfig)
+	api.PUT("/config", updateConfig)
+	api.GET("/version", getVersion)
+	api.POST("/clips", createClip)
+	api.GET("/clips", listClips)
+	api.POST("/clips/fetch", fetchClip)
+	api.POST("/clips/bulk", bulkClips)
+	api.POST("/clips/bulk-update", bulkUpdateClips)
+	api.POST("/uploads", startUpload)
+	api.GET("/uploads/{id}", getUpload)
+	api.PUT("/uploads/{id}", appendUpload)
+	api.POST("/uploads/{id}/finalize", finalizeUpload)
+	api.DELETE("/uploads/{id}", abortUpload)
+	api.POST("/quick-clip", quickClip)
+	api.GET("/clips/recent-views", listRecentViews)
+	api.GET("/clips/{id}", getClip)
+	api.GET("/clips/{id}/media/{filename}", getClipMedia)
+	api.GET("/clips/{id}/favicon", getClipFavicon)
+	api.DELETE("/clips/{id}", deleteClip)
+	api.GET("/clips/{id}/export", exportClip)
+	api.POST("/clips/{id}/send-to-kindle", sendClipToKindle)
+	api.GET("/clips/{id}/versions", listClipVersions)
+	api.GET("/clips/{id}/versions/diff", diffClipVersions)
+	api.GET("/clips/{id}/highlights", listHighlights)
+	api.POST("/clips/{id}/highlights", createHighlight)
+	api.PUT("/clips/{id}/highlights/{highlight_id}", updateHighlight)
+	api.DELETE("/clips/{id}/highlights/{highlight_id}", deleteHighlight)
+	api.GET("/clips/{id}/shares", listShares)
+	api.POST("/clips/{id}/shares", createShare)
+	api.DELETE("/shares/{id}", deleteShare)
+	api.GET("/deliveries/{id}", getDelivery)
+	api.GET("/jobs/{id}", getJob)
+	api.GET("/collections", listCollections)
+	api.POST("/collections", createCollection)
+	api.PUT("/collections/{id}", updateCollection)
+	api.DELETE("/collections/{id}", deleteCollection)
+	api.GET("/searches", listSavedSearches)
+	api.POST("/searches", createSavedSearch)
+	api.GET("/searches/{id}", getSavedSearch)
+	api.PUT("/searches/{id}", updateSavedSearch)
+	api.DELETE("/searches/{id}", deleteSavedSearch)
+	api.GET("/searches/{id}/clips", runSavedSearch)
+	api.GET("/templates/frontmatter", getFrontmatterTemplate)
+	api.PUT("/templates/frontmatter", updateFrontmatterTemplate)
+	api.GET("/templates/naming", getNamingTemplate)
+	api.PUT("/templates/naming", updateNamingTemplate)
+	api.GET("/rules", listClipRules)
+	api.POST("/rules", createClipRule)
+	api.PUT("/rules/{id}", updateClipRule)
+	api.DELETE("/rules/{id}", deleteClipRule)
+	api.GET("/retention-rules", listRetentionRules)
+	api.POST("/retention-rules", createRetentionRule)
+	api.PUT("/retention-rules/{id}", updateRetentionRule)
+	api.DELETE("/retention-rules/{id}", deleteRetentionRule)
+	api.GET("/tags", listTags)
+	api.PUT("/tags/{name}", updateTagRules)
+	api.GET("/taxonomy", getTaxonomy)
+	api.GET("/usage/api", getAPIUsage)
+	api.GET("/me", getProfile)
+	api.GET("/me/usage", getDiskUsage)
+	api.GET("/trash", listTrash)
+	api.DELETE("/trash", emptyTrash)
+	api.POST("/trash/{id}/restore", restoreClip)
+	api.DELETE("/trash/{id}", purgeTrashedClip)
+	api.GET("/events", streamEvents)
+	api.Middleware.Skip(popmw.Transaction(db), streamEvents) // Long-lived; queries db directly
+	api.GET("/cloud", listCloudConnections)
+	api.POST("/cloud/{provider}/connect", connectCloud)
+	api.DELETE("/cloud/{provider}", disconnectCloud)
+	api.GET("/webhooks", listWebhooks)
+	api.POST("/webhooks", createWebhook)
+	api.DELETE("/webhooks/{id}", deleteWebhook)
+	api.GET("/webhooks/{id}/secret-rotation", getWebhookSecretRotation)
+	api.POST("/webhooks/{id}/secret-rotation", rotateWebhookSecret)
+	api.POST("/webhooks/{id}/verify", verifyWebhookSignature)
+	api.GET("/webhooks/{id}/deliveries", listWebhookDeliveries)
+	api.POST("/webhooks/{id}/deliveries/{delivery_id}/replay", replayWebhookDelivery)
+
+	// Admin API (users listed in admin.emails), used by the CLI's --remote mode
+	admin := api.Group("/admin")
+	admin.Use(adminMiddleware)
+	admin.GET("/users", adminListUsers)
+	admin.GET("/users/{email}", adminGetUser)
+	admin.PUT("/users/{email}/storage", adminSetStoragePath)
+	admin.PUT("/users/{email}/kindle", adminSetKindleEmail)
+	admin.POST("/users/{email}/disable", adminDisableUser)
+	admin.POST("/users/{email}/enable", adminEnableUser)
+	admin.GET("/users/{email}/tokens", adminListTokens)
+	admin.POST("/users/{email}/tokens", adminCreateToken)
+	admin.GET("/users/{email}/clips", adminListClips)
+	admin.POST("/users/{email}/transfer", adminTransferClips)
+	admin.POST("/tokens/{id}/revoke", adminRevokeToken)
+	admin.POST("/trash/purge", adminPurgeTrash)
+	admin.POST("/retention", adminExpireClips)
+	admin.GET("/scheduler", adminSchedulerStatus)
+	admin.POST("/scheduler/{task}", adminRunTask)
+	admin.POST("/janitor", adminCleanClipFolders)
+	admin.POST("/mirror", adminPublishMirror)
+	admin.GET("/replication", adminReplicationStatus)
+	admin.POST("/replication", adminReplicate)
+	admin.GET("/reports", adminListReports)
+	admin.POST("/reports/{id}/resolve", adminResolveReport)
+	admin.POST("/shares/{id}/disable", adminDisableShare)
+	admin.POST("/shares/{id}/enable", adminEnableShare)
+	admin.POST("/clips/{id}/disable", adminDisableClip)
+	admin.POST("/clips/{id}/enable", adminEnableClip)
+	admin.POST("/storage/dedupe", adminDedupeMedia)
+	admin.GET("/metrics/ingestion", adminIngestionMetrics)
+	admin.GET("/consistency", adminConsistency)
+	admin.GET("/audit", adminListAuditLogs)
+	admin.GET("/taxonomy", getTaxonomy)
+	admin.POST("/taxonomy", adminCreateTaxonomyTag)
+	admin.PUT("/taxonomy/{id}", adminUpdateTaxonomyTag)
+	admin.DELETE("/taxonomy/{id}", adminDeleteTaxonomyTag)
+
+	return app
+}
+
+// setupOAuth configures the OAuth provider based on config
+func setupOAuth() {
+	provider, err := newOAuthProvider(GetConfig().OAuth)
+	if err != nil {
+		log.Printf("Warning: Could not setup OAuth provider: %v", err)
 		return
 	}
+	goth.UseProviders(provider)
+}
+
+// newOAuthProvider returns the provider of oauth.provider, named after it:
+// GitHub's OAuth app, or an OpenID Connect provider for the others
+func newOAuthProvider(oauth config.OAuthConfig) (goth.Provider, error) {
+	if oauth.Provider == "github" {
+		scopes := oauthScopes(oauth, "read:user", "user:email")
+		base := strings.TrimSuffix(oauth.GitHub.BaseURL, "/")
+		if base == "" {
+			return github.New(oauth.ClientID, oauth.ClientSecret, oauth.RedirectURL, scopes...), nil
+		}
+		// GitHub Enterprise Server
+		return github.NewCustomisedURL(oauth.ClientID, oauth.ClientSecret, oauth.RedirectURL,
+			base+"/login/oauth/authorize", base+"/login/oauth/access_token",
+			base+"/api/v3/user", base+"/api/v3/user/emails", scopes...), nil
+	}
 
+	discoveryURL := oauthDiscoveryURL(oauth)
+	if discoveryURL == "" {
+		return nil, fmt.Errorf("unknown OAuth provider: %s", oauth.Provider)
+	}
 	provider, err := openidConnect.New(
-		cfg.OAuth.ClientID,
-		cfg.OAuth.ClientSecret,
-		cfg.OAuth.RedirectURL,
+		oauth.ClientID,
+		oauth.ClientSecret,
+		oauth.RedirectURL,
 		discoveryURL,
-		"openid", "email", "profile",
+		oauthScopes(oauth, "openid", "email", "profile")...,
 	)
 	if err != nil {
-		log.Printf("Warning: Could not setup OAuth provider: %v", err)
-		return
+		return nil, err
 	}
-	provider.SetName(providerName)
-	goth.UseProviders(provider)
+	provider.SetName(oauth.Provider)
+	return provider, nil
+}
+
+// oauthScopes returns oauth.scopes, or defaults when none are configured
+func oauthScopes(oauth config.OAuthConfig, defaults ...string) []string {
+	if len(oauth.Scopes) > 0 {
+		return oauth.Scopes
+	}
+	return defaults
+}
+
+// oauthDiscoveryURL returns the OpenID Connect discovery document of the
+// provider, or "" for an unknown one and GitHub, which has none
+func oauthDiscoveryURL(oauth config.OAuthConfig) string {
+	switch oauth.Provider {
+	case "google":
+		return "https://accounts.google.com/.well-known/openid-configuration"
+	case "keycloak":
+		return oauth.Keycloak.BaseURL +
+			"/realms/" + oauth.Keycloak.Realm +
+			"/.well-known/openid-configuration"
+	case "gitlab":
+		base := oauth.GitLab.BaseURL
+		if base == "" {
+			base = "https://gitlab.com"
+		}
+		return strings.TrimSuffix(base, "/") + "/.well-known/openid-configuration"
+	case "oidc":
+		if oauth.OIDC.IssuerURL == "" {
+			return ""
+		}
+		return strings.TrimSuffix(oauth.OIDC.IssuerURL, "/") + "/.well-known/openid-configuration"
+	}
+	return ""
 }
 
 // corsMiddleware handles CORS headers for the extension
 func corsMiddleware(next buffalo.Handler) buffalo.Handler {
 	return func(c buffalo.Context) error {
 		c.Response().Header().Set("Access-Control-Allow-Origin", "*")
-		c.Response().Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-		c.Response().Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		c.Response().Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Response().Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Idempotency-Key")
 
 		if c.Request().Method == "OPTIONS" {
 			return c.Render(200, nil)
@@ -140,12 +368,39 @@ func corsMiddleware(next buffalo.Handler) buffalo.Handler {
 	}
 }
 
-// healthCheck returns server status
+// dbKey holds the app's database connection, outside the request transaction
+const dbKey = "db"
+
+// dbMiddleware makes db available to handlers through appDB. Handlers that
+// skip the request transaction get db as "tx" too.
+func dbMiddleware(db *pop.Connection) buffalo.MiddlewareFunc {
+	return func(next buffalo.Handler) buffalo.Handler {
+		return func(c buffalo.Context) error {
+			c.Set(dbKey, db)
+			if _, ok := c.Value("tx").(*pop.Connection); !ok {
+				c.Set("tx", db)
+			}
+			return next(c)
+		}
+	}
+}
+
+// appDB returns the app's database connection, for work that runs after the
+// request's transaction is committed
+func appDB(c buffalo.Context) *pop.Connection {
+	if db, ok := c.Value(dbKey).(*pop.Connection); ok {
+		return db
+	}
+	return models.DB
+}
+
+// healthCheck is the liveness probe: the process answers requests
 func healthCheck(c buffalo.Context) error {
 	return c.Render(200, r.JSON(map[string]string{"status": "ok"}))
 }
 
-// GetConfig returns the loaded configuration (for use by other actions)
+// GetConfig returns the loaded configuration (for use by other actions).
+// Read it once per request: ReloadConfig replaces it rather than editing it.
 func GetConfig() *config.Config {
-	return cfg
+	return appConfig.Load()
 }
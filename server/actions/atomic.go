@@ -0,0 +1,175 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"server/internal/fsys"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gofrs/uuid"
+)
+
+// clipFS is what clip folders are written through: the OS, or in dev mode
+// with dev_mode.chaos a file system injecting storage faults
+var clipFS fsys.FS = fsys.OS{}
+
+// rollbackHooksKey and commitHooksKey hold the functions registered with
+// onRollback and onCommit
+const (
+	rollbackHooksKey = "rollback_hooks"
+	commitHooksKey   = "commit_hooks"
+)
+
+// rollbackMiddleware runs the cleanups registered with onRollback when the
+// request's transaction is not committed: an error, a 4xx/5xx response or a
+// failed commit. Otherwise it runs those registered with onCommit. It must
+// wrap popmw.Transaction to see commit errors.
+func rollbackMiddleware(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		hooks := &[]func(){}
+		c.Set(rollbackHooksKey, hooks)
+		commitHooks := &[]func(){}
+		c.Set(commitHooksKey, commitHooks)
+
+		err := next(c)
+
+		rolledBack := err != nil
+		if res, ok := c.Response().(*buffalo.Response); ok && res.Status >= 400 {
+			rolledBack = true
+		}
+		if rolledBack {
+			for i := len(*hooks) - 1; i >= 0; i-- {
+				(*hooks)[i]()
+			}
+		} else {
+			for _, fn := range *commitHooks {
+				fn()
+			}
+		}
+		return err
+	}
+}
+
+// onCommit registers fn to run once the request's transaction is committed,
+// for side effects others must not see before the rows exist
+func onCommit(c buffalo.Context, fn func()) {
+	if hooks, ok := c.Value(commitHooksKey).(*[]func()); ok {
+		*hooks = append(*hooks, fn)
+	}
+}
+
+// onRollback registers fn to undo a side effect (typically files written)
+// if the request's transaction ends up rolled back
+func onRollback(c buffalo.Context, fn func()) {
+	if hooks, ok := c.Value(rollbackHooksKey).(*[]func()); ok {
+		*hooks = append(*hooks, fn)
+	}
+}
+
+// newStagingDir creates a temporary folder next to the clip folders, so a
+// finished capture can be renamed into place atomically (same filesystem)
+func newStagingDir(clipDir string) (string, error) {
+	parent := filepath.Join(clipDir, "web-clips")
+	if err := clipFS.MkdirAll(parent, 0755); err != nil {
+		return "", err
+	}
+	return clipFS.MkdirTemp(parent, ".staging-")
+}
+
+// publishClipFolder moves a staged capture to its final folder, creating its
+// parents (naming templates can nest clip folders). It never merges into an
+// existing folder: that returns os.ErrExist. On failure the capture is left
+// in staging.
+func publishClipFolder(staging, folderPath string) error {
+	if _, err := os.Lstat(folderPath); err == nil {
+		return fmt.Errorf("%s: %w", folderPath, os.ErrExist)
+	}
+	if err := clipFS.MkdirAll(filepath.Dir(folderPath), 0755); err != nil {
+		return err
+	}
+	if err := os.Chmod(staging, 0755); err != nil { // MkdirTemp creates 0700
+		return err
+	}
+	if err := clipFS.Rename(staging, folderPath); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(folderPath)); err != nil {
+		os.Rename(folderPath, staging) // Back where the caller cleans up
+		return err
+	}
+	return nil
+}
+
+// maxFolderAttempts bounds the retries when a new clip's folder name is taken
+const maxFolderAttempts = 3
+
+// publishNewClipFolder moves a staged capture into web-clips under the
+// folder name returns for a new clip ID. The built-in names contain a prefix
+// of the ID, which keeps clips of the same site within the same second
+// apart. If the name is taken anyway, it retries with another ID, suffixed
+// to the name when the name doesn't depend on it.
+func publishNewClipFolder(staging, clipDir string, name func(id uuid.UUID) string) (uuid.UUID, string, error) {
+	var err error
+	previous := ""
+	for attempt := 0; attempt < maxFolderAttempts; attempt++ {
+		id := uuid.Must(uuid.NewV4())
+		base := name(id)
+		folderName := base
+		if base == previous {
+			folderName = fmt.Sprintf("%s_%s", base, id.String()[:8])
+		}
+		previous = base
+		err = publishClipFolder(staging, filepath.Join(clipDir, "web-clips", folderName))
+		if err == nil {
+			return id, folderName, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			break
+		}
+	}
+	return uuid.Nil, "", err
+}
+
+// clipFolderName returns YYYYMMDD_HHMMSS_site-slug_<first 8 hex digits of the ID>
+func clipFolderName(t time.Time, siteSlug string, id uuid.UUID) string {
+	return fmt.Sprintf("%s_%s_%s", t.Format("20060102_150405"), siteSlug, id.String()[:8])
+}
+
+// writeFileSync writes data to a file, compressed and encrypted as storage
+// is configured, and flushes it to disk
+func writeFileSync(path string, data []byte, perm os.FileMode) error {
+	data, err := compressClipData(path, data)
+	if err == nil {
+		data, err = sealClipData(data)
+	}
+	if err != nil {
+		return err
+	}
+	f, err := clipFS.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir flushes a directory's entries (created or renamed files) to disk
+func syncDir(dir string) error {
+	d, err := clipFS.OpenFile(dir, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
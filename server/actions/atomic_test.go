@@ -0,0 +1,93 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/httptest"
+	"github.com/gofrs/uuid"
+)
+
+func (as *ActionSuite) Test_PublishClipFolder() {
+	clipDir := as.T().TempDir()
+
+	staging, err := newStagingDir(clipDir)
+	as.NoError(err)
+	as.NoError(writeFileSync(filepath.Join(staging, "page.md"), []byte("# Page"), 0644))
+
+	folderPath := filepath.Join(clipDir, "web-clips", "20260101_120000_example-com")
+	as.NoError(publishClipFolder(staging, folderPath))
+
+	data, err := os.ReadFile(filepath.Join(folderPath, "page.md"))
+	as.NoError(err)
+	as.Equal("# Page", string(data))
+	_, err = os.Stat(staging)
+	as.True(os.IsNotExist(err))
+
+	// An existing folder is never merged into
+	other, err := newStagingDir(clipDir)
+	as.NoError(err)
+	err = publishClipFolder(other, folderPath)
+	as.True(errors.Is(err, os.ErrExist))
+}
+
+func (as *ActionSuite) Test_RollbackMiddleware() {
+	app := buffalo.New(buffalo.Options{})
+	app.Use(rollbackMiddleware)
+
+	var ran []string
+	handler := func(status int) buffalo.Handler {
+		return func(c buffalo.Context) error {
+			onRollback(c, func() { ran = append(ran, fmt.Sprint(status)) })
+			if status >= 400 {
+				return c.Error(status, fmt.Errorf("failed"))
+			}
+			return c.Render(status, nil)
+		}
+	}
+	app.GET("/ok", handler(http.StatusOK))
+	app.GET("/fail", handler(http.StatusUnprocessableEntity))
+
+	w := httptest.New(app)
+	w.HTML("/ok").Get()
+	as.Empty(ran)
+
+	w.HTML("/fail").Get()
+	as.Equal([]string{"422"}, ran)
+}
+
+func (as *ActionSuite) Test_PublishNewClipFolder() {
+	clipDir := as.T().TempDir()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Two captures of the same site in the same second get distinct folders
+	var names []string
+	for i := 0; i < 2; i++ {
+		staging, err := newStagingDir(clipDir)
+		as.NoError(err)
+		id, name, err := publishNewClipFolder(staging, clipDir, func(id uuid.UUID) string {
+			return clipFolderName(now, "example-com", id)
+		})
+		as.NoError(err)
+		as.Equal(clipFolderName(now, "example-com", id), name)
+		as.True(strings.HasPrefix(name, "20260301_120000_example-com_"))
+		names = append(names, name)
+	}
+	as.NotEqual(names[0], names[1])
+
+	// A name taken regardless of the ID gets one appended
+	staging, err := newStagingDir(clipDir)
+	as.NoError(err)
+	id, name, err := publishNewClipFolder(staging, clipDir, func(uuid.UUID) string { return names[0] })
+	as.NoError(err)
+	as.Equal(names[0]+"_"+id.String()[:8], name)
+
+	id = uuid.Must(uuid.FromString("0f93576e-e585-4827-8fd4-803e2a6a2f32"))
+	as.Equal("20260301_120000_example-com_0f93576e", clipFolderName(now, "example-com", id))
+}
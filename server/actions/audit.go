@@ -0,0 +1,211 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"server/internal/logging"
+	"server/internal/services"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Audit actions: clips created, re-clipped, trashed and purged, sign-ins, API tokens
+// and admin changes to users
+const (
+	auditClipCreate  = "clip.create"
+	auditClipUpdate  = "clip.update"
+	auditClipDelete  = "clip.delete"
+	auditClipPurge   = "clip.purge"
+	auditLogin       = "auth.login"
+	auditRefresh     = "auth.refresh"
+	auditLogout      = "auth.logout"
+	auditAuthFailed  = "auth.failed"
+	auditTokenCreate = "token.create"
+	auditTokenRevoke = "token.revoke"
+	auditUserStorage = "user.storage"
+	auditUserDisable = "user.disable"
+	auditUserEnable  = "user.enable"
+	auditTrashPurge  = "trash.purge"
+	auditClipsExpire = "clips.expire"
+	auditTransfer    = "clips.transfer"
+)
+
+// audit writes an audit event for the request now, with the authenticated
+// user (when there is one), the client's address and the request's ID.
+// Failures are audited this way, as their transaction is rolled back.
+func audit(c buffalo.Context, action string, args ...any) {
+	logging.Audit(c.Request().Context(), slog.Default(), action, append(auditArgs(c, args), "request_id", requestID(c))...)
+}
+
+// auditOnCommit writes an audit event once the request's transaction is
+// committed, so that nothing is audited that did not happen
+func auditOnCommit(c buffalo.Context, action string, args ...any) {
+	args = append(auditArgs(c, args), "request_id", requestID(c))
+	onCommit(c, func() {
+		logging.Audit(c.Request().Context(), slog.Default(), action, args...)
+	})
+}
+
+// recordAudit keeps a security-relevant event in the audit_logs table, in
+// the request's transaction, and writes it once committed like
+// auditOnCommit. Other events are only logged: clips are created too often
+// to be worth it, and failed authentications would let anyone fill the table.
+func recordAudit(c buffalo.Context, tx *pop.Connection, action string, args ...any) error {
+	entry := models.NewAuditLog(models.AuditSourceAPI, action, auditArgs(c, args)...)
+	if email, ok := c.Value("user_email").(string); ok && email != "" {
+		entry.Actor = nulls.NewString(email)
+	}
+	if err := tx.Create(entry); err != nil {
+		return fmt.Errorf("failed to store audit log: %w", err)
+	}
+	auditOnCommit(c, action, args...)
+	return nil
+}
+
+// auditArgs adds the user and the client's address to an event's attributes
+func auditArgs(c buffalo.Context, args []any) []any {
+	if userID, ok := c.Value("user_id").(string); ok && userID != "" {
+		args = append([]any{"user_id", userID}, args...)
+	}
+	return append(args, "ip", requestIP(c))
+}
+
+// requestIP returns the client's address: the peer's, or behind proxies
+// (logging.trust_proxy) the X-Forwarded-For entry logging.proxy_hops from
+// the right. Entries further left are whatever the client sent, so they are
+// never trusted.
+func requestIP(c buffalo.Context) string {
+	req := c.Request()
+	if cfg := GetConfig(); cfg != nil && cfg.Logging.TrustProxy {
+		if forwarded := forwardedFor(req.Header.Values("X-Forwarded-For"), cfg.Logging.ProxyHops); forwarded != "" {
+			return forwarded
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// forwardedFor returns the address hops entries from the right of the
+// X-Forwarded-For headers, or their leftmost one when there are fewer
+func forwardedFor(headers []string, hops int) string {
+	var entries []string
+	for _, header := range headers {
+		for _, entry := range strings.Split(header, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return ""
+	}
+	return entries[max(len(entries)-max(hops, 1), 0)]
+}
+
+// AuditQuery selects stored audit logs, with the admin API's parameters:
+// by action, user (Email or UserID), ClipID and time (Since, Until:
+// YYYY-MM-DD or RFC 3339). Limit defaults to 100, at most 1000.
+type AuditQuery struct {
+	Action string
+	Email  string
+	UserID string
+	ClipID string
+	Since  string
+	Until  string
+	Limit  int
+}
+
+// filter validates the query and resolves its user
+func (q AuditQuery) filter(tx *pop.Connection) (models.AuditFilter, error) {
+	f := models.AuditFilter{Action: q.Action, Limit: q.Limit}
+	if f.Limit == 0 {
+		f.Limit = 100
+	}
+	if f.Limit < 1 || f.Limit > 1000 {
+		return f, fmt.Errorf("limit must be between 1 and 1000")
+	}
+	if q.Email != "" {
+		user := &models.User{}
+		if err := tx.Where("email = ?", q.Email).First(user); err != nil {
+			return f, fmt.Errorf("%w: %s", services.ErrUserNotFound, q.Email)
+		}
+		f.UserID = user.ID
+	}
+	var err error
+	if q.UserID != "" {
+		if f.UserID, err = uuid.FromString(q.UserID); err != nil {
+			return f, fmt.Errorf("invalid user_id: %s", q.UserID)
+		}
+	}
+	if q.ClipID != "" {
+		if f.ClipID, err = uuid.FromString(q.ClipID); err != nil {
+			return f, fmt.Errorf("invalid clip_id: %s", q.ClipID)
+		}
+	}
+	if q.Since != "" {
+		if f.Since, err = parseDateParam(q.Since, false); err != nil {
+			return f, fmt.Errorf("since: %w", err)
+		}
+	}
+	if q.Until != "" {
+		if f.Until, err = parseDateParam(q.Until, true); err != nil {
+			return f, fmt.Errorf("until: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// FindAuditLogs returns the audit logs matching q, most recent first
+func FindAuditLogs(tx *pop.Connection, q AuditQuery) (models.AuditLogs, error) {
+	f, err := q.filter(tx)
+	if err != nil {
+		return nil, err
+	}
+	return models.FindAuditLogs(tx, f)
+}
+
+// adminListAuditLogs returns the stored audit logs, most recent first
+func adminListAuditLogs(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	q := AuditQuery{
+		Action: c.Param("action"),
+		Email:  c.Param("email"),
+		UserID: c.Param("user_id"),
+		ClipID: c.Param("clip_id"),
+		Since:  c.Param("since"),
+		Until:  c.Param("until"),
+	}
+	if limit := c.Param("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return c.Error(http.StatusBadRequest, fmt.Errorf("invalid limit: %s", limit))
+		}
+		q.Limit = n
+	}
+	f, err := q.filter(tx)
+	if errors.Is(err, services.ErrUserNotFound) {
+		return c.Error(http.StatusNotFound, err)
+	} else if err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+
+	logs, err := models.FindAuditLogs(tx, f)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{"logs": logs}))
+}
@@ -0,0 +1,127 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"server/internal/config"
+	"server/internal/logging"
+	"server/internal/testkit"
+	"server/models"
+)
+
+// captureAudit makes the default logger write JSON to a buffer until the
+// returned function is called, which returns the audit events written
+func captureAudit() func() []map[string]any {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, config.LoggingConfig{}))
+	return func() []map[string]any {
+		slog.SetDefault(previous)
+		var events []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var record map[string]any
+			if json.Unmarshal([]byte(line), &record) == nil && record["msg"] == logging.AuditMessage {
+				events = append(events, record)
+			}
+		}
+		return events
+	}
+}
+
+func (as *ActionSuite) Test_AuditEvents() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	app := newKitApp(kit)
+	client := kit.Client(app, user)
+
+	events := captureAudit()
+	var created ClipResponse
+	res := client.Post("/api/v1/clips", ClipPayload{Title: "Audited", URL: "https://example.com/audited", Mode: "bookmark"})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&created)
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/clips/"+created.ID).Code)
+	// A rejected request is audited, one rolled back is not
+	as.Equal(http.StatusNotFound, client.Delete("/api/v1/clips/"+created.ID).Code)
+	forged := kit.Client(app, user)
+	forged.Token = "forged"
+	as.Equal(http.StatusUnauthorized, forged.Get("/api/v1/clips").Code)
+	got := events()
+
+	as.Len(got, 3, "%v", got)
+	as.Equal(auditClipCreate, got[0]["action"])
+	as.Equal(created.ID, got[0]["clip_id"])
+	as.Equal(user.ID.String(), got[0]["user_id"])
+	as.Equal("192.0.2.1", got[0]["ip"])
+	as.Equal(auditClipDelete, got[1]["action"])
+	as.Equal(created.ID, got[1]["clip_id"])
+	as.Equal(auditAuthFailed, got[2]["action"])
+	as.Equal("invalid_token", got[2]["reason"])
+	as.NotContains(got[2], "user_id")
+
+	// Behind a proxy, the client is the address the proxy appended; what the
+	// client sent before it is not trusted
+	kit.Config.Logging.TrustProxy = true
+	events = captureAudit()
+	forged.Header = http.Header{"X-Forwarded-For": {"203.0.113.7, 198.51.100.20"}}
+	forged.Get("/api/v1/clips")
+	got = events()
+	as.Len(got, 1)
+	as.Equal("198.51.100.20", got[0]["ip"])
+
+	// Behind two proxies, the first one's
+	kit.Config.Logging.ProxyHops = 2
+	events = captureAudit()
+	forged.Header = http.Header{"X-Forwarded-For": {"203.0.113.7, 198.51.100.20", "10.0.0.1"}}
+	forged.Get("/api/v1/clips")
+	as.Equal("198.51.100.20", events()[0]["ip"])
+}
+
+func (as *ActionSuite) Test_AuditLogs() {
+	kit := testkit.New(as.T())
+	admin := kit.CreateUser()
+	user := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{admin.Email}
+	app := newKitApp(kit)
+	adminClient := kit.Client(app, admin)
+	client := kit.Client(app, user)
+
+	var created ClipResponse
+	client.Post("/api/v1/clips", ClipPayload{Title: "Gone", URL: "https://example.com/gone", Mode: "bookmark"}).JSON(&created)
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/clips/"+created.ID).Code)
+	res := adminClient.Post("/api/v1/admin/users/"+user.Email+"/disable", nil)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	// Dry runs change nothing and are not stored
+	as.Equal(http.StatusOK, adminClient.Put("/api/v1/admin/users/"+user.Email+"/storage", map[string]interface{}{"dry_run": true}).Code)
+
+	var list struct {
+		Logs []models.AuditLog `json:"logs"`
+	}
+	res = adminClient.Get("/api/v1/admin/audit")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&list)
+	as.Len(list.Logs, 2) // Clip creations are only logged
+	as.Equal(auditUserDisable, list.Logs[0].Action)
+	as.Equal(admin.ID, list.Logs[0].UserID.UUID)
+	as.Equal(admin.Email, list.Logs[0].Actor.String)
+	as.JSONEq(`{"email":"`+user.Email+`"}`, list.Logs[0].Details.String)
+	as.Equal(auditClipDelete, list.Logs[1].Action)
+	as.Equal(models.AuditSourceAPI, list.Logs[1].Source)
+	as.Equal(created.ID, list.Logs[1].ClipID.UUID.String())
+	as.Equal("192.0.2.1", list.Logs[1].IP.String)
+
+	list.Logs = nil
+	adminClient.Get("/api/v1/admin/audit?email=" + user.Email + "&action=clip.delete&since=2000-01-01").JSON(&list)
+	as.Len(list.Logs, 1)
+	list.Logs = nil
+	adminClient.Get("/api/v1/admin/audit?until=2000-01-01").JSON(&list)
+	as.Empty(list.Logs)
+	as.Equal(http.StatusBadRequest, adminClient.Get("/api/v1/admin/audit?since=yesterday").Code)
+	as.Equal(http.StatusBadRequest, adminClient.Get("/api/v1/admin/audit?limit=5000").Code)
+	as.Equal(http.StatusNotFound, adminClient.Get("/api/v1/admin/audit?email=nobody@example.com").Code)
+	other := kit.CreateUser()
+	as.Equal(http.StatusForbidden, kit.Client(app, other).Get("/api/v1/admin/audit").Code)
+}
@@ -3,6 +3,7 @@ package actions
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +15,7 @@ import (
 	"github.com/gobuffalo/nulls"
 	"github.com/gobuffalo/pop/v6"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
 )
 
@@ -24,18 +26,16 @@ type TokenResponse struct {
 	ExpiresAt    int64  `json:"expires_at"`
 }
 
-// authLogin initiates the OAuth flow via Goth
-// The redirect param is stored in the session for use after callback
+// authLogin initiates the OAuth flow via Goth. The callback returns the
+// tokens as JSON; the extension logs in with /auth/extension/start and CLIs
+// with /auth/device instead.
 func authLogin(c buffalo.Context) error {
-	// Store the redirect URL in session for use after OAuth callback
-	redirectURL := c.Param("redirect")
-	if redirectURL != "" {
-		c.Session().Set("oauth_redirect", redirectURL)
-		if err := c.Session().Save(); err != nil {
-			return c.Error(http.StatusInternalServerError, err)
-		}
-	}
+	clearPendingLogin(c)
+	return beginOAuth(c)
+}
 
+// beginOAuth redirects to the OAuth provider
+func beginOAuth(c buffalo.Context) error {
 	// Set provider from config if not specified
 	q := c.Request().URL.Query()
 	if q.Get("provider") == "" {
@@ -49,8 +49,16 @@ func authLogin(c buffalo.Context) error {
 	}
 
 	// Begin OAuth flow - this redirects to the OAuth provider
-	gothic.BeginAuthHandler(c.Response(), c.Request())
-	return nil
+	if c.Request().Method == http.MethodGet {
+		gothic.BeginAuthHandler(c.Response(), c.Request())
+		return nil
+	}
+	// After a form post, 303 so the browser doesn't post the form to the provider
+	authURL, err := gothic.GetAuthURL(c.Response(), c.Request())
+	if err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+	return c.Redirect(http.StatusSeeOther, authURL)
 }
 
 // isEmailAllowed checks if an email is allowed based on domain and email whitelists
@@ -84,8 +92,9 @@ func isEmailAllowed(email string, allowedDomains, allowedEmails []string) bool {
 	return false
 }
 
-// renderAuthSuccess renders a success page with tokens for the extension to read
-func renderAuthSuccess(c buffalo.Context, tokens *TokenResponse) error {
+// renderAuthSuccess renders a page telling the user they are logged in, and
+// what to do next
+func renderAuthSuccess(c buffalo.Context, message string) error {
 	html := fmt.Sprintf(`<!DOCTYPE html>
 <html lang="en">
 <head>
@@ -99,26 +108,16 @@ func renderAuthSuccess(c buffalo.Context, tokens *TokenResponse) error {
         .icon { font-size: 3rem; margin-bottom: 1rem; }
         h1 { color: #2e7d32; margin-bottom: 0.5rem; font-size: 1.5rem; }
         p { color: #666; margin-bottom: 1rem; line-height: 1.5; }
-        .hint { font-size: 0.875rem; color: #999; }
     </style>
 </head>
 <body>
     <div class="container">
         <div class="icon">✓</div>
         <h1>Authentication Successful</h1>
-        <p>You can close this window and return to the extension.</p>
-        <p class="hint">The extension will automatically detect your login.</p>
+        <p>%s</p>
     </div>
-    <!-- Token data for extension to read -->
-    <script>
-        window.webClipperAuth = {
-            accessToken: "%s",
-            refreshToken: "%s",
-            expiresAt: %d
-        };
-    </script>
 </body>
-</html>`, tokens.AccessToken, tokens.RefreshToken, tokens.ExpiresAt)
+</html>`, message)
 	c.Response().Header().Set("Content-Type", "text/html; charset=utf-8")
 	c.Response().WriteHeader(http.StatusOK)
 	c.Response().Write([]byte(html))
@@ -160,7 +159,9 @@ func renderAuthError(c buffalo.Context, status int, title, message string) error
 	return nil
 }
 
-// authCallback handles the OAuth callback from the provider
+// authCallback handles the OAuth callback from the provider. Logins started
+// by the extension get a one-time code on its redirect URI, those started on
+// /auth/device approve the device's code, others get the tokens as JSON.
 func authCallback(c buffalo.Context) error {
 	// Set provider from config if not in query
 	q := c.Request().URL.Query()
@@ -174,25 +175,56 @@ func authCallback(c buffalo.Context) error {
 		c.Request().URL.RawQuery = q.Encode()
 	}
 
+	challenge, extensionRedirect, state, fromExtension := pendingExtensionLogin(c)
+	deviceUserCode, fromDevice := pendingDeviceLogin(c)
+	// fail tells the extension why the login failed, or the user
+	fail := func(status int, code, title, message string) error {
+		if fromExtension {
+			return redirectToExtension(c, extensionRedirect, state, url.Values{"error": {code}, "error_description": {message}})
+		}
+		return renderAuthError(c, status, title, message)
+	}
+
 	// Check for OAuth error from provider
 	if errMsg := c.Param("error"); errMsg != "" {
 		errDesc := c.Param("error_description")
 		c.Logger().Errorf("OAuth error from provider: %s - %s", errMsg, errDesc)
-		return renderAuthError(c, http.StatusUnauthorized, "Access Denied", errDesc)
+		audit(c, auditAuthFailed, "reason", "provider_error", "error", errMsg)
+		if fromDevice {
+			denyDeviceLogin(c, deviceUserCode)
+		}
+		return fail(http.StatusUnauthorized, errMsg, "Access Denied", errDesc)
 	}
 
 	// Complete the OAuth flow
 	gothUser, err := gothic.CompleteUserAuth(c.Response(), c.Request())
 	if err != nil {
 		c.Logger().Errorf("OAuth authentication failed: %v", err)
-		return renderAuthError(c, http.StatusUnauthorized, "Authentication Failed", err.Error())
+		audit(c, auditAuthFailed, "reason", "oauth_failed")
+		return fail(http.StatusUnauthorized, "server_error", "Authentication Failed", err.Error())
+	}
+
+	// The allowed domains and emails, and the account's email, trust the
+	// email: one the provider hasn't verified could be anyone's
+	if !emailVerified(gothUser) {
+		c.Logger().Warnf("Unverified email: %s", gothUser.Email)
+		audit(c, auditAuthFailed, "reason", "email_not_verified", "email", gothUser.Email)
+		if fromDevice {
+			denyDeviceLogin(c, deviceUserCode)
+		}
+		return fail(http.StatusForbidden, "access_denied", "Email Not Verified",
+			fmt.Sprintf("The email %s is not verified. Verify it with your identity provider, then try again.", gothUser.Email))
 	}
 
 	// Check if user is allowed (by domain or email whitelist)
 	cfg := GetConfig()
 	if cfg != nil && !isEmailAllowed(gothUser.Email, cfg.OAuth.AllowedDomains, cfg.OAuth.AllowedEmails) {
 		c.Logger().Warnf("Access denied for email: %s", gothUser.Email)
-		return renderAuthError(c, http.StatusForbidden, "Access Denied",
+		audit(c, auditAuthFailed, "reason", "email_not_allowed", "email", gothUser.Email)
+		if fromDevice {
+			denyDeviceLogin(c, deviceUserCode)
+		}
+		return fail(http.StatusForbidden, "access_denied", "Access Denied",
 			fmt.Sprintf("The email %s is not authorized to access this application. Please contact an administrator.", gothUser.Email))
 	}
 
@@ -202,31 +234,50 @@ func authCallback(c buffalo.Context) error {
 	if err != nil {
 		return c.Error(http.StatusInternalServerError, err)
 	}
-
-	// Generate JWT tokens
-	tokens, err := generateTokens(user)
-	if err != nil {
+	c.Set("user_id", user.ID.String())
+	c.Set("user_email", user.Email)
+	if err := recordAudit(c, tx, auditLogin); err != nil {
 		return c.Error(http.StatusInternalServerError, err)
 	}
 
-	// Check for redirect URL (for extension callback)
-	redirectURL := c.Session().Get("oauth_redirect")
-	c.Logger().Infof("OAuth callback - redirect URL from session: %v", redirectURL)
-
-	if redirectURL != nil && redirectURL.(string) != "" {
-		// Clear the session value
-		c.Session().Delete("oauth_redirect")
-		c.Session().Save()
+	// The extension exchanges the code for tokens with its code verifier
+	if fromExtension {
+		code, err := issueExtensionCode(user, challenge)
+		if err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+		return redirectToExtension(c, extensionRedirect, state, url.Values{"code": {code}})
+	}
 
-		c.Logger().Infof("Rendering success page for extension callback")
-		// Return success page with tokens that the extension can read
-		return renderAuthSuccess(c, tokens)
+	// The device polling with the code gets the tokens
+	if fromDevice {
+		if err := approveDeviceLogin(tx, deviceUserCode, user); err != nil {
+			return renderAuthError(c, http.StatusBadRequest, "Device Not Connected", err.Error())
+		}
+		return renderAuthSuccess(c, "Your device is connected. You can close this window and return to it.")
 	}
 
-	// Return tokens as JSON if no redirect
+	// Generate JWT tokens
+	tokens, err := generateTokens(user)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
 	return c.Render(http.StatusOK, r.JSON(tokens))
 }
 
+// emailVerified reports whether the provider vouches for the user's email.
+// OpenID Connect providers say so in the email_verified claim, a boolean or,
+// for some, a string; GitHub only returns verified emails.
+func emailVerified(user goth.User) bool {
+	switch verified := user.RawData["email_verified"].(type) {
+	case bool:
+		return verified
+	case string:
+		return verified != "false"
+	}
+	return true
+}
+
 // authRefresh handles token refresh requests
 func authRefresh(c buffalo.Context) error {
 	var req struct {
@@ -249,6 +300,7 @@ func authRefresh(c buffalo.Context) error {
 		return []byte(cfg.JWT.Secret), nil
 	})
 	if err != nil || !token.Valid {
+		audit(c, auditAuthFailed, "reason", "invalid_refresh_token")
 		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid refresh token"))
 	}
 
@@ -274,6 +326,7 @@ func authRefresh(c buffalo.Context) error {
 	// Check if user is disabled
 	if user.Disabled {
 		c.Logger().Warnf("Token refresh denied for disabled user: %s", user.Email)
+		audit(c, auditAuthFailed, "reason", "account_disabled", "user_id", user.ID.String())
 		return c.Error(http.StatusForbidden, fmt.Errorf("account is disabled"))
 	}
 
@@ -282,46 +335,17 @@ func authRefresh(c buffalo.Context) error {
 	if err != nil {
 		return c.Error(http.StatusInternalServerError, err)
 	}
+	audit(c, auditRefresh, "user_id", user.ID.String())
 
 	return c.Render(http.StatusOK, r.JSON(tokens))
 }
 
 // authLogout handles user logout (client-side logout)
 func authLogout(c buffalo.Context) error {
+	audit(c, auditLogout)
 	return c.Render(http.StatusOK, r.JSON(map[string]bool{"success": true}))
 }
 
-// authDevToken provides JWT tokens for dev mode testing without OAuth
-func authDevToken(c buffalo.Context) error {
-	cfg := GetConfig()
-
-	// Reject if dev mode not enabled
-	if cfg == nil || !cfg.DevMode.Enabled {
-		return c.Error(http.StatusForbidden, fmt.Errorf("dev mode is not enabled"))
-	}
-
-	// Find or create dev user
-	tx := c.Value("tx").(*pop.Connection)
-	user, err := models.FindOrCreateByOAuthID(
-		tx,
-		cfg.DevMode.UserID,
-		cfg.DevMode.Email,
-		cfg.DevMode.Name,
-	)
-	if err != nil {
-		return c.Error(http.StatusInternalServerError, err)
-	}
-
-	// Generate tokens
-	tokens, err := generateTokens(user)
-	if err != nil {
-		return c.Error(http.StatusInternalServerError, err)
-	}
-
-	c.Logger().Warn("DEV MODE: Token generated for dev user")
-	return c.Render(http.StatusOK, r.JSON(tokens))
-}
-
 // generateTokens creates access and refresh JWT tokens for a user
 func generateTokens(user *models.User) (*TokenResponse, error) {
 	cfg := GetConfig()
@@ -362,6 +386,12 @@ func generateTokens(user *models.User) (*TokenResponse, error) {
 	}, nil
 }
 
+// devModeEnabled tells whether dev mode is on: enabled in the config of a
+// build that honors it
+func devModeEnabled(cfg *config.Config) bool {
+	return devBuild && cfg != nil && cfg.DevMode.Enabled
+}
+
 // authMiddleware protects API routes by validating JWT tokens
 func authMiddleware(next buffalo.Handler) buffalo.Handler {
 	return func(c buffalo.Context) error {
@@ -369,7 +399,7 @@ func authMiddleware(next buffalo.Handler) buffalo.Handler {
 
 		// Dev mode bypass - skip auth ONLY if no Authorization header provided
 		authHeader := c.Request().Header.Get("Authorization")
-		if cfg != nil && cfg.DevMode.Enabled && authHeader == "" {
+		if devModeEnabled(cfg) && authHeader == "" {
 			c.Logger().Warn("DEV MODE: Authentication bypassed for request")
 
 			// Look up or create dev user to get their UUID
@@ -387,6 +417,7 @@ func authMiddleware(next buffalo.Handler) buffalo.Handler {
 			// Set actual UUID in context
 			c.Set("user_id", user.ID.String())
 			c.Set("user_email", user.Email)
+			c.Set("auth_type", "dev_mode")
 			return next(c)
 		}
 		if authHeader == "" {
@@ -420,12 +451,14 @@ func validateServiceToken(c buffalo.Context, token string, next buffalo.Handler)
 	apiToken, err := models.FindTokenByHash(tx, tokenHash)
 	if err != nil {
 		c.Logger().Warnf("Service token not found: %v", err)
+		audit(c, auditAuthFailed, "reason", "invalid_service_token")
 		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid service token"))
 	}
 
 	// Validate token
 	if !apiToken.IsValid() {
 		c.Logger().Warnf("Service token is revoked or expired: %s", apiToken.Prefix)
+		audit(c, auditAuthFailed, "reason", "service_token_revoked", "token_id", apiToken.ID.String())
 		return c.Error(http.StatusUnauthorized, fmt.Errorf("service token is revoked or expired"))
 	}
 
@@ -439,6 +472,7 @@ func validateServiceToken(c buffalo.Context, token string, next buffalo.Handler)
 	// Check if user is disabled
 	if user.Disabled {
 		c.Logger().Warnf("Access denied for disabled user via service token: %s", user.Email)
+		audit(c, auditAuthFailed, "reason", "account_disabled", "user_id", user.ID.String())
 		return c.Error(http.StatusForbidden, fmt.Errorf("account is disabled"))
 	}
 
@@ -452,6 +486,7 @@ func validateServiceToken(c buffalo.Context, token string, next buffalo.Handler)
 	c.Set("user_id", user.ID.String())
 	c.Set("user_email", user.Email)
 	c.Set("auth_type", "service_token") // For logging/audit
+	c.Set("token_id", apiToken.ID.String())
 
 	c.Logger().Infof("Request authenticated via service token: %s (user: %s)",
 		apiToken.Prefix, user.Email)
@@ -472,6 +507,7 @@ func validateJWTToken(c buffalo.Context, tokenStr string, cfg *config.Config, ne
 		return []byte(cfg.JWT.Secret), nil
 	})
 	if err != nil || !token.Valid {
+		audit(c, auditAuthFailed, "reason", "invalid_token")
 		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid token"))
 	}
 
@@ -496,12 +532,17 @@ func validateJWTToken(c buffalo.Context, tokenStr string, cfg *config.Config, ne
 
 	if user.Disabled {
 		c.Logger().Warnf("Access denied for disabled user: %s", user.Email)
+		audit(c, auditAuthFailed, "reason", "account_disabled", "user_id", user.ID.String())
 		return c.Error(http.StatusForbidden, fmt.Errorf("account is disabled"))
 	}
 
 	// Set user info in context for downstream handlers
 	c.Set("user_id", userID)
 	c.Set("user_email", claims["email"])
+	c.Set("auth_type", "jwt")
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		c.Set("token_expires_at", exp.Time)
+	}
 
 	return next(c)
 }
@@ -510,13 +551,3 @@ func validateJWTToken(c buffalo.Context, tokenStr string, cfg *config.Config, ne
 func int64ToString(i int64) string {
 	return strconv.FormatInt(i, 10)
 }
-
-// authTestSuccess renders a test success page (for debugging)
-func authTestSuccess(c buffalo.Context) error {
-	tokens := &TokenResponse{
-		AccessToken:  "test-access-token",
-		RefreshToken: "test-refresh-token",
-		ExpiresAt:    time.Now().Add(24 * time.Hour).Unix(),
-	}
-	return renderAuthSuccess(c, tokens)
-}
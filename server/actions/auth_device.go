@@ -0,0 +1,330 @@
+package actions
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+)
+
+// CLIs and scripts log in with the OAuth device authorization grant (RFC
+// 8628): POST /auth/device/code returns a device code and a user code, the
+// user enters the user code on /auth/device and logs in with the OAuth
+// provider, and meanwhile the client polls POST /auth/device/token with the
+// device code until it gets tokens. The page shows the code and the client
+// that requested it, and only a POST with the page's CSRF token starts the
+// OAuth login, so a link to /auth/device?user_code=... can't approve a
+// code on its own (RFC 8628 section 5.4).
+
+const (
+	deviceCodeTTL      = 10 * time.Minute
+	devicePollInterval = 5 * time.Second // Polling faster gets slow_down
+)
+
+// Session keys of a device login: the user code being approved, and the
+// code and CSRF token of the confirmation page shown last
+const (
+	sessionDeviceUserCode    = "device_user_code"
+	sessionDeviceConfirmCode = "device_confirm_code"
+	sessionDeviceCSRF        = "device_csrf_token"
+)
+
+// DeviceCodeResponse is the response from POST /auth/device/code
+type DeviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"` // Seconds
+	Interval                int    `json:"interval"`   // Seconds between polls
+}
+
+// DeviceTokenPayload is the request body of POST /auth/device/token
+type DeviceTokenPayload struct {
+	DeviceCode string `json:"device_code"`
+}
+
+// authDeviceCode starts a device login
+func authDeviceCode(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	auth, deviceCode, err := models.CreateDeviceAuthorization(tx, deviceCodeTTL, requestIP(c), c.Request().UserAgent())
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	verify := deviceVerificationURL(c)
+	return c.Render(http.StatusOK, r.JSON(DeviceCodeResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                auth.UserCode,
+		VerificationURI:         verify,
+		VerificationURIComplete: verify + "?" + url.Values{"user_code": {auth.UserCode}}.Encode(),
+		ExpiresIn:               int(deviceCodeTTL.Seconds()),
+		Interval:                int(devicePollInterval.Seconds()),
+	}))
+}
+
+// deviceVerificationURL is the address of the page where users enter user
+// codes: on server.base_url, or the host of the request
+func deviceVerificationURL(c buffalo.Context) string {
+	base := strings.TrimSuffix(GetConfig().Server.BaseURL, "/")
+	if base == "" {
+		scheme := "http"
+		if c.Request().TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request().Host
+	}
+	return base + "/auth/device"
+}
+
+// authDevice asks the user for a user code, then shows the code and the
+// client that requested it for the user to confirm
+func authDevice(c buffalo.Context) error {
+	userCode := c.Param("user_code")
+	if userCode == "" {
+		return renderDevicePage(c, http.StatusOK, "", "")
+	}
+	auth, err := findPendingDeviceLogin(c, userCode)
+	if auth == nil {
+		return err
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	session := c.Session()
+	session.Set(sessionDeviceConfirmCode, auth.UserCode)
+	session.Set(sessionDeviceCSRF, token)
+	if err := session.Save(); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return renderDeviceConfirmPage(c, auth, token)
+}
+
+// authDeviceConfirm logs the user in with the OAuth provider to approve the
+// code of the confirmation page, when the form carries its CSRF token
+func authDeviceConfirm(c buffalo.Context) error {
+	session := c.Session()
+	userCode, _ := session.Get(sessionDeviceConfirmCode).(string)
+	token, _ := session.Get(sessionDeviceCSRF).(string)
+	session.Delete(sessionDeviceConfirmCode)
+	session.Delete(sessionDeviceCSRF)
+	if userCode == "" || token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(c.Param("csrf_token"))) != 1 {
+		audit(c, auditAuthFailed, "reason", "device_csrf")
+		session.Save()
+		return renderDevicePage(c, http.StatusForbidden, "This confirmation is no longer valid. Enter the code again.", "")
+	}
+	auth, err := findPendingDeviceLogin(c, userCode)
+	if auth == nil {
+		return err
+	}
+
+	clearPendingLogin(c)
+	session.Set(sessionDeviceUserCode, auth.UserCode)
+	if err := session.Save(); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return beginOAuth(c)
+}
+
+// findPendingDeviceLogin finds the device login of userCode waiting for
+// approval. It returns nil when there is none, after rendering the code page
+// with an error.
+func findPendingDeviceLogin(c buffalo.Context, userCode string) (*models.DeviceAuthorization, error) {
+	tx := c.Value("tx").(*pop.Connection)
+	auth, err := models.FindDeviceAuthorizationByUserCode(tx, userCode)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && auth.Status != models.DeviceAuthorizationPending {
+		return nil, renderDevicePage(c, http.StatusNotFound, "This code is invalid or has expired. Check it, or start the login again on your device.", userCode)
+	} else if err != nil {
+		return nil, c.Error(http.StatusInternalServerError, err)
+	}
+	return auth, nil
+}
+
+// randomToken returns 32 random bytes in base64url
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// pendingDeviceLogin returns the user code of the device login started in
+// the session, and clears it. ok is false when the login wasn't started on
+// /auth/device.
+func pendingDeviceLogin(c buffalo.Context) (userCode string, ok bool) {
+	userCode, _ = c.Session().Get(sessionDeviceUserCode).(string)
+	if userCode == "" {
+		return "", false
+	}
+	c.Session().Delete(sessionDeviceUserCode)
+	c.Session().Save()
+	return userCode, true
+}
+
+// approveDeviceLogin lets the device of userCode get tokens for user
+func approveDeviceLogin(tx *pop.Connection, userCode string, user *models.User) error {
+	auth, err := models.FindDeviceAuthorizationByUserCode(tx, userCode)
+	if err != nil || auth.Status != models.DeviceAuthorizationPending {
+		return fmt.Errorf("the code %s is invalid or has expired, start the login again on your device", userCode)
+	}
+	auth.Status = models.DeviceAuthorizationApproved
+	auth.UserID = nulls.NewUUID(user.ID)
+	return tx.UpdateColumns(auth, "status", "user_id", "updated_at")
+}
+
+// denyDeviceLogin tells the device of userCode that the login was refused.
+// It writes outside the request's transaction, which the error rolls back.
+func denyDeviceLogin(c buffalo.Context, userCode string) {
+	db := appDB(c)
+	auth, err := models.FindDeviceAuthorizationByUserCode(db, userCode)
+	if err != nil || auth.Status != models.DeviceAuthorizationPending {
+		return
+	}
+	auth.Status = models.DeviceAuthorizationDenied
+	if err := db.UpdateColumns(auth, "status", "updated_at"); err != nil {
+		c.Logger().Errorf("Failed to deny device login: %v", err)
+	}
+}
+
+// authDeviceToken returns tokens once the user approved the device code,
+// and the error of RFC 8628 in the error code until then. It runs outside a
+// transaction, so the time of polls is saved along with the errors.
+func authDeviceToken(c buffalo.Context) error {
+	var req DeviceTokenPayload
+	if err := c.Bind(&req); err != nil || req.DeviceCode == "" {
+		return renderError(c, http.StatusBadRequest, "invalid_request", "device_code is required")
+	}
+	db := appDB(c)
+	auth, err := models.FindDeviceAuthorizationByDeviceCode(db, req.DeviceCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return renderError(c, http.StatusBadRequest, "invalid_grant", "unknown device_code")
+	} else if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	now := time.Now()
+	switch {
+	case now.After(auth.ExpiresAt):
+		return renderError(c, http.StatusBadRequest, "expired_token", "the device code has expired, start the login again")
+	case auth.Status == models.DeviceAuthorizationDenied:
+		return renderError(c, http.StatusBadRequest, "access_denied", "the login was refused")
+	case auth.Status == models.DeviceAuthorizationPending:
+		tooSoon := auth.LastPolledAt.Valid && now.Sub(auth.LastPolledAt.Time) < devicePollInterval
+		auth.LastPolledAt = nulls.NewTime(now)
+		if err := db.UpdateColumns(auth, "last_polled_at", "updated_at"); err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+		if tooSoon {
+			return renderError(c, http.StatusBadRequest, "slow_down",
+				fmt.Sprintf("poll at most every %d seconds", int(devicePollInterval.Seconds())))
+		}
+		return renderError(c, http.StatusBadRequest, "authorization_pending", "waiting for the user to enter the code")
+	}
+
+	// Approved: the device code is used once, by the first of concurrent polls
+	redeemed, err := models.RedeemDeviceAuthorization(db, auth)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	} else if !redeemed {
+		return renderError(c, http.StatusBadRequest, "invalid_grant", "the device code was already used")
+	}
+	user := &models.User{}
+	if err := db.Find(user, auth.UserID.UUID); err != nil {
+		return renderError(c, http.StatusBadRequest, "invalid_grant", "user not found")
+	}
+	if user.Disabled {
+		audit(c, auditAuthFailed, "reason", "account_disabled", "user_id", user.ID.String())
+		return c.Error(http.StatusForbidden, fmt.Errorf("account is disabled"))
+	}
+	tokens, err := generateTokens(user)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(tokens))
+}
+
+// renderDevicePage renders the form where users enter a user code, with a
+// message when the last one was refused
+func renderDevicePage(c buffalo.Context, status int, message, userCode string) error {
+	if message != "" {
+		message = `<p class="error">` + html.EscapeString(message) + `</p>`
+	}
+	return writeDevicePage(c, status, fmt.Sprintf(`<p>Enter the code shown by the command line or app you are logging in.</p>
+        %s
+        <form method="get" action="/auth/device">
+            <input name="user_code" value="%s" placeholder="XXXX-XXXX" autocomplete="off" autofocus required>
+            <button type="submit" class="btn">Continue</button>
+        </form>`, message, html.EscapeString(userCode)))
+}
+
+// renderDeviceConfirmPage asks the user to confirm that the device login
+// of auth is theirs: the code must match the one on their device
+func renderDeviceConfirmPage(c buffalo.Context, auth *models.DeviceAuthorization, csrfToken string) error {
+	client := auth.ClientAgent
+	if client == "" {
+		client = "an unknown client"
+	}
+	requested := fmt.Sprintf("%d minutes ago", int(time.Since(auth.CreatedAt).Minutes()))
+	if time.Since(auth.CreatedAt) < time.Minute {
+		requested = "less than a minute ago"
+	}
+	return writeDevicePage(c, http.StatusOK, fmt.Sprintf(`<p>A device is asking to access your clips. Only continue if you started this login and your device shows this code:</p>
+        <p class="code">%s</p>
+        <p class="client">Requested %s by %s from %s.</p>
+        <form method="post" action="/auth/device">
+            <input type="hidden" name="csrf_token" value="%s">
+            <button type="submit" class="btn">Connect this device</button>
+        </form>
+        <p><a href="/auth/device">This is not my code</a></p>`,
+		html.EscapeString(auth.UserCode), requested, html.EscapeString(client), html.EscapeString(auth.ClientIP), html.EscapeString(csrfToken)))
+}
+
+// writeDevicePage writes the page of /auth/device around body
+func writeDevicePage(c buffalo.Context, status int, body string) error {
+	page := fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Connect a Device - Web Clipper</title>
+    <style>
+        * { box-sizing: border-box; margin: 0; padding: 0; }
+        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; background: #f5f5f5; min-height: 100vh; display: flex; align-items: center; justify-content: center; }
+        .container { background: white; padding: 2rem; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); max-width: 400px; text-align: center; }
+        h1 { color: #333; margin-bottom: 0.5rem; font-size: 1.5rem; }
+        p { color: #666; margin-bottom: 1rem; line-height: 1.5; }
+        .error { color: #d32f2f; }
+        input { font-family: monospace; font-size: 1.5rem; letter-spacing: 0.2rem; text-align: center; text-transform: uppercase; width: 100%%; padding: 0.5rem; border: 1px solid #ccc; border-radius: 4px; }
+        .btn { display: inline-block; padding: 0.75rem 1.5rem; background: #1976d2; color: white; border: none; border-radius: 4px; margin-top: 1rem; font-size: 1rem; cursor: pointer; }
+        .btn:hover { background: #1565c0; }
+        .code { font-family: monospace; font-size: 2rem; letter-spacing: 0.2rem; color: #333; }
+        .client { font-size: 0.9rem; word-break: break-word; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>Connect a Device</h1>
+        %s
+    </div>
+</body>
+</html>`, body)
+	c.Response().Header().Set("Content-Type", "text/html; charset=utf-8")
+	c.Response().WriteHeader(status)
+	c.Response().Write([]byte(page))
+	return nil
+}
@@ -0,0 +1,150 @@
+package actions
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/github"
+)
+
+func (as *ActionSuite) Test_DeviceLogin() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+	kit.Config.Server.BaseURL = "https://clips.example.com/"
+
+	res := client.Post("/auth/device/code", nil)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	var code DeviceCodeResponse
+	res.JSON(&code)
+	as.Regexp(`^[B-Z]{4}-[B-Z]{4}$`, code.UserCode)
+	as.Equal("https://clips.example.com/auth/device", code.VerificationURI)
+	as.Equal(code.VerificationURI+"?user_code="+code.UserCode, code.VerificationURIComplete)
+	as.Equal(600, code.ExpiresIn)
+	as.Equal(5, code.Interval)
+
+	poll := func(deviceCode string) (int, string) {
+		res := client.Post("/auth/device/token", DeviceTokenPayload{DeviceCode: deviceCode})
+		var body ErrorResponse
+		if res.Code != http.StatusOK {
+			res.JSON(&body)
+			return res.Code, body.Error.Code
+		}
+		return res.Code, ""
+	}
+	status, errCode := poll(code.DeviceCode)
+	as.Equal(http.StatusBadRequest, status)
+	as.Equal("authorization_pending", errCode)
+	_, errCode = poll(code.DeviceCode)
+	as.Equal("slow_down", errCode)
+	_, errCode = poll("unknown")
+	as.Equal("invalid_grant", errCode)
+
+	// The page asks for the code, and refuses unknown ones
+	res = client.Get("/auth/device")
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), `name="user_code"`)
+	res = client.Get("/auth/device?user_code=BBBB-BBBB%3Cscript%3E")
+	as.Equal(http.StatusNotFound, res.Code)
+	as.NotContains(res.Body.String(), "<script>")
+	as.Equal(http.StatusNotFound, client.Get("/auth/device?user_code=BBBB-BBBB").Code)
+
+	// The user logs in: user codes are matched however they are typed
+	as.NoError(approveDeviceLogin(kit.DB, strings.ToLower(strings.ReplaceAll(code.UserCode, "-", "")), user))
+	as.Error(approveDeviceLogin(kit.DB, code.UserCode, user), "approved once")
+	res = client.Post("/auth/device/token", DeviceTokenPayload{DeviceCode: code.DeviceCode})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	var tokens TokenResponse
+	res.JSON(&tokens)
+	as.NotEmpty(tokens.RefreshToken)
+	client.Token = tokens.AccessToken
+	as.Equal(http.StatusOK, client.Get("/api/v1/me").Code)
+
+	// The device code is used once
+	_, errCode = poll(code.DeviceCode)
+	as.Equal("invalid_grant", errCode)
+
+	// Expired and denied codes
+	auth, deviceCode, err := models.CreateDeviceAuthorization(kit.DB, time.Minute, "192.0.2.1", "")
+	as.NoError(err)
+	auth.Status = models.DeviceAuthorizationDenied
+	as.NoError(kit.DB.Update(auth))
+	_, errCode = poll(deviceCode)
+	as.Equal("access_denied", errCode)
+	auth, deviceCode, err = models.CreateDeviceAuthorization(kit.DB, time.Minute, "192.0.2.1", "")
+	as.NoError(err)
+	auth.ExpiresAt, auth.UserID = time.Now().Add(-time.Second), nulls.NewUUID(user.ID)
+	as.NoError(kit.DB.Update(auth))
+	_, errCode = poll(deviceCode)
+	as.Equal("expired_token", errCode)
+}
+
+func (as *ActionSuite) Test_DeviceLogin_Confirmation() {
+	kit := testkit.New(as.T())
+	kit.Config.OAuth.Provider = "github"
+	goth.UseProviders(github.New("client-id", "secret", "https://clips.example.com/auth/callback"))
+	defer goth.ClearProviders()
+	app := newKitApp(kit)
+	device := kit.Client(app, kit.CreateUser())
+	device.Header = http.Header{"User-Agent": {"web-clipper-cli/1.2 <b>"}}
+	var code DeviceCodeResponse
+	device.Post("/auth/device/code", nil).JSON(&code)
+
+	// Opening the link shows the code and the client, and starts no login
+	browser := kit.Client(app, kit.CreateUser())
+	browser.Token = ""
+	res := browser.Get("/auth/device?user_code=" + code.UserCode)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	page := res.Body.String()
+	as.Contains(page, code.UserCode)
+	as.Contains(page, "web-clipper-cli/1.2 &lt;b&gt;")
+	csrf := regexp.MustCompile(`name="csrf_token" value="([^"]+)"`).FindStringSubmatch(page)
+	as.Require().Len(csrf, 2)
+	browser.Header = http.Header{"Cookie": {res.Header().Get("Set-Cookie")}}
+
+	confirm := func(token string) *testkit.Response {
+		return browser.Upload("/auth/device", strings.NewReader(url.Values{"csrf_token": {token}}.Encode()), "application/x-www-form-urlencoded")
+	}
+	// A form posted from elsewhere has no token, or a wrong one
+	as.Equal(http.StatusForbidden, confirm("forged").Code)
+
+	// Confirming starts the login with the provider
+	res = confirm(csrf[1])
+	as.Equal(http.StatusSeeOther, res.Code, res.Body.String())
+	as.Contains(res.Header().Get("Location"), "https://github.com/login/oauth/authorize")
+
+	// The token is used once, and only with the cookie of the page
+	browser.Header = http.Header{"Cookie": {res.Header().Get("Set-Cookie")}}
+	as.Equal(http.StatusForbidden, confirm(csrf[1]).Code)
+	browser.Header = nil
+	as.Equal(http.StatusForbidden, confirm(csrf[1]).Code)
+}
+
+func (as *ActionSuite) Test_DeviceLogin_TokensIssuedOnce() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	auth, deviceCode, err := models.CreateDeviceAuthorization(kit.DB, time.Minute, "192.0.2.1", "cli")
+	as.NoError(err)
+	as.NoError(approveDeviceLogin(kit.DB, auth.UserCode, user))
+
+	// A poll that read the approval after another redeemed it gets nothing
+	stale := *auth
+	redeemed, err := models.RedeemDeviceAuthorization(kit.DB, &stale)
+	as.NoError(err)
+	as.True(redeemed)
+	redeemed, err = models.RedeemDeviceAuthorization(kit.DB, &stale)
+	as.NoError(err)
+	as.False(redeemed)
+
+	client := kit.Client(newKitApp(kit), user)
+	res := client.Post("/auth/device/token", DeviceTokenPayload{DeviceCode: deviceCode})
+	as.Equal(http.StatusBadRequest, res.Code)
+}
@@ -0,0 +1,247 @@
+package actions
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// The extension logs in with PKCE (RFC 7636): /auth/extension/start takes the
+// SHA-256 of a secret verifier the extension keeps, the OAuth callback sends
+// a one-time code to the extension's redirect URI, and only the holder of
+// the verifier can exchange the code for tokens at /auth/extension/exchange.
+// The extension holds no client secret.
+
+// extensionCodeTTL is how long the code from the OAuth callback can be
+// exchanged for tokens
+const extensionCodeTTL = 2 * time.Minute
+
+// Session keys of a pending extension login
+const (
+	sessionExtensionChallenge = "extension_code_challenge"
+	sessionExtensionRedirect  = "extension_redirect_uri"
+	sessionExtensionState     = "extension_state"
+)
+
+// extensionRedirectHosts are the hosts of the redirect URIs browsers give
+// extensions (chrome.identity.getRedirectURL), below the extension's ID.
+// Only the IDs of oauth.extension_ids are accepted: any extension could
+// otherwise start a login and receive the user's code.
+var extensionRedirectHosts = []string{".chromiumapp.org", ".extensions.allizom.org"}
+
+// pkcePattern matches code challenges and verifiers: 43 to 128 characters
+// of base64url, which S256 challenges always are
+var pkcePattern = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+
+// ExtensionExchangePayload is the request body of POST /auth/extension/exchange
+type ExtensionExchangePayload struct {
+	Code         string `json:"code"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// authExtensionStart begins the extension's login: it saves the code
+// challenge, redirect URI and state in the session and redirects to the
+// OAuth provider
+func authExtensionStart(c buffalo.Context) error {
+	challenge := c.Param("code_challenge")
+	if method := c.Param("code_challenge_method"); method != "S256" {
+		return renderAuthError(c, http.StatusBadRequest, "Invalid Login Request",
+			fmt.Sprintf("Unsupported code_challenge_method %q: only S256 is supported.", method))
+	}
+	if !pkcePattern.MatchString(challenge) {
+		return renderAuthError(c, http.StatusBadRequest, "Invalid Login Request", "Missing or invalid code_challenge.")
+	}
+	redirectURI := c.Param("redirect_uri")
+	if !isExtensionRedirect(redirectURI, GetConfig().OAuth.ExtensionIDs) {
+		return renderAuthError(c, http.StatusBadRequest, "Invalid Login Request",
+			"The redirect_uri must be the extension's, from chrome.identity.getRedirectURL().")
+	}
+
+	clearPendingLogin(c)
+	session := c.Session()
+	session.Set(sessionExtensionChallenge, challenge)
+	session.Set(sessionExtensionRedirect, redirectURI)
+	session.Set(sessionExtensionState, c.Param("state"))
+	if err := session.Save(); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return beginOAuth(c)
+}
+
+// isExtensionRedirect reports whether uri is the redirect URI of one of the
+// browser extensions ids
+func isExtensionRedirect(uri string, ids []string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "https" || u.User != nil || u.Port() != "" {
+		return false
+	}
+	for _, suffix := range extensionRedirectHosts {
+		id, ok := strings.CutSuffix(strings.ToLower(u.Hostname()), suffix)
+		if !ok || id == "" {
+			continue
+		}
+		for _, allowed := range ids {
+			if id == strings.ToLower(allowed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pendingExtensionLogin returns the code challenge, redirect URI and state
+// of the extension login started in the session, and clears it. ok is false
+// when the login wasn't started by the extension.
+func pendingExtensionLogin(c buffalo.Context) (challenge, redirectURI, state string, ok bool) {
+	session := c.Session()
+	challenge, _ = session.Get(sessionExtensionChallenge).(string)
+	redirectURI, _ = session.Get(sessionExtensionRedirect).(string)
+	state, _ = session.Get(sessionExtensionState).(string)
+	if challenge == "" || redirectURI == "" {
+		return "", "", "", false
+	}
+	clearPendingLogin(c)
+	return challenge, redirectURI, state, true
+}
+
+// clearPendingLogin forgets the extension or device login started in the
+// session
+func clearPendingLogin(c buffalo.Context) {
+	session := c.Session()
+	session.Delete(sessionExtensionChallenge)
+	session.Delete(sessionExtensionRedirect)
+	session.Delete(sessionExtensionState)
+	session.Delete(sessionDeviceUserCode)
+	session.Delete(sessionDeviceConfirmCode)
+	session.Delete(sessionDeviceCSRF)
+	session.Save()
+}
+
+// redirectToExtension sends the extension params on its redirect URI, with
+// its state
+func redirectToExtension(c buffalo.Context, redirectURI, state string, params url.Values) error {
+	if state != "" {
+		params.Set("state", state)
+	}
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	u.RawQuery = params.Encode()
+	return c.Redirect(http.StatusFound, u.String())
+}
+
+// issueExtensionCode returns a one-time code for user, bound to challenge
+func issueExtensionCode(user *models.User, challenge string) (string, error) {
+	cfg := GetConfig()
+	if cfg == nil || cfg.JWT.Secret == "" {
+		return "", fmt.Errorf("JWT not configured")
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":       user.ID.String(),
+		"challenge": challenge,
+		"jti":       uuid.Must(uuid.NewV4()).String(),
+		"exp":       time.Now().Add(extensionCodeTTL).Unix(),
+		"type":      "extension_code",
+	}).SignedString([]byte(cfg.JWT.Secret))
+}
+
+// authExtensionExchange returns tokens for a code from the OAuth callback
+// and the verifier of the challenge it was issued for
+func authExtensionExchange(c buffalo.Context) error {
+	var req ExtensionExchangePayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+	if !pkcePattern.MatchString(req.CodeVerifier) {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("missing or invalid code_verifier"))
+	}
+
+	cfg := GetConfig()
+	if cfg == nil || cfg.JWT.Secret == "" {
+		return c.Error(http.StatusInternalServerError, fmt.Errorf("JWT not configured"))
+	}
+	token, err := jwt.Parse(req.Code, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return []byte(cfg.JWT.Secret), nil
+	})
+	if err != nil || !token.Valid {
+		audit(c, auditAuthFailed, "reason", "invalid_extension_code")
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid or expired code"))
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["type"] != "extension_code" {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("not an extension code"))
+	}
+	challenge, _ := claims["challenge"].(string)
+	sum := sha256.Sum256([]byte(req.CodeVerifier))
+	if subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) != 1 {
+		audit(c, auditAuthFailed, "reason", "invalid_code_verifier")
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("code_verifier does not match the code_challenge"))
+	}
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims.GetExpirationTime()
+	if jti == "" || exp == nil || !extensionCodes.redeem(jti, exp.Time) {
+		audit(c, auditAuthFailed, "reason", "extension_code_reused")
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("code already used"))
+	}
+
+	userID, _ := claims["sub"].(string)
+	tx := c.Value("tx").(*pop.Connection)
+	user := &models.User{}
+	if err := tx.Find(user, userID); err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("user not found"))
+	}
+	if user.Disabled {
+		audit(c, auditAuthFailed, "reason", "account_disabled", "user_id", user.ID.String())
+		return c.Error(http.StatusForbidden, fmt.Errorf("account is disabled"))
+	}
+	tokens, err := generateTokens(user)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(tokens))
+}
+
+// extensionCodes remembers the codes exchanged until they expire, so each
+// is exchanged once. Servers sharing a JWT secret don't share it, but a
+// code is still useless without its verifier.
+var extensionCodes = &redeemedCodes{expires: map[string]time.Time{}}
+
+type redeemedCodes struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+}
+
+// redeem marks the code id as used, and reports whether it was not yet
+func (r *redeemedCodes) redeem(id string, expires time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for k, t := range r.expires {
+		if now.After(t) {
+			delete(r.expires, k)
+		}
+	}
+	if _, used := r.expires[id]; used {
+		return false
+	}
+	r.expires[id] = expires
+	return true
+}
@@ -0,0 +1,93 @@
+package actions
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_ExtensionLoginStart() {
+	kit := testkit.New(as.T())
+	kit.Config.OAuth.ExtensionIDs = []string{"abcdefghijklmnop"}
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+	challenge := base64.RawURLEncoding.EncodeToString(make([]byte, 32))
+
+	start := func(params url.Values) int {
+		return client.Get("/auth/extension/start?" + params.Encode()).Code
+	}
+	valid := url.Values{
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+		"redirect_uri":          {"https://abcdefghijklmnop.chromiumapp.org/"},
+	}
+	for name, change := range map[string]func(url.Values){
+		"plain method":       func(v url.Values) { v.Set("code_challenge_method", "plain") },
+		"no method":          func(v url.Values) { v.Del("code_challenge_method") },
+		"short challenge":    func(v url.Values) { v.Set("code_challenge", "abc") },
+		"no redirect":        func(v url.Values) { v.Del("redirect_uri") },
+		"website redirect":   func(v url.Values) { v.Set("redirect_uri", "https://evil.example.com/") },
+		"lookalike host":     func(v url.Values) { v.Set("redirect_uri", "https://chromiumapp.org.evil.com/") },
+		"http redirect":      func(v url.Values) { v.Set("redirect_uri", "http://abc.chromiumapp.org/") },
+		"subdomain of an id": func(v url.Values) { v.Set("redirect_uri", "https://x.abcdefghijklmnop.chromiumapp.org/") },
+		"another extension":  func(v url.Values) { v.Set("redirect_uri", "https://ponmlkjihgfedcba.chromiumapp.org/") },
+	} {
+		params := url.Values{}
+		for k, v := range valid {
+			params[k] = v
+		}
+		change(params)
+		as.Equal(http.StatusBadRequest, start(params), name)
+	}
+
+	ids := []string{"abcdefghijklmnop", "0123abcd"}
+	as.True(isExtensionRedirect("https://abcdefghijklmnop.chromiumapp.org/callback", ids))
+	as.True(isExtensionRedirect("https://ABCDEFGHIJKLMNOP.chromiumapp.org/", ids))
+	as.True(isExtensionRedirect("https://0123abcd.extensions.allizom.org/", ids))
+	as.False(isExtensionRedirect("https://abcdefghijklmno.chromiumapp.org/", ids))
+	as.False(isExtensionRedirect("https://abcdefghijklmnop.chromiumapp.org/", nil), "no extension is allowed by default")
+}
+
+func (as *ActionSuite) Test_ExtensionLoginExchange() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+
+	verifier := strings.Repeat("v", 43)
+	sum := sha256.Sum256([]byte(verifier))
+	code, err := issueExtensionCode(user, base64.RawURLEncoding.EncodeToString(sum[:]))
+	as.NoError(err)
+
+	exchange := func(code, verifier string) *testkit.Response {
+		return client.Post("/auth/extension/exchange", ExtensionExchangePayload{Code: code, CodeVerifier: verifier})
+	}
+	as.Equal(http.StatusBadRequest, exchange(code, "short").Code)
+	as.Equal(http.StatusUnauthorized, exchange(code, strings.Repeat("w", 43)).Code)
+	as.Equal(http.StatusUnauthorized, exchange("not-a-code", verifier).Code)
+
+	// The refresh token is not a code
+	tokens, err := generateTokens(user)
+	as.NoError(err)
+	as.Equal(http.StatusUnauthorized, exchange(tokens.RefreshToken, verifier).Code)
+
+	res := exchange(code, verifier)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	var issued TokenResponse
+	res.JSON(&issued)
+	as.NotEmpty(issued.RefreshToken)
+	client.Token = issued.AccessToken
+	as.Equal(http.StatusOK, client.Get("/api/v1/me").Code)
+
+	// A code is exchanged once
+	as.Equal(http.StatusUnauthorized, exchange(code, verifier).Code)
+
+	// Nor by a disabled user
+	code, err = issueExtensionCode(user, base64.RawURLEncoding.EncodeToString(sum[:]))
+	as.NoError(err)
+	user.Disabled = true
+	as.NoError(kit.DB.UpdateColumns(user, "disabled"))
+	as.Equal(http.StatusForbidden, exchange(code, verifier).Code)
+}
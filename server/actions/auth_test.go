@@ -1,7 +1,19 @@
 package actions
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"server/internal/config"
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/markbates/goth"
 )
 
 func (as *ActionSuite) Test_AuthLogout() {
@@ -45,8 +57,129 @@ func (as *ActionSuite) Test_AuthRefresh_InvalidToken() {
 }
 
 func (as *ActionSuite) Test_DevToken_WhenDisabled() {
-	// Dev mode is disabled by default, so endpoint should return 403 Forbidden
-	res := as.JSON("/auth/dev-token").Get()
-	as.Equal(http.StatusForbidden, res.Code)
-	as.Contains(res.Body.String(), "dev mode is not enabled")
+	// Dev mode is disabled by default, so the dev routes don't exist
+	as.Equal(http.StatusNotFound, as.JSON("/auth/dev-token").Get().Code)
+	as.Equal(http.StatusNotFound, as.HTML("/auth/test-success").Get().Code)
+}
+
+func (as *ActionSuite) Test_OAuthProviders() {
+	var issuer string
+	discovery := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": issuer + "/authorize",
+			"token_endpoint":         issuer + "/token",
+			"userinfo_endpoint":      issuer + "/userinfo",
+		})
+	}))
+	defer discovery.Close()
+	issuer = discovery.URL
+
+	authURL := func(oauth config.OAuthConfig) *url.URL {
+		oauth.ClientID, oauth.ClientSecret, oauth.RedirectURL = "web-clipper", "secret", "https://clips.example.com/auth/callback"
+		provider, err := newOAuthProvider(oauth)
+		as.Require().NoError(err)
+		as.Equal(oauth.Provider, provider.Name())
+		session, err := provider.BeginAuth("state")
+		as.Require().NoError(err)
+		raw, err := session.GetAuthURL()
+		as.Require().NoError(err)
+		u, err := url.Parse(raw)
+		as.Require().NoError(err)
+		return u
+	}
+
+	u := authURL(config.OAuthConfig{Provider: "github"})
+	as.Equal("github.com", u.Host)
+	as.Equal("read:user user:email", u.Query().Get("scope"))
+	u = authURL(config.OAuthConfig{Provider: "github", GitHub: config.GitHubConfig{BaseURL: "https://github.example.com/"}})
+	as.Equal("https://github.example.com/login/oauth/authorize", u.Scheme+"://"+u.Host+u.Path)
+
+	// GitLab and generic providers are found through OpenID Connect discovery
+	u = authURL(config.OAuthConfig{Provider: "gitlab", GitLab: config.GitLabConfig{BaseURL: discovery.URL}})
+	as.Equal(discovery.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	as.Equal("openid email profile", u.Query().Get("scope"))
+	u = authURL(config.OAuthConfig{Provider: "oidc", OIDC: config.OIDCConfig{IssuerURL: discovery.URL}, Scopes: []string{"openid", "email", "groups"}})
+	as.Equal(discovery.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	as.Equal("openid email groups", u.Query().Get("scope"))
+
+	_, err := newOAuthProvider(config.OAuthConfig{Provider: "okta"})
+	as.Error(err)
+}
+
+func (as *ActionSuite) Test_OAuthCallback_UnverifiedEmail() {
+	var issuer string
+	var verified any
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 issuer,
+				"authorization_endpoint": issuer + "/authorize",
+				"token_endpoint":         issuer + "/token",
+			})
+		case "/token":
+			claims, _ := json.Marshal(map[string]any{
+				"iss": issuer, "aud": "web-clipper", "sub": "oidc-user", "exp": time.Now().Add(time.Hour).Unix(),
+				"email": "boss@example.com", "email_verified": verified,
+			})
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"access_token": "access", "token_type": "Bearer", "expires_in": 3600,
+				"id_token": "e30." + base64.RawURLEncoding.EncodeToString(claims) + ".",
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer provider.Close()
+	issuer = provider.URL
+
+	kit := testkit.New(as.T())
+	kit.Config.OAuth = config.OAuthConfig{Provider: "oidc", ClientID: "web-clipper", ClientSecret: "secret",
+		RedirectURL: "https://clips.example.com/auth/callback", OIDC: config.OIDCConfig{IssuerURL: provider.URL}}
+	oidc, err := newOAuthProvider(kit.Config.OAuth)
+	as.Require().NoError(err)
+	goth.UseProviders(oidc)
+	defer goth.ClearProviders()
+	browser := kit.Client(newKitApp(kit), kit.CreateUser())
+	browser.Token = ""
+
+	login := func() *testkit.Response {
+		browser.Header = nil
+		res := browser.Get("/auth/login")
+		as.Require().Equal(http.StatusTemporaryRedirect, res.Code, res.Body.String())
+		authURL, err := url.Parse(res.Header().Get("Location"))
+		as.Require().NoError(err)
+		var cookies []string
+		for _, c := range res.Result().Cookies() {
+			cookies = append(cookies, c.Name+"="+c.Value)
+		}
+		browser.Header = http.Header{"Cookie": {strings.Join(cookies, "; ")}}
+		return browser.Get("/auth/callback?code=granted&state=" + url.QueryEscape(authURL.Query().Get("state")))
+	}
+
+	// An email the provider hasn't verified is refused
+	verified = false
+	res := login()
+	as.Equal(http.StatusForbidden, res.Code, res.Body.String())
+	as.Contains(res.Body.String(), "not verified")
+	count, err := kit.DB.Where("oauth_id = ?", "oidc-user").Count(&models.User{})
+	as.NoError(err)
+	as.Zero(count)
+
+	verified = true
+	res = login()
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	var tokens TokenResponse
+	res.JSON(&tokens)
+	as.NotEmpty(tokens.AccessToken)
+
+	as.False(emailVerified(goth.User{RawData: map[string]any{"email_verified": "false"}}))
+	as.True(emailVerified(goth.User{RawData: map[string]any{"email_verified": "true"}}))
+	as.True(emailVerified(goth.User{}), "providers without the claim, such as GitHub")
 }
@@ -0,0 +1,95 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gobuffalo/buffalo"
+)
+
+// limitedBody is a request body read up to the limit of its endpoint
+type limitedBody struct {
+	io.ReadCloser
+	limit    int64
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// bodyLimit returns the most a request may send: server.max_clip_body_bytes
+// for clips (and their uploads), server.max_body_bytes for the rest. 0 is
+// no limit.
+func bodyLimit(req *http.Request) int64 {
+	cfg := GetConfig()
+	if cfg == nil {
+		return 0
+	}
+	limit := cfg.Server.MaxBodyBytes
+	if isIngestionRequest(req) {
+		limit = cfg.Server.MaxClipBodyBytes
+	}
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// bodyOverLimit returns why the request's body was cut short, or nil when it
+// was not
+func bodyOverLimit(c buffalo.Context) error {
+	if b, ok := c.Request().Body.(*limitedBody); ok && b.exceeded {
+		return fmt.Errorf("request body exceeds %d bytes", b.limit)
+	}
+	return nil
+}
+
+// bodyLimitMiddleware answers 413 to requests whose body is over the limit
+// of their endpoint: before reading it when they declare a longer
+// Content-Length, else once a handler failed to read it, as reading stops
+// at the limit. Clips are rejected like the others (rejectQuota).
+func bodyLimitMiddleware(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		req := c.Request()
+		limit := bodyLimit(req)
+		if limit == 0 || req.Body == nil || req.Body == http.NoBody {
+			return next(c)
+		}
+		if req.ContentLength > limit {
+			return rejectOversizeBody(c, fmt.Errorf("request body exceeds %d bytes", limit))
+		}
+		req.Body = &limitedBody{ReadCloser: http.MaxBytesReader(c.Response(), req.Body, limit), limit: limit}
+
+		err := next(c)
+		var herr buffalo.HTTPError
+		if over := bodyOverLimit(c); over != nil && errors.As(err, &herr) && herr.Status == http.StatusBadRequest {
+			return c.Error(http.StatusRequestEntityTooLarge, over)
+		}
+		return err
+	}
+}
+
+// rejectOversizeBody answers 413 with err
+func rejectOversizeBody(c buffalo.Context, err error) error {
+	if isIngestionRequest(c.Request()) {
+		return rejectClip(c, http.StatusRequestEntityTooLarge, rejectQuota, err.Error())
+	}
+	return c.Error(http.StatusRequestEntityTooLarge, err)
+}
+
+// rejectClipBody rejects a clip whose body could not be read: as too large
+// when reading stopped at the limit, else as invalid
+func rejectClipBody(c buffalo.Context) error {
+	if over := bodyOverLimit(c); over != nil {
+		return rejectOversizeBody(c, over)
+	}
+	return rejectClip(c, http.StatusBadRequest, rejectInvalidPayload, "Invalid request body")
+}
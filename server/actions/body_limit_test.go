@@ -0,0 +1,50 @@
+package actions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_BodyLimit() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	kit.Config.Server.MaxBodyBytes = 200
+	kit.Config.Server.MaxClipBodyBytes = 2000
+	app := newKitApp(kit)
+	client := kit.Client(app, user)
+
+	// Other endpoints have the smaller limit
+	res := client.Post("/api/v1/collections", map[string]string{"name": strings.Repeat("n", 300)})
+	as.Equal(http.StatusRequestEntityTooLarge, res.Code)
+	var resp ErrorResponse
+	res.JSON(&resp)
+	as.Equal("request_entity_too_large", resp.Error.Code)
+	as.Equal(http.StatusCreated, client.Post("/api/v1/collections", map[string]string{"name": "Small"}).Code, "under the limit")
+
+	// Clips have theirs, and are counted as rejected
+	before := clipRejections.Values()[rejectQuota]
+	clip := ClipPayload{Title: "Big", URL: "https://example.com/big", Mode: "bookmark", Markdown: strings.Repeat("m", 3000)}
+	var clipResp ClipResponse
+	res = client.Post("/api/v1/clips", clip)
+	as.Equal(http.StatusRequestEntityTooLarge, res.Code)
+	res.JSON(&clipResp)
+	as.Equal(rejectQuota, clipResp.Error.Code)
+	as.Equal(before+1, clipRejections.Values()[rejectQuota])
+
+	// Bodies without a length are cut at the limit
+	body, _ := json.Marshal(clip)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/clips", strings.NewReader(string(body)))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+client.Token)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	as.Equal(http.StatusRequestEntityTooLarge, rec.Code, rec.Body.String())
+
+	clip.Markdown = "small"
+	as.Equal(http.StatusOK, client.Post("/api/v1/clips", clip).Code)
+}
@@ -0,0 +1,389 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Bulk operations accepted by POST /api/v1/clips/bulk
+const (
+	bulkDelete           = "delete"
+	bulkAddTags          = "add-tags"
+	bulkRemoveTags       = "remove-tags"
+	bulkMoveToCollection = "move-to-collection"
+	bulkArchive          = "archive"
+	bulkUnarchive        = "unarchive"
+	bulkMarkRead         = "mark-read"
+	bulkMarkUnread       = "mark-unread"
+)
+
+// maxBulkClips caps the number of clips in one bulk request
+const maxBulkClips = 500
+
+// BulkPayload is the request body for POST /api/v1/clips/bulk
+type BulkPayload struct {
+	Operation    string   `json:"operation"`
+	IDs          []string `json:"ids"`
+	Tags         []string `json:"tags,omitempty"`          // For add-tags and remove-tags
+	CollectionID string   `json:"collection_id,omitempty"` // For move-to-collection; empty removes clips from their collection
+}
+
+// BulkUpdatePayload is the request body for POST /api/v1/clips/bulk-update;
+// each field set is applied to every clip
+type BulkUpdatePayload struct {
+	IDs          []string `json:"ids"`
+	AddTags      []string `json:"add_tags,omitempty"`
+	RemoveTags   []string `json:"remove_tags,omitempty"`
+	CollectionID *string  `json:"collection_id,omitempty"` // Empty removes clips from their collection
+	Archived     *bool    `json:"archived,omitempty"`
+	Read         *bool    `json:"read,omitempty"`
+}
+
+// operations returns the bulk operations of an update, in the order they apply
+func (p BulkUpdatePayload) operations() []BulkPayload {
+	var ops []BulkPayload
+	if len(p.AddTags) > 0 {
+		ops = append(ops, BulkPayload{Operation: bulkAddTags, Tags: p.AddTags})
+	}
+	if len(p.RemoveTags) > 0 {
+		ops = append(ops, BulkPayload{Operation: bulkRemoveTags, Tags: p.RemoveTags})
+	}
+	if p.CollectionID != nil {
+		ops = append(ops, BulkPayload{Operation: bulkMoveToCollection, CollectionID: *p.CollectionID})
+	}
+	if p.Archived != nil {
+		op := bulkUnarchive
+		if *p.Archived {
+			op = bulkArchive
+		}
+		ops = append(ops, BulkPayload{Operation: op})
+	}
+	if p.Read != nil {
+		op := bulkMarkUnread
+		if *p.Read {
+			op = bulkMarkRead
+		}
+		ops = append(ops, BulkPayload{Operation: op})
+	}
+	return ops
+}
+
+// BulkUpdateResponse is the response from POST /api/v1/clips/bulk-update
+type BulkUpdateResponse struct {
+	Operations []string         `json:"operations"` // Applied to each clip, in order
+	Applied    bool             `json:"applied"`    // False when any item failed and nothing was changed
+	Results    []BulkItemResult `json:"results"`
+}
+
+// BulkItemResult is the outcome for one clip of a bulk operation
+type BulkItemResult struct {
+	ID      string `json:"id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BulkResponse is the response from POST /api/v1/clips/bulk
+type BulkResponse struct {
+	Operation string           `json:"operation"`
+	Applied   bool             `json:"applied"` // False when any item failed and nothing was changed
+	Results   []BulkItemResult `json:"results"`
+}
+
+// bulkClips applies one operation to many clips. It is all-or-nothing: if any
+// clip fails, the transaction is rolled back and the per-item results explain why.
+func bulkClips(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	var req BulkPayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	if err := checkBulkIDs(req.IDs); err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+
+	apply, err := bulkOperation(c, tx, userID, req)
+	if err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+
+	results, failed, err := applyBulk(c, userID, req.IDs, apply, req.Operation == bulkDelete)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if failed {
+		// A 4xx status makes the transaction middleware roll back
+		return c.Render(http.StatusUnprocessableEntity, r.JSON(BulkResponse{
+			Operation: req.Operation,
+			Results:   results,
+		}))
+	}
+
+	return c.Render(http.StatusOK, r.JSON(BulkResponse{
+		Operation: req.Operation,
+		Applied:   true,
+		Results:   results,
+	}))
+}
+
+// bulkUpdateClips applies several changes to many clips at once, e.g. to tag
+// and file an import. Like bulkClips it is all-or-nothing.
+func bulkUpdateClips(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	var req BulkUpdatePayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+	if err := checkBulkIDs(req.IDs); err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+
+	ops := req.operations()
+	if len(ops) == 0 {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("nothing to update"))
+	}
+	names := make([]string, len(ops))
+	steps := make([]func(*models.Clip) error, len(ops))
+	for i, op := range ops {
+		names[i] = op.Operation
+		if steps[i], err = bulkOperation(c, tx, userID, op); err != nil {
+			return c.Error(http.StatusBadRequest, err)
+		}
+	}
+	apply := func(clip *models.Clip) error {
+		for i, step := range steps {
+			if err := step(clip); err != nil {
+				return fmt.Errorf("%s: %w", names[i], err)
+			}
+		}
+		return nil
+	}
+
+	results, failed, err := applyBulk(c, userID, req.IDs, apply, false)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if failed {
+		// A 4xx status makes the transaction middleware roll back
+		return c.Render(http.StatusUnprocessableEntity, r.JSON(BulkUpdateResponse{
+			Operations: names,
+			Results:    results,
+		}))
+	}
+
+	return c.Render(http.StatusOK, r.JSON(BulkUpdateResponse{
+		Operations: names,
+		Applied:    true,
+		Results:    results,
+	}))
+}
+
+// checkBulkIDs validates the clip IDs of a bulk request
+func checkBulkIDs(ids []string) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("ids is required")
+	}
+	if len(ids) > maxBulkClips {
+		return fmt.Errorf("at most %d clips per request", maxBulkClips)
+	}
+	return nil
+}
+
+// applyBulk applies a change to each of the user's clips in ids, moving the
+// folders of clips that changed storage and, with trash, moving them to the
+// trash. When any clip fails nothing is moved and failed is set: the caller
+// must then respond with a 4xx status to roll back the transaction.
+func applyBulk(c buffalo.Context, userID uuid.UUID, ids []string, apply func(*models.Clip) error, trash bool) ([]BulkItemResult, bool, error) {
+	tx := c.Value("tx").(*pop.Connection)
+	user := &models.User{}
+	if err := tx.Find(user, userID); err != nil {
+		return nil, false, err
+	}
+	cfg := GetConfig()
+
+	// Database changes first; files are only moved once every clip succeeded
+	var trashed []*models.Clip
+	var relocated []movedFolder // Clips moved to a collection with another storage
+	results := make([]BulkItemResult, len(ids))
+	failed := false
+	for i, id := range ids {
+		results[i] = BulkItemResult{ID: id, Success: true}
+
+		clip, err := findBulkClip(tx, id, userID)
+		var before string
+		if err == nil {
+			before = clipRoot(cfg, user, clip)
+			err = apply(clip)
+		}
+		if err == nil {
+			if after := clipRoot(cfg, user, clip); filepath.Clean(after) != filepath.Clean(before) {
+				var moves []movedFolder
+				moves, err = clipMoves(clip, before, after)
+				if err == nil {
+					if _, statErr := os.Stat(moves[0].dst); statErr == nil {
+						err = fmt.Errorf("a folder already exists at %s", moves[0].dst)
+					} else {
+						relocated = append(relocated, moves...)
+					}
+				}
+			}
+		}
+		if err != nil {
+			results[i].Success = false
+			results[i].Error = err.Error()
+			failed = true
+			continue
+		}
+		if trash {
+			if err := recordAudit(c, tx, auditClipDelete, "clip_id", clip.ID.String()); err != nil {
+				return nil, false, err
+			}
+			trashed = append(trashed, clip)
+		}
+	}
+
+	if failed {
+		return results, true, nil
+	}
+
+	var moves folderMoves
+	for _, move := range relocated {
+		if err := moves.move(move.src, move.dst); err != nil {
+			moves.undo()
+			return nil, false, fmt.Errorf("failed to move %s: %w", move.src, err)
+		}
+	}
+	// The rows still point at the old storage if the commit fails
+	onRollback(c, moves.undo)
+
+	if len(trashed) > 0 {
+		for _, clip := range trashed {
+			if err := moveToTrash(clipRoot(cfg, user, clip), clip.Path); err != nil {
+				c.Logger().Warnf("Failed to move clip files to trash: %v", err)
+			}
+		}
+	}
+	return results, false, nil
+}
+
+// bulkOperation validates the operation's arguments and returns a function
+// applying it to a single clip
+func bulkOperation(c buffalo.Context, tx *pop.Connection, userID uuid.UUID, req BulkPayload) (func(*models.Clip) error, error) {
+	switch req.Operation {
+	case bulkDelete:
+		return func(clip *models.Clip) error {
+			clip.DeletedAt = nulls.NewTime(time.Now())
+			return tx.Update(clip)
+		}, nil
+
+	case bulkAddTags, bulkRemoveTags:
+		tags := models.CleanTagNames(req.Tags)
+		if len(tags) == 0 {
+			return nil, fmt.Errorf("tags is required for %s", req.Operation)
+		}
+		if req.Operation == bulkAddTags {
+			tags, err := resolveTags(tx, userID, tags)
+			if err != nil {
+				return nil, err
+			}
+			return func(clip *models.Clip) error {
+				if err := models.AddClipTags(tx, clip, tags); err != nil {
+					return err
+				}
+				refreshClipSidecarOnCommit(c, tx, clip)
+				return nil
+			}, nil
+		}
+		return func(clip *models.Clip) error {
+			if err := models.RemoveClipTags(tx, clip, tags); err != nil {
+				return err
+			}
+			refreshClipSidecarOnCommit(c, tx, clip)
+			return nil
+		}, nil
+
+	case bulkMoveToCollection:
+		// Clips follow the collection's storage (the clip directory without one)
+		collectionID, storagePath := nulls.UUID{}, nulls.String{}
+		if req.CollectionID != "" {
+			id, err := uuid.FromString(req.CollectionID)
+			if err != nil {
+				return nil, fmt.Errorf("invalid collection ID")
+			}
+			collection, err := models.FindCollectionByIDAndUser(tx, id, userID)
+			if err != nil {
+				return nil, fmt.Errorf("collection not found")
+			}
+			collectionID, storagePath = nulls.NewUUID(id), collection.StoragePath
+		}
+		return func(clip *models.Clip) error {
+			clip.CollectionID = collectionID
+			clip.StoragePath = storagePath
+			return tx.Update(clip)
+		}, nil
+
+	case bulkMarkRead, bulkMarkUnread:
+		readAt := nulls.Time{}
+		if req.Operation == bulkMarkRead {
+			readAt = nulls.NewTime(time.Now())
+		}
+		return func(clip *models.Clip) error {
+			if clip.ReadAt.Valid == readAt.Valid {
+				return nil // Already in the requested state
+			}
+			clip.ReadAt = readAt
+			return tx.Update(clip)
+		}, nil
+
+	case bulkArchive, bulkUnarchive:
+		archivedAt := nulls.Time{}
+		if req.Operation == bulkArchive {
+			archivedAt = nulls.NewTime(time.Now())
+		}
+		return func(clip *models.Clip) error {
+			if clip.ArchivedAt.Valid == archivedAt.Valid {
+				return nil // Already in the requested state
+			}
+			clip.ArchivedAt = archivedAt
+			return tx.Update(clip)
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported operation: %q", req.Operation)
+	}
+}
+
+// findBulkClip loads one of the user's clips by its string ID
+func findBulkClip(tx *pop.Connection, id string, userID uuid.UUID) (*models.Clip, error) {
+	clipID, err := uuid.FromString(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid clip ID")
+	}
+	clip, err := models.FindClipByIDAndUser(tx, clipID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("clip not found")
+	}
+	return clip, nil
+}
@@ -0,0 +1,96 @@
+package actions
+
+import (
+	"net/http"
+
+	"server/internal/testkit"
+
+	"github.com/gofrs/uuid"
+)
+
+func (as *ActionSuite) Test_BulkClips_Unauthorized() {
+	res := as.JSON("/api/v1/clips/bulk").Post(BulkPayload{
+		Operation: bulkArchive,
+		IDs:       []string{"550e8400-e29b-41d4-a716-446655440000"},
+	})
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_ListCollections_Unauthorized() {
+	res := as.JSON("/api/v1/collections").Get()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_BulkOperation_Validation() {
+	userID := uuid.Must(uuid.NewV4())
+
+	_, err := bulkOperation(nil, nil, userID, BulkPayload{Operation: "rename"})
+	as.Error(err)
+
+	_, err = bulkOperation(nil, nil, userID, BulkPayload{Operation: bulkAddTags, Tags: []string{" ", ""}})
+	as.Error(err)
+
+	_, err = bulkOperation(nil, nil, userID, BulkPayload{Operation: bulkMoveToCollection, CollectionID: "nope"})
+	as.Error(err)
+
+	apply, err := bulkOperation(nil, nil, userID, BulkPayload{Operation: bulkArchive})
+	as.NoError(err)
+	as.NotNil(apply)
+}
+
+func (as *ActionSuite) Test_BulkUpdateClips() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+	first := kit.CreateClip(user, testkit.WithTags("import", "todo"))
+	second := kit.CreateClip(user, testkit.WithTags("import"))
+	ids := []string{first.ID.String(), second.ID.String()}
+
+	var collection CollectionResponse
+	client.Post("/api/v1/collections", CollectionPayload{Name: "Imported"}).JSON(&collection)
+
+	yes := true
+	var resp BulkUpdateResponse
+	res := client.Post("/api/v1/clips/bulk-update", BulkUpdatePayload{
+		IDs:          ids,
+		AddTags:      []string{"reviewed"},
+		RemoveTags:   []string{"todo"},
+		CollectionID: &collection.ID,
+		Read:         &yes,
+	})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&resp)
+	as.True(resp.Applied)
+	as.Equal([]string{bulkAddTags, bulkRemoveTags, bulkMoveToCollection, bulkMarkRead}, resp.Operations)
+
+	var list ListClipsResponse
+	client.Get("/api/v1/clips?read=true&collection=" + collection.ID).JSON(&list)
+	as.Len(list.Clips, 2)
+	for _, clip := range list.Clips {
+		as.ElementsMatch([]string{"import", "reviewed"}, clip.Tags)
+		as.NotNil(clip.ReadAt)
+	}
+	list = ListClipsResponse{}
+	client.Get("/api/v1/clips?read=false").JSON(&list)
+	as.Empty(list.Clips)
+
+	// One bad clip rolls back the whole update
+	res = client.Post("/api/v1/clips/bulk-update", BulkUpdatePayload{
+		IDs:      []string{first.ID.String(), uuid.Must(uuid.NewV4()).String()},
+		AddTags:  []string{"never"},
+		Archived: &yes,
+	})
+	as.Equal(http.StatusUnprocessableEntity, res.Code, res.Body.String())
+	resp = BulkUpdateResponse{}
+	res.JSON(&resp)
+	as.False(resp.Applied)
+	as.True(resp.Results[0].Success)
+	as.Equal("clip not found", resp.Results[1].Error)
+	var detail ClipSummary
+	client.Get("/api/v1/clips/" + first.ID.String()).JSON(&detail)
+	as.NotContains(detail.Tags, "never")
+	as.Nil(detail.ArchivedAt)
+
+	as.Equal(http.StatusBadRequest, client.Post("/api/v1/clips/bulk-update", BulkUpdatePayload{IDs: ids}).Code)
+	as.Equal(http.StatusBadRequest, client.Get("/api/v1/clips?read=maybe").Code)
+}
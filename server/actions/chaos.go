@@ -0,0 +1,27 @@
+package actions
+
+import (
+	"log"
+	"time"
+
+	"server/internal/config"
+	"server/internal/fsys"
+)
+
+// chaosFS returns the file system clip folders are written through: with
+// dev_mode.chaos in dev mode, one failing some operations so the error
+// handling of clip creation runs against the real app
+func chaosFS(cfg *config.Config) fsys.FS {
+	chaos := cfg.DevMode.Chaos
+	if !devModeEnabled(cfg) || !chaos.Enabled {
+		return fsys.OS{}
+	}
+	log.Printf("WARNING: Chaos mode is ENABLED - clip writes fail at random (errors %.0f%%, disk full %.0f%%, +%dms per write)",
+		chaos.ErrorRate*100, chaos.NoSpaceRate*100, chaos.WriteDelayMs)
+	return fsys.NewFaulty(fsys.OS{}, fsys.Faults{
+		ErrorRate:   chaos.ErrorRate,
+		NoSpaceRate: chaos.NoSpaceRate,
+		WriteDelay:  time.Duration(chaos.WriteDelayMs) * time.Millisecond,
+		Ops:         chaos.Ops,
+	})
+}
@@ -0,0 +1,96 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/config"
+	"server/internal/fsys"
+	"server/internal/testkit"
+	"server/models"
+)
+
+func (as *ActionSuite) Test_CreateClip_StorageFaults() {
+	kit := testkit.New(as.T())
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+	defer func() { clipFS = fsys.OS{} }()
+
+	var buf bytes.Buffer
+	as.NoError(png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 10, 10))))
+	payload := func(i int) ClipPayload {
+		return ClipPayload{
+			Title:    "Article",
+			URL:      fmt.Sprintf("https://example.com/%d", i),
+			Markdown: "![](media/hero.png)",
+			Mode:     "article",
+			Images:   []ImagePayload{{Filename: "hero.png", Data: base64.StdEncoding.EncodeToString(buf.Bytes())}},
+		}
+	}
+
+	faults := []fsys.Faults{
+		{ErrorRate: 1, Ops: []string{fsys.OpMkdir}},
+		{ErrorRate: 1, Ops: []string{fsys.OpOpen}},
+		{ErrorRate: 1, Ops: []string{fsys.OpWrite}},
+		{ErrorRate: 1, Ops: []string{fsys.OpSync}},
+		{ErrorRate: 1, Ops: []string{fsys.OpRename}},
+		{NoSpaceRate: 1},
+	}
+	for i, f := range faults {
+		clipFS = fsys.NewFaulty(fsys.OS{}, f)
+		res := client.Post("/api/v1/clips", payload(i))
+		as.Equal(http.StatusInternalServerError, res.Code, "%+v: %s", f, res.Body.String())
+
+		// Nothing is left behind: no row, no folder, no staging folder
+		count, err := kit.DB.Count(&models.Clips{})
+		as.NoError(err)
+		as.Zero(count, "%+v", f)
+		entries, _ := os.ReadDir(filepath.Join(kit.StorageRoot, "web-clips"))
+		as.Empty(entries, "%+v", f)
+	}
+
+	// Failures at random leave either a complete clip or nothing
+	clipFS = fsys.NewFaulty(fsys.OS{}, fsys.Faults{ErrorRate: 0.1, NoSpaceRate: 0.1, Seed: 1})
+	saved := 0
+	for i := 0; i < 20; i++ {
+		res := client.Post("/api/v1/clips", payload(len(faults)+i))
+		if res.Code == http.StatusOK {
+			saved++
+		} else {
+			as.Equal(http.StatusInternalServerError, res.Code, res.Body.String())
+		}
+	}
+	clips := models.Clips{}
+	as.NoError(kit.DB.All(&clips))
+	as.Len(clips, saved)
+	as.NotZero(saved)
+	as.Less(saved, 20)
+	for _, clip := range clips {
+		as.Empty(checkClipFolder(filepath.Join(kit.StorageRoot, clip.Path)))
+		_, err := os.Stat(filepath.Join(kit.StorageRoot, clip.Path, "media", "hero.png"))
+		as.NoError(err)
+	}
+	entries, err := os.ReadDir(filepath.Join(kit.StorageRoot, "web-clips"))
+	as.NoError(err)
+	as.Len(entries, saved)
+}
+
+func (as *ActionSuite) Test_ChaosFS() {
+	cfg := &config.Config{}
+	cfg.DevMode.Chaos.Enabled = true
+	as.Equal(fsys.OS{}, chaosFS(cfg)) // Never outside dev mode
+
+	cfg.DevMode.Enabled = true
+	cfg.DevMode.Chaos.ErrorRate = 1
+	if !devBuild {
+		as.Equal(fsys.OS{}, chaosFS(cfg)) // Production builds ignore dev_mode
+		return
+	}
+	_, err := chaosFS(cfg).MkdirTemp(as.T().TempDir(), "x-")
+	as.Error(err)
+}
@@ -0,0 +1,313 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Chunked uploads carry a POST /api/v1/clips body (JSON or multipart) that
+// is too large for one request through a proxy: the client starts a session
+// with the body's content type, PUTs it in chunks at increasing offsets,
+// then finalizes it, which saves the clip as if the body had been posted
+// at once. A chunk sent at the wrong offset gets a 409 with the current
+// offset, so interrupted uploads resume where the server left off.
+
+// UploadStartPayload is the request body for starting an upload session
+type UploadStartPayload struct {
+	ContentType string `json:"content_type"` // application/json or multipart/form-data with its boundary
+}
+
+// UploadSessionResponse describes an upload session
+type UploadSessionResponse struct {
+	ID            string    `json:"id"`
+	Offset        int64     `json:"offset"` // Bytes received; the next chunk starts here
+	MaxChunkBytes int64     `json:"max_chunk_bytes"`
+	MaxBytes      int64     `json:"max_bytes"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// startUpload creates an upload session
+func startUpload(c buffalo.Context) error {
+	cfg := GetConfig()
+	if cfg == nil {
+		return c.Error(http.StatusInternalServerError, fmt.Errorf("configuration not loaded"))
+	}
+
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	var req UploadStartPayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+	mediaType, params, err := mime.ParseMediaType(req.ContentType)
+	switch {
+	case err != nil:
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid content_type"))
+	case mediaType == "multipart/form-data" && params["boundary"] == "":
+		return c.Error(http.StatusBadRequest, fmt.Errorf("multipart content_type needs a boundary"))
+	case mediaType != "application/json" && mediaType != "multipart/form-data":
+		return c.Error(http.StatusBadRequest, fmt.Errorf("content_type must be application/json or multipart/form-data"))
+	}
+
+	session := &models.UploadSession{
+		ID:          uuid.Must(uuid.NewV4()),
+		UserID:      userID,
+		ContentType: req.ContentType,
+		ExpiresAt:   time.Now().Add(time.Duration(cfg.Uploads.SessionTTLHours) * time.Hour),
+	}
+	verrs, err := tx.ValidateAndCreate(session)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	return c.Render(http.StatusCreated, r.JSON(uploadToResponse(session)))
+}
+
+// getUpload returns an upload session, to find where to resume
+func getUpload(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	session, _, err := findUserUpload(c, tx)
+	if err != nil {
+		return err
+	}
+	return c.Render(http.StatusOK, r.JSON(uploadToResponse(session)))
+}
+
+// appendUpload writes the request body at ?offset=, which must be the number
+// of bytes received so far
+func appendUpload(c buffalo.Context) error {
+	cfg := GetConfig()
+	if cfg == nil {
+		return c.Error(http.StatusInternalServerError, fmt.Errorf("configuration not loaded"))
+	}
+
+	tx := c.Value("tx").(*pop.Connection)
+	session, user, err := findUserUpload(c, tx)
+	if err != nil {
+		return err
+	}
+
+	offset, err := strconv.ParseInt(c.Param("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("offset is required"))
+	}
+	if offset != session.Size {
+		return c.Render(http.StatusConflict, r.JSON(map[string]interface{}{
+			"error":  newErrorDetail(c, http.StatusConflict, "offset_mismatch", fmt.Sprintf("expected offset %d", session.Size)),
+			"offset": session.Size,
+		}))
+	}
+
+	n, err := writeChunk(uploadPath(userClipDir(cfg, user), session.ID), offset, c.Request().Body,
+		cfg.Uploads.MaxChunkBytes, cfg.Uploads.MaxBytes-offset)
+	var uerr *uploadError
+	if errors.As(err, &uerr) {
+		recordRejection(c, uerr.reason, uerr.msg)
+		return c.Error(uerr.status, uerr)
+	}
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	session.Size = offset + n
+	if err := tx.Update(session); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	return c.Render(http.StatusOK, r.JSON(uploadToResponse(session)))
+}
+
+// finalizeUpload saves the assembled upload as a clip, exactly as
+// POST /api/v1/clips would, and ends the session if that succeeds
+func finalizeUpload(c buffalo.Context) error {
+	cfg := GetConfig()
+	if cfg == nil {
+		return c.Error(http.StatusInternalServerError, fmt.Errorf("configuration not loaded"))
+	}
+
+	tx := c.Value("tx").(*pop.Connection)
+	session, user, err := findUserUpload(c, tx)
+	if err != nil {
+		return err
+	}
+
+	path := uploadPath(userClipDir(cfg, user), session.ID)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("nothing was uploaded"))
+	}
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	defer f.Close()
+
+	req := c.Request()
+	req.Body = f
+	req.ContentLength = session.Size
+	req.Header.Set("Content-Type", session.ContentType)
+
+	if err := createClip(c); err != nil {
+		return err
+	}
+	if res, ok := c.Response().(*buffalo.Response); ok && res.Status >= 400 {
+		return nil // Kept for another attempt until it expires
+	}
+
+	if err := tx.Destroy(session); err != nil {
+		return err
+	}
+	onCommit(c, func() { os.Remove(path) })
+	return nil
+}
+
+// abortUpload deletes an upload session and what was received
+func abortUpload(c buffalo.Context) error {
+	cfg := GetConfig()
+	if cfg == nil {
+		return c.Error(http.StatusInternalServerError, fmt.Errorf("configuration not loaded"))
+	}
+
+	tx := c.Value("tx").(*pop.Connection)
+	session, user, err := findUserUpload(c, tx)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Destroy(session); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	path := uploadPath(userClipDir(cfg, user), session.ID)
+	onCommit(c, func() { os.Remove(path) })
+
+	return c.Render(http.StatusNoContent, nil)
+}
+
+// findUserUpload loads the {id} upload session of the current user and the
+// user, or renders the error
+func findUserUpload(c buffalo.Context, tx *pop.Connection) (*models.UploadSession, *models.User, error) {
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return nil, nil, c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	sessionID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return nil, nil, c.Error(http.StatusBadRequest, fmt.Errorf("invalid upload ID"))
+	}
+
+	session, err := models.FindUploadSessionByIDAndUser(tx, sessionID, userID)
+	if err != nil {
+		return nil, nil, c.Error(http.StatusNotFound, fmt.Errorf("upload not found"))
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, userID); err != nil {
+		return nil, nil, c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+	return session, user, nil
+}
+
+// uploadPath is where an upload session's bytes are assembled, next to the
+// clip folders so multipart images can be moved into place
+func uploadPath(clipDir string, sessionID uuid.UUID) string {
+	return filepath.Join(clipDir, "web-clips", ".uploads", sessionID.String())
+}
+
+// writeChunk writes body to path at offset, flushed to disk, and truncates
+// the file after it, dropping bytes of an earlier attempt that was not
+// recorded. A chunk over maxChunk or remaining bytes is rejected with a 413
+// and leaves the file as it was.
+func writeChunk(path string, offset int64, body io.Reader, maxChunk, remaining int64) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	limit := maxChunk
+	if remaining < limit {
+		limit = remaining
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, io.LimitReader(body, limit+1))
+	if err == nil && n > limit {
+		err = &uploadError{http.StatusRequestEntityTooLarge, rejectInvalidPayload, fmt.Sprintf("chunk exceeds %d bytes", maxChunk)}
+		if n > remaining {
+			err = &uploadError{http.StatusRequestEntityTooLarge, rejectQuota, "upload exceeds the maximum size"}
+		}
+	}
+	if err != nil {
+		f.Truncate(offset)
+		return 0, err
+	}
+	if err := f.Truncate(offset + n); err != nil {
+		return 0, err
+	}
+	return n, f.Sync()
+}
+
+// PurgeExpiredUploads deletes upload sessions past their expiry with their
+// files and returns how many were deleted
+func PurgeExpiredUploads(db *pop.Connection) (int, error) {
+	sessions, err := models.FindExpiredUploadSessions(db, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for i := range sessions {
+		user := &models.User{}
+		if err := db.Find(user, sessions[i].UserID); err == nil {
+			if err := os.Remove(uploadPath(userClipDir(GetConfig(), user), sessions[i].ID)); err != nil && !os.IsNotExist(err) {
+				log.Printf("upload purge: failed to delete upload %s: %v", sessions[i].ID, err)
+				continue
+			}
+		}
+		if err := db.Destroy(&sessions[i]); err != nil {
+			log.Printf("upload purge: failed to delete upload %s: %v", sessions[i].ID, err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// uploadToResponse converts an upload session to its API representation
+func uploadToResponse(s *models.UploadSession) UploadSessionResponse {
+	cfg := GetConfig()
+	return UploadSessionResponse{
+		ID:            s.ID.String(),
+		Offset:        s.Size,
+		MaxChunkBytes: cfg.Uploads.MaxChunkBytes,
+		MaxBytes:      cfg.Uploads.MaxBytes,
+		ExpiresAt:     s.ExpiresAt,
+	}
+}
@@ -0,0 +1,171 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/gofrs/uuid"
+)
+
+// uploadChunks sends body from offset start to an upload session in chunks
+// of size bytes
+func (as *ActionSuite) uploadChunks(client *testkit.Client, session UploadSessionResponse, body []byte, start, size int) {
+	for offset := start; offset < len(body); offset += size {
+		end := offset + size
+		if end > len(body) {
+			end = len(body)
+		}
+		path := fmt.Sprintf("/api/v1/uploads/%s?offset=%d", session.ID, offset)
+		res := client.Put(path, bytes.NewReader(body[offset:end]))
+		as.Require().Equal(http.StatusOK, res.Code, res.Body.String())
+	}
+}
+
+func (as *ActionSuite) Test_ChunkedUpload_JSON() {
+	kit := testkit.New(as.T())
+	kit.Config.Uploads.MaxChunkBytes = 1024
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+
+	html := "<html><head></head><body>" + strings.Repeat("<p>Very long page</p>", 500) + "</body></html>"
+	body, _ := json.Marshal(ClipPayload{Title: "Huge page", URL: "https://example.com/huge", Mode: "fullpage", HTML: html})
+
+	var session UploadSessionResponse
+	res := client.Post("/api/v1/uploads", UploadStartPayload{ContentType: "application/json"})
+	as.Equal(http.StatusCreated, res.Code, res.Body.String())
+	res.JSON(&session)
+	as.Equal(int64(1024), session.MaxChunkBytes)
+
+	as.uploadChunks(client, session, body[:2048], 0, 1024)
+
+	// A retried or out-of-order chunk is told where to resume
+	res = client.Put("/api/v1/uploads/"+session.ID+"?offset=1024", bytes.NewReader(body[1024:2048]))
+	as.Equal(http.StatusConflict, res.Code)
+	var conflict struct {
+		Offset int64 `json:"offset"`
+	}
+	res.JSON(&conflict)
+	as.Equal(int64(2048), conflict.Offset)
+
+	res = client.Put("/api/v1/uploads/"+session.ID+"?offset=2048", bytes.NewReader(body[2048:2048+1025]))
+	as.Equal(http.StatusRequestEntityTooLarge, res.Code)
+
+	res = client.Get("/api/v1/uploads/" + session.ID)
+	as.Equal(http.StatusOK, res.Code)
+	res.JSON(&session)
+	as.Equal(int64(2048), session.Offset)
+
+	as.uploadChunks(client, session, body, 2048, 1024)
+
+	var clip ClipResponse
+	res = client.Post("/api/v1/uploads/"+session.ID+"/finalize", nil)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+	as.True(clip.Success)
+
+	data, err := os.ReadFile(filepath.Join(kit.StorageRoot, clip.Path))
+	as.NoError(err)
+	as.Contains(string(data), html)
+
+	// The session is gone with its file
+	as.Equal(http.StatusNotFound, client.Get("/api/v1/uploads/"+session.ID).Code)
+	id := uuid.FromStringOrNil(session.ID)
+	_, err = os.Stat(uploadPath(userClipDir(kit.Config, user), id))
+	as.True(os.IsNotExist(err))
+}
+
+func (as *ActionSuite) Test_ChunkedUpload_Multipart() {
+	kit := testkit.New(as.T())
+	kit.Config.Uploads.MaxChunkBytes = 64
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	payload := ClipPayload{
+		Title:  "Screenshot",
+		URL:    "https://example.com/shot",
+		Mode:   "screenshot",
+		Images: []ImagePayload{{Filename: "shot.png"}},
+	}
+	image := append([]byte(pngMagic), bytes.Repeat([]byte("p"), 300)...)
+	body, contentType := multipartClip(payload, map[string][]byte{"shot.png": image})
+
+	var session UploadSessionResponse
+	res := client.Post("/api/v1/uploads", UploadStartPayload{ContentType: contentType})
+	as.Equal(http.StatusCreated, res.Code, res.Body.String())
+	res.JSON(&session)
+	as.uploadChunks(client, session, body.Bytes(), 0, 64)
+
+	var clip ClipResponse
+	res = client.Post("/api/v1/uploads/"+session.ID+"/finalize", nil)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+
+	data, err := os.ReadFile(filepath.Join(kit.StorageRoot, filepath.Dir(clip.Path), "media", "shot.png"))
+	as.NoError(err)
+	as.Equal(image, data)
+	as.Empty(stagingDirs(kit))
+}
+
+func (as *ActionSuite) Test_ChunkedUpload_Limits() {
+	kit := testkit.New(as.T())
+	kit.Config.Uploads.MaxBytes = 10
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+
+	for _, contentType := range []string{"", "text/plain", "multipart/form-data"} {
+		res := client.Post("/api/v1/uploads", UploadStartPayload{ContentType: contentType})
+		as.Equal(http.StatusBadRequest, res.Code, contentType)
+	}
+
+	var session UploadSessionResponse
+	client.Post("/api/v1/uploads", UploadStartPayload{ContentType: "application/json"}).JSON(&session)
+	as.uploadChunks(client, session, []byte(`{"title":`), 0, 9)
+	res := client.Put("/api/v1/uploads/"+session.ID+"?offset=9", strings.NewReader(`"too long"}`))
+	as.Equal(http.StatusRequestEntityTooLarge, res.Code)
+
+	// Finalizing an invalid body keeps the session for another attempt
+	res = client.Post("/api/v1/uploads/"+session.ID+"/finalize", nil)
+	as.Equal(http.StatusBadRequest, res.Code)
+	as.Equal(http.StatusOK, client.Get("/api/v1/uploads/"+session.ID).Code)
+
+	other := kit.Client(newKitApp(kit), kit.CreateUser())
+	as.Equal(http.StatusNotFound, other.Get("/api/v1/uploads/"+session.ID).Code)
+
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/uploads/"+session.ID).Code)
+	as.Equal(http.StatusNotFound, client.Get("/api/v1/uploads/"+session.ID).Code)
+	_, err := os.Stat(uploadPath(userClipDir(kit.Config, user), uuid.FromStringOrNil(session.ID)))
+	as.True(os.IsNotExist(err))
+}
+
+func (as *ActionSuite) Test_PurgeExpiredUploads() {
+	kit := testkit.New(as.T())
+	newKitApp(kit)
+	user := kit.CreateUser()
+
+	expired := &models.UploadSession{ID: uuid.Must(uuid.NewV4()), UserID: user.ID, ContentType: "application/json", ExpiresAt: time.Now().Add(-time.Minute)}
+	active := &models.UploadSession{ID: uuid.Must(uuid.NewV4()), UserID: user.ID, ContentType: "application/json", ExpiresAt: time.Now().Add(time.Hour)}
+	for _, s := range []*models.UploadSession{expired, active} {
+		as.NoError(kit.DB.Create(s))
+		_, err := writeChunk(uploadPath(userClipDir(kit.Config, user), s.ID), 0, strings.NewReader("{}"), 10, 10)
+		as.NoError(err)
+	}
+
+	purged, err := PurgeExpiredUploads(kit.DB)
+	as.NoError(err)
+	as.Equal(1, purged)
+
+	_, err = os.Stat(uploadPath(userClipDir(kit.Config, user), expired.ID))
+	as.True(os.IsNotExist(err))
+	_, err = os.Stat(uploadPath(userClipDir(kit.Config, user), active.ID))
+	as.NoError(err)
+	count, _ := kit.DB.Count(&models.UploadSession{})
+	as.Equal(1, count)
+}
@@ -0,0 +1,233 @@
+package actions
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// ClipFilter is a combination of list filters, shared by GET /api/v1/clips
+// and saved searches
+type ClipFilter struct {
+	Query  string   `json:"query,omitempty"`  // Search query: words, "phrases" and field:value terms (see query.go)
+	Fields []string `json:"fields,omitempty"` // Fields the query's words are searched in: title, highlights, notes, body (default: all)
+	Sort   string   `json:"sort,omitempty"`   // "date" (default) or "relevance", for queries with words
+	Tags   []string `json:"tags,omitempty"`   // Clips must have all of these tags
+	Mode   string   `json:"mode,omitempty"`   // article, bookmark, screenshot, ...
+	Domain string   `json:"domain,omitempty"` // Matches the domain and its subdomains
+	Lang   string   `json:"lang,omitempty"`   // ISO 639-1 language code
+	From   string   `json:"from,omitempty"`   // Clipped on or after (YYYY-MM-DD or RFC 3339)
+	To     string   `json:"to,omitempty"`     // Clipped on or before
+	Near   string   `json:"near,omitempty"`   // "lat,lon"
+	Radius string   `json:"radius_km,omitempty"`
+	BBox   string   `json:"bbox,omitempty"` // "minLon,minLat,maxLon,maxLat"
+
+	Collection string `json:"collection,omitempty"` // Collection ID
+	Archived   string `json:"archived,omitempty"`   // "" hides archived clips, "true" shows only them, "all" both
+	Read       string `json:"read,omitempty"`       // "true" or "false" keeps only read or unread clips
+}
+
+// clipFilterFromParams reads filters from the query string
+func clipFilterFromParams(c buffalo.Context) ClipFilter {
+	filter := ClipFilter{
+		Query:  strings.TrimSpace(c.Param("q")),
+		Mode:   c.Param("mode"),
+		Domain: strings.ToLower(strings.TrimSpace(c.Param("domain"))),
+		Lang:   strings.ToLower(strings.TrimSpace(c.Param("lang"))),
+		From:   c.Param("from"),
+		To:     c.Param("to"),
+		Near:   c.Param("near"),
+		Radius: c.Param("radius_km"),
+		BBox:   c.Param("bbox"),
+
+		Collection: c.Param("collection"),
+		Archived:   c.Param("archived"),
+		Read:       c.Param("read"),
+	}
+	if tag := c.Param("tag"); tag != "" {
+		filter.Tags = []string{tag}
+	}
+	if fields := c.Param("fields"); fields != "" {
+		filter.Fields = strings.Split(fields, ",")
+	}
+	filter.Sort = c.Param("sort")
+	return filter
+}
+
+// apply adds the filter's conditions to a clips query. Errors describe
+// invalid filter values.
+func (f ClipFilter) apply(q *pop.Query, userID uuid.UUID) (*pop.Query, error) {
+	if f.Mode != "" {
+		q = q.Where("mode = ?", f.Mode)
+	}
+	archived := f.Archived
+	if archived == "" && queryShowsArchived(f.Query) {
+		archived = "all" // is:archived looks past the default
+	}
+	switch archived {
+	case "", "false":
+		q = q.Where("archived_at IS NULL")
+	case "true":
+		q = q.Where("archived_at IS NOT NULL")
+	case "all":
+	default:
+		return nil, fmt.Errorf("invalid archived value: expected true, false or all")
+	}
+	switch f.Read {
+	case "":
+	case "true":
+		q = q.Where("read_at IS NOT NULL")
+	case "false":
+		q = q.Where("read_at IS NULL")
+	default:
+		return nil, fmt.Errorf("invalid read value: expected true or false")
+	}
+	if f.Collection != "" {
+		collectionID, err := uuid.FromString(f.Collection)
+		if err != nil {
+			return nil, fmt.Errorf("invalid collection ID")
+		}
+		q = q.Where("collection_id = ?", collectionID)
+	}
+	for _, tag := range f.Tags {
+		q = q.Where("id IN (SELECT clips_tags.clip_id FROM clips_tags JOIN tags ON tags.id = clips_tags.tag_id WHERE tags.user_id = ? AND tags.name = ?)", userID, tag)
+	}
+	fields, err := parseSearchFields(f.Fields)
+	if err != nil {
+		return nil, err
+	}
+	switch f.Sort {
+	case "", sortDate, sortRelevance:
+	default:
+		return nil, fmt.Errorf("invalid sort value: expected date or relevance")
+	}
+	if f.Query != "" {
+		if q, err = applyQuery(q, f.Query, fields, userID); err != nil {
+			return nil, err
+		}
+	}
+	if f.Domain != "" {
+		q = q.Where("(domain = ? OR domain LIKE ?)", f.Domain, "%."+f.Domain)
+	}
+	if f.Lang != "" {
+		q = q.Where("language = ?", f.Lang)
+	}
+	if f.From != "" {
+		from, err := parseDateParam(f.From, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from date: %w", err)
+		}
+		q = q.Where("created_at >= ?", from)
+	}
+	if f.To != "" {
+		to, err := parseDateParam(f.To, true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to date: %w", err)
+		}
+		q = q.Where("created_at < ?", to)
+	}
+	if f.Near != "" {
+		box, err := parseNear(f.Near, f.Radius)
+		if err != nil {
+			return nil, err
+		}
+		q = box.where(q)
+	}
+	if f.BBox != "" {
+		box, err := parseBBox(f.BBox)
+		if err != nil {
+			return nil, err
+		}
+		q = box.where(q)
+	}
+	return q, nil
+}
+
+// Listing orders (sort)
+const (
+	sortDate      = "date"      // Newest first
+	sortRelevance = "relevance" // Highest score first, then newest
+)
+
+// clipScore is the relevance score of the clips a search finds
+type clipScore struct {
+	expr string // SQL expression
+	args []interface{}
+	rank bool // Order clips by score
+}
+
+// score returns the relevance score of the clips the filter finds, or nil
+// when its query has no words. The filter must have been applied.
+func (f ClipFilter) score(userID uuid.UUID) *clipScore {
+	fields, err := parseSearchFields(f.Fields)
+	if err != nil {
+		return nil
+	}
+	expr, args := queryScore(f.Query, fields, userID)
+	if expr == "" {
+		return nil
+	}
+	return &clipScore{expr: expr, args: args, rank: f.Sort == sortRelevance}
+}
+
+// clipScoreRow is the score of a clip
+type clipScoreRow struct {
+	ID    uuid.UUID `db:"id"`
+	Score int       `db:"score"`
+}
+
+// clipScores returns the scores of clips by ID
+func clipScores(tx *pop.Connection, clips models.Clips, score *clipScore) (map[uuid.UUID]int, error) {
+	scores := make(map[uuid.UUID]int, len(clips))
+	if len(clips) == 0 {
+		return scores, nil
+	}
+	placeholders := make([]string, len(clips))
+	args := append([]interface{}{}, score.args...)
+	for i := range clips {
+		placeholders[i] = "?"
+		args = append(args, clips[i].ID)
+	}
+	rows := []clipScoreRow{}
+	query := fmt.Sprintf("SELECT id, %s AS score FROM clips WHERE id IN (%s)", score.expr, strings.Join(placeholders, ", "))
+	if err := tx.RawQuery(query, args...).All(&rows); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		scores[row.ID] = row.Score
+	}
+	return scores, nil
+}
+
+// parseDateParam accepts RFC 3339 timestamps or YYYY-MM-DD dates. A date used
+// as an upper bound covers the whole day.
+func parseDateParam(value string, upper bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.UTC)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or RFC 3339, got %q", value)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// clipDomain returns the lowercased host of a URL (without port), stored on
+// clips for domain filters
+func clipDomain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,30 @@
+package actions
+
+func (as *ActionSuite) Test_ParseDateParam() {
+	from, err := parseDateParam("2026-03-01", false)
+	as.NoError(err)
+	as.Equal("2026-03-01T00:00:00Z", from.Format("2006-01-02T15:04:05Z07:00"))
+
+	to, err := parseDateParam("2026-03-31", true)
+	as.NoError(err)
+	as.Equal("2026-04-01T00:00:00Z", to.Format("2006-01-02T15:04:05Z07:00"))
+
+	_, err = parseDateParam("last month", false)
+	as.Error(err)
+}
+
+func (as *ActionSuite) Test_ClipDomain() {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://arxiv.org/abs/2401.00001", "arxiv.org"},
+		{"https://WWW.Example.com:8443/path?q=1", "www.example.com"},
+		{"http://user@blog.example.org#top", "blog.example.org"},
+		{"not a url", ""},
+	}
+
+	for _, tt := range tests {
+		as.Equal(tt.expected, clipDomain(tt.input), "clipDomain(%q)", tt.input)
+	}
+}
@@ -0,0 +1,262 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Clip rules file new clips without manual input: every rule matching the
+// clip's domain or URL adds its tags, and the first matching rule setting a
+// collection or mode decides it. Rules run on each save, so re-clipping a URL
+// keeps its tags.
+
+// clipModes are the modes a clip can be saved in
+var clipModes = []string{"article", "bookmark", "screenshot", "selection", "fullpage", "pdf"}
+
+// ClipRulePayload is the request body for creating or updating a clip rule
+type ClipRulePayload struct {
+	Domain       string   `json:"domain,omitempty"`      // Matches the domain and its subdomains
+	URLPattern   string   `json:"url_pattern,omitempty"` // Matches the whole URL; * stands for any characters
+	Tags         []string `json:"tags"`
+	CollectionID string   `json:"collection_id,omitempty"`
+	Mode         string   `json:"mode,omitempty"`
+}
+
+// ClipRuleResponse is the API representation of a clip rule
+type ClipRuleResponse struct {
+	ID           string    `json:"id"`
+	Domain       string    `json:"domain,omitempty"`
+	URLPattern   string    `json:"url_pattern,omitempty"`
+	Tags         []string  `json:"tags"`
+	CollectionID string    `json:"collection_id,omitempty"`
+	Mode         string    `json:"mode,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// listClipRules returns the user's clip rules in the order they apply
+func listClipRules(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	rules, err := models.FindClipRulesByUserID(tx, userID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	resp := make([]ClipRuleResponse, len(rules))
+	for i := range rules {
+		resp[i] = clipRuleToResponse(&rules[i])
+	}
+
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"rules": resp,
+	}))
+}
+
+// createClipRule adds a rule applied to the user's next clips
+func createClipRule(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	var req ClipRulePayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	rule := &models.ClipRule{
+		ID:     uuid.Must(uuid.NewV4()),
+		UserID: userID,
+	}
+	if err := applyClipRulePayload(tx, rule, req); err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+
+	verrs, err := tx.ValidateAndCreate(rule)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	return c.Render(http.StatusCreated, r.JSON(clipRuleToResponse(rule)))
+}
+
+// updateClipRule replaces a clip rule's match and actions
+func updateClipRule(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	ruleID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid rule ID"))
+	}
+
+	var req ClipRulePayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	rule, err := models.FindClipRuleByIDAndUser(tx, ruleID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("rule not found"))
+	}
+	if err := applyClipRulePayload(tx, rule, req); err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+
+	verrs, err := tx.ValidateAndUpdate(rule)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	return c.Render(http.StatusOK, r.JSON(clipRuleToResponse(rule)))
+}
+
+// deleteClipRule removes a clip rule; clips it filed are left as they are
+func deleteClipRule(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	ruleID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid rule ID"))
+	}
+
+	rule, err := models.FindClipRuleByIDAndUser(tx, ruleID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("rule not found"))
+	}
+
+	if err := tx.Destroy(rule); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	return c.Render(http.StatusNoContent, nil)
+}
+
+// applyClipRulePayload validates the rule's match and actions and copies
+// them onto the model
+func applyClipRulePayload(tx *pop.Connection, rule *models.ClipRule, req ClipRulePayload) error {
+	rule.Domain = nulls.String{}
+	if domain := strings.ToLower(strings.TrimSpace(req.Domain)); domain != "" {
+		rule.Domain = nulls.NewString(domain)
+	}
+	rule.URLPattern = nulls.String{}
+	if pattern := strings.TrimSpace(req.URLPattern); pattern != "" {
+		rule.URLPattern = nulls.NewString(pattern)
+	}
+	rule.SetTagList(req.Tags)
+
+	rule.CollectionID = nulls.UUID{}
+	if req.CollectionID != "" {
+		id, err := uuid.FromString(req.CollectionID)
+		if err != nil {
+			return fmt.Errorf("invalid collection ID")
+		}
+		if _, err := models.FindCollectionByIDAndUser(tx, id, rule.UserID); err != nil {
+			return fmt.Errorf("collection not found")
+		}
+		rule.CollectionID = nulls.NewUUID(id)
+	}
+
+	rule.Mode = nulls.String{}
+	if req.Mode != "" {
+		if !isClipMode(req.Mode) {
+			return fmt.Errorf("invalid mode %q", req.Mode)
+		}
+		rule.Mode = nulls.NewString(req.Mode)
+	}
+	return nil
+}
+
+// clipRuleToResponse converts a clip rule model to its API representation
+func clipRuleToResponse(rule *models.ClipRule) ClipRuleResponse {
+	resp := ClipRuleResponse{
+		ID:         rule.ID.String(),
+		Domain:     rule.Domain.String,
+		URLPattern: rule.URLPattern.String,
+		Tags:       rule.TagList(),
+		Mode:       rule.Mode.String,
+		CreatedAt:  rule.CreatedAt,
+		UpdatedAt:  rule.UpdatedAt,
+	}
+	if rule.CollectionID.Valid {
+		resp.CollectionID = rule.CollectionID.UUID.String()
+	}
+	return resp
+}
+
+// applyClipRules files a clip with the user's matching rules: their tags are
+// added to the payload, and the mode replaced. It returns the collection a
+// new clip goes to, or nil.
+func applyClipRules(tx *pop.Connection, userID uuid.UUID, req *ClipPayload) (*models.Collection, error) {
+	rules, err := models.FindClipRulesByUserID(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	domain := clipDomain(req.URL)
+	var collectionID nulls.UUID
+	mode := ""
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Matches(req.URL, domain) {
+			continue
+		}
+		req.Tags = append(req.Tags, rule.TagList()...)
+		if !collectionID.Valid {
+			collectionID = rule.CollectionID
+		}
+		if mode == "" {
+			mode = rule.Mode.String
+		}
+	}
+	req.Tags = models.CleanTagNames(req.Tags)
+	if mode != "" {
+		req.Mode = mode
+	}
+
+	if !collectionID.Valid {
+		return nil, nil
+	}
+	return models.FindCollectionByIDAndUser(tx, collectionID.UUID, userID)
+}
+
+func isClipMode(mode string) bool {
+	for _, m := range clipModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
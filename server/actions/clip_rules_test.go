@@ -0,0 +1,81 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_ListClipRules_Unauthorized() {
+	res := as.JSON("/api/v1/rules").Get()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_ClipRules() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+
+	var collection CollectionResponse
+	client.Post("/api/v1/collections", CollectionPayload{Name: "Code", StoragePath: "code"}).JSON(&collection)
+
+	var dev, issues ClipRuleResponse
+	res := client.Post("/api/v1/rules", ClipRulePayload{Domain: "GitHub.com", Tags: []string{"dev"}, CollectionID: collection.ID})
+	as.Equal(http.StatusCreated, res.Code, res.Body.String())
+	res.JSON(&dev)
+	as.Equal("github.com", dev.Domain)
+	res = client.Post("/api/v1/rules", ClipRulePayload{URLPattern: "https://github.com/*/issues/*", Tags: []string{"issue", "dev"}, Mode: "bookmark"})
+	as.Equal(http.StatusCreated, res.Code, res.Body.String())
+	res.JSON(&issues)
+
+	// Rules need a match and an action
+	as.Equal(http.StatusUnprocessableEntity, client.Post("/api/v1/rules", ClipRulePayload{Tags: []string{"dev"}}).Code)
+	as.Equal(http.StatusUnprocessableEntity, client.Post("/api/v1/rules", ClipRulePayload{Domain: "example.com"}).Code)
+	as.Equal(http.StatusBadRequest, client.Post("/api/v1/rules", ClipRulePayload{Domain: "example.com", Mode: "video"}).Code)
+
+	// Matching rules file the clip
+	var clip ClipResponse
+	res = client.Post("/api/v1/clips", ClipPayload{Title: "Issue", URL: "https://gist.github.com/x/issues/1", Mode: "article", Markdown: "# Issue", Tags: []string{"read"}})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+	var detail ClipSummary
+	client.Get("/api/v1/clips/" + clip.ID).JSON(&detail)
+	as.ElementsMatch([]string{"dev", "read"}, detail.Tags)
+	as.Equal(collection.ID, detail.CollectionID)
+	as.Equal("article", detail.Mode)
+	_, err := os.Stat(filepath.Join(kit.StorageRoot, "code", clip.Path))
+	as.NoError(err)
+
+	res = client.Post("/api/v1/clips", ClipPayload{Title: "Bug", URL: "https://github.com/acme/app/issues/7", Mode: "article", Markdown: "# Bug"})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+	detail = ClipSummary{}
+	client.Get("/api/v1/clips/" + clip.ID).JSON(&detail)
+	as.ElementsMatch([]string{"dev", "issue"}, detail.Tags)
+	as.Equal("bookmark", detail.Mode)
+
+	// Other clips are left alone
+	res = client.Post("/api/v1/clips", ClipPayload{Title: "Other", URL: "https://notgithub.com/", Mode: "article", Markdown: "# Other"})
+	res.JSON(&clip)
+	detail = ClipSummary{}
+	client.Get("/api/v1/clips/" + clip.ID).JSON(&detail)
+	as.Empty(detail.Tags)
+	as.Empty(detail.CollectionID)
+
+	// Updating and deleting
+	res = client.Put("/api/v1/rules/"+dev.ID, ClipRulePayload{Domain: "github.com", Tags: []string{"code"}})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/rules/"+issues.ID).Code)
+	var list struct {
+		Rules []ClipRuleResponse `json:"rules"`
+	}
+	client.Get("/api/v1/rules").JSON(&list)
+	as.Len(list.Rules, 1)
+	as.Equal([]string{"code"}, list.Rules[0].Tags)
+	as.Empty(list.Rules[0].CollectionID)
+
+	other := kit.Client(newKitApp(kit), kit.CreateUser())
+	as.Equal(http.StatusNotFound, other.Delete("/api/v1/rules/"+dev.ID).Code)
+}
@@ -1,17 +1,22 @@
 package actions
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"server/internal/config"
+	"server/internal/extract"
+	"server/internal/sanitize"
 	"server/models"
 
 	"github.com/gobuffalo/buffalo"
@@ -29,141 +34,456 @@ type ClipPayload struct {
 	Tags     []string       `json:"tags"`
 	Notes    string         `json:"notes"`
 	Images   []ImagePayload `json:"images"`
-	Mode     string         `json:"mode"` // article, bookmark, screenshot, selection, fullpage
+	Mode     string         `json:"mode"`             // article, bookmark, screenshot, selection, fullpage, pdf
+	Dedupe   string         `json:"dedupe,omitempty"` // "reject" (default) or "merge" when the URL was just clipped
+	TOC      *bool          `json:"toc,omitempty"`    // Add a table of contents (default markdown.toc.enabled)
+
+	// Icon the page declares, tried before /favicon.ico (favicons.enabled)
+	FaviconURL string `json:"faviconUrl,omitempty"`
+
+	// Set by the server: OpenGraph and other metadata of the page, and the
+	// language of its content (ISO 639-1, declared or detected)
+	meta extract.Metadata
+	lang string
+
+	// Set by the server: the user's frontmatter template ("" for the
+	// built-in layout)
+	frontmatter string
+
+	// Set by the server: the page file name from the user's file template
+	// ("" for the title slug)
+	fileName string
+
+	// Set by the server: images settings with the user's preferences (nil
+	// for the server's)
+	images *config.ImagesConfig
+
+	// Optional capture location (e.g. from mobile share sheets)
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Place     string   `json:"place,omitempty"`
+}
+
+// imagesConfig returns the images settings the clip's images are saved with
+func (req ClipPayload) imagesConfig() config.ImagesConfig {
+	if req.images != nil {
+		return *req.images
+	}
+	return GetConfig().Images
 }
 
 // ImagePayload represents an image in the clip
 type ImagePayload struct {
 	Filename    string `json:"filename"`
-	Data        string `json:"data"` // base64
+	Data        string `json:"data"` // base64; empty for multipart uploads
 	OriginalURL string `json:"originalUrl"`
+
+	// Set for multipart uploads: the image was streamed to this file
+	file string
+	size int64
 }
 
 // ClipResponse is the response from POST /api/v1/clips
 type ClipResponse struct {
-	Success bool   `json:"success"`
-	Path    string `json:"path,omitempty"`
-	ID      string `json:"id,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success   bool         `json:"success"`
+	Path      string       `json:"path,omitempty"`
+	ID        string       `json:"id,omitempty"`
+	Version   int          `json:"version,omitempty"`   // Version number when the URL was clipped before
+	Duplicate bool         `json:"duplicate,omitempty"` // Set on 409 when the URL was just clipped
+	JobID     string       `json:"job_id,omitempty"`    // Set on 202: the job processing the clip
+	Error     *ErrorDetail `json:"error,omitempty"`
+}
+
+// clipError renders a failed clip request: a ClipResponse, whose error is
+// the one of the error envelope
+func clipError(c buffalo.Context, status int, message string) error {
+	return c.Render(status, r.JSON(ClipResponse{
+		Success: false,
+		Error:   newErrorDetail(c, status, "", message),
+	}))
 }
 
 // createClip handles clip creation
 func createClip(c buffalo.Context) error {
+	if isMultipartRequest(c.Request()) {
+		return createClipMultipart(c)
+	}
+
 	var req ClipPayload
 	if err := c.Bind(&req); err != nil {
-		return c.Render(http.StatusBadRequest, r.JSON(ClipResponse{
-			Success: false,
-			Error:   "Invalid request body",
-		}))
+		return rejectClipBody(c)
 	}
 
+	return saveClip(c, req)
+}
+
+// saveClip validates a clip payload and writes it to the user's clip directory
+func saveClip(c buffalo.Context, req ClipPayload) error {
 	cfg := GetConfig()
 	if cfg == nil {
-		return c.Render(http.StatusInternalServerError, r.JSON(ClipResponse{
-			Success: false,
-			Error:   "Configuration not loaded",
-		}))
+		return clipError(c, http.StatusInternalServerError, "Configuration not loaded")
+	}
+
+	if err := validateLocation(req); err != nil {
+		return rejectClip(c, http.StatusBadRequest, rejectInvalidPayload, err.Error())
 	}
 
+	if req.Dedupe != "" && req.Dedupe != dedupeReject && req.Dedupe != dedupeMerge {
+		return rejectClip(c, http.StatusBadRequest, rejectInvalidPayload, fmt.Sprintf("Invalid dedupe value: %s", req.Dedupe))
+	}
+	withPageMetadata(&req)
+	withLanguage(&req)
+
 	// Validate image sizes
 	var totalSize int64
 	for _, img := range req.Images {
-		data, err := base64.StdEncoding.DecodeString(img.Data)
+		size, err := img.dataSize()
 		if err != nil {
-			return c.Render(http.StatusBadRequest, r.JSON(ClipResponse{
-				Success: false,
-				Error:   fmt.Sprintf("Invalid image data for: %s", img.Filename),
-			}))
+			return rejectClip(c, http.StatusBadRequest, rejectInvalidPayload, fmt.Sprintf("Invalid image data for: %s", img.Filename))
 		}
-		size := int64(len(data))
 		if size > cfg.Images.MaxSizeBytes {
-			return c.Render(http.StatusRequestEntityTooLarge, r.JSON(ClipResponse{
-				Success: false,
-				Error:   fmt.Sprintf("Image %s exceeds max size of %d bytes", img.Filename, cfg.Images.MaxSizeBytes),
-			}))
+			return rejectClip(c, http.StatusRequestEntityTooLarge, rejectOversizeImage,
+				fmt.Sprintf("Image %s exceeds max size of %d bytes", img.Filename, cfg.Images.MaxSizeBytes))
 		}
 		totalSize += size
 	}
 	if totalSize > cfg.Images.MaxTotalBytes {
-		return c.Render(http.StatusRequestEntityTooLarge, r.JSON(ClipResponse{
-			Success: false,
-			Error:   fmt.Sprintf("Total image size %d exceeds limit of %d bytes", totalSize, cfg.Images.MaxTotalBytes),
-		}))
+		return rejectClip(c, http.StatusRequestEntityTooLarge, rejectQuota,
+			fmt.Sprintf("Total image size %d exceeds limit of %d bytes", totalSize, cfg.Images.MaxTotalBytes))
+	}
+	for _, img := range req.Images {
+		if img.remote() {
+			// Downloaded and checked once the clip is accepted
+			if !cfg.Images.FetchRemote {
+				return rejectClip(c, http.StatusBadRequest, rejectInvalidPayload, fmt.Sprintf("No data for image: %s", img.Filename))
+			}
+			continue
+		}
+		if err := checkImageFormat(img, cfg.Images.AllowedFormats); err != nil {
+			return rejectClip(c, http.StatusUnsupportedMediaType, rejectImageFormat, err.Error())
+		}
 	}
 
 	// Get user from context (set by authMiddleware)
 	userID, ok := c.Value("user_id").(string)
 	if !ok || userID == "" {
-		return c.Render(http.StatusUnauthorized, r.JSON(ClipResponse{
-			Success: false,
-			Error:   "User not authenticated",
-		}))
+		return rejectClip(c, http.StatusUnauthorized, rejectAuth, "User not authenticated")
 	}
 
 	tx := c.Value("tx").(*pop.Connection)
 	user := &models.User{}
 	if err := tx.Find(user, userID); err != nil {
-		return c.Render(http.StatusUnauthorized, r.JSON(ClipResponse{
-			Success: false,
-			Error:   "User not found",
+		return rejectClip(c, http.StatusUnauthorized, rejectAuth, "User not found")
+	}
+
+	key, err := idempotencyKey(c)
+	if err != nil {
+		return rejectClip(c, http.StatusBadRequest, rejectInvalidPayload, err.Error())
+	}
+	if key.Valid {
+		if saved, err := models.FindClipByIdempotencyKey(tx, key.String, user.ID); err == nil {
+			return replayClipResponse(c, tx, clipRoot(cfg, user, saved), saved)
+		}
+	}
+
+	req.frontmatter = userFrontmatterTemplate(user)
+
+	// The user's settings give the default mode, tags and image preferences
+	settings, err := models.FindUserSettings(tx, user.ID)
+	if err != nil {
+		c.Logger().Errorf("Failed to load user settings: %v", err)
+		return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+	}
+	applyUserSettings(&req, settings)
+
+	// The user's rules add tags and may set the mode and collection
+	collection, err := applyClipRules(tx, user.ID, &req)
+	if err != nil {
+		c.Logger().Errorf("Failed to apply clip rules: %v", err)
+		return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+	}
+
+	// Tag aliases and implications apply, then the taxonomy, if any, rule
+	// tags included
+	req.Tags, err = resolveTags(tx, user.ID, req.Tags)
+	var unknown *UnknownTagsError
+	if errors.As(err, &unknown) {
+		return rejectClip(c, http.StatusUnprocessableEntity, rejectUnknownTags, err.Error())
+	}
+	if err != nil {
+		c.Logger().Errorf("Failed to resolve clip tags: %v", err)
+		return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+	}
+
+	// Re-clipping a URL records a new version of the existing clip, unless it
+	// was clipped moments ago (typically a double click in the extension)
+	normalizedURL := normalizeURL(req.URL)
+	existing, err := models.FindClipByURLAndUser(tx, req.URL, normalizedURL, user.ID)
+	if err != nil {
+		existing = nil
+	}
+	if existing != nil && isRecentDuplicate(cfg, existing) && req.Dedupe != dedupeMerge {
+		return c.Render(http.StatusConflict, r.JSON(ClipResponse{
+			Success:   false,
+			ID:        existing.ID.String(),
+			Path:      existing.Path,
+			Duplicate: true,
+			Error:     newErrorDetail(c, http.StatusConflict, "duplicate", "This URL was already clipped moments ago"),
 		}))
 	}
 
-	// Determine clip directory (user-specific or default)
-	clipDir := cfg.Storage.BasePath
-	if user.ClipDirectory.Valid && user.ClipDirectory.String != "" {
-		clipDir = user.ClipDirectory.String
+	if isDryRun(c) {
+		changes, err := saveChanges(tx, existing, req)
+		if err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+		return renderDryRun(c, changes)
+	}
+
+	// Images sent by URL are downloaded by the server, next to the clip
+	// folders so saving them is a rename
+	if cfg.Images.FetchRemote {
+		spool, err := newStagingDir(userClipDir(cfg, user))
+		if err != nil {
+			return clipError(c, http.StatusInternalServerError, "Failed to create clip directory")
+		}
+		defer os.RemoveAll(spool) // Saved images were moved out already
+		fetchRemoteImages(c.Request().Context(), cfg, &req, spool)
+	}
+
+	// Clients preferring an asynchronous response get a new clip saved right
+	// away, with its text extracted by a job. Only the text is deferred: the
+	// remote images above and the image processing of writeClipFiles are done
+	// before the response, as documented for the Prefer header.
+	async := existing == nil && respondAsync(c)
+
+	// Extract text from screenshots and PDFs so they become searchable
+	var text clipText
+	if !async {
+		text = extractClipText(c, cfg, req)
+	}
+
+	// Name the files after the rules, which may change the mode
+	naming := newClipNameData(req, time.Now())
+	req.fileName = naming.fileName(userFileTemplate(user))
+
+	if existing != nil {
+		if key.Valid {
+			existing.IdempotencyKey = key
+		}
+		// Notes keep no versions: re-clips replace them
+		if isRecentDuplicate(cfg, existing) || existing.IsNote() {
+			return mergeIntoClip(c, tx, clipRoot(cfg, user, existing), existing, req, text)
+		}
+		return recordClipVersion(c, tx, clipRoot(cfg, user, existing), existing, req, text)
+	}
+
+	// Determine clip directory (user-specific or default, or the storage of
+	// the collection a rule files the clip in)
+	clipDir := userClipDir(cfg, user)
+	if collection != nil {
+		clipDir = storageRoot(cfg, user, collection.StoragePath.String)
 	}
 
-	// Create folder structure: YYYYMMDD_HHMMSS_site-slug
-	timestamp := time.Now().Format("20060102_150405")
-	siteSlug := slugify(extractDomain(req.URL))
-	folderName := fmt.Sprintf("%s_%s", timestamp, siteSlug)
-	folderPath := filepath.Join(clipDir, "web-clips", folderName)
+	var clipID uuid.UUID
+	var relativePath, relPath string
+	if cfg.Storage.Layout == layoutObsidian {
+		// A note in the vault instead of a clip folder
+		clipID, relativePath, err = writeClipNote(clipDir, req, text.Recognized)
+		if err != nil {
+			return clipError(c, http.StatusInternalServerError, err.Error())
+		}
+		notePath := filepath.Join(clipDir, relativePath)
+		// The rows are committed after the response; remove the files if that fails
+		onRollback(c, func() { removeClipNote(notePath, clipID) })
+		relPath = relativePath
+	} else {
+		// Write the capture to a staging folder and only move it into place once
+		// complete, so a failure never leaves a partial or orphan clip folder
+		staging, err := newStagingDir(clipDir)
+		if err != nil {
+			return clipError(c, http.StatusInternalServerError, "Failed to create clip directory")
+		}
+
+		fileName, err := writeClipFiles(staging, req, text.Recognized)
+		if err != nil {
+			os.RemoveAll(staging)
+			return clipError(c, http.StatusInternalServerError, err.Error())
+		}
+
+		// Folder structure: YYYYMMDD_HHMMSS_site-slug_<clip ID prefix>, or the
+		// user's folder template
+		id, folderName, err := publishNewClipFolder(staging, clipDir, func(id uuid.UUID) string {
+			return naming.folderName(userFolderTemplate(user), id)
+		})
+		if err != nil {
+			os.RemoveAll(staging)
+			c.Logger().Errorf("Failed to move clip folder into place: %v", err)
+			return clipError(c, http.StatusInternalServerError, "Failed to create clip directory")
+		}
+		folderPath := filepath.Join(clipDir, "web-clips", folderName)
+		// The rows are committed after the response; remove the files if that fails
+		onRollback(c, func() { os.RemoveAll(folderPath) })
+
+		clipID = id
+		relPath = filepath.Join("web-clips", folderName, fileName)
+
+		// Store relative path from web-clips directory
+		relativePath = filepath.Join("web-clips", folderName)
+	}
 
-	// Create directory (and parent directories if needed)
-	if err := os.MkdirAll(folderPath, 0755); err != nil {
-		return c.Render(http.StatusInternalServerError, r.JSON(ClipResponse{
-			Success: false,
-			Error:   "Failed to create clip directory",
+	clip := &models.Clip{
+		ID:             clipID,
+		UserID:         user.ID,
+		Title:          req.Title,
+		URL:            req.URL,
+		NormalizedURL:  normalizedURL,
+		Domain:         clipDomain(req.URL),
+		Path:           relativePath,
+		Mode:           req.Mode,
+		Notes:          nulls.NewString(req.Notes),
+		IdempotencyKey: key,
+	}
+	text.apply(clip)
+	applyLocation(clip, req)
+	applyMetadata(clip, req)
+	applyLanguage(clip, req)
+	if collection != nil {
+		clip.CollectionID = nulls.NewUUID(collection.ID)
+		clip.StoragePath = collection.StoragePath
+	}
+
+	if err := createClipRecords(tx, clip, req.Tags); err != nil {
+		c.Logger().Errorf("Failed to save clip metadata: %v", err)
+		return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+	}
+	refreshClipSidecarOnCommit(c, tx, clip)
+	if err := clipSaved(c, tx, clip, models.EventClipCreated, auditClipCreate); err != nil {
+		c.Logger().Errorf("Failed to record clip: %v", err)
+		return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+	}
+	if cfg.Favicons.Enabled && clip.Domain != "" {
+		args := fetchFaviconArgs{ClipID: clip.ID, URL: req.FaviconURL}
+		if _, err := enqueueJob(c, tx, user.ID, models.JobFetchFavicon, args); err != nil {
+			c.Logger().Errorf("Failed to queue favicon fetch: %v", err)
+			return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+		}
+	}
+
+	if async {
+		job, err := enqueueJob(c, tx, user.ID, models.JobProcessClip, processClipArgs{ClipID: clip.ID})
+		if err != nil {
+			c.Logger().Errorf("Failed to queue clip processing: %v", err)
+			return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+		}
+		return c.Render(http.StatusAccepted, r.JSON(ClipResponse{
+			Success: true,
+			Path:    relPath,
+			ID:      clip.ID.String(),
+			Version: 1,
+			JobID:   job.ID.String(),
 		}))
 	}
 
+	// Return relative path and clip ID
+	return c.Render(http.StatusOK, r.JSON(ClipResponse{
+		Success: true,
+		Path:    relPath,
+		ID:      clip.ID.String(),
+		Version: 1,
+	}))
+}
+
+// clipSaved records the event of a created or re-clipped clip and queues
+// its upload to the user's cloud folders; once committed, the change is
+// audited, replicated and synced to git
+func clipSaved(c buffalo.Context, tx *pop.Connection, clip *models.Clip, event, action string) error {
+	if err := recordEvent(c, tx, clip.UserID, event, clipToSummary(clip)); err != nil {
+		return err
+	}
+	if err := queueCloudUpload(c, tx, clip); err != nil {
+		return err
+	}
+	auditOnCommit(c, action, "clip_id", clip.ID.String(), "mode", clip.Mode)
+	replicateAfterCommit(c)
+	syncGitAfterCommit(c)
+	return nil
+}
+
+// createClipRecords inserts a new clip with its tags and first version
+func createClipRecords(tx *pop.Connection, clip *models.Clip, tags []string) error {
+	if err := tx.Create(clip); err != nil {
+		return err
+	}
+	if err := models.SetClipTags(tx, clip, tags); err != nil {
+		return err
+	}
+	return tx.Create(newClipVersion(clip, 1))
+}
+
+// writeClipFiles saves a capture's media and page content into folderPath,
+// flushed to disk, and returns the name of the main file. Errors are suitable
+// for the API response.
+func writeClipFiles(folderPath string, req ClipPayload, recognizedText string) (string, error) {
 	// Save images to media/ subfolder
 	if len(req.Images) > 0 {
 		mediaDir := filepath.Join(folderPath, "media")
-		if err := os.MkdirAll(mediaDir, 0755); err != nil {
-			return c.Render(http.StatusInternalServerError, r.JSON(ClipResponse{
-				Success: false,
-				Error:   "Failed to create media directory",
-			}))
+		if err := clipFS.MkdirAll(mediaDir, 0755); err != nil {
+			return "", fmt.Errorf("Failed to create media directory")
 		}
 
+		renamed := map[string]string{}
 		for _, img := range req.Images {
-			data, _ := base64.StdEncoding.DecodeString(img.Data)
-			imgPath := filepath.Join(mediaDir, sanitizeFilename(img.Filename))
-			if err := os.WriteFile(imgPath, data, 0644); err != nil {
-				return c.Render(http.StatusInternalServerError, r.JSON(ClipResponse{
-					Success: false,
-					Error:   fmt.Sprintf("Failed to save image: %s", img.Filename),
-				}))
+			name := sanitizeFilename(img.Filename)
+			imgPath := filepath.Join(mediaDir, name)
+			os.Remove(imgPath) // Never write through a link to a shared media blob
+			if err := img.save(imgPath); err != nil {
+				return "", fmt.Errorf("Failed to save image: %s", img.Filename)
+			}
+			if err := downscaleImage(imgPath, req.imagesConfig()); err != nil {
+				return "", fmt.Errorf("Failed to save image: %s", img.Filename)
+			}
+			stored, err := convertToWebP(imgPath, req.imagesConfig())
+			if err != nil {
+				return "", fmt.Errorf("Failed to save image: %s", img.Filename)
+			}
+			if stored != name {
+				renamed[name] = stored
+			}
+			if GetConfig().Storage.DedupeMedia {
+				if _, err := linkMedia(mediaStore(folderPath), filepath.Join(mediaDir, stored), false, nil); err != nil {
+					log.Printf("Failed to link %s into the media store: %v", stored, err)
+				}
 			}
 		}
+		if err := syncDir(mediaDir); err != nil {
+			return "", fmt.Errorf("Failed to save images")
+		}
+		req.Markdown = renameMediaLinks(req.Markdown, renamed)
+		req.HTML = renameMediaLinks(req.HTML, renamed)
 	}
 
 	// Generate file content based on mode
-	pageSlug := slugify(req.Title)
+	pageSlug := req.fileName
+	if pageSlug == "" {
+		pageSlug = slugify(req.Title)
+	}
 	if pageSlug == "" {
 		pageSlug = "page"
 	}
 
-	var filePath string
-	var relPath string
-
 	if req.Mode == "fullpage" && req.HTML != "" {
 		// For fullpage mode, save HTML file
-		filePath = filepath.Join(folderPath, pageSlug+".html")
-		relPath = filepath.Join("web-clips", folderName, pageSlug+".html")
+		filePath := filepath.Join(folderPath, pageSlug+".html")
+
+		// Share links and readers render the page: no scripts or trackers
+		if !GetConfig().Clips.RawHTML {
+			clean, err := sanitize.HTML(req.HTML)
+			if err != nil {
+				return "", fmt.Errorf("Failed to save HTML file")
+			}
+			req.HTML = clean
+		}
 
 		// Add a comment header with metadata
 		htmlContent := fmt.Sprintf("<!-- \n  Clipped: %s\n  URL: %s\n  Mode: fullpage\n-->\n%s",
@@ -171,11 +491,8 @@ func createClip(c buffalo.Context) error {
 			req.URL,
 			req.HTML)
 
-		if err := os.WriteFile(filePath, []byte(htmlContent), 0644); err != nil {
-			return c.Render(http.StatusInternalServerError, r.JSON(ClipResponse{
-				Success: false,
-				Error:   "Failed to save HTML file",
-			}))
+		if err := writeFileSync(filePath, []byte(htmlContent), 0644); err != nil {
+			return "", fmt.Errorf("Failed to save HTML file")
 		}
 
 		// Also save a companion markdown file with metadata
@@ -183,69 +500,88 @@ func createClip(c buffalo.Context) error {
 		mdContent := frontmatter + fmt.Sprintf("\n# %s\n\nFull page capture saved as [%s.html](./%s.html)\n\nOriginal URL: %s\n",
 			req.Title, pageSlug, pageSlug, req.URL)
 		mdPath := filepath.Join(folderPath, pageSlug+".md")
-		os.WriteFile(mdPath, []byte(mdContent), 0644) // Best effort
-	} else {
-		// For other modes, save Markdown file
-		frontmatter := generateFrontmatter(req)
-		content := frontmatter + "\n" + req.Markdown
-		filePath = filepath.Join(folderPath, pageSlug+".md")
-		relPath = filepath.Join("web-clips", folderName, pageSlug+".md")
-
-		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
-			return c.Render(http.StatusInternalServerError, r.JSON(ClipResponse{
-				Success: false,
-				Error:   "Failed to save markdown file",
-			}))
+		writeFileSync(mdPath, []byte(mdContent), 0644) // Best effort
+		if err := syncDir(folderPath); err != nil {
+			return "", fmt.Errorf("Failed to save HTML file")
 		}
+		return pageSlug + ".html", nil
+	}
+
+	// For other modes, save Markdown file
+	frontmatter := generateFrontmatter(req)
+	content := frontmatter + "\n" + withTOC(req.Markdown, req.TOC) + recognizedTextSection(recognizedText)
+	filePath := filepath.Join(folderPath, pageSlug+".md")
+
+	if err := writeFileSync(filePath, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("Failed to save markdown file")
 	}
+	if err := syncDir(folderPath); err != nil {
+		return "", fmt.Errorf("Failed to save markdown file")
+	}
+	return pageSlug + ".md", nil
+}
+
+// clipText holds text extracted from a capture's attachments
+type clipText struct {
+	Recognized string // OCR output from screenshots
+	PDF        string // Text layer of attached PDFs
+}
 
-	// Save clip metadata to database
-	// tx already declared earlier in function
-	userUUID, err := uuid.FromString(userID)
+// extractClipText runs OCR on screenshots and text extraction on attached PDFs.
+// Both are best effort: failures are logged and whatever was extracted is kept.
+func extractClipText(c buffalo.Context, cfg *config.Config, req ClipPayload) clipText {
+	text, err := extractText(c.Request().Context(), cfg, req.Mode, req.Images)
 	if err != nil {
-		c.Logger().Errorf("Invalid user ID: %v", err)
-		return c.Render(http.StatusOK, r.JSON(ClipResponse{
-			Success: true,
-			Path:    relPath,
-		}))
+		c.Logger().Warnf("%v", err)
 	}
+	return text
+}
 
-	// Serialize tags to JSON
-	var tagsJSON nulls.String
-	if len(req.Tags) > 0 {
-		tagsBytes, _ := json.Marshal(req.Tags)
-		tagsJSON = nulls.NewString(string(tagsBytes))
+// extractText extracts the text of a capture's attachments. The error joins
+// the OCR and PDF failures; the text holds whatever was extracted anyway.
+func extractText(ctx context.Context, cfg *config.Config, mode string, attachments []ImagePayload) (clipText, error) {
+	var text clipText
+	var ocrErr error
+	if mode == "screenshot" {
+		text.Recognized, ocrErr = recognizeImageText(ctx, cfg, attachments)
 	}
 
-	// Store relative path from web-clips directory
-	relativePath := filepath.Join("web-clips", folderName)
+	var pdfErr error
+	text.PDF, pdfErr = extractPDFText(ctx, cfg, attachments)
 
-	clip := &models.Clip{
-		ID:     uuid.Must(uuid.NewV4()),
-		UserID: userUUID,
-		Title:  req.Title,
-		URL:    req.URL,
-		Path:   relativePath,
-		Mode:   req.Mode,
-		Tags:   tagsJSON,
-		Notes:  nulls.NewString(req.Notes),
-	}
+	return text, errors.Join(ocrErr, pdfErr)
+}
 
-	if err := tx.Create(clip); err != nil {
-		// Log error but don't fail - file was already saved
-		c.Logger().Errorf("Failed to save clip metadata: %v", err)
+// apply stores the extracted text on the clip for search and previews
+func (t clipText) apply(clip *models.Clip) {
+	var parts []string
+	for _, part := range []string{t.Recognized, t.PDF} {
+		if part != "" {
+			parts = append(parts, part)
+		}
 	}
 
-	// Return relative path and clip ID
-	return c.Render(http.StatusOK, r.JSON(ClipResponse{
-		Success: true,
-		Path:    relPath,
-		ID:      clip.ID.String(),
-	}))
+	clip.ContentText = nulls.String{}
+	clip.Excerpt = nulls.String{}
+	if len(parts) > 0 {
+		clip.ContentText = nulls.NewString(strings.Join(parts, "\n\n"))
+	}
+	if t.PDF != "" {
+		clip.Excerpt = nulls.NewString(makeExcerpt(t.PDF))
+	}
 }
 
-// generateFrontmatter creates YAML frontmatter for the clip
+// generateFrontmatter creates YAML frontmatter for the clip, with the
+// user's template if any
 func generateFrontmatter(req ClipPayload) string {
+	if req.frontmatter != "" {
+		frontmatter, err := renderFrontmatterTemplate(req.frontmatter, req)
+		if err == nil {
+			return frontmatter
+		}
+		log.Printf("Frontmatter template failed, using the default layout: %v", err)
+	}
+
 	var sb strings.Builder
 	sb.WriteString("---\n")
 	sb.WriteString(fmt.Sprintf("title: %q\n", req.Title))
@@ -277,6 +613,20 @@ func generateFrontmatter(req ClipPayload) string {
 		sb.WriteString("notes: \"\"\n")
 	}
 
+	// Location
+	if req.Latitude != nil && req.Longitude != nil {
+		sb.WriteString(fmt.Sprintf("location: [%s, %s]\n",
+			strconv.FormatFloat(*req.Latitude, 'f', -1, 64),
+			strconv.FormatFloat(*req.Longitude, 'f', -1, 64)))
+	}
+	if req.Place != "" {
+		sb.WriteString(fmt.Sprintf("place: %q\n", req.Place))
+	}
+	if req.lang != "" {
+		sb.WriteString(fmt.Sprintf("lang: %s\n", req.lang))
+	}
+	writeMetadataFrontmatter(&sb, req.meta)
+
 	sb.WriteString("---\n")
 	return sb.String()
 }
@@ -307,6 +657,52 @@ func slugify(s string) string {
 	return s
 }
 
+// userClipDir returns the storage root for a user (user-specific or default)
+func userClipDir(cfg *config.Config, user *models.User) string {
+	if user.ClipDirectory.Valid && user.ClipDirectory.String != "" {
+		return user.ClipDirectory.String
+	}
+	return cfg.Storage.BasePath
+}
+
+// readClipMarkdown returns the content of the first markdown file in a clip
+// folder, or of a note with its attachment links made media/ links
+func readClipMarkdown(folderPath string) (string, error) {
+	if isNotePath(folderPath) {
+		data, err := readClipFile(folderPath)
+		if err != nil {
+			return "", err
+		}
+		return noteMarkdown(string(data)), nil
+	}
+	entries, err := os.ReadDir(folderPath)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") {
+			data, err := readClipFile(filepath.Join(folderPath, entry.Name()))
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+// stripFrontmatter removes a leading YAML frontmatter block from markdown
+func stripFrontmatter(content string) string {
+	if !strings.HasPrefix(content, "---\n") {
+		return content
+	}
+	end := strings.Index(content[4:], "\n---\n")
+	if end == -1 {
+		return content
+	}
+	return strings.TrimLeft(content[4+end+5:], "\n")
+}
+
 // sanitizeFilename removes unsafe characters from filenames
 func sanitizeFilename(name string) string {
 	// Remove path traversal attempts
@@ -319,21 +715,67 @@ func sanitizeFilename(name string) string {
 // ListClipsResponse represents the paginated clips response
 type ListClipsResponse struct {
 	Clips      []ClipSummary `json:"clips"`
-	Page       int           `json:"page"`
+	Page       int           `json:"page,omitempty"` // Omitted when paginating with after=
 	PerPage    int           `json:"per_page"`
 	Total      int           `json:"total"`
 	TotalPages int           `json:"total_pages"`
+	NextCursor string        `json:"next_cursor,omitempty"` // Pass as after= for the next page
 }
 
 // ClipSummary represents clip metadata without content
 type ClipSummary struct {
-	ID        string    `json:"id"`
-	Title     string    `json:"title"`
-	URL       string    `json:"url"`
-	Mode      string    `json:"mode"`
-	Tags      []string  `json:"tags"`
-	Notes     string    `json:"notes,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
+	ID           string            `json:"id"`
+	Title        string            `json:"title"`
+	URL          string            `json:"url"`
+	Domain       string            `json:"domain"`
+	Mode         string            `json:"mode"`
+	Tags         []string          `json:"tags"`
+	Notes        string            `json:"notes,omitempty"`
+	Excerpt      string            `json:"excerpt,omitempty"`
+	Location     *ClipLocation     `json:"location,omitempty"`
+	CollectionID string            `json:"collection_id,omitempty"`
+	FaviconURL   string            `json:"favicon_url,omitempty"` // Icon of the site, once fetched (favicons.enabled)
+	Metadata     *extract.Metadata `json:"metadata,omitempty"`    // What the page declares: OpenGraph, author, publication date
+	Language     string            `json:"language,omitempty"`    // ISO 639-1, declared by the page or detected
+	ArchivedAt   *time.Time        `json:"archived_at,omitempty"`
+	ReadAt       *time.Time        `json:"read_at,omitempty"`
+	DisabledAt   *time.Time        `json:"disabled_at,omitempty"` // Taken down from public routes by an admin
+	Score        int               `json:"score,omitempty"`       // Relevance to a search query with words
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+// clipToSummary converts a clip model to its API summary
+func clipToSummary(clip *models.Clip) ClipSummary {
+	summary := ClipSummary{
+		ID:        clip.ID.String(),
+		Title:     clip.Title,
+		URL:       clip.URL,
+		Domain:    clip.Domain,
+		Mode:      clip.Mode,
+		Tags:      clip.Tags,
+		Notes:     clip.Notes.String,
+		Excerpt:   clip.Excerpt.String,
+		Location:  clipLocation(clip),
+		Metadata:  clipMetadata(clip),
+		Language:  clip.Language.String,
+		CreatedAt: clip.CreatedAt,
+	}
+	if clip.CollectionID.Valid {
+		summary.CollectionID = clip.CollectionID.UUID.String()
+	}
+	if clip.Favicon.Valid {
+		summary.FaviconURL = "/api/v1/clips/" + summary.ID + "/favicon"
+	}
+	if clip.ArchivedAt.Valid {
+		summary.ArchivedAt = &clip.ArchivedAt.Time
+	}
+	if clip.ReadAt.Valid {
+		summary.ReadAt = &clip.ReadAt.Time
+	}
+	if clip.DisabledAt.Valid {
+		summary.DisabledAt = &clip.DisabledAt.Time
+	}
+	return summary
 }
 
 // listClips returns paginated list of user's clips
@@ -345,7 +787,22 @@ func listClips(c buffalo.Context) error {
 		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
 	}
 
-	// Parse pagination params
+	page, perPage := paginationParams(c)
+
+	// Optional filters
+	filter := clipFilterFromParams(c)
+
+	// Build query
+	q, err := filter.apply(tx.Where("user_id = ? AND deleted_at IS NULL", userID), userID)
+	if err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+
+	return renderClipPage(c, tx, q, filter.score(userID), page, perPage)
+}
+
+// paginationParams parses the page and per_page query parameters
+func paginationParams(c buffalo.Context) (int, int) {
 	page := 1
 	if pageStr := c.Param("page"); pageStr != "" {
 		if p, err := fmt.Sscanf(pageStr, "%d", &page); err == nil && p == 1 && page >= 1 {
@@ -364,49 +821,75 @@ func listClips(c buffalo.Context) error {
 		}
 	}
 
-	// Optional filters
-	mode := c.Param("mode")
-	tag := c.Param("tag")
-
-	// Build query
-	q := tx.Where("user_id = ?", userID)
-	if mode != "" {
-		q = q.Where("mode = ?", mode)
-	}
-	if tag != "" {
-		// SQLite JSON contains check
-		q = q.Where("tags LIKE ?", "%\""+tag+"\"%")
-	}
-	q = q.Order("created_at DESC")
+	return page, perPage
+}
 
-	// Get total count
+// renderClipPage runs a clips query for one page and renders it as a ListClipsResponse.
+// With an after= cursor the page starts just past that clip instead of at
+// page/per_page, so results stay consistent while new clips arrive. Clips
+// get their score when there is one, and are ordered by it if asked.
+func renderClipPage(c buffalo.Context, tx *pop.Connection, q *pop.Query, score *clipScore, page, perPage int) error {
+	// Get total count (of the whole listing, not just what follows the cursor)
 	count, err := q.Count(&models.Clip{})
 	if err != nil {
 		return c.Error(http.StatusInternalServerError, err)
 	}
 
+	// id breaks ties between clips created in the same instant
+	if score != nil && score.rank {
+		if c.Param("after") != "" {
+			return c.Error(http.StatusBadRequest, fmt.Errorf("after= cursors only page through listings sorted by date"))
+		}
+		q = q.Order(score.expr+" DESC, created_at DESC, id DESC", score.args...)
+	} else {
+		q = q.Order("created_at DESC, id DESC")
+	}
+
 	// Fetch clips
 	clips := models.Clips{}
-	if err := q.Paginate(page, perPage).All(&clips); err != nil {
+	hasMore := false
+	if after := c.Param("after"); after != "" {
+		cursor, err := decodeClipCursor(after)
+		if err != nil {
+			return c.Error(http.StatusBadRequest, err)
+		}
+		// One extra row tells whether another page follows
+		if err := cursor.where(q).Limit(perPage + 1).All(&clips); err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+		if len(clips) > perPage {
+			clips = clips[:perPage]
+			hasMore = true
+		}
+		page = 0
+	} else {
+		if err := q.Paginate(page, perPage).All(&clips); err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+		hasMore = page*perPage < count
+	}
+
+	var nextCursor string
+	if hasMore && len(clips) > 0 {
+		nextCursor = encodeClipCursor(&clips[len(clips)-1])
+	}
+
+	if err := clips.LoadTags(tx); err != nil {
 		return c.Error(http.StatusInternalServerError, err)
 	}
 
+	scores := map[uuid.UUID]int{}
+	if score != nil {
+		if scores, err = clipScores(tx, clips, score); err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+	}
+
 	// Convert to response format
 	summaries := make([]ClipSummary, len(clips))
-	for i, clip := range clips {
-		var tags []string
-		if clip.Tags.Valid {
-			json.Unmarshal([]byte(clip.Tags.String), &tags)
-		}
-		summaries[i] = ClipSummary{
-			ID:        clip.ID.String(),
-			Title:     clip.Title,
-			URL:       clip.URL,
-			Mode:      clip.Mode,
-			Tags:      tags,
-			Notes:     clip.Notes.String,
-			CreatedAt: clip.CreatedAt,
-		}
+	for i := range clips {
+		summaries[i] = clipToSummary(&clips[i])
+		summaries[i].Score = scores[clips[i].ID]
 	}
 
 	totalPages := (count + perPage - 1) / perPage
@@ -417,6 +900,7 @@ func listClips(c buffalo.Context) error {
 		PerPage:    perPage,
 		Total:      count,
 		TotalPages: totalPages,
+		NextCursor: nextCursor,
 	}))
 }
 
@@ -424,7 +908,7 @@ func listClips(c buffalo.Context) error {
 type ClipDetail struct {
 	ClipSummary
 	Path    string      `json:"path"`
-	Content string      `json:"content,omitempty"`   // Markdown content
+	Content string      `json:"content,omitempty"` // Markdown content
 	Images  []ClipImage `json:"images,omitempty"`
 }
 
@@ -462,69 +946,43 @@ func getClip(c buffalo.Context) error {
 		return c.Error(http.StatusInternalServerError, err)
 	}
 
-	cfg := GetConfig()
-	clipDir := cfg.Storage.BasePath
-	if user.ClipDirectory.Valid {
-		clipDir = user.ClipDirectory.String
-	}
-
 	// Read markdown content
-	fullPath := filepath.Join(clipDir, clip.Path)
+	fullPath := filepath.Join(clipRoot(GetConfig(), user, clip), clip.Path)
 	var content string
 	var images []ClipImage
 
 	// Find and read markdown file
-	entries, _ := os.ReadDir(fullPath)
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") {
-			mdPath := filepath.Join(fullPath, entry.Name())
-			data, err := os.ReadFile(mdPath)
-			if err == nil {
-				content = string(data)
+	content, _ = readClipMarkdown(fullPath)
+
+	// List images in media folder (or a note's attachments)
+	mediaPath, _ := clipMediaDir(clip, clip.Path)
+	if names, err := clipMediaFiles(clip, fullPath); err == nil {
+		for _, name := range names {
+			// Detect MIME type
+			mimeType := mime.TypeByExtension(filepath.Ext(name))
+			if mimeType == "" {
+				mimeType = "application/octet-stream"
 			}
-			break
-		}
-	}
 
-	// List images in media folder
-	mediaPath := filepath.Join(fullPath, "media")
-	if mediaEntries, err := os.ReadDir(mediaPath); err == nil {
-		for _, entry := range mediaEntries {
-			if !entry.IsDir() {
-				// Detect MIME type
-				mimeType := mime.TypeByExtension(filepath.Ext(entry.Name()))
-				if mimeType == "" {
-					mimeType = "application/octet-stream"
-				}
-
-				images = append(images, ClipImage{
-					Filename: entry.Name(),
-					Path:     filepath.Join(clip.Path, "media", entry.Name()),
-					MimeType: mimeType,
-				})
-			}
+			images = append(images, ClipImage{
+				Filename: name,
+				Path:     filepath.Join(mediaPath, name),
+				MimeType: mimeType,
+			})
 		}
 	}
 
-	// Parse tags
-	var tags []string
-	if clip.Tags.Valid {
-		json.Unmarshal([]byte(clip.Tags.String), &tags)
+	if err := clip.LoadTags(tx); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
 	}
 
+	recordClipView(c, tx, clip)
+
 	return c.Render(http.StatusOK, r.JSON(ClipDetail{
-		ClipSummary: ClipSummary{
-			ID:        clip.ID.String(),
-			Title:     clip.Title,
-			URL:       clip.URL,
-			Mode:      clip.Mode,
-			Tags:      tags,
-			Notes:     clip.Notes.String,
-			CreatedAt: clip.CreatedAt,
-		},
-		Path:    clip.Path,
-		Content: content,
-		Images:  images,
+		ClipSummary: clipToSummary(clip),
+		Path:        clip.Path,
+		Content:     content,
+		Images:      images,
 	}))
 }
 
@@ -567,17 +1025,11 @@ func getClipMedia(c buffalo.Context) error {
 		return c.Error(http.StatusInternalServerError, err)
 	}
 
-	cfg := GetConfig()
-	clipDir := cfg.Storage.BasePath
-	if user.ClipDirectory.Valid {
-		clipDir = user.ClipDirectory.String
-	}
-
 	// Construct full path to media file
-	fullPath := filepath.Join(clipDir, clip.Path, "media", cleanFilename)
+	fullPath := clipMediaFile(clip, filepath.Join(clipRoot(GetConfig(), user, clip), clip.Path), cleanFilename)
 
 	// Verify file exists
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+	if _, err := os.Stat(fullPath); fullPath == "" || os.IsNotExist(err) {
 		return c.Error(http.StatusNotFound, fmt.Errorf("media file not found"))
 	}
 
@@ -592,11 +1044,10 @@ func getClipMedia(c buffalo.Context) error {
 	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", cleanFilename))
 
 	// Serve the file
-	http.ServeFile(c.Response(), c.Request(), fullPath)
-	return nil
+	return serveClipFile(c, fullPath, cleanFilename)
 }
 
-// deleteClip deletes a clip from database and optionally from filesystem
+// deleteClip moves a clip to the trash (files are moved to the .trash folder)
 func deleteClip(c buffalo.Context) error {
 	tx := c.Value("tx").(*pop.Connection)
 	userIDStr := c.Value("user_id").(string)
@@ -617,9 +1068,13 @@ func deleteClip(c buffalo.Context) error {
 		return c.Error(http.StatusNotFound, fmt.Errorf("clip not found"))
 	}
 
-	// Get delete_files param (default: true)
+	// Get delete_files param (default: true). When false, files stay in place.
 	deleteFiles := c.Param("delete_files") != "false"
 
+	if isDryRun(c) {
+		return renderDryRun(c, trashChanges(clip, deleteFiles))
+	}
+
 	if deleteFiles {
 		// Get user's clip directory
 		user := &models.User{}
@@ -627,22 +1082,17 @@ func deleteClip(c buffalo.Context) error {
 			return c.Error(http.StatusInternalServerError, err)
 		}
 
-		cfg := GetConfig()
-		clipDir := cfg.Storage.BasePath
-		if user.ClipDirectory.Valid {
-			clipDir = user.ClipDirectory.String
-		}
-
-		// Delete clip folder
-		fullPath := filepath.Join(clipDir, clip.Path)
-		if err := os.RemoveAll(fullPath); err != nil {
-			c.Logger().Warnf("Failed to delete clip files at %s: %v", fullPath, err)
-			// Continue with database deletion even if file deletion fails
+		if err := moveToTrash(clipRoot(GetConfig(), user, clip), clip.Path); err != nil {
+			c.Logger().Warnf("Failed to move clip files to trash: %v", err)
+			// Continue with trashing the record even if file move fails
 		}
 	}
 
-	// Delete from database
-	if err := tx.Destroy(clip); err != nil {
+	clip.DeletedAt = nulls.NewTime(time.Now())
+	if err := tx.Update(clip); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if err := recordAudit(c, tx, auditClipDelete, "clip_id", clip.ID.String()); err != nil {
 		return c.Error(http.StatusInternalServerError, err)
 	}
 
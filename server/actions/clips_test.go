@@ -3,6 +3,10 @@ package actions
 import (
 	"encoding/base64"
 	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
 )
 
 func (as *ActionSuite) Test_ClipsEndpoint_Unauthorized() {
@@ -181,3 +185,45 @@ func (as *ActionSuite) Test_DeleteClip_WithDeleteFilesTrue() {
 	res := as.JSON("/api/v1/clips/550e8400-e29b-41d4-a716-446655440000?delete_files=true").Delete()
 	as.Equal(http.StatusUnauthorized, res.Code)
 }
+
+func (as *ActionSuite) Test_GenerateFrontmatterLocation() {
+	lat, lon := 48.8566, 2.3522
+	frontmatter := generateFrontmatter(ClipPayload{
+		Title:     "Test",
+		URL:       "https://example.com",
+		Latitude:  &lat,
+		Longitude: &lon,
+		Place:     "Paris",
+	})
+
+	as.Contains(frontmatter, "location: [48.8566, 2.3522]")
+	as.Contains(frontmatter, `place: "Paris"`)
+	as.NotContains(generateFrontmatter(ClipPayload{Title: "Test"}), "location:")
+}
+
+func (as *ActionSuite) Test_CreateClip_SanitizesFullpage() {
+	kit := testkit.New(as.T())
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	page := `<html><head><script>alert(1)</script><style>p { color: red }</style></head>` +
+		`<body><p onclick="x()">Saved</p><img src="https://stats.example/p.gif" width="1" height="1"></body></html>`
+	save := func(url string) string {
+		var clip ClipResponse
+		res := client.Post("/api/v1/clips", ClipPayload{Title: "Page", URL: url, Mode: "fullpage", HTML: page})
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		res.JSON(&clip)
+		data, err := os.ReadFile(filepath.Join(kit.StorageRoot, clip.Path))
+		as.NoError(err)
+		return string(data)
+	}
+
+	saved := save("https://example.com/sanitized")
+	as.Contains(saved, "<p>Saved</p>")
+	as.Contains(saved, "p { color: red }")
+	as.NotContains(saved, "script")
+	as.NotContains(saved, "onclick")
+	as.NotContains(saved, "stats.example")
+
+	kit.Config.Clips.RawHTML = true
+	as.Contains(save("https://example.com/raw"), page)
+}
@@ -0,0 +1,394 @@
+package actions
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"server/internal/cloud"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// Users connect a Dropbox or Google Drive folder (cloud.dropbox and
+// cloud.google_drive being the server's OAuth apps): POST
+// /api/v1/cloud/{provider}/connect returns the provider's authorization
+// page, which sends the user back to /auth/cloud/{provider}/callback. Each
+// new clip is then uploaded to the connected folders by a job, decrypted,
+// laid out as in the clip directory. The local copy stays the one the
+// server reads.
+
+// cloudStateTTL is how long a user has to authorize the server
+const cloudStateTTL = 15 * time.Minute
+
+// cloudNonceSessionKey is the session key of the nonce binding a
+// provider's state to the browser that asked to connect
+func cloudNonceSessionKey(provider string) string {
+	return "cloud_connect_nonce:" + provider
+}
+
+// CloudConnectionResponse is the API representation of a cloud connection
+type CloudConnectionResponse struct {
+	Provider     string     `json:"provider"`
+	Folder       string     `json:"folder"`
+	ConnectedAt  time.Time  `json:"connected_at"`
+	LastUploadAt *time.Time `json:"last_upload_at,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// ListCloudConnectionsResponse is the response from GET /api/v1/cloud
+type ListCloudConnectionsResponse struct {
+	Connections []CloudConnectionResponse `json:"connections"`
+	Providers   []string                  `json:"providers"` // Those the server has an app for
+}
+
+// ConnectCloudPayload is the body of POST /api/v1/cloud/{provider}/connect
+type ConnectCloudPayload struct {
+	Folder string `json:"folder"` // Default: cloud.folder
+}
+
+// cloudUploadArgs is the payload of a cloud upload job
+type cloudUploadArgs struct {
+	ClipID uuid.UUID `json:"clip_id"`
+}
+
+// cloudUploadResult is the result of a cloud upload job
+type cloudUploadResult struct {
+	ClipID    string   `json:"clip_id"`
+	Files     int      `json:"files"`     // Uploaded to each folder
+	Providers []string `json:"providers"` // Uploaded to
+}
+
+// cloudConnectionToResponse converts a connection to its API representation
+func cloudConnectionToResponse(conn *models.CloudConnection) CloudConnectionResponse {
+	resp := CloudConnectionResponse{
+		Provider:    conn.Provider,
+		Folder:      conn.Folder,
+		ConnectedAt: conn.CreatedAt,
+		LastError:   conn.LastError.String,
+	}
+	if conn.LastUploadAt.Valid {
+		resp.LastUploadAt = &conn.LastUploadAt.Time
+	}
+	return resp
+}
+
+// cloudRedirectURL is where a provider sends users back to
+func cloudRedirectURL(provider string) string {
+	return strings.TrimSuffix(GetConfig().Server.BaseURL, "/") + "/auth/cloud/" + provider + "/callback"
+}
+
+// listCloudConnections lists the user's cloud connections
+func listCloudConnections(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userID, err := uuid.FromString(c.Value("user_id").(string))
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	conns, err := models.FindCloudConnectionsByUserID(tx, userID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	resp := ListCloudConnectionsResponse{
+		Connections: make([]CloudConnectionResponse, len(conns)),
+		Providers:   []string{},
+	}
+	for i := range conns {
+		resp.Connections[i] = cloudConnectionToResponse(&conns[i])
+	}
+	for _, provider := range cloud.Providers {
+		if cloud.App(GetConfig().Cloud, provider) != nil {
+			resp.Providers = append(resp.Providers, provider)
+		}
+	}
+	return c.Render(http.StatusOK, r.JSON(resp))
+}
+
+// connectCloud returns the provider's authorization page, where the user
+// lets the server upload to their storage
+func connectCloud(c buffalo.Context) error {
+	userID, err := uuid.FromString(c.Value("user_id").(string))
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+	cfg := GetConfig()
+	provider := c.Param("provider")
+	app := cloud.App(cfg.Cloud, provider)
+	if app == nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("cloud provider not configured: %s", provider))
+	}
+	if cfg.JWT.Secret == "" {
+		return c.Error(http.StatusInternalServerError, fmt.Errorf("JWT not configured"))
+	}
+
+	var req ConnectCloudPayload
+	if c.Request().ContentLength > 0 {
+		if err := c.Bind(&req); err != nil {
+			return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		}
+	}
+	folder := strings.Trim(strings.TrimSpace(req.Folder), "/")
+	if folder == "" {
+		folder = cfg.Cloud.Folder
+	}
+	for _, part := range strings.Split(folder, "/") {
+		if part == "" || part == "." || part == ".." {
+			return c.Error(http.StatusBadRequest, fmt.Errorf("invalid folder: %s", req.Folder))
+		}
+	}
+
+	// The state identifies the user on the callback, which has no API token.
+	// Its nonce is also kept in the session, so the callback only completes
+	// in the browser that asked to connect, not in one sent the link.
+	nonce, err := randomToken()
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	state, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":      userID.String(),
+		"provider": provider,
+		"folder":   folder,
+		"nonce":    nonce,
+		"exp":      time.Now().Add(cloudStateTTL).Unix(),
+		"type":     "cloud_connect",
+	}).SignedString([]byte(cfg.JWT.Secret))
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	c.Session().Set(cloudNonceSessionKey(provider), nonce)
+	if err := c.Session().Save(); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	conf := cloud.OAuthConfig(provider, *app, cloudRedirectURL(provider))
+	return c.Render(http.StatusOK, r.JSON(map[string]string{
+		"url": conf.AuthCodeURL(state, cloud.AuthCodeOptions(provider)...),
+	}))
+}
+
+// cloudCallback saves the token the provider issued once the user
+// authorized the server, replacing a previous connection to the provider
+func cloudCallback(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	cfg := GetConfig()
+	provider := c.Param("provider")
+	app := cloud.App(cfg.Cloud, provider)
+	if app == nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("cloud provider not configured: %s", provider))
+	}
+	if msg := c.Param("error"); msg != "" {
+		if desc := c.Param("error_description"); desc != "" {
+			msg += ": " + desc
+		}
+		return c.Error(http.StatusBadRequest, fmt.Errorf("authorization refused: %s", msg))
+	}
+
+	token, err := jwt.Parse(c.Param("state"), func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return []byte(cfg.JWT.Secret), nil
+	})
+	if err != nil || !token.Valid {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid or expired state"))
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["type"] != "cloud_connect" || claims["provider"] != provider {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid state"))
+	}
+	nonce, _ := claims["nonce"].(string)
+	expected, _ := c.Session().Get(cloudNonceSessionKey(provider)).(string)
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(nonce), []byte(expected)) != 1 {
+		audit(c, auditAuthFailed, "reason", "cloud_state_not_bound")
+		return c.Error(http.StatusBadRequest, fmt.Errorf("the connection was started in another browser, start it again here"))
+	}
+	c.Session().Delete(cloudNonceSessionKey(provider))
+	c.Session().Save()
+	sub, _ := claims["sub"].(string)
+	folder, _ := claims["folder"].(string)
+	user := &models.User{}
+	if err := tx.Find(user, sub); err != nil || user.Disabled {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid state"))
+	}
+
+	ctx := context.WithValue(c.Request().Context(), oauth2.HTTPClient, &http.Client{Timeout: time.Duration(cfg.Cloud.TimeoutSeconds) * time.Second})
+	issued, err := cloud.OAuthConfig(provider, *app, cloudRedirectURL(provider)).Exchange(ctx, c.Param("code"))
+	if err != nil {
+		return c.Error(http.StatusBadGateway, fmt.Errorf("failed to get a token from %s: %w", provider, err))
+	}
+	body, err := json.Marshal(issued)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	conn, err := models.FindCloudConnection(tx, user.ID, provider)
+	isNew := errors.Is(err, sql.ErrNoRows)
+	if isNew {
+		conn = &models.CloudConnection{ID: uuid.Must(uuid.NewV4()), UserID: user.ID, Provider: provider}
+	} else if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	conn.Folder = folder
+	conn.Token = string(body)
+	conn.LastError = nulls.String{}
+	var verrs *validate.Errors
+	if isNew {
+		verrs, err = tx.ValidateAndCreate(conn)
+	} else {
+		verrs, err = tx.ValidateAndUpdate(conn)
+	}
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+	return c.Render(http.StatusOK, r.JSON(cloudConnectionToResponse(conn)))
+}
+
+// disconnectCloud forgets the user's connection to a provider. The token
+// stays valid at the provider until the user revokes the app there.
+func disconnectCloud(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userID, err := uuid.FromString(c.Value("user_id").(string))
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	conn, err := models.FindCloudConnection(tx, userID, c.Param("provider"))
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("not connected to %s", c.Param("provider")))
+	}
+	if err := tx.Destroy(conn); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusNoContent, nil)
+}
+
+// queueCloudUpload queues the upload of a new clip to the user's cloud
+// folders, when they connected any
+func queueCloudUpload(c buffalo.Context, tx *pop.Connection, clip *models.Clip) error {
+	conns, err := models.FindCloudConnectionsByUserID(tx, clip.UserID)
+	if err != nil || len(conns) == 0 {
+		return err
+	}
+	_, err = enqueueJob(c, tx, clip.UserID, models.JobCloudUpload, cloudUploadArgs{ClipID: clip.ID})
+	return err
+}
+
+// cloudUploadJob uploads a clip's files to each of the user's cloud
+// folders. A failed upload is recorded on its connection and fails the
+// attempt; files are replaced, so the next attempt uploads them all again.
+func cloudUploadJob(ctx context.Context, db *pop.Connection, job *models.Job) (interface{}, error) {
+	var args cloudUploadArgs
+	if err := json.Unmarshal([]byte(job.Payload), &args); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	clip := &models.Clip{}
+	if err := db.Where("id = ? AND user_id = ?", args.ClipID, job.UserID).First(clip); err != nil {
+		return nil, fmt.Errorf("clip %s: %w", args.ClipID, err)
+	}
+	user := &models.User{}
+	if err := db.Find(user, job.UserID); err != nil {
+		return nil, err
+	}
+	conns, err := models.FindCloudConnectionsByUserID(db, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := cloudUploadResult{ClipID: clip.ID.String(), Providers: []string{}}
+	if clip.DeletedAt.Valid {
+		return result, nil // Trashed before it was uploaded
+	}
+	root := clipRoot(GetConfig(), user, clip)
+	files, err := clipFiles(root, clip)
+	if err != nil {
+		return nil, err
+	}
+	result.Files = len(files)
+
+	var failed error
+	for i := range conns {
+		conn := &conns[i]
+		err := uploadToCloud(ctx, conn, root, files)
+		if err != nil {
+			conn.LastError = nulls.NewString(err.Error())
+			failed = errors.Join(failed, err)
+		} else {
+			conn.LastUploadAt = nulls.NewTime(time.Now())
+			conn.LastError = nulls.String{}
+			result.Providers = append(result.Providers, conn.Provider)
+		}
+		if err := db.Update(conn); err != nil {
+			return nil, err
+		}
+	}
+	if failed != nil {
+		return nil, failed
+	}
+	return result, nil
+}
+
+// uploadToCloud uploads files under root to a connection's folder, keeping
+// the token when it was refreshed
+func uploadToCloud(ctx context.Context, conn *models.CloudConnection, root string, files []string) error {
+	cfg := GetConfig()
+	app := cloud.App(cfg.Cloud, conn.Provider)
+	if app == nil {
+		return fmt.Errorf("cloud provider not configured: %s", conn.Provider)
+	}
+	var token oauth2.Token
+	if err := json.Unmarshal([]byte(conn.Token), &token); err != nil {
+		return fmt.Errorf("invalid token: %w", err)
+	}
+
+	timeout := time.Duration(cfg.Cloud.TimeoutSeconds) * time.Second
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Timeout: timeout})
+	source := cloud.OAuthConfig(conn.Provider, *app, cloudRedirectURL(conn.Provider)).TokenSource(ctx, &token)
+	client := oauth2.NewClient(ctx, source)
+	client.Timeout = timeout
+	folder, err := cloud.New(conn.Provider, *app, client, conn.Folder)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := readClipFile(file)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		if err := folder.Put(ctx, filepath.ToSlash(rel), data); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", rel, err)
+		}
+	}
+
+	if current, err := source.Token(); err == nil && current.AccessToken != token.AccessToken {
+		body, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+		conn.Token = string(body)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package actions
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"server/internal/testkit"
+	"server/models"
+)
+
+func (as *ActionSuite) Test_CloudUploads() {
+	// A Dropbox serving the OAuth token and upload endpoints
+	var mu sync.Mutex
+	uploads := map[string]string{}
+	dropbox := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/token":
+			r.ParseForm()
+			if r.Form.Get("code") != "granted" {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"access","refresh_token":"refresh","token_type":"bearer","expires_in":14400}`))
+		case "/2/files/upload":
+			if r.Header.Get("Authorization") != "Bearer access" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			var arg struct {
+				Path string `json:"path"`
+			}
+			json.Unmarshal([]byte(r.Header.Get("Dropbox-API-Arg")), &arg)
+			data, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			uploads[arg.Path] = string(data)
+			mu.Unlock()
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer dropbox.Close()
+
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	kit.Config.Server.BaseURL = "https://clipper.example.com"
+	kit.Config.Cloud.Dropbox.ClientID = "app-key"
+	kit.Config.Cloud.Dropbox.ClientSecret = "app-secret"
+	kit.Config.Cloud.Dropbox.BaseURL = dropbox.URL
+	client := kit.Client(newKitApp(kit), user)
+
+	var list ListCloudConnectionsResponse
+	res := client.Get("/api/v1/cloud")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&list)
+	as.Equal([]string{"dropbox"}, list.Providers)
+	as.Empty(list.Connections)
+	as.Equal(http.StatusNotFound, client.Post("/api/v1/cloud/google_drive/connect", nil).Code)
+	as.Equal(http.StatusBadRequest, client.Post("/api/v1/cloud/dropbox/connect", ConnectCloudPayload{Folder: "../elsewhere"}).Code)
+
+	// The user is sent to Dropbox, which sends them back with a code
+	var connect struct {
+		URL string `json:"url"`
+	}
+	res = client.Post("/api/v1/cloud/dropbox/connect", ConnectCloudPayload{Folder: "Clips/Inbox"})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&connect)
+	authURL, err := url.Parse(connect.URL)
+	as.NoError(err)
+	as.Equal("/oauth2/authorize", authURL.Path)
+	as.Equal("offline", authURL.Query().Get("token_access_type"))
+	as.Equal("https://clipper.example.com/auth/cloud/dropbox/callback", authURL.Query().Get("redirect_uri"))
+	state := authURL.Query().Get("state")
+
+	// The callback completes only in the browser that asked to connect
+	as.Equal(http.StatusBadRequest, client.Get("/auth/cloud/dropbox/callback?code=granted&state="+url.QueryEscape(state)).Code)
+	client.Header = http.Header{"Cookie": {res.Header().Get("Set-Cookie")}}
+	as.Equal(http.StatusBadRequest, client.Get("/auth/cloud/dropbox/callback?code=granted&state=forged").Code)
+	as.Equal(http.StatusBadRequest, client.Get("/auth/cloud/dropbox/callback?error=access_denied&state="+state).Code)
+	var conn CloudConnectionResponse
+	res = client.Get("/auth/cloud/dropbox/callback?code=granted&state=" + url.QueryEscape(state))
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&conn)
+	as.Equal("dropbox", conn.Provider)
+	as.Equal("Clips/Inbox", conn.Folder)
+	// Connecting again replaces the connection
+	client.Header = nil
+	res = client.Post("/api/v1/cloud/dropbox/connect", ConnectCloudPayload{Folder: "Clips/Inbox"})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&connect)
+	authURL, err = url.Parse(connect.URL)
+	as.NoError(err)
+	client.Header = http.Header{"Cookie": {res.Header().Get("Set-Cookie")}}
+	res = client.Get("/auth/cloud/dropbox/callback?code=granted&state=" + url.QueryEscape(authURL.Query().Get("state")))
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	client.Header = nil
+
+	// New clips are uploaded to the folder
+	var created ClipResponse
+	res = client.Post("/api/v1/clips", ClipPayload{
+		Title:    "Synced",
+		URL:      "https://example.com/synced",
+		Mode:     "article",
+		Markdown: "In my Dropbox",
+	})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&created)
+	runQueuedJobs(kit.DB)
+
+	job := &models.Job{}
+	as.NoError(kit.DB.Where("type = ?", models.JobCloudUpload).First(job))
+	as.Equal(models.JobSucceeded, job.Status, job.Error.String)
+	prefix := "/Clips/Inbox/" + filepath.ToSlash(filepath.Dir(created.Path)) + "/"
+	found := false
+	for p, content := range uploads {
+		as.True(strings.HasPrefix(p, prefix), p)
+		found = found || strings.HasSuffix(p, ".md") && strings.Contains(content, "In my Dropbox")
+	}
+	as.True(found, "%v", uploads)
+
+	list = ListCloudConnectionsResponse{}
+	client.Get("/api/v1/cloud").JSON(&list)
+	as.Len(list.Connections, 1)
+	as.NotNil(list.Connections[0].LastUploadAt)
+	as.Empty(list.Connections[0].LastError)
+
+	// Once disconnected, clips are no longer uploaded
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/cloud/dropbox").Code)
+	as.Equal(http.StatusNotFound, client.Delete("/api/v1/cloud/dropbox").Code)
+	res = client.Post("/api/v1/clips", ClipPayload{Title: "Local", URL: "https://example.com/local", Mode: "bookmark"})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	count, err := kit.DB.Where("type = ?", models.JobCloudUpload).Count(&models.Job{})
+	as.NoError(err)
+	as.Equal(1, count)
+}
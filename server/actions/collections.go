@@ -0,0 +1,217 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"server/internal/config"
+	"server/internal/services"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// CollectionPayload is the request body for creating a collection
+type CollectionPayload struct {
+	Name        string `json:"name"`
+	StoragePath string `json:"storage_path,omitempty"` // Subpath of the clip directory, or an absolute path under admin.allowed_paths
+	Public      bool   `json:"public,omitempty"`       // Published by the read-only mirror
+}
+
+// CollectionUpdatePayload is the request body for updating a collection;
+// omitted fields are left unchanged
+type CollectionUpdatePayload struct {
+	Name   *string `json:"name,omitempty"`
+	Public *bool   `json:"public,omitempty"`
+}
+
+// CollectionResponse is the API representation of a collection
+type CollectionResponse struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	StoragePath string    `json:"storage_path,omitempty"`
+	Public      bool      `json:"public"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// listCollections returns the user's collections
+func listCollections(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	collections, err := models.FindCollectionsByUserID(tx, userID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	resp := make([]CollectionResponse, len(collections))
+	for i := range collections {
+		resp[i] = collectionToResponse(&collections[i])
+	}
+
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"collections": resp,
+	}))
+}
+
+// createCollection creates a named collection
+func createCollection(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	var req CollectionPayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	collection := &models.Collection{
+		ID:     uuid.Must(uuid.NewV4()),
+		UserID: userID,
+		Name:   strings.TrimSpace(req.Name),
+		Public: req.Public,
+	}
+	if path := strings.TrimSpace(req.StoragePath); path != "" {
+		path = filepath.Clean(path)
+		email, _ := c.Value("user_email").(string)
+		admin := isAdminEmail(GetConfig(), email)
+		if err := services.NewStorageService(GetConfig(), adminLogger{c}).ValidateCollectionPath(path, admin); err != nil {
+			return c.Error(http.StatusUnprocessableEntity, fmt.Errorf("storage_path: %w", err))
+		}
+		collection.StoragePath = nulls.NewString(path)
+	}
+
+	if _, err := models.FindCollectionByName(tx, userID, collection.Name); err == nil {
+		return c.Error(http.StatusConflict, fmt.Errorf("a collection named %q already exists", collection.Name))
+	}
+
+	verrs, err := tx.ValidateAndCreate(collection)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	return c.Render(http.StatusCreated, r.JSON(collectionToResponse(collection)))
+}
+
+// updateCollection renames a collection or changes whether it is public
+func updateCollection(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	collectionID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid collection ID"))
+	}
+
+	var req CollectionUpdatePayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	collection, err := models.FindCollectionByIDAndUser(tx, collectionID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("collection not found"))
+	}
+	if req.Name != nil {
+		collection.Name = strings.TrimSpace(*req.Name)
+		if other, err := models.FindCollectionByName(tx, userID, collection.Name); err == nil && other.ID != collection.ID {
+			return c.Error(http.StatusConflict, fmt.Errorf("a collection named %q already exists", collection.Name))
+		}
+	}
+	if req.Public != nil {
+		collection.Public = *req.Public
+	}
+
+	verrs, err := tx.ValidateAndUpdate(collection)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	return c.Render(http.StatusOK, r.JSON(collectionToResponse(collection)))
+}
+
+// deleteCollection removes a collection; its clips are kept but no longer
+// belong to it, and stay in the collection's storage until moved. Rules
+// filing clips in it stop doing so.
+func deleteCollection(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	collectionID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid collection ID"))
+	}
+
+	collection, err := models.FindCollectionByIDAndUser(tx, collectionID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("collection not found"))
+	}
+
+	if err := tx.RawQuery("UPDATE clips SET collection_id = NULL WHERE collection_id = ?", collection.ID).Exec(); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if err := tx.RawQuery("UPDATE clip_rules SET collection_id = NULL WHERE collection_id = ?", collection.ID).Exec(); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if err := tx.Destroy(collection); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	return c.Render(http.StatusNoContent, nil)
+}
+
+// collectionToResponse converts a collection model to its API representation
+func collectionToResponse(col *models.Collection) CollectionResponse {
+	return CollectionResponse{
+		ID:          col.ID.String(),
+		Name:        col.Name,
+		StoragePath: col.StoragePath.String,
+		Public:      col.Public,
+		CreatedAt:   col.CreatedAt,
+	}
+}
+
+// clipRoot returns the directory holding a clip's folder: the user's clip
+// directory, or the storage of the collection the clip was moved to
+func clipRoot(cfg *config.Config, user *models.User, clip *models.Clip) string {
+	return storageRoot(cfg, user, clip.StoragePath.String)
+}
+
+// storageRoot resolves a collection's storage path; relative paths are inside
+// the user's clip directory and an empty one is the directory itself
+func storageRoot(cfg *config.Config, user *models.User, storagePath string) string {
+	switch {
+	case storagePath == "":
+		return userClipDir(cfg, user)
+	case filepath.IsAbs(storagePath):
+		return storagePath
+	}
+	return filepath.Join(userClipDir(cfg, user), storagePath)
+}
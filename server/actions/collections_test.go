@@ -0,0 +1,91 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_CreateCollection_StoragePath() {
+	kit := testkit.New(as.T())
+	mount := as.T().TempDir()
+	admin := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{admin.Email}
+	app := newKitApp(kit)
+	client := kit.Client(app, admin)
+
+	// Outside the clip directory, or absolute without allowed paths
+	for _, path := range []string{"../elsewhere", mount} {
+		res := client.Post("/api/v1/collections", CollectionPayload{Name: "Bad", StoragePath: path})
+		as.Equal(http.StatusUnprocessableEntity, res.Code, path)
+	}
+
+	// Under an allowed path: not another user's directory, nor a sibling
+	// sharing its prefix
+	kit.Config.Admin.AllowedPaths = []string{mount, kit.StorageRoot}
+	other := kit.CreateUser()
+	for _, path := range []string{filepath.Join(kit.StorageRoot, other.ID.String()), mount + "-evil"} {
+		res := client.Post("/api/v1/collections", CollectionPayload{Name: "Bad", StoragePath: path})
+		as.Equal(http.StatusUnprocessableEntity, res.Code, path)
+	}
+	// Absolute paths are for admins only
+	res := kit.Client(app, other).Post("/api/v1/collections", CollectionPayload{Name: "Mount", StoragePath: mount})
+	as.Equal(http.StatusUnprocessableEntity, res.Code, res.Body.String())
+	as.Contains(res.Body.String(), "admins")
+
+	var collection CollectionResponse
+	res = client.Post("/api/v1/collections", CollectionPayload{Name: "Receipts", StoragePath: mount + "/receipts/"})
+	as.Equal(http.StatusCreated, res.Code, res.Body.String())
+	res.JSON(&collection)
+	as.Equal(filepath.Join(mount, "receipts"), collection.StoragePath)
+}
+
+func (as *ActionSuite) Test_BulkMoveToCollection_MovesFolders() {
+	kit := testkit.New(as.T())
+	mount := as.T().TempDir()
+	kit.Config.Admin.AllowedPaths = []string{mount}
+	user := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{user.Email} // Sets absolute storage paths
+	client := kit.Client(newKitApp(kit), user)
+	clip := kit.CreateClip(user, testkit.WithContent("# Receipt\n"))
+
+	var subpath, absolute CollectionResponse
+	client.Post("/api/v1/collections", CollectionPayload{Name: "Archive", StoragePath: "archive"}).JSON(&subpath)
+	client.Post("/api/v1/collections", CollectionPayload{Name: "Receipts", StoragePath: mount}).JSON(&absolute)
+
+	locations := []struct {
+		collectionID string
+		root         string
+	}{
+		{subpath.ID, filepath.Join(kit.StorageRoot, "archive")},
+		{absolute.ID, mount},
+		{"", kit.StorageRoot}, // Out of any collection, back in the clip directory
+	}
+	previous := kit.StorageRoot
+	for _, loc := range locations {
+		res := client.Post("/api/v1/clips/bulk", BulkPayload{Operation: bulkMoveToCollection, IDs: []string{clip.ID.String()}, CollectionID: loc.collectionID})
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+
+		_, err := os.Stat(filepath.Join(loc.root, clip.Path))
+		as.NoError(err, loc.root)
+		_, err = os.Stat(filepath.Join(previous, clip.Path))
+		as.True(os.IsNotExist(err), previous)
+		previous = loc.root
+
+		// The clip is still read from wherever it lives
+		var detail ClipDetail
+		res = client.Get("/api/v1/clips/" + clip.ID.String())
+		as.Equal(http.StatusOK, res.Code)
+		res.JSON(&detail)
+		as.Contains(detail.Content, "# Receipt")
+	}
+
+	// A folder with the same name in the collection's storage fails the move
+	as.NoError(os.MkdirAll(filepath.Join(mount, clip.Path), 0755))
+	res := client.Post("/api/v1/clips/bulk", BulkPayload{Operation: bulkMoveToCollection, IDs: []string{clip.ID.String()}, CollectionID: absolute.ID})
+	as.Equal(http.StatusUnprocessableEntity, res.Code)
+	_, err := os.Stat(filepath.Join(kit.StorageRoot, clip.Path))
+	as.NoError(err)
+}
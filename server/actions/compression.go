@@ -0,0 +1,74 @@
+package actions
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// With storage.compression.enabled, pages and notes (.md and .html files)
+// of at least storage.compression.min_bytes are gzipped by writeFileSync,
+// before encryption, keeping their names; fullpage captures shrink several
+// times. Readers tell them apart by the gzip header, which text files never
+// start with, so files written uncompressed stay readable.
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// isCompressibleClipFile reports whether a clip file is a page or note,
+// which compression applies to (media formats are compressed already)
+func isCompressibleClipFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".html", ".htm":
+		return true
+	}
+	return false
+}
+
+// isCompressedClipFile reports whether a clip file starting with head is
+// stored compressed
+func isCompressedClipFile(path string, head []byte) bool {
+	return isCompressibleClipFile(path) && bytes.HasPrefix(head, gzipMagic)
+}
+
+// compressClipData returns the content of a clip file as it is to be
+// stored: gzipped when compression applies to it
+func compressClipData(path string, data []byte) ([]byte, error) {
+	conf := GetConfig().Storage.Compression
+	if !conf.Enabled || !isCompressibleClipFile(path) || len(data) < conf.MinBytes {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if buf.Len() >= len(data) {
+		return data, nil // Incompressible
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressClipReader returns the content of the clip file at path read
+// from r, decompressed when it is stored compressed
+func decompressClipReader(path string, r io.Reader) (io.Reader, error) {
+	if !isCompressibleClipFile(path) {
+		return r, nil
+	}
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(len(gzipMagic))
+	if !bytes.Equal(head, gzipMagic) {
+		return br, nil
+	}
+	return gzip.NewReader(br)
+}
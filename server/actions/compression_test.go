@@ -0,0 +1,77 @@
+package actions
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"server/internal/atrest"
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_CompressedStorage() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	old := kit.CreateClip(user, testkit.WithContent("Written before compression"))
+	kit.Config.Storage.Compression.Enabled = true
+	kit.Config.Storage.Compression.MinBytes = 1024
+	client := kit.Client(newKitApp(kit), user)
+
+	body := strings.Repeat("A long fullpage capture. ", 200)
+	var created ClipResponse
+	res := client.Post("/api/v1/clips", ClipPayload{
+		Title:    "Long read",
+		URL:      "https://example.com/long",
+		Mode:     "article",
+		Markdown: body,
+	})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&created)
+
+	// Large pages are gzipped on disk
+	folder := filepath.Join(kit.StorageRoot, filepath.Dir(created.Path))
+	pages, _ := filepath.Glob(filepath.Join(folder, "*.md"))
+	as.NotEmpty(pages)
+	for _, p := range pages {
+		data, err := os.ReadFile(p)
+		as.NoError(err)
+		as.True(isCompressedClipFile(p, data), p)
+		as.Less(len(data), len(body)/4)
+	}
+
+	// and read back decompressed, like the clips written before
+	var detail ClipDetail
+	res = client.Get("/api/v1/clips/" + created.ID)
+	as.Equal(http.StatusOK, res.Code)
+	res.JSON(&detail)
+	as.Contains(detail.Content, body)
+
+	detail = ClipDetail{}
+	client.Get("/api/v1/clips/" + old.ID.String()).JSON(&detail)
+	as.Contains(detail.Content, "Written before compression")
+
+	// Small files and media are stored as they are
+	small, err := compressClipData("note.md", []byte("# Short"))
+	as.NoError(err)
+	as.Equal("# Short", string(small))
+	png := bytes.Repeat([]byte{0}, 4096)
+	stored, err := compressClipData("media/hero.png", png)
+	as.NoError(err)
+	as.Equal(png, stored)
+
+	// Compressed pages are encrypted after compression
+	secret, _, err := atrest.GenerateKey()
+	as.NoError(err)
+	kit.Config.Storage.Encryption.Enabled = true
+	kit.Config.Storage.Encryption.Key = secret
+	page := filepath.Join(as.T().TempDir(), "page.md")
+	as.NoError(writeFileSync(page, []byte(body), 0644))
+	data, err := os.ReadFile(page)
+	as.NoError(err)
+	as.True(atrest.IsEncrypted(data))
+	data, err = readClipFile(page)
+	as.NoError(err)
+	as.Equal(body, string(data))
+}
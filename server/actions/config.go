@@ -1,44 +1,199 @@
 package actions
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
+
+	"server/internal/config"
+	"server/models"
 
 	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
 )
 
-// ConfigResponse is the response from GET /api/v1/config
+// ConfigResponse is the response from GET and PUT /api/v1/config: the
+// user's settings, and the server's limits with the user's image preferences
+// applied
 type ConfigResponse struct {
-	ClipDirectory string       `json:"clipDirectory"`
-	DefaultFormat string       `json:"defaultFormat"`
-	Images        ImagesConfig `json:"images"`
+	ClipDirectory  string       `json:"clipDirectory"`
+	DefaultFormat  string       `json:"defaultFormat"` // Mode of clips sent without one
+	DefaultTags    []string     `json:"defaultTags"`
+	FolderTemplate string       `json:"folderTemplate"` // The user's own, "" for clips.folder_template
+	Images         ImagesConfig `json:"images"`
 }
 
 // ImagesConfig contains image processing limits
 type ImagesConfig struct {
-	MaxSizeBytes   int64 `json:"maxSizeBytes"`
-	MaxDimensionPx int   `json:"maxDimensionPx"`
-	MaxTotalBytes  int64 `json:"maxTotalBytes"`
-	ConvertToWebp  bool  `json:"convertToWebp"`
+	MaxSizeBytes     int64    `json:"maxSizeBytes"`
+	MaxDimensionPx   int      `json:"maxDimensionPx"`
+	MaxTotalBytes    int64    `json:"maxTotalBytes"`
+	ConvertToWebp    bool     `json:"convertToWebp"`
+	PreserveOriginal bool     `json:"preserveOriginal"`
+	AllowedFormats   []string `json:"allowedFormats"`
+	FetchRemote      bool     `json:"fetchRemote"`
+}
+
+// ConfigPayload is the request body of PUT /api/v1/config. It replaces the
+// user's settings: empty fields fall back on the server's config.
+type ConfigPayload struct {
+	DefaultFormat  string            `json:"defaultFormat"`
+	DefaultTags    []string          `json:"defaultTags"`
+	FolderTemplate string            `json:"folderTemplate"`
+	Images         ImagesPreferences `json:"images"`
 }
 
+// ImagesPreferences are the image settings a user may choose
+type ImagesPreferences struct {
+	ConvertToWebp    *bool `json:"convertToWebp,omitempty"`
+	PreserveOriginal *bool `json:"preserveOriginal,omitempty"`
+	MaxDimensionPx   *int  `json:"maxDimensionPx,omitempty"` // At most images.max_dimension_px
+}
+
+// defaultClipMode is the mode of clips sent without one, and no default
+const defaultClipMode = "article"
+
 // getConfig returns the user's configuration
-// TODO: Implement user-specific config in task 2.4
 func getConfig(c buffalo.Context) error {
 	appCfg := GetConfig()
 	if appCfg == nil {
-		return c.Render(http.StatusInternalServerError, r.JSON(map[string]string{
-			"error": "configuration not loaded",
-		}))
+		return renderError(c, http.StatusInternalServerError, "", "configuration not loaded")
+	}
+	tx := c.Value("tx").(*pop.Connection)
+	user := &models.User{}
+	if err := tx.Find(user, c.Value("user_id")); err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+	settings, err := models.FindUserSettings(tx, user.ID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(configResponse(appCfg, user, settings)))
+}
+
+// updateConfig replaces the user's settings
+func updateConfig(c buffalo.Context) error {
+	appCfg := GetConfig()
+	if appCfg == nil {
+		return renderError(c, http.StatusInternalServerError, "", "configuration not loaded")
+	}
+	tx := c.Value("tx").(*pop.Connection)
+	user := &models.User{}
+	if err := tx.Find(user, c.Value("user_id")); err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+	settings, err := models.FindUserSettings(tx, user.ID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	var req ConfigPayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+	if err := applyConfigPayload(appCfg, user, settings, req); err != nil {
+		return c.Error(http.StatusUnprocessableEntity, err)
+	}
+
+	verrs, err := models.SaveUserSettings(tx, settings)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
 	}
+	if err := tx.UpdateColumns(user, "folder_template", "updated_at"); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(configResponse(appCfg, user, settings)))
+}
+
+// applyConfigPayload validates req and sets it on the user's settings and
+// folder template
+func applyConfigPayload(appCfg *config.Config, user *models.User, settings *models.UserSettings, req ConfigPayload) error {
+	settings.DefaultMode = nulls.String{}
+	if req.DefaultFormat != "" {
+		if !isClipMode(req.DefaultFormat) {
+			return fmt.Errorf("invalid defaultFormat %q: expected one of %s", req.DefaultFormat, strings.Join(clipModes, ", "))
+		}
+		settings.DefaultMode = nulls.NewString(req.DefaultFormat)
+	}
+	settings.SetTagList(req.DefaultTags)
+
+	user.FolderTemplate = nulls.String{}
+	if strings.TrimSpace(req.FolderTemplate) != "" {
+		if _, err := renderNamingTemplate(req.FolderTemplate, sampleClipNameData(), true); err != nil {
+			return fmt.Errorf("invalid folder template: %w", err)
+		}
+		user.FolderTemplate = nulls.NewString(req.FolderTemplate)
+	}
+
+	settings.ConvertToWebP = nulls.Bool{}
+	if v := req.Images.ConvertToWebp; v != nil {
+		settings.ConvertToWebP = nulls.NewBool(*v)
+	}
+	settings.PreserveOriginal = nulls.Bool{}
+	if v := req.Images.PreserveOriginal; v != nil {
+		settings.PreserveOriginal = nulls.NewBool(*v)
+	}
+	settings.MaxDimensionPx = nulls.Int{}
+	if v := req.Images.MaxDimensionPx; v != nil {
+		if limit := appCfg.Images.MaxDimensionPx; *v <= 0 || limit > 0 && *v > limit {
+			return fmt.Errorf("invalid maxDimensionPx %d: expected 1 to %d", *v, limit)
+		}
+		settings.MaxDimensionPx = nulls.NewInt(*v)
+	}
+	return nil
+}
 
-	return c.Render(http.StatusOK, r.JSON(ConfigResponse{
-		ClipDirectory: appCfg.Storage.BasePath,
-		DefaultFormat: "markdown",
+// configResponse converts the user's settings to their API representation
+func configResponse(appCfg *config.Config, user *models.User, settings *models.UserSettings) ConfigResponse {
+	images := userImagesConfig(appCfg.Images, settings)
+	mode := defaultClipMode
+	if settings.DefaultMode.Valid {
+		mode = settings.DefaultMode.String
+	}
+	return ConfigResponse{
+		ClipDirectory:  userClipDir(appCfg, user),
+		DefaultFormat:  mode,
+		DefaultTags:    settings.TagList(),
+		FolderTemplate: user.FolderTemplate.String,
 		Images: ImagesConfig{
-			MaxSizeBytes:   appCfg.Images.MaxSizeBytes,
-			MaxDimensionPx: appCfg.Images.MaxDimensionPx,
-			MaxTotalBytes:  appCfg.Images.MaxTotalBytes,
-			ConvertToWebp:  false,
+			MaxSizeBytes:     images.MaxSizeBytes,
+			MaxDimensionPx:   images.MaxDimensionPx,
+			MaxTotalBytes:    images.MaxTotalBytes,
+			ConvertToWebp:    images.ConvertToWebP,
+			PreserveOriginal: images.PreserveOriginal,
+			AllowedFormats:   images.AllowedFormats,
+			FetchRemote:      images.FetchRemote,
 		},
-	}))
+	}
+}
+
+// userImagesConfig returns images with the user's preferences applied. The
+// user's max dimension only applies when lower than the server's, which
+// may have been lowered since it was set.
+func userImagesConfig(images config.ImagesConfig, settings *models.UserSettings) config.ImagesConfig {
+	if settings.ConvertToWebP.Valid {
+		images.ConvertToWebP = settings.ConvertToWebP.Bool
+	}
+	if settings.PreserveOriginal.Valid {
+		images.PreserveOriginal = settings.PreserveOriginal.Bool
+	}
+	if px := settings.MaxDimensionPx; px.Valid && (images.MaxDimensionPx <= 0 || px.Int < images.MaxDimensionPx) {
+		images.MaxDimensionPx = px.Int
+	}
+	return images
+}
+
+// applyUserSettings gives a clip the user's default mode, when it was sent
+// without one, and tags, and saves its images with the user's preferences
+func applyUserSettings(req *ClipPayload, settings *models.UserSettings) {
+	if req.Mode == "" && settings.DefaultMode.Valid {
+		req.Mode = settings.DefaultMode.String
+	}
+	req.Tags = append(req.Tags, settings.TagList()...)
+	images := userImagesConfig(GetConfig().Images, settings)
+	req.images = &images
 }
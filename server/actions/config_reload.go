@@ -0,0 +1,124 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+
+	"server/internal/config"
+)
+
+// ConfigReload is what ReloadConfig did: the settings it applied, and those
+// that changed in the file but are only read at startup
+type ConfigReload struct {
+	Applied      []string
+	NeedsRestart []string
+}
+
+// ReloadConfig reads the config file again and applies the settings that can
+// change while serving: oauth.allowed_domains, allowed_emails and
+// extension_ids, images, rate_limit (but its store) and webhooks. The config
+// is replaced, never edited, so requests keep the one they started with. An
+// invalid file leaves the config as it was.
+func ReloadConfig() (ConfigReload, error) {
+	var reload ConfigReload
+	current := GetConfig()
+	if current == nil || configPath == "" {
+		return reload, fmt.Errorf("no config file was loaded")
+	}
+	loaded, err := config.Load(configPath)
+	if err != nil {
+		return reload, err
+	}
+	for _, p := range loaded.Check() {
+		if p.Severity == config.SeverityError && reloadable(p.Field) {
+			return reload, fmt.Errorf("%s: %s", configPath, p)
+		}
+	}
+
+	next := *current
+	apply := func(name string, dst, src any) {
+		d, s := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+		if !reflect.DeepEqual(d.Interface(), s.Interface()) {
+			d.Set(s)
+			reload.Applied = append(reload.Applied, name)
+		}
+	}
+	apply("oauth.allowed_domains", &next.OAuth.AllowedDomains, &loaded.OAuth.AllowedDomains)
+	apply("oauth.allowed_emails", &next.OAuth.AllowedEmails, &loaded.OAuth.AllowedEmails)
+	apply("oauth.extension_ids", &next.OAuth.ExtensionIDs, &loaded.OAuth.ExtensionIDs)
+	apply("images", &next.Images, &loaded.Images)
+	rateLimit := loaded.RateLimit
+	rateLimit.Store, rateLimit.RedisURL = current.RateLimit.Store, current.RateLimit.RedisURL
+	apply("rate_limit", &next.RateLimit, &rateLimit)
+	apply("webhooks", &next.Webhooks, &loaded.Webhooks)
+	appConfig.Store(&next)
+
+	reload.NeedsRestart = changedSections(&next, loaded)
+	return reload, nil
+}
+
+// reloadable reports whether ReloadConfig applies the setting named field
+func reloadable(field string) bool {
+	if field == "rate_limit.store" || field == "rate_limit.redis_url" {
+		return false
+	}
+	for _, prefix := range []string{"oauth.allowed_", "oauth.extension_ids", "images.", "rate_limit.", "webhooks."} {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// changedSections returns the top-level keys of clipper.yaml that differ
+// between a and b
+func changedSections(a, b *config.Config) []string {
+	var changed []string
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	for i := range va.NumField() {
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			name, _, _ := strings.Cut(va.Type().Field(i).Tag.Get("yaml"), ",")
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// WatchConfig reloads the config file on SIGHUP until ctx is done
+func WatchConfig(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				reload, err := ReloadConfig()
+				if err != nil {
+					log.Printf("Warning: config not reloaded: %v", err)
+					continue
+				}
+				log.Printf("Config reloaded from %s, applied: %s", configPath, listOrNone(reload.Applied))
+				if len(reload.NeedsRestart) > 0 {
+					log.Printf("Warning: changes to %s need a restart", strings.Join(reload.NeedsRestart, ", "))
+				}
+			}
+		}
+	}()
+}
+
+// listOrNone joins names, or says there are none
+func listOrNone(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,65 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_ReloadConfig() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	app := newKitApp(kit)
+	client := kit.Client(app, user)
+	path := filepath.Join(as.T().TempDir(), "clipper.yaml")
+	saved := configPath
+	configPath = path
+	as.T().Cleanup(func() { configPath = saved })
+
+	write := func(yaml string) {
+		as.NoError(os.WriteFile(path, []byte(yaml), 0600))
+	}
+	write(`
+server:
+  port: "9999"
+oauth:
+  allowed_domains: [example.com]
+images:
+  max_size_bytes: 1000
+  max_total_bytes: 5000
+rate_limit:
+  enabled: true
+  store: redis
+  per_user:
+    requests_per_minute: 60
+    burst: 1
+`)
+	reload, err := ReloadConfig()
+	as.NoError(err)
+	as.Equal([]string{"oauth.allowed_domains", "images", "rate_limit"}, reload.Applied)
+	as.Contains(reload.NeedsRestart, "server")
+	as.Contains(reload.NeedsRestart, "rate_limit") // Its store
+
+	cfg := GetConfig()
+	as.Equal([]string{"example.com"}, cfg.OAuth.AllowedDomains)
+	as.Equal(int64(1000), cfg.Images.MaxSizeBytes)
+	as.Equal("memory", cfg.RateLimit.Store)
+	as.Equal(kit.Config.Server.Port, cfg.Server.Port, "only read at startup")
+	as.Equal(kit.Config.Storage.BasePath, cfg.Storage.BasePath)
+
+	// The running app applies the new limits
+	as.Equal(http.StatusOK, client.Get("/api/v1/clips").Code)
+	as.Equal(http.StatusTooManyRequests, client.Get("/api/v1/clips").Code)
+
+	// An invalid file is not applied
+	write("images:\n  max_size_bytes: 1000\n  max_total_bytes: 10\n")
+	_, err = ReloadConfig()
+	as.ErrorContains(err, "images.max_total_bytes")
+	as.Same(cfg, GetConfig())
+	write("images: [")
+	_, err = ReloadConfig()
+	as.Error(err)
+	as.Same(cfg, GetConfig())
+}
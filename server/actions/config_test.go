@@ -1,6 +1,17 @@
 package actions
 
-import "net/http"
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
+	"server/models"
+)
 
 func (as *ActionSuite) Test_ConfigEndpoint_Unauthorized() {
 	// Config endpoint requires authentication
@@ -8,7 +19,68 @@ func (as *ActionSuite) Test_ConfigEndpoint_Unauthorized() {
 	as.Equal(http.StatusUnauthorized, res.Code)
 }
 
-// Note: Testing authenticated config endpoint requires:
-// 1. A valid JWT token with matching secret
-// 2. Config to be properly loaded
-// These would be better tested with a mock config or integration test
+func (as *ActionSuite) Test_UserSettings() {
+	kit := testkit.New(as.T())
+	kit.Config.Images.MaxDimensionPx = 200
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+
+	var resp ConfigResponse
+	client.Get("/api/v1/config").JSON(&resp)
+	as.Equal(kit.StorageRoot, resp.ClipDirectory)
+	as.Equal("article", resp.DefaultFormat)
+	as.Empty(resp.DefaultTags)
+	as.Equal(200, resp.Images.MaxDimensionPx)
+
+	as.Equal(http.StatusUnprocessableEntity, client.Put("/api/v1/config", ConfigPayload{DefaultFormat: "video"}).Code)
+	as.Equal(http.StatusUnprocessableEntity, client.Put("/api/v1/config", ConfigPayload{FolderTemplate: "{{.Year"}).Code)
+	tooLarge := 400
+	as.Equal(http.StatusUnprocessableEntity, client.Put("/api/v1/config", ConfigPayload{Images: ImagesPreferences{MaxDimensionPx: &tooLarge}}).Code)
+
+	px, preserve := 100, true
+	res := client.Put("/api/v1/config", ConfigPayload{
+		DefaultFormat:  "bookmark",
+		DefaultTags:    []string{"inbox", " inbox", "web"},
+		FolderTemplate: "{{.Site}}/{{.Slug}}",
+		Images:         ImagesPreferences{MaxDimensionPx: &px, PreserveOriginal: &preserve},
+	})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	client.Get("/api/v1/config").JSON(&resp)
+	as.Equal("bookmark", resp.DefaultFormat)
+	as.Equal([]string{"inbox", "web"}, resp.DefaultTags)
+	as.Equal("{{.Site}}/{{.Slug}}", resp.FolderTemplate)
+	as.Equal(100, resp.Images.MaxDimensionPx)
+	as.True(resp.Images.PreserveOriginal)
+
+	// New clips honor them
+	var buf bytes.Buffer
+	as.NoError(png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 150))))
+	var clip ClipResponse
+	res = client.Post("/api/v1/clips", ClipPayload{
+		Title: "Hello", URL: "https://example.com/hello", Markdown: "# Hello", Tags: []string{"go"},
+		Images: []ImagePayload{{Filename: "big.png", Data: base64.StdEncoding.EncodeToString(buf.Bytes())}},
+	})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+	as.Equal(filepath.Join("web-clips", "example-com", "hello"), filepath.Dir(clip.Path))
+	saved := &models.Clip{}
+	as.NoError(kit.DB.Find(saved, clip.ID))
+	as.Equal("bookmark", saved.Mode)
+	as.NoError(saved.LoadTags(kit.DB))
+	as.ElementsMatch([]string{"go", "inbox", "web"}, saved.Tags)
+	f, err := os.Open(filepath.Join(kit.StorageRoot, filepath.Dir(clip.Path), "media", "big.png"))
+	as.NoError(err)
+	defer f.Close()
+	img, _, err := image.DecodeConfig(f)
+	as.NoError(err)
+	as.Equal(100, img.Width)
+	_, err = os.Stat(filepath.Join(kit.StorageRoot, filepath.Dir(clip.Path), "media", originalsDir, "big.png"))
+	as.NoError(err)
+
+	// An empty body resets them
+	as.Equal(http.StatusOK, client.Put("/api/v1/config", ConfigPayload{}).Code)
+	client.Get("/api/v1/config").JSON(&resp)
+	as.Equal("article", resp.DefaultFormat)
+	as.Empty(resp.FolderTemplate)
+	as.Equal(200, resp.Images.MaxDimensionPx)
+}
@@ -0,0 +1,286 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"server/api"
+	"server/client"
+	"server/internal/openapi"
+	"server/internal/testkit"
+)
+
+// contractTransport checks every response the SDK receives against the spec
+type contractTransport struct {
+	spec *openapi.Spec
+
+	mu       sync.Mutex
+	failures []error
+}
+
+func (t *contractTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := t.spec.ValidateResponse(req.Method, req.URL.Path, res.StatusCode, body); err != nil {
+		t.mu.Lock()
+		t.failures = append(t.failures, err)
+		t.mu.Unlock()
+	}
+	return res, nil
+}
+
+// newContractClient starts the app on a test server and returns an SDK client
+// authenticated as a new user, with every response checked against the spec
+func (as *ActionSuite) newContractClient(kit *testkit.Kit) *client.Client {
+	spec, err := openapi.Parse(api.Spec)
+	as.Require().NoError(err)
+
+	srv := httptest.NewServer(newKitApp(kit))
+	as.T().Cleanup(srv.Close)
+
+	transport := &contractTransport{spec: spec}
+	as.T().Cleanup(func() {
+		for _, err := range transport.failures {
+			as.Fail("contract violation", err.Error())
+		}
+	})
+
+	sdk := client.New(srv.URL, kit.CreateToken(kit.CreateUser()))
+	sdk.HTTP = &http.Client{Transport: transport}
+	return sdk
+}
+
+func (as *ActionSuite) Test_Contract_ClipLifecycle() {
+	kit := testkit.New(as.T())
+	sdk := as.newContractClient(kit)
+	ctx := context.Background()
+
+	_, err := sdk.GetConfig(ctx)
+	as.NoError(err)
+
+	lat, lon := 48.8566, 2.3522
+	payload := client.ClipPayload{
+		Title:     "Contract Test",
+		URL:       "https://example.com/contract",
+		Markdown:  "# Contract Test\n\nBody.",
+		Tags:      []string{"contract"},
+		Images:    []client.ImagePayload{},
+		Mode:      client.ClipModeArticle,
+		Latitude:  &lat,
+		Longitude: &lon,
+		Place:     "Paris",
+	}
+	params := &client.CreateClipParams{IdempotencyKey: "contract-1"}
+	created, err := sdk.CreateClip(ctx, payload, params)
+	as.Require().NoError(err)
+	as.True(created.Success)
+
+	replayed, err := sdk.CreateClip(ctx, payload, params)
+	as.Require().NoError(err)
+	as.Equal(created.ID, replayed.ID)
+
+	detail, err := sdk.GetClip(ctx, created.ID)
+	as.Require().NoError(err)
+	as.Equal("Contract Test", detail.Title)
+	as.NotNil(detail.Location)
+
+	collection, err := sdk.CreateCollection(ctx, client.CollectionPayload{Name: "Reading"})
+	as.Require().NoError(err)
+
+	bulk, err := sdk.BulkClips(ctx, client.BulkPayload{
+		Operation:    bulkMoveToCollection,
+		IDs:          []string{created.ID},
+		CollectionID: collection.ID,
+	})
+	as.Require().NoError(err)
+	as.True(bulk.Applied)
+
+	read := true
+	updated, err := sdk.BulkUpdateClips(ctx, client.BulkUpdatePayload{IDs: []string{created.ID}, AddTags: []string{"contract"}, Read: &read})
+	as.Require().NoError(err)
+	as.True(updated.Applied)
+
+	list, err := sdk.ListClips(ctx, &client.ListClipsParams{Collection: collection.ID, Read: "true", PerPage: 1})
+	as.Require().NoError(err)
+	as.Len(list.Clips, 1)
+	as.Equal(collection.ID, list.Clips[0].CollectionID)
+	as.NotNil(list.Clips[0].ReadAt)
+
+	collections, err := sdk.ListCollections(ctx)
+	as.Require().NoError(err)
+	as.Len(collections.Collections, 1)
+
+	share, err := sdk.CreateShare(ctx, created.ID, client.SharePayload{ExpiresInHours: 24, MaxViews: 3})
+	as.Require().NoError(err)
+	as.Equal(3, share.MaxViews)
+	as.NotNil(share.ExpiresAt)
+	shares, err := sdk.ListShares(ctx, created.ID)
+	as.Require().NoError(err)
+	as.Len(shares.Shares, 1)
+	as.NoError(sdk.DeleteShare(ctx, share.ID))
+
+	as.NoError(sdk.DeleteClip(ctx, created.ID, nil))
+	as.NoError(sdk.DeleteCollection(ctx, collection.ID))
+}
+
+func (as *ActionSuite) Test_Contract_Errors() {
+	kit := testkit.New(as.T())
+	sdk := as.newContractClient(kit)
+	ctx := context.Background()
+
+	var apiErr *client.APIError
+
+	_, err := sdk.GetClip(ctx, "00000000-0000-0000-0000-000000000000")
+	as.True(errors.As(err, &apiErr))
+	as.Equal(http.StatusNotFound, apiErr.StatusCode)
+	as.Equal("clip not found", apiErr.Message)
+	as.Equal("not_found", apiErr.Code)
+	as.NotEmpty(apiErr.RequestID)
+
+	_, err = sdk.CreateClip(ctx, client.ClipPayload{URL: "https://example.com", Dedupe: "sometimes"}, nil)
+	as.True(errors.As(err, &apiErr))
+	as.Equal(http.StatusBadRequest, apiErr.StatusCode)
+
+	// A failed bulk item rolls everything back and reports per-item results
+	_, err = sdk.BulkClips(ctx, client.BulkPayload{Operation: bulkArchive, IDs: []string{"not-a-uuid"}})
+	as.True(errors.As(err, &apiErr))
+	as.Equal(http.StatusUnprocessableEntity, apiErr.StatusCode)
+
+	_, err = sdk.CreateCollection(ctx, client.CollectionPayload{Name: "Twice"})
+	as.NoError(err)
+	_, err = sdk.CreateCollection(ctx, client.CollectionPayload{Name: "Twice"})
+	as.True(errors.As(err, &apiErr))
+	as.Equal(http.StatusConflict, apiErr.StatusCode)
+}
+
+func (as *ActionSuite) Test_Contract_VersionsAndHighlights() {
+	kit := testkit.New(as.T())
+	sdk := as.newContractClient(kit)
+	ctx := context.Background()
+
+	created, err := sdk.CreateClip(ctx, client.ClipPayload{
+		Title:    "Annotated",
+		URL:      "https://example.com/annotated",
+		Markdown: "# Annotated\n\nA sentence worth keeping.",
+		Images:   []client.ImagePayload{},
+		Mode:     client.ClipModeArticle,
+	}, nil)
+	as.Require().NoError(err)
+
+	versions, err := sdk.ListClipVersions(ctx, created.ID)
+	as.Require().NoError(err)
+	as.Len(versions.Versions, 1)
+	as.True(versions.Versions[0].Current)
+
+	highlight, err := sdk.CreateHighlight(ctx, created.ID, client.HighlightPayload{Text: "worth keeping", Comment: "Why"})
+	as.Require().NoError(err)
+	as.Equal("yellow", highlight.Color)
+	highlight, err = sdk.UpdateHighlight(ctx, created.ID, highlight.ID, client.HighlightPayload{Text: "worth keeping", Color: "green"})
+	as.Require().NoError(err)
+	as.Equal("green", highlight.Color)
+	highlights, err := sdk.ListHighlights(ctx, created.ID)
+	as.Require().NoError(err)
+	as.Len(highlights.Highlights, 1)
+	as.NoError(sdk.DeleteHighlight(ctx, created.ID, highlight.ID))
+
+	var apiErr *client.APIError
+	_, err = sdk.RefreshToken(ctx, client.RefreshPayload{RefreshToken: "invalid"})
+	as.True(errors.As(err, &apiErr))
+	as.Equal(http.StatusUnauthorized, apiErr.StatusCode)
+	logout, err := sdk.Logout(ctx)
+	as.Require().NoError(err)
+	as.True(logout.Success)
+}
+
+func (as *ActionSuite) Test_Contract_UploadsAndPages() {
+	kit := testkit.New(as.T())
+	kit.Config.Uploads.MaxChunkBytes = 64
+	sdk := as.newContractClient(kit)
+	ctx := context.Background()
+
+	uploaded, err := sdk.UploadClip(ctx, client.ClipPayload{
+		Title:    "Uploaded",
+		URL:      "https://example.com/uploaded",
+		Markdown: "# Uploaded\n\n" + strings.Repeat("Sent in chunks. ", 20),
+		Images:   []client.ImagePayload{},
+		Mode:     client.ClipModeArticle,
+	})
+	as.Require().NoError(err)
+	as.True(uploaded.Success)
+
+	for i := 0; i < 2; i++ {
+		_, err := sdk.CreateClip(ctx, client.ClipPayload{
+			Title:  fmt.Sprintf("Paged %d", i),
+			URL:    fmt.Sprintf("https://example.com/paged/%d", i),
+			Images: []client.ImagePayload{},
+			Mode:   client.ClipModeBookmark,
+		}, nil)
+		as.Require().NoError(err)
+	}
+	var ids []string
+	for clip, err := range sdk.AllClips(ctx, &client.ListClipsParams{PerPage: 1}) {
+		as.Require().NoError(err)
+		ids = append(ids, clip.ID)
+	}
+	as.Len(ids, 3)
+	as.Contains(ids, uploaded.ID)
+}
+
+func (as *ActionSuite) Test_Contract_Config() {
+	kit := testkit.New(as.T())
+	sdk := as.newContractClient(kit)
+	ctx := context.Background()
+
+	cfg, err := sdk.GetConfig(ctx)
+	as.Require().NoError(err)
+	as.Equal(client.ClipModeArticle, cfg.DefaultFormat)
+
+	webp := false
+	cfg, err = sdk.UpdateConfig(ctx, client.ConfigPayload{
+		DefaultFormat: "bookmark",
+		DefaultTags:   []string{"inbox"},
+		Images:        &client.ImagesPreferences{ConvertToWebp: &webp},
+	})
+	as.Require().NoError(err)
+	as.Equal(client.ClipModeBookmark, cfg.DefaultFormat)
+	as.Equal([]string{"inbox"}, cfg.DefaultTags)
+	as.False(cfg.Images.ConvertToWebp)
+}
+
+func (as *ActionSuite) Test_Contract_Profile() {
+	kit := testkit.New(as.T())
+	sdk := as.newContractClient(kit)
+
+	profile, err := sdk.GetProfile(context.Background())
+	as.Require().NoError(err)
+	as.Equal("ok", profile.Storage.Status)
+	as.Equal("service_token", profile.Token.Type)
+}
+
+func (as *ActionSuite) Test_Contract_DeviceLogin() {
+	kit := testkit.New(as.T())
+	sdk := as.newContractClient(kit)
+
+	code, err := sdk.RequestDeviceCode(context.Background())
+	as.Require().NoError(err)
+	_, err = sdk.DeviceToken(context.Background(), client.DeviceTokenPayload{DeviceCode: code.DeviceCode})
+	var apiErr *client.APIError
+	as.Require().ErrorAs(err, &apiErr)
+	as.Equal("authorization_pending", apiErr.Code)
+}
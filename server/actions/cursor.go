@@ -0,0 +1,53 @@
+package actions
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// clipCursor is a keyset position in a listing ordered by created_at DESC, id DESC
+type clipCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// encodeClipCursor returns the opaque cursor for the position just after clip
+func encodeClipCursor(clip *models.Clip) string {
+	raw := clip.CreatedAt.Format(time.RFC3339Nano) + "_" + clip.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeClipCursor parses a cursor produced by encodeClipCursor
+func decodeClipCursor(s string) (clipCursor, error) {
+	invalid := fmt.Errorf("invalid cursor")
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return clipCursor{}, invalid
+	}
+	ts, id, ok := strings.Cut(string(raw), "_")
+	if !ok {
+		return clipCursor{}, invalid
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return clipCursor{}, invalid
+	}
+	clipID, err := uuid.FromString(id)
+	if err != nil {
+		return clipCursor{}, invalid
+	}
+	return clipCursor{CreatedAt: createdAt, ID: clipID}, nil
+}
+
+// where restricts a clips query to rows after the cursor
+func (cur clipCursor) where(q *pop.Query) *pop.Query {
+	return q.Where("(created_at < ? OR (created_at = ? AND id < ?))", cur.CreatedAt, cur.CreatedAt, cur.ID)
+}
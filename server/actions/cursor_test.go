@@ -0,0 +1,26 @@
+package actions
+
+import (
+	"time"
+
+	"server/models"
+
+	"github.com/gofrs/uuid"
+)
+
+func (as *ActionSuite) Test_ClipCursor_RoundTrip() {
+	clip := &models.Clip{
+		ID:        uuid.Must(uuid.NewV4()),
+		CreatedAt: time.Date(2026, 10, 16, 18, 30, 0, 123456000, time.UTC),
+	}
+
+	cursor, err := decodeClipCursor(encodeClipCursor(clip))
+	as.NoError(err)
+	as.Equal(clip.ID, cursor.ID)
+	as.True(clip.CreatedAt.Equal(cursor.CreatedAt))
+
+	for _, bad := range []string{"not base64!", "bm9wZQ", encodeClipCursor(&models.Clip{})[:10]} {
+		_, err := decodeClipCursor(bad)
+		as.Error(err, "decodeClipCursor(%q)", bad)
+	}
+}
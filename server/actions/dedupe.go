@@ -0,0 +1,194 @@
+package actions
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"server/internal/config"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Values accepted in ClipPayload.Dedupe
+const (
+	dedupeReject = "reject" // Default: answer 409 with the existing clip
+	dedupeMerge  = "merge"  // Replace the existing clip's current capture in place
+)
+
+// trackingParams are query parameters that don't change the page content
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true,
+	"mc_cid": true, "mc_eid": true, "igshid": true, "ref_src": true,
+}
+
+// normalizeURL reduces a URL to a canonical form for duplicate detection:
+// lowercase scheme and host, no default port, fragment, tracking parameters
+// or trailing slash, and sorted query parameters
+func normalizeURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return strings.TrimSpace(raw)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.User = nil
+
+	query := u.Query()
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") || trackingParams[strings.ToLower(key)] {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = query.Encode() // Encode sorts by key
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+
+	return u.String()
+}
+
+// isRecentDuplicate reports whether the clip was captured within the duplicate window
+func isRecentDuplicate(cfg *config.Config, clip *models.Clip) bool {
+	window := cfg.Clips.DuplicateWindowSeconds
+	if window < 0 {
+		return false
+	}
+	return time.Since(clip.UpdatedAt) < time.Duration(window)*time.Second
+}
+
+// mergeIntoClip replaces the clip's current capture with the new one without
+// recording a new version
+func mergeIntoClip(c buffalo.Context, tx *pop.Connection, clipDir string, clip *models.Clip, req ClipPayload, text clipText) error {
+	var fileName string
+	if clip.IsNote() {
+		if err := rewriteClipNote(clipDir, clip, req, text.Recognized); err != nil {
+			return clipError(c, http.StatusInternalServerError, err.Error())
+		}
+	} else {
+		// The new capture is written in staging and swapped in; the previous
+		// one is only deleted once the rows are committed
+		staging, err := newStagingDir(clipDir)
+		if err != nil {
+			return clipError(c, http.StatusInternalServerError, "Failed to create clip directory")
+		}
+		fileName, err = writeClipFiles(staging, req, text.Recognized)
+		if err != nil {
+			os.RemoveAll(staging)
+			return clipError(c, http.StatusInternalServerError, err.Error())
+		}
+		discard, undo, err := replaceCapture(staging, filepath.Join(clipDir, clip.Path))
+		if err != nil {
+			os.RemoveAll(staging)
+			c.Logger().Errorf("Failed to replace clip folder: %v", err)
+			return clipError(c, http.StatusInternalServerError, "Failed to replace existing clip")
+		}
+		onRollback(c, undo)
+		onCommit(c, discard)
+	}
+
+	clip.Title = req.Title
+	clip.Mode = req.Mode
+	clip.NormalizedURL = normalizeURL(req.URL)
+	clip.Domain = clipDomain(req.URL)
+	clip.Notes = nulls.NewString(req.Notes)
+	text.apply(clip)
+	applyLocation(clip, req)
+	applyMetadata(clip, req)
+	applyLanguage(clip, req)
+	if err := tx.Update(clip); err != nil {
+		c.Logger().Errorf("Failed to update clip metadata: %v", err)
+		return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+	}
+	if err := models.SetClipTags(tx, clip, req.Tags); err != nil {
+		c.Logger().Errorf("Failed to save clip tags: %v", err)
+		return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+	}
+	refreshClipSidecarOnCommit(c, tx, clip)
+
+	versions, err := clipVersions(tx, clip)
+	if err != nil {
+		c.Logger().Errorf("Failed to load clip versions: %v", err)
+		return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+	}
+	current := versions[len(versions)-1]
+	if current.ID != uuid.Nil {
+		current.Title = clip.Title
+		current.Mode = clip.Mode
+		if err := tx.Update(&current); err != nil {
+			c.Logger().Errorf("Failed to update clip version: %v", err)
+			return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+		}
+	}
+	if err := clipSaved(c, tx, clip, models.EventClipUpdated, auditClipUpdate); err != nil {
+		c.Logger().Errorf("Failed to record clip: %v", err)
+		return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+	}
+
+	return c.Render(http.StatusOK, r.JSON(ClipResponse{
+		Success: true,
+		Path:    filepath.Join(clip.Path, fileName),
+		ID:      clip.ID.String(),
+		Version: current.Version,
+	}))
+}
+
+// replaceCapture swaps a staged capture in for the current one of a clip
+// folder, moving the versions folder along. The previous capture is kept
+// next to it until discard deletes it; undo puts it back instead, and
+// removes the new one.
+func replaceCapture(staging, folderPath string) (discard, undo func(), err error) {
+	previous := staging + ".previous"
+	var steps []func()
+	rollback := func() {
+		for i := len(steps) - 1; i >= 0; i-- {
+			steps[i]()
+		}
+	}
+	defer func() {
+		if err != nil {
+			rollback()
+		}
+	}()
+
+	if _, statErr := os.Lstat(folderPath); statErr == nil {
+		versionsDir := filepath.Join(folderPath, versionsDirName)
+		stagedVersions := filepath.Join(staging, versionsDirName)
+		if _, statErr := os.Stat(versionsDir); statErr == nil {
+			if err = clipFS.Rename(versionsDir, stagedVersions); err != nil {
+				return nil, nil, err
+			}
+			steps = append(steps, func() { os.Rename(stagedVersions, versionsDir) })
+		}
+		if err = clipFS.Rename(folderPath, previous); err != nil {
+			return nil, nil, err
+		}
+		steps = append(steps, func() { os.Rename(previous, folderPath) })
+	}
+
+	if err = publishClipFolder(staging, folderPath); err != nil {
+		return nil, nil, err
+	}
+	steps = append(steps, func() { os.Rename(folderPath, staging) })
+
+	discard = func() { os.RemoveAll(previous) }
+	undo = func() {
+		rollback()
+		os.RemoveAll(staging)
+	}
+	return discard, undo, nil
+}
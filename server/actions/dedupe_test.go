@@ -0,0 +1,80 @@
+package actions
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"server/internal/config"
+	"server/models"
+)
+
+func (as *ActionSuite) Test_NormalizeURL() {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"https://Example.com/Article/", "https://example.com/Article"},
+		{"https://example.com:443/a#section", "https://example.com/a"},
+		{"http://example.com:8080/a", "http://example.com:8080/a"},
+		{"https://example.com/a?utm_source=x&b=2&a=1&fbclid=y", "https://example.com/a?a=1&b=2"},
+		{"https://example.com/", "https://example.com"},
+		{"not a url", "not a url"},
+	}
+
+	for _, tt := range tests {
+		as.Equal(tt.expected, normalizeURL(tt.input), "normalizeURL(%q)", tt.input)
+	}
+}
+
+func (as *ActionSuite) Test_IsRecentDuplicate() {
+	cfg := &config.Config{Clips: config.ClipsConfig{DuplicateWindowSeconds: 120}}
+
+	as.True(isRecentDuplicate(cfg, &models.Clip{UpdatedAt: time.Now().Add(-time.Minute)}))
+	as.False(isRecentDuplicate(cfg, &models.Clip{UpdatedAt: time.Now().Add(-time.Hour)}))
+
+	cfg.Clips.DuplicateWindowSeconds = -1
+	as.False(isRecentDuplicate(cfg, &models.Clip{UpdatedAt: time.Now()}))
+}
+
+func (as *ActionSuite) Test_ReplaceCapture() {
+	folderPath := filepath.Join(as.T().TempDir(), "clip")
+	as.NoError(os.MkdirAll(filepath.Join(folderPath, versionsDirName, "v1"), 0755))
+	as.NoError(os.WriteFile(filepath.Join(folderPath, "page.md"), []byte("# Old"), 0644))
+	as.NoError(os.WriteFile(filepath.Join(folderPath, "old.png"), []byte("png"), 0644))
+	read := func(path string) string {
+		data, err := os.ReadFile(filepath.Join(folderPath, path))
+		as.NoError(err)
+		return string(data)
+	}
+	stage := func(content string) string {
+		staging, err := os.MkdirTemp(filepath.Dir(folderPath), ".staging-")
+		as.Require().NoError(err)
+		as.NoError(os.WriteFile(filepath.Join(staging, "page.md"), []byte(content), 0644))
+		return staging
+	}
+
+	// A rolled-back merge puts the previous capture back
+	staging := stage("# New")
+	_, undo, err := replaceCapture(staging, folderPath)
+	as.Require().NoError(err)
+	as.Equal("# New", read("page.md"))
+	as.NoFileExists(filepath.Join(folderPath, "old.png"))
+	as.DirExists(filepath.Join(folderPath, versionsDirName, "v1"))
+	undo()
+	as.Equal("# Old", read("page.md"))
+	as.Equal("png", read("old.png"))
+	as.DirExists(filepath.Join(folderPath, versionsDirName, "v1"))
+	as.NoDirExists(staging)
+
+	// A committed one deletes it
+	staging = stage("# New")
+	discard, _, err := replaceCapture(staging, folderPath)
+	as.Require().NoError(err)
+	discard()
+	as.Equal("# New", read("page.md"))
+	as.DirExists(filepath.Join(folderPath, versionsDirName, "v1"))
+	entries, err := os.ReadDir(filepath.Dir(folderPath))
+	as.NoError(err)
+	as.Len(entries, 1, "only the clip folder is left")
+}
@@ -0,0 +1,95 @@
+//go:build !production
+
+package actions
+
+import (
+	"fmt"
+	"net/http"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+)
+
+// Development builds can run in dev mode (dev_mode.enabled), which bypasses
+// authentication and adds helper routes. Release builds use the production
+// tag, which compiles these routes out and ignores dev_mode (see
+// dev_routes_production.go).
+
+// devBuild tells whether this build honors dev_mode
+const devBuild = true
+
+// registerDevRoutes adds the dev mode helpers to the app and its auth group,
+// only when dev mode is enabled: they don't exist otherwise
+func registerDevRoutes(app, auth *buffalo.App) {
+	if !devModeEnabled(GetConfig()) {
+		return
+	}
+	app.GET("/docs", apiDocs)                  // Swagger UI over /openapi.json
+	auth.GET("/dev-token", authDevToken)       // Tokens for the dev user
+	auth.GET("/test-success", authTestSuccess) // Test success page rendering
+}
+
+// apiDocsPage runs Swagger UI (from the unpkg CDN) on /openapi.json. Get a
+// token from /auth/dev-token and "Authorize" with it to try requests.
+const apiDocsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>API - Web Clipper</title>
+    <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+    <div id="swagger-ui"></div>
+    <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+    <script>
+        window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+    </script>
+</body>
+</html>`
+
+// apiDocs renders the API's documentation
+func apiDocs(c buffalo.Context) error {
+	c.Response().Header().Set("Content-Type", "text/html; charset=utf-8")
+	c.Response().WriteHeader(http.StatusOK)
+	c.Response().Write([]byte(apiDocsPage))
+	return nil
+}
+
+// authDevToken provides JWT tokens for dev mode testing without OAuth
+func authDevToken(c buffalo.Context) error {
+	cfg := GetConfig()
+
+	// Reject if dev mode not enabled
+	if !devModeEnabled(cfg) {
+		return c.Error(http.StatusForbidden, fmt.Errorf("dev mode is not enabled"))
+	}
+
+	// Find or create dev user
+	tx := c.Value("tx").(*pop.Connection)
+	user, err := models.FindOrCreateByOAuthID(
+		tx,
+		cfg.DevMode.UserID,
+		cfg.DevMode.Email,
+		cfg.DevMode.Name,
+	)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	// Generate tokens
+	tokens, err := generateTokens(user)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	c.Logger().Warn("DEV MODE: Token generated for dev user")
+	return c.Render(http.StatusOK, r.JSON(tokens))
+}
+
+// authTestSuccess renders a test success page (for debugging)
+func authTestSuccess(c buffalo.Context) error {
+	return renderAuthSuccess(c, "You can close this window and return to the extension.")
+}
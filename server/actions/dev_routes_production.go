@@ -0,0 +1,14 @@
+//go:build production
+
+package actions
+
+import "github.com/gobuffalo/buffalo"
+
+// Production builds leave the dev mode routes out and ignore dev_mode, so a
+// stray DEV_MODE=true can't disable authentication on a release
+
+// devBuild tells whether this build honors dev_mode
+const devBuild = false
+
+// registerDevRoutes adds nothing: the dev routes aren't compiled in
+func registerDevRoutes(app, auth *buffalo.App) {}
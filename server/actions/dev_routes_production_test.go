@@ -0,0 +1,29 @@
+//go:build production
+
+package actions
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_DevRoutes_Production() {
+	kit := testkit.New(as.T())
+	kit.Config.DevMode.Enabled = true
+	kit.Config.DevMode.UserID = "dev-user-001"
+	kit.Config.DevMode.Email = "dev@localhost"
+	app := newKitApp(kit)
+
+	// dev_mode is ignored: no dev routes and no authentication bypass
+	for path, status := range map[string]int{
+		"/auth/dev-token":    http.StatusNotFound,
+		"/auth/test-success": http.StatusNotFound,
+		"/api/v1/clips":      http.StatusUnauthorized,
+	} {
+		res := httptest.NewRecorder()
+		app.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		as.Equal(status, res.Code, path)
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !production
+
+package actions
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_DevRoutes() {
+	get := func(kit *testkit.Kit, path string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		newKitApp(kit).ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		return res
+	}
+
+	kit := testkit.New(as.T())
+	as.Equal(http.StatusNotFound, get(kit, "/auth/dev-token").Code)
+	as.Equal(http.StatusNotFound, get(kit, "/auth/test-success").Code)
+	as.Equal(http.StatusUnauthorized, get(kit, "/api/v1/clips").Code)
+
+	kit.Config.DevMode.Enabled = true
+	kit.Config.DevMode.UserID = "dev-user-001"
+	kit.Config.DevMode.Email = "dev@localhost"
+	res := get(kit, "/auth/dev-token")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	as.Contains(res.Body.String(), "access_token")
+	as.Equal(http.StatusOK, get(kit, "/auth/test-success").Code)
+	as.Equal(http.StatusOK, get(kit, "/api/v1/clips").Code)
+}
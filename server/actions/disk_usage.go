@@ -0,0 +1,210 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Disk usage is measured by walking each clip's folder (or note and its
+// attachments), including trashed clips, and cached per user in
+// storage_usages for storage.usage_cache_minutes, as walking a large
+// library takes a while. Media hard-linked by storage.dedupe_media count for
+// every clip linking them.
+
+// ModeUsage is the storage taken by the clips of one mode
+type ModeUsage struct {
+	Clips int   `json:"clips"`
+	Bytes int64 `json:"bytes"`
+}
+
+// DiskUsage is the storage taken by a user's clips
+type DiskUsage struct {
+	Email        string               `json:"email"`
+	Clips        int                  `json:"clips"` // Not trashed
+	Bytes        int64                `json:"bytes"`
+	TrashedClips int                  `json:"trashed_clips"`
+	TrashedBytes int64                `json:"trashed_bytes"`
+	Modes        map[string]ModeUsage `json:"modes"` // Clips not trashed, by mode
+	ComputedAt   time.Time            `json:"computed_at"`
+}
+
+// UserDiskUsage returns the storage taken by the user's clips: the cached
+// figures while they are recent enough, or new ones measured on disk with
+// refresh or once they are stale
+func UserDiskUsage(tx *pop.Connection, user *models.User, refresh bool) (*DiskUsage, error) {
+	maxAge := time.Duration(GetConfig().Storage.UsageCacheMinutes) * time.Minute
+	if !refresh && maxAge > 0 {
+		cached, err := models.FindStorageUsage(tx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if cached != nil && time.Since(cached.ComputedAt) < maxAge {
+			usage := &DiskUsage{
+				Email:        user.Email,
+				Clips:        cached.Clips,
+				Bytes:        cached.Bytes,
+				TrashedClips: cached.TrashedClips,
+				TrashedBytes: cached.TrashedBytes,
+				ComputedAt:   cached.ComputedAt,
+			}
+			if err := json.Unmarshal([]byte(cached.Modes), &usage.Modes); err != nil {
+				return nil, err
+			}
+			return usage, nil
+		}
+	}
+
+	usage, err := measureDiskUsage(tx, user)
+	if err != nil {
+		return nil, err
+	}
+	modes, err := json.Marshal(usage.Modes)
+	if err != nil {
+		return nil, err
+	}
+	err = models.SaveStorageUsage(tx, &models.StorageUsage{
+		UserID:       user.ID,
+		Clips:        usage.Clips,
+		Bytes:        usage.Bytes,
+		TrashedClips: usage.TrashedClips,
+		TrashedBytes: usage.TrashedBytes,
+		Modes:        string(modes),
+		ComputedAt:   usage.ComputedAt,
+	})
+	return usage, err
+}
+
+// DiskUsages returns the storage taken by the clips of one user, or of every
+// user without an email, largest first
+func DiskUsages(tx *pop.Connection, email string, refresh bool) ([]DiskUsage, error) {
+	users := models.Users{}
+	q := tx.Order("email ASC")
+	if email != "" {
+		q = q.Where("email = ?", email)
+	}
+	if err := q.All(&users); err != nil {
+		return nil, err
+	}
+	if email != "" && len(users) == 0 {
+		return nil, fmt.Errorf("user not found: %s", email)
+	}
+
+	usages := make([]DiskUsage, 0, len(users))
+	for i := range users {
+		usage, err := UserDiskUsage(tx, &users[i], refresh)
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure the storage of %s: %w", users[i].Email, err)
+		}
+		usages = append(usages, *usage)
+	}
+	sort.SliceStable(usages, func(i, j int) bool {
+		return usages[i].Bytes+usages[i].TrashedBytes > usages[j].Bytes+usages[j].TrashedBytes
+	})
+	return usages, nil
+}
+
+// measureDiskUsage walks the files of every clip of the user
+func measureDiskUsage(tx *pop.Connection, user *models.User) (*DiskUsage, error) {
+	clips := models.Clips{}
+	if err := tx.Where("user_id = ?", user.ID).All(&clips); err != nil {
+		return nil, err
+	}
+
+	cfg := GetConfig()
+	usage := &DiskUsage{Email: user.Email, Modes: map[string]ModeUsage{}, ComputedAt: time.Now().UTC()}
+	for i := range clips {
+		clip := &clips[i]
+		root := clipRoot(cfg, user, clip)
+		p := filepath.Join(root, clip.Path)
+		if clip.DeletedAt.Valid {
+			p = filepath.Join(root, trashDirName, clip.Path)
+		}
+		size, err := pathSize(p)
+		if err != nil {
+			return nil, err
+		}
+		// A note's attachments stay in the vault while it is in the trash
+		if clip.IsNote() {
+			attachments, err := noteAttachmentPaths(filepath.Join(root, clip.Path), clip.ID)
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range attachments {
+				n, err := pathSize(a)
+				if err != nil {
+					return nil, err
+				}
+				size += n
+			}
+		}
+
+		if clip.DeletedAt.Valid {
+			usage.TrashedClips++
+			usage.TrashedBytes += size
+			continue
+		}
+		usage.Clips++
+		usage.Bytes += size
+		mode := usage.Modes[clip.Mode]
+		mode.Clips++
+		mode.Bytes += size
+		usage.Modes[clip.Mode] = mode
+	}
+	return usage, nil
+}
+
+// pathSize returns the size of a file, or of the regular files under a
+// directory (0 when missing)
+func pathSize(p string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(p, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return size, err
+}
+
+// getDiskUsage returns the storage taken by the user's clips (refresh=true
+// measures it again rather than using the cached figures)
+func getDiskUsage(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, userID); err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+	usage, err := UserDiskUsage(tx, user, c.Param("refresh") == "true")
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(usage))
+}
@@ -0,0 +1,52 @@
+package actions
+
+import (
+	"net/http"
+
+	"server/internal/testkit"
+	"server/models"
+)
+
+func (as *ActionSuite) Test_DiskUsage() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+	kit.CreateUser() // No clips
+
+	kit.CreateClip(user, testkit.WithMedia("hero.png", make([]byte, 1000)))
+	kit.CreateClip(user, testkit.WithClip(func(c *models.Clip) { c.Mode = "bookmark" }))
+	trashed := kit.CreateClip(user, testkit.WithMedia("big.png", make([]byte, 5000)))
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/clips/"+trashed.ID.String()).Code)
+
+	get := func(path string) DiskUsage {
+		res := client.Get(path)
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		var usage DiskUsage
+		res.JSON(&usage)
+		return usage
+	}
+	usage := get("/api/v1/me/usage")
+	as.Equal(user.Email, usage.Email)
+	as.Equal(2, usage.Clips)
+	as.Equal(1, usage.TrashedClips)
+	as.Greater(usage.TrashedBytes, int64(5000))
+	as.Len(usage.Modes, 2)
+	as.Equal(1, usage.Modes["article"].Clips)
+	as.Greater(usage.Modes["article"].Bytes, int64(1000))
+	as.Equal(usage.Bytes, usage.Modes["article"].Bytes+usage.Modes["bookmark"].Bytes)
+
+	// Cached until refreshed
+	kit.CreateClip(user)
+	as.Equal(2, get("/api/v1/me/usage").Clips)
+	as.Equal(3, get("/api/v1/me/usage?refresh=true").Clips)
+	as.Equal(3, get("/api/v1/me/usage").Clips)
+
+	// Largest first, every user
+	usages, err := DiskUsages(kit.DB, "", false)
+	as.NoError(err)
+	as.Len(usages, 2)
+	as.Equal(user.Email, usages[0].Email)
+	as.Zero(usages[1].Bytes)
+	_, err = DiskUsages(kit.DB, "nobody@example.com", false)
+	as.Error(err)
+}
@@ -0,0 +1,74 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+)
+
+// DryRunResponse describes what a destructive request would have changed
+type DryRunResponse struct {
+	DryRun  bool     `json:"dry_run"`
+	Changes []string `json:"changes"`
+}
+
+// isDryRun reports whether the request asked for dry_run=true
+func isDryRun(c buffalo.Context) bool {
+	switch c.Param("dry_run") {
+	case "true", "1":
+		return true
+	}
+	return false
+}
+
+// renderDryRun renders the planned changes without applying them
+func renderDryRun(c buffalo.Context, changes []string) error {
+	if changes == nil {
+		changes = []string{}
+	}
+	return c.Render(http.StatusOK, r.JSON(DryRunResponse{DryRun: true, Changes: changes}))
+}
+
+// trashChanges describes moving a clip to the trash
+func trashChanges(clip *models.Clip, deleteFiles bool) []string {
+	var changes []string
+	if deleteFiles {
+		changes = append(changes, fmt.Sprintf("move %s to %s", clip.Path, filepath.Join(trashDirName, clip.Path)))
+	}
+	return append(changes, fmt.Sprintf("move clip %s (%s) to the trash", clip.ID, clip.Title))
+}
+
+// purgeChanges describes permanently deleting a trashed clip
+func purgeChanges(clip *models.Clip) []string {
+	return []string{
+		fmt.Sprintf("delete %s", filepath.Join(trashDirName, clip.Path)),
+		fmt.Sprintf("permanently delete clip %s (%s) with its versions, highlights and tags", clip.ID, clip.Title),
+	}
+}
+
+// saveChanges describes what saving a clip payload would do
+func saveChanges(tx *pop.Connection, existing *models.Clip, req ClipPayload) ([]string, error) {
+	switch {
+	case existing == nil:
+		return []string{fmt.Sprintf("create clip %q for %s", req.Title, req.URL)}, nil
+	case isRecentDuplicate(GetConfig(), existing):
+		// Only reached with dedupe=merge; other recent duplicates are rejected
+		return []string{fmt.Sprintf("replace the capture of clip %s in %s", existing.ID, existing.Path)}, nil
+	}
+
+	versions, err := clipVersions(tx, existing)
+	if err != nil {
+		return nil, err
+	}
+	current := versions[len(versions)-1].Version
+	return []string{
+		fmt.Sprintf("archive version %d of clip %s to %s", current, existing.ID,
+			filepath.Join(existing.Path, versionsDirName, fmt.Sprintf("v%d", current))),
+		fmt.Sprintf("save the new capture as version %d", current+1),
+	}, nil
+}
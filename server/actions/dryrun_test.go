@@ -0,0 +1,25 @@
+package actions
+
+import (
+	"net/http"
+
+	"server/models"
+
+	"github.com/gofrs/uuid"
+)
+
+func (as *ActionSuite) Test_DeleteClip_DryRun_Unauthorized() {
+	res := as.JSON("/api/v1/clips/550e8400-e29b-41d4-a716-446655440000?dry_run=true").Delete()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_TrashChanges() {
+	clip := &models.Clip{ID: uuid.Must(uuid.NewV4()), Title: "Article", Path: "web-clips/20260101_120000_example-com"}
+
+	changes := trashChanges(clip, true)
+	as.Len(changes, 2)
+	as.Contains(changes[0], ".trash/web-clips/20260101_120000_example-com")
+
+	as.Len(trashChanges(clip, false), 1)
+	as.Len(purgeChanges(clip), 2)
+}
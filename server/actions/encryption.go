@@ -0,0 +1,134 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"server/internal/atrest"
+	"server/internal/config"
+
+	"github.com/gobuffalo/buffalo"
+)
+
+// With storage.encryption.enabled, clip files (pages, notes, media,
+// sidecars) are encrypted by writeFileSync and decrypted by readClipFile,
+// openClipFile and serveClipFile. Files are told apart by their header, so
+// plaintext written before encryption was enabled, or after it was disabled,
+// stays readable; clips are not re-encrypted in bulk. Derived and public
+// copies (mirror, share pages, exports) are decrypted, replicas and backups
+// are copied encrypted.
+
+// storageKey is the encryption key, for the settings it was loaded from
+var storageKey struct {
+	mu     sync.Mutex
+	loaded bool
+	conf   config.EncryptionConfig
+	key    *atrest.Key
+	err    error
+}
+
+// clipKey returns the configured storage key, nil without one. Enabled
+// encryption without a key is an error, so files are never written in
+// plaintext by mistake.
+func clipKey() (*atrest.Key, error) {
+	storageKey.mu.Lock()
+	defer storageKey.mu.Unlock()
+	var conf config.EncryptionConfig
+	if cfg := GetConfig(); cfg != nil {
+		conf = cfg.Storage.Encryption
+	}
+	if storageKey.loaded && storageKey.conf == conf {
+		return storageKey.key, storageKey.err
+	}
+
+	key, err := atrest.LoadKey(conf.Key, conf.KeyFile)
+	if err == nil && key == nil && conf.Enabled {
+		err = fmt.Errorf("storage.encryption.enabled needs storage.encryption.key or key_file")
+	}
+	storageKey.loaded, storageKey.conf, storageKey.key, storageKey.err = true, conf, key, err
+	return key, err
+}
+
+// sealClipData returns data as it is to be stored: encrypted when storage
+// encryption is enabled
+func sealClipData(data []byte) ([]byte, error) {
+	key, err := clipKey()
+	if err != nil {
+		return nil, err
+	}
+	if !GetConfig().Storage.Encryption.Enabled {
+		return data, nil
+	}
+	return key.Encrypt(data)
+}
+
+// readClipFile returns the content of a clip file, decrypted and
+// decompressed
+func readClipFile(path string) ([]byte, error) {
+	r, err := openClipFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// openClipFile opens a clip file for reading its content, decrypted and
+// decompressed
+func openClipFile(path string) (io.ReadCloser, error) {
+	key, err := clipKey()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := key.Reader(f)
+	if err == nil {
+		r, err = decompressClipReader(path, r)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, f}, nil
+}
+
+// serveClipFile serves a clip file like http.ServeFile, decrypting or
+// decompressing it in memory when it is stored encrypted or compressed
+func serveClipFile(c buffalo.Context, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("file not found"))
+	}
+	defer f.Close()
+	head := make([]byte, 64)
+	n, _ := io.ReadFull(f, head)
+	if !atrest.IsEncrypted(head[:n]) && !isCompressedClipFile(path, head[:n]) {
+		http.ServeFile(c.Response(), c.Request(), path)
+		return nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	data, err := readClipFile(path)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	http.ServeContent(c.Response(), c.Request(), name, info.ModTime(), bytes.NewReader(data))
+	return nil
+}
@@ -0,0 +1,76 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"server/internal/atrest"
+	"server/internal/config"
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_EncryptedStorage() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	old := kit.CreateClip(user, testkit.WithContent("Written before encryption"))
+	secret, _, err := atrest.GenerateKey()
+	as.NoError(err)
+	kit.Config.Storage.Encryption.Enabled = true
+	kit.Config.Storage.Encryption.Key = secret
+	client := kit.Client(newKitApp(kit), user)
+
+	var buf bytes.Buffer
+	as.NoError(png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 10, 10))))
+	var created ClipResponse
+	res := client.Post("/api/v1/clips", ClipPayload{
+		Title:    "Private",
+		URL:      "https://example.com/private",
+		Mode:     "article",
+		Markdown: "Confidential text ![](media/hero.png)",
+		Images:   []ImagePayload{{Filename: "hero.png", Data: base64.StdEncoding.EncodeToString(buf.Bytes())}},
+	})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&created)
+
+	// Pages and media are encrypted on disk
+	folder := filepath.Join(kit.StorageRoot, filepath.Dir(created.Path))
+	pages, _ := filepath.Glob(filepath.Join(folder, "*.md"))
+	as.NotEmpty(pages)
+	for _, p := range append(pages, filepath.Join(folder, "media", "hero.png")) {
+		data, err := os.ReadFile(p)
+		as.NoError(err)
+		as.True(atrest.IsEncrypted(data), p)
+		as.NotContains(string(data), "Confidential")
+	}
+
+	// and read back decrypted, like the clips written in plaintext before
+	var detail ClipDetail
+	res = client.Get("/api/v1/clips/" + created.ID)
+	as.Equal(http.StatusOK, res.Code)
+	res.JSON(&detail)
+	as.Contains(detail.Content, "Confidential text")
+	res = client.Get("/api/v1/clips/" + created.ID + "/media/hero.png")
+	as.Equal(http.StatusOK, res.Code)
+	as.Equal(buf.Bytes(), res.Body.Bytes())
+
+	detail = ClipDetail{}
+	client.Get("/api/v1/clips/" + old.ID.String()).JSON(&detail)
+	as.Contains(detail.Content, "Written before encryption")
+
+	matches, err := GrepClips(kit.DB, user.Email, ClipFilter{Query: "private", Archived: "all"})
+	as.NoError(err)
+	as.NotEmpty(matches)
+	as.True(strings.HasPrefix(matches[0].Path, folder))
+	as.Empty(matches[0].Field) // Found in the decrypted page
+
+	// Encrypted files can't be read without the key
+	kit.Config.Storage.Encryption = config.EncryptionConfig{}
+	res = client.Get("/api/v1/clips/" + created.ID + "/media/hero.png")
+	as.Equal(http.StatusInternalServerError, res.Code)
+}
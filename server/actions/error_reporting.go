@@ -0,0 +1,115 @@
+package actions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"server/internal/sentry"
+
+	"github.com/gobuffalo/buffalo"
+)
+
+// sensitiveHeaders are left out of reported requests
+var sensitiveHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"X-Api-Key":     true,
+}
+
+// newErrorReporter returns the client of error_reporting.dsn, or nil when
+// none is set (or it is invalid: the server starts without reporting)
+func newErrorReporter() *sentry.Client {
+	cfg := GetConfig()
+	if cfg == nil || cfg.Errors.DSN == "" {
+		return nil
+	}
+	env := cfg.Errors.Environment
+	if env == "" {
+		env = ENV
+	}
+	client, err := sentry.New(cfg.Errors.DSN, env)
+	if err != nil {
+		log.Printf("Warning: error reporting disabled: %v", err)
+		return nil
+	}
+	return client
+}
+
+// errorReportingMiddleware sends the panics (before Buffalo recovers them)
+// and the 5xx responses of requests to the error reporter, with the
+// request, its route and user. Reports are sent in the background.
+func errorReportingMiddleware(client *sentry.Client) buffalo.MiddlewareFunc {
+	return func(next buffalo.Handler) buffalo.Handler {
+		return func(c buffalo.Context) error {
+			defer func() {
+				if r := recover(); r != nil {
+					reportError(c, client, "panic", fmt.Sprint(r), sentry.PanicStacktrace("server"))
+					panic(r)
+				}
+			}()
+
+			err := next(c)
+			if err != nil {
+				status := http.StatusInternalServerError // As Buffalo answers
+				var httpErr buffalo.HTTPError
+				if errors.As(err, &httpErr) {
+					status = httpErr.Status
+				} else if errors.Is(err, sql.ErrNoRows) {
+					status = http.StatusNotFound
+				}
+				if status >= 500 {
+					reportError(c, client, statusType(status), err.Error(), nil)
+				}
+			} else if res, ok := c.Response().(*buffalo.Response); ok && res.Status >= 500 {
+				// Handlers rendering their own 5xx have logged the cause
+				reportError(c, client, statusType(res.Status), "rendered by the handler", nil)
+			}
+			return err
+		}
+	}
+}
+
+// statusType is the exception type of an error response, which Sentry
+// groups events by with the message
+func statusType(status int) string {
+	return fmt.Sprintf("%d %s", status, http.StatusText(status))
+}
+
+// reportError sends an error of the request. Query strings are left out,
+// as some carry tokens.
+func reportError(c buffalo.Context, client *sentry.Client, typ, value string, stack *sentry.Stacktrace) {
+	req := c.Request()
+	event := &sentry.Event{
+		Transaction: req.Method + " " + req.URL.Path,
+		Exception:   []sentry.Exception{{Type: typ, Value: value, Stacktrace: stack}},
+		Request:     &sentry.Request{URL: strings.TrimSuffix(GetConfig().Server.BaseURL, "/") + req.URL.Path, Method: req.Method, Headers: map[string]string{}},
+		User:        &sentry.User{IPAddress: requestIP(c)},
+		Tags:        map[string]string{"request_id": requestID(c)},
+	}
+	if route, ok := c.Value("current_route").(buffalo.RouteInfo); ok {
+		event.Transaction = route.Method + " " + path.Clean(route.Path) // Without Buffalo's trailing slash
+	}
+	if userID, ok := c.Value("user_id").(string); ok {
+		event.User.ID = userID
+	}
+	if host, err := os.Hostname(); err == nil {
+		event.ServerName = host
+	}
+	for name := range req.Header {
+		if !sensitiveHeaders[name] {
+			event.Request.Headers[name] = req.Header.Get(name)
+		}
+	}
+	goBackground(func() {
+		if err := client.Capture(context.Background(), event); err != nil {
+			log.Printf("Failed to report error: %v", err)
+		}
+	})
+}
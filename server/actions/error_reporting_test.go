@@ -0,0 +1,64 @@
+package actions
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"server/internal/sentry"
+	"server/internal/testkit"
+
+	"github.com/gobuffalo/buffalo"
+)
+
+func (as *ActionSuite) Test_ErrorReporting() {
+	var mu sync.Mutex
+	var events []sentry.Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event sentry.Event
+		json.NewDecoder(r.Body).Decode(&event)
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	kit.Config.Server.BaseURL = "https://clipper.example.com"
+	kit.Config.Errors.DSN = strings.Replace(srv.URL, "http://", "http://key@", 1) + "/1"
+	kit.Config.Errors.Environment = "staging"
+	app := newKitApp(kit)
+	app.GET("/boom", func(c buffalo.Context) error { panic("boom") })
+	app.GET("/fail", func(c buffalo.Context) error { return errors.New("database is locked") })
+	app.GET("/missing", func(c buffalo.Context) error { return c.Error(http.StatusNotFound, errors.New("no such clip")) })
+	client := kit.Client(app, user)
+
+	as.Equal(http.StatusInternalServerError, client.Get("/boom?token=secret").Code)
+	as.Equal(http.StatusInternalServerError, client.Get("/fail").Code)
+	as.Equal(http.StatusNotFound, client.Get("/missing").Code)
+	background.Wait()
+
+	as.Len(events, 2) // 4xx are not reported
+	byType := map[string]sentry.Event{}
+	for _, e := range events {
+		byType[e.Exception[0].Type] = e
+	}
+	panicked := byType["panic"]
+	as.Equal("boom", panicked.Exception[0].Value)
+	as.Equal("GET /boom", panicked.Transaction)
+	as.True(strings.HasPrefix(panicked.Request.URL, "https://clipper.example.com/boom"), panicked.Request.URL)
+	as.NotContains(panicked.Request.URL, "secret")
+	as.NotContains(panicked.Request.Headers, "Authorization")
+	as.Equal("192.0.2.1", panicked.User.IPAddress)
+	frames := panicked.Exception[0].Stacktrace.Frames
+	as.True(frames[len(frames)-1].InApp)
+	as.Contains(frames[len(frames)-1].Function, "Test_ErrorReporting")
+
+	failed := byType["500 Internal Server Error"]
+	as.Equal("database is locked", failed.Exception[0].Value)
+	as.Equal("staging", failed.Environment)
+}
@@ -0,0 +1,131 @@
+package actions
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gofrs/uuid"
+)
+
+// requestIDHeader carries the request's ID in responses, and in requests
+// from a trusted proxy (logging.trust_proxy) that assigned one
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID is what an ID from a proxy may look like; others are
+// replaced, as they end up in logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Error *ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes an error. Code is a machine-readable reason: the
+// status' (not_found, bad_request...) or a more specific one, like the
+// reason a clip was rejected for. RequestID is the one in the logs and
+// error reports.
+type ErrorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// requestIDMiddleware gives each request an ID, set in the response's
+// X-Request-ID, in its log line and in the errors it answers with
+func requestIDMiddleware(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		setRequestID(c)
+		return next(c)
+	}
+}
+
+// requestID returns the ID of the request, giving it one if it has none
+// yet (requests no route matched skip middleware)
+func requestID(c buffalo.Context) string {
+	if id := c.Response().Header().Get(requestIDHeader); id != "" {
+		return id
+	}
+	return setRequestID(c)
+}
+
+// setRequestID gives the request the ID its trusted proxy sent, else a new
+// one. It replaces Buffalo's, which is per session.
+func setRequestID(c buffalo.Context) string {
+	id := ""
+	if cfg := GetConfig(); cfg != nil && cfg.Logging.TrustProxy {
+		if forwarded := c.Request().Header.Get(requestIDHeader); validRequestID.MatchString(forwarded) {
+			id = forwarded
+		}
+	}
+	if id == "" {
+		id = uuid.Must(uuid.NewV4()).String()
+	}
+	c.Set("request_id", id)
+	c.LogField("request_id", id)
+	c.Response().Header().Set(requestIDHeader, id)
+	return id
+}
+
+// renderError answers with the error envelope. code defaults to the
+// status'.
+func renderError(c buffalo.Context, status int, code, message string) error {
+	return c.Render(status, r.JSON(ErrorResponse{Error: newErrorDetail(c, status, code, message)}))
+}
+
+// newErrorDetail describes an error of the request
+func newErrorDetail(c buffalo.Context, status int, code, message string) *ErrorDetail {
+	if code == "" {
+		code = errorCode(status)
+	}
+	return &ErrorDetail{Code: code, Message: message, RequestID: requestID(c)}
+}
+
+// errorCode is the code of a status: its text in snake case
+func errorCode(status int) string {
+	code := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r
+		case r == ' ' || r == '-':
+			return '_'
+		}
+		return -1
+	}, strings.ToLower(http.StatusText(status)))
+	if code == "" {
+		return "error"
+	}
+	return code
+}
+
+// errorHandler answers the errors handlers return (c.Error), the panics
+// and the requests no route matches with the error envelope, whatever the
+// request's content type. The messages of 5xx errors are only logged
+// outside development.
+func errorHandler(status int, err error, c buffalo.Context) error {
+	c.LogField("status", status)
+	message := err.Error()
+	if status >= http.StatusInternalServerError {
+		c.Logger().Error(err)
+		if ENV != "development" {
+			message = http.StatusText(status)
+		}
+	} else {
+		c.Logger().Warn(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			message = "not found"
+		}
+	}
+	return renderError(c, status, "", message)
+}
+
+// useErrorEnvelope makes app answer with the error envelope and give
+// requests an ID
+func useErrorEnvelope(app *buffalo.App) {
+	app.ErrorHandlers = buffalo.ErrorHandlers{}
+	app.ErrorHandlers.Default(errorHandler)
+	app.Use(requestIDMiddleware)
+}
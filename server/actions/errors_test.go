@@ -0,0 +1,73 @@
+package actions
+
+import (
+	"net/http"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_ErrorEnvelope() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+
+	// Errors handlers return
+	var resp ErrorResponse
+	res := client.Get("/api/v1/clips/00000000-0000-0000-0000-000000000000")
+	as.Equal(http.StatusNotFound, res.Code)
+	as.Contains(res.Header().Get("Content-Type"), "application/json")
+	res.JSON(&resp)
+	as.Require().NotNil(resp.Error)
+	as.Equal("not_found", resp.Error.Code)
+	as.Equal("clip not found", resp.Error.Message)
+	as.NotEmpty(resp.Error.RequestID)
+	as.Equal(res.Header().Get(requestIDHeader), resp.Error.RequestID)
+
+	// Requests no route matches, whatever they accept
+	resp = ErrorResponse{}
+	client.Header = http.Header{"Accept": {"text/html"}}
+	res = client.Get("/api/v1/nowhere")
+	as.Equal(http.StatusNotFound, res.Code)
+	res.JSON(&resp)
+	as.Require().NotNil(resp.Error)
+	as.Equal("not_found", resp.Error.Code)
+	as.Equal(res.Header().Get(requestIDHeader), resp.Error.RequestID)
+	client.Header = nil
+
+	// Rejected clips keep their ClipResponse, with the reason as code
+	var clip ClipResponse
+	res = client.Post("/api/v1/clips", ClipPayload{Title: "T", URL: "https://example.com", Dedupe: "sometimes"})
+	as.Equal(http.StatusBadRequest, res.Code)
+	res.JSON(&clip)
+	as.False(clip.Success)
+	as.Require().NotNil(clip.Error)
+	as.Equal(rejectInvalidPayload, clip.Error.Code)
+	as.Equal(res.Header().Get(requestIDHeader), clip.Error.RequestID)
+}
+
+func (as *ActionSuite) Test_RequestID() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+
+	first := client.Get("/api/v1/clips").Header().Get(requestIDHeader)
+	as.NotEmpty(first)
+	as.NotEqual(first, client.Get("/api/v1/clips").Header().Get(requestIDHeader))
+
+	// The ID of a trusted proxy is kept when it looks like one
+	client.Header = http.Header{"X-Request-Id": {"proxy-42"}}
+	as.NotEqual("proxy-42", client.Get("/api/v1/clips").Header().Get(requestIDHeader))
+	kit.Config.Logging.TrustProxy = true
+	as.Equal("proxy-42", client.Get("/api/v1/clips").Header().Get(requestIDHeader))
+	client.Header = http.Header{"X-Request-Id": {"no spaces\nor newlines"}}
+	as.NotEqual("no spaces\nor newlines", client.Get("/api/v1/clips").Header().Get(requestIDHeader))
+
+	// Audit events carry it
+	events := captureAudit()
+	client.Header = http.Header{"X-Request-Id": {"proxy-43"}}
+	client.Token = "forged"
+	client.Get("/api/v1/clips")
+	got := events()
+	as.Len(got, 1)
+	as.Equal("proxy-43", got[0]["request_id"])
+}
@@ -0,0 +1,312 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Domain changes are recorded as events in the same transaction as the
+// change (the outbox pattern). Once committed, they are handed to webhooks
+// and picked up by event streams; a periodic sweep catches what a crash
+// interrupted, so events are delivered at least once.
+
+// eventSweepInterval is how often undispatched events and lost webhook
+// deliveries are picked up again
+const eventSweepInterval = time.Minute
+
+// Event stream timings: streams look for events written by other processes
+// every eventPollInterval and send a comment every eventHeartbeat so proxies
+// keep the connection open
+const (
+	eventPollInterval = 5 * time.Second
+	eventHeartbeat    = 30 * time.Second
+)
+
+// maxEventBatch bounds the events loaded at once
+const maxEventBatch = 100
+
+// dispatchMu keeps concurrent dispatches from queueing an event twice
+var dispatchMu sync.Mutex
+
+// background tracks dispatches and deliveries that run after their request,
+// so tests can wait for them before closing their database
+var background sync.WaitGroup
+
+// goBackground runs fn in a goroutine tracked by background
+func goBackground(fn func()) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		fn()
+	}()
+}
+
+// eventStreams wakes up open event streams when events are committed
+var eventStreams = &eventSignal{}
+
+// eventSignal is a broadcast: wait returns a channel closed by the next notify
+type eventSignal struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func (s *eventSignal) wait() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ch == nil {
+		s.ch = make(chan struct{})
+	}
+	return s.ch
+}
+
+func (s *eventSignal) notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ch != nil {
+		close(s.ch)
+		s.ch = nil
+	}
+}
+
+// recordEvent records a domain change in the request's transaction. Once the
+// transaction is committed, the event is dispatched to the user's webhooks
+// and event streams.
+func recordEvent(c buffalo.Context, tx *pop.Connection, userID uuid.UUID, eventType string, data interface{}) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	event := &models.Event{
+		UserID: userID,
+		Type:   eventType,
+		Data:   string(body),
+	}
+	if err := tx.Create(event); err != nil {
+		return err
+	}
+
+	db := appDB(c)
+	onCommit(c, func() {
+		eventStreams.notify()
+		goBackground(func() { dispatchEvents(db) })
+	})
+	return nil
+}
+
+// eventEnvelope is how an event is sent to webhooks and streams. ID
+// identifies the event, so receivers can drop duplicates.
+func eventEnvelope(e *models.Event) webhookEvent {
+	return webhookEvent{
+		ID:        strconv.FormatInt(e.ID, 10),
+		Event:     e.Type,
+		CreatedAt: e.CreatedAt.UTC(),
+		Data:      json.RawMessage(e.Data),
+	}
+}
+
+// dispatchEvents queues webhook deliveries for every undispatched event and
+// sends them
+func dispatchEvents(db *pop.Connection) {
+	dispatchMu.Lock()
+	defer dispatchMu.Unlock()
+
+	for {
+		events, err := models.FindUndispatchedEvents(db, maxEventBatch)
+		if err != nil {
+			log.Printf("events: failed to load undispatched events: %v", err)
+			return
+		}
+		if len(events) == 0 {
+			return
+		}
+
+		for i := range events {
+			deliveries, err := queueEventDeliveries(db, &events[i])
+			if err != nil {
+				log.Printf("events: failed to dispatch event %d: %v", events[i].ID, err)
+				return // Retried by the next sweep
+			}
+			for _, id := range deliveries {
+				id := id
+				goBackground(func() { runWebhookDelivery(db, id) })
+			}
+		}
+	}
+}
+
+// queueEventDeliveries creates a pending delivery for each webhook subscribed
+// to the event and marks it dispatched, in one transaction
+func queueEventDeliveries(db *pop.Connection, event *models.Event) ([]uuid.UUID, error) {
+	var queued []uuid.UUID
+	err := db.Transaction(func(tx *pop.Connection) error {
+		webhooks, err := models.FindWebhooksByUserID(tx, event.UserID)
+		if err != nil {
+			return err
+		}
+
+		body, err := json.Marshal(eventEnvelope(event))
+		if err != nil {
+			return err
+		}
+
+		for i := range webhooks {
+			if !webhooks[i].Subscribes(event.Type) {
+				continue
+			}
+			delivery := &models.WebhookDelivery{
+				ID:        uuid.Must(uuid.NewV4()),
+				WebhookID: webhooks[i].ID,
+				Event:     event.Type,
+				Payload:   string(body),
+				EventID:   nulls.NewInt64(event.ID),
+				Status:    models.WebhookDeliveryPending,
+			}
+			if err := tx.Create(delivery); err != nil {
+				return err
+			}
+			queued = append(queued, delivery.ID)
+		}
+
+		event.DispatchedAt = nulls.NewTime(time.Now())
+		return tx.Update(event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return queued, nil
+}
+
+// resendLostDeliveries sends again deliveries left pending longer than an
+// attempt can take, whose sender was stopped (typically by a restart)
+func resendLostDeliveries(db *pop.Connection) {
+	timeout := time.Duration(GetConfig().Webhooks.TimeoutSeconds) * time.Second
+	deliveries, err := models.FindStaleWebhookDeliveries(db, time.Now().Add(-timeout-eventSweepInterval))
+	if err != nil {
+		log.Printf("events: failed to load lost deliveries: %v", err)
+		return
+	}
+
+	for i := range deliveries {
+		// Touch it so the next sweep leaves it to this attempt
+		if err := db.Update(&deliveries[i]); err != nil {
+			log.Printf("webhook delivery %s: failed to update: %v", deliveries[i].ID, err)
+			continue
+		}
+		id := deliveries[i].ID
+		goBackground(func() { runWebhookDelivery(db, id) })
+	}
+}
+
+// StartEventDispatcher dispatches events left undispatched and resends lost
+// webhook deliveries at startup and then every minute, until the context is
+// cancelled
+func StartEventDispatcher(ctx context.Context) {
+	if GetConfig() == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(eventSweepInterval)
+		defer ticker.Stop()
+		for {
+			dispatchEvents(models.DB)
+			resendLostDeliveries(models.DB)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// streamEvents streams the user's events as server-sent events. Clients
+// resume after the last event they saw with the Last-Event-ID header (sent
+// by EventSource when it reconnects) or ?after=; otherwise only new events
+// are sent. It runs outside a request transaction, which would hold the
+// database for as long as the stream is open.
+func streamEvents(c buffalo.Context) error {
+	db := appDB(c)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	after := c.Request().Header.Get("Last-Event-ID")
+	if after == "" {
+		after = c.Param("after")
+	}
+	var lastID int64
+	if after != "" {
+		lastID, err = strconv.ParseInt(after, 10, 64)
+		if err != nil || lastID < 0 {
+			return c.Error(http.StatusBadRequest, fmt.Errorf("invalid event ID: %s", after))
+		}
+	} else if lastID, err = models.LatestEventID(db, userID); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	res := c.Response()
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		return c.Error(http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+	}
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("X-Accel-Buffering", "no") // Don't let nginx buffer the stream
+	res.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ctx := c.Request().Context()
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		wake := eventStreams.wait()
+
+		events, err := models.FindUserEventsAfter(db, userID, lastID, maxEventBatch)
+		if err != nil {
+			log.Printf("events: stream for user %s failed: %v", userID, err)
+			return nil
+		}
+		for i := range events {
+			data, err := json.Marshal(eventEnvelope(&events[i]))
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", events[i].ID, events[i].Type, data)
+			lastID = events[i].ID
+		}
+		if len(events) > 0 {
+			flusher.Flush()
+		}
+		if len(events) == maxEventBatch {
+			continue // More to catch up on
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wake:
+		case <-time.After(eventPollInterval):
+		case <-heartbeat.C:
+			fmt.Fprint(res, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
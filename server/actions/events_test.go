@@ -0,0 +1,159 @@
+package actions
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/gofrs/uuid"
+)
+
+func (as *ActionSuite) Test_Events_RecordedWithClip() {
+	kit := testkit.New(as.T())
+	kit.Config.Webhooks.AllowPrivate = true // Receivers are httptest servers
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+	_, url := newWebhookReceiver(as)
+
+	var hook WebhookResponse
+	client.Post("/api/v1/webhooks", WebhookPayload{URL: url}).JSON(&hook)
+
+	var clip ClipResponse
+	res := client.Post("/api/v1/clips", ClipPayload{Title: "Evented", URL: "https://example.com/evented", Mode: "article"})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+
+	events := models.Events{}
+	as.NoError(kit.DB.All(&events))
+	as.Len(events, 1)
+	as.Equal(models.EventClipCreated, events[0].Type)
+	as.Contains(events[0].Data, clip.ID)
+
+	delivery := as.waitForDelivery(kit, hook, models.WebhookDeliverySucceeded)
+	as.Equal(events[0].ID, delivery.EventID.Int64)
+	var body webhookEvent
+	as.NoError(json.Unmarshal([]byte(delivery.Payload), &body))
+	as.Equal("1", body.ID)
+
+	as.NoError(kit.DB.Reload(&events[0]))
+	as.True(events[0].DispatchedAt.Valid)
+}
+
+func (as *ActionSuite) Test_Events_SweepAfterCrash() {
+	kit := testkit.New(as.T())
+	kit.Config.Webhooks.AllowPrivate = true // Receivers are httptest servers
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+	rec, url := newWebhookReceiver(as)
+
+	var hook WebhookResponse
+	client.Post("/api/v1/webhooks", WebhookPayload{URL: url}).JSON(&hook)
+
+	// Committed, but the process stopped before dispatching it
+	event := &models.Event{UserID: user.ID, Type: models.EventClipCreated, Data: `{"id":"abc"}`}
+	as.NoError(kit.DB.Create(event))
+	dispatchEvents(kit.DB)
+	delivery := as.waitForDelivery(kit, hook, models.WebhookDeliverySucceeded)
+	as.Equal(event.ID, delivery.EventID.Int64)
+
+	// Dispatched, but the process stopped before sending it
+	lost := &models.WebhookDelivery{
+		ID:        uuid.Must(uuid.NewV4()),
+		WebhookID: uuid.FromStringOrNil(hook.ID),
+		Event:     models.EventClipCreated,
+		Payload:   `{"id":"lost"}`,
+		Status:    models.WebhookDeliveryPending,
+	}
+	as.NoError(kit.DB.Create(lost))
+	as.NoError(kit.DB.RawQuery("UPDATE webhook_deliveries SET updated_at = ? WHERE id = ?", time.Now().Add(-time.Hour), lost.ID).Exec())
+	resendLostDeliveries(kit.DB)
+
+	as.Eventually(func() bool {
+		d := &models.WebhookDelivery{}
+		return kit.DB.Find(d, lost.ID) == nil && d.Status == models.WebhookDeliverySucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+	rec.mu.Lock()
+	as.Len(rec.bodies, 2)
+	rec.mu.Unlock()
+
+	// Nothing is dispatched twice
+	dispatchEvents(kit.DB)
+	count, err := kit.DB.Where("webhook_id = ?", hook.ID).Count(&models.WebhookDelivery{})
+	as.NoError(err)
+	as.Equal(2, count)
+}
+
+// readEvents reads server-sent events from an event stream until it has n
+func readEvents(scanner *bufio.Scanner, n int) []map[string]string {
+	var events []map[string]string
+	event := map[string]string{}
+	for len(events) < n && scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(event) > 0 {
+				events = append(events, event)
+			}
+			event = map[string]string{}
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ": "); ok && !strings.HasPrefix(line, ":") {
+			event[key] = value
+		}
+	}
+	return events
+}
+
+func (as *ActionSuite) Test_Events_Stream() {
+	kit := testkit.New(as.T())
+	kit.Config.Webhooks.AllowPrivate = true // Receivers are httptest servers
+	user := kit.CreateUser()
+	app := newKitApp(kit)
+	client := kit.Client(app, user)
+	srv := httptest.NewServer(app)
+	as.T().Cleanup(srv.Close)
+
+	res := client.Post("/api/v1/clips", ClipPayload{Title: "Before", URL: "https://example.com/before", Mode: "article"})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+
+	open := func(header, value string) *bufio.Scanner {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		as.T().Cleanup(cancel)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/events", nil)
+		req.Header.Set("Authorization", "Bearer "+kit.CreateToken(user))
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		as.Require().NoError(err)
+		as.T().Cleanup(func() { resp.Body.Close() })
+		as.Require().Equal(http.StatusOK, resp.StatusCode)
+		as.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+		return bufio.NewScanner(resp.Body)
+	}
+
+	// Resuming replays what was missed
+	events := readEvents(open("Last-Event-ID", "0"), 1)
+	as.Len(events, 1)
+	as.Equal("1", events[0]["id"])
+	as.Equal(models.EventClipCreated, events[0]["event"])
+	as.Contains(events[0]["data"], `"title":"Before"`)
+
+	// A new stream only gets new events, as soon as they are committed
+	stream := open("", "")
+	res = client.Post("/api/v1/clips", ClipPayload{Title: "After", URL: "https://example.com/after", Mode: "article"})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	events = readEvents(stream, 1)
+	as.Len(events, 1)
+	as.Equal("2", events[0]["id"])
+	as.Contains(events[0]["data"], `"title":"After"`)
+
+	res = client.Get("/api/v1/events?after=bogus")
+	as.Equal(http.StatusBadRequest, res.Code)
+}
@@ -0,0 +1,120 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"server/internal/epub"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// exportClip returns a clip as a downloadable file (format=epub or markdown).
+// toc=true|false adds a table of contents or not (default markdown.toc.enabled).
+func exportClip(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	clipID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid clip ID"))
+	}
+
+	// Fetch clip with ownership check
+	clip, err := models.FindClipByIDAndUser(tx, clipID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("clip not found"))
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, userID); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	folderPath := filepath.Join(clipRoot(GetConfig(), user, clip), clip.Path)
+
+	highlights, err := models.FindHighlightsByClip(tx, clip.ID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	slug := slugify(clip.Title)
+	if slug == "" {
+		slug = "clip"
+	}
+
+	toc := tocParam(c.Param("toc"))
+	switch format := c.Param("format"); format {
+	case "", "epub":
+		data, err := buildClipEPUB(clip, folderPath, highlights, toc)
+		if err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+		c.Response().Header().Set("Content-Type", "application/epub+zip")
+		c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", slug+".epub"))
+		c.Response().WriteHeader(http.StatusOK)
+		_, err = c.Response().Write(data)
+		return err
+	case "markdown":
+		content, err := readClipMarkdown(folderPath)
+		if err != nil {
+			return c.Error(http.StatusNotFound, fmt.Errorf("clip content not found"))
+		}
+		c.Response().Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", slug+".md"))
+		c.Response().WriteHeader(http.StatusOK)
+		if body := stripFrontmatter(content); body != content {
+			content = strings.TrimSuffix(content, body) + withTOC(body, toc)
+		} else {
+			content = withTOC(content, toc)
+		}
+		_, err = c.Response().Write([]byte(content + highlightsMarkdown(highlights)))
+		return err
+	default:
+		return c.Error(http.StatusBadRequest, fmt.Errorf("unsupported export format: %s", format))
+	}
+}
+
+// buildClipEPUB renders a clip's markdown, highlights and media into an EPUB
+// document, with a table of contents when toc (or markdown.toc.enabled) says
+func buildClipEPUB(clip *models.Clip, folderPath string, highlights models.Highlights, toc *bool) ([]byte, error) {
+	content, err := readClipMarkdown(folderPath)
+	if err != nil {
+		return nil, fmt.Errorf("clip content not found: %w", err)
+	}
+
+	body := []byte(renderMarkdown(withTOC(stripFrontmatter(content), toc) + highlightsMarkdown(highlights)))
+
+	// Embed media so relative image references keep working
+	var resources []epub.Resource
+	mediaPath, _ := clipMediaDir(clip, folderPath)
+	if names, err := clipMediaFiles(clip, folderPath); err == nil {
+		for _, name := range names {
+			data, err := readClipFile(filepath.Join(mediaPath, name))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read media %s: %w", name, err)
+			}
+			resources = append(resources, epub.Resource{
+				Filename: "media/" + name,
+				Data:     data,
+			})
+		}
+	}
+
+	return epub.Build(epub.Book{
+		Identifier: clip.ID.String(),
+		Title:      clip.Title,
+		Author:     clipAuthor(clip),
+		Source:     clip.URL,
+		BodyHTML:   string(body),
+		Resources:  resources,
+	})
+}
@@ -0,0 +1,23 @@
+package actions
+
+import "net/http"
+
+func (as *ActionSuite) Test_ExportClip_Unauthorized() {
+	res := as.JSON("/api/v1/clips/550e8400-e29b-41d4-a716-446655440000/export?format=epub").Get()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_StripFrontmatterFunction() {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"---\ntitle: \"x\"\n---\n\n# Body\n", "# Body\n"},
+		{"# No frontmatter\n", "# No frontmatter\n"},
+		{"---\nunterminated\n# Body\n", "---\nunterminated\n# Body\n"},
+	}
+
+	for _, tt := range tests {
+		as.Equal(tt.expected, stripFrontmatter(tt.input), "stripFrontmatter(%q)", tt.input)
+	}
+}
@@ -0,0 +1,208 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"server/internal/config"
+	"server/internal/imaging"
+	"server/internal/safehttp"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// With favicons.enabled, a job fetches the icon of each new clip's site into
+// a cache shared by the user's clips of the same domain:
+// web-clips/.favicons/<domain>.<format>. The clip row records the file name,
+// and the icon is served at /api/v1/clips/{id}/favicon.
+
+// faviconDir is the favicon cache, next to the clip folders
+const faviconDir = ".favicons"
+
+// faviconTypes are the icon formats kept, with their content type. SVG is
+// left out as it can carry scripts.
+var faviconTypes = map[string]string{
+	imaging.FormatICO:  "image/x-icon",
+	imaging.FormatPNG:  "image/png",
+	imaging.FormatGIF:  "image/gif",
+	imaging.FormatJPEG: "image/jpeg",
+	imaging.FormatWebP: "image/webp",
+}
+
+// fetchFaviconArgs is the payload of a favicon job
+type fetchFaviconArgs struct {
+	ClipID uuid.UUID `json:"clip_id"`
+	URL    string    `json:"url,omitempty"` // Icon the page declared, tried before /favicon.ico
+}
+
+// fetchFaviconResult is the result of a favicon job
+type fetchFaviconResult struct {
+	ClipID  string `json:"clip_id"`
+	Favicon string `json:"favicon,omitempty"` // Empty when the site has none
+	Cached  bool   `json:"cached"`
+}
+
+// faviconCache returns the user's favicon cache
+func faviconCache(cfg *config.Config, user *models.User) string {
+	return filepath.Join(userClipDir(cfg, user), "web-clips", faviconDir)
+}
+
+// cachedFavicon returns the name of the cached icon of a domain, or "" when
+// there is none fetched after since
+func cachedFavicon(cache, domain string, since time.Time) string {
+	for format := range faviconTypes {
+		name := domain + "." + format
+		if info, err := os.Stat(filepath.Join(cache, name)); err == nil && info.ModTime().After(since) {
+			return name
+		}
+	}
+	return ""
+}
+
+// faviconCandidates lists the URLs tried for a page's icon: the one it
+// declared, then /favicon.ico on its host
+func faviconCandidates(declared, pageURL string) []string {
+	var candidates []string
+	if u, err := url.Parse(declared); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		candidates = append(candidates, declared)
+	}
+	if u, err := url.Parse(pageURL); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		candidates = append(candidates, u.Scheme+"://"+u.Host+"/favicon.ico")
+	}
+	return candidates
+}
+
+// fetchFaviconJob sets a clip's favicon, fetching it unless the domain's
+// icon is cached already. A site without a usable icon is not an error.
+func fetchFaviconJob(ctx context.Context, db *pop.Connection, job *models.Job) (interface{}, error) {
+	var args fetchFaviconArgs
+	if err := json.Unmarshal([]byte(job.Payload), &args); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	clip := &models.Clip{}
+	if err := db.Where("id = ? AND user_id = ?", args.ClipID, job.UserID).First(clip); err != nil {
+		return nil, fmt.Errorf("clip %s: %w", args.ClipID, err)
+	}
+	user := &models.User{}
+	if err := db.Find(user, job.UserID); err != nil {
+		return nil, err
+	}
+
+	result := fetchFaviconResult{ClipID: clip.ID.String()}
+	domain := sanitizeFilename(clip.Domain)
+	if domain == "" {
+		return result, nil
+	}
+
+	cfg := GetConfig()
+	cache := faviconCache(cfg, user)
+	maxAge := time.Duration(cfg.Favicons.MaxAgeDays) * 24 * time.Hour
+	result.Favicon = cachedFavicon(cache, domain, time.Now().Add(-maxAge))
+	result.Cached = result.Favicon != ""
+	if !result.Cached {
+		name, err := fetchFavicon(ctx, cfg, user, cache, domain, faviconCandidates(args.URL, clip.URL))
+		if err != nil {
+			return nil, err
+		}
+		result.Favicon = name
+	}
+
+	if result.Favicon != "" {
+		if err := db.RawQuery("UPDATE clips SET favicon = ? WHERE id = ?", result.Favicon, clip.ID).Exec(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// fetchFavicon downloads the first usable icon among candidates into the
+// cache and returns its name, or "" when there is none. Only failures to
+// write the cache are errors.
+func fetchFavicon(ctx context.Context, cfg *config.Config, user *models.User, cache, domain string, candidates []string) (string, error) {
+	spool, err := newStagingDir(userClipDir(cfg, user))
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(spool)
+
+	client := safehttp.NewClient(time.Duration(cfg.Favicons.TimeoutSeconds)*time.Second, cfg.Images.FetchAllowPrivate)
+	for i, candidate := range candidates {
+		icon := ImagePayload{Filename: candidate, file: filepath.Join(spool, fmt.Sprintf("favicon-%d", i))}
+		if _, err := fetchImage(ctx, client, candidate, icon.file, cfg.Favicons.MaxBytes); err != nil {
+			log.Printf("favicon %s: %v", candidate, err)
+			continue
+		}
+		head, err := icon.head(imaging.SniffLen)
+		if err != nil {
+			return "", err
+		}
+		format := imaging.Sniff(head)
+		if _, ok := faviconTypes[format]; !ok {
+			log.Printf("favicon %s: not an icon (%s)", candidate, http.DetectContentType(head))
+			continue
+		}
+
+		if err := clipFS.MkdirAll(cache, 0755); err != nil {
+			return "", err
+		}
+		name := domain + "." + format
+		if err := clipFS.Rename(icon.file, filepath.Join(cache, name)); err != nil {
+			return "", err
+		}
+		// An icon changing format leaves the previous one behind
+		for other := range faviconTypes {
+			if other != format {
+				os.Remove(filepath.Join(cache, domain+"."+other))
+			}
+		}
+		return name, nil
+	}
+	return "", nil
+}
+
+// getClipFavicon serves the icon of a clip's site
+func getClipFavicon(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userID, err := uuid.FromString(c.Value("user_id").(string))
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+	clipID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid clip ID"))
+	}
+
+	clip, err := models.FindClipByIDAndUser(tx, clipID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("clip not found"))
+	}
+	if !clip.Favicon.Valid || clip.Favicon.String != filepath.Base(clip.Favicon.String) {
+		return c.Error(http.StatusNotFound, fmt.Errorf("no favicon for this clip"))
+	}
+	user := &models.User{}
+	if err := tx.Find(user, userID); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	path := filepath.Join(faviconCache(GetConfig(), user), clip.Favicon.String)
+	if _, err := os.Stat(path); err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("no favicon for this clip"))
+	}
+	format := strings.TrimPrefix(filepath.Ext(path), ".")
+	c.Response().Header().Set("Content-Type", faviconTypes[format])
+	c.Response().Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeFile(c.Response(), c.Request(), path)
+	return nil
+}
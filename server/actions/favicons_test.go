@@ -0,0 +1,70 @@
+package actions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+
+	"server/internal/testkit"
+	"server/models"
+)
+
+func (as *ActionSuite) Test_ClipFavicon() {
+	var fetches int32
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		switch r.URL.Path {
+		case "/favicon.ico":
+			w.Write([]byte(pngMagic + "icon"))
+		case "/declared.svg":
+			w.Write([]byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer site.Close()
+	iconless := httptest.NewServer(http.NotFoundHandler())
+	defer iconless.Close()
+
+	kit := testkit.New(as.T())
+	kit.Config.Favicons.Enabled = true
+	kit.Config.Images.FetchAllowPrivate = true // The test servers listen on loopback
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+	save := func(url string) ClipSummary {
+		var clip ClipResponse
+		res := client.Post("/api/v1/clips", ClipPayload{Title: "Page", URL: url, Mode: "bookmark", FaviconURL: site.URL + "/declared.svg"})
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		res.JSON(&clip)
+		runQueuedJobs(kit.DB)
+
+		var detail ClipSummary
+		res = client.Get("/api/v1/clips/" + clip.ID)
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		res.JSON(&detail)
+		return detail
+	}
+
+	// The declared SVG is refused, /favicon.ico is used instead
+	first := save(site.URL + "/one")
+	as.Equal("/api/v1/clips/"+first.ID+"/favicon", first.FaviconURL)
+	as.Equal(int32(2), atomic.LoadInt32(&fetches))
+	res := client.Get(first.FaviconURL)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	as.Equal("image/png", res.Header().Get("Content-Type"))
+	as.Equal(pngMagic+"icon", res.Body.String())
+
+	// Other clips of the domain share the cached icon
+	second := save(site.URL + "/two")
+	as.NotEmpty(second.FaviconURL)
+	as.Equal(int32(2), atomic.LoadInt32(&fetches))
+
+	// A site without an icon is not an error
+	none := save(strings.Replace(iconless.URL, "127.0.0.1", "localhost", 1) + "/page")
+	as.Empty(none.FaviconURL)
+	job := &models.Job{}
+	as.NoError(kit.DB.Where("type = ? AND payload LIKE ?", models.JobFetchFavicon, "%"+none.ID+"%").First(job))
+	as.Equal(models.JobSucceeded, job.Status)
+	res = client.Get("/api/v1/clips/" + none.ID + "/favicon")
+	as.Equal(http.StatusNotFound, res.Code)
+}
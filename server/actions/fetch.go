@@ -0,0 +1,75 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"server/internal/extract"
+	"server/internal/safehttp"
+
+	"github.com/gobuffalo/buffalo"
+)
+
+// FetchClipPayload is the request body for POST /api/v1/clips/fetch
+type FetchClipPayload struct {
+	URL   string   `json:"url"`
+	Title string   `json:"title,omitempty"` // Overrides the extracted title
+	Mode  string   `json:"mode,omitempty"`  // article (default) or bookmark
+	Tags  []string `json:"tags"`
+	Notes string   `json:"notes"`
+}
+
+// fetchClip downloads a page on the server, extracts its content and saves it
+// as a clip. Used by clients that can't run the browser extension (e.g. the CLI).
+func fetchClip(c buffalo.Context) error {
+	var req FetchClipPayload
+	if err := c.Bind(&req); err != nil {
+		return clipError(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	mode := req.Mode
+	if mode == "" {
+		mode = "article"
+	}
+	if mode != "article" && mode != "bookmark" {
+		return clipError(c, http.StatusBadRequest, fmt.Sprintf("Unsupported mode for server-side fetch: %s", mode))
+	}
+
+	cfg := GetConfig()
+	if cfg == nil {
+		return clipError(c, http.StatusInternalServerError, "Configuration not loaded")
+	}
+
+	// The page is saved where the user can read it: internal addresses are refused
+	fetcher := extract.NewFetcher(safehttp.NewClient(time.Duration(cfg.Clips.FetchTimeoutSeconds)*time.Second, cfg.Images.FetchAllowPrivate))
+	page, err := fetcher.Fetch(c.Request().Context(), strings.TrimSpace(req.URL))
+	if err != nil {
+		c.Logger().Warnf("Server-side fetch failed: %v", err)
+		return clipError(c, http.StatusBadGateway, err.Error())
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = page.Title
+	}
+	if title == "" {
+		title = extractDomain(page.URL)
+	}
+
+	markdown := page.Markdown
+	if mode == "bookmark" {
+		markdown = fmt.Sprintf("# %s\n\n[%s](%s)\n", title, title, page.URL)
+	}
+
+	return saveClip(c, ClipPayload{
+		Title:    title,
+		URL:      page.URL,
+		Markdown: markdown,
+		Tags:     req.Tags,
+		Notes:    req.Notes,
+		Mode:     mode,
+		meta:     page.Meta,
+	})
+}
@@ -0,0 +1,34 @@
+package actions
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_FetchClip_Unauthorized() {
+	res := as.JSON("/api/v1/clips/fetch").Post(FetchClipPayload{URL: "https://example.com"})
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_FetchClip_RefusesInternalAddresses() {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Internal</title></head><body><p>secret</p></body></html>"))
+	}))
+	defer srv.Close()
+
+	kit := testkit.New(as.T())
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	res := client.Post("/api/v1/clips/fetch", FetchClipPayload{URL: srv.URL})
+	as.Equal(http.StatusBadGateway, res.Code, res.Body.String())
+	as.Contains(res.Body.String(), "address not allowed")
+	as.NotContains(res.Body.String(), "secret")
+
+	// Development setups may allow them
+	kit.Config.Images.FetchAllowPrivate = true
+	res = client.Post("/api/v1/clips/fetch", FetchClipPayload{URL: srv.URL})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+}
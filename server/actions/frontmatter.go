@@ -0,0 +1,169 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"server/internal/extract"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+)
+
+// Frontmatter templates replace the built-in frontmatter layout (see
+// generateFrontmatter) to match an existing vault's conventions. A user's
+// template takes precedence over clips.frontmatter_template, set by the
+// admin. Templates are Go text/templates of the YAML between the ---
+// delimiters, executed with a frontmatterData.
+
+// frontmatterData is what frontmatter templates are executed with
+type frontmatterData struct {
+	Title     string
+	URL       string
+	Domain    string
+	Mode      string
+	Tags      []string
+	Notes     string
+	Date      time.Time // When the clip was saved
+	Lang      string
+	Latitude  *float64
+	Longitude *float64
+	Place     string
+	Meta      extract.Metadata // Page metadata: Description, Author, Published, SiteName, Image, Type
+}
+
+// frontmatterFuncs are the functions available to frontmatter templates
+var frontmatterFuncs = template.FuncMap{
+	"quote": strconv.Quote,
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"slug":  slugify,
+}
+
+// parseFrontmatterTemplate parses a frontmatter template and checks it runs
+func parseFrontmatterTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("frontmatter").Funcs(frontmatterFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	sample := frontmatterData{Title: "Title", URL: "https://example.com/", Domain: "example.com", Mode: "article", Tags: []string{"tag"}, Date: time.Now()}
+	if err := tmpl.Execute(&strings.Builder{}, sample); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// userFrontmatterTemplate returns the frontmatter template in effect for
+// user, or "" for the built-in layout
+func userFrontmatterTemplate(user *models.User) string {
+	if user.FrontmatterTemplate.Valid {
+		return user.FrontmatterTemplate.String
+	}
+	return GetConfig().Clips.FrontmatterTemplate
+}
+
+// renderFrontmatterTemplate executes a frontmatter template for req
+func renderFrontmatterTemplate(text string, req ClipPayload) (string, error) {
+	tmpl, err := parseFrontmatterTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	mode := req.Mode
+	if mode == "" {
+		mode = "article"
+	}
+	data := frontmatterData{
+		Title:     req.Title,
+		URL:       req.URL,
+		Domain:    extractDomain(req.URL),
+		Mode:      mode,
+		Tags:      req.Tags,
+		Notes:     req.Notes,
+		Date:      time.Now(),
+		Lang:      req.lang,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Place:     req.Place,
+		Meta:      req.meta,
+	}
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(sb.String(), "\n") {
+		sb.WriteString("\n")
+	}
+	sb.WriteString("---\n")
+	return sb.String(), nil
+}
+
+// FrontmatterTemplatePayload is the request body for setting a user's
+// frontmatter template
+type FrontmatterTemplatePayload struct {
+	Template string `json:"template"` // Empty restores the default
+}
+
+// FrontmatterTemplateResponse describes the frontmatter template in effect
+type FrontmatterTemplateResponse struct {
+	Template string `json:"template,omitempty"` // Empty for the built-in layout
+	Source   string `json:"source"`             // user, config or builtin
+	Preview  string `json:"preview"`            // Frontmatter of an example clip
+}
+
+// getFrontmatterTemplate returns the user's frontmatter template
+func getFrontmatterTemplate(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	user := &models.User{}
+	if err := tx.Find(user, c.Value("user_id")); err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+	return c.Render(http.StatusOK, r.JSON(frontmatterTemplateResponse(user)))
+}
+
+// updateFrontmatterTemplate sets or clears the user's frontmatter template
+func updateFrontmatterTemplate(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	user := &models.User{}
+	if err := tx.Find(user, c.Value("user_id")); err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	var req FrontmatterTemplatePayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+	user.FrontmatterTemplate = nulls.String{}
+	if strings.TrimSpace(req.Template) != "" {
+		if _, err := parseFrontmatterTemplate(req.Template); err != nil {
+			return c.Error(http.StatusUnprocessableEntity, fmt.Errorf("invalid template: %w", err))
+		}
+		user.FrontmatterTemplate = nulls.NewString(req.Template)
+	}
+	if err := tx.UpdateColumns(user, "frontmatter_template", "updated_at"); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(frontmatterTemplateResponse(user)))
+}
+
+func frontmatterTemplateResponse(user *models.User) FrontmatterTemplateResponse {
+	resp := FrontmatterTemplateResponse{
+		Template: userFrontmatterTemplate(user),
+		Source:   templateSource(user.FrontmatterTemplate, GetConfig().Clips.FrontmatterTemplate),
+	}
+	example := ClipPayload{
+		Title:       "Example Domain",
+		URL:         "https://example.com/",
+		Mode:        "article",
+		Tags:        []string{"example"},
+		frontmatter: resp.Template,
+	}
+	resp.Preview = generateFrontmatter(example)
+	return resp
+}
@@ -0,0 +1,51 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
+)
+
+const zettelTemplate = `id: {{.Date.Format "20060102150405"}}
+title: {{quote .Title}}
+source: {{.URL}}
+tags: [{{join .Tags ", "}}]
+{{- with .Meta.Author}}
+author: {{quote .}}{{end}}`
+
+func (as *ActionSuite) Test_FrontmatterTemplate() {
+	kit := testkit.New(as.T())
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	var tmpl FrontmatterTemplateResponse
+	client.Get("/api/v1/templates/frontmatter").JSON(&tmpl)
+	as.Equal("builtin", tmpl.Source)
+	as.Contains(tmpl.Preview, "clipped_at: ")
+
+	as.Equal(http.StatusUnprocessableEntity, client.Put("/api/v1/templates/frontmatter", FrontmatterTemplatePayload{Template: "title: {{.Title"}).Code)
+	as.Equal(http.StatusUnprocessableEntity, client.Put("/api/v1/templates/frontmatter", FrontmatterTemplatePayload{Template: "title: {{.Heading}}"}).Code)
+
+	res := client.Put("/api/v1/templates/frontmatter", FrontmatterTemplatePayload{Template: zettelTemplate})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&tmpl)
+	as.Equal("user", tmpl.Source)
+	as.Contains(tmpl.Preview, "tags: [example]\n---\n")
+
+	var clip ClipResponse
+	res = client.Post("/api/v1/clips", ClipPayload{Title: "Zettel", URL: "https://example.com/z", Mode: "article", Markdown: "# Body", Tags: []string{"a", "b"}})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+	data, err := os.ReadFile(filepath.Join(kit.StorageRoot, clip.Path))
+	as.NoError(err)
+	as.Regexp(`^---\nid: \d{14}\ntitle: "Zettel"\nsource: https://example.com/z\ntags: \[a, b\]\n---\n\n# Body`, string(data))
+
+	// Clearing it falls back to the admin's template
+	kit.Config.Clips.FrontmatterTemplate = "title: {{.Title}}"
+	res = client.Put("/api/v1/templates/frontmatter", FrontmatterTemplatePayload{})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&tmpl)
+	as.Equal("config", tmpl.Source)
+	as.Equal("---\ntitle: Example Domain\n---\n", tmpl.Preview)
+}
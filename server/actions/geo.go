@@ -0,0 +1,162 @@
+package actions
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+)
+
+// defaultRadiusKm is the search radius used by ?near= when radius_km is omitted
+const defaultRadiusKm = 10.0
+
+// kmPerDegree is the approximate length of one degree of latitude
+const kmPerDegree = 111.32
+
+// ClipLocation is where a clip was captured
+type ClipLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Place     string  `json:"place,omitempty"`
+}
+
+// validateLocation checks that coordinates are given together and within range
+func validateLocation(req ClipPayload) error {
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		return fmt.Errorf("latitude and longitude must be provided together")
+	}
+	if req.Latitude == nil {
+		return nil
+	}
+	if *req.Latitude < -90 || *req.Latitude > 90 {
+		return fmt.Errorf("latitude must be between -90 and 90")
+	}
+	if *req.Longitude < -180 || *req.Longitude > 180 {
+		return fmt.Errorf("longitude must be between -180 and 180")
+	}
+	return nil
+}
+
+// applyLocation stores the payload's location on the clip, keeping any
+// existing location when the payload has none
+func applyLocation(clip *models.Clip, req ClipPayload) {
+	if req.Latitude != nil && req.Longitude != nil {
+		clip.Latitude = nulls.NewFloat64(*req.Latitude)
+		clip.Longitude = nulls.NewFloat64(*req.Longitude)
+	}
+	if req.Place != "" {
+		clip.Place = nulls.NewString(req.Place)
+	}
+}
+
+// clipLocation returns the clip's location, or nil when it has none
+func clipLocation(clip *models.Clip) *ClipLocation {
+	if !clip.Latitude.Valid || !clip.Longitude.Valid {
+		if clip.Place.Valid && clip.Place.String != "" {
+			return &ClipLocation{Place: clip.Place.String}
+		}
+		return nil
+	}
+	return &ClipLocation{
+		Latitude:  clip.Latitude.Float64,
+		Longitude: clip.Longitude.Float64,
+		Place:     clip.Place.String,
+	}
+}
+
+// boundingBox is a lat/lon rectangle; MinLon > MaxLon means it crosses the antimeridian
+type boundingBox struct {
+	MinLat, MinLon, MaxLat, MaxLon float64
+}
+
+// parseBBox parses "minLon,minLat,maxLon,maxLat" (GeoJSON order)
+func parseBBox(value string) (*boundingBox, error) {
+	v, err := parseFloats(value, 4)
+	if err != nil {
+		return nil, fmt.Errorf("bbox must be minLon,minLat,maxLon,maxLat")
+	}
+	box := &boundingBox{MinLon: v[0], MinLat: v[1], MaxLon: v[2], MaxLat: v[3]}
+	if box.MinLat > box.MaxLat || box.MinLat < -90 || box.MaxLat > 90 ||
+		box.MinLon < -180 || box.MaxLon > 180 {
+		return nil, fmt.Errorf("bbox is out of range")
+	}
+	return box, nil
+}
+
+// parseNear turns "lat,lon" and a radius into the enclosing bounding box.
+// The box is an approximation of the circle, which is precise enough for browsing.
+func parseNear(value, radiusStr string) (*boundingBox, error) {
+	v, err := parseFloats(value, 2)
+	if err != nil || v[0] < -90 || v[0] > 90 || v[1] < -180 || v[1] > 180 {
+		return nil, fmt.Errorf("near must be lat,lon")
+	}
+
+	radius := defaultRadiusKm
+	if radiusStr != "" {
+		radius, err = strconv.ParseFloat(radiusStr, 64)
+		if err != nil || radius <= 0 {
+			return nil, fmt.Errorf("radius_km must be a positive number")
+		}
+	}
+
+	lat, lon := v[0], v[1]
+	latDelta := radius / kmPerDegree
+	box := &boundingBox{
+		MinLat: math.Max(lat-latDelta, -90),
+		MaxLat: math.Min(lat+latDelta, 90),
+		MinLon: -180,
+		MaxLon: 180,
+	}
+
+	// Longitude degrees shrink towards the poles
+	if cos := math.Cos(lat * math.Pi / 180); cos > 0.01 {
+		lonDelta := radius / (kmPerDegree * cos)
+		if lonDelta < 180 {
+			box.MinLon = wrapLongitude(lon - lonDelta)
+			box.MaxLon = wrapLongitude(lon + lonDelta)
+		}
+	}
+	return box, nil
+}
+
+// where adds the bounding box condition to a clips query
+func (b *boundingBox) where(q *pop.Query) *pop.Query {
+	q = q.Where("latitude BETWEEN ? AND ?", b.MinLat, b.MaxLat)
+	if b.MinLon > b.MaxLon {
+		return q.Where("(longitude >= ? OR longitude <= ?)", b.MinLon, b.MaxLon)
+	}
+	return q.Where("longitude BETWEEN ? AND ?", b.MinLon, b.MaxLon)
+}
+
+// wrapLongitude maps a longitude into [-180, 180]
+func wrapLongitude(lon float64) float64 {
+	for lon > 180 {
+		lon -= 360
+	}
+	for lon < -180 {
+		lon += 360
+	}
+	return lon
+}
+
+// parseFloats parses exactly n comma-separated numbers
+func parseFloats(value string, n int) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d values", n)
+	}
+	out := make([]float64, n)
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = f
+	}
+	return out, nil
+}
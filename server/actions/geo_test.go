@@ -0,0 +1,41 @@
+package actions
+
+func (as *ActionSuite) Test_ValidateLocation() {
+	lat, lon, bad := 45.0, 5.0, 200.0
+
+	as.NoError(validateLocation(ClipPayload{}))
+	as.NoError(validateLocation(ClipPayload{Latitude: &lat, Longitude: &lon}))
+	as.Error(validateLocation(ClipPayload{Latitude: &lat}))
+	as.Error(validateLocation(ClipPayload{Latitude: &bad, Longitude: &lon}))
+	as.Error(validateLocation(ClipPayload{Latitude: &lat, Longitude: &bad}))
+}
+
+func (as *ActionSuite) Test_ParseBBox() {
+	box, err := parseBBox("2.2,48.8,2.4,48.9")
+	as.NoError(err)
+	as.Equal(boundingBox{MinLat: 48.8, MinLon: 2.2, MaxLat: 48.9, MaxLon: 2.4}, *box)
+
+	_, err = parseBBox("2.2,48.8,2.4")
+	as.Error(err)
+	_, err = parseBBox("2.2,49,2.4,48")
+	as.Error(err)
+}
+
+func (as *ActionSuite) Test_ParseNear() {
+	box, err := parseNear("0,0", "111.32")
+	as.NoError(err)
+	as.InDelta(-1, box.MinLat, 1e-9)
+	as.InDelta(1, box.MaxLat, 1e-9)
+	as.InDelta(-1, box.MinLon, 1e-9)
+	as.InDelta(1, box.MaxLon, 1e-9)
+
+	// Crossing the antimeridian wraps the longitude range
+	box, err = parseNear("0,179.5", "111.32")
+	as.NoError(err)
+	as.Greater(box.MinLon, box.MaxLon)
+
+	_, err = parseNear("91,0", "")
+	as.Error(err)
+	_, err = parseNear("0,0", "-5")
+	as.Error(err)
+}
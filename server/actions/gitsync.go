@@ -0,0 +1,207 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"server/internal/config"
+	"server/internal/gitsync"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// With git_sync.enabled, clip directories are git repositories: a pass
+// commits each clip changed since the previous one (new clips right after
+// they are saved, other changes on the git_sync task's schedule), then what
+// else changed in the repository, and pushes storage.base_path's repository
+// to git_sync.remote. A user's own clip directory, or a collection stored
+// outside it, is a repository of its own that is committed but not pushed.
+// Hidden folders (trash, staging, caches) are left out by the .gitignore
+// written when a repository is created; files are committed as stored, so
+// encrypted or compressed when storage is.
+
+// gitSyncPass keeps passes from overlapping
+var gitSyncPass sync.Mutex
+
+// gitSync is the state kept between passes, for the config it was built
+// from
+var gitSync struct {
+	mu     sync.Mutex
+	cfg    *config.Config
+	synced time.Time
+}
+
+// GitSyncResult summarizes a git sync pass
+type GitSyncResult struct {
+	Clips   int      `json:"clips"`   // Clips changed since the previous pass
+	Commits int      `json:"commits"` // Commits made
+	Pushed  bool     `json:"pushed"`  // Whether git_sync.remote was pushed to
+	Repos   []string `json:"repos"`   // Repositories committed to
+}
+
+// SyncClipsToGit commits the clips changed since the previous pass to their
+// repositories and pushes the base path's. The changes are only marked
+// synced when the pass succeeds, so a failed pass is retried whole.
+func SyncClipsToGit(ctx context.Context, db *pop.Connection) (*GitSyncResult, error) {
+	gitSyncPass.Lock()
+	defer gitSyncPass.Unlock()
+
+	gitSync.mu.Lock()
+	if gitSync.cfg != GetConfig() {
+		gitSync.cfg, gitSync.synced = GetConfig(), time.Time{}
+	}
+	synced := gitSync.synced
+	gitSync.mu.Unlock()
+
+	start := time.Now().UTC()
+	result, err := syncClipsToGit(ctx, db, synced)
+	if err != nil {
+		return nil, err
+	}
+
+	gitSync.mu.Lock()
+	defer gitSync.mu.Unlock()
+	if gitSync.cfg == GetConfig() {
+		gitSync.synced = start
+	}
+	return result, nil
+}
+
+func syncClipsToGit(ctx context.Context, db *pop.Connection, synced time.Time) (*GitSyncResult, error) {
+	cfg := GetConfig()
+	conf := cfg.GitSync
+
+	clips := models.Clips{}
+	q := db.Order("updated_at ASC")
+	if !synced.IsZero() {
+		q = q.Where("updated_at > ?", synced.Add(-replicationOverlap))
+	}
+	if err := q.All(&clips); err != nil {
+		return nil, err
+	}
+
+	result := &GitSyncResult{Clips: len(clips), Repos: []string{}}
+	repos := map[string]*gitsync.Repo{}
+	repo := func(dir string) (*gitsync.Repo, error) {
+		if repo, ok := repos[dir]; ok {
+			return repo, nil
+		}
+		repo := &gitsync.Repo{Dir: dir, Git: conf.GitPath, Branch: conf.Branch, AuthorName: conf.AuthorName, AuthorEmail: conf.AuthorEmail}
+		remote := ""
+		if dir == cfg.Storage.BasePath {
+			remote = conf.Remote
+		}
+		if err := repo.Open(ctx, remote); err != nil {
+			return nil, fmt.Errorf("failed to open the repository in %s: %w", dir, err)
+		}
+		repos[dir] = repo
+		result.Repos = append(result.Repos, dir)
+		return repo, nil
+	}
+	// The base path's repository is pushed even when no clip changed
+	if _, err := repo(cfg.Storage.BasePath); err != nil {
+		return nil, err
+	}
+
+	users := map[uuid.UUID]*models.User{}
+	for i := range clips {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		clip := &clips[i]
+		user, ok := users[clip.UserID]
+		if !ok {
+			user = &models.User{}
+			if err := db.Find(user, clip.UserID); err != nil {
+				return nil, fmt.Errorf("failed to find the owner of clip %s: %w", clip.ID, err)
+			}
+			users[clip.UserID] = user
+		}
+
+		// Collections with a relative storage path are inside the user's
+		// directory, and its repository
+		root := clipRoot(cfg, user, clip)
+		dir := userClipDir(cfg, user)
+		if rel, err := filepath.Rel(dir, root); err != nil || !filepath.IsLocal(rel) {
+			dir = root
+		}
+		r, err := repo(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		paths := []string{filepath.Join(root, clip.Path)}
+		if clip.IsNote() {
+			attachments, err := noteAttachmentPaths(paths[0], clip.ID)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, attachments...)
+		}
+		for j, p := range paths {
+			if paths[j], err = filepath.Rel(dir, p); err != nil {
+				return nil, err
+			}
+		}
+
+		committed, err := r.Commit(ctx, gitCommitMessage(clip), paths...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to commit clip %s: %w", clip.ID, err)
+		}
+		if committed {
+			result.Commits++
+		}
+	}
+
+	// What changed outside of the clips found (clips deleted for good, files
+	// edited in place)
+	for _, dir := range result.Repos {
+		committed, err := repos[dir].Commit(ctx, "Sync clip storage", ".")
+		if err != nil {
+			return nil, err
+		}
+		if committed {
+			result.Commits++
+		}
+	}
+
+	if conf.Remote != "" {
+		if err := repos[cfg.Storage.BasePath].Push(ctx); err != nil {
+			return nil, err
+		}
+		result.Pushed = true
+	}
+	return result, nil
+}
+
+// gitCommitMessage describes the change of a clip
+func gitCommitMessage(clip *models.Clip) string {
+	action := "Clip"
+	if clip.DeletedAt.Valid {
+		action = "Trash"
+	}
+	return fmt.Sprintf("%s: %s\n\nClip %s\n%s", action, clip.Title, clip.ID, clip.URL)
+}
+
+// syncGitAfterCommit commits a saved clip to git once the request's
+// transaction is committed, without waiting for the git_sync task
+func syncGitAfterCommit(c buffalo.Context) {
+	if !GetConfig().GitSync.Enabled {
+		return
+	}
+	db := appDB(c)
+	onCommit(c, func() {
+		goBackground(func() {
+			if _, err := SyncClipsToGit(context.Background(), db); err != nil {
+				log.Printf("git sync: %v", err)
+			}
+		})
+	})
+}
@@ -0,0 +1,66 @@
+package actions
+
+import (
+	"context"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"server/internal/scheduler"
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_GitSync() {
+	if _, err := exec.LookPath("git"); err != nil {
+		as.T().Skip("git is not installed")
+	}
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{user.Email}
+	remote := filepath.Join(as.T().TempDir(), "clips.git")
+	out, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput()
+	as.NoError(err, string(out))
+	kit.Config.GitSync.Enabled = true
+	kit.Config.GitSync.Remote = remote
+	client := kit.Client(newKitApp(kit), user)
+
+	// New clips are committed and pushed once saved
+	var created ClipResponse
+	res := client.Post("/api/v1/clips", ClipPayload{
+		Title:    "Versioned",
+		URL:      "https://example.com/versioned",
+		Mode:     "article",
+		Markdown: "Keep every version",
+	})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&created)
+	background.Wait()
+
+	gitLog := func(dir string, args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", dir, "log", "--format=%an|%s"}, args...)...).CombinedOutput()
+		as.NoError(err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	as.Equal("Web Clipper|Clip: Versioned", gitLog(remote, "-1", "main"))
+	files, err := exec.Command("git", "-C", kit.StorageRoot, "ls-files").Output()
+	as.NoError(err)
+	as.Contains(string(files), filepath.ToSlash(filepath.Dir(created.Path)))
+	as.NotContains(string(files), trashDirName)
+
+	// Other changes are committed by the git_sync task
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/clips/"+created.ID).Code)
+	var status scheduler.Status
+	res = client.Post("/api/v1/admin/scheduler/git_sync", nil)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&status)
+	as.Equal("made 1 commits", status.LastResult)
+	as.Equal("Web Clipper|Trash: Versioned\nWeb Clipper|Clip: Versioned", gitLog(remote, "-2", "main"))
+
+	// Nothing is committed when nothing changed
+	result, err := SyncClipsToGit(context.Background(), kit.DB)
+	as.NoError(err)
+	as.Zero(result.Commits)
+	as.True(result.Pushed)
+	as.Equal([]string{kit.StorageRoot}, result.Repos)
+}
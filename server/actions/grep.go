@@ -0,0 +1,140 @@
+package actions
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"server/models"
+
+	"github.com/gobuffalo/pop/v6"
+)
+
+// GrepMatch is a line of a clip matching a text search
+type GrepMatch struct {
+	ClipID string
+	Path   string // Page file, or the clip folder for a match in a field
+	Line   int    // 0 for a match in a field
+	Field  string // title, notes or content_text when not in the page file
+	Text   string
+}
+
+// GrepClips runs the clip search of GET /api/v1/clips (filter.Query is
+// required) for one user, or every user without an email, and returns the
+// matching lines of the clips found: lines of their markdown files, or the
+// searched fields the query matched in, containing any of its words and
+// phrases.
+func GrepClips(db *pop.Connection, email string, filter ClipFilter) ([]GrepMatch, error) {
+	if strings.TrimSpace(filter.Query) == "" {
+		return nil, fmt.Errorf("a search query is required")
+	}
+
+	users := models.Users{}
+	q := db.Order("email ASC")
+	if email != "" {
+		q = q.Where("email = ?", email)
+	}
+	if err := q.All(&users); err != nil {
+		return nil, err
+	}
+	if email != "" && len(users) == 0 {
+		return nil, fmt.Errorf("user not found: %s", email)
+	}
+
+	needles := queryText(filter.Query)
+	if len(needles) == 0 {
+		return nil, fmt.Errorf("the search query has no words to look for")
+	}
+
+	cfg := GetConfig()
+	var matches []GrepMatch
+	for i := range users {
+		user := &users[i]
+		q, err := filter.apply(db.Where("user_id = ? AND deleted_at IS NULL", user.ID), user.ID)
+		if err != nil {
+			return nil, err
+		}
+		clips := models.Clips{}
+		if err := q.Order("created_at DESC").All(&clips); err != nil {
+			return nil, err
+		}
+
+		for j := range clips {
+			clip := &clips[j]
+			folder := filepath.Join(clipRoot(cfg, user, clip), clip.Path)
+			found, err := grepClipFiles(folder, needles)
+			if err != nil {
+				return nil, err
+			}
+			for k := range found {
+				found[k].ClipID = clip.ID.String()
+			}
+			if len(found) == 0 {
+				// The query matched in a field the API searches but not in the page
+				for _, field := range []struct{ name, value string }{
+					{"title", clip.Title}, {"notes", clip.Notes.String}, {"content_text", clip.ContentText.String},
+				} {
+					if line := matchingLine(field.value, needles); line != "" {
+						found = append(found, GrepMatch{ClipID: clip.ID.String(), Path: folder, Field: field.name, Text: line})
+					}
+				}
+			}
+			matches = append(matches, found...)
+		}
+	}
+	return matches, nil
+}
+
+// grepClipFiles returns the lines of a clip folder's markdown files (or of
+// a note) containing any of needles (lowercase)
+func grepClipFiles(folder string, needles []string) ([]GrepMatch, error) {
+	pages, err := filepath.Glob(filepath.Join(folder, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	if isNotePath(folder) {
+		pages = []string{folder}
+	}
+
+	var matches []GrepMatch
+	for _, page := range pages {
+		f, err := openClipFile(page)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		for n := 1; scanner.Scan(); n++ {
+			if containsAny(strings.ToLower(scanner.Text()), needles) {
+				matches = append(matches, GrepMatch{Path: page, Line: n, Text: scanner.Text()})
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", page, err)
+		}
+	}
+	return matches, nil
+}
+
+// matchingLine returns the first line of s containing any of needles (lowercase)
+func matchingLine(s string, needles []string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if containsAny(strings.ToLower(line), needles) {
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
+
+// containsAny reports whether s contains any of needles
+func containsAny(s string, needles []string) bool {
+	for _, needle := range needles {
+		if strings.Contains(s, needle) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package actions
+
+import (
+	"path/filepath"
+
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+)
+
+func (as *ActionSuite) Test_GrepClips() {
+	kit := testkit.New(as.T())
+	newKitApp(kit) // Uses the kit's storage
+	user := kit.CreateUser()
+	other := kit.CreateUser()
+
+	titled := kit.CreateClip(user, testkit.WithClip(func(c *models.Clip) { c.Title = "Go Generics" }),
+		testkit.WithContent("Intro\n\nGenerics arrived in Go 1.18.\nThe end."))
+	noted := kit.CreateClip(user, testkit.WithClip(func(c *models.Clip) { c.Notes = nulls.NewString("read again\nabout generics") }))
+	kit.CreateClip(user, testkit.WithContent("Generics, but only in the page")) // Not found by the API search either
+	kit.CreateClip(other, testkit.WithClip(func(c *models.Clip) { c.Title = "generics elsewhere" }))
+
+	matches, err := GrepClips(kit.DB, user.Email, ClipFilter{Query: "GENERICS"})
+	as.NoError(err)
+	as.Len(matches, 3)
+
+	byClip := map[string][]GrepMatch{}
+	for _, m := range matches {
+		byClip[m.ClipID] = append(byClip[m.ClipID], m)
+	}
+	page := byClip[titled.ID.String()]
+	as.Len(page, 2) // The frontmatter title and the body line
+	as.Equal(".md", filepath.Ext(page[1].Path))
+	as.Equal("Generics arrived in Go 1.18.", page[1].Text)
+	as.Greater(page[1].Line, page[0].Line)
+
+	note := byClip[noted.ID.String()]
+	as.Len(note, 1)
+	as.Equal("notes", note[0].Field)
+	as.Equal("about generics", note[0].Text)
+
+	matches, err = GrepClips(kit.DB, "", ClipFilter{Query: "generics"})
+	as.NoError(err)
+	as.Len(matches, 4)
+
+	_, err = GrepClips(kit.DB, "nobody@example.com", ClipFilter{Query: "generics"})
+	as.Error(err)
+	_, err = GrepClips(kit.DB, user.Email, ClipFilter{Query: " "})
+	as.Error(err)
+	_, err = GrepClips(kit.DB, user.Email, ClipFilter{Query: "tag:go -generics"})
+	as.Error(err)
+
+	// Lines with the query's words and phrases, in the clips it finds
+	matches, err = GrepClips(kit.DB, user.Email, ClipFilter{Query: `"go generics" -again`})
+	as.NoError(err)
+	as.Len(matches, 1)
+	as.Equal(titled.ID.String(), matches[0].ClipID)
+}
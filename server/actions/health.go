@@ -0,0 +1,279 @@
+package actions
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Readiness check results
+const (
+	checkOK       = "ok"
+	checkDegraded = "degraded" // Working, with drift worth a look
+	checkFailed   = "fail"
+)
+
+// ReadinessCheck is the outcome of one readiness check
+type ReadinessCheck struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// ClipDrift is a clip whose folder does not match the database
+type ClipDrift struct {
+	ID     string `json:"id"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// ConsistencyReport compares a sample of recent clips with their folders
+type ConsistencyReport struct {
+	Sampled int         `json:"sampled"`
+	Drift   []ClipDrift `json:"drift"`
+}
+
+// ConsistencySummary is the consistency check of the public probe: counts
+// only, the drifted clips are listed at GET /api/v1/admin/consistency
+type ConsistencySummary struct {
+	Sampled int `json:"sampled"`
+	Drifted int `json:"drifted"`
+}
+
+// ReadinessResponse is the body of GET /health/ready
+type ReadinessResponse struct {
+	Status      string              `json:"status"`
+	Checks      []ReadinessCheck    `json:"checks"`
+	Consistency *ConsistencySummary `json:"consistency,omitempty"`
+}
+
+// healthReady is the readiness probe: whether the instance can serve clips.
+// The database answers, the storage is mounted and writable, the folders of
+// the most recent clips are there and readable, and the OAuth provider's
+// discovery document can be fetched. A little drift (a folder removed by
+// hand) is reported as degraded; more than health.max_drift_percent of the
+// sample missing, typically a broken mount, makes the instance unready
+// (503). An unreachable OAuth provider is degraded only: signed-in clients
+// keep working, and every instance would be unready at once.
+//
+// The probe is public: details name no clip, path or error, those are
+// logged, and drifted clips are listed for admins only.
+func healthReady(c buffalo.Context) error {
+	tx := appDB(c) // Not in a transaction, which would fail without a database
+	health := GetConfig().Health
+
+	resp := ReadinessResponse{Status: checkOK}
+	run := func(name string, check func() (string, string)) {
+		start := time.Now()
+		status, detail := check()
+		resp.Checks = append(resp.Checks, ReadinessCheck{
+			Name:       name,
+			Status:     status,
+			Detail:     detail,
+			DurationMs: time.Since(start).Milliseconds(),
+		})
+		if status == checkFailed || (status == checkDegraded && resp.Status == checkOK) {
+			resp.Status = status
+		}
+	}
+
+	run("database", func() (string, string) {
+		if err := tx.RawQuery("SELECT 1").Exec(); err != nil {
+			c.Logger().Errorf("Readiness: database: %v", err)
+			return checkFailed, "database unavailable"
+		}
+		return checkOK, ""
+	})
+	run("storage", func() (string, string) {
+		if err := checkWritableDir(GetConfig().Storage.BasePath); err != nil {
+			c.Logger().Errorf("Readiness: storage: %v", err)
+			return checkFailed, "storage not writable"
+		}
+		return checkOK, ""
+	})
+	if discoveryURL := oauthDiscoveryURL(GetConfig().OAuth); discoveryURL != "" && GetConfig().OAuth.ClientID != "" {
+		run("oauth", func() (string, string) {
+			if err := checkOAuthDiscovery(discoveryURL); err != nil {
+				c.Logger().Warnf("Readiness: oauth: %v", err)
+				return checkDegraded, "discovery document unavailable"
+			}
+			return checkOK, ""
+		})
+	}
+	if health.ConsistencySample > 0 && resp.Status != checkFailed {
+		run("consistency", func() (string, string) {
+			report, err := sampleConsistency(tx, health.ConsistencySample)
+			if err != nil {
+				c.Logger().Errorf("Readiness: consistency: %v", err)
+				return checkFailed, "clips could not be sampled"
+			}
+			resp.Consistency = &ConsistencySummary{Sampled: report.Sampled, Drifted: len(report.Drift)}
+			for _, drift := range report.Drift {
+				c.Logger().Warnf("Readiness: clip %s (%s): %s", drift.ID, drift.Path, drift.Reason)
+			}
+			if len(report.Drift) == 0 {
+				return checkOK, ""
+			}
+			detail := fmt.Sprintf("%d of %d recent clips drifted from their folders", len(report.Drift), report.Sampled)
+			if len(report.Drift)*100 > report.Sampled*health.MaxDriftPercent {
+				return checkFailed, detail
+			}
+			return checkDegraded, detail
+		})
+	}
+
+	status := http.StatusOK
+	if resp.Status == checkFailed {
+		status = http.StatusServiceUnavailable
+	}
+	return c.Render(status, r.JSON(resp))
+}
+
+// adminConsistency lists the recent clips whose folders drifted from the
+// database, the sample of the readiness probe (health.consistency_sample,
+// or ?limit=)
+func adminConsistency(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	limit := GetConfig().Health.ConsistencySample
+	if limit < 1 {
+		limit = 20
+	}
+	if s := c.Param("limit"); s != "" {
+		if n, err := fmt.Sscanf(s, "%d", &limit); err != nil || n != 1 || limit < 1 || limit > 1000 {
+			return c.Error(http.StatusBadRequest, fmt.Errorf("limit must be between 1 and 1000"))
+		}
+	}
+
+	report, err := sampleConsistency(tx, limit)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(report))
+}
+
+// oauthCheck caches the last OAuth discovery check, for probes not to call
+// the provider every few seconds
+var oauthCheck struct {
+	mu  sync.Mutex
+	url string
+	at  time.Time
+	err error
+}
+
+// checkOAuthDiscovery fetches the provider's discovery document, at most
+// once a minute
+func checkOAuthDiscovery(discoveryURL string) error {
+	oauthCheck.mu.Lock()
+	defer oauthCheck.mu.Unlock()
+	if oauthCheck.url == discoveryURL && time.Since(oauthCheck.at) < time.Minute {
+		return oauthCheck.err
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(discoveryURL)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("discovery document: %s", resp.Status)
+		}
+	}
+	oauthCheck.url, oauthCheck.at, oauthCheck.err = discoveryURL, time.Now(), err
+	return err
+}
+
+// checkWritableDir returns why files can't be created in dir, or nil
+func checkWritableDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	f, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// sampleConsistency checks the folders of the n most recent clips
+func sampleConsistency(tx *pop.Connection, n int) (*ConsistencyReport, error) {
+	clips := models.Clips{}
+	if err := tx.Where("deleted_at IS NULL").Order("created_at DESC").Limit(n).All(&clips); err != nil {
+		return nil, err
+	}
+
+	cfg := GetConfig()
+	users := map[uuid.UUID]*models.User{}
+	report := &ConsistencyReport{Sampled: len(clips), Drift: []ClipDrift{}}
+	for i := range clips {
+		clip := &clips[i]
+		user, ok := users[clip.UserID]
+		if !ok {
+			user = &models.User{}
+			if err := tx.Find(user, clip.UserID); err != nil {
+				return nil, err
+			}
+			users[clip.UserID] = user
+		}
+		if reason := checkClipFolder(filepath.Join(clipRoot(cfg, user, clip), clip.Path)); reason != "" {
+			report.Drift = append(report.Drift, ClipDrift{ID: clip.ID.String(), Path: clip.Path, Reason: reason})
+		}
+	}
+	return report, nil
+}
+
+// checkClipFolder returns what is wrong with a clip folder, or "" when it
+// has a readable page file. A note is checked as its own page file.
+func checkClipFolder(folder string) string {
+	if isNotePath(folder) {
+		return checkPageFile(folder, "note")
+	}
+	entries, err := os.ReadDir(folder)
+	if os.IsNotExist(err) {
+		return "folder missing"
+	}
+	if err != nil {
+		return "folder unreadable: " + err.Error()
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, ".md") || strings.HasSuffix(name, ".html")) {
+			continue
+		}
+		return checkPageFile(filepath.Join(folder, name), "page")
+	}
+	return "no page file"
+}
+
+// checkPageFile returns what is wrong with a page file, or "" when it is readable
+func checkPageFile(path, what string) string {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return what + " missing"
+	}
+	if err != nil {
+		return what + " unreadable: " + err.Error()
+	}
+	_, err = f.Read(make([]byte, 512))
+	f.Close()
+	if err != nil && err != io.EOF {
+		return what + " unreadable: " + err.Error()
+	}
+	return ""
+}
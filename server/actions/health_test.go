@@ -0,0 +1,115 @@
+package actions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"server/internal/config"
+	"server/internal/testkit"
+	"server/models"
+)
+
+func (as *ActionSuite) Test_HealthReady() {
+	kit := testkit.New(as.T())
+	app := newKitApp(kit)
+	admin := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{admin.Email}
+	client := kit.Client(app, kit.CreateUser())
+	adminClient := kit.Client(app, admin)
+	user := kit.CreateUser()
+	var clips []*models.Clip
+	for i := 0; i < 4; i++ {
+		clips = append(clips, kit.CreateClip(user))
+	}
+
+	var resp ReadinessResponse
+	res := client.Get("/health/ready")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&resp)
+	as.Equal(checkOK, resp.Status)
+	as.Len(resp.Checks, 3)
+	as.Equal(4, resp.Consistency.Sampled)
+	as.Zero(resp.Consistency.Drifted)
+
+	// A folder removed by hand is drift, not an outage
+	as.NoError(os.RemoveAll(filepath.Join(kit.StorageRoot, clips[0].Path)))
+	res = client.Get("/health/ready")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&resp)
+	as.Equal(checkDegraded, resp.Status)
+	as.Equal(1, resp.Consistency.Drifted)
+	as.NotContains(res.Body.String(), clips[0].ID.String(), "the public probe names no clip")
+	as.NotContains(res.Body.String(), clips[0].Path)
+
+	// Admins get the drifted clips
+	var report ConsistencyReport
+	res = adminClient.Get("/api/v1/admin/consistency")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&report)
+	as.Equal(4, report.Sampled)
+	as.Len(report.Drift, 1)
+	as.Equal(clips[0].ID.String(), report.Drift[0].ID)
+	as.Equal("folder missing", report.Drift[0].Reason)
+	as.Equal(http.StatusForbidden, client.Get("/api/v1/admin/consistency").Code)
+
+	// Most of the sample gone looks like a broken mount
+	for _, clip := range clips[1:3] {
+		as.NoError(os.RemoveAll(filepath.Join(kit.StorageRoot, clip.Path)))
+	}
+	res = client.Get("/health/ready")
+	as.Equal(http.StatusServiceUnavailable, res.Code, res.Body.String())
+	res.JSON(&resp)
+	as.Equal(checkFailed, resp.Status)
+	as.Equal(3, resp.Consistency.Drifted)
+}
+
+func (as *ActionSuite) Test_HealthProbes() {
+	kit := testkit.New(as.T())
+	app := newKitApp(kit)
+	client := kit.Client(app, kit.CreateUser())
+
+	res := client.Get("/healthz")
+	as.Equal(http.StatusOK, res.Code)
+	as.JSONEq(`{"status":"ok"}`, res.Body.String())
+
+	// The OAuth provider is checked once configured; unreachable, it only degrades
+	discovery := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/realms/clips/.well-known/openid-configuration" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer discovery.Close()
+	kit.Config.OAuth = config.OAuthConfig{Provider: "keycloak", ClientID: "web-clipper", Keycloak: config.KeycloakConfig{BaseURL: discovery.URL, Realm: "clips"}}
+
+	var resp ReadinessResponse
+	res = client.Get("/readyz")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&resp)
+	as.Equal(checkOK, resp.Status)
+	as.Equal([]string{"database", "storage", "oauth", "consistency"}, checkNames(resp))
+
+	kit.Config.OAuth.Keycloak.Realm = "gone"
+	resp = ReadinessResponse{}
+	res = client.Get("/readyz")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&resp)
+	as.Equal(checkDegraded, resp.Status)
+	as.Equal(checkDegraded, resp.Checks[2].Status)
+	as.Equal("discovery document unavailable", resp.Checks[2].Detail)
+	entries, _ := os.ReadDir(kit.StorageRoot)
+	for _, entry := range entries {
+		as.False(strings.HasPrefix(entry.Name(), ".readyz"), "write check left %s", entry.Name())
+	}
+}
+
+// checkNames lists the checks of a readiness response
+func checkNames(resp ReadinessResponse) []string {
+	var names []string
+	for _, check := range resp.Checks {
+		names = append(names, check.Name)
+	}
+	return names
+}
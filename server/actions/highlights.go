@@ -0,0 +1,242 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// HighlightPayload is the request body for creating or updating a highlight
+type HighlightPayload struct {
+	Text        string `json:"text"`
+	Selector    string `json:"selector"`
+	StartOffset int    `json:"start_offset"`
+	EndOffset   int    `json:"end_offset"`
+	Color       string `json:"color"` // Defaults to yellow
+	Comment     string `json:"comment"`
+}
+
+// HighlightResponse is the API representation of a highlight
+type HighlightResponse struct {
+	ID          string    `json:"id"`
+	ClipID      string    `json:"clip_id"`
+	Text        string    `json:"text"`
+	Selector    string    `json:"selector,omitempty"`
+	StartOffset int       `json:"start_offset"`
+	EndOffset   int       `json:"end_offset"`
+	Color       string    `json:"color"`
+	Comment     string    `json:"comment,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// listHighlights returns all highlights on a clip
+func listHighlights(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	clipID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid clip ID"))
+	}
+
+	// Fetch clip with ownership check
+	clip, err := models.FindClipByIDAndUser(tx, clipID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("clip not found"))
+	}
+
+	highlights, err := models.FindHighlightsByClip(tx, clip.ID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	resp := make([]HighlightResponse, len(highlights))
+	for i := range highlights {
+		resp[i] = highlightToResponse(&highlights[i])
+	}
+
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"highlights": resp,
+	}))
+}
+
+// createHighlight attaches a new highlight or annotation to a clip
+func createHighlight(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	clipID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid clip ID"))
+	}
+
+	var req HighlightPayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	// Fetch clip with ownership check
+	clip, err := models.FindClipByIDAndUser(tx, clipID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("clip not found"))
+	}
+
+	highlight := &models.Highlight{
+		ID:     uuid.Must(uuid.NewV4()),
+		ClipID: clip.ID,
+		UserID: userID,
+	}
+	applyHighlightPayload(highlight, req)
+
+	verrs, err := tx.ValidateAndCreate(highlight)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	return c.Render(http.StatusCreated, r.JSON(highlightToResponse(highlight)))
+}
+
+// updateHighlight replaces a highlight's anchor, color and comment
+func updateHighlight(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	clipID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid clip ID"))
+	}
+	highlightID, err := uuid.FromString(c.Param("highlight_id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid highlight ID"))
+	}
+
+	var req HighlightPayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	highlight, err := models.FindHighlightByIDAndUser(tx, highlightID, clipID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("highlight not found"))
+	}
+	applyHighlightPayload(highlight, req)
+
+	verrs, err := tx.ValidateAndUpdate(highlight)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	return c.Render(http.StatusOK, r.JSON(highlightToResponse(highlight)))
+}
+
+// deleteHighlight removes a highlight from a clip
+func deleteHighlight(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	clipID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid clip ID"))
+	}
+	highlightID, err := uuid.FromString(c.Param("highlight_id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid highlight ID"))
+	}
+
+	highlight, err := models.FindHighlightByIDAndUser(tx, highlightID, clipID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("highlight not found"))
+	}
+
+	if err := tx.Destroy(highlight); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	return c.Render(http.StatusNoContent, nil)
+}
+
+// applyHighlightPayload copies request fields onto a highlight model
+func applyHighlightPayload(h *models.Highlight, req HighlightPayload) {
+	h.Text = req.Text
+	h.Selector = nulls.String{}
+	if req.Selector != "" {
+		h.Selector = nulls.NewString(req.Selector)
+	}
+	h.StartOffset = req.StartOffset
+	h.EndOffset = req.EndOffset
+	h.Color = req.Color
+	if h.Color == "" {
+		h.Color = "yellow"
+	}
+	h.Comment = nulls.String{}
+	if req.Comment != "" {
+		h.Comment = nulls.NewString(req.Comment)
+	}
+}
+
+// highlightToResponse converts a highlight model to its API representation
+func highlightToResponse(h *models.Highlight) HighlightResponse {
+	return HighlightResponse{
+		ID:          h.ID.String(),
+		ClipID:      h.ClipID.String(),
+		Text:        h.Text,
+		Selector:    h.Selector.String,
+		StartOffset: h.StartOffset,
+		EndOffset:   h.EndOffset,
+		Color:       h.Color,
+		Comment:     h.Comment.String,
+		CreatedAt:   h.CreatedAt,
+		UpdatedAt:   h.UpdatedAt,
+	}
+}
+
+// highlightsMarkdown renders highlights as a markdown section for exports
+func highlightsMarkdown(highlights models.Highlights) string {
+	if len(highlights) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n## Highlights\n")
+	for _, h := range highlights {
+		sb.WriteString("\n")
+		for _, line := range strings.Split(strings.TrimSpace(h.Text), "\n") {
+			sb.WriteString("> " + line + "\n")
+		}
+		if h.Comment.Valid && h.Comment.String != "" {
+			sb.WriteString("\n" + strings.TrimSpace(h.Comment.String) + "\n")
+		}
+	}
+	return sb.String()
+}
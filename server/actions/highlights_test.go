@@ -0,0 +1,34 @@
+package actions
+
+import (
+	"net/http"
+
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+)
+
+func (as *ActionSuite) Test_ListHighlights_Unauthorized() {
+	res := as.JSON("/api/v1/clips/550e8400-e29b-41d4-a716-446655440000/highlights").Get()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_CreateHighlight_Unauthorized() {
+	res := as.JSON("/api/v1/clips/550e8400-e29b-41d4-a716-446655440000/highlights").Post(HighlightPayload{Text: "quote"})
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_DeleteHighlight_Unauthorized() {
+	res := as.JSON("/api/v1/clips/550e8400-e29b-41d4-a716-446655440000/highlights/550e8400-e29b-41d4-a716-446655440001").Delete()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_HighlightsMarkdown() {
+	as.Equal("", highlightsMarkdown(nil))
+
+	md := highlightsMarkdown(models.Highlights{
+		{Text: "first line\nsecond line"},
+		{Text: "quoted", Comment: nulls.NewString("my note")},
+	})
+	as.Equal("\n\n## Highlights\n\n> first line\n> second line\n\n> quoted\n\nmy note\n", md)
+}
@@ -0,0 +1,80 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+)
+
+// idempotencyKeyHeader lets clients retry POST /api/v1/clips safely: a
+// request repeating a key returns the clip saved by the first one.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds the stored key
+const maxIdempotencyKeyLength = 255
+
+// idempotencyKey returns the request's Idempotency-Key, if any
+func idempotencyKey(c buffalo.Context) (nulls.String, error) {
+	key := strings.TrimSpace(c.Request().Header.Get(idempotencyKeyHeader))
+	if key == "" {
+		return nulls.String{}, nil
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return nulls.String{}, fmt.Errorf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)
+	}
+	return nulls.NewString(key), nil
+}
+
+// replayClipResponse renders the success response for a clip saved by an
+// earlier request with the same Idempotency-Key
+func replayClipResponse(c buffalo.Context, tx *pop.Connection, clipDir string, clip *models.Clip) error {
+	versions, err := clipVersions(tx, clip)
+	if err != nil {
+		return clipError(c, http.StatusInternalServerError, "Failed to load clip versions")
+	}
+
+	c.Response().Header().Set("Idempotent-Replayed", "true")
+	return c.Render(http.StatusOK, r.JSON(ClipResponse{
+		Success: true,
+		Path:    filepath.Join(clip.Path, clipMainFile(filepath.Join(clipDir, clip.Path), clip)),
+		ID:      clip.ID.String(),
+		Version: versions[len(versions)-1].Version,
+	}))
+}
+
+// clipMainFile returns the name writeClipFiles gave the clip's main file,
+// or "" for a note (its own main file)
+func clipMainFile(folderPath string, clip *models.Clip) string {
+	if clip.IsNote() {
+		return ""
+	}
+	pageSlug := slugify(clip.Title)
+	if pageSlug == "" {
+		pageSlug = "page"
+	}
+	exts := []string{".md"}
+	if clip.Mode == "fullpage" {
+		exts = []string{".html", ".md"}
+	}
+	// Names from a file template aren't derived from the title
+	entries, _ := os.ReadDir(folderPath)
+	for _, ext := range exts {
+		if _, err := os.Stat(filepath.Join(folderPath, pageSlug+ext)); err == nil {
+			return pageSlug + ext
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ext) {
+				return entry.Name()
+			}
+		}
+	}
+	return pageSlug + ".md"
+}
@@ -0,0 +1,29 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/models"
+)
+
+func (as *ActionSuite) Test_CreateClip_IdempotencyKey_Unauthorized() {
+	req := as.JSON("/api/v1/clips")
+	req.Headers[idempotencyKeyHeader] = "retry-1"
+	res := req.Post(ClipPayload{Title: "Test", URL: "https://example.com", Mode: "bookmark"})
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_ClipMainFile() {
+	dir := as.T().TempDir()
+
+	as.Equal("my-article.md", clipMainFile(dir, &models.Clip{Title: "My Article", Mode: "article"}))
+	as.Equal("page.md", clipMainFile(dir, &models.Clip{Title: "", Mode: "bookmark"}))
+
+	// Full page captures keep their HTML as the main file when it was written
+	fullpage := &models.Clip{Title: "Saved Page", Mode: "fullpage"}
+	as.Equal("saved-page.md", clipMainFile(dir, fullpage))
+	as.NoError(os.WriteFile(filepath.Join(dir, "saved-page.html"), []byte("<html></html>"), 0644))
+	as.Equal("saved-page.html", clipMainFile(dir, fullpage))
+}
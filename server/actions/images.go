@@ -0,0 +1,135 @@
+package actions
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"server/internal/config"
+	"server/internal/imaging"
+)
+
+// originalsDir is where full-size images are kept, within a clip's media
+// folder, when images.preserve_original is set
+const originalsDir = "originals"
+
+// checkImageFormat rejects an image whose content is not one of the allowed
+// formats, whatever its filename says: HTML or an executable named .png
+// never reaches a media folder.
+func checkImageFormat(img ImagePayload, allowed []string) error {
+	head, err := img.head(imaging.SniffLen)
+	if err != nil {
+		return fmt.Errorf("Failed to read image: %s", img.Filename)
+	}
+	format := imaging.Sniff(head)
+	if format == "" {
+		return fmt.Errorf("Image %s is not a supported format (content looks like %s)", img.Filename, http.DetectContentType(head))
+	}
+	for _, f := range allowed {
+		if strings.EqualFold(f, format) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Image %s is %s, which is not an allowed format", img.Filename, strings.ToUpper(format))
+}
+
+// downscaleImage enforces images.max_dimension_px on an image saved in a
+// media folder. With images.preserve_original the full-size image is moved to
+// media/originals first. Formats that can't be resized (GIF, WebP, PDF...)
+// and images that fail to decode are kept as they are.
+func downscaleImage(path string, cfg config.ImagesConfig) error {
+	if cfg.MaxDimensionPx <= 0 {
+		return nil
+	}
+
+	data, err := readClipFile(path)
+	if err != nil {
+		return err
+	}
+	resized, err := imaging.Fit(data, cfg.MaxDimensionPx)
+	if err != nil {
+		log.Printf("Failed to resize %s, keeping it as is: %v", filepath.Base(path), err)
+		return nil
+	}
+	if resized == nil {
+		return nil
+	}
+
+	if cfg.PreserveOriginal {
+		dir := filepath.Join(filepath.Dir(path), originalsDir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(path, filepath.Join(dir, filepath.Base(path))); err != nil {
+			return err
+		}
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+	}
+	return writeFileSync(path, resized, 0644)
+}
+
+// convertToWebP stores an image saved in a media folder as WebP when
+// images.convert_to_webp is set, and returns its new file name. With
+// images.preserve_original the image is moved to media/originals, unless
+// downscaling already put the original there. Images that can't be converted,
+// or wouldn't get smaller, keep their name.
+func convertToWebP(path string, cfg config.ImagesConfig) (string, error) {
+	name := filepath.Base(path)
+	if !cfg.ConvertToWebP {
+		return name, nil
+	}
+
+	data, err := readClipFile(path)
+	if err != nil {
+		return "", err
+	}
+	converted, err := imaging.ToWebP(data, cfg.WebPQuality)
+	if err != nil {
+		log.Printf("Failed to convert %s to WebP, keeping it as is: %v", name, err)
+		return name, nil
+	}
+	if converted == nil {
+		return name, nil
+	}
+	webpName := strings.TrimSuffix(name, filepath.Ext(name)) + ".webp"
+	webpPath := filepath.Join(filepath.Dir(path), webpName)
+	if _, err := os.Stat(webpPath); err == nil {
+		return name, nil // Don't overwrite another image
+	}
+
+	if err := writeFileSync(webpPath, converted, 0644); err != nil {
+		return "", err
+	}
+	dir := filepath.Join(filepath.Dir(path), originalsDir)
+	if _, err := os.Stat(filepath.Join(dir, name)); cfg.PreserveOriginal && os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+		if err := os.Rename(path, filepath.Join(dir, name)); err != nil {
+			return "", err
+		}
+		if err := syncDir(dir); err != nil {
+			return "", err
+		}
+	} else if err := os.Remove(path); err != nil {
+		return "", err
+	}
+	return webpName, nil
+}
+
+// renameMediaLinks rewrites the media/ links of a page to renamed images
+func renameMediaLinks(content string, renamed map[string]string) string {
+	if len(renamed) == 0 {
+		return content
+	}
+	pairs := make([]string, 0, 2*len(renamed))
+	for from, to := range renamed {
+		pairs = append(pairs, "media/"+from, "media/"+to)
+	}
+	return strings.NewReplacer(pairs...).Replace(content)
+}
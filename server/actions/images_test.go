@@ -0,0 +1,152 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
+	"server/models"
+)
+
+// pngMagic starts a PNG file, enough for an image to pass the format check
+const pngMagic = "\x89PNG\r\n\x1a\n"
+
+func (as *ActionSuite) Test_CreateClip_DownscalesImages() {
+	kit := testkit.New(as.T())
+	kit.Config.Images.MaxDimensionPx = 100
+	kit.Config.Images.PreserveOriginal = true
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	var buf bytes.Buffer
+	as.NoError(png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 150))))
+	original := buf.Bytes()
+	payload := ClipPayload{
+		Title: "Big screenshot",
+		URL:   "https://example.com/big",
+		Mode:  "screenshot",
+		Images: []ImagePayload{
+			{Filename: "big.png", Data: base64.StdEncoding.EncodeToString(original)},
+			{Filename: "notes.pdf", Data: base64.StdEncoding.EncodeToString([]byte("%PDF-1.4 not an image"))},
+		},
+	}
+
+	var clip ClipResponse
+	res := client.Post("/api/v1/clips", payload)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+	media := filepath.Join(kit.StorageRoot, filepath.Dir(clip.Path), "media")
+
+	f, err := os.Open(filepath.Join(media, "big.png"))
+	as.NoError(err)
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	as.NoError(err)
+	as.Equal(100, cfg.Width)
+	as.Equal(50, cfg.Height)
+
+	data, err := os.ReadFile(filepath.Join(media, originalsDir, "big.png"))
+	as.NoError(err)
+	as.Equal(original, data)
+
+	data, err = os.ReadFile(filepath.Join(media, "notes.pdf"))
+	as.NoError(err)
+	as.Equal("%PDF-1.4 not an image", string(data))
+	_, err = os.Stat(filepath.Join(media, originalsDir, "notes.pdf"))
+	as.True(os.IsNotExist(err))
+
+	// Originals are not listed with the clip's images
+	var detail ClipDetail
+	client.Get("/api/v1/clips/" + clip.ID).JSON(&detail)
+	as.Len(detail.Images, 2)
+}
+
+func (as *ActionSuite) Test_CreateClip_ConvertsToWebP() {
+	kit := testkit.New(as.T())
+	kit.Config.Images.ConvertToWebP = true
+	kit.Config.Images.PreserveOriginal = true
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	// Noise, which PNG compresses poorly
+	img := image.NewRGBA(image.Rect(0, 0, 120, 80))
+	seed := uint32(1)
+	for i := range img.Pix {
+		seed = seed*1664525 + 1013904223
+		img.Pix[i] = uint8(seed >> 24)
+		if i%4 == 3 {
+			img.Pix[i] = 255
+		}
+	}
+	var buf bytes.Buffer
+	as.NoError(png.Encode(&buf, img))
+	payload := ClipPayload{
+		Title:    "Photo",
+		URL:      "https://example.com/photo",
+		Mode:     "screenshot",
+		Markdown: "![Shot](media/shot.png)\n",
+		Images:   []ImagePayload{{Filename: "shot.png", Data: base64.StdEncoding.EncodeToString(buf.Bytes())}},
+	}
+
+	var clip ClipResponse
+	res := client.Post("/api/v1/clips", payload)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+	folder := filepath.Join(kit.StorageRoot, filepath.Dir(clip.Path))
+
+	data, err := os.ReadFile(filepath.Join(folder, "media", "shot.webp"))
+	as.NoError(err)
+	as.Equal("RIFF", string(data[:4]))
+	_, err = os.Stat(filepath.Join(folder, "media", "shot.png"))
+	as.True(os.IsNotExist(err))
+	data, err = os.ReadFile(filepath.Join(folder, "media", originalsDir, "shot.png"))
+	as.NoError(err)
+	as.Equal(buf.Bytes(), data)
+
+	markdown, err := os.ReadFile(filepath.Join(kit.StorageRoot, clip.Path))
+	as.NoError(err)
+	as.Contains(string(markdown), "![Shot](media/shot.webp)")
+
+	var config ConfigResponse
+	client.Get("/api/v1/config").JSON(&config)
+	as.True(config.Images.ConvertToWebp)
+}
+
+func (as *ActionSuite) Test_CreateClip_ChecksImageFormat() {
+	kit := testkit.New(as.T())
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+	clip := func(filename string, data []byte) *testkit.Response {
+		return client.Post("/api/v1/clips", ClipPayload{
+			Title:  "Shot",
+			URL:    "https://example.com/" + filename,
+			Mode:   "screenshot",
+			Images: []ImagePayload{{Filename: filename, Data: base64.StdEncoding.EncodeToString(data)}},
+		})
+	}
+
+	res := clip("shot.png", []byte("<!DOCTYPE html><script>alert(1)</script>"))
+	as.Equal(http.StatusUnsupportedMediaType, res.Code, res.Body.String())
+	as.Contains(res.Body.String(), "text/html")
+	res = clip("shot.png", []byte("MZ\x90\x00\x03\x00\x00\x00"))
+	as.Equal(http.StatusUnsupportedMediaType, res.Code, res.Body.String())
+
+	// SVG is recognized but only accepted once allowed
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`)
+	res = clip("logo.svg", svg)
+	as.Equal(http.StatusUnsupportedMediaType, res.Code, res.Body.String())
+	as.Contains(res.Body.String(), "SVG")
+	kit.Config.Images.AllowedFormats = append(kit.Config.Images.AllowedFormats, "svg")
+	res = clip("logo.svg", svg)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+
+	// The content decides, not the name
+	res = clip("photo.jpg", []byte(pngMagic+"png bytes"))
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+
+	count, err := kit.DB.Count(&models.Clips{})
+	as.NoError(err)
+	as.Equal(2, count)
+}
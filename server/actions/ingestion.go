@@ -0,0 +1,155 @@
+package actions
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"server/internal/metrics"
+
+	"github.com/gobuffalo/buffalo"
+)
+
+// Rejected clip attempts are counted by reason so operators can tell when a
+// client (typically a new extension release) starts sending bad payloads.
+// Counts are served at /metrics and, with the latest rejections, in the
+// admin API.
+
+// Clip rejection reasons
+const (
+	rejectInvalidPayload = "invalid_payload" // Malformed body, image data or fields
+	rejectOversizeImage  = "oversize_image"  // An image over images.max_size_bytes
+	rejectQuota          = "quota"           // Over a total size limit (images, clip data, upload)
+	rejectAuth           = "auth"            // Missing or invalid credentials
+	rejectImageFormat    = "image_format"    // An image whose content is not in images.allowed_formats
+	rejectUnknownTags    = "unknown_tags"    // Tags outside the taxonomy (tags.taxonomy: reject)
+)
+
+// maxRecentRejections is how many rejections the admin API lists
+const maxRecentRejections = 50
+
+// clipRejections counts rejected clip attempts by reason
+var clipRejections = metrics.Default.NewCounterVec(
+	"webclipper_clip_rejections_total",
+	"Clip attempts rejected, by reason.",
+	"reason",
+	rejectInvalidPayload, rejectOversizeImage, rejectQuota, rejectAuth, rejectImageFormat,
+	rejectUnknownTags,
+)
+
+// metricsStarted is when counting started; counts reset with the process
+var metricsStarted = time.Now()
+
+// ClipRejection is a rejected clip attempt
+type ClipRejection struct {
+	Reason    string    `json:"reason"`
+	Error     string    `json:"error"`
+	Path      string    `json:"path"`
+	UserID    string    `json:"user_id,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// recentRejections keeps the latest rejections, newest last
+var recentRejections struct {
+	mu    sync.Mutex
+	items []ClipRejection
+}
+
+// recordRejection counts a rejected clip attempt
+func recordRejection(c buffalo.Context, reason, msg string) {
+	clipRejections.Inc(reason)
+
+	userID, _ := c.Value("user_id").(string)
+	rejection := ClipRejection{
+		Reason:    reason,
+		Error:     msg,
+		Path:      c.Request().URL.Path,
+		UserID:    userID,
+		UserAgent: c.Request().UserAgent(),
+		At:        time.Now(),
+	}
+
+	recentRejections.mu.Lock()
+	defer recentRejections.mu.Unlock()
+	recentRejections.items = append(recentRejections.items, rejection)
+	if len(recentRejections.items) > maxRecentRejections {
+		recentRejections.items = recentRejections.items[1:]
+	}
+}
+
+// rejectClip counts a rejected clip attempt and renders the error
+func rejectClip(c buffalo.Context, status int, reason, msg string) error {
+	recordRejection(c, reason, msg)
+	return c.Render(status, r.JSON(ClipResponse{
+		Success: false,
+		Error:   newErrorDetail(c, status, reason, msg),
+	}))
+}
+
+// isIngestionRequest reports whether the request saves a clip or uploads
+// one in chunks
+func isIngestionRequest(req *http.Request) bool {
+	if req.Method != http.MethodPost && req.Method != http.MethodPut {
+		return false
+	}
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	return path == "/api/v1/clips" || path == "/api/v1/quick-clip" || strings.HasPrefix(path, "/api/v1/uploads")
+}
+
+// ingestionAuthMiddleware counts clip attempts rejected by authMiddleware,
+// which it wraps
+func ingestionAuthMiddleware(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		err := next(c)
+		var herr buffalo.HTTPError
+		if errors.As(err, &herr) && herr.Status == http.StatusUnauthorized && isIngestionRequest(c.Request()) {
+			recordRejection(c, rejectAuth, herr.Error())
+		}
+		return err
+	}
+}
+
+// serveMetrics serves the instance metrics in the Prometheus text format,
+// when metrics.enabled is set. With metrics.token set, scrapers must send it
+// as a bearer token.
+func serveMetrics(c buffalo.Context) error {
+	cfg := GetConfig()
+	if cfg == nil || !cfg.Metrics.Enabled {
+		return c.Error(http.StatusNotFound, errors.New("metrics are not enabled"))
+	}
+	auth := []byte(c.Request().Header.Get("Authorization"))
+	if cfg.Metrics.Token != "" && subtle.ConstantTimeCompare(auth, []byte("Bearer "+cfg.Metrics.Token)) != 1 {
+		return c.Error(http.StatusUnauthorized, errors.New("invalid metrics token"))
+	}
+
+	metrics.Default.Handler().ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+// adminIngestionMetrics returns rejected clip attempts by reason since the
+// server started, with the latest rejections
+func adminIngestionMetrics(c buffalo.Context) error {
+	recentRejections.mu.Lock()
+	recent := make([]ClipRejection, len(recentRejections.items))
+	for i, item := range recentRejections.items {
+		recent[len(recent)-1-i] = item // Newest first
+	}
+	recentRejections.mu.Unlock()
+
+	var total uint64
+	rejected := clipRejections.Values()
+	for _, n := range rejected {
+		total += n
+	}
+
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"since":          metricsStarted,
+		"rejected":       rejected,
+		"rejected_total": total,
+		"recent":         recent,
+	}))
+}
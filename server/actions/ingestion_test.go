@@ -0,0 +1,83 @@
+package actions
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_IngestionMetrics() {
+	kit := testkit.New(as.T())
+	kit.Config.Images.MaxSizeBytes = 4
+	kit.Config.Images.MaxTotalBytes = 6
+	user := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{user.Email}
+	app := newKitApp(kit)
+	client := kit.Client(app, user)
+
+	before := clipRejections.Values()
+	image := func(data string) ImagePayload {
+		return ImagePayload{Filename: "a.png", Data: base64.StdEncoding.EncodeToString([]byte(data))}
+	}
+
+	res := client.Post("/api/v1/clips", strings.NewReader("{not json"))
+	as.Equal(http.StatusBadRequest, res.Code)
+	res = client.Post("/api/v1/clips", ClipPayload{Title: "Big", URL: "https://example.com", Images: []ImagePayload{image("too big")}})
+	as.Equal(http.StatusRequestEntityTooLarge, res.Code)
+	res = client.Post("/api/v1/clips", ClipPayload{Title: "Many", URL: "https://example.com", Images: []ImagePayload{image("four"), image("four")}})
+	as.Equal(http.StatusRequestEntityTooLarge, res.Code)
+	anonymous := kit.Client(app, user)
+	anonymous.Token = "wc_bogus"
+	as.Equal(http.StatusUnauthorized, anonymous.Post("/api/v1/clips", ClipPayload{Title: "Anon"}).Code)
+	as.Equal(http.StatusUnauthorized, anonymous.Get("/api/v1/clips").Code) // Not an ingestion
+
+	after := clipRejections.Values()
+	for _, reason := range []string{rejectInvalidPayload, rejectOversizeImage, rejectQuota, rejectAuth} {
+		as.Equal(before[reason]+1, after[reason], reason)
+	}
+
+	var stats struct {
+		Rejected map[string]uint64 `json:"rejected"`
+		Recent   []ClipRejection   `json:"recent"`
+	}
+	res = client.Get("/api/v1/admin/metrics/ingestion")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&stats)
+	as.Equal(after[rejectAuth], stats.Rejected[rejectAuth])
+	as.Equal(rejectAuth, stats.Recent[0].Reason)
+	as.Equal(rejectQuota, stats.Recent[1].Reason)
+	as.Equal(user.ID.String(), stats.Recent[1].UserID)
+
+	other := kit.Client(app, kit.CreateUser())
+	as.Equal(http.StatusForbidden, other.Get("/api/v1/admin/metrics/ingestion").Code)
+}
+
+func (as *ActionSuite) Test_ServeMetrics() {
+	kit := testkit.New(as.T())
+	app := newKitApp(kit)
+
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res := httptest.NewRecorder()
+		app.ServeHTTP(res, req)
+		return res
+	}
+
+	as.Equal(http.StatusNotFound, get("").Code)
+
+	kit.Config.Metrics.Enabled = true
+	res := get("")
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), `webclipper_clip_rejections_total{reason="oversize_image"}`)
+
+	kit.Config.Metrics.Token = "scrape"
+	as.Equal(http.StatusUnauthorized, get("").Code)
+	as.Equal(http.StatusUnauthorized, get("wrong").Code)
+	as.Equal(http.StatusOK, get("scrape").Code)
+}
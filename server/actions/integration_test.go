@@ -0,0 +1,79 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
+
+	"github.com/gobuffalo/buffalo"
+)
+
+// newKitApp builds the app on a testkit database and config, restoring the
+// global config when the test ends
+func newKitApp(kit *testkit.Kit) *buffalo.App {
+	saved := appConfig.Swap(kit.Config)
+	kit.T.Cleanup(func() { appConfig.Store(saved) })
+	kit.T.Cleanup(background.Wait) // Before the database is closed
+	return newApp(kit.DB)
+}
+
+func (as *ActionSuite) Test_Integration_ListAndArchiveClips() {
+	kit := testkit.New(as.T())
+	app := newKitApp(kit)
+
+	user := kit.CreateUser()
+	first := kit.CreateClip(user, testkit.WithTags("go"))
+	kit.CreateClip(user, testkit.WithTags("rust"))
+	kit.CreateClip(kit.CreateUser()) // Another user's clip is never listed
+
+	client := kit.Client(app, user)
+
+	var list ListClipsResponse
+	res := client.Get("/api/v1/clips?tag=go")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&list)
+	as.Len(list.Clips, 1)
+	as.Equal(first.ID.String(), list.Clips[0].ID)
+
+	res = client.Post("/api/v1/clips/bulk", BulkPayload{Operation: bulkArchive, IDs: []string{first.ID.String()}})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+
+	res = client.Get("/api/v1/clips")
+	res.JSON(&list)
+	as.Len(list.Clips, 1)
+	as.NotEqual(first.ID.String(), list.Clips[0].ID)
+}
+
+func (as *ActionSuite) Test_Integration_GetClipContent() {
+	kit := testkit.New(as.T())
+	app := newKitApp(kit)
+
+	user := kit.CreateUser()
+	clip := kit.CreateClip(user, testkit.WithContent("Hello from the testkit."))
+
+	var detail ClipDetail
+	res := kit.Client(app, user).Get("/api/v1/clips/" + clip.ID.String())
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&detail)
+	as.Contains(detail.Content, "Hello from the testkit.")
+}
+
+func (as *ActionSuite) Test_Integration_SameSiteClipsGetSeparateFolders() {
+	kit := testkit.New(as.T())
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	paths := map[string]bool{}
+	for _, u := range []string{"https://example.com/a", "https://example.com/b"} {
+		var resp ClipResponse
+		res := client.Post("/api/v1/clips", ClipPayload{Title: "Page", URL: u, Markdown: "Text", Mode: "article"})
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		res.JSON(&resp)
+		paths[filepath.Dir(resp.Path)] = true
+
+		_, err := os.Stat(filepath.Join(kit.StorageRoot, resp.Path))
+		as.NoError(err)
+	}
+	as.Len(paths, 2)
+}
@@ -0,0 +1,233 @@
+package actions
+
+import (
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Failed or interrupted captures can leave folders behind in web-clips. The
+// janitor removes them once they are older than storage.janitor_min_age_hours,
+// so captures in progress are never touched.
+
+// Reasons a leftover is removed
+const (
+	leftoverEmpty   = "empty"   // Clip folder without any file
+	leftoverPartial = "partial" // Clip folder without a page file, not referenced by a clip
+	leftoverStaging = "staging" // Capture interrupted before it was moved into place
+	leftoverUpload  = "upload"  // Chunked upload file whose session is gone
+	leftoverMedia   = "media"   // Media blob no clip links to anymore
+)
+
+// clipFolderPattern matches the YYYYMMDD_HHMMSS_ prefix of clip folder names.
+// Folders named by a folder template are never taken for leftovers.
+var clipFolderPattern = regexp.MustCompile(`^\d{8}_\d{6}_`)
+
+// Leftover is a file or folder removed by the janitor
+type Leftover struct {
+	Path       string    `json:"path"`
+	Reason     string    `json:"reason"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// CleanClipFolders removes leftovers of failed captures older than
+// storage.janitor_min_age_hours from every clip directory and returns them.
+// With dryRun nothing is removed.
+func CleanClipFolders(db *pop.Connection, dryRun bool) ([]Leftover, error) {
+	minAge := GetConfig().Storage.JanitorMinAgeHours
+	if minAge < 0 {
+		return nil, nil
+	}
+	cutoff := time.Now().Add(-time.Duration(minAge) * time.Hour)
+
+	dirs, err := clipDirectories(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var cleaned []Leftover
+	for _, dir := range dirs {
+		leftovers, err := findLeftovers(db, dir, cutoff)
+		if err != nil {
+			log.Printf("janitor: failed to scan %s: %v", dir, err)
+			continue
+		}
+		for _, leftover := range leftovers {
+			if !dryRun {
+				if err := os.RemoveAll(leftover.Path); err != nil {
+					log.Printf("janitor: failed to remove %s: %v", leftover.Path, err)
+					continue
+				}
+			}
+			cleaned = append(cleaned, leftover)
+		}
+	}
+	return cleaned, nil
+}
+
+// clipDirectories returns the default clip directory and the users' own
+func clipDirectories(db *pop.Connection) ([]string, error) {
+	users := models.Users{}
+	if err := db.Where("clip_directory IS NOT NULL AND clip_directory != ''").All(&users); err != nil {
+		return nil, err
+	}
+
+	dirs := []string{GetConfig().Storage.BasePath}
+	seen := map[string]bool{filepath.Clean(dirs[0]): true}
+	for _, user := range users {
+		dir := filepath.Clean(user.ClipDirectory.String)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, user.ClipDirectory.String)
+		}
+	}
+	return dirs, nil
+}
+
+// findLeftovers lists the leftovers in a clip directory last modified before
+// cutoff
+func findLeftovers(db *pop.Connection, clipDir string, cutoff time.Time) ([]Leftover, error) {
+	webClips := filepath.Join(clipDir, "web-clips")
+	entries, err := os.ReadDir(webClips)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var leftovers []Leftover
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		path := filepath.Join(webClips, name)
+
+		if name == ".uploads" {
+			uploads, err := findOrphanUploads(db, path, cutoff)
+			if err != nil {
+				return nil, err
+			}
+			leftovers = append(leftovers, uploads...)
+			continue
+		}
+		if name == mediaStoreDir {
+			blobs, err := findOrphanBlobs(path, cutoff)
+			if err != nil {
+				return nil, err
+			}
+			leftovers = append(leftovers, blobs...)
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+
+		reason := ""
+		switch {
+		case strings.HasPrefix(name, ".staging-"):
+			reason = leftoverStaging
+		case clipFolderPattern.MatchString(name):
+			if reason, err = clipFolderLeftover(db, path); err != nil {
+				return nil, err
+			}
+		}
+		if reason != "" {
+			leftovers = append(leftovers, Leftover{Path: path, Reason: reason, ModifiedAt: info.ModTime()})
+		}
+	}
+	return leftovers, nil
+}
+
+// clipFolderLeftover returns why a clip folder is a leftover, or "" when it
+// is a clip (or something else worth keeping)
+func clipFolderLeftover(db *pop.Connection, path string) (string, error) {
+	referenced, err := db.Where("path = ?", filepath.Join("web-clips", filepath.Base(path))).Exists(&models.Clip{})
+	if err != nil || referenced {
+		return "", err
+	}
+
+	files, pages := 0, 0
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		files++
+		if filepath.Dir(p) == path && (strings.HasSuffix(p, ".md") || strings.HasSuffix(p, ".html")) {
+			pages++
+		}
+		return nil
+	})
+	switch {
+	case err != nil:
+		return "", err
+	case files == 0:
+		return leftoverEmpty, nil
+	case pages == 0:
+		return leftoverPartial, nil
+	}
+	return "", nil
+}
+
+// findOrphanUploads lists chunked upload files older than cutoff without a
+// session (expired sessions are left to PurgeExpiredUploads)
+func findOrphanUploads(db *pop.Connection, dir string, cutoff time.Time) ([]Leftover, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var leftovers []Leftover
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if id, err := uuid.FromString(entry.Name()); err == nil {
+			exists, err := db.Where("id = ?", id).Exists(&models.UploadSession{})
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				continue
+			}
+		}
+		leftovers = append(leftovers, Leftover{Path: filepath.Join(dir, entry.Name()), Reason: leftoverUpload, ModifiedAt: info.ModTime()})
+	}
+	return leftovers, nil
+}
+
+// adminCleanClipFolders runs the janitor now (dry_run=true only lists what it
+// would remove)
+func adminCleanClipFolders(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	dryRun := isDryRun(c)
+
+	cleaned, err := CleanClipFolders(tx, dryRun)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if cleaned == nil {
+		cleaned = []Leftover{}
+	}
+
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"dry_run":       dryRun,
+		"min_age_hours": GetConfig().Storage.JanitorMinAgeHours,
+		"removed":       cleaned,
+	}))
+}
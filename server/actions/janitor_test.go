@@ -0,0 +1,92 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/gofrs/uuid"
+)
+
+func (as *ActionSuite) Test_CleanClipFolders() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{user.Email}
+	client := kit.Client(newKitApp(kit), user)
+	webClips := filepath.Join(kit.StorageRoot, "web-clips")
+	old := time.Now().Add(-48 * time.Hour)
+
+	mkdir := func(name string, files ...string) string {
+		path := filepath.Join(webClips, name)
+		as.NoError(os.MkdirAll(filepath.Join(path, "media"), 0755))
+		for _, file := range files {
+			as.NoError(os.WriteFile(filepath.Join(path, file), []byte("x"), 0644))
+		}
+		as.NoError(os.Chtimes(path, old, old))
+		return path
+	}
+
+	clip := kit.CreateClip(user)
+	as.NoError(os.Chtimes(filepath.Join(kit.StorageRoot, clip.Path), old, old))
+	empty := mkdir("20260101_120000_example-com_aaaaaaaa")
+	partial := mkdir("20260101_120000_example-com_bbbbbbbb", "media/shot.png")
+	unindexed := mkdir("20260101_120000_example-com_cccccccc", "page.md") // Kept: might be imported
+	staging := mkdir(".staging-123", "page.md")
+	mkdir("Notes") // Not a clip folder
+	recent := filepath.Join(webClips, "20260101_120000_example-com_dddddddd")
+	as.NoError(os.MkdirAll(recent, 0755))
+
+	session := &models.UploadSession{ID: uuid.Must(uuid.NewV4()), UserID: user.ID, ContentType: "application/json", ExpiresAt: time.Now().Add(time.Hour)}
+	as.NoError(kit.DB.Create(session))
+	uploads := filepath.Join(webClips, ".uploads")
+	as.NoError(os.MkdirAll(uploads, 0755))
+	orphan := filepath.Join(uploads, uuid.Must(uuid.NewV4()).String())
+	active := filepath.Join(uploads, session.ID.String())
+	for _, path := range []string{orphan, active} {
+		as.NoError(os.WriteFile(path, []byte("{}"), 0644))
+		as.NoError(os.Chtimes(path, old, old))
+	}
+
+	var resp struct {
+		DryRun  bool       `json:"dry_run"`
+		Removed []Leftover `json:"removed"`
+	}
+	res := client.Post("/api/v1/admin/janitor?dry_run=true", nil)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&resp)
+	as.True(resp.DryRun)
+	reasons := map[string]string{}
+	for _, l := range resp.Removed {
+		reasons[l.Path] = l.Reason
+	}
+	as.Equal(map[string]string{
+		empty:   leftoverEmpty,
+		partial: leftoverPartial,
+		staging: leftoverStaging,
+		orphan:  leftoverUpload,
+	}, reasons)
+	_, err := os.Stat(empty)
+	as.NoError(err)
+
+	cleaned, err := CleanClipFolders(kit.DB, false)
+	as.NoError(err)
+	as.Len(cleaned, 4)
+	for path := range reasons {
+		_, err := os.Stat(path)
+		as.True(os.IsNotExist(err), path)
+	}
+	for _, path := range []string{filepath.Join(kit.StorageRoot, clip.Path), unindexed, recent, active, filepath.Join(webClips, "Notes")} {
+		_, err := os.Stat(path)
+		as.NoError(err, path)
+	}
+
+	kit.Config.Storage.JanitorMinAgeHours = -1
+	mkdir("20260101_120000_example-com_eeeeeeee")
+	cleaned, err = CleanClipFolders(kit.DB, false)
+	as.NoError(err)
+	as.Empty(cleaned)
+}
@@ -0,0 +1,368 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Heavy work is queued as jobs in the database and run by a pool of workers
+// after the request returned. A failed attempt is queued again with a growing
+// delay until the configured attempts run out.
+
+// jobPollInterval is how often idle workers look for jobs queued by other
+// processes or due for a retry
+const jobPollInterval = 5 * time.Second
+
+// jobQueue wakes up idle workers when jobs are committed
+var jobQueue = &eventSignal{}
+
+// jobHandler runs one attempt of a job and returns its result
+type jobHandler func(ctx context.Context, db *pop.Connection, job *models.Job) (interface{}, error)
+
+// jobHandlers maps job types to their handler
+var jobHandlers = map[string]jobHandler{
+	models.JobProcessClip:  processClipJob,
+	models.JobFetchFavicon: fetchFaviconJob,
+	models.JobCloudUpload:  cloudUploadJob,
+	models.JobSendToKindle: kindleDeliveryJob,
+}
+
+// JobResponse is the API representation of a job
+type JobResponse struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Status     string          `json:"status"`
+	Attempts   int             `json:"attempts"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// jobToResponse converts a job to its API representation
+func jobToResponse(j *models.Job) JobResponse {
+	resp := JobResponse{
+		ID:        j.ID.String(),
+		Type:      j.Type,
+		Status:    j.Status,
+		Attempts:  j.Attempts,
+		Error:     j.Error.String,
+		CreatedAt: j.CreatedAt,
+	}
+	if j.Result.Valid {
+		resp.Result = json.RawMessage(j.Result.String)
+	}
+	if j.StartedAt.Valid {
+		resp.StartedAt = &j.StartedAt.Time
+	}
+	if j.FinishedAt.Valid {
+		resp.FinishedAt = &j.FinishedAt.Time
+	}
+	return resp
+}
+
+// getJob returns the status of one of the user's jobs
+func getJob(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	jobID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid job ID"))
+	}
+
+	job, err := models.FindJobByIDAndUser(tx, jobID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("job not found"))
+	}
+
+	return c.Render(http.StatusOK, r.JSON(jobToResponse(job)))
+}
+
+// respondAsync reports whether the client asked for heavy work to be done
+// after the response (RFC 7240 "Prefer: respond-async")
+func respondAsync(c buffalo.Context) bool {
+	for _, prefer := range c.Request().Header.Values("Prefer") {
+		for _, pref := range strings.Split(prefer, ",") {
+			if strings.EqualFold(strings.TrimSpace(pref), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// enqueueJob queues a job in the request's transaction. Workers are woken up
+// once the transaction is committed.
+func enqueueJob(c buffalo.Context, tx *pop.Connection, userID uuid.UUID, jobType string, payload interface{}) (*models.Job, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.Job{
+		ID:      uuid.Must(uuid.NewV4()),
+		UserID:  userID,
+		Type:    jobType,
+		Payload: string(body),
+		Status:  models.JobQueued,
+		RunAt:   time.Now(),
+	}
+	if err := tx.Create(job); err != nil {
+		return nil, err
+	}
+
+	onCommit(c, jobQueue.notify)
+	return job, nil
+}
+
+// runNextJob claims a due job and runs it. It reports false when no job was
+// due.
+func runNextJob(db *pop.Connection) bool {
+	jobs, err := models.FindRunnableJobs(db, time.Now(), 10)
+	if err != nil {
+		log.Printf("jobs: failed to load queued jobs: %v", err)
+		return false
+	}
+
+	for i := range jobs {
+		claimed, err := models.ClaimJob(db, &jobs[i], time.Now())
+		if err != nil {
+			log.Printf("job %s: failed to claim: %v", jobs[i].ID, err)
+			return false
+		}
+		if claimed {
+			runJob(db, &jobs[i])
+			return true
+		}
+	}
+	return false
+}
+
+// runQueuedJobs runs due jobs until none is left
+func runQueuedJobs(db *pop.Connection) {
+	for runNextJob(db) {
+	}
+}
+
+// runJob runs an attempt of a claimed job and records its outcome
+func runJob(db *pop.Connection, job *models.Job) {
+	cfg := GetConfig()
+	result, err := callJobHandler(db, job, time.Duration(cfg.Jobs.TimeoutSeconds)*time.Second)
+
+	now := time.Now()
+	switch {
+	case err == nil:
+		job.Status = models.JobSucceeded
+		job.Error = nulls.String{}
+		job.FinishedAt = nulls.NewTime(now)
+		if body, err := json.Marshal(result); err == nil && result != nil {
+			job.Result = nulls.NewString(string(body))
+		}
+	case job.Attempts < cfg.Jobs.MaxAttempts:
+		log.Printf("job %s: attempt %d failed: %v", job.ID, job.Attempts, err)
+		job.Status = models.JobQueued
+		job.Error = nulls.NewString(err.Error())
+		job.RunAt = now.Add(jobRetryDelay(job.Attempts))
+	default:
+		log.Printf("job %s: failed: %v", job.ID, err)
+		job.Status = models.JobFailed
+		job.Error = nulls.NewString(err.Error())
+		job.FinishedAt = nulls.NewTime(now)
+	}
+
+	if err := db.Update(job); err != nil {
+		log.Printf("job %s: failed to update: %v", job.ID, err)
+	}
+}
+
+// callJobHandler runs the job's handler, turning a panic into an error so it
+// doesn't take the worker down
+func callJobHandler(db *pop.Connection, job *models.Job, timeout time.Duration) (result interface{}, err error) {
+	handler, ok := jobHandlers[job.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown job type: %s", job.Type)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return handler(ctx, db, job)
+}
+
+// jobRetryDelay is how long a job waits after its nth failed attempt
+func jobRetryDelay(attempt int) time.Duration {
+	return time.Duration(attempt*attempt) * 30 * time.Second
+}
+
+// requeueLostJobs queues again jobs left running longer than an attempt can
+// take, whose worker was stopped (typically by a restart)
+func requeueLostJobs(db *pop.Connection) {
+	timeout := time.Duration(GetConfig().Jobs.TimeoutSeconds) * time.Second
+	jobs, err := models.FindStaleJobs(db, time.Now().Add(-timeout-time.Minute))
+	if err != nil {
+		log.Printf("jobs: failed to load lost jobs: %v", err)
+		return
+	}
+
+	for i := range jobs {
+		jobs[i].Status = models.JobQueued
+		jobs[i].RunAt = time.Now()
+		if err := db.Update(&jobs[i]); err != nil {
+			log.Printf("job %s: failed to requeue: %v", jobs[i].ID, err)
+		}
+	}
+}
+
+// StartJobWorkers queues again jobs lost by a previous run and starts the
+// configured number of workers, which run jobs until the context is cancelled
+func StartJobWorkers(ctx context.Context) {
+	cfg := GetConfig()
+	if cfg == nil {
+		return
+	}
+
+	requeueLostJobs(models.DB)
+	for i := 0; i < cfg.Jobs.Workers; i++ {
+		go func() {
+			for {
+				wake := jobQueue.wait()
+				if runNextJob(models.DB) {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-wake:
+				case <-time.After(jobPollInterval):
+				}
+			}
+		}()
+	}
+}
+
+// processClipArgs is the payload of a clip processing job
+type processClipArgs struct {
+	ClipID uuid.UUID `json:"clip_id"`
+}
+
+// processClipResult is the result of a clip processing job
+type processClipResult struct {
+	ClipID        string `json:"clip_id"`
+	TextExtracted bool   `json:"text_extracted"`
+}
+
+// processClipJob extracts the text of a clip saved asynchronously, as
+// saveClip does inline: it becomes searchable, and OCR output is appended to
+// the clip's markdown.
+func processClipJob(ctx context.Context, db *pop.Connection, job *models.Job) (interface{}, error) {
+	var args processClipArgs
+	if err := json.Unmarshal([]byte(job.Payload), &args); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	clip := &models.Clip{}
+	if err := db.Where("id = ? AND user_id = ?", args.ClipID, job.UserID).First(clip); err != nil {
+		return nil, fmt.Errorf("clip %s: %w", args.ClipID, err)
+	}
+	user := &models.User{}
+	if err := db.Find(user, job.UserID); err != nil {
+		return nil, err
+	}
+
+	cfg := GetConfig()
+	folderPath := filepath.Join(clipRoot(cfg, user, clip), clip.Path)
+	attachments, err := clipAttachments(clip, folderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := extractText(ctx, cfg, clip.Mode, attachments)
+	if err != nil {
+		return nil, err
+	}
+
+	// The row is updated first so a retry after a failed write doesn't append
+	// the recognized text twice
+	text.apply(clip)
+	if err := db.Update(clip); err != nil {
+		return nil, err
+	}
+	if section := recognizedTextSection(text.Recognized); section != "" {
+		if err := appendClipMarkdown(folderPath, section); err != nil {
+			return nil, err
+		}
+		if err := clip.LoadTags(db); err != nil {
+			return nil, err
+		}
+		if err := writeClipSidecar(folderPath, clip); err != nil {
+			log.Printf("Failed to write the sidecar of clip %s: %v", clip.ID, err)
+		}
+	}
+
+	return processClipResult{
+		ClipID:        clip.ID.String(),
+		TextExtracted: clip.ContentText.Valid,
+	}, nil
+}
+
+// clipAttachments lists the files in a clip's media folder (or a note's
+// attachments)
+func clipAttachments(clip *models.Clip, folderPath string) ([]ImagePayload, error) {
+	mediaDir, _ := clipMediaDir(clip, folderPath)
+	names, err := clipMediaFiles(clip, folderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var attachments []ImagePayload
+	for _, name := range names {
+		attachments = append(attachments, ImagePayload{
+			Filename: name,
+			file:     filepath.Join(mediaDir, name),
+		})
+	}
+	return attachments, nil
+}
+
+// appendClipMarkdown appends content to the markdown file of a clip folder,
+// or to a note
+func appendClipMarkdown(folderPath, content string) error {
+	matches, err := filepath.Glob(filepath.Join(folderPath, "*.md"))
+	if isNotePath(folderPath) {
+		matches = []string{folderPath}
+	}
+	if err != nil || len(matches) == 0 {
+		return fmt.Errorf("no markdown file in %s", folderPath)
+	}
+
+	data, err := readClipFile(matches[0])
+	if err != nil {
+		return err
+	}
+	return writeFileSync(matches[0], append(data, content...), 0644)
+}
@@ -0,0 +1,130 @@
+package actions
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"server/internal/testkit"
+	"server/models"
+)
+
+// newOCRServer serves the http OCR engine, failing the first failures calls
+func (as *ActionSuite) newOCRServer(kit *testkit.Kit, failures int32) *int32 {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("Recognized later"))
+	}))
+	as.T().Cleanup(srv.Close)
+	kit.Config.OCR.Engine = "http"
+	kit.Config.OCR.HTTPURL = srv.URL
+	return &calls
+}
+
+func (as *ActionSuite) Test_CreateClip_Async() {
+	kit := testkit.New(as.T())
+	calls := as.newOCRServer(kit, 0)
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+	client.Header = http.Header{"Prefer": {"respond-async, wait=10"}}
+
+	payload := ClipPayload{
+		Title:  "Async shot",
+		URL:    "https://example.com/async",
+		Mode:   "screenshot",
+		Images: []ImagePayload{{Filename: "shot.png", Data: base64.StdEncoding.EncodeToString([]byte(pngMagic))}},
+	}
+	var clip ClipResponse
+	res := client.Post("/api/v1/clips", payload)
+	as.Equal(http.StatusAccepted, res.Code, res.Body.String())
+	res.JSON(&clip)
+	as.True(clip.Success)
+	as.NotEmpty(clip.JobID)
+	as.Equal(int32(0), atomic.LoadInt32(calls))
+
+	var job JobResponse
+	res = client.Get("/api/v1/jobs/" + clip.JobID)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&job)
+	as.Equal(models.JobQueued, job.Status)
+	as.Equal(models.JobProcessClip, job.Type)
+
+	runQueuedJobs(kit.DB)
+
+	client.Get("/api/v1/jobs/" + clip.JobID).JSON(&job)
+	as.Equal(models.JobSucceeded, job.Status)
+	as.Equal(1, job.Attempts)
+	as.NotNil(job.FinishedAt)
+	as.JSONEq(`{"clip_id":"`+clip.ID+`","text_extracted":true}`, string(job.Result))
+
+	saved := &models.Clip{}
+	as.NoError(kit.DB.Find(saved, clip.ID))
+	as.Equal("Recognized later", saved.ContentText.String)
+	data, err := os.ReadFile(filepath.Join(kit.StorageRoot, clip.Path))
+	as.NoError(err)
+	as.Contains(string(data), recognizedTextSection("Recognized later"))
+
+	other := kit.Client(newKitApp(kit), kit.CreateUser())
+	as.Equal(http.StatusNotFound, other.Get("/api/v1/jobs/"+clip.JobID).Code)
+	as.Equal(http.StatusBadRequest, client.Get("/api/v1/jobs/bogus").Code)
+
+	// Without the preference, text is extracted inline
+	client.Header = nil
+	payload.URL = "https://example.com/sync"
+	res = client.Post("/api/v1/clips", payload)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	var inline ClipResponse
+	res.JSON(&inline)
+	as.Empty(inline.JobID)
+	as.Equal(int32(2), atomic.LoadInt32(calls))
+}
+
+func (as *ActionSuite) Test_Jobs_Retry() {
+	kit := testkit.New(as.T())
+	kit.Config.Jobs.MaxAttempts = 2
+	calls := as.newOCRServer(kit, 2)
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+	client.Header = http.Header{"Prefer": {"respond-async"}}
+
+	payload := ClipPayload{
+		Title:  "Flaky",
+		URL:    "https://example.com/flaky",
+		Mode:   "screenshot",
+		Images: []ImagePayload{{Filename: "shot.png", Data: base64.StdEncoding.EncodeToString([]byte(pngMagic))}},
+	}
+	var clip ClipResponse
+	client.Post("/api/v1/clips", payload).JSON(&clip)
+
+	// A failed attempt is queued again for later
+	runQueuedJobs(kit.DB)
+	job := &models.Job{}
+	as.NoError(kit.DB.Find(job, clip.JobID))
+	as.Equal(models.JobQueued, job.Status)
+	as.Equal(1, job.Attempts)
+	as.Contains(job.Error.String, "503")
+	as.True(job.RunAt.After(time.Now()))
+
+	// Until the attempts run out
+	as.NoError(kit.DB.RawQuery("UPDATE jobs SET run_at = ? WHERE id = ?", time.Now(), job.ID).Exec())
+	runQueuedJobs(kit.DB)
+	as.NoError(kit.DB.Reload(job))
+	as.Equal(models.JobFailed, job.Status)
+	as.Equal(2, job.Attempts)
+	as.True(job.FinishedAt.Valid)
+	as.Equal(int32(2), atomic.LoadInt32(calls))
+
+	// Jobs lost to a restart are queued again
+	as.NoError(kit.DB.RawQuery("UPDATE jobs SET status = ?, started_at = ? WHERE id = ?", models.JobRunning, time.Now().Add(-time.Hour), job.ID).Exec())
+	requeueLostJobs(kit.DB)
+	as.NoError(kit.DB.Reload(job))
+	as.Equal(models.JobQueued, job.Status)
+}
@@ -0,0 +1,211 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"server/internal/mailer"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// DeliveryResponse is the response for Send to Kindle requests and status checks
+type DeliveryResponse struct {
+	ID          string     `json:"id"`
+	ClipID      string     `json:"clip_id"`
+	DeviceEmail string     `json:"device_email"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	SentAt      *time.Time `json:"sent_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// sendClipToKindle queues delivery of a clip's EPUB export to the user's Kindle address
+func sendClipToKindle(c buffalo.Context) error {
+	cfg := GetConfig()
+	if cfg == nil || !cfg.Kindle.Enabled {
+		return c.Error(http.StatusNotFound, fmt.Errorf("send to kindle is not enabled"))
+	}
+	if !mailer.New(cfg.SMTP).Configured() {
+		return c.Error(http.StatusServiceUnavailable, fmt.Errorf("SMTP is not configured"))
+	}
+
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	clipID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid clip ID"))
+	}
+
+	// Fetch clip with ownership check
+	clip, err := models.FindClipByIDAndUser(tx, clipID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("clip not found"))
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, userID); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if !user.KindleEmail.Valid || user.KindleEmail.String == "" {
+		return c.Error(http.StatusUnprocessableEntity, fmt.Errorf("no Kindle address configured for this account"))
+	}
+
+	delivery := &models.KindleDelivery{
+		ID:          uuid.Must(uuid.NewV4()),
+		UserID:      userID,
+		ClipID:      clip.ID,
+		DeviceEmail: user.KindleEmail.String,
+		Status:      models.DeliveryPending,
+	}
+	verrs, err := tx.ValidateAndCreate(delivery)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+	if _, err := enqueueJob(c, tx, userID, models.JobSendToKindle, kindleDeliveryArgs{DeliveryID: delivery.ID}); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	return c.Render(http.StatusAccepted, r.JSON(deliveryToResponse(delivery)))
+}
+
+// getDelivery returns the status of a Send to Kindle delivery
+func getDelivery(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	deliveryID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid delivery ID"))
+	}
+
+	delivery, err := models.FindDeliveryByIDAndUser(tx, deliveryID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("delivery not found"))
+	}
+
+	return c.Render(http.StatusOK, r.JSON(deliveryToResponse(delivery)))
+}
+
+// kindleDeliveryArgs is the payload of a Send to Kindle job
+type kindleDeliveryArgs struct {
+	DeliveryID uuid.UUID `json:"delivery_id"`
+}
+
+// kindleDeliveryJob builds the EPUB and emails it, recording progress on the
+// delivery row. A failed attempt leaves the delivery pending until the job's
+// attempts run out.
+func kindleDeliveryJob(ctx context.Context, db *pop.Connection, job *models.Job) (interface{}, error) {
+	var args kindleDeliveryArgs
+	if err := json.Unmarshal([]byte(job.Payload), &args); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	delivery, err := models.FindDeliveryByIDAndUser(db, args.DeliveryID, job.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("delivery %s: %w", args.DeliveryID, err)
+	}
+	if delivery.Status == models.DeliverySent {
+		return deliveryToResponse(delivery), nil // Sent by an attempt that failed to finish
+	}
+
+	delivery.Status = models.DeliverySending
+	if err := db.Update(delivery); err != nil {
+		return nil, err
+	}
+
+	err = sendDelivery(db, delivery)
+	if err != nil {
+		delivery.Status = models.DeliveryPending
+		if job.Attempts >= GetConfig().Jobs.MaxAttempts {
+			delivery.Status = models.DeliveryFailed
+		}
+		delivery.Error = nulls.NewString(err.Error())
+	} else {
+		delivery.Status = models.DeliverySent
+		delivery.Error = nulls.String{}
+		delivery.SentAt = nulls.NewTime(time.Now())
+	}
+	if err := db.Update(delivery); err != nil {
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return deliveryToResponse(delivery), nil
+}
+
+// sendDelivery emails the EPUB of a delivery's clip, with its highlights
+func sendDelivery(db *pop.Connection, delivery *models.KindleDelivery) error {
+	clip, err := models.FindClipByIDAndUser(db, delivery.ClipID, delivery.UserID)
+	if err != nil {
+		return fmt.Errorf("clip %s: %w", delivery.ClipID, err)
+	}
+	user := &models.User{}
+	if err := db.Find(user, delivery.UserID); err != nil {
+		return err
+	}
+	highlights, err := models.FindHighlightsByClip(db, clip.ID)
+	if err != nil {
+		return err
+	}
+
+	cfg := GetConfig()
+	folderPath := filepath.Join(clipRoot(cfg, user, clip), clip.Path)
+	data, err := buildClipEPUB(clip, folderPath, highlights, nil)
+	if err != nil {
+		return err
+	}
+
+	filename := slugify(clip.Title)
+	if filename == "" {
+		filename = "clip"
+	}
+
+	return mailer.New(cfg.SMTP).Send(mailer.Message{
+		To:      delivery.DeviceEmail,
+		Subject: clip.Title,
+		Body:    fmt.Sprintf("Sent from Web Clipper.\n\nOriginal URL: %s\n", clip.URL),
+		Attachments: []mailer.Attachment{{
+			Filename:    filename + ".epub",
+			ContentType: "application/epub+zip",
+			Data:        data,
+		}},
+	})
+}
+
+// deliveryToResponse converts a delivery model to its API representation
+func deliveryToResponse(d *models.KindleDelivery) DeliveryResponse {
+	resp := DeliveryResponse{
+		ID:          d.ID.String(),
+		ClipID:      d.ClipID.String(),
+		DeviceEmail: d.DeviceEmail,
+		Status:      d.Status,
+		Error:       d.Error.String,
+		CreatedAt:   d.CreatedAt,
+	}
+	if d.SentAt.Valid {
+		resp.SentAt = &d.SentAt.Time
+	}
+	return resp
+}
@@ -0,0 +1,113 @@
+package actions
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"server/internal/config"
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+)
+
+func (as *ActionSuite) Test_SendToKindle_Unauthorized() {
+	res := as.JSON("/api/v1/clips/550e8400-e29b-41d4-a716-446655440000/send-to-kindle").Post(nil)
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_GetDelivery_Unauthorized() {
+	res := as.JSON("/api/v1/deliveries/550e8400-e29b-41d4-a716-446655440000").Get()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_SendToKindle_Job() {
+	kit := testkit.New(as.T())
+	kit.Config.Kindle.Enabled = true
+	kit.Config.Jobs.MaxAttempts = 3
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	as.Require().NoError(err)
+	closed.Close()
+	kit.Config.SMTP = config.SMTPConfig{Host: "127.0.0.1", Port: closed.Addr().(*net.TCPAddr).Port, From: "clipper@example.com"}
+	user := kit.CreateUser(func(u *models.User) { u.KindleEmail = nulls.NewString("reader@kindle.com") })
+	client := kit.Client(newKitApp(kit), user)
+	clip := kit.CreateClip(user, testkit.WithContent("# Kindle\n\nSome text."))
+
+	// The delivery is queued as a job with its row, in the request
+	var delivery DeliveryResponse
+	res := client.Post("/api/v1/clips/"+clip.ID.String()+"/send-to-kindle", nil)
+	as.Equal(http.StatusAccepted, res.Code, res.Body.String())
+	res.JSON(&delivery)
+	as.Equal(models.DeliveryPending, delivery.Status)
+	job := &models.Job{}
+	as.NoError(kit.DB.Where("type = ?", models.JobSendToKindle).First(job))
+
+	// A failed attempt leaves it pending, for the job to retry
+	runQueuedJobs(kit.DB)
+	row := &models.KindleDelivery{}
+	as.NoError(kit.DB.Find(row, delivery.ID))
+	as.Equal(models.DeliveryPending, row.Status)
+	as.Contains(row.Error.String, "failed to connect")
+
+	messages := as.newSMTPServer(kit)
+	as.NoError(kit.DB.RawQuery("UPDATE jobs SET run_at = ? WHERE id = ?", time.Now(), job.ID).Exec())
+	runQueuedJobs(kit.DB)
+	res = client.Get("/api/v1/deliveries/" + delivery.ID)
+	as.Equal(http.StatusOK, res.Code)
+	res.JSON(&delivery)
+	as.Equal(models.DeliverySent, delivery.Status)
+	as.Empty(delivery.Error)
+	as.NotNil(delivery.SentAt)
+	as.Contains(<-messages, "To: reader@kindle.com")
+}
+
+// newSMTPServer points the kit's SMTP settings at a server accepting every
+// message, sent on the returned channel
+func (as *ActionSuite) newSMTPServer(kit *testkit.Kit) <-chan string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	as.Require().NoError(err)
+	as.T().Cleanup(func() { l.Close() })
+	kit.Config.SMTP.Port = l.Addr().(*net.TCPAddr).Port
+
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+			reply("220 localhost")
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					break
+				}
+				switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+				case "DATA":
+					reply("354 go ahead")
+					var msg strings.Builder
+					for {
+						line, err := r.ReadString('\n')
+						if err != nil || line == ".\r\n" {
+							break
+						}
+						msg.WriteString(line)
+					}
+					messages <- msg.String()
+					reply("250 ok")
+				case "QUIT":
+					reply("221 bye")
+				default:
+					reply("250 ok")
+				}
+			}
+			conn.Close()
+		}
+	}()
+	return messages
+}
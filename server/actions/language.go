@@ -0,0 +1,34 @@
+package actions
+
+import (
+	"strings"
+
+	"server/internal/lang"
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+)
+
+// languageSample caps the text language detection reads
+const languageSample = 16 * 1024
+
+// withLanguage sets the language of a clip request: the one its page
+// declares, else the one detected from its title and content
+func withLanguage(req *ClipPayload) {
+	req.lang = req.meta.Language
+	if req.lang != "" {
+		return
+	}
+	text := req.Title + "\n\n" + req.Markdown
+	if len(text) > languageSample {
+		text = strings.ToValidUTF8(text[:languageSample], "")
+	}
+	req.lang = lang.Detect(text)
+}
+
+// applyLanguage records a request's language on its clip, when known
+func applyLanguage(clip *models.Clip, req ClipPayload) {
+	if req.lang != "" {
+		clip.Language = nulls.NewString(req.lang)
+	}
+}
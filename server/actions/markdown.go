@@ -0,0 +1,39 @@
+package actions
+
+import (
+	"log"
+	"strconv"
+
+	"server/internal/markdown"
+)
+
+// renderMarkdown renders clip markdown to HTML with the configured renderer
+// and extensions (markdown section of the config)
+func renderMarkdown(src string) string {
+	cfg := GetConfig().Markdown
+	r, err := markdown.New(cfg.Renderer, markdown.Options{Disabled: cfg.Disable})
+	if err != nil {
+		log.Printf("markdown: %v; using the default renderer", err)
+		r, _ = markdown.New("", markdown.Options{})
+	}
+	return r.Render(src)
+}
+
+// withTOC adds a table of contents to clip markdown that has enough headings
+// and none yet. want overrides markdown.toc.enabled when set.
+func withTOC(src string, want *bool) string {
+	cfg := GetConfig().Markdown.TOC
+	if want != nil && !*want || want == nil && !cfg.Enabled {
+		return src
+	}
+	return markdown.InsertTOC(src, markdown.TOCOptions{MinHeadings: cfg.MinHeadings, MaxDepth: cfg.MaxDepth})
+}
+
+// tocParam reads a toc=true|false parameter, nil when absent or invalid
+func tocParam(value string) *bool {
+	want, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil
+	}
+	return &want
+}
@@ -0,0 +1,65 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_RenderMarkdown() {
+	kit := testkit.New(as.T())
+	newKitApp(kit) // Uses the kit's config
+
+	src := "Energy $E=mc^2$[^1]\n\n[^1]: Einstein, 1905.\n"
+	out := renderMarkdown(src)
+	as.Contains(out, `<span class="math math-inline">\(E=mc^2\)</span>`)
+	as.Contains(out, `<li id="fn-1">`)
+
+	kit.Config.Markdown.Disable = []string{"math", "footnotes"}
+	out = renderMarkdown(src)
+	as.Contains(out, "$E=mc^2$")
+	as.NotContains(out, "footnote")
+
+	// A bad config falls back to the defaults rather than failing exports
+	kit.Config.Markdown.Renderer = "missing"
+	as.Contains(renderMarkdown(src), "math-inline")
+}
+
+func (as *ActionSuite) Test_ClipTOC() {
+	kit := testkit.New(as.T())
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+	article := "# Guide\n\n## Setup\n\ntext\n\n## Usage\n\ntext\n\n### Flags\n\ntext\n"
+	save := func(url string, toc *bool) (ClipResponse, string) {
+		var clip ClipResponse
+		res := client.Post("/api/v1/clips", ClipPayload{Title: "Guide", URL: url, Mode: "article", Markdown: article, TOC: toc})
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		res.JSON(&clip)
+		page, err := os.ReadFile(filepath.Join(kit.StorageRoot, clip.Path))
+		as.NoError(err)
+		return clip, string(page)
+	}
+
+	plain, page := save("https://example.com/plain", nil)
+	as.NotContains(page, "## Contents")
+
+	yes := true
+	_, page = save("https://example.com/toc", &yes)
+	as.Contains(page, "# Guide\n\n<!-- toc -->\n## Contents\n\n- [Setup](#setup)\n- [Usage](#usage)\n  - [Flags](#flags)\n")
+
+	// Exports add one on request
+	res := client.Get("/api/v1/clips/" + plain.ID + "/export?format=markdown&toc=true")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	as.Contains(res.Body.String(), "- [Usage](#usage)")
+	as.True(strings.HasPrefix(res.Body.String(), "---\n"))
+
+	// The instance default applies when the request doesn't say
+	kit.Config.Markdown.TOC.Enabled = true
+	no := false
+	_, page = save("https://example.com/default", nil)
+	as.Contains(page, "## Contents")
+	_, page = save("https://example.com/opt-out", &no)
+	as.NotContains(page, "## Contents")
+}
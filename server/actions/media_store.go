@@ -0,0 +1,244 @@
+package actions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+)
+
+// With storage.dedupe_media, media files are stored once under their SHA-256
+// in web-clips/.media/sha256/<2 hex>/<hash> and hard-linked into the clips'
+// media folders. Clip folders stay self-contained for other tools, while the
+// same image clipped from many articles takes the space of one. The link count
+// is the reference count: a blob linked only from the store is garbage, left
+// to the janitor. Media files are never written in place, as that would
+// change every clip sharing them.
+
+// mediaStoreDir is the content-addressed store, next to the clip folders
+const mediaStoreDir = ".media"
+
+// mediaStore returns the store used by the clip folder at folderPath: the
+// one in the web-clips folder it is in, however deeply a folder template
+// nested it
+func mediaStore(folderPath string) string {
+	for dir := filepath.Dir(folderPath); filepath.Dir(dir) != dir; dir = filepath.Dir(dir) {
+		if filepath.Base(dir) == "web-clips" {
+			return filepath.Join(dir, mediaStoreDir)
+		}
+	}
+	return filepath.Join(filepath.Dir(folderPath), mediaStoreDir)
+}
+
+// mediaBlobPath returns where content with the given hash is stored
+func mediaBlobPath(store, hash string) string {
+	return filepath.Join(store, "sha256", hash[:2], hash)
+}
+
+// hashFile returns the hex SHA-256 of a file's content, decrypted, so
+// encrypted copies of the same media share a blob
+func hashFile(path string) (string, error) {
+	f, err := openClipFile(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// linkMedia makes the media file at path a link to its blob in store: the
+// file becomes the blob when its content is new, and is replaced by a link
+// to the existing blob otherwise. It returns the bytes saved by replacing it.
+// With dryRun nothing changes; planned then records the blobs that would have
+// been added, so later duplicates of them are counted.
+func linkMedia(store, path string, dryRun bool, planned map[string]bool) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	hash, err := hashFile(path)
+	if err != nil {
+		return 0, err
+	}
+	blob := mediaBlobPath(store, hash)
+
+	existing, err := os.Stat(blob)
+	if os.IsNotExist(err) {
+		if dryRun {
+			if planned[blob] {
+				return info.Size(), nil
+			}
+			planned[blob] = true
+			return 0, nil
+		}
+		if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+			return 0, err
+		}
+		return 0, os.Link(path, blob)
+	}
+	if err != nil || os.SameFile(info, existing) {
+		return 0, err
+	}
+	if dryRun {
+		return info.Size(), nil
+	}
+
+	// Link next to the file, then swap it in atomically
+	tmp := path + ".link"
+	os.Remove(tmp)
+	if err := os.Link(blob, tmp); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// linkCount returns the number of hard links to a file (1 when unknown)
+func linkCount(info fs.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Nlink)
+	}
+	return 1
+}
+
+// findOrphanBlobs lists the blobs of a store last modified before cutoff that
+// no clip links to anymore
+func findOrphanBlobs(store string, cutoff time.Time) ([]Leftover, error) {
+	var leftovers []Leftover
+	err := filepath.WalkDir(store, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if linkCount(info) == 1 && info.ModTime().Before(cutoff) {
+			leftovers = append(leftovers, Leftover{Path: path, Reason: leftoverMedia, ModifiedAt: info.ModTime()})
+		}
+		return nil
+	})
+	return leftovers, err
+}
+
+// MediaDedupeResult summarizes a pass of DedupeMedia
+type MediaDedupeResult struct {
+	Files        int   `json:"files"`         // Media files looked at
+	Linked       int   `json:"linked"`        // Duplicates replaced by a link
+	SavedBytes   int64 `json:"saved_bytes"`   // Space freed by the links
+	RemovedBlobs int   `json:"removed_blobs"` // Blobs no clip linked to
+}
+
+// DedupeMedia moves the media of every clip, trashed ones included, into the
+// content-addressed store, linking duplicates to a single copy, and removes
+// the blobs nothing links to. With dryRun nothing changes.
+func DedupeMedia(db *pop.Connection, dryRun bool) (*MediaDedupeResult, error) {
+	clips := models.Clips{}
+	if err := db.Order("created_at ASC").All(&clips); err != nil {
+		return nil, err
+	}
+
+	cfg := GetConfig()
+	users := map[string]*models.User{}
+	stores := map[string]bool{}
+	planned := map[string]bool{}
+	result := &MediaDedupeResult{}
+	for i := range clips {
+		clip := &clips[i]
+		user, ok := users[clip.UserID.String()]
+		if !ok {
+			user = &models.User{}
+			if err := db.Find(user, clip.UserID); err != nil {
+				return nil, err
+			}
+			users[clip.UserID.String()] = user
+		}
+
+		if clip.IsNote() {
+			continue // Attachments of notes are not deduplicated
+		}
+		folder := filepath.Join(clipRoot(cfg, user, clip), clip.Path)
+		if clip.DeletedAt.Valid {
+			folder = filepath.Join(clipRoot(cfg, user, clip), trashDirName, clip.Path)
+		}
+		store := mediaStore(folder)
+		stores[store] = true
+
+		media := filepath.Join(folder, "media")
+		err := filepath.WalkDir(media, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == media {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() || !d.Type().IsRegular() {
+				return nil
+			}
+			saved, err := linkMedia(store, path, dryRun, planned)
+			if err != nil {
+				// Typically a store on another filesystem; the file stays as is
+				log.Printf("storage dedupe: failed to link %s: %v", path, err)
+				return nil
+			}
+			result.Files++
+			if saved > 0 {
+				result.Linked++
+				result.SavedBytes += saved
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for store := range stores {
+		orphans, err := findOrphanBlobs(store, time.Now())
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, orphan := range orphans {
+			if !dryRun {
+				if err := os.Remove(orphan.Path); err != nil {
+					return nil, err
+				}
+			}
+			result.RemovedBlobs++
+		}
+	}
+	return result, nil
+}
+
+// adminDedupeMedia runs DedupeMedia (dry_run=true only reports what it would do)
+func adminDedupeMedia(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	dryRun := isDryRun(c)
+
+	result, err := DedupeMedia(tx, dryRun)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"dry_run": dryRun,
+		"result":  result,
+	}))
+}
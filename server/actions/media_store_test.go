@@ -0,0 +1,95 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_CreateClip_DedupesMedia() {
+	kit := testkit.New(as.T())
+	kit.Config.Storage.DedupeMedia = true
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	var buf bytes.Buffer
+	as.NoError(png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 10, 10))))
+	hero := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	var paths []string
+	for _, url := range []string{"https://example.com/one", "https://example.com/two"} {
+		var clip ClipResponse
+		res := client.Post("/api/v1/clips", ClipPayload{
+			Title:  "Article",
+			URL:    url,
+			Mode:   "article",
+			Images: []ImagePayload{{Filename: "hero.png", Data: hero}},
+		})
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		res.JSON(&clip)
+		paths = append(paths, filepath.Join(kit.StorageRoot, filepath.Dir(clip.Path), "media", "hero.png"))
+	}
+
+	first, err := os.Stat(paths[0])
+	as.NoError(err)
+	second, err := os.Stat(paths[1])
+	as.NoError(err)
+	as.True(os.SameFile(first, second))
+	as.Equal(uint64(3), linkCount(first)) // Both clips and the store
+
+	blobs, err := filepath.Glob(filepath.Join(kit.StorageRoot, "web-clips", mediaStoreDir, "sha256", "*", "*"))
+	as.NoError(err)
+	as.Len(blobs, 1)
+}
+
+func (as *ActionSuite) Test_DedupeMedia() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{user.Email}
+	client := kit.Client(newKitApp(kit), user)
+
+	hero := bytes.Repeat([]byte("hero"), 256)
+	one := kit.CreateClip(user, testkit.WithMedia("hero.jpg", hero))
+	two := kit.CreateClip(user, testkit.WithMedia("banner.jpg", hero), testkit.WithMedia("logo.png", []byte("logo")))
+	heroPaths := []string{
+		filepath.Join(kit.StorageRoot, one.Path, "media", "hero.jpg"),
+		filepath.Join(kit.StorageRoot, two.Path, "media", "banner.jpg"),
+	}
+	sameFile := func() bool {
+		a, errA := os.Stat(heroPaths[0])
+		b, errB := os.Stat(heroPaths[1])
+		return errA == nil && errB == nil && os.SameFile(a, b)
+	}
+
+	var resp struct {
+		DryRun bool              `json:"dry_run"`
+		Result MediaDedupeResult `json:"result"`
+	}
+	res := client.Post("/api/v1/admin/storage/dedupe?dry_run=true", nil)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&resp)
+	as.Equal(MediaDedupeResult{Files: 3, Linked: 1, SavedBytes: int64(len(hero))}, resp.Result)
+	as.False(sameFile())
+
+	res = client.Post("/api/v1/admin/storage/dedupe", nil)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&resp)
+	as.Equal(1, resp.Result.Linked)
+	as.True(sameFile())
+	data, err := os.ReadFile(heroPaths[1])
+	as.NoError(err)
+	as.Equal(hero, data)
+
+	// Once no clip links to a blob, it is removed
+	as.NoError(os.RemoveAll(filepath.Join(kit.StorageRoot, one.Path)))
+	as.NoError(os.RemoveAll(filepath.Join(kit.StorageRoot, two.Path)))
+	res = client.Post("/api/v1/admin/storage/dedupe", nil)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&resp)
+	as.Equal(2, resp.Result.RemovedBlobs)
+}
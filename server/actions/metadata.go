@@ -0,0 +1,70 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"server/internal/extract"
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+)
+
+// withPageMetadata completes the metadata of a clip request (set by the
+// server-side fetcher) with what its HTML declares, for fullpage captures
+func withPageMetadata(req *ClipPayload) {
+	if req.HTML == "" {
+		return
+	}
+	base, _ := url.Parse(req.URL)
+	req.meta = req.meta.Merge(extract.ParseMetadata(req.HTML, base))
+}
+
+// applyMetadata records a request's page metadata on its clip
+func applyMetadata(clip *models.Clip, req ClipPayload) {
+	if req.meta.IsZero() {
+		return
+	}
+	data, err := json.Marshal(req.meta)
+	if err != nil {
+		return
+	}
+	clip.Metadata = nulls.NewString(string(data))
+}
+
+// clipMetadata returns the page metadata of a clip, or nil when it has none
+func clipMetadata(clip *models.Clip) *extract.Metadata {
+	if !clip.Metadata.Valid {
+		return nil
+	}
+	meta := &extract.Metadata{}
+	if err := json.Unmarshal([]byte(clip.Metadata.String), meta); err != nil || meta.IsZero() {
+		return nil
+	}
+	return meta
+}
+
+// writeMetadataFrontmatter adds the page metadata to a clip's frontmatter
+func writeMetadataFrontmatter(sb *strings.Builder, meta extract.Metadata) {
+	for _, field := range []struct{ key, value string }{
+		{"description", meta.Description},
+		{"author", meta.Author},
+		{"published", meta.Published},
+		{"site_name", meta.SiteName},
+		{"image", meta.Image},
+	} {
+		if field.value != "" {
+			sb.WriteString(fmt.Sprintf("%s: %q\n", field.key, field.value))
+		}
+	}
+}
+
+// clipAuthor returns the author of a clip's page, or its site when unknown
+func clipAuthor(clip *models.Clip) string {
+	if meta := clipMetadata(clip); meta != nil && meta.Author != "" {
+		return meta.Author
+	}
+	return extractDomain(clip.URL)
+}
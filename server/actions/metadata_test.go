@@ -0,0 +1,101 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/extract"
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_CreateClip_PageMetadata() {
+	kit := testkit.New(as.T())
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	page := `<html><head>
+<meta property="og:description" content="Notes on the Analytical Engine">
+<meta name="author" content="Ada Lovelace">
+<meta property="article:published_time" content="1843-09-01T00:00:00Z">
+<meta property="og:image" content="/engine.png">
+</head><body><p>Saved</p></body></html>`
+	var clip ClipResponse
+	res := client.Post("/api/v1/clips", ClipPayload{Title: "Sketch", URL: "https://example.com/notes", Mode: "fullpage", HTML: page})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+
+	// The companion markdown file carries the frontmatter
+	md := filepath.Join(kit.StorageRoot, filepath.Dir(clip.Path), "sketch.md")
+	data, err := os.ReadFile(md)
+	as.NoError(err)
+	as.Contains(string(data), "author: \"Ada Lovelace\"\n")
+	as.Contains(string(data), "published: \"1843-09-01T00:00:00Z\"\n")
+	as.Contains(string(data), "image: \"https://example.com/engine.png\"\n")
+
+	var detail ClipSummary
+	client.Get("/api/v1/clips/" + clip.ID).JSON(&detail)
+	as.Equal(&extract.Metadata{
+		Description: "Notes on the Analytical Engine",
+		Author:      "Ada Lovelace",
+		Published:   "1843-09-01T00:00:00Z",
+		Image:       "https://example.com/engine.png",
+	}, detail.Metadata)
+
+	// Clips are found by their metadata
+	var list ListClipsResponse
+	res = client.Get("/api/v1/clips?q=Analytical")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&list)
+	as.Len(list.Clips, 1)
+
+	// Pages without metadata have none
+	res = client.Post("/api/v1/clips", ClipPayload{Title: "Plain", URL: "https://example.com/plain", Mode: "article", Markdown: "# Plain"})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+	detail = ClipSummary{}
+	client.Get("/api/v1/clips/" + clip.ID).JSON(&detail)
+	as.Nil(detail.Metadata)
+}
+
+func (as *ActionSuite) Test_CreateClip_Language() {
+	kit := testkit.New(as.T())
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	// Markdown clips are detected from their text
+	var clip ClipResponse
+	res := client.Post("/api/v1/clips", ClipPayload{
+		Title:    "Le moteur analytique",
+		URL:      "https://example.fr/moteur",
+		Mode:     "article",
+		Markdown: "# Le moteur analytique\n\nLa machine de Babbage est une des premières machines pour le calcul, et elle ne fut pas construite de son vivant.",
+	})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+	data, err := os.ReadFile(filepath.Join(kit.StorageRoot, clip.Path))
+	as.NoError(err)
+	as.Contains(string(data), "lang: fr\n")
+
+	// Pages declaring their language are trusted
+	page := `<html lang="de-CH"><head></head><body><p>Short</p></body></html>`
+	res = client.Post("/api/v1/clips", ClipPayload{Title: "Notiz", URL: "https://example.ch/notiz", Mode: "fullpage", HTML: page})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+	var detail ClipSummary
+	client.Get("/api/v1/clips/" + clip.ID).JSON(&detail)
+	as.Equal("de", detail.Language)
+
+	// Too little text stays undetected
+	res = client.Post("/api/v1/clips", ClipPayload{Title: "Link", URL: "https://example.com/link", Mode: "bookmark"})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+
+	var list ListClipsResponse
+	client.Get("/api/v1/clips?lang=fr").JSON(&list)
+	as.Len(list.Clips, 1)
+	as.Equal("fr", list.Clips[0].Language)
+	list = ListClipsResponse{}
+	client.Get("/api/v1/clips?lang=DE").JSON(&list)
+	as.Len(list.Clips, 1)
+	list = ListClipsResponse{}
+	client.Get("/api/v1/clips").JSON(&list)
+	as.Len(list.Clips, 3)
+}
@@ -0,0 +1,524 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"server/internal/imaging"
+	"server/internal/s3"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Collections marked public are published as a read-only static site, so a
+// reading list can be served by any web server or an S3 website bucket
+// without exposing the API. The site is rebuilt from scratch on every run:
+//
+//	index.html, index.json                       the public collections
+//	<collection>/index.html, index.json          a collection's clips
+//	<collection>/<clip id>/index.html, index.json, media/
+//	<collection>/<clip id>/card.png              with mirror.social_cards
+//
+// Archived, trashed and taken down clips, highlights and notes are never
+// published.
+
+// errMirrorNotConfigured is returned when the mirror has nowhere to publish to
+var errMirrorNotConfigured = errors.New("mirror.directory or mirror.s3 must be configured")
+
+// MirrorResult summarizes a publication of the mirror
+type MirrorResult struct {
+	Collections int      `json:"collections"`
+	Clips       int      `json:"clips"`
+	Files       int      `json:"files"`
+	Targets     []string `json:"targets"` // Directory and/or s3://bucket/prefix
+}
+
+// mirrorIndex is index.json at the root of the mirror
+type mirrorIndex struct {
+	Title       string                 `json:"title"`
+	GeneratedAt time.Time              `json:"generated_at"`
+	Collections []mirrorCollectionItem `json:"collections"`
+}
+
+type mirrorCollectionItem struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Path  string `json:"path"` // Relative to the mirror root
+	Clips int    `json:"clips"`
+}
+
+// mirrorCollection is a collection's index.json
+type mirrorCollection struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	GeneratedAt time.Time        `json:"generated_at"`
+	Clips       []mirrorClipItem `json:"clips"`
+}
+
+type mirrorClipItem struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	URL       string    `json:"url"`
+	Domain    string    `json:"domain"`
+	Tags      []string  `json:"tags"`
+	Excerpt   string    `json:"excerpt,omitempty"`
+	Path      string    `json:"path"` // Relative to the collection
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// mirrorClip is a clip's index.json
+type mirrorClip struct {
+	mirrorClipItem
+	Markdown string `json:"markdown"`
+}
+
+// mirrorSocial is the OpenGraph description of a clip page
+type mirrorSocial struct {
+	Title, Description, SiteName string
+	URL                          string // Absolute URL of the page, when mirror.base_url is set
+	Width, Height                int
+}
+
+var mirrorTemplates = template.Must(template.New("mirror").Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.}}</title>
+<style>body{max-width:44rem;margin:2rem auto;padding:0 1rem;font-family:system-ui,sans-serif;line-height:1.5}img{max-width:100%}.meta{color:#666;font-size:.9em}</style>
+{{end}}
+{{define "header"}}{{template "head" .}}</head>
+<body>
+{{end}}
+{{define "index"}}{{template "header" .Title}}<h1>{{.Title}}</h1>
+<ul>
+{{range .Collections}}<li><a href="{{.Path}}">{{.Name}}</a> <span class="meta">({{.Clips}})</span></li>
+{{end}}</ul>
+</body>
+</html>
+{{end}}
+{{define "collection"}}{{template "header" .Name}}<p><a href="../">&larr; All collections</a></p>
+<h1>{{.Name}}</h1>
+{{range .Clips}}<article>
+<h2><a href="{{.Path}}">{{.Title}}</a></h2>
+<p class="meta">{{.Domain}} &middot; {{.CreatedAt.Format "2006-01-02"}}{{range .Tags}} &middot; #{{.}}{{end}}</p>
+{{if .Excerpt}}<p>{{.Excerpt}}</p>{{end}}
+</article>
+{{end}}</body>
+</html>
+{{end}}
+{{define "clip"}}{{template "head" .Clip.Title}}{{with .Social}}<meta property="og:type" content="article">
+<meta property="og:title" content="{{.Title}}">
+{{if .Description}}<meta property="og:description" content="{{.Description}}">
+{{end}}<meta property="og:site_name" content="{{.SiteName}}">
+{{if .URL}}<meta property="og:url" content="{{.URL}}">
+<meta property="og:image" content="{{.URL}}card.png">
+{{else}}<meta property="og:image" content="card.png">
+{{end}}<meta property="og:image:width" content="{{.Width}}">
+<meta property="og:image:height" content="{{.Height}}">
+<meta name="twitter:card" content="summary_large_image">
+{{end}}</head>
+<body>
+{{with .Collection}}<p><a href="../">&larr; {{.}}</a></p>
+{{end}}<h1>{{.Clip.Title}}</h1>
+<p class="meta"><a href="{{.Clip.URL}}">{{.Clip.Domain}}</a> &middot; {{.Clip.CreatedAt.Format "2006-01-02"}}{{range .Clip.Tags}} &middot; #{{.}}{{end}}</p>
+{{.Body}}
+{{with .Report}}<footer class="meta"><details><summary>Report this page</summary>
+<form method="post" action="{{.Action}}">
+<p><select name="reason">{{range .Reasons}}<option>{{.}}</option>{{end}}</select></p>
+<p><textarea name="details" rows="4" cols="50" maxlength="2000" placeholder="What is wrong with this page?"></textarea></p>
+<p><input type="email" name="email" placeholder="Your email (optional)"> <button type="submit">Report</button></p>
+</form></details></footer>
+{{end}}</body>
+</html>
+{{end}}
+{{define "share-password"}}{{template "header" "Protected page"}}<h1>Protected page</h1>
+<p>This page is protected by a passphrase.</p>
+{{with .Message}}<p class="meta">{{.}}</p>
+{{end}}<form method="post" action="{{.Action}}">
+<input type="password" name="password" autofocus required>
+<button type="submit">Open</button>
+</form>
+</body>
+</html>
+{{end}}
+{{define "report-sent"}}{{template "header" "Report sent"}}<h1>Thank you</h1>
+<p>Your report was sent and will be reviewed.</p>
+</body>
+</html>
+{{end}}`))
+
+// PublishMirror builds the mirror and publishes it to mirror.directory and
+// mirror.s3, replacing what was published before
+func PublishMirror(ctx context.Context, db *pop.Connection) (*MirrorResult, error) {
+	cfg := GetConfig().Mirror
+	bucket := s3.New(cfg.S3)
+	if cfg.Directory == "" && !bucket.Configured() {
+		return nil, errMirrorNotConfigured
+	}
+
+	// Build next to the target directory so it can be swapped in with a rename
+	parent := ""
+	if cfg.Directory != "" {
+		parent = filepath.Dir(filepath.Clean(cfg.Directory))
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return nil, err
+		}
+	}
+	build, err := os.MkdirTemp(parent, ".mirror-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(build)
+
+	result, err := BuildMirror(db, build)
+	if err != nil {
+		return nil, err
+	}
+
+	if bucket.Configured() {
+		if err := uploadMirror(ctx, bucket, build, cfg.S3.Prefix); err != nil {
+			return nil, err
+		}
+		result.Targets = append(result.Targets, "s3://"+path.Join(cfg.S3.Bucket, cfg.S3.Prefix))
+	}
+	if cfg.Directory != "" {
+		if err := swapDir(build, cfg.Directory); err != nil {
+			return nil, err
+		}
+		result.Targets = append(result.Targets, cfg.Directory)
+	}
+	return result, nil
+}
+
+// BuildMirror writes the mirror of every public collection into dir
+func BuildMirror(db *pop.Connection, dir string) (*MirrorResult, error) {
+	collections, err := models.FindPublicCollections(db)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	result := &MirrorResult{}
+	index := mirrorIndex{Title: GetConfig().Mirror.Title, GeneratedAt: now, Collections: []mirrorCollectionItem{}}
+	users := map[uuid.UUID]*models.User{}
+	slugs := map[string]bool{}
+
+	for _, collection := range collections {
+		slug := slugify(collection.Name)
+		if slug == "" {
+			slug = "collection"
+		}
+		if slugs[slug] {
+			slug += "-" + collection.ID.String()[:8]
+		}
+		slugs[slug] = true
+
+		user, ok := users[collection.UserID]
+		if !ok {
+			user = &models.User{}
+			if err := db.Find(user, collection.UserID); err != nil {
+				return nil, err
+			}
+			users[collection.UserID] = user
+		}
+
+		base := ""
+		if url := GetConfig().Mirror.BaseURL; url != "" {
+			base = strings.TrimSuffix(url, "/") + "/" + slug + "/"
+		}
+		page, files, err := buildMirrorCollection(db, user, collection, filepath.Join(dir, slug), base, now)
+		if err != nil {
+			return nil, fmt.Errorf("collection %s: %w", collection.Name, err)
+		}
+		index.Collections = append(index.Collections, mirrorCollectionItem{
+			ID:    collection.ID.String(),
+			Name:  collection.Name,
+			Path:  slug + "/",
+			Clips: len(page.Clips),
+		})
+		result.Collections++
+		result.Clips += len(page.Clips)
+		result.Files += files
+	}
+
+	if err := writeMirrorPage(dir, "index", index, index); err != nil {
+		return nil, err
+	}
+	result.Files += 2
+	return result, nil
+}
+
+// buildMirrorCollection writes a collection's pages and those of its clips,
+// returning its index and the number of files written. base is the public
+// URL of the collection, if known.
+func buildMirrorCollection(db *pop.Connection, user *models.User, collection models.Collection, dir, base string, now time.Time) (*mirrorCollection, int, error) {
+	clips := models.Clips{}
+	err := db.Where("collection_id = ? AND deleted_at IS NULL AND archived_at IS NULL AND disabled_at IS NULL", collection.ID).
+		Order("created_at DESC").All(&clips)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	page := &mirrorCollection{ID: collection.ID.String(), Name: collection.Name, GeneratedAt: now, Clips: []mirrorClipItem{}}
+	files := 0
+	for i := range clips {
+		clip := &clips[i]
+		if err := clip.LoadTags(db); err != nil {
+			return nil, 0, err
+		}
+		item := mirrorClipItem{
+			ID:        clip.ID.String(),
+			Title:     clip.Title,
+			URL:       clip.URL,
+			Domain:    clip.Domain,
+			Tags:      clip.Tags,
+			Excerpt:   clip.Excerpt.String,
+			Path:      clip.ID.String() + "/",
+			CreatedAt: clip.CreatedAt,
+		}
+		if item.Tags == nil {
+			item.Tags = []string{}
+		}
+
+		url := ""
+		if base != "" {
+			url = base + item.Path
+		}
+		n, err := buildMirrorClip(user, clip, item, collection.Name, filepath.Join(dir, clip.ID.String()), url)
+		if err != nil {
+			return nil, 0, fmt.Errorf("clip %s: %w", clip.ID, err)
+		}
+		page.Clips = append(page.Clips, item)
+		files += n
+	}
+
+	if err := writeMirrorPage(dir, "collection", page, page); err != nil {
+		return nil, 0, err
+	}
+	return page, files + 2, nil
+}
+
+// buildMirrorClip writes a clip's page and copies its media, returning the
+// number of files written. url is the public URL of the page, if known.
+func buildMirrorClip(user *models.User, clip *models.Clip, item mirrorClipItem, collection, dir, url string) (int, error) {
+	cfg := GetConfig()
+	folder := filepath.Join(clipRoot(cfg, user, clip), clip.Path)
+	markdown, err := readClipMarkdown(folder)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	markdown = withTOC(stripFrontmatter(markdown), nil)
+
+	data := map[string]interface{}{
+		"Clip":       item,
+		"Collection": collection,
+		"Body":       template.HTML(renderMarkdown(markdown)),
+	}
+	files := 2
+	if cfg.Mirror.SocialCards {
+		card, err := socialCard(cfg, user, clip, folder, markdown)
+		if err != nil {
+			return 0, err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return 0, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, "card.png"), card, 0644); err != nil {
+			return 0, err
+		}
+		files++
+
+		social := mirrorSocial{
+			Title:       item.Title,
+			Description: item.Excerpt,
+			SiteName:    cfg.Mirror.Title,
+			URL:         url,
+			Width:       imaging.CardWidth,
+			Height:      imaging.CardHeight,
+		}
+		if meta := clipMetadata(clip); social.Description == "" && meta != nil {
+			social.Description = meta.Description
+		}
+		data["Social"] = social
+	}
+	if err := writeMirrorPage(dir, "clip", mirrorClip{item, markdown}, data); err != nil {
+		return 0, err
+	}
+
+	// Media, without the originals kept aside by image processing (nor, for
+	// a note, the other notes' attachments)
+	media, prefix := clipMediaDir(clip, folder)
+	err = filepath.WalkDir(media, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == media {
+				return nil
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(media, p)
+		if d.IsDir() {
+			if rel == originalsDir || (prefix != "" && p != media) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(rel, prefix) {
+			return nil
+		}
+		content, err := readClipFile(p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, "media", rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		files++
+		return os.WriteFile(target, content, 0644)
+	})
+	return files, err
+}
+
+// writeMirrorPage writes index.json (v) and index.html (the template name
+// rendered with data) into dir
+func writeMirrorPage(dir, name string, v, data interface{}) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	js, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.json"), js, 0644); err != nil {
+		return err
+	}
+	var html bytes.Buffer
+	if err := mirrorTemplates.ExecuteTemplate(&html, name, data); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "index.html"), html.Bytes(), 0644)
+}
+
+// uploadMirror uploads the files of dir under prefix, then deletes the
+// objects under prefix left from earlier publications
+func uploadMirror(ctx context.Context, bucket *s3.Client, dir, prefix string) error {
+	if prefix != "" && prefix[len(prefix)-1] != '/' {
+		prefix += "/"
+	}
+
+	uploaded := map[string]bool{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		key := prefix + filepath.ToSlash(rel)
+		if err := bucket.Put(ctx, key, data, mime.TypeByExtension(filepath.Ext(p))); err != nil {
+			return err
+		}
+		uploaded[key] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	keys, err := bucket.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if !uploaded[key] {
+			if err := bucket.Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// swapDir replaces dst with src; the old dst is removed once src is in place
+func swapDir(src, dst string) error {
+	old := ""
+	if _, err := os.Stat(dst); err == nil {
+		old = fmt.Sprintf("%s.old-%d", dst, time.Now().UnixNano())
+		if err := os.Rename(dst, old); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(src, dst); err != nil {
+		if old != "" {
+			os.Rename(old, dst)
+		}
+		return err
+	}
+	if err := os.Chmod(dst, 0755); err != nil {
+		return err
+	}
+	if old != "" {
+		return os.RemoveAll(old)
+	}
+	return nil
+}
+
+// StartMirror publishes the mirror every mirror.interval_minutes until the
+// context is cancelled
+func StartMirror(ctx context.Context) {
+	if GetConfig() == nil || !GetConfig().Mirror.Enabled {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(GetConfig().Mirror.IntervalMinutes) * time.Minute)
+		defer ticker.Stop()
+		for {
+			if result, err := PublishMirror(ctx, models.DB); err != nil {
+				log.Printf("mirror: publication failed: %v", err)
+			} else {
+				log.Printf("mirror: published %d clips of %d collections to %v", result.Clips, result.Collections, result.Targets)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// adminPublishMirror publishes the mirror now
+func adminPublishMirror(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	result, err := PublishMirror(c, tx)
+	if errors.Is(err, errMirrorNotConfigured) {
+		return c.Error(http.StatusConflict, err)
+	}
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(result))
+}
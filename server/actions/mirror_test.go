@@ -0,0 +1,126 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gofrs/uuid"
+)
+
+func (as *ActionSuite) Test_PublishMirror() {
+	kit := testkit.New(as.T())
+	site := filepath.Join(as.T().TempDir(), "site")
+	user := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{user.Email}
+	client := kit.Client(newKitApp(kit), user)
+
+	res := client.Post("/api/v1/admin/mirror", nil)
+	as.Equal(http.StatusConflict, res.Code)
+	kit.Config.Mirror.Directory = site
+
+	var public, private CollectionResponse
+	client.Post("/api/v1/collections", CollectionPayload{Name: "Reading List", Public: true}).JSON(&public)
+	client.Post("/api/v1/collections", CollectionPayload{Name: "Private"}).JSON(&private)
+	inCollection := func(id string) testkit.ClipOption {
+		return testkit.WithClip(func(c *models.Clip) { c.CollectionID = nulls.NewUUID(uuid.FromStringOrNil(id)) })
+	}
+	clip := kit.CreateClip(user, inCollection(public.ID), testkit.WithTags("go"),
+		testkit.WithContent("# Hello\n\n![Pic](media/pic.png)"), testkit.WithMedia("pic.png", []byte("png")))
+	kit.CreateClip(user, inCollection(public.ID), testkit.WithClip(func(c *models.Clip) { c.ArchivedAt = nulls.NewTime(time.Now()) }))
+	secret := kit.CreateClip(user, inCollection(private.ID))
+
+	var result MirrorResult
+	res = client.Post("/api/v1/admin/mirror", nil)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&result)
+	as.Equal(1, result.Collections)
+	as.Equal(1, result.Clips)
+	as.Equal([]string{site}, result.Targets)
+
+	var index mirrorIndex
+	data, err := os.ReadFile(filepath.Join(site, "index.json"))
+	as.NoError(err)
+	as.NoError(json.Unmarshal(data, &index))
+	as.Len(index.Collections, 1)
+	as.Equal("reading-list/", index.Collections[0].Path)
+
+	clipDir := filepath.Join(site, "reading-list", clip.ID.String())
+	page, err := os.ReadFile(filepath.Join(clipDir, "index.html"))
+	as.NoError(err)
+	as.Contains(string(page), "Hello</h1>")
+	as.Contains(string(page), `src="media/pic.png"`)
+	as.NotContains(string(page), "title:") // Frontmatter is not published
+	media, err := os.ReadFile(filepath.Join(clipDir, "media", "pic.png"))
+	as.NoError(err)
+	as.Equal("png", string(media))
+	_, err = os.Stat(filepath.Join(site, "private"))
+	as.True(os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(site, "reading-list", secret.ID.String()))
+	as.True(os.IsNotExist(err))
+
+	// Unpublished collections disappear from the next publication
+	res = client.Put("/api/v1/collections/"+public.ID, map[string]bool{"public": false})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	client.Post("/api/v1/admin/mirror", nil)
+	_, err = os.Stat(filepath.Join(site, "reading-list"))
+	as.True(os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(site, "index.html"))
+	as.NoError(err)
+}
+
+func (as *ActionSuite) Test_PublishMirror_SocialCards() {
+	kit := testkit.New(as.T())
+	site := filepath.Join(as.T().TempDir(), "site")
+	user := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{user.Email}
+	kit.Config.Mirror.Directory = site
+	kit.Config.Mirror.SocialCards = true
+	kit.Config.Mirror.BaseURL = "https://reading.example.com"
+	client := kit.Client(newKitApp(kit), user)
+
+	var public CollectionResponse
+	client.Post("/api/v1/collections", CollectionPayload{Name: "Reading List", Public: true}).JSON(&public)
+	clip := kit.CreateClip(user, testkit.WithClip(func(c *models.Clip) {
+		c.Title = "Notes & Queries"
+		c.Excerpt = nulls.NewString("A short excerpt")
+		c.CollectionID = nulls.NewUUID(uuid.FromStringOrNil(public.ID))
+	}))
+
+	res := client.Post("/api/v1/admin/mirror", nil)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+
+	clipDir := filepath.Join(site, "reading-list", clip.ID.String())
+	card, err := os.ReadFile(filepath.Join(clipDir, "card.png"))
+	as.NoError(err)
+	as.True(bytes.HasPrefix(card, []byte(pngMagic)))
+	page, err := os.ReadFile(filepath.Join(clipDir, "index.html"))
+	as.NoError(err)
+	url := "https://reading.example.com/reading-list/" + clip.ID.String() + "/"
+	as.Contains(string(page), `<meta property="og:title" content="Notes &amp; Queries">`)
+	as.Contains(string(page), `<meta property="og:description" content="A short excerpt">`)
+	as.Contains(string(page), `<meta property="og:url" content="`+url+`">`)
+	as.Contains(string(page), `<meta property="og:image" content="`+url+`card.png">`)
+	as.Contains(string(page), `<meta name="twitter:card" content="summary_large_image">`)
+
+	// Cards are cached per clip until its title changes
+	cache := filepath.Join(kit.StorageRoot, "web-clips", cardDir)
+	cached, _ := filepath.Glob(filepath.Join(cache, clip.ID.String()+"-*.png"))
+	as.Len(cached, 1)
+	client.Post("/api/v1/admin/mirror", nil)
+	again, _ := filepath.Glob(filepath.Join(cache, clip.ID.String()+"-*.png"))
+	as.Equal(cached, again)
+
+	as.NoError(kit.DB.RawQuery("UPDATE clips SET title = ? WHERE id = ?", "Renamed", clip.ID).Exec())
+	client.Post("/api/v1/admin/mirror", nil)
+	renamed, _ := filepath.Glob(filepath.Join(cache, clip.ID.String()+"-*.png"))
+	as.Len(renamed, 1)
+	as.NotEqual(cached, renamed)
+}
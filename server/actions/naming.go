@@ -0,0 +1,246 @@
+package actions
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Naming templates replace the built-in YYYYMMDD_HHMMSS_site-slug_<ID>
+// folder and <title-slug> file names of new clips. Folder templates may
+// contain slashes to file clips in subfolders of web-clips, e.g.
+// {{.Year}}/{{.Month}}/{{.Slug}} for date-based folders. As with frontmatter
+// templates, a user's templates take precedence over clips.folder_template
+// and clips.file_template, set by the admin. Existing clips keep their
+// folders.
+
+// clipNameData is what naming templates are executed with
+type clipNameData struct {
+	Date    string // YYYYMMDD
+	Time    string // HHMMSS
+	Year    string
+	Month   string // 01-12
+	Day     string // 01-31
+	Clipped time.Time
+	Title   string
+	Slug    string // Slug of the title, "page" when empty
+	Domain  string
+	Site    string // Slug of the domain
+	Mode    string
+	ID      string // First 8 hex digits of the clip ID; empty in file templates
+}
+
+// newClipNameData returns the naming template data of a clip of req saved at now
+func newClipNameData(req ClipPayload, now time.Time) clipNameData {
+	slug := slugify(req.Title)
+	if slug == "" {
+		slug = "page"
+	}
+	mode := req.Mode
+	if mode == "" {
+		mode = "article"
+	}
+	domain := extractDomain(req.URL)
+	return clipNameData{
+		Date:    now.Format("20060102"),
+		Time:    now.Format("150405"),
+		Year:    now.Format("2006"),
+		Month:   now.Format("01"),
+		Day:     now.Format("02"),
+		Clipped: now,
+		Title:   req.Title,
+		Slug:    slug,
+		Domain:  domain,
+		Site:    slugify(domain),
+		Mode:    mode,
+	}
+}
+
+// folderName names the folder (relative to web-clips) of the new clip id
+// with tmpl, or the built-in scheme when tmpl is empty or fails
+func (d clipNameData) folderName(tmpl string, id uuid.UUID) string {
+	if tmpl != "" {
+		d.ID = id.String()[:8]
+		name, err := renderNamingTemplate(tmpl, d, true)
+		if err == nil {
+			return name
+		}
+		log.Printf("Folder template failed, using the default name: %v", err)
+	}
+	return clipFolderName(d.Clipped, d.Site, id)
+}
+
+// fileName names the clip's page file (without extension) with tmpl, or
+// returns "" for the title slug when tmpl is empty or fails
+func (d clipNameData) fileName(tmpl string) string {
+	if tmpl == "" {
+		return ""
+	}
+	name, err := renderNamingTemplate(tmpl, d, false)
+	if err != nil {
+		log.Printf("File template failed, using the default name: %v", err)
+		return ""
+	}
+	return name
+}
+
+// unsafeNameChars are replaced in the segments of rendered names
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// maxNameSegment bounds the length of each folder or file name
+const maxNameSegment = 100
+
+// renderNamingTemplate executes a naming template and makes the result a
+// safe relative path: subfolders when nested, a single name otherwise.
+// Segments can't be hidden (leading dots), which also rules out "..".
+func renderNamingTemplate(text string, data clipNameData, nested bool) (string, error) {
+	tmpl, err := template.New("naming").Funcs(frontmatterFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+
+	rendered := sb.String()
+	if !nested {
+		rendered = strings.ReplaceAll(rendered, "/", "-")
+	}
+	var segments []string
+	for _, segment := range strings.Split(rendered, "/") {
+		segment = unsafeNameChars.ReplaceAllString(strings.TrimSpace(segment), "_")
+		segment = strings.TrimLeft(segment, ".")
+		if len(segment) > maxNameSegment {
+			segment = segment[:maxNameSegment]
+		}
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	if len(segments) == 0 {
+		return "", fmt.Errorf("template renders an empty name")
+	}
+	return filepath.Join(segments...), nil
+}
+
+// sampleClipNameData is what templates are checked and previewed with
+func sampleClipNameData() clipNameData {
+	return newClipNameData(ClipPayload{Title: "Example Domain", URL: "https://example.com/", Mode: "article"}, time.Now())
+}
+
+// userFolderTemplate returns the folder template in effect for user, or ""
+// for the built-in scheme
+func userFolderTemplate(user *models.User) string {
+	if user.FolderTemplate.Valid {
+		return user.FolderTemplate.String
+	}
+	return GetConfig().Clips.FolderTemplate
+}
+
+// userFileTemplate returns the file template in effect for user, or "" for
+// the title slug
+func userFileTemplate(user *models.User) string {
+	if user.FileTemplate.Valid {
+		return user.FileTemplate.String
+	}
+	return GetConfig().Clips.FileTemplate
+}
+
+// NamingTemplatePayload is the request body for setting a user's naming
+// templates
+type NamingTemplatePayload struct {
+	Folder string `json:"folder"` // Empty restores the default
+	File   string `json:"file"`   // Empty restores the default
+}
+
+// NamingTemplateResponse describes the naming templates in effect
+type NamingTemplateResponse struct {
+	Folder       string `json:"folder,omitempty"` // Empty for the built-in scheme
+	FolderSource string `json:"folder_source"`    // user, config or builtin
+	File         string `json:"file,omitempty"`   // Empty for the title slug
+	FileSource   string `json:"file_source"`      // user, config or builtin
+	Preview      string `json:"preview"`          // Path of an example clip's page
+}
+
+// getNamingTemplate returns the user's naming templates
+func getNamingTemplate(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	user := &models.User{}
+	if err := tx.Find(user, c.Value("user_id")); err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+	return c.Render(http.StatusOK, r.JSON(namingTemplateResponse(user)))
+}
+
+// updateNamingTemplate sets or clears the user's naming templates
+func updateNamingTemplate(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	user := &models.User{}
+	if err := tx.Find(user, c.Value("user_id")); err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	var req NamingTemplatePayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+	user.FolderTemplate = nulls.String{}
+	if strings.TrimSpace(req.Folder) != "" {
+		if _, err := renderNamingTemplate(req.Folder, sampleClipNameData(), true); err != nil {
+			return c.Error(http.StatusUnprocessableEntity, fmt.Errorf("invalid folder template: %w", err))
+		}
+		user.FolderTemplate = nulls.NewString(req.Folder)
+	}
+	user.FileTemplate = nulls.String{}
+	if strings.TrimSpace(req.File) != "" {
+		if _, err := renderNamingTemplate(req.File, sampleClipNameData(), false); err != nil {
+			return c.Error(http.StatusUnprocessableEntity, fmt.Errorf("invalid file template: %w", err))
+		}
+		user.FileTemplate = nulls.NewString(req.File)
+	}
+	if err := tx.UpdateColumns(user, "folder_template", "file_template", "updated_at"); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(namingTemplateResponse(user)))
+}
+
+func namingTemplateResponse(user *models.User) NamingTemplateResponse {
+	resp := NamingTemplateResponse{
+		Folder:       userFolderTemplate(user),
+		FolderSource: templateSource(user.FolderTemplate, GetConfig().Clips.FolderTemplate),
+		File:         userFileTemplate(user),
+		FileSource:   templateSource(user.FileTemplate, GetConfig().Clips.FileTemplate),
+	}
+	example := sampleClipNameData()
+	file := example.fileName(resp.File)
+	if file == "" {
+		file = example.Slug
+	}
+	folder := example.folderName(resp.Folder, uuid.Must(uuid.NewV4()))
+	resp.Preview = filepath.ToSlash(filepath.Join("web-clips", folder, file+".md"))
+	return resp
+}
+
+// templateSource tells where a template in effect comes from
+func templateSource(own nulls.String, configured string) string {
+	switch {
+	case own.Valid:
+		return "user"
+	case configured != "":
+		return "config"
+	}
+	return "builtin"
+}
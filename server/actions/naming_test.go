@@ -0,0 +1,77 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_NamingTemplate() {
+	kit := testkit.New(as.T())
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	var tmpl NamingTemplateResponse
+	client.Get("/api/v1/templates/naming").JSON(&tmpl)
+	as.Equal("builtin", tmpl.FolderSource)
+	as.Regexp(`^web-clips/\d{8}_\d{6}_example-com_[0-9a-f]{8}/example-domain\.md$`, tmpl.Preview)
+
+	as.Equal(http.StatusUnprocessableEntity, client.Put("/api/v1/templates/naming", NamingTemplatePayload{Folder: "{{.Year"}).Code)
+	as.Equal(http.StatusUnprocessableEntity, client.Put("/api/v1/templates/naming", NamingTemplatePayload{File: "{{.Heading}}"}).Code)
+	as.Equal(http.StatusUnprocessableEntity, client.Put("/api/v1/templates/naming", NamingTemplatePayload{Folder: "../.."}).Code)
+
+	res := client.Put("/api/v1/templates/naming", NamingTemplatePayload{Folder: "{{.Year}}/{{.Month}}/{{.Domain}}/{{.Slug}}", File: "{{.Date}}-{{.Slug}}"})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&tmpl)
+	as.Equal("user", tmpl.FolderSource)
+	as.Equal("user", tmpl.FileSource)
+	now := time.Now()
+	as.Equal("web-clips/"+now.Format("2006/01")+"/example.com/example-domain/"+now.Format("20060102")+"-example-domain.md", tmpl.Preview)
+
+	// Clips land in date folders; a second clip of the same page gets the ID appended
+	payload := ClipPayload{Title: "Hello World", URL: "https://example.com/hello", Mode: "article", Markdown: "# Hello"}
+	var first, second ClipResponse
+	res = client.Post("/api/v1/clips", payload)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&first)
+	folder := filepath.Join("web-clips", now.Format("2006"), now.Format("01"), "example.com", "hello-world")
+	as.Equal(filepath.Join(folder, now.Format("20060102")+"-hello-world.md"), first.Path)
+	_, err := os.Stat(filepath.Join(kit.StorageRoot, first.Path))
+	as.NoError(err)
+
+	payload.URL = "https://example.com/hello-again"
+	res = client.Post("/api/v1/clips", payload)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&second)
+	as.Equal(filepath.Join(folder+"_"+second.ID[:8], now.Format("20060102")+"-hello-world.md"), second.Path)
+
+	var detail ClipDetail
+	client.Get("/api/v1/clips/" + first.ID).JSON(&detail)
+	as.Equal(folder, detail.Path)
+
+	// Clearing them falls back to the admin's templates
+	kit.Config.Clips.FolderTemplate = "{{.Site}}/{{.Slug}}_{{.ID}}"
+	res = client.Put("/api/v1/templates/naming", NamingTemplatePayload{})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&tmpl)
+	as.Equal("config", tmpl.FolderSource)
+	as.Equal("builtin", tmpl.FileSource)
+	as.Regexp(`^web-clips/example-com/example-domain_[0-9a-f]{8}/example-domain\.md$`, tmpl.Preview)
+}
+
+func (as *ActionSuite) Test_RenderNamingTemplate() {
+	data := clipNameData{Title: "A/B", Slug: "a-b", Domain: "example.com"}
+
+	name, err := renderNamingTemplate("{{.Domain}}/ {{.Title}} /./..", data, true)
+	as.NoError(err)
+	as.Equal(filepath.Join("example.com", "A", "B"), name)
+
+	name, err = renderNamingTemplate("{{.Title}} (draft)", data, false)
+	as.NoError(err)
+	as.Equal("A-B_draft_", name)
+
+	_, err = renderNamingTemplate("/../", data, true)
+	as.Error(err)
+}
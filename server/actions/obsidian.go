@@ -0,0 +1,378 @@
+package actions
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"server/models"
+
+	"github.com/gofrs/uuid"
+)
+
+// With storage.layout: obsidian, new clips are written the way Obsidian
+// keeps notes instead of as clip folders: a single "<Title>.md" in
+// storage.obsidian_folder, with its media in the attachments folder shared by
+// all notes (storage.obsidian_attachments, inside the notes folder) and
+// embedded with ![[name]] links. Attachment names start with a prefix of the
+// clip ID, which tells the attachments of a note apart. A clip is a note when
+// its path is a .md file; switching layouts leaves existing clips as they
+// are. Notes keep no versions: re-clips replace them.
+
+// Storage layouts
+const (
+	layoutFolders  = "folders"  // A folder per clip in web-clips
+	layoutObsidian = "obsidian" // A note per clip in an Obsidian vault
+)
+
+// maxNoteName bounds the length of note names, in characters
+const maxNoteName = 100
+
+// maxNoteAttempts bounds the numbers tried when a note name is taken
+const maxNoteAttempts = 100
+
+// unsafeNoteChars can't appear in note names: they break Obsidian links or
+// file systems
+var unsafeNoteChars = regexp.MustCompile(`[\\/:*?"<>|#^\[\]]+`)
+
+// mediaLinks matches links and embeds of files in a clip's media folder
+var mediaLinks = regexp.MustCompile(`(!?)\[[^\]]*\]\(media/([^)\s]+)\)`)
+
+// wikiLinks matches [[name]] links and ![[name]] embeds, with an optional
+// |alias or |size
+var wikiLinks = regexp.MustCompile(`(!?)\[\[([^\]|]+)(\|[^\]]*)?\]\]`)
+
+// isNotePath tells the path of a note from that of a clip folder
+func isNotePath(p string) bool {
+	return strings.HasSuffix(p, ".md")
+}
+
+// noteName returns the name (without extension) of the note of a page titled title
+func noteName(title string) string {
+	name := strings.Trim(strings.Join(strings.Fields(unsafeNoteChars.ReplaceAllString(title, " ")), " "), " .")
+	if utf8.RuneCountInString(name) > maxNoteName {
+		name = strings.TrimSpace(string([]rune(name)[:maxNoteName]))
+	}
+	if name == "" {
+		return "Untitled"
+	}
+	return name
+}
+
+// noteMediaPrefix starts the names of a note's attachments
+func noteMediaPrefix(id uuid.UUID) string {
+	return id.String()[:8] + "-"
+}
+
+// noteAttachments returns the attachments folder of the note at notePath
+func noteAttachments(notePath string) string {
+	return filepath.Join(filepath.Dir(notePath), GetConfig().Storage.ObsidianAttachments)
+}
+
+// clipMediaDir returns the folder holding the media of clip, stored at p (a
+// clip folder or a note), and the prefix of its files' names there
+func clipMediaDir(clip *models.Clip, p string) (string, string) {
+	if clip.IsNote() {
+		return noteAttachments(p), noteMediaPrefix(clip.ID)
+	}
+	return filepath.Join(p, "media"), ""
+}
+
+// clipMediaFile returns the path of a media file of clip, stored at p, or ""
+// when name is not one of its files
+func clipMediaFile(clip *models.Clip, p, name string) string {
+	dir, prefix := clipMediaDir(clip, p)
+	if !strings.HasPrefix(name, prefix) {
+		return ""
+	}
+	return filepath.Join(dir, name)
+}
+
+// clipMediaFiles lists the names of the media files of clip, stored at p
+func clipMediaFiles(clip *models.Clip, p string) ([]string, error) {
+	dir, prefix := clipMediaDir(clip, p)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// noteAttachmentPaths lists the attachments of the note of clip id at
+// notePath, originals kept aside by image processing included
+func noteAttachmentPaths(notePath string, id uuid.UUID) ([]string, error) {
+	attachments := noteAttachments(notePath)
+	prefix := noteMediaPrefix(id)
+	var paths []string
+	err := filepath.WalkDir(attachments, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == attachments {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if p != attachments && d.Name() != originalsDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), prefix) {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// noteCapture is a capture staged as a note: its attachments are already
+// in place, the note itself is still in staging
+type noteCapture struct {
+	staging  string
+	note     string   // Staged note
+	attached []string // Attachments moved into place
+}
+
+// discard removes a staged note and its attachments
+func (n *noteCapture) discard() {
+	for _, p := range n.attached {
+		os.Remove(p)
+	}
+	os.RemoveAll(n.staging)
+}
+
+// stageClipNote writes a capture as the note of clip id, with its media
+// attached in the attachments folder of notes. The capture is written to a
+// staging folder as for clip folders, then its media moved to the
+// attachments and its page links rewritten for Obsidian.
+func stageClipNote(clipDir, notes string, id uuid.UUID, req ClipPayload, recognizedText string) (*noteCapture, error) {
+	staging, err := newStagingDir(clipDir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create clip directory")
+	}
+	capture := &noteCapture{staging: staging}
+
+	pageFile, err := writeClipFiles(staging, req, recognizedText)
+	if err != nil {
+		capture.discard()
+		return nil, err
+	}
+
+	prefix := noteMediaPrefix(id)
+	attachments := filepath.Join(notes, GetConfig().Storage.ObsidianAttachments)
+	attach := func(src, rel string) error {
+		dst := filepath.Join(attachments, filepath.Dir(rel), prefix+filepath.Base(rel))
+		if err := clipFS.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := clipFS.Rename(src, dst); err != nil {
+			return err
+		}
+		capture.attached = append(capture.attached, dst)
+		return nil
+	}
+
+	media := filepath.Join(staging, "media")
+	err = filepath.WalkDir(media, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == media {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(media, p)
+		return attach(p, rel)
+	})
+	if err != nil {
+		capture.discard()
+		return nil, fmt.Errorf("Failed to save images")
+	}
+
+	// A full page capture is attached, next to its media
+	fullPage := filepath.Ext(pageFile) == ".html"
+	if fullPage {
+		htmlPath := filepath.Join(staging, pageFile)
+		data, err := readClipFile(htmlPath)
+		if err == nil {
+			html := strings.NewReplacer(`src="media/`, `src="`+prefix, `href="media/`, `href="`+prefix).Replace(string(data))
+			err = writeFileSync(htmlPath, []byte(html), 0644)
+		}
+		if err == nil {
+			err = attach(htmlPath, pageFile)
+		}
+		if err != nil {
+			capture.discard()
+			return nil, fmt.Errorf("Failed to write file")
+		}
+	}
+
+	capture.note = filepath.Join(staging, strings.TrimSuffix(pageFile, filepath.Ext(pageFile))+".md")
+	data, err := readClipFile(capture.note)
+	if err == nil {
+		content := mediaLinks.ReplaceAllString(string(data), "$1[["+prefix+"$2]]")
+		if fullPage {
+			content = strings.ReplaceAll(content, fmt.Sprintf("[%s](./%s)", pageFile, pageFile), "[["+prefix+pageFile+"]]")
+		}
+		err = writeFileSync(capture.note, []byte(content), 0644)
+	}
+	if err != nil {
+		capture.discard()
+		return nil, fmt.Errorf("Failed to write file")
+	}
+	return capture, nil
+}
+
+// writeClipNote writes a capture as a new note in clipDir's notes folder and
+// returns the new clip's ID and the note's path relative to clipDir
+func writeClipNote(clipDir string, req ClipPayload, recognizedText string) (uuid.UUID, string, error) {
+	folder := GetConfig().Storage.ObsidianFolder
+	notes := filepath.Join(clipDir, folder)
+	id := uuid.Must(uuid.NewV4())
+	capture, err := stageClipNote(clipDir, notes, id, req, recognizedText)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	defer os.RemoveAll(capture.staging)
+
+	// The file template names notes too; a taken name gets a number
+	name := req.fileName
+	if name == "" {
+		name = noteName(req.Title)
+	}
+	if err := clipFS.MkdirAll(notes, 0755); err != nil {
+		capture.discard()
+		return uuid.Nil, "", fmt.Errorf("Failed to create clip directory")
+	}
+	for n := 1; n <= maxNoteAttempts; n++ {
+		file := name + ".md"
+		if n > 1 {
+			file = fmt.Sprintf("%s %d.md", name, n)
+		}
+		notePath := filepath.Join(notes, file)
+		if _, err := os.Lstat(notePath); err == nil {
+			continue
+		}
+		err := clipFS.Rename(capture.note, notePath)
+		if err == nil {
+			err = syncDir(notes)
+		}
+		if err != nil {
+			os.Remove(notePath)
+			capture.discard()
+			return uuid.Nil, "", fmt.Errorf("Failed to write file")
+		}
+		return id, filepath.Join(folder, file), nil
+	}
+	capture.discard()
+	return uuid.Nil, "", fmt.Errorf("Failed to write file: %s is taken", name+".md")
+}
+
+// rewriteClipNote replaces the note of clip, in clipDir, with a new capture
+// for re-clips. The note keeps its name.
+func rewriteClipNote(clipDir string, clip *models.Clip, req ClipPayload, recognizedText string) error {
+	notePath := filepath.Join(clipDir, clip.Path)
+	previous, err := noteAttachmentPaths(notePath, clip.ID)
+	if err != nil {
+		return err
+	}
+	capture, err := stageClipNote(clipDir, filepath.Dir(notePath), clip.ID, req, recognizedText)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(capture.staging)
+	if err := clipFS.Rename(capture.note, notePath); err != nil {
+		capture.discard()
+		return fmt.Errorf("Failed to write file")
+	}
+
+	// Attachments the new capture doesn't have anymore
+	kept := map[string]bool{}
+	for _, p := range capture.attached {
+		kept[p] = true
+	}
+	for _, p := range previous {
+		if !kept[p] {
+			os.Remove(p)
+		}
+	}
+	return nil
+}
+
+// removeClipNote removes the note of clip id at notePath and its attachments
+func removeClipNote(notePath string, id uuid.UUID) error {
+	if err := removeNoteAttachments(notePath, id); err != nil {
+		return err
+	}
+	if err := os.Remove(notePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// removeNoteAttachments removes the attachments of the note of clip id at notePath
+func removeNoteAttachments(notePath string, id uuid.UUID) error {
+	paths, err := noteAttachmentPaths(notePath, id)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// clipMoves returns the moves relocating the files of clip from the clip
+// directory fromDir to toDir: its folder or note (in the trash for trashed
+// clips), and a note's attachments
+func clipMoves(clip *models.Clip, fromDir, toDir string) ([]movedFolder, error) {
+	rel := clip.Path
+	if clip.DeletedAt.Valid {
+		rel = filepath.Join(trashDirName, clip.Path)
+	}
+	moves := []movedFolder{{filepath.Join(fromDir, rel), filepath.Join(toDir, rel)}}
+	if !clip.IsNote() {
+		return moves, nil
+	}
+
+	notePath := filepath.Join(fromDir, clip.Path)
+	attachments, err := noteAttachmentPaths(notePath, clip.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range attachments {
+		rel, _ := filepath.Rel(filepath.Dir(notePath), p)
+		moves = append(moves, movedFolder{p, filepath.Join(toDir, filepath.Dir(clip.Path), rel)})
+	}
+	return moves, nil
+}
+
+// noteMarkdown turns a note's links to its attachments into links to media/,
+// which is where the rest of the server expects a clip's media
+func noteMarkdown(content string) string {
+	return wikiLinks.ReplaceAllStringFunc(content, func(link string) string {
+		m := wikiLinks.FindStringSubmatch(link)
+		if ext := path.Ext(m[2]); ext == "" || ext == ".md" {
+			return link // A link to another note
+		}
+		return fmt.Sprintf("%s[%s](media/%s)", m[1], m[2], strings.ReplaceAll(m[2], " ", "%20"))
+	})
+}
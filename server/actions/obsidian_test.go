@@ -0,0 +1,83 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_ObsidianLayout() {
+	kit := testkit.New(as.T())
+	kit.Config.Storage.Layout = layoutObsidian
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	var buf bytes.Buffer
+	as.NoError(png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 10, 10))))
+	payload := ClipPayload{
+		Title:    "Notes: a/b?",
+		URL:      "https://example.com/notes",
+		Markdown: "# Notes\n\n![hero](media/hero.png)",
+		Mode:     "article",
+		Images:   []ImagePayload{{Filename: "hero.png", Data: base64.StdEncoding.EncodeToString(buf.Bytes())}},
+	}
+	var first ClipResponse
+	res := client.Post("/api/v1/clips", payload)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&first)
+	as.Equal(filepath.Join("Clips", "Notes a b.md"), first.Path)
+
+	// One note, its image in the shared attachments folder
+	attachment := first.ID[:8] + "-hero.png"
+	data, err := os.ReadFile(filepath.Join(kit.StorageRoot, first.Path))
+	as.NoError(err)
+	as.Contains(string(data), "\n# Notes\n\n![["+attachment+"]]")
+	as.FileExists(filepath.Join(kit.StorageRoot, "Clips", "attachments", attachment))
+	as.NoDirExists(filepath.Join(kit.StorageRoot, "web-clips", "Notes a b"))
+
+	// The same title gets a numbered note
+	var second ClipResponse
+	payload.URL = "https://example.com/other"
+	res = client.Post("/api/v1/clips", payload)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&second)
+	as.Equal(filepath.Join("Clips", "Notes a b 2.md"), second.Path)
+
+	// The API reads notes like clip folders, and serves only their own attachments
+	var detail ClipDetail
+	client.Get("/api/v1/clips/" + first.ID).JSON(&detail)
+	as.Contains(detail.Content, "!["+attachment+"](media/"+attachment+")")
+	as.Len(detail.Images, 1)
+	as.Equal(filepath.Join("Clips", "attachments", attachment), detail.Images[0].Path)
+	as.Equal(http.StatusOK, client.Get("/api/v1/clips/"+first.ID+"/media/"+attachment).Code)
+	as.Equal(http.StatusNotFound, client.Get("/api/v1/clips/"+second.ID+"/media/"+attachment).Code)
+
+	// Re-clips replace the note in place
+	payload.Markdown = "# Notes\n\nNo image anymore."
+	payload.Images = nil
+	payload.Dedupe = dedupeMerge
+	res = client.Post("/api/v1/clips", payload)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	data, err = os.ReadFile(filepath.Join(kit.StorageRoot, second.Path))
+	as.NoError(err)
+	as.Contains(string(data), "No image anymore.")
+	as.NoFileExists(filepath.Join(kit.StorageRoot, "Clips", "attachments", second.ID[:8]+"-hero.png"))
+
+	// Trashing moves the note; purging removes its attachments too
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/clips/"+first.ID).Code)
+	as.FileExists(filepath.Join(kit.StorageRoot, trashDirName, first.Path))
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/trash/"+first.ID).Code)
+	as.NoFileExists(filepath.Join(kit.StorageRoot, trashDirName, first.Path))
+	as.NoFileExists(filepath.Join(kit.StorageRoot, "Clips", "attachments", attachment))
+}
+
+func (as *ActionSuite) Test_NoteMarkdown() {
+	as.Equal("![a.png](media/a.png) [[Other note]] [b c.pdf](media/b%20c.pdf)", noteMarkdown("![[a.png|200]] [[Other note]] [[b c.pdf]]"))
+	as.Equal("Untitled", noteName(" ?? "))
+	as.Equal("C C++ Java", noteName("C#/C++ [Java]"))
+}
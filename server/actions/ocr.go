@@ -0,0 +1,48 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"server/internal/config"
+	"server/internal/ocr"
+)
+
+// recognizeImageText runs OCR over the clip's images and joins the results.
+// Returns an empty string when OCR is disabled or nothing was recognized.
+func recognizeImageText(ctx context.Context, cfg *config.Config, images []ImagePayload) (string, error) {
+	engine, err := ocr.New(cfg.OCR)
+	if err != nil || engine == nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.OCR.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	var parts []string
+	for _, img := range images {
+		data, err := img.bytes()
+		if err != nil {
+			continue // Already rejected during validation
+		}
+		text, err := engine.Recognize(ctx, data, sanitizeFilename(img.Filename))
+		if err != nil {
+			return strings.Join(parts, "\n\n"), fmt.Errorf("OCR failed for %s: %w", img.Filename, err)
+		}
+		if text != "" {
+			parts = append(parts, text)
+		}
+	}
+
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// recognizedTextSection formats OCR output for appending to the clip markdown
+func recognizedTextSection(text string) string {
+	if text == "" {
+		return ""
+	}
+	return "\n\n## Recognized Text\n\n" + text + "\n"
+}
@@ -0,0 +1,24 @@
+package actions
+
+import (
+	"context"
+	"net/http"
+
+	"server/internal/config"
+)
+
+func (as *ActionSuite) Test_RecognizedTextSection() {
+	as.Equal("", recognizedTextSection(""))
+	as.Equal("\n\n## Recognized Text\n\nHello\n", recognizedTextSection("Hello"))
+}
+
+func (as *ActionSuite) Test_RecognizeImageText_Disabled() {
+	text, err := recognizeImageText(context.Background(), &config.Config{}, []ImagePayload{{Filename: "a.png", Data: "aGVsbG8="}})
+	as.NoError(err)
+	as.Equal("", text)
+}
+
+func (as *ActionSuite) Test_ListClips_WithSearchQuery() {
+	res := as.JSON("/api/v1/clips?q=invoice").Get()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
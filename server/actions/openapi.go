@@ -0,0 +1,27 @@
+package actions
+
+import (
+	"net/http"
+	"sync"
+
+	"server/api"
+
+	"github.com/gobuffalo/buffalo"
+)
+
+// openAPIDoc is api/openapi.yaml as JSON, converted on first request
+var openAPIDoc = sync.OnceValues(api.JSON)
+
+// serveOpenAPI returns the OpenAPI description of the API, the one the SDKs
+// are generated from and contract tests check responses against
+// GET /openapi.json
+func serveOpenAPI(c buffalo.Context) error {
+	doc, err := openAPIDoc()
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	c.Response().Header().Set("Content-Type", "application/json")
+	c.Response().WriteHeader(http.StatusOK)
+	c.Response().Write(doc)
+	return nil
+}
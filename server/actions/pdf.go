@@ -0,0 +1,60 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"server/internal/config"
+	"server/internal/pdftext"
+)
+
+// excerptLength is the maximum number of characters kept in a clip excerpt
+const excerptLength = 280
+
+// extractPDFText pulls the text layer out of any PDFs attached to the clip.
+// Non-PDF attachments are ignored.
+func extractPDFText(ctx context.Context, cfg *config.Config, attachments []ImagePayload) (string, error) {
+	extractor := &pdftext.Extractor{Path: cfg.PDF.PdftotextPath}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.PDF.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	var parts []string
+	for _, att := range attachments {
+		head, err := att.head(8)
+		if err != nil || !pdftext.IsPDF(head) {
+			continue
+		}
+		data, err := att.bytes()
+		if err != nil {
+			continue
+		}
+		text, err := extractor.Extract(ctx, data)
+		if err != nil {
+			return strings.Join(parts, "\n\n"), fmt.Errorf("PDF text extraction failed for %s: %w", att.Filename, err)
+		}
+		if text != "" {
+			parts = append(parts, text)
+		}
+	}
+
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// makeExcerpt returns the start of text, cut at a word boundary
+func makeExcerpt(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= excerptLength {
+		return text
+	}
+
+	runes := []rune(text)
+	cut := string(runes[:excerptLength])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
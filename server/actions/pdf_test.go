@@ -0,0 +1,12 @@
+package actions
+
+import "strings"
+
+func (as *ActionSuite) Test_MakeExcerpt() {
+	as.Equal("short text", makeExcerpt("  short\n\ntext "))
+
+	long := strings.Repeat("word ", 100)
+	excerpt := makeExcerpt(long)
+	as.True(strings.HasSuffix(excerpt, "word…"), excerpt)
+	as.LessOrEqual(len([]rune(excerpt)), excerptLength+1)
+}
@@ -0,0 +1,132 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Profile is the response from GET /api/v1/me: who the caller is, where their
+// clips go and how much room they take, so clients don't decode the JWT
+type Profile struct {
+	ID        string        `json:"id"`
+	Email     string        `json:"email"`
+	Name      string        `json:"name"`
+	Admin     bool          `json:"admin"`
+	CreatedAt time.Time     `json:"created_at"`
+	Storage   StorageStatus `json:"storage"`
+	Usage     *DiskUsage    `json:"usage"`
+	Limits    ProfileLimits `json:"limits"`
+	Token     ProfileToken  `json:"token"`
+}
+
+// StorageStatus tells whether the user's clip directory can be written to
+type StorageStatus struct {
+	Path   string `json:"path"`
+	Custom bool   `json:"custom"` // Set by an admin, instead of storage.base_path
+	Status string `json:"status"` // ok or failed
+	Detail string `json:"detail,omitempty"`
+}
+
+// ProfileLimits are the server's limits on what the user sends (0 for none)
+type ProfileLimits struct {
+	MaxClipBytes      int64 `json:"max_clip_bytes"`      // server.max_clip_body_bytes
+	MaxImageBytes     int64 `json:"max_image_bytes"`     // images.max_size_bytes
+	MaxImagesBytes    int64 `json:"max_images_bytes"`    // images.max_total_bytes, per clip
+	MaxUploadBytes    int64 `json:"max_upload_bytes"`    // uploads.max_bytes
+	RequestsPerMinute int   `json:"requests_per_minute"` // rate_limit.per_user, when enabled
+}
+
+// ProfileToken describes the credential of the request
+type ProfileToken struct {
+	Type       string     `json:"type"` // jwt, service_token or dev_mode
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	ID         string     `json:"id,omitempty"` // Service tokens only
+	Name       string     `json:"name,omitempty"`
+	Prefix     string     `json:"prefix,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// getProfile returns the authenticated user's profile. Usage comes from the
+// cache of GET /api/v1/me/usage.
+func getProfile(c buffalo.Context) error {
+	cfg := GetConfig()
+	tx := c.Value("tx").(*pop.Connection)
+	user := &models.User{}
+	if err := tx.Find(user, c.Value("user_id")); err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+	usage, err := UserDiskUsage(tx, user, false)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	token, err := profileToken(c, tx)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	profile := Profile{
+		ID:        user.ID.String(),
+		Email:     user.Email,
+		Name:      user.Name,
+		Admin:     isAdminEmail(cfg, user.Email),
+		CreatedAt: user.CreatedAt,
+		Storage: StorageStatus{
+			Path:   userClipDir(cfg, user),
+			Custom: user.ClipDirectory.Valid && user.ClipDirectory.String != "",
+			Status: checkOK,
+		},
+		Usage: usage,
+		Limits: ProfileLimits{
+			MaxClipBytes:   max(cfg.Server.MaxClipBodyBytes, 0),
+			MaxImageBytes:  cfg.Images.MaxSizeBytes,
+			MaxImagesBytes: cfg.Images.MaxTotalBytes,
+			MaxUploadBytes: cfg.Uploads.MaxBytes,
+		},
+		Token: token,
+	}
+	if err := checkWritableDir(profile.Storage.Path); err != nil {
+		profile.Storage.Status, profile.Storage.Detail = checkFailed, err.Error()
+	}
+	if cfg.RateLimit.Enabled {
+		profile.Limits.RequestsPerMinute = cfg.RateLimit.PerUser.RequestsPerMinute
+	}
+	return c.Render(http.StatusOK, r.JSON(profile))
+}
+
+// profileToken describes the credential authMiddleware accepted
+func profileToken(c buffalo.Context, tx *pop.Connection) (ProfileToken, error) {
+	authType, _ := c.Value("auth_type").(string)
+	token := ProfileToken{Type: authType}
+	if exp, ok := c.Value("token_expires_at").(time.Time); ok {
+		token.ExpiresAt = &exp
+	}
+	if authType != "service_token" {
+		return token, nil
+	}
+
+	id, err := uuid.FromString(c.Value("token_id").(string))
+	if err != nil {
+		return token, err
+	}
+	apiToken := &models.ApiToken{}
+	if err := tx.Find(apiToken, id); err != nil {
+		return token, err
+	}
+	token.ID, token.Name, token.Prefix = apiToken.ID.String(), apiToken.Name, apiToken.Prefix
+	token.CreatedAt = &apiToken.CreatedAt
+	if apiToken.ExpiresAt.Valid {
+		token.ExpiresAt = &apiToken.ExpiresAt.Time
+	}
+	if apiToken.LastUsedAt.Valid {
+		token.LastUsedAt = &apiToken.LastUsedAt.Time
+	}
+	return token, nil
+}
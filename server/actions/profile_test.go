@@ -0,0 +1,67 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+)
+
+func (as *ActionSuite) Test_Profile() {
+	kit := testkit.New(as.T())
+	kit.Config.Admin.Emails = []string{"admin@example.com"}
+	kit.Config.RateLimit.Enabled = true
+	kit.Config.RateLimit.PerUser.RequestsPerMinute = 600
+	kit.Config.RateLimit.PerIP.RequestsPerMinute = 600
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+	kit.CreateClip(user, testkit.WithMedia("hero.png", make([]byte, 1000)))
+
+	var profile Profile
+	res := client.Get("/api/v1/me")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&profile)
+	as.Equal(user.ID.String(), profile.ID)
+	as.Equal(user.Email, profile.Email)
+	as.False(profile.Admin)
+	as.Equal(StorageStatus{Path: kit.StorageRoot, Status: checkOK}, profile.Storage)
+	as.Equal(1, profile.Usage.Clips)
+	as.Equal(kit.Config.Images.MaxSizeBytes, profile.Limits.MaxImageBytes)
+	as.Equal(600, profile.Limits.RequestsPerMinute)
+	as.Equal("service_token", profile.Token.Type)
+	as.NotEmpty(profile.Token.ID)
+	as.NotEmpty(profile.Token.Name)
+	as.NotEmpty(profile.Token.Prefix)
+	as.Nil(profile.Token.ExpiresAt)
+
+	// Login sessions show when they expire
+	tokens, err := generateTokens(user)
+	as.NoError(err)
+	client.Token = tokens.AccessToken
+	profile = Profile{}
+	client.Get("/api/v1/me").JSON(&profile)
+	as.Equal("jwt", profile.Token.Type)
+	as.Equal(tokens.ExpiresAt, profile.Token.ExpiresAt.Unix())
+	as.Empty(profile.Token.ID)
+
+	// A storage path that went missing is reported
+	missing := filepath.Join(as.T().TempDir(), "gone")
+	user.ClipDirectory = nulls.NewString(missing)
+	as.NoError(kit.DB.UpdateColumns(user, "clip_directory"))
+	profile = Profile{}
+	client.Get("/api/v1/me").JSON(&profile)
+	as.True(profile.Storage.Custom)
+	as.Equal(checkFailed, profile.Storage.Status)
+	as.Contains(profile.Storage.Detail, missing)
+	_, err = os.Stat(missing)
+	as.True(os.IsNotExist(err))
+
+	admin := kit.CreateUser(func(u *models.User) { u.Email = "admin@example.com" })
+	profile = Profile{}
+	kit.Client(newKitApp(kit), admin).Get("/api/v1/me").JSON(&profile)
+	as.True(profile.Admin)
+}
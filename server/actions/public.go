@@ -0,0 +1,201 @@
+package actions
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"server/internal/logging"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/buffalo-pop/v3/pop/popmw"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Public routes are readable without an account: share links and the feeds
+// of public collections. They are served by the app, and also on their own
+// listener when public.listen is set, so they can be exposed on a public
+// domain while the API stays private. Links to them are built on
+// public.base_url.
+
+// feedSize is the number of clips in a collection feed
+const feedSize = 50
+
+// registerPublicRoutes adds the public routes to app, limited by client
+// address, and passphrases and reports further
+func registerPublicRoutes(app *buffalo.App, limiter *rateLimiter) {
+	shares := app.Group("/s")
+	feeds := app.Group("/feeds")
+	unlock, report := unlockShare, reportShare
+	if limiter != nil {
+		shares.Use(limiter.byIP)
+		feeds.Use(limiter.byIP)
+		unlock = limiter.passphraseAttempts(unlockShare)
+		report = limiter.reportAttempts(reportShare)
+	}
+	shares.GET("/{token}", viewShare)
+	shares.POST("/{token}", unlock)
+	shares.GET("/{token}/media/{filename}", getShareMedia)
+	shares.POST("/{token}/report", report)
+	feeds.GET("/{id}", collectionFeed)
+}
+
+// newPublicApp builds an app serving only the public routes on top of db
+func newPublicApp(db *pop.Connection) *buffalo.App {
+	app := buffalo.New(buffalo.Options{
+		Env:         ENV,
+		SessionName: "_clipper_public_session",
+		Logger:      logging.Buffalo(slog.Default()),
+	})
+	useErrorEnvelope(app)
+	if reporter := newErrorReporter(); reporter != nil {
+		app.Use(errorReportingMiddleware(reporter))
+	}
+	app.Use(bodyLimitMiddleware)
+	app.Use(popmw.Transaction(db))
+	app.Use(dbMiddleware(db))
+
+	app.GET("/health", healthCheck)
+	app.GET("/healthz", healthCheck)
+	app.Middleware.Skip(popmw.Transaction(db), healthCheck)
+	registerPublicRoutes(app, newRateLimiter())
+	return app
+}
+
+// publicURL returns the link to a public route: path on public.base_url,
+// else server.base_url, else path itself
+func publicURL(path string) string {
+	cfg := GetConfig()
+	base := cfg.Public.BaseURL
+	if base == "" {
+		base = cfg.Server.BaseURL
+	}
+	return strings.TrimSuffix(base, "/") + path
+}
+
+// StartPublicServer serves the public routes on public.listen, if set, until
+// the context is cancelled
+func StartPublicServer(ctx context.Context) {
+	if GetConfig() == nil || GetConfig().Public.Listen == "" {
+		return
+	}
+
+	srv := &http.Server{
+		Addr:              GetConfig().Public.Listen,
+		Handler:           newPublicApp(models.DB),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		log.Printf("public: serving share links and feeds on %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("public: server failed: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+}
+
+// atomFeed is an Atom feed (RFC 4287)
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated time.Time   `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    time.Time      `xml:"updated"`
+	Published  time.Time      `xml:"published"`
+	Link       atomLink       `xml:"link"`
+	Author     atomAuthor     `xml:"author"`
+	Summary    string         `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// collectionFeed returns the Atom feed of a public collection's latest clips
+func collectionFeed(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	collectionID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("feed not found"))
+	}
+	collection := &models.Collection{}
+	if err := tx.Where("id = ? AND public = ?", collectionID, true).First(collection); err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("feed not found"))
+	}
+
+	clips := models.Clips{}
+	err = tx.Where("collection_id = ? AND deleted_at IS NULL AND archived_at IS NULL AND disabled_at IS NULL", collection.ID).
+		Order("created_at DESC").Limit(feedSize).All(&clips)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	feed := atomFeed{
+		ID:      "urn:uuid:" + collection.ID.String(),
+		Title:   collection.Name,
+		Updated: collection.UpdatedAt.UTC(),
+		Links:   []atomLink{{Href: publicURL("/feeds/" + collection.ID.String()), Rel: "self"}},
+	}
+	for i := range clips {
+		clip := &clips[i]
+		if err := clip.LoadTags(tx); err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+		entry := atomEntry{
+			ID:        "urn:uuid:" + clip.ID.String(),
+			Title:     clip.Title,
+			Updated:   clip.UpdatedAt.UTC(),
+			Published: clip.CreatedAt.UTC(),
+			Link:      atomLink{Href: clip.URL},
+			Author:    atomAuthor{Name: clipAuthor(clip)},
+			Summary:   clip.Excerpt.String,
+		}
+		for _, tag := range clip.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		if entry.Updated.After(feed.Updated) {
+			feed.Updated = entry.Updated
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	c.Response().Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	c.Response().WriteHeader(http.StatusOK)
+	c.Response().Write([]byte(xml.Header))
+	c.Response().Write(data)
+	return nil
+}
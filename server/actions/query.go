@@ -0,0 +1,246 @@
+package actions
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// The q filter is a small query language:
+//
+//	tag:go site:github.com before:2024-01-01 "exact phrase" -draft
+//
+// Words and "quoted phrases" must each appear in one of the searched fields:
+// the title, highlights (text and comments), notes, or body (extracted text
+// and page metadata), all of them unless the fields parameter picks some.
+// Clips are scored by where the words appear, see searchWeights. field:value terms filter on tag, site (or domain,
+// subdomains included), mode, lang, before and after (clip dates, YYYY-MM-DD
+// or RFC 3339, the day itself excluded) and is (read, unread, archived).
+// Values can be quoted. A leading - negates any term. Terms with an unknown
+// field, such as URLs, are searched as text.
+
+// queryTerm is a term of a search query
+type queryTerm struct {
+	Field   string // Empty for text
+	Value   string
+	Negated bool
+}
+
+// queryFields are the fields of field:value terms
+var queryFields = map[string]bool{
+	"tag": true, "site": true, "domain": true, "mode": true, "lang": true,
+	"before": true, "after": true, "is": true,
+}
+
+// searchWeights are the fields words are searched in, with the points a
+// word found in the field adds to a clip's relevance score
+var searchWeights = map[string]int{"title": 8, "highlights": 4, "notes": 2, "body": 1}
+
+// searchFieldNames lists the searched fields by decreasing weight
+var searchFieldNames = []string{"title", "highlights", "notes", "body"}
+
+// parseSearchFields reads a list of searched fields; empty means all of them
+func parseSearchFields(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return searchFieldNames, nil
+	}
+	picked := map[string]bool{}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := searchWeights[name]; !ok {
+			return nil, fmt.Errorf("invalid search field %q: expected title, highlights, notes or body", name)
+		}
+		picked[name] = true
+	}
+	var fields []string
+	for _, name := range searchFieldNames {
+		if picked[name] {
+			fields = append(fields, name)
+		}
+	}
+	return fields, nil
+}
+
+// parseQuery splits a search query into terms
+func parseQuery(query string) []queryTerm {
+	var terms []queryTerm
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		var term queryTerm
+		if runes[i] == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			term.Negated = true
+			i++
+		}
+
+		// field: prefix
+		start := i
+		for i < len(runes) && unicode.IsLetter(runes[i]) {
+			i++
+		}
+		if i < len(runes) && runes[i] == ':' && queryFields[strings.ToLower(string(runes[start:i]))] {
+			term.Field = strings.ToLower(string(runes[start:i]))
+			i++
+		} else {
+			i = start
+		}
+
+		// Quoted or bare value
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			term.Value = string(runes[i+1 : end])
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			term.Value = string(runes[i:end])
+			i = end
+		}
+
+		if term.Value = strings.TrimSpace(term.Value); term.Value != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// applyQuery adds the conditions of a search query to a clips query, words
+// being searched in fields. Errors describe invalid terms.
+func applyQuery(q *pop.Query, query string, fields []string, userID uuid.UUID) (*pop.Query, error) {
+	for _, term := range parseQuery(query) {
+		clause, args, err := term.sql(fields, userID)
+		if err != nil {
+			return nil, err
+		}
+		if term.Negated {
+			clause = "NOT " + clause
+		}
+		q = q.Where(clause, args...)
+	}
+	return q, nil
+}
+
+// queryShowsArchived reports whether a query asks for archived clips, which
+// listings hide by default
+func queryShowsArchived(query string) bool {
+	for _, term := range parseQuery(query) {
+		if term.Field == "is" && strings.EqualFold(term.Value, "archived") && !term.Negated {
+			return true
+		}
+	}
+	return false
+}
+
+// queryText returns the words and phrases a query looks for, lowercased
+func queryText(query string) []string {
+	var text []string
+	for _, term := range parseQuery(query) {
+		if term.Field == "" && !term.Negated {
+			text = append(text, strings.ToLower(term.Value))
+		}
+	}
+	return text
+}
+
+// queryScore returns the relevance score of clips for a search query as a
+// SQL expression: the weights of the fields each word is found in, summed.
+// It is empty when the query has no words.
+func queryScore(query string, fields []string, userID uuid.UUID) (string, []interface{}) {
+	var parts []string
+	var args []interface{}
+	for _, text := range queryText(query) {
+		for _, field := range fields {
+			clause, fieldArgs := fieldMatch(field, text, userID)
+			parts = append(parts, fmt.Sprintf("CASE WHEN %s THEN %d ELSE 0 END", clause, searchWeights[field]))
+			args = append(args, fieldArgs...)
+		}
+	}
+	if len(parts) == 0 {
+		return "", nil
+	}
+	return "(" + strings.Join(parts, " + ") + ")", args
+}
+
+// fieldMatch returns the condition that a searched field contains text,
+// ignoring case. LIKE ignores it on SQLite only, so both sides are
+// lowercased for PostgreSQL to match the same clips.
+func fieldMatch(field, text string, userID uuid.UUID) (string, []interface{}) {
+	like := "%" + strings.ToLower(text) + "%"
+	switch field {
+	case "title":
+		return "LOWER(title) LIKE ?", []interface{}{like}
+	case "highlights":
+		return "id IN (SELECT highlights.clip_id FROM highlights WHERE highlights.user_id = ? AND (LOWER(highlights.text) LIKE ? OR LOWER(COALESCE(highlights.comment, '')) LIKE ?))",
+			[]interface{}{userID, like, like}
+	case "notes":
+		return "LOWER(COALESCE(notes, '')) LIKE ?", []interface{}{like}
+	}
+	// Extracted text (OCR, PDF) and page metadata (description, author, ...),
+	// JSONB on PostgreSQL
+	return "(LOWER(COALESCE(content_text, '')) LIKE ? OR LOWER(COALESCE(CAST(metadata AS TEXT), '')) LIKE ?)", []interface{}{like, like}
+}
+
+// sql returns the condition a term puts on clips, words being searched in
+// fields
+func (t queryTerm) sql(fields []string, userID uuid.UUID) (string, []interface{}, error) {
+	switch t.Field {
+	case "":
+		// Substring search over the fields
+		var clauses []string
+		var args []interface{}
+		for _, field := range fields {
+			clause, fieldArgs := fieldMatch(field, t.Value, userID)
+			clauses = append(clauses, clause)
+			args = append(args, fieldArgs...)
+		}
+		return "(" + strings.Join(clauses, " OR ") + ")", args, nil
+	case "tag":
+		return "(id IN (SELECT clips_tags.clip_id FROM clips_tags JOIN tags ON tags.id = clips_tags.tag_id WHERE tags.user_id = ? AND tags.name = ?))",
+			[]interface{}{userID, t.Value}, nil
+	case "site", "domain":
+		domain := strings.ToLower(t.Value)
+		return "(domain = ? OR domain LIKE ?)", []interface{}{domain, "%." + domain}, nil
+	case "mode":
+		if !isClipMode(t.Value) {
+			return "", nil, fmt.Errorf("invalid mode %q", t.Value)
+		}
+		return "(mode = ?)", []interface{}{t.Value}, nil
+	case "lang":
+		return "(COALESCE(language, '') = ?)", []interface{}{strings.ToLower(t.Value)}, nil
+	case "before":
+		before, err := parseDateParam(t.Value, false)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid before date: %w", err)
+		}
+		return "(created_at < ?)", []interface{}{before}, nil
+	case "after":
+		after, err := parseDateParam(t.Value, true)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid after date: %w", err)
+		}
+		return "(created_at >= ?)", []interface{}{after}, nil
+	case "is":
+		switch strings.ToLower(t.Value) {
+		case "read":
+			return "(read_at IS NOT NULL)", nil, nil
+		case "unread":
+			return "(read_at IS NULL)", nil, nil
+		case "archived":
+			return "(archived_at IS NOT NULL)", nil, nil
+		}
+		return "", nil, fmt.Errorf("invalid is:%s: expected read, unread or archived", t.Value)
+	}
+	return "", nil, fmt.Errorf("unknown search field %q", t.Field)
+}
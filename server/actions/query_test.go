@@ -0,0 +1,134 @@
+package actions
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gofrs/uuid"
+)
+
+func (as *ActionSuite) Test_ParseQuery() {
+	as.Equal([]queryTerm{
+		{Field: "tag", Value: "go"},
+		{Field: "site", Value: "github.com"},
+		{Field: "before", Value: "2024-01-01"},
+		{Value: "exact phrase"},
+		{Value: "draft", Negated: true},
+		{Field: "tag", Value: "two words", Negated: true},
+		{Value: "https://example.com/a"},
+		{Value: "-"},
+	}, parseQuery(`tag:go  SITE:github.com before:2024-01-01 "exact phrase" -draft -tag:"two words" https://example.com/a -`))
+	as.Empty(parseQuery(` "" tag: `))
+}
+
+func (as *ActionSuite) Test_SearchQuery() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+
+	old := kit.CreateClip(user, testkit.WithTags("go"), testkit.WithClip(func(c *models.Clip) {
+		c.Title = "Concurrency patterns in Go"
+		c.Domain = "blog.github.com"
+		c.CreatedAt = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	}))
+	draft := kit.CreateClip(user, testkit.WithTags("go", "draft"), testkit.WithClip(func(c *models.Clip) {
+		c.Title = "Go draft notes"
+		c.Domain = "github.com"
+		c.ReadAt = nulls.NewTime(time.Now())
+	}))
+	archived := kit.CreateClip(user, testkit.WithClip(func(c *models.Clip) {
+		c.Title = "Exact phrase here"
+		c.Notes = nulls.NewString("rust")
+		c.ArchivedAt = nulls.NewTime(time.Now())
+	}))
+
+	search := func(q string) []string {
+		res := client.Get("/api/v1/clips?q=" + url.QueryEscape(q))
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		var list ListClipsResponse
+		res.JSON(&list)
+		ids := []string{}
+		for _, clip := range list.Clips {
+			ids = append(ids, clip.ID)
+		}
+		return ids
+	}
+
+	as.ElementsMatch([]string{old.ID.String(), draft.ID.String()}, search("tag:go site:github.com"))
+	as.Equal([]string{old.ID.String()}, search("tag:go -tag:draft"))
+	as.Equal([]string{old.ID.String()}, search("go before:2024-01-01"))
+	as.Equal([]string{draft.ID.String()}, search("go after:2024-01-01 -concurrency"))
+	as.Equal([]string{draft.ID.String()}, search("is:read"))
+	as.Empty(search(`"phrase here"`)) // Archived clips stay hidden...
+	as.Equal([]string{archived.ID.String()}, search(`"phrase here" is:archived`))
+	as.Equal([]string{archived.ID.String()}, search("RUST is:archived"))
+	as.Empty(search(`"here phrase" is:archived`))
+
+	for _, q := range []string{"before:yesterday", "mode:video", "is:starred"} {
+		as.Equal(http.StatusBadRequest, client.Get("/api/v1/clips?q="+url.QueryEscape(q)).Code, q)
+	}
+}
+
+func (as *ActionSuite) Test_SearchWeights() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+
+	inBody := kit.CreateClip(user, testkit.WithClip(func(c *models.Clip) {
+		c.ContentText = nulls.NewString("a word about kernels")
+	}))
+	inNotes := kit.CreateClip(user, testkit.WithClip(func(c *models.Clip) {
+		c.Notes = nulls.NewString("read the kernels chapter")
+		c.CreatedAt = time.Now().Add(-time.Hour)
+	}))
+	inHighlight := kit.CreateClip(user, testkit.WithClip(func(c *models.Clip) {
+		c.CreatedAt = time.Now().Add(-2 * time.Hour)
+	}))
+	highlight := &models.Highlight{ID: uuid.Must(uuid.NewV4()), ClipID: inHighlight.ID, UserID: user.ID, Text: "quote", Comment: nulls.NewString("Kernels!"), Color: "yellow"}
+	as.NoError(kit.DB.Create(highlight))
+	inTitle := kit.CreateClip(user, testkit.WithClip(func(c *models.Clip) {
+		c.Title = "Kernels explained"
+		c.Notes = nulls.NewString("kernels, again")
+		c.CreatedAt = time.Now().Add(-3 * time.Hour)
+	}))
+
+	search := func(params string) ([]string, []int) {
+		res := client.Get("/api/v1/clips?q=kernels&" + params)
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		var list ListClipsResponse
+		res.JSON(&list)
+		ids, scores := []string{}, []int{}
+		for _, clip := range list.Clips {
+			ids = append(ids, clip.ID)
+			scores = append(scores, clip.Score)
+		}
+		return ids, scores
+	}
+
+	// Newest first by default, with scores
+	ids, scores := search("")
+	as.Equal([]string{inBody.ID.String(), inNotes.ID.String(), inHighlight.ID.String(), inTitle.ID.String()}, ids)
+	as.Equal([]int{1, 2, 4, 10}, scores)
+
+	ids, scores = search("sort=relevance")
+	as.Equal([]string{inTitle.ID.String(), inHighlight.ID.String(), inNotes.ID.String(), inBody.ID.String()}, ids)
+	as.Equal([]int{10, 4, 2, 1}, scores)
+
+	ids, scores = search("sort=relevance&fields=notes,highlights")
+	as.Equal([]string{inHighlight.ID.String(), inNotes.ID.String(), inTitle.ID.String()}, ids) // Ties newest first
+	as.Equal([]int{4, 2, 2}, scores)
+
+	// No words, no score
+	res := client.Get("/api/v1/clips?q=" + url.QueryEscape("-kernels"))
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	as.NotContains(res.Body.String(), `"score"`)
+
+	as.Equal(http.StatusBadRequest, client.Get("/api/v1/clips?q=kernels&fields=url").Code)
+	as.Equal(http.StatusBadRequest, client.Get("/api/v1/clips?q=kernels&sort=title").Code)
+	as.Equal(http.StatusBadRequest, client.Get("/api/v1/clips?q=kernels&sort=relevance&after=abc").Code)
+}
@@ -0,0 +1,76 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gobuffalo/buffalo"
+)
+
+// QuickClipPayload is the request body for POST /api/v1/quick-clip.
+// Accepted as JSON or form data so Shortcuts/Tasker can post it directly.
+type QuickClipPayload struct {
+	URL          string `json:"url" form:"url"`
+	Title        string `json:"title" form:"title"`
+	SelectedText string `json:"selected_text" form:"selected_text"`
+	Tags         string `json:"tags" form:"tags"` // Comma-separated
+	Notes        string `json:"notes" form:"notes"`
+}
+
+// quickClip saves a clip from a minimal payload (url, title, selected text)
+func quickClip(c buffalo.Context) error {
+	var req QuickClipPayload
+	if err := c.Bind(&req); err != nil {
+		return rejectClipBody(c)
+	}
+
+	payload, err := quickClipToPayload(req)
+	if err != nil {
+		return rejectClip(c, http.StatusBadRequest, rejectInvalidPayload, err.Error())
+	}
+
+	return saveClip(c, payload)
+}
+
+// quickClipToPayload expands a quick clip into a full clip payload: a
+// selection clip when text was selected, a bookmark otherwise
+func quickClipToPayload(req QuickClipPayload) (ClipPayload, error) {
+	url := strings.TrimSpace(req.URL)
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return ClipPayload{}, fmt.Errorf("A valid http(s) url is required")
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = extractDomain(url)
+	}
+
+	var tags []string
+	for _, tag := range strings.Split(req.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	var md strings.Builder
+	md.WriteString(fmt.Sprintf("# %s\n\n", title))
+	mode := "bookmark"
+	if text := strings.TrimSpace(req.SelectedText); text != "" {
+		mode = "selection"
+		for _, line := range strings.Split(text, "\n") {
+			md.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+		md.WriteString("\n")
+	}
+	md.WriteString(fmt.Sprintf("[%s](%s)\n", title, url))
+
+	return ClipPayload{
+		Title:    title,
+		URL:      url,
+		Markdown: md.String(),
+		Tags:     tags,
+		Notes:    strings.TrimSpace(req.Notes),
+		Mode:     mode,
+	}, nil
+}
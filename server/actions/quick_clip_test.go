@@ -0,0 +1,29 @@
+package actions
+
+import "net/http"
+
+func (as *ActionSuite) Test_QuickClip_Unauthorized() {
+	res := as.JSON("/api/v1/quick-clip").Post(QuickClipPayload{URL: "https://example.com"})
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_QuickClipToPayload() {
+	payload, err := quickClipToPayload(QuickClipPayload{
+		URL:          " https://example.com/post ",
+		SelectedText: "first\n\nsecond",
+		Tags:         "go, ,reading",
+	})
+	as.NoError(err)
+	as.Equal("selection", payload.Mode)
+	as.Equal("example.com", payload.Title)
+	as.Equal("https://example.com/post", payload.URL)
+	as.Equal([]string{"go", "reading"}, payload.Tags)
+	as.Equal("# example.com\n\n> first\n>\n> second\n\n[example.com](https://example.com/post)\n", payload.Markdown)
+
+	payload, err = quickClipToPayload(QuickClipPayload{URL: "https://example.com", Title: "Home"})
+	as.NoError(err)
+	as.Equal("bookmark", payload.Mode)
+
+	_, err = quickClipToPayload(QuickClipPayload{URL: "javascript:alert(1)"})
+	as.Error(err)
+}
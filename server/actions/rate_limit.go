@@ -0,0 +1,126 @@
+package actions
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"server/internal/config"
+	"server/internal/ratelimit"
+
+	"github.com/gobuffalo/buffalo"
+)
+
+// rateLimiter applies rate_limit to the requests of /auth, /api/v1 and the
+// public routes. The
+// limits are read on each request, so that ReloadConfig changes them; the
+// store is chosen at startup.
+type rateLimiter struct {
+	store ratelimit.Store
+}
+
+// newRateLimiter returns the limiter of rate_limit, or nil without a config.
+// An invalid Redis URL falls back to limits in memory.
+func newRateLimiter() *rateLimiter {
+	cfg := GetConfig()
+	if cfg == nil {
+		return nil
+	}
+	l := &rateLimiter{store: ratelimit.NewMemory()}
+	if rl := cfg.RateLimit; rl.Enabled && rl.Store == "redis" {
+		store, err := ratelimit.NewRedis(rl.RedisURL)
+		if err != nil {
+			log.Printf("Warning: rate limits kept in memory: %v", err)
+		} else {
+			l.store = store
+		}
+	}
+	return l
+}
+
+// byIP limits the requests of each client address (requestIP), before they
+// are authenticated
+func (l *rateLimiter) byIP(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		rule := GetConfig().RateLimit.PerIP
+		return l.limit(c, "ip:"+requestIP(c), rule, next)
+	}
+}
+
+// byCredential limits the requests of each service token, and of the login
+// sessions of each user, after authMiddleware
+func (l *rateLimiter) byCredential(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		userID, _ := c.Value("user_id").(string)
+		key := "user:" + userID
+		if tokenID, _ := c.Value("token_id").(string); tokenID != "" {
+			key = "token:" + tokenID
+		}
+		return l.limit(c, key, GetConfig().RateLimit.PerUser, next)
+	}
+}
+
+// limit answers 429, with Retry-After, when key has no request left under
+// rule. Requests go through when rate_limit is disabled, and when the store
+// fails, so that an unavailable Redis does not take the API down.
+func (l *rateLimiter) limit(c buffalo.Context, key string, rule config.RateLimitRule, next buffalo.Handler) error {
+	if !GetConfig().RateLimit.Enabled {
+		return next(c)
+	}
+	limit := ratelimit.Limit{PerMinute: rule.RequestsPerMinute, Burst: rule.Burst}
+	res, err := l.store.Take(c.Request().Context(), key, limit, time.Now())
+	if err != nil {
+		log.Printf("Warning: rate limit not applied: %v", err)
+		return next(c)
+	}
+	if !res.Allowed {
+		return tooManyRequests(c, res, "rate limit exceeded")
+	}
+	return next(c)
+}
+
+// passphraseAttempts limits the passphrase guesses on share links to
+// public.passphrase_attempts_per_minute on each link and from each client
+// address
+func (l *rateLimiter) passphraseAttempts(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		limit := ratelimit.Limit{PerMinute: GetConfig().Public.PassphraseAttemptsPerMinute}
+		return l.guard(c, limit, "too many passphrase attempts", next,
+			"passphrase:share:"+c.Param("token"), "passphrase:ip:"+requestIP(c))
+	}
+}
+
+// reportAttempts limits the abuse reports sent from each client address to
+// public.reports_per_minute
+func (l *rateLimiter) reportAttempts(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		limit := ratelimit.Limit{PerMinute: GetConfig().Public.ReportsPerMinute}
+		return l.guard(c, limit, "too many reports", next, "report:ip:"+requestIP(c))
+	}
+}
+
+// guard lets a request open to anyone through when each of keys has one left
+// under limit. Unlike limit it applies when rate_limit is disabled, and
+// refuses requests when the store fails.
+func (l *rateLimiter) guard(c buffalo.Context, limit ratelimit.Limit, reason string, next buffalo.Handler, keys ...string) error {
+	for _, key := range keys {
+		res, err := l.store.Take(c.Request().Context(), key, limit, time.Now())
+		if err != nil {
+			return c.Error(http.StatusServiceUnavailable, fmt.Errorf("requests can't be counted: %w", err))
+		}
+		if !res.Allowed {
+			return tooManyRequests(c, res, reason)
+		}
+	}
+	return next(c)
+}
+
+// tooManyRequests answers 429 with the Retry-After of res
+func tooManyRequests(c buffalo.Context, res ratelimit.Result, reason string) error {
+	retry := int(math.Ceil(res.RetryAfter.Seconds()))
+	c.Response().Header().Set("Retry-After", strconv.Itoa(retry))
+	return c.Error(http.StatusTooManyRequests, fmt.Errorf("%s, retry in %d seconds", reason, retry))
+}
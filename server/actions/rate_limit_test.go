@@ -0,0 +1,69 @@
+package actions
+
+import (
+	"net/http"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_RateLimit() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	kit.Config.RateLimit.Enabled = true
+	kit.Config.RateLimit.PerIP.RequestsPerMinute = 60
+	kit.Config.RateLimit.PerIP.Burst = 4
+	kit.Config.RateLimit.PerUser.RequestsPerMinute = 60
+	kit.Config.RateLimit.PerUser.Burst = 2
+	app := newKitApp(kit)
+	client := kit.Client(app, user)
+
+	// Each credential has its bucket
+	as.Equal(http.StatusOK, client.Get("/api/v1/clips").Code)
+	as.Equal(http.StatusOK, client.Get("/api/v1/clips").Code)
+	res := client.Get("/api/v1/clips")
+	as.Equal(http.StatusTooManyRequests, res.Code)
+	as.Equal("1", res.Header().Get("Retry-After"))
+	var resp ErrorResponse
+	res.JSON(&resp)
+	as.Equal("too_many_requests", resp.Error.Code)
+	other := kit.Client(app, user) // Another token
+	as.Equal(http.StatusOK, other.Get("/api/v1/clips").Code)
+
+	// The address has used its burst: unauthenticated requests are limited too
+	forged := kit.Client(app, user)
+	forged.Token = "forged"
+	as.Equal(http.StatusTooManyRequests, forged.Get("/api/v1/clips").Code)
+	as.Equal(http.StatusTooManyRequests, kit.Client(app, user).Post("/auth/refresh", nil).Code)
+
+	// Throttled requests show in the usage of the user
+	kit.Config.RateLimit.PerIP.Burst = 100
+	app = newKitApp(kit)
+	client = kit.Client(app, user)
+	var usage APIUsage
+	client.Get("/api/v1/usage/api").JSON(&usage)
+	as.True(usage.RateLimit.Enabled)
+	as.Equal(60, usage.RateLimit.RequestsPerMinute)
+	as.Equal(2, usage.RateLimit.Burst)
+	as.Equal(1, usage.Throttled)
+}
+
+func (as *ActionSuite) Test_RateLimit_SpoofedForwardedFor() {
+	kit := testkit.New(as.T())
+	kit.Config.Logging.TrustProxy = true
+	kit.Config.RateLimit.Enabled = true
+	kit.Config.RateLimit.PerIP.RequestsPerMinute = 60
+	kit.Config.RateLimit.PerIP.Burst = 2
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+	client.Token = ""
+
+	// The proxy appends the client's address to whatever the client sent
+	for i, spoofed := range []string{"", "203.0.113.1, ", "203.0.113.2, ", "203.0.113.3, "} {
+		client.Header = http.Header{"X-Forwarded-For": {spoofed + "198.51.100.7"}}
+		code := client.Post("/auth/refresh", nil).Code
+		if i < 2 {
+			as.NotEqual(http.StatusTooManyRequests, code)
+		} else {
+			as.Equal(http.StatusTooManyRequests, code, "a spoofed X-Forwarded-For gets no fresh bucket")
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Opening a clip (GET /api/v1/clips/{id}) records a view, so clients can
+// offer a "continue reading" shelf of the clips the user opened last. Each
+// user keeps one row per clip: the last view and a count. Trashed clips
+// leave the shelf, and purged ones take their views along.
+
+// RecentView is a clip the user opened
+type RecentView struct {
+	ClipSummary
+	ViewedAt time.Time `json:"viewed_at"` // Last time it was opened
+	Views    int       `json:"views"`     // Times it was opened
+}
+
+// recordClipView counts a view of the clip by its owner. A failure is only
+// logged, as the clip was served anyway.
+func recordClipView(c buffalo.Context, tx *pop.Connection, clip *models.Clip) {
+	if err := models.RecordClipView(tx, clip.UserID, clip.ID, time.Now()); err != nil {
+		c.Logger().Errorf("Failed to record a view of clip %s: %v", clip.ID, err)
+	}
+}
+
+// listRecentViews returns the clips the user opened last, most recent
+// first (limit, default 20, at most 100)
+func listRecentViews(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	limit := 20
+	if s := c.Param("limit"); s != "" {
+		if n, err := fmt.Sscanf(s, "%d", &limit); err != nil || n != 1 || limit < 1 || limit > 100 {
+			return c.Error(http.StatusBadRequest, fmt.Errorf("limit must be between 1 and 100"))
+		}
+	}
+
+	views, err := models.FindRecentClipViews(tx, userID, limit)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	resp := make([]RecentView, 0, len(views))
+	if len(views) > 0 {
+		ids := make([]interface{}, len(views))
+		for i, view := range views {
+			ids[i] = view.ClipID
+		}
+		clips := models.Clips{}
+		if err := tx.Where("id IN (?)", ids...).All(&clips); err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+		if err := clips.LoadTags(tx); err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+		byID := make(map[uuid.UUID]*models.Clip, len(clips))
+		for i := range clips {
+			byID[clips[i].ID] = &clips[i]
+		}
+		for _, view := range views {
+			if clip, ok := byID[view.ClipID]; ok {
+				resp = append(resp, RecentView{ClipSummary: clipToSummary(clip), ViewedAt: view.ViewedAt, Views: view.Views})
+			}
+		}
+	}
+
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"clips": resp,
+	}))
+}
@@ -0,0 +1,58 @@
+package actions
+
+import (
+	"net/http"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_RecentViews() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+	other := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	first := kit.CreateClip(user, testkit.WithTags("go"))
+	second := kit.CreateClip(user)
+	kit.CreateClip(user) // Never opened
+
+	recent := func() []RecentView {
+		res := client.Get("/api/v1/clips/recent-views")
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		var resp struct {
+			Clips []RecentView `json:"clips"`
+		}
+		res.JSON(&resp)
+		return resp.Clips
+	}
+	as.Empty(recent())
+
+	for _, id := range []string{first.ID.String(), second.ID.String(), first.ID.String()} {
+		as.Equal(http.StatusOK, client.Get("/api/v1/clips/"+id).Code)
+	}
+	views := recent()
+	as.Len(views, 2)
+	as.Equal(first.ID.String(), views[0].ID)
+	as.Equal(2, views[0].Views)
+	as.Equal([]string{"go"}, views[0].Tags)
+	as.Equal(second.ID.String(), views[1].ID)
+	as.Equal(1, views[1].Views)
+	as.False(views[0].ViewedAt.Before(views[1].ViewedAt))
+
+	res := client.Get("/api/v1/clips/recent-views?limit=1")
+	as.Equal(http.StatusOK, res.Code)
+	as.Contains(res.Body.String(), first.ID.String())
+	as.NotContains(res.Body.String(), second.ID.String())
+	as.Equal(http.StatusBadRequest, client.Get("/api/v1/clips/recent-views?limit=0").Code)
+
+	// Views are per user, and trashed clips leave the shelf
+	as.Equal(http.StatusNotFound, other.Get("/api/v1/clips/"+first.ID.String()).Code)
+	res = other.Get("/api/v1/clips/recent-views")
+	as.Equal(http.StatusOK, res.Code)
+	as.NotContains(res.Body.String(), first.ID.String())
+
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/clips/"+first.ID.String()).Code)
+	views = recent()
+	as.Len(views, 1)
+	as.Equal(second.ID.String(), views[0].ID)
+}
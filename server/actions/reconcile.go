@@ -0,0 +1,185 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"server/models"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// The replication_reconcile task goes over every clip, not only the changed
+// ones: a clip missing on the primary storage (a replaced disk, a folder
+// removed by hand) is restored from the first replica that has it, and the
+// files a replica is missing (every file of a newly added replica) are
+// copied to it. Restored files are written as the replica has them, so
+// encrypted and compressed files stay so.
+
+// ReconcileResult is the outcome of a reconciliation
+type ReconcileResult struct {
+	Clips    int      `json:"clips"`          // Clips checked
+	Restored int      `json:"restored"`       // Clips restored from a replica
+	Copied   int      `json:"copied"`         // Files copied to the replicas
+	Lost     []string `json:"lost,omitempty"` // Paths of the clips no replica has
+	Targets  []string `json:"targets"`
+}
+
+// ReconcileReplicas restores the clips missing on the primary storage from
+// the replicas, then copies to each replica the files it is missing
+func ReconcileReplicas(ctx context.Context, db *pop.Connection) (*ReconcileResult, error) {
+	replicationPass.Lock()
+	defer replicationPass.Unlock()
+
+	targets, _, err := replicaTargets()
+	if err != nil {
+		return nil, err
+	}
+	clips := models.Clips{}
+	if err := db.Order("created_at ASC").All(&clips); err != nil {
+		return nil, err
+	}
+
+	cfg := GetConfig()
+	result := &ReconcileResult{Clips: len(clips)}
+	for _, t := range targets {
+		result.Targets = append(result.Targets, t.String())
+	}
+	users := map[uuid.UUID]*models.User{}
+	for i := range clips {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		clip := &clips[i]
+		user, ok := users[clip.UserID]
+		if !ok {
+			user = &models.User{}
+			if err := db.Find(user, clip.UserID); err != nil {
+				return nil, fmt.Errorf("failed to find the owner of clip %s: %w", clip.ID, err)
+			}
+			users[clip.UserID] = user
+		}
+
+		root := clipRoot(cfg, user, clip)
+		files, err := clipFiles(root, clip)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			restored, err := restoreFromReplica(ctx, targets, root, user, clip)
+			if err != nil {
+				return nil, err
+			}
+			if !restored {
+				log.Printf("replication: clip %s (%s) is on no replica", clip.ID, clip.Path)
+				result.Lost = append(result.Lost, clip.Path)
+				continue
+			}
+			result.Restored++
+		}
+
+		copied, _, err := replicateClip(ctx, targets, root, user, clip)
+		if err != nil {
+			return nil, err
+		}
+		result.Copied += copied
+	}
+	return result, nil
+}
+
+// restoreFromReplica copies the files of a clip back from the first replica
+// that has them, and reports whether one had
+func restoreFromReplica(ctx context.Context, targets []replicaTarget, root string, user *models.User, clip *models.Clip) (bool, error) {
+	p := clip.Path
+	if clip.DeletedAt.Valid {
+		p = filepath.Join(trashDirName, clip.Path)
+	}
+	base := user.ID.String() + "/"
+	for _, t := range targets {
+		var rels []string
+		if clip.IsNote() {
+			notes, err := t.list(ctx, base+filepath.ToSlash(p))
+			if err != nil {
+				return false, fmt.Errorf("failed to list %s: %w", t, err)
+			}
+			for _, rel := range notes {
+				if rel == base+filepath.ToSlash(p) {
+					rels = append(rels, rel)
+				}
+			}
+			if len(rels) == 0 {
+				continue
+			}
+			// The attachments are in the vault's attachments folder, or in
+			// its originals folder, named after the note's ID
+			dir, err := filepath.Rel(root, noteAttachments(filepath.Join(root, clip.Path)))
+			if err != nil {
+				return false, err
+			}
+			attachments, err := t.list(ctx, base+filepath.ToSlash(dir)+"/")
+			if err != nil {
+				return false, fmt.Errorf("failed to list %s: %w", t, err)
+			}
+			for _, rel := range attachments {
+				if strings.HasPrefix(path.Base(rel), noteMediaPrefix(clip.ID)) {
+					rels = append(rels, rel)
+				}
+			}
+		} else {
+			var err error
+			if rels, err = t.list(ctx, base+filepath.ToSlash(p)+"/"); err != nil {
+				return false, fmt.Errorf("failed to list %s: %w", t, err)
+			}
+			if len(rels) == 0 {
+				continue
+			}
+		}
+
+		for _, rel := range rels {
+			data, modTime, err := t.get(ctx, rel)
+			if err != nil {
+				return false, fmt.Errorf("failed to read %s from %s: %w", rel, t, err)
+			}
+			file := filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(rel, base)))
+			if err := clipFS.MkdirAll(filepath.Dir(file), 0755); err != nil {
+				return false, err
+			}
+			if err := writeReplicaFile(file, data); err != nil {
+				return false, err
+			}
+			// So that the replica is not sent the file back
+			if !modTime.IsZero() {
+				if err := os.Chtimes(file, modTime, modTime); err != nil {
+					return false, err
+				}
+			}
+		}
+		log.Printf("replication: restored clip %s (%s) from %s", clip.ID, clip.Path, t)
+		return true, nil
+	}
+	return false, nil
+}
+
+// writeReplicaFile writes a file restored from a replica as is, unlike
+// writeFileSync, which would compress and seal it again
+func writeReplicaFile(file string, data []byte) error {
+	f, err := clipFS.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
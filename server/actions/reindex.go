@@ -0,0 +1,376 @@
+package actions
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"server/internal/config"
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// After a database loss, or for clips written out-of-band, an admin can
+// rebuild the rows of a user's clips from their files. Clip folders are
+// looked for in web-clips under the user's clip directory and the storage of
+// their collections, and notes in the notes folder (storage.layout:
+// obsidian). A folder is a clip when it has a sidecar (clips.sidecar) or a
+// markdown page whose frontmatter has a url; the sidecar is preferred, as it
+// keeps the clip's ID and page metadata. Files that already have a row are
+// left alone. Rebuilt clips have no versions, highlights or read state, and
+// their tags are taken as written.
+
+// Files a clip is rebuilt from
+const (
+	reindexSidecar     = "sidecar"
+	reindexFrontmatter = "frontmatter" // Of the page in its folder
+	reindexNote        = "note"
+)
+
+// ReindexedClip is a clip rebuilt (or, in a dry run, found) by a reindex
+type ReindexedClip struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Path   string `json:"path"`   // Clip folder or note
+	Source string `json:"source"` // sidecar, frontmatter or note
+}
+
+// clipFrontmatter is what the default frontmatter tells about a clip
+type clipFrontmatter struct {
+	Title     string    `yaml:"title"`
+	URL       string    `yaml:"url"`
+	ClippedAt string    `yaml:"clipped_at"`
+	Mode      string    `yaml:"mode"`
+	Tags      []string  `yaml:"tags"`
+	Notes     string    `yaml:"notes"`
+	Location  []float64 `yaml:"location"`
+	Place     string    `yaml:"place"`
+	Lang      string    `yaml:"lang"`
+}
+
+// attachmentEmbeds matches the ID prefix of attachments embedded in a note
+var attachmentEmbeds = regexp.MustCompile(`!\[\[(?:[^\]|]*/)?([0-9a-f]{8})-[^\]|]+`)
+
+// ReindexClips creates the missing rows of the user's clips found in their
+// storage and returns them. With dryRun nothing is written.
+func ReindexClips(tx *pop.Connection, user *models.User, dryRun bool) ([]ReindexedClip, error) {
+	return reindexClips(tx, user, dryRun, nil)
+}
+
+// reindexClips is ReindexClips restricted to the folders and notes include
+// accepts, when not nil
+func reindexClips(tx *pop.Connection, user *models.User, dryRun bool, include func(p string) bool) ([]ReindexedClip, error) {
+	cfg := GetConfig()
+
+	// Users without a clip directory share the base path: the files of
+	// everyone's clips are known
+	known, err := clipPaths(tx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	collections, err := models.FindCollectionsByUserID(tx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	roots := []*models.Collection{nil}
+	for i := range collections {
+		if collections[i].StoragePath.Valid && collections[i].StoragePath.String != "" {
+			roots = append(roots, &collections[i])
+		}
+	}
+
+	var reindexed []ReindexedClip
+	seen := map[string]bool{}
+	for _, collection := range roots {
+		root := userClipDir(cfg, user)
+		if collection != nil {
+			root = storageRoot(cfg, user, collection.StoragePath.String)
+		}
+		if seen[filepath.Clean(root)] {
+			continue
+		}
+		seen[filepath.Clean(root)] = true
+
+		found, err := findUnindexedClips(cfg, root, known)
+		if err != nil {
+			return nil, err
+		}
+		for _, clip := range found {
+			if include != nil && !include(filepath.Join(root, clip.Path)) {
+				continue
+			}
+			clip.UserID = user.ID
+			if collection != nil {
+				clip.CollectionID = nulls.NewUUID(collection.ID)
+				clip.StoragePath = collection.StoragePath
+			}
+			source := reindexNote
+			if !clip.IsNote() {
+				source = reindexFrontmatter
+				if _, err := os.Stat(filepath.Join(root, clip.Path, sidecarName)); err == nil {
+					source = reindexSidecar
+				}
+			}
+			if err := ensureUnusedClipID(tx, clip); err != nil {
+				return nil, err
+			}
+
+			if !dryRun {
+				if err := tx.Create(clip); err != nil {
+					return nil, err
+				}
+				if err := models.SetClipTags(tx, clip, clip.Tags); err != nil {
+					return nil, err
+				}
+				clip.Tags = models.CleanTagNames(clip.Tags)
+				refreshClipSidecar(tx, clip)
+			}
+			reindexed = append(reindexed, ReindexedClip{
+				ID:     clip.ID.String(),
+				Title:  clip.Title,
+				Path:   filepath.Join(root, clip.Path),
+				Source: source,
+			})
+		}
+	}
+	return reindexed, nil
+}
+
+// clipPaths returns the folders and notes of every clip, trash excluded, by
+// cleaned path
+func clipPaths(tx *pop.Connection, cfg *config.Config) (map[string]bool, error) {
+	users := models.Users{}
+	if err := tx.All(&users); err != nil {
+		return nil, err
+	}
+	owners := make(map[uuid.UUID]*models.User, len(users))
+	for i := range users {
+		owners[users[i].ID] = &users[i]
+	}
+
+	clips := models.Clips{}
+	if err := tx.Select("user_id", "path", "storage_path").All(&clips); err != nil {
+		return nil, err
+	}
+	paths := make(map[string]bool, len(clips))
+	for i := range clips {
+		if user, ok := owners[clips[i].UserID]; ok {
+			paths[filepath.Clean(filepath.Join(clipRoot(cfg, user, &clips[i]), clips[i].Path))] = true
+		}
+	}
+	return paths, nil
+}
+
+// findUnindexedClips returns the clips stored in root whose files aren't in
+// known, with their path relative to root
+func findUnindexedClips(cfg *config.Config, root string, known map[string]bool) ([]*models.Clip, error) {
+	var found []*models.Clip
+
+	webClips := filepath.Join(root, "web-clips")
+	err := filepath.WalkDir(webClips, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p == webClips {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() || p == webClips {
+			return nil
+		}
+		// Staging folders, uploads and the media store
+		if strings.HasPrefix(d.Name(), ".") || known[filepath.Clean(p)] {
+			return filepath.SkipDir
+		}
+		clip, ok, err := readClipFolder(p)
+		if err != nil || !ok {
+			return err // Not a clip folder: maybe one of a folder template's
+		}
+		clip.Path, _ = filepath.Rel(root, p)
+		found = append(found, clip)
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notes := filepath.Join(root, cfg.Storage.ObsidianFolder)
+	entries, err := os.ReadDir(notes)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		p := filepath.Join(notes, entry.Name())
+		if entry.IsDir() || !isNotePath(p) || known[filepath.Clean(p)] {
+			continue
+		}
+		data, err := readClipFile(p)
+		if err != nil {
+			return nil, err
+		}
+		// Notes of the vault that aren't clips have no url
+		clip, ok := clipFromFrontmatter(string(data), entry)
+		if !ok {
+			continue
+		}
+		if m := attachmentEmbeds.FindStringSubmatch(string(data)); m != nil {
+			clip.ID = noteClipID(m[1])
+		}
+		clip.Path = filepath.Join(cfg.Storage.ObsidianFolder, entry.Name())
+		found = append(found, clip)
+	}
+	return found, nil
+}
+
+// readClipFolder reads the clip stored in a folder from its sidecar, or the
+// frontmatter of its page. ok is false when the folder isn't a clip's.
+func readClipFolder(folderPath string) (*models.Clip, bool, error) {
+	entries, err := os.ReadDir(folderPath)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if data, err := readClipFile(filepath.Join(folderPath, sidecarName)); err == nil {
+		var sidecar ClipSidecar
+		if err := json.Unmarshal(data, &sidecar); err == nil && sidecar.URL != "" {
+			return clipFromSidecar(sidecar), true, nil
+		}
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".md") {
+			continue
+		}
+		data, err := readClipFile(filepath.Join(folderPath, entry.Name()))
+		if err != nil {
+			return nil, false, err
+		}
+		if clip, ok := clipFromFrontmatter(string(data), entry); ok {
+			return clip, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// clipFromSidecar rebuilds a clip from its sidecar
+func clipFromSidecar(sidecar ClipSidecar) *models.Clip {
+	req := ClipPayload{
+		Title: sidecar.Title,
+		URL:   sidecar.URL,
+		Mode:  sidecar.Mode,
+		Tags:  sidecar.Tags,
+		Notes: sidecar.Notes,
+		lang:  sidecar.Language,
+	}
+	if sidecar.Location != nil {
+		req.Place = sidecar.Location.Place
+		if sidecar.Location.Latitude != 0 || sidecar.Location.Longitude != 0 {
+			req.Latitude, req.Longitude = &sidecar.Location.Latitude, &sidecar.Location.Longitude
+		}
+	}
+	if sidecar.Metadata != nil {
+		req.meta = *sidecar.Metadata
+	}
+
+	clip := clipFromPayload(req, sidecar.ClippedAt)
+	if id, err := uuid.FromString(sidecar.ID); err == nil {
+		clip.ID = id
+	}
+	return clip
+}
+
+// clipFromFrontmatter rebuilds a clip from the frontmatter of its page, the
+// file entry. ok is false when it has no url.
+func clipFromFrontmatter(content string, entry fs.DirEntry) (*models.Clip, bool) {
+	if !strings.HasPrefix(content, "---\n") {
+		return nil, false
+	}
+	end := strings.Index(content[4:], "\n---\n")
+	if end == -1 {
+		return nil, false
+	}
+	var fm clipFrontmatter
+	if err := yaml.Unmarshal([]byte(content[4:4+end]), &fm); err != nil || fm.URL == "" {
+		return nil, false
+	}
+
+	req := ClipPayload{
+		Title: fm.Title,
+		URL:   fm.URL,
+		Mode:  fm.Mode,
+		Tags:  fm.Tags,
+		Notes: fm.Notes,
+		Place: fm.Place,
+		lang:  fm.Lang,
+	}
+	if len(fm.Location) == 2 {
+		req.Latitude, req.Longitude = &fm.Location[0], &fm.Location[1]
+	}
+	clippedAt, err := time.Parse(time.RFC3339, fm.ClippedAt)
+	if err != nil {
+		// Frontmatter templates may leave the date out
+		if info, err := entry.Info(); err == nil {
+			clippedAt = info.ModTime()
+		}
+	}
+	return clipFromPayload(req, clippedAt), true
+}
+
+// clipFromPayload builds the row of a clip saved with req at clippedAt
+func clipFromPayload(req ClipPayload, clippedAt time.Time) *models.Clip {
+	if req.Title == "" {
+		req.Title = req.URL
+	}
+	if !isClipMode(req.Mode) {
+		req.Mode = "article"
+	}
+	clip := &models.Clip{
+		ID:            uuid.Must(uuid.NewV4()),
+		Title:         req.Title,
+		URL:           req.URL,
+		NormalizedURL: normalizeURL(req.URL),
+		Domain:        clipDomain(req.URL),
+		Mode:          req.Mode,
+		Notes:         nulls.NewString(req.Notes),
+		Tags:          req.Tags,
+		CreatedAt:     clippedAt,
+	}
+	applyLocation(clip, req)
+	applyMetadata(clip, req)
+	applyLanguage(clip, req)
+	return clip
+}
+
+// noteClipID returns a new clip ID starting with the attachment prefix of a
+// note, so its attachments stay its own
+func noteClipID(prefix string) uuid.UUID {
+	id := uuid.Must(uuid.NewV4())
+	if rebuilt, err := uuid.FromString(prefix + id.String()[8:]); err == nil {
+		return rebuilt
+	}
+	return id
+}
+
+// ensureUnusedClipID gives clip a new ID when its own, from a sidecar or a
+// note, is taken by a row
+func ensureUnusedClipID(tx *pop.Connection, clip *models.Clip) error {
+	exists, err := tx.Where("id = ?", clip.ID).Exists(&models.Clip{})
+	if err != nil || !exists {
+		return err
+	}
+	if clip.IsNote() {
+		clip.ID = noteClipID(clip.ID.String()[:8])
+	} else {
+		clip.ID = uuid.Must(uuid.NewV4())
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/gofrs/uuid"
+)
+
+func (as *ActionSuite) Test_ReindexClips() {
+	kit := testkit.New(as.T())
+	kit.Config.Clips.Sidecar = true
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+
+	forget := func(id uuid.UUID) {
+		for _, table := range []string{"clips_tags", "clip_versions"} {
+			as.NoError(kit.DB.RawQuery("DELETE FROM "+table+" WHERE clip_id = ?", id).Exec())
+		}
+		as.NoError(kit.DB.RawQuery("DELETE FROM clips WHERE id = ?", id).Exec())
+	}
+
+	// A clip with a sidecar, one with only its frontmatter, and one still indexed
+	var saved ClipResponse
+	res := client.Post("/api/v1/clips", ClipPayload{
+		Title: "Sidecar", URL: "https://Example.com/sidecar", Markdown: "# Sidecar", Mode: "article", Tags: []string{"go", "web"},
+	})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&saved)
+	withSidecar := uuid.FromStringOrNil(saved.ID)
+	forget(withSidecar)
+
+	written := kit.CreateClip(user, testkit.WithClip(func(c *models.Clip) { c.Title = "Out of band" }))
+	forget(written.ID)
+	kept := kit.CreateClip(user)
+
+	// Dry run
+	reindexed, err := ReindexClips(kit.DB, user, true)
+	as.NoError(err)
+	as.Len(reindexed, 2)
+	count, err := kit.DB.Where("user_id = ?", user.ID).Count(&models.Clip{})
+	as.NoError(err)
+	as.Equal(1, count)
+
+	reindexed, err = ReindexClips(kit.DB, user, false)
+	as.NoError(err)
+	as.Len(reindexed, 2)
+	sources := map[string]string{}
+	for _, c := range reindexed {
+		sources[c.Title] = c.Source
+	}
+	as.Equal(map[string]string{"Sidecar": reindexSidecar, "Out of band": reindexFrontmatter}, sources)
+
+	clip := &models.Clip{}
+	as.NoError(kit.DB.Find(clip, withSidecar))
+	as.Equal("https://Example.com/sidecar", clip.URL)
+	as.Equal("example.com", clip.Domain)
+	as.Equal(filepath.Dir(saved.Path), clip.Path)
+	as.NoError(clip.LoadTags(kit.DB))
+	as.Equal([]string{"go", "web"}, clip.Tags)
+
+	clip = &models.Clip{}
+	as.NoError(kit.DB.Where("title = ?", "Out of band").First(clip))
+	as.Equal(written.Path, clip.Path)
+	as.Equal(written.URL, clip.URL)
+	// It has a sidecar now
+	as.FileExists(filepath.Join(kit.StorageRoot, clip.Path, sidecarName))
+
+	// Restored clips are served like the others
+	res = client.Get("/api/v1/clips/" + withSidecar.String())
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+
+	// Nothing left to restore
+	reindexed, err = ReindexClips(kit.DB, user, false)
+	as.NoError(err)
+	as.Empty(reindexed)
+	as.NoError(kit.DB.Find(&models.Clip{}, kept.ID))
+}
+
+func (as *ActionSuite) Test_ReindexClips_Notes() {
+	kit := testkit.New(as.T())
+	kit.Config.Storage.Layout = layoutObsidian
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+
+	var buf bytes.Buffer
+	as.NoError(png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 10, 10))))
+	var saved ClipResponse
+	res := client.Post("/api/v1/clips", ClipPayload{
+		Title:    "Vault note",
+		URL:      "https://example.com/note",
+		Markdown: "# Note\n\n![hero](media/hero.png)",
+		Mode:     "article",
+		Tags:     []string{"go"},
+		Images:   []ImagePayload{{Filename: "hero.png", Data: base64.StdEncoding.EncodeToString(buf.Bytes())}},
+	})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&saved)
+	as.NoError(kit.DB.RawQuery("DELETE FROM clips_tags WHERE clip_id = ?", saved.ID).Exec())
+	as.NoError(kit.DB.RawQuery("DELETE FROM clips WHERE id = ?", saved.ID).Exec())
+
+	// Notes of the vault that aren't clips are left alone
+	notes := filepath.Join(kit.StorageRoot, kit.Config.Storage.ObsidianFolder)
+	as.NoError(os.WriteFile(filepath.Join(notes, "Ideas.md"), []byte("---\ntags: [todo]\n---\n\nIdeas\n"), 0644))
+
+	reindexed, err := ReindexClips(kit.DB, user, false)
+	as.NoError(err)
+	as.Require().Len(reindexed, 1)
+	as.Equal(reindexNote, reindexed[0].Source)
+
+	// The clip ID keeps the prefix of its attachments
+	clip := &models.Clip{}
+	as.NoError(kit.DB.Where("user_id = ?", user.ID).First(clip))
+	as.Equal(saved.ID[:8], clip.ID.String()[:8])
+	as.Equal(saved.Path, clip.Path)
+	as.NoError(clip.LoadTags(kit.DB))
+	as.Equal([]string{"go"}, clip.Tags)
+
+	res = client.Get("/api/v1/clips/" + clip.ID.String() + "/media/" + noteMediaPrefix(clip.ID) + "hero.png")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+}
@@ -0,0 +1,111 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"server/internal/config"
+	"server/internal/safehttp"
+)
+
+// remoteFetchConcurrency bounds the images downloaded at once for a clip
+const remoteFetchConcurrency = 4
+
+// remoteUserAgent identifies the server to image hosts
+const remoteUserAgent = "Mozilla/5.0 (compatible; WebClipper/1.0; +https://github.com/jpoutrin/web-clipper)"
+
+// remote reports whether the image was sent with only its original URL, for
+// the server to download (images.fetch_remote)
+func (img ImagePayload) remote() bool {
+	return img.file == "" && img.Data == "" && img.OriginalURL != ""
+}
+
+// fetchRemoteImages downloads the payload's remote images into dir, within
+// the same size and format limits as uploaded ones. An image that can't be
+// fetched is dropped rather than failing the clip: the page links to its
+// original URL instead of media/<filename>.
+func fetchRemoteImages(ctx context.Context, cfg *config.Config, req *ClipPayload, dir string) {
+	client := safehttp.NewClient(time.Duration(cfg.Images.FetchTimeoutSeconds)*time.Second, cfg.Images.FetchAllowPrivate)
+
+	errs := make([]error, len(req.Images))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, remoteFetchConcurrency)
+	for i := range req.Images {
+		img := &req.Images[i]
+		if !img.remote() {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			img.file = filepath.Join(dir, fmt.Sprintf("remote-%d", i))
+			img.size, errs[i] = fetchImage(ctx, client, img.OriginalURL, img.file, cfg.Images.MaxSizeBytes)
+			if errs[i] == nil {
+				errs[i] = checkImageFormat(*img, cfg.Images.AllowedFormats)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The total limit applies in payload order, as for uploads
+	var total int64
+	kept := req.Images[:0]
+	for i, img := range req.Images {
+		err := errs[i]
+		size, _ := img.dataSize() // Checked by saveClip for the others
+		if err == nil && total+size > cfg.Images.MaxTotalBytes {
+			err = fmt.Errorf("total image size exceeds limit of %d bytes", cfg.Images.MaxTotalBytes)
+		}
+		if err != nil {
+			log.Printf("Dropped remote image %s: %v", img.Filename, err)
+			link := "media/" + sanitizeFilename(img.Filename)
+			req.Markdown = strings.ReplaceAll(req.Markdown, link, img.OriginalURL)
+			req.HTML = strings.ReplaceAll(req.HTML, link, img.OriginalURL)
+			continue
+		}
+		total += size
+		kept = append(kept, img)
+	}
+	req.Images = kept
+}
+
+// fetchImage downloads an image to path, flushed to disk, and returns its size
+func fetchImage(ctx context.Context, client *http.Client, imageURL, path string, limit int64) (int64, error) {
+	u, err := url.Parse(imageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return 0, fmt.Errorf("invalid URL: %s", imageURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", remoteUserAgent)
+	req.Header.Set("Accept", "image/*,application/pdf")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	size, err := int64(0), errImageTooLarge
+	if resp.ContentLength <= limit {
+		size, err = spoolPart(resp.Body, path, limit)
+	}
+	if errors.Is(err, errImageTooLarge) {
+		return size, fmt.Errorf("exceeds max size of %d bytes", limit)
+	}
+	return size, err
+}
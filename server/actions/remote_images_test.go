@@ -0,0 +1,78 @@
+package actions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_CreateClip_FetchesRemoteImages() {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hero.png":
+			w.Write([]byte(pngMagic + "hero"))
+		case "/page.png":
+			w.Write([]byte("<!DOCTYPE html><p>not an image</p>"))
+		case "/huge.png":
+			w.Write([]byte(pngMagic + strings.Repeat("x", 2048)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	kit := testkit.New(as.T())
+	kit.Config.Images.MaxSizeBytes = 1024
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+	payload := func(url string) ClipPayload {
+		images := []ImagePayload{}
+		markdown := ""
+		for _, name := range []string{"hero.png", "missing.png", "page.png", "huge.png"} {
+			images = append(images, ImagePayload{Filename: name, OriginalURL: srv.URL + "/" + name})
+			markdown += "![](media/" + name + ")\n"
+		}
+		return ClipPayload{Title: "Gallery", URL: url, Mode: "article", Markdown: markdown, Images: images}
+	}
+
+	// Off by default: an image without data is invalid
+	res := client.Post("/api/v1/clips", payload("https://example.com/off"))
+	as.Equal(http.StatusBadRequest, res.Code, res.Body.String())
+
+	kit.Config.Images.FetchRemote = true
+	kit.Config.Images.FetchAllowPrivate = true // The test server listens on loopback
+	var clip ClipResponse
+	res = client.Post("/api/v1/clips", payload("https://example.com/on"))
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+
+	folder := filepath.Join(kit.StorageRoot, filepath.Dir(clip.Path))
+	data, err := os.ReadFile(filepath.Join(folder, "media", "hero.png"))
+	as.NoError(err)
+	as.Equal(pngMagic+"hero", string(data))
+	media, err := os.ReadDir(filepath.Join(folder, "media"))
+	as.NoError(err)
+	as.Len(media, 1)
+
+	// Images that couldn't be fetched link to where they were found
+	page, err := os.ReadFile(filepath.Join(kit.StorageRoot, clip.Path))
+	as.NoError(err)
+	as.Contains(string(page), "![](media/hero.png)")
+	for _, name := range []string{"missing.png", "page.png", "huge.png"} {
+		as.Contains(string(page), "![]("+srv.URL+"/"+name+")")
+	}
+
+	// Internal addresses are refused
+	kit.Config.Images.FetchAllowPrivate = false
+	res = client.Post("/api/v1/clips", payload("https://example.com/private"))
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+	_, err = os.Stat(filepath.Join(kit.StorageRoot, filepath.Dir(clip.Path), "media", "hero.png"))
+	as.True(os.IsNotExist(err))
+	page, err = os.ReadFile(filepath.Join(kit.StorageRoot, clip.Path))
+	as.NoError(err)
+	as.Contains(string(page), "![]("+srv.URL+"/hero.png)")
+}
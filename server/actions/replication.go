@@ -0,0 +1,517 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"server/internal/config"
+	"server/internal/metrics"
+	"server/internal/s3"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Clip files are replicated to warm standbys (replication.directory,
+// typically on another disk, replication.s3 and those of
+// replication.targets) as clips are saved and every
+// replication.interval_seconds, so a failed disk loses the clips of the last
+// interval rather than everything since the last backup. Each pass copies
+// the files of the clips changed since the previous pass (every clip on the
+// first pass after a start), rsync-style: files the replica already has with
+// the same size and modification time are skipped (the same size on S3,
+// until the server copies the file itself). The replica is laid out as
+// <user id>/<path in the clip root>. Deletions are not replicated, so clips
+// removed by mistake can still be recovered from the standby. The
+// replication_reconcile task goes over every clip (reconcile.go).
+
+// errReplicationNotConfigured is returned when replication has nowhere to
+// copy to
+var errReplicationNotConfigured = errors.New("replication.directory or replication.s3 must be configured")
+
+// replicationOverlap is how far before the previous pass changed clips are
+// looked for again, for files written after their row was saved
+const replicationOverlap = time.Minute
+
+// replicatedFiles counts the files copied to the standby, and the copies
+// that failed
+var replicatedFiles = metrics.Default.NewCounterVec(
+	"webclipper_replication_files_total",
+	"Clip files copied to the standby, by result.",
+	"result",
+	"copied", "failed",
+)
+
+// The replication lag is how old the newest changes sure to be on the
+// standby are
+var _ = metrics.Default.NewGaugeFunc(
+	"webclipper_replication_lag_seconds",
+	"Age of the newest clip changes known to be on the standby (0 when replication is disabled).",
+	func() float64 { return currentReplicationStatus().LagSeconds },
+)
+
+// ReplicationResult summarizes a replication pass
+type ReplicationResult struct {
+	Clips   int      `json:"clips"`   // Clips changed since the previous pass
+	Copied  int      `json:"copied"`  // Files copied
+	Skipped int      `json:"skipped"` // Files the standby already had
+	Targets []string `json:"targets"` // Directory and/or s3://bucket/prefix
+}
+
+// ReplicationStatus is the state of replication since the server started
+type ReplicationStatus struct {
+	Enabled     bool               `json:"enabled"`
+	SyncedUntil *time.Time         `json:"synced_until,omitempty"` // Changes before this are on the standby
+	LagSeconds  float64            `json:"lag_seconds"`
+	LastRunAt   *time.Time         `json:"last_run_at,omitempty"`
+	LastError   string             `json:"last_error,omitempty"` // Of the last pass; it is retried on the next one
+	LastResult  *ReplicationResult `json:"last_result,omitempty"`
+}
+
+// replicationPass keeps passes from overlapping
+var replicationPass sync.Mutex
+
+// replication is the state kept between passes, for the config it was
+// built from
+var replication struct {
+	mu      sync.Mutex
+	cfg     *config.Config
+	targets []replicaTarget
+	synced  time.Time
+	lastRun time.Time
+	lastErr string
+	last    *ReplicationResult
+}
+
+// replicaTarget is where clip files are copied
+type replicaTarget interface {
+	// put copies the file at src to rel on the replica unless it has it
+	// already, and reports whether it was copied
+	put(ctx context.Context, src, rel string, info fs.FileInfo) (bool, error)
+	// list returns the files of the replica whose rel starts with prefix
+	list(ctx context.Context, prefix string) ([]string, error)
+	// get returns the content of the file at rel and its modification
+	// time, zero when the replica does not keep it
+	get(ctx context.Context, rel string) ([]byte, time.Time, error)
+	String() string
+}
+
+// dirReplica copies files under a directory
+type dirReplica struct {
+	root string
+}
+
+func (d *dirReplica) put(ctx context.Context, src, rel string, info fs.FileInfo) (bool, error) {
+	dst := filepath.Join(d.root, rel)
+	if existing, err := os.Stat(dst); err == nil && existing.Size() == info.Size() && existing.ModTime().Equal(info.ModTime()) {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return false, err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+	// Copied next to the file, then renamed, so the standby never has half a file
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".replica-*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	if err := os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp.Name(), dst)
+}
+
+func (d *dirReplica) list(ctx context.Context, prefix string) ([]string, error) {
+	var rels []string
+	dir := filepath.Join(d.root, filepath.FromSlash(path.Dir(prefix)))
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(d.root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if entry.Type().IsRegular() && strings.HasPrefix(rel, prefix) && !strings.HasPrefix(entry.Name(), ".replica-") {
+			rels = append(rels, rel)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return rels, err
+}
+
+func (d *dirReplica) get(ctx context.Context, rel string) ([]byte, time.Time, error) {
+	file := filepath.Join(d.root, filepath.FromSlash(rel))
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(file)
+	return data, info.ModTime(), err
+}
+
+func (d *dirReplica) String() string {
+	return d.root
+}
+
+// s3Replica copies files to a bucket
+type s3Replica struct {
+	bucket     *s3.Client
+	bucketName string
+	prefix     string
+	copied     map[string]replicaFile // Listed on the first put, then updated as files are copied
+}
+
+// replicaFile is what a replica knows of a copied file; modTime is zero for
+// files copied before the server started
+type replicaFile struct {
+	size    int64
+	modTime time.Time
+}
+
+func (r *s3Replica) put(ctx context.Context, src, rel string, info fs.FileInfo) (bool, error) {
+	if r.copied == nil {
+		sizes, err := r.bucket.Sizes(ctx, r.prefix)
+		if err != nil {
+			return false, err
+		}
+		r.copied = make(map[string]replicaFile, len(sizes))
+		for key, size := range sizes {
+			r.copied[key] = replicaFile{size: size}
+		}
+	}
+
+	key := r.prefix + filepath.ToSlash(rel)
+	if existing, ok := r.copied[key]; ok && existing.size == info.Size() &&
+		(existing.modTime.IsZero() || existing.modTime.Equal(info.ModTime())) {
+		return false, nil
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return false, err
+	}
+	if err := r.bucket.Put(ctx, key, data, mime.TypeByExtension(filepath.Ext(src))); err != nil {
+		return false, err
+	}
+	r.copied[key] = replicaFile{size: info.Size(), modTime: info.ModTime()}
+	return true, nil
+}
+
+func (r *s3Replica) list(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := r.bucket.List(ctx, r.prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	rels := make([]string, len(keys))
+	for i, key := range keys {
+		rels[i] = strings.TrimPrefix(key, r.prefix)
+	}
+	return rels, nil
+}
+
+func (r *s3Replica) get(ctx context.Context, rel string) ([]byte, time.Time, error) {
+	data, err := r.bucket.Get(ctx, r.prefix+rel)
+	return data, time.Time{}, err
+}
+
+func (r *s3Replica) String() string {
+	return "s3://" + path.Join(r.bucketName, r.prefix)
+}
+
+// replicaTargets returns the configured replicas and the time changes are
+// replicated until. The replicas are created once per config, so what the S3
+// replica knows of the bucket is kept between passes.
+func replicaTargets() ([]replicaTarget, time.Time, error) {
+	replication.mu.Lock()
+	defer replication.mu.Unlock()
+	if replication.cfg != GetConfig() {
+		replication.cfg = GetConfig()
+		replication.targets = nil
+		replication.synced = time.Time{}
+		replication.lastRun = time.Time{}
+		replication.lastErr = ""
+		replication.last = nil
+	}
+	if replication.targets != nil {
+		return replication.targets, replication.synced, nil
+	}
+
+	cfg := replication.cfg.Replication
+	var targets []replicaTarget
+	for _, replica := range append([]config.ReplicaConfig{{Directory: cfg.Directory, S3: cfg.S3}}, cfg.Targets...) {
+		if replica.Directory != "" {
+			targets = append(targets, &dirReplica{root: replica.Directory})
+		}
+		if bucket := s3.New(replica.S3); bucket.Configured() {
+			prefix := replica.S3.Prefix
+			if prefix != "" && prefix[len(prefix)-1] != '/' {
+				prefix += "/"
+			}
+			targets = append(targets, &s3Replica{bucket: bucket, bucketName: replica.S3.Bucket, prefix: prefix})
+		}
+	}
+	if len(targets) == 0 {
+		return nil, time.Time{}, errReplicationNotConfigured
+	}
+	replication.targets = targets
+	return targets, replication.synced, nil
+}
+
+// ReplicateClips copies the files of the clips changed since the previous
+// pass to the standby. The changes are only marked replicated when every
+// file was copied, so a failed pass is retried whole.
+func ReplicateClips(ctx context.Context, db *pop.Connection) (*ReplicationResult, error) {
+	replicationPass.Lock()
+	defer replicationPass.Unlock()
+
+	start := time.Now().UTC()
+	result, err := replicateClips(ctx, db)
+
+	replication.mu.Lock()
+	defer replication.mu.Unlock()
+	replication.lastRun = start
+	replication.lastErr = ""
+	if err != nil {
+		replication.lastErr = err.Error()
+		return nil, err
+	}
+	replication.synced = start
+	replication.last = result
+	return result, nil
+}
+
+func replicateClips(ctx context.Context, db *pop.Connection) (*ReplicationResult, error) {
+	targets, synced, err := replicaTargets()
+	if err != nil {
+		return nil, err
+	}
+
+	clips := models.Clips{}
+	q := db.Order("updated_at ASC")
+	if !synced.IsZero() {
+		q = q.Where("updated_at > ?", synced.Add(-replicationOverlap))
+	}
+	if err := q.All(&clips); err != nil {
+		return nil, err
+	}
+
+	cfg := GetConfig()
+	result := &ReplicationResult{Clips: len(clips)}
+	for _, t := range targets {
+		result.Targets = append(result.Targets, t.String())
+	}
+	users := map[uuid.UUID]*models.User{}
+	for i := range clips {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		clip := &clips[i]
+		user, ok := users[clip.UserID]
+		if !ok {
+			user = &models.User{}
+			if err := db.Find(user, clip.UserID); err != nil {
+				return nil, fmt.Errorf("failed to find the owner of clip %s: %w", clip.ID, err)
+			}
+			users[clip.UserID] = user
+		}
+
+		copied, skipped, err := replicateClip(ctx, targets, clipRoot(cfg, user, clip), user, clip)
+		if err != nil {
+			return nil, err
+		}
+		result.Copied += copied
+		result.Skipped += skipped
+	}
+	return result, nil
+}
+
+// replicateClip copies the files of a clip to the replicas that don't have
+// them, and returns how many were copied and skipped
+func replicateClip(ctx context.Context, targets []replicaTarget, root string, user *models.User, clip *models.Clip) (copied, skipped int, err error) {
+	files, err := clipFiles(root, clip)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if os.IsNotExist(err) {
+			continue // Removed since it was listed
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return 0, 0, err
+		}
+		rel = filepath.Join(user.ID.String(), rel)
+		for _, t := range targets {
+			done, err := t.put(ctx, file, rel, info)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				replicatedFiles.Inc("failed")
+				return 0, 0, fmt.Errorf("failed to copy %s to %s: %w", rel, t, err)
+			}
+			if done {
+				replicatedFiles.Inc("copied")
+				copied++
+			} else {
+				skipped++
+			}
+		}
+	}
+	return copied, skipped, nil
+}
+
+// clipFiles returns the regular files of a clip: those of its folder (in the
+// trash when it is trashed), or a note and its attachments
+func clipFiles(root string, clip *models.Clip) ([]string, error) {
+	p := filepath.Join(root, clip.Path)
+	if clip.DeletedAt.Valid {
+		p = filepath.Join(root, trashDirName, clip.Path)
+	}
+
+	var files []string
+	err := filepath.WalkDir(p, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	// A note's attachments stay in the vault while it is in the trash
+	if clip.IsNote() {
+		attachments, err := noteAttachmentPaths(filepath.Join(root, clip.Path), clip.ID)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, attachments...)
+	}
+	return files, nil
+}
+
+// currentReplicationStatus returns the state of replication
+func currentReplicationStatus() ReplicationStatus {
+	cfg := GetConfig()
+	status := ReplicationStatus{Enabled: cfg != nil && cfg.Replication.Enabled}
+	if !status.Enabled {
+		return status
+	}
+
+	replication.mu.Lock()
+	defer replication.mu.Unlock()
+	synced := metricsStarted // Nothing is known to be replicated before the first pass
+	if !replication.synced.IsZero() {
+		synced = replication.synced
+		status.SyncedUntil = &replication.synced
+	}
+	status.LagSeconds = time.Since(synced).Seconds()
+	if !replication.lastRun.IsZero() {
+		status.LastRunAt = &replication.lastRun
+	}
+	status.LastError = replication.lastErr
+	status.LastResult = replication.last
+	return status
+}
+
+// StartReplication replicates clip files every replication.interval_seconds
+// until the context is cancelled
+func StartReplication(ctx context.Context) {
+	if GetConfig() == nil || !GetConfig().Replication.Enabled {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(GetConfig().Replication.IntervalSeconds) * time.Second)
+		defer ticker.Stop()
+		for {
+			if result, err := ReplicateClips(ctx, models.DB); err != nil {
+				log.Printf("replication: pass failed: %v", err)
+			} else if result.Copied > 0 {
+				log.Printf("replication: copied %d files of %d clips to %v", result.Copied, result.Clips, result.Targets)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// replicateAfterCommit replicates a saved clip once the request's
+// transaction is committed, without waiting for the next pass
+func replicateAfterCommit(c buffalo.Context) {
+	if !GetConfig().Replication.Enabled {
+		return
+	}
+	db := appDB(c)
+	onCommit(c, func() {
+		goBackground(func() {
+			if _, err := ReplicateClips(context.Background(), db); err != nil {
+				log.Printf("replication: pass failed: %v", err)
+			}
+		})
+	})
+}
+
+// adminReplicationStatus returns the state of replication
+func adminReplicationStatus(c buffalo.Context) error {
+	return c.Render(http.StatusOK, r.JSON(currentReplicationStatus()))
+}
+
+// adminReplicate runs a replication pass now
+func adminReplicate(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	result, err := ReplicateClips(c, tx)
+	if errors.Is(err, errReplicationNotConfigured) {
+		return c.Error(http.StatusConflict, err)
+	}
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(result))
+}
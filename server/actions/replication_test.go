@@ -0,0 +1,123 @@
+package actions
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/config"
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_ReplicateClips() {
+	kit := testkit.New(as.T())
+	standby := filepath.Join(as.T().TempDir(), "standby")
+	user := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{user.Email}
+	kit.Config.Replication.Enabled = true
+	client := kit.Client(newKitApp(kit), user)
+
+	res := client.Post("/api/v1/admin/replication", nil)
+	as.Equal(http.StatusConflict, res.Code)
+	kit.Config.Replication.Directory = standby
+
+	clip := kit.CreateClip(user, testkit.WithMedia("pic.png", []byte("png")))
+	kit.CreateClip(user)
+
+	replicate := func() ReplicationResult {
+		var result ReplicationResult
+		res := client.Post("/api/v1/admin/replication", nil)
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		res.JSON(&result)
+		return result
+	}
+	result := replicate()
+	as.Equal(2, result.Clips)
+	as.Equal(3, result.Copied) // Two markdown files and a picture
+	as.Equal([]string{standby}, result.Targets)
+
+	media := filepath.Join(standby, user.ID.String(), clip.Path, "media", "pic.png")
+	data, err := os.ReadFile(media)
+	as.NoError(err)
+	as.Equal("png", string(data))
+
+	// Unchanged files are skipped
+	result = replicate()
+	as.Zero(result.Copied)
+
+	// Changed files are copied again, and deletions are kept on the standby
+	as.NoError(os.WriteFile(filepath.Join(kit.StorageRoot, clip.Path, "media", "pic.png"), []byte("a bigger png"), 0644))
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/clips/"+clip.ID.String()).Code)
+	result = replicate()
+	as.Equal(2, result.Copied) // The trashed markdown file and picture
+	_, err = os.Stat(filepath.Join(standby, user.ID.String(), trashDirName, clip.Path, "media", "pic.png"))
+	as.NoError(err)
+	_, err = os.Stat(media)
+	as.NoError(err)
+
+	var status ReplicationStatus
+	res = client.Get("/api/v1/admin/replication")
+	as.Equal(http.StatusOK, res.Code)
+	res.JSON(&status)
+	as.True(status.Enabled)
+	as.NotNil(status.SyncedUntil)
+	as.Less(status.LagSeconds, 60.0)
+	as.Empty(status.LastError)
+	as.Equal(2, status.LastResult.Copied)
+}
+
+func (as *ActionSuite) Test_ReconcileReplicas() {
+	kit := testkit.New(as.T())
+	disks := []string{filepath.Join(as.T().TempDir(), "disk1"), filepath.Join(as.T().TempDir(), "disk2")}
+	user := kit.CreateUser()
+	kit.Config.Replication.Enabled = true
+	kit.Config.Replication.Targets = []config.ReplicaConfig{{Directory: disks[0]}, {Directory: disks[1]}}
+	client := kit.Client(newKitApp(kit), user)
+
+	// Saved clips are replicated without waiting for the next pass
+	var created ClipResponse
+	res := client.Post("/api/v1/clips", ClipPayload{
+		Title:    "Replicated",
+		URL:      "https://example.com/replicated",
+		Mode:     "article",
+		Markdown: "On two disks",
+	})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&created)
+	background.Wait()
+	replicated := func(disk string) []string {
+		var files []string
+		filepath.WalkDir(filepath.Join(disk, user.ID.String()), func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				rel, _ := filepath.Rel(disk, p)
+				files = append(files, rel)
+			}
+			return nil
+		})
+		return files
+	}
+	files := replicated(disks[0])
+	as.NotEmpty(files)
+	as.Equal(files, replicated(disks[1]))
+
+	// A replaced disk gets every file again, and a clip gone from the
+	// primary storage is restored
+	as.NoError(os.RemoveAll(disks[1]))
+	lost := kit.CreateClip(user)
+	as.NoError(os.RemoveAll(filepath.Join(kit.StorageRoot, filepath.Dir(created.Path))))
+	as.NoError(os.RemoveAll(filepath.Join(kit.StorageRoot, lost.Path)))
+
+	result, err := ReconcileReplicas(context.Background(), kit.DB)
+	as.NoError(err)
+	as.Equal(2, result.Clips)
+	as.Equal(1, result.Restored)
+	as.Equal([]string{lost.Path}, result.Lost)
+	as.Equal(disks, result.Targets)
+	as.Equal(len(files), result.Copied) // To the replaced disk
+	as.Equal(files, replicated(disks[1]))
+	data, err := readClipFile(filepath.Join(kit.StorageRoot, created.Path))
+	as.NoError(err)
+	as.Contains(string(data), "On two disks")
+}
@@ -0,0 +1,315 @@
+package actions
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Retention rules expire clips automatically: on the retention task's
+// schedule (hourly by default), the clips saved more than a rule's
+// max_age_days ago (all of the user's, or only those of its mode and/or tag)
+// are moved to the trash, like a delete, and purged with it after
+// storage.trash_retention_days. `clips expire --dry-run` previews them.
+
+// RetentionRulePayload is the request body for creating or updating a
+// retention rule
+type RetentionRulePayload struct {
+	Mode       string `json:"mode,omitempty"` // Only clips of this mode
+	Tag        string `json:"tag,omitempty"`  // Only clips with this tag
+	MaxAgeDays int    `json:"max_age_days"`
+}
+
+// RetentionRuleResponse is the API representation of a retention rule
+type RetentionRuleResponse struct {
+	ID         string    `json:"id"`
+	Mode       string    `json:"mode,omitempty"`
+	Tag        string    `json:"tag,omitempty"`
+	MaxAgeDays int       `json:"max_age_days"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ExpiredClip is a clip moved to the trash by a retention rule
+type ExpiredClip struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Title     string    `json:"title"`
+	Path      string    `json:"path"`
+	Mode      string    `json:"mode"`
+	CreatedAt time.Time `json:"created_at"`
+	Rule      string    `json:"rule"` // The first rule expiring it, e.g. "mode:bookmark 180d"
+}
+
+// listRetentionRules returns the user's retention rules
+func listRetentionRules(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	rules, err := models.FindRetentionRulesByUserID(tx, userID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	resp := make([]RetentionRuleResponse, len(rules))
+	for i := range rules {
+		resp[i] = retentionRuleToResponse(&rules[i])
+	}
+
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"rules": resp,
+	}))
+}
+
+// createRetentionRule adds a rule expiring the user's clips
+func createRetentionRule(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	var req RetentionRulePayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	rule := &models.RetentionRule{
+		ID:     uuid.Must(uuid.NewV4()),
+		UserID: userID,
+	}
+	if err := applyRetentionRulePayload(rule, req); err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+
+	verrs, err := tx.ValidateAndCreate(rule)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	return c.Render(http.StatusCreated, r.JSON(retentionRuleToResponse(rule)))
+}
+
+// updateRetentionRule replaces a retention rule's match and age
+func updateRetentionRule(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	ruleID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid rule ID"))
+	}
+
+	var req RetentionRulePayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	rule, err := models.FindRetentionRuleByIDAndUser(tx, ruleID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("rule not found"))
+	}
+	if err := applyRetentionRulePayload(rule, req); err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+
+	verrs, err := tx.ValidateAndUpdate(rule)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	return c.Render(http.StatusOK, r.JSON(retentionRuleToResponse(rule)))
+}
+
+// deleteRetentionRule removes a retention rule; clips it expired stay in
+// the trash
+func deleteRetentionRule(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	ruleID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid rule ID"))
+	}
+
+	rule, err := models.FindRetentionRuleByIDAndUser(tx, ruleID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("rule not found"))
+	}
+
+	if err := tx.Destroy(rule); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	return c.Render(http.StatusNoContent, nil)
+}
+
+// applyRetentionRulePayload validates the rule's match and copies it onto
+// the model
+func applyRetentionRulePayload(rule *models.RetentionRule, req RetentionRulePayload) error {
+	rule.Mode = nulls.String{}
+	if req.Mode != "" {
+		if !isClipMode(req.Mode) {
+			return fmt.Errorf("invalid mode %q", req.Mode)
+		}
+		rule.Mode = nulls.NewString(req.Mode)
+	}
+	rule.Tag = nulls.String{}
+	if tags := models.CleanTagNames([]string{req.Tag}); len(tags) > 0 {
+		rule.Tag = nulls.NewString(tags[0])
+	}
+	rule.MaxAgeDays = req.MaxAgeDays
+	return nil
+}
+
+// retentionRuleToResponse converts a retention rule model to its API
+// representation
+func retentionRuleToResponse(rule *models.RetentionRule) RetentionRuleResponse {
+	return RetentionRuleResponse{
+		ID:         rule.ID.String(),
+		Mode:       rule.Mode.String,
+		Tag:        rule.Tag.String,
+		MaxAgeDays: rule.MaxAgeDays,
+		CreatedAt:  rule.CreatedAt,
+		UpdatedAt:  rule.UpdatedAt,
+	}
+}
+
+// describeRetentionRule summarizes a rule, e.g. "mode:bookmark tag:news 180d"
+func describeRetentionRule(rule *models.RetentionRule) string {
+	var parts []string
+	if rule.Mode.Valid {
+		parts = append(parts, "mode:"+rule.Mode.String)
+	}
+	if rule.Tag.Valid {
+		parts = append(parts, "tag:"+rule.Tag.String)
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "all")
+	}
+	return fmt.Sprintf("%s %dd", strings.Join(parts, " "), rule.MaxAgeDays)
+}
+
+// ExpireClips moves the clips expired by retention rules to the trash, for
+// one user or every user without an email, and returns them. With dryRun it
+// only returns the clips it would expire.
+func ExpireClips(db *pop.Connection, email string, dryRun bool) ([]ExpiredClip, error) {
+	rules := models.RetentionRules{}
+	q := db.Order("created_at ASC, id ASC")
+	if email != "" {
+		user := &models.User{}
+		if err := db.Where("email = ?", email).First(user); err != nil {
+			return nil, fmt.Errorf("user not found: %s", email)
+		}
+		q = q.Where("user_id = ?", user.ID)
+	}
+	if err := q.All(&rules); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expired := []ExpiredClip{}
+	seen := map[uuid.UUID]bool{}
+	users := map[uuid.UUID]*models.User{}
+	for i := range rules {
+		rule := &rules[i]
+		user, ok := users[rule.UserID]
+		if !ok {
+			user = &models.User{}
+			if err := db.Find(user, rule.UserID); err != nil {
+				log.Printf("retention: user %s not found for rule %s: %v", rule.UserID, rule.ID, err)
+				continue
+			}
+			users[rule.UserID] = user
+		}
+
+		clips, err := models.FindExpiredClips(db, rule, now)
+		if err != nil {
+			return nil, err
+		}
+		for j := range clips {
+			clip := &clips[j]
+			if seen[clip.ID] {
+				continue
+			}
+			seen[clip.ID] = true
+
+			if !dryRun {
+				if err := moveToTrash(clipRoot(GetConfig(), user, clip), clip.Path); err != nil {
+					log.Printf("retention: failed to move clip %s to the trash: %v", clip.ID, err)
+					continue
+				}
+				clip.DeletedAt = nulls.NewTime(now)
+				if err := db.Update(clip); err != nil {
+					return nil, err
+				}
+			}
+			expired = append(expired, ExpiredClip{
+				ID:        clip.ID.String(),
+				Email:     user.Email,
+				Title:     clip.Title,
+				Path:      clip.Path,
+				Mode:      clip.Mode,
+				CreatedAt: clip.CreatedAt,
+				Rule:      describeRetentionRule(rule),
+			})
+		}
+	}
+	return expired, nil
+}
+
+// adminExpireClips runs the retention rules now, for one user with ?email=
+func adminExpireClips(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	dryRun := isDryRun(c)
+
+	email := c.Param("email")
+	if email != "" {
+		if n, err := tx.Where("email = ?", email).Count(&models.User{}); err != nil || n == 0 {
+			return c.Error(http.StatusNotFound, fmt.Errorf("user not found: %s", email))
+		}
+	}
+
+	expired, err := ExpireClips(tx, email, dryRun)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if !dryRun {
+		if err := recordAudit(c, tx, auditClipsExpire, "email", email, "clips", len(expired)); err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+	}
+
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"dry_run": dryRun,
+		"expired": expired,
+	}))
+}
@@ -0,0 +1,118 @@
+package actions
+
+import (
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gofrs/uuid"
+)
+
+func (as *ActionSuite) Test_ListRetentionRules_Unauthorized() {
+	res := as.JSON("/api/v1/retention-rules").Get()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_RetentionRules() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+
+	res := client.Post("/api/v1/retention-rules", RetentionRulePayload{Mode: "nope", MaxAgeDays: 30})
+	as.Equal(http.StatusBadRequest, res.Code)
+	res = client.Post("/api/v1/retention-rules", RetentionRulePayload{Mode: "bookmark"})
+	as.Equal(http.StatusUnprocessableEntity, res.Code)
+
+	var rule RetentionRuleResponse
+	res = client.Post("/api/v1/retention-rules", RetentionRulePayload{Mode: "bookmark", MaxAgeDays: 180})
+	as.Equal(http.StatusCreated, res.Code, res.Body.String())
+	res.JSON(&rule)
+	as.Equal("bookmark", rule.Mode)
+
+	res = client.Put("/api/v1/retention-rules/"+rule.ID, RetentionRulePayload{Tag: " news ", MaxAgeDays: 30})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	rule = RetentionRuleResponse{}
+	res.JSON(&rule)
+	as.Empty(rule.Mode)
+	as.Equal("news", rule.Tag)
+	as.Equal(30, rule.MaxAgeDays)
+
+	// Other users' rules are not found
+	other := kit.Client(newKitApp(kit), kit.CreateUser())
+	as.Equal(http.StatusNotFound, other.Delete("/api/v1/retention-rules/"+rule.ID).Code)
+
+	var list struct {
+		Rules []RetentionRuleResponse `json:"rules"`
+	}
+	res = client.Get("/api/v1/retention-rules")
+	as.Equal(http.StatusOK, res.Code)
+	res.JSON(&list)
+	as.Len(list.Rules, 1)
+
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/retention-rules/"+rule.ID).Code)
+	rules, err := models.FindRetentionRulesByUserID(kit.DB, user.ID)
+	as.NoError(err)
+	as.Empty(rules)
+}
+
+func (as *ActionSuite) Test_ExpireClips() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{user.Email}
+	client := kit.Client(newKitApp(kit), user)
+
+	aged := func(days int, mode string) testkit.ClipOption {
+		return testkit.WithClip(func(c *models.Clip) {
+			c.Mode = mode
+			c.CreatedAt = time.Now().AddDate(0, 0, -days)
+		})
+	}
+	oldBookmark := kit.CreateClip(user, aged(200, "bookmark"))
+	kit.CreateClip(user, aged(10, "bookmark"))
+	kit.CreateClip(user, aged(200, "article"))
+	oldNews := kit.CreateClip(user, aged(40, "article"), testkit.WithTags("news"))
+	kit.CreateClip(user, aged(400, "bookmark"))
+	kit.CreateClip(kit.CreateUser(), aged(400, "bookmark")) // Not the rules' owner
+
+	for _, rule := range []*models.RetentionRule{
+		{UserID: user.ID, Mode: nulls.NewString("bookmark"), MaxAgeDays: 180},
+		{UserID: user.ID, Tag: nulls.NewString("news"), MaxAgeDays: 30},
+	} {
+		rule.ID = uuid.Must(uuid.NewV4())
+		as.NoError(kit.DB.Create(rule))
+	}
+
+	expire := func(dryRun string) []ExpiredClip {
+		var resp struct {
+			DryRun  bool          `json:"dry_run"`
+			Expired []ExpiredClip `json:"expired"`
+		}
+		res := client.Post("/api/v1/admin/retention?email="+user.Email+"&dry_run="+dryRun, nil)
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		res.JSON(&resp)
+		return resp.Expired
+	}
+
+	expired := expire("true")
+	as.Len(expired, 3)
+	as.Equal("mode:bookmark 180d", expired[0].Rule)
+	as.DirExists(filepath.Join(kit.StorageRoot, oldBookmark.Path))
+
+	expired = expire("false")
+	as.Len(expired, 3)
+	as.NoDirExists(filepath.Join(kit.StorageRoot, oldBookmark.Path))
+	as.DirExists(filepath.Join(kit.StorageRoot, trashDirName, oldNews.Path))
+	reloaded := &models.Clip{}
+	as.NoError(kit.DB.Find(reloaded, oldNews.ID))
+	as.True(reloaded.DeletedAt.Valid)
+
+	// Trashed clips are not expired again
+	as.Empty(expire("false"))
+
+	res := client.Post("/api/v1/admin/retention?email=nobody@example.com", nil)
+	as.Equal(http.StatusNotFound, res.Code)
+}
@@ -0,0 +1,192 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"server/internal/config"
+	"server/internal/scheduler"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+)
+
+// staleTokenAge is how long expired and revoked tokens are kept, listed in
+// the user's tokens, before token_cleanup deletes them
+const staleTokenAge = 30 * 24 * time.Hour
+
+// maintenanceTask is a task run on its scheduler.tasks schedule
+type maintenanceTask struct {
+	name string
+	run  func(ctx context.Context, db *pop.Connection) (string, error)
+}
+
+// maintenanceTasks are the tasks of the scheduler, by config name
+var maintenanceTasks = []maintenanceTask{
+	{"trash_purge", func(ctx context.Context, db *pop.Connection) (string, error) {
+		purged, err := PurgeExpiredTrash(db, false)
+		return countSummary(len(purged), "permanently deleted %d clips"), err
+	}},
+	{"retention", func(ctx context.Context, db *pop.Connection) (string, error) {
+		expired, err := ExpireClips(db, "", false)
+		return countSummary(len(expired), "moved %d expired clips to the trash"), err
+	}},
+	{"upload_purge", func(ctx context.Context, db *pop.Connection) (string, error) {
+		purged, err := PurgeExpiredUploads(db)
+		return countSummary(purged, "deleted %d expired uploads"), err
+	}},
+	{"janitor", func(ctx context.Context, db *pop.Connection) (string, error) {
+		cleaned, err := CleanClipFolders(db, false)
+		for _, leftover := range cleaned {
+			log.Printf("janitor: removed %s (%s)", leftover.Path, leftover.Reason)
+		}
+		return countSummary(len(cleaned), "removed %d leftovers"), err
+	}},
+	{"token_cleanup", func(ctx context.Context, db *pop.Connection) (string, error) {
+		deleted, err := models.DeleteStaleTokens(db, time.Now().Add(-staleTokenAge))
+		return countSummary(deleted, "deleted %d expired or revoked tokens"), err
+	}},
+	{"usage_refresh", func(ctx context.Context, db *pop.Connection) (string, error) {
+		usages, err := DiskUsages(db, "", true)
+		return countSummary(len(usages), "measured the storage of %d users"), err
+	}},
+	{"webhook_retry", func(ctx context.Context, db *pop.Connection) (string, error) {
+		retried, err := RetryWebhookDeliveries(db)
+		return countSummary(retried, "sent %d failed deliveries again"), err
+	}},
+	{"outbox_prune", func(ctx context.Context, db *pop.Connection) (string, error) {
+		cutoff := time.Now().AddDate(0, 0, -GetConfig().Webhooks.RetentionDays)
+		events, err := models.DeleteDispatchedEvents(db, cutoff)
+		if err != nil {
+			return "", err
+		}
+		deliveries, err := models.DeleteFinishedWebhookDeliveries(db, cutoff)
+		if err != nil || events+deliveries == 0 {
+			return "", err
+		}
+		return fmt.Sprintf("deleted %d events and %d webhook deliveries", events, deliveries), nil
+	}},
+	{"git_sync", func(ctx context.Context, db *pop.Connection) (string, error) {
+		if !GetConfig().GitSync.Enabled {
+			return "", nil
+		}
+		result, err := SyncClipsToGit(ctx, db)
+		if err != nil {
+			return "", err
+		}
+		return countSummary(result.Commits, "made %d commits"), nil
+	}},
+	{"replication_reconcile", func(ctx context.Context, db *pop.Connection) (string, error) {
+		if !GetConfig().Replication.Enabled {
+			return "", nil
+		}
+		result, err := ReconcileReplicas(ctx, db)
+		if err != nil {
+			return "", err
+		}
+		if result.Restored == 0 && result.Copied == 0 && len(result.Lost) == 0 {
+			return "", nil
+		}
+		return fmt.Sprintf("restored %d clips, copied %d files, %d clips lost", result.Restored, result.Copied, len(result.Lost)), nil
+	}},
+}
+
+// countSummary formats a task's result, empty when it did nothing
+func countSummary(n int, format string) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf(format, n)
+}
+
+// tasks is the scheduler of the maintenance tasks, for the config it was
+// built from
+var tasks struct {
+	mu  sync.Mutex
+	cfg *config.Config
+	s   *scheduler.Scheduler
+	err error
+}
+
+// taskScheduler returns the scheduler of the current config's tasks, which
+// run on db unless run from a request (its transaction is used then)
+func taskScheduler(db *pop.Connection) (*scheduler.Scheduler, error) {
+	tasks.mu.Lock()
+	defer tasks.mu.Unlock()
+	if tasks.s != nil && tasks.cfg == GetConfig() {
+		return tasks.s, tasks.err
+	}
+
+	s := scheduler.New()
+	var err error
+	for _, task := range maintenanceTasks {
+		spec := GetConfig().Scheduler.Tasks[task.name]
+		if spec == "" || spec == "off" {
+			continue
+		}
+		run := task.run
+		if addErr := s.Add(task.name, spec, func(ctx context.Context) (string, error) {
+			if tx, ok := ctx.Value("tx").(*pop.Connection); ok {
+				return run(ctx, tx)
+			}
+			return run(ctx, db)
+		}); addErr != nil && err == nil {
+			err = fmt.Errorf("scheduler.tasks: %w", addErr)
+		}
+	}
+	tasks.cfg, tasks.s, tasks.err = GetConfig(), s, err
+	return s, err
+}
+
+// StartScheduler runs the maintenance tasks on their schedules until the
+// context is cancelled. A task with an invalid schedule is left out and
+// logged; the others still run.
+func StartScheduler(ctx context.Context) {
+	if GetConfig() == nil {
+		return
+	}
+
+	s, err := taskScheduler(models.DB)
+	if err != nil {
+		log.Printf("scheduler: %v", err)
+	}
+	go s.Run(ctx)
+}
+
+// adminSchedulerStatus lists the maintenance tasks with their last and next
+// runs
+func adminSchedulerStatus(c buffalo.Context) error {
+	s, err := taskScheduler(appDB(c))
+	resp := map[string]interface{}{
+		"tasks": s.Statuses(),
+	}
+	if err != nil {
+		resp["error"] = err.Error() // The tasks with a valid schedule still run
+	}
+	return c.Render(http.StatusOK, r.JSON(resp))
+}
+
+// adminRunTask runs a maintenance task now and returns its status
+func adminRunTask(c buffalo.Context) error {
+	s, _ := taskScheduler(appDB(c))
+
+	name := c.Param("task")
+	known := false
+	for _, status := range s.Statuses() {
+		known = known || status.Name == name
+	}
+	if !known {
+		return c.Error(http.StatusNotFound, fmt.Errorf("unknown or disabled task: %s", name))
+	}
+
+	status, err := s.RunNow(c, name)
+	if err != nil {
+		return c.Error(http.StatusConflict, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(status))
+}
@@ -0,0 +1,154 @@
+package actions
+
+import (
+	"net/http"
+	"time"
+
+	"server/internal/scheduler"
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gofrs/uuid"
+)
+
+func (as *ActionSuite) Test_SchedulerTasks() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{user.Email}
+	kit.Config.Scheduler.Tasks["usage_refresh"] = "off"
+	client := kit.Client(newKitApp(kit), user)
+
+	var list struct {
+		Tasks []scheduler.Status `json:"tasks"`
+		Error string             `json:"error"`
+	}
+	res := client.Get("/api/v1/admin/scheduler")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&list)
+	as.Len(list.Tasks, len(maintenanceTasks)-1)
+	as.Empty(list.Error)
+	for _, task := range list.Tasks {
+		as.NotEqual("usage_refresh", task.Name)
+		as.True(task.NextRun.After(time.Now()), task.Name)
+	}
+	as.Equal(http.StatusNotFound, client.Post("/api/v1/admin/scheduler/usage_refresh", nil).Code)
+
+	// token_cleanup deletes tokens expired or revoked a while ago
+	_, token, err := models.GenerateToken(user.ID, "Old", nulls.NewTime(time.Now().Add(-2*staleTokenAge)))
+	as.NoError(err)
+	as.NoError(kit.DB.Create(token))
+	_, recent, err := models.GenerateToken(user.ID, "Recent", nulls.NewTime(time.Now().Add(-time.Hour)))
+	as.NoError(err)
+	as.NoError(kit.DB.Create(recent))
+
+	var status scheduler.Status
+	res = client.Post("/api/v1/admin/scheduler/token_cleanup", nil)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&status)
+	as.Equal("deleted 1 expired or revoked tokens", status.LastResult)
+	as.Equal(1, status.Runs)
+	exists, err := kit.DB.Where("id = ?", token.ID).Exists(&models.ApiToken{})
+	as.NoError(err)
+	as.False(exists)
+	exists, err = kit.DB.Where("id = ?", recent.ID).Exists(&models.ApiToken{})
+	as.NoError(err)
+	as.True(exists)
+}
+
+func (as *ActionSuite) Test_SchedulerRetriesFailedWebhooks() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{user.Email}
+	kit.Config.Webhooks.AllowPrivate = true // The receiver is an httptest server
+	client := kit.Client(newKitApp(kit), user)
+	rec, url := newWebhookReceiver(as)
+	rec.setStatus(http.StatusServiceUnavailable)
+
+	var hook WebhookResponse
+	client.Post("/api/v1/webhooks", WebhookPayload{URL: url, Events: []string{models.EventClipCreated}}).JSON(&hook)
+	failed := &models.WebhookDelivery{
+		ID:        uuid.Must(uuid.NewV4()),
+		WebhookID: uuid.FromStringOrNil(hook.ID),
+		Event:     models.EventClipCreated,
+		Payload:   `{"event":"clip.created"}`,
+		Status:    models.WebhookDeliveryFailed,
+	}
+	as.NoError(kit.DB.Create(failed))
+
+	retry := func() string {
+		var status scheduler.Status
+		res := client.Post("/api/v1/admin/scheduler/webhook_retry", nil)
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		res.JSON(&status)
+		as.Empty(status.LastError)
+		return status.LastResult
+	}
+
+	// Retried until the event had webhooks.max_attempts deliveries
+	as.Equal("sent 1 failed deliveries again", retry())
+	as.Equal("sent 1 failed deliveries again", retry())
+	as.Empty(retry())
+	count, err := kit.DB.Where("webhook_id = ?", hook.ID).Count(&models.WebhookDelivery{})
+	as.NoError(err)
+	as.Equal(kit.Config.Webhooks.MaxAttempts, count)
+
+	// A successful retry ends the chain
+	as.NoError(kit.DB.RawQuery("DELETE FROM webhook_deliveries WHERE replay_of IS NOT NULL").Exec())
+	rec.setStatus(http.StatusOK)
+	as.Equal("sent 1 failed deliveries again", retry())
+	as.Empty(retry())
+	succeeded, err := kit.DB.Where("webhook_id = ? AND status = ?", hook.ID, models.WebhookDeliverySucceeded).Count(&models.WebhookDelivery{})
+	as.NoError(err)
+	as.Equal(1, succeeded)
+}
+
+func (as *ActionSuite) Test_SchedulerPrunesOutbox() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{user.Email}
+	client := kit.Client(newKitApp(kit), user)
+	old := time.Now().AddDate(0, 0, -kit.Config.Webhooks.RetentionDays-1)
+
+	event := func(dispatchedAt nulls.Time) *models.Event {
+		e := &models.Event{UserID: user.ID, Type: models.EventClipCreated, Data: `{"id":"abc"}`, DispatchedAt: dispatchedAt}
+		as.NoError(kit.DB.Create(e))
+		return e
+	}
+	stale := event(nulls.NewTime(old))
+	undispatched := event(nulls.Time{})
+	recent := event(nulls.NewTime(time.Now()))
+
+	delivery := func(status string) *models.WebhookDelivery {
+		d := &models.WebhookDelivery{
+			ID:        uuid.Must(uuid.NewV4()),
+			WebhookID: uuid.Must(uuid.NewV4()),
+			Event:     models.EventClipCreated,
+			Payload:   `{"event":"clip.created"}`,
+			Status:    status,
+		}
+		as.NoError(kit.DB.Create(d))
+		return d
+	}
+	succeeded, failed, pending := delivery(models.WebhookDeliverySucceeded), delivery(models.WebhookDeliveryFailed), delivery(models.WebhookDeliveryPending)
+	fresh := delivery(models.WebhookDeliverySucceeded)
+	as.NoError(kit.DB.RawQuery("UPDATE webhook_deliveries SET updated_at = ? WHERE id != ?", old, fresh.ID).Exec())
+
+	// Dispatched events and finished deliveries past webhooks.retention_days go
+	var status scheduler.Status
+	res := client.Post("/api/v1/admin/scheduler/outbox_prune", nil)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&status)
+	as.Empty(status.LastError)
+	as.Equal("deleted 1 events and 2 webhook deliveries", status.LastResult)
+	for _, e := range []*models.Event{stale, undispatched, recent} {
+		exists, err := kit.DB.Where("id = ?", e.ID).Exists(&models.Event{})
+		as.NoError(err)
+		as.Equal(e != stale, exists)
+	}
+	for _, d := range []*models.WebhookDelivery{succeeded, failed, pending, fresh} {
+		exists, err := kit.DB.Where("id = ?", d.ID).Exists(&models.WebhookDelivery{})
+		as.NoError(err)
+		as.Equal(d == pending || d == fresh, exists)
+	}
+}
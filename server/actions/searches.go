@@ -0,0 +1,249 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// SavedSearchPayload is the request body for creating or updating a saved search
+type SavedSearchPayload struct {
+	Name string `json:"name"`
+	ClipFilter
+}
+
+// SavedSearchResponse is the API representation of a saved search
+type SavedSearchResponse struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Filters   ClipFilter `json:"filters"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// listSavedSearches returns the user's saved searches
+func listSavedSearches(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	searches, err := models.FindSavedSearchesByUserID(tx, userID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	resp := make([]SavedSearchResponse, len(searches))
+	for i := range searches {
+		resp[i] = savedSearchToResponse(&searches[i])
+	}
+
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"searches": resp,
+	}))
+}
+
+// createSavedSearch stores a named filter combination
+func createSavedSearch(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	var req SavedSearchPayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	search := &models.SavedSearch{
+		ID:     uuid.Must(uuid.NewV4()),
+		UserID: userID,
+	}
+	if err := applySavedSearchPayload(tx, search, req); err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+
+	if _, err := models.FindSavedSearchByName(tx, userID, search.Name); err == nil {
+		return c.Error(http.StatusConflict, fmt.Errorf("a saved search named %q already exists", search.Name))
+	}
+
+	verrs, err := tx.ValidateAndCreate(search)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	return c.Render(http.StatusCreated, r.JSON(savedSearchToResponse(search)))
+}
+
+// getSavedSearch returns a single saved search
+func getSavedSearch(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	searchID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid search ID"))
+	}
+
+	search, err := models.FindSavedSearchByIDAndUser(tx, searchID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("saved search not found"))
+	}
+
+	return c.Render(http.StatusOK, r.JSON(savedSearchToResponse(search)))
+}
+
+// updateSavedSearch replaces a saved search's name and filters
+func updateSavedSearch(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	searchID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid search ID"))
+	}
+
+	var req SavedSearchPayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	search, err := models.FindSavedSearchByIDAndUser(tx, searchID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("saved search not found"))
+	}
+	if err := applySavedSearchPayload(tx, search, req); err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+
+	if other, err := models.FindSavedSearchByName(tx, userID, search.Name); err == nil && other.ID != search.ID {
+		return c.Error(http.StatusConflict, fmt.Errorf("a saved search named %q already exists", search.Name))
+	}
+
+	verrs, err := tx.ValidateAndUpdate(search)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	return c.Render(http.StatusOK, r.JSON(savedSearchToResponse(search)))
+}
+
+// deleteSavedSearch removes a saved search
+func deleteSavedSearch(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	searchID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid search ID"))
+	}
+
+	search, err := models.FindSavedSearchByIDAndUser(tx, searchID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("saved search not found"))
+	}
+
+	if err := tx.Destroy(search); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	return c.Render(http.StatusNoContent, nil)
+}
+
+// runSavedSearch returns the clips matching a saved search (paginated like GET /clips)
+func runSavedSearch(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	searchID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid search ID"))
+	}
+
+	search, err := models.FindSavedSearchByIDAndUser(tx, searchID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("saved search not found"))
+	}
+
+	var filter ClipFilter
+	if err := json.Unmarshal([]byte(search.Filters), &filter); err != nil {
+		return c.Error(http.StatusInternalServerError, fmt.Errorf("corrupt saved search filters: %w", err))
+	}
+
+	q, err := filter.apply(tx.Where("user_id = ? AND deleted_at IS NULL", userID), userID)
+	if err != nil {
+		return c.Error(http.StatusUnprocessableEntity, err)
+	}
+
+	page, perPage := paginationParams(c)
+	return renderClipPage(c, tx, q, filter.score(userID), page, perPage)
+}
+
+// applySavedSearchPayload validates the filters and copies them onto the model
+func applySavedSearchPayload(tx *pop.Connection, search *models.SavedSearch, req SavedSearchPayload) error {
+	filter := req.ClipFilter
+	filter.Query = strings.TrimSpace(filter.Query)
+	filter.Domain = strings.ToLower(strings.TrimSpace(filter.Domain))
+	filter.Lang = strings.ToLower(strings.TrimSpace(filter.Lang))
+	filter.Tags = models.CleanTagNames(filter.Tags)
+
+	// Build (but don't run) the query to reject invalid dates or coordinates
+	if _, err := filter.apply(tx.Q(), search.UserID); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(filter)
+	if err != nil {
+		return err
+	}
+	search.Name = strings.TrimSpace(req.Name)
+	search.Filters = string(data)
+	return nil
+}
+
+// savedSearchToResponse converts a saved search model to its API representation
+func savedSearchToResponse(s *models.SavedSearch) SavedSearchResponse {
+	var filter ClipFilter
+	json.Unmarshal([]byte(s.Filters), &filter)
+	return SavedSearchResponse{
+		ID:        s.ID.String(),
+		Name:      s.Name,
+		Filters:   filter,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+	}
+}
@@ -0,0 +1,18 @@
+package actions
+
+import "net/http"
+
+func (as *ActionSuite) Test_ListSavedSearches_Unauthorized() {
+	res := as.JSON("/api/v1/searches").Get()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_CreateSavedSearch_Unauthorized() {
+	res := as.JSON("/api/v1/searches").Post(SavedSearchPayload{Name: "Reading"})
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_RunSavedSearch_Unauthorized() {
+	res := as.JSON("/api/v1/searches/550e8400-e29b-41d4-a716-446655440000/clips").Get()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
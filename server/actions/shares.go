@@ -0,0 +1,402 @@
+package actions
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"server/internal/imaging"
+	"server/internal/passhash"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// A share is a public link to one clip, /s/{token}, readable without an
+// account. Its page is the clip rendered like on the mirror, with its media
+// served under /s/{token}/media/. Deleting the share revokes the link;
+// admins can also disable it (see abuse_reports.go).
+//
+// Links can expire after a number of hours and/or page views (burn after
+// reading is a single view). Views are counted with a conditional update, so
+// concurrent visitors can't read a page more than max_views times.
+//
+// A link can also require a passphrase: its page asks for it, and once it
+// was entered the visitor's session unlocks the page and its media. Only an
+// argon2id hash of the passphrase is stored.
+
+// shareViewGrace is how long the media and report form of a page stay
+// available after its last view was used
+const shareViewGrace = 10 * time.Minute
+
+// SharePayload is the optional body of POST /clips/{id}/shares
+type SharePayload struct {
+	ExpiresInHours   int    `json:"expires_in_hours,omitempty"`
+	MaxViews         int    `json:"max_views,omitempty"`
+	BurnAfterReading bool   `json:"burn_after_reading,omitempty"` // Same as max_views 1
+	Password         string `json:"password,omitempty"`           // Passphrase visitors must enter
+}
+
+// ShareResponse is the API representation of a share link
+type ShareResponse struct {
+	ID         string     `json:"id"`
+	ClipID     string     `json:"clip_id"`
+	URL        string     `json:"url"`                   // Public link, on public.base_url
+	DisabledAt *time.Time `json:"disabled_at,omitempty"` // Disabled by an admin
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	MaxViews   int        `json:"max_views,omitempty"`
+	Views      int        `json:"views"`
+	Expired    bool       `json:"expired"`   // Past expires_at or out of views
+	Protected  bool       `json:"protected"` // Requires a passphrase
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func shareToResponse(share *models.Share) ShareResponse {
+	resp := ShareResponse{
+		ID:        share.ID.String(),
+		ClipID:    share.ClipID.String(),
+		URL:       shareURL(share),
+		MaxViews:  share.MaxViews.Int,
+		Views:     share.Views,
+		Expired:   share.Expired(time.Now()) || share.ExhaustedAt.Valid,
+		Protected: share.PasswordHash.Valid,
+		CreatedAt: share.CreatedAt,
+	}
+	if share.DisabledAt.Valid {
+		resp.DisabledAt = &share.DisabledAt.Time
+	}
+	if share.ExpiresAt.Valid {
+		resp.ExpiresAt = &share.ExpiresAt.Time
+	}
+	return resp
+}
+
+// shareURL returns the public link of a share
+func shareURL(share *models.Share) string {
+	return publicURL("/s/" + share.Token)
+}
+
+// listShares returns the share links of a clip
+func listShares(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userID, err := uuid.FromString(c.Value("user_id").(string))
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+	clipID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid clip ID"))
+	}
+
+	clip, err := models.FindClipByIDAndUser(tx, clipID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("clip not found"))
+	}
+	shares, err := models.FindSharesByClip(tx, clip.ID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	resp := make([]ShareResponse, len(shares))
+	for i := range shares {
+		resp[i] = shareToResponse(&shares[i])
+	}
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"shares": resp,
+	}))
+}
+
+// createShare creates a new public link to a clip, optionally limited in
+// time or views
+func createShare(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userID, err := uuid.FromString(c.Value("user_id").(string))
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+	clipID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid clip ID"))
+	}
+
+	var req SharePayload
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(&req); err != nil {
+			return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		}
+	}
+	if req.ExpiresInHours < 0 || req.MaxViews < 0 {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("expires_in_hours and max_views must be positive"))
+	}
+	if req.BurnAfterReading {
+		if req.MaxViews > 1 {
+			return c.Error(http.StatusBadRequest, fmt.Errorf("burn_after_reading allows a single view"))
+		}
+		req.MaxViews = 1
+	}
+
+	clip, err := models.FindClipByIDAndUser(tx, clipID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("clip not found"))
+	}
+	if clip.DisabledAt.Valid {
+		return c.Error(http.StatusForbidden, fmt.Errorf("clip was taken down pending review"))
+	}
+	share, err := models.NewShare(userID, clip.ID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if req.ExpiresInHours > 0 {
+		share.ExpiresAt = nulls.NewTime(time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour))
+	}
+	if req.MaxViews > 0 {
+		share.MaxViews = nulls.NewInt(req.MaxViews)
+	}
+	if req.Password != "" {
+		hash, err := passhash.Hash(req.Password)
+		if err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+		share.PasswordHash = nulls.NewString(hash)
+	}
+	verrs, err := tx.ValidateAndCreate(share)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	return c.Render(http.StatusCreated, r.JSON(shareToResponse(share)))
+}
+
+// deleteShare revokes a share link
+func deleteShare(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userID, err := uuid.FromString(c.Value("user_id").(string))
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+	shareID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid share ID"))
+	}
+
+	share, err := models.FindShareByIDAndUser(tx, shareID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("share not found"))
+	}
+	if err := tx.Destroy(share); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusNoContent, nil)
+}
+
+// sharedClip returns the clip of the share in the request's token and its
+// owner, or an error response when the link is unknown, disabled, expired,
+// locked by a passphrase or the clip gone. It doesn't count a view.
+func sharedClip(c buffalo.Context) (*models.Share, *models.Clip, *models.User, error) {
+	share, clip, user, err := findSharedClip(c)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !shareUnlocked(c, share) {
+		return nil, nil, nil, c.Error(http.StatusUnauthorized, fmt.Errorf("this page requires a passphrase"))
+	}
+	return share, clip, user, nil
+}
+
+// findSharedClip is sharedClip without the passphrase check
+func findSharedClip(c buffalo.Context) (*models.Share, *models.Clip, *models.User, error) {
+	tx := c.Value("tx").(*pop.Connection)
+
+	share, err := models.FindShareByToken(tx, c.Param("token"))
+	if err != nil {
+		return nil, nil, nil, c.Error(http.StatusNotFound, fmt.Errorf("share not found"))
+	}
+	clip, err := models.FindClipByIDAndUser(tx, share.ClipID, share.UserID)
+	if err != nil {
+		return nil, nil, nil, c.Error(http.StatusNotFound, fmt.Errorf("share not found"))
+	}
+	user := &models.User{}
+	if err := tx.Find(user, share.UserID); err != nil || user.Disabled {
+		return nil, nil, nil, c.Error(http.StatusNotFound, fmt.Errorf("share not found"))
+	}
+	if share.DisabledAt.Valid || clip.DisabledAt.Valid {
+		return nil, nil, nil, c.Error(http.StatusGone, fmt.Errorf("this page has been disabled"))
+	}
+	now := time.Now()
+	if share.Expired(now) || (share.ExhaustedAt.Valid && now.Sub(share.ExhaustedAt.Time) > shareViewGrace) {
+		return nil, nil, nil, c.Error(http.StatusGone, fmt.Errorf("this link has expired"))
+	}
+	return share, clip, user, nil
+}
+
+// viewShare renders a shared clip, or the passphrase form of a locked one
+func viewShare(c buffalo.Context) error {
+	share, clip, user, err := findSharedClip(c)
+	if err != nil {
+		return err
+	}
+	if !shareUnlocked(c, share) {
+		return renderSharePassword(c, share, "")
+	}
+	tx := c.Value("tx").(*pop.Connection)
+	ok, err := models.UseShareView(tx, share, time.Now())
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if !ok {
+		return c.Error(http.StatusGone, fmt.Errorf("this link has expired"))
+	}
+	if err := clip.LoadTags(tx); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	folder := filepath.Join(clipRoot(GetConfig(), user, clip), clip.Path)
+	markdown, err := readClipMarkdown(folder)
+	if err != nil && !os.IsNotExist(err) {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	// Media links are relative to the clip folder
+	media := shareURL(share) + "/media/"
+	markdown = strings.NewReplacer("](media/", "]("+media, `src="media/`, `src="`+media).
+		Replace(withTOC(stripFrontmatter(markdown), nil))
+
+	item := mirrorClipItem{
+		Title:     clip.Title,
+		URL:       clip.URL,
+		Domain:    clip.Domain,
+		Tags:      clip.Tags,
+		CreatedAt: clip.CreatedAt,
+	}
+	var html strings.Builder
+	err = mirrorTemplates.ExecuteTemplate(&html, "clip", map[string]interface{}{
+		"Clip": item,
+		"Body": template.HTML(renderMarkdown(markdown)),
+		"Report": map[string]interface{}{
+			"Action":  shareURL(share) + "/report",
+			"Reasons": models.ReportReasons,
+		},
+	})
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	c.Response().Header().Set("Content-Type", "text/html; charset=utf-8")
+	c.Response().WriteHeader(http.StatusOK)
+	c.Response().Write([]byte(html.String()))
+	return nil
+}
+
+// getShareMedia serves a media file of a shared clip
+func getShareMedia(c buffalo.Context) error {
+	_, clip, user, err := sharedClip(c)
+	if err != nil {
+		return err
+	}
+	filename := c.Param("filename")
+	if filename == "" || filename != filepath.Base(filename) || strings.Contains(filename, "..") {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid filename"))
+	}
+
+	path := clipMediaFile(clip, filepath.Join(clipRoot(GetConfig(), user, clip), clip.Path), filename)
+	info, err := os.Stat(path)
+	if path == "" || err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("media file not found"))
+	}
+	data, err := readClipFile(path)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	// The type comes from the content, not the name: a page's HTML saved as
+	// media/x.png must not run on the server's origin
+	header := c.Response().Header()
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Content-Security-Policy", "sandbox")
+	if contentType := imaging.ContentType(imaging.Sniff(data)); contentType != "" {
+		header.Set("Content-Type", contentType)
+	} else {
+		header.Set("Content-Type", "application/octet-stream")
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
+	http.ServeContent(c.Response(), c.Request(), filename, info.ModTime(), bytes.NewReader(data))
+	return nil
+}
+
+// unlockShare checks the passphrase of a share sent by its form, and shows
+// the page when it matches
+func unlockShare(c buffalo.Context) error {
+	share, _, _, err := findSharedClip(c)
+	if err != nil {
+		return err
+	}
+	var req struct {
+		Password string `json:"password" form:"password"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+	if !share.PasswordHash.Valid {
+		return viewShare(c)
+	}
+	if !passhash.Verify(req.Password, share.PasswordHash.String) {
+		c.Logger().WithField("share", share.ID).Warn("wrong share passphrase")
+		return renderSharePassword(c, share, "Wrong passphrase, try again.")
+	}
+
+	c.Session().Set(shareSessionKey(share), shareUnlockProof(share))
+	if err := c.Session().Save(); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return viewShare(c)
+}
+
+// renderSharePassword shows the passphrase form of a locked share
+func renderSharePassword(c buffalo.Context, share *models.Share, message string) error {
+	var html strings.Builder
+	err := mirrorTemplates.ExecuteTemplate(&html, "share-password", map[string]string{
+		"Action":  shareURL(share),
+		"Message": message,
+	})
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	c.Response().Header().Set("Content-Type", "text/html; charset=utf-8")
+	c.Response().WriteHeader(http.StatusUnauthorized)
+	c.Response().Write([]byte(html.String()))
+	return nil
+}
+
+// shareUnlocked reports whether the visitor may read a share: it has no
+// passphrase, or the visitor's session entered the current one
+func shareUnlocked(c buffalo.Context, share *models.Share) bool {
+	if !share.PasswordHash.Valid {
+		return true
+	}
+	proof, _ := c.Session().Get(shareSessionKey(share)).(string)
+	return subtle.ConstantTimeCompare([]byte(proof), []byte(shareUnlockProof(share))) == 1
+}
+
+func shareSessionKey(share *models.Share) string {
+	return "share:" + share.ID.String()
+}
+
+// shareUnlockProof is kept in the session of visitors who entered the
+// passphrase; it changes with the hash, so a new passphrase locks them out
+func shareUnlockProof(share *models.Share) string {
+	sum := sha256.Sum256([]byte(share.PasswordHash.String))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,314 @@
+package actions
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gofrs/uuid"
+)
+
+func (as *ActionSuite) Test_Shares() {
+	kit := testkit.New(as.T())
+	kit.Config.Server.BaseURL = "http://api.internal:3000"
+	kit.Config.Public.BaseURL = "https://share.example.com/"
+	user := kit.CreateUser()
+	app := newKitApp(kit)
+	client := kit.Client(app, user)
+	clip := kit.CreateClip(user, testkit.WithContent("# Shared\n\n![Pic](media/pic.png)"), testkit.WithMedia("pic.png", []byte("png")))
+
+	var share ShareResponse
+	res := client.Post("/api/v1/clips/"+clip.ID.String()+"/shares", nil)
+	as.Equal(http.StatusCreated, res.Code, res.Body.String())
+	res.JSON(&share)
+	as.True(strings.HasPrefix(share.URL, "https://share.example.com/s/"), share.URL)
+	path := strings.TrimPrefix(share.URL, "https://share.example.com")
+
+	var list struct {
+		Shares []ShareResponse `json:"shares"`
+	}
+	client.Get("/api/v1/clips/" + clip.ID.String() + "/shares").JSON(&list)
+	as.Equal([]ShareResponse{share}, list.Shares)
+
+	// Anyone with the link reads the clip, on the app or the public listener
+	anonymous := kit.Client(app, user)
+	anonymous.Token = ""
+	public := kit.Client(newPublicApp(kit.DB), user)
+	public.Token = ""
+	for _, c := range []*testkit.Client{anonymous, public} {
+		res = c.Get(path)
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		as.Contains(res.Body.String(), "Shared</h1>")
+		as.Contains(res.Body.String(), `src="`+share.URL+`/media/pic.png"`)
+		res = c.Get(path + "/media/pic.png")
+		as.Equal(http.StatusOK, res.Code)
+		as.Equal("png", res.Body.String())
+		as.Equal("nosniff", res.Header().Get("X-Content-Type-Options"))
+		as.Equal("sandbox", res.Header().Get("Content-Security-Policy"))
+	}
+	as.Equal(http.StatusNotFound, public.Get("/api/v1/config").Code)
+	as.Equal(http.StatusNotFound, public.Get(path+"/media/missing.png").Code)
+
+	// Revoked links and trashed clips are gone
+	other := kit.CreateClip(user)
+	var trashed ShareResponse
+	client.Post("/api/v1/clips/"+other.ID.String()+"/shares", nil).JSON(&trashed)
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/clips/"+other.ID.String()).Code)
+	as.Equal(http.StatusNotFound, anonymous.Get(strings.TrimPrefix(trashed.URL, "https://share.example.com")).Code)
+
+	res = client.Delete("/api/v1/shares/" + share.ID)
+	as.Equal(http.StatusNoContent, res.Code, res.Body.String())
+	as.Equal(http.StatusNotFound, anonymous.Get(path).Code)
+	as.Equal(http.StatusNotFound, client.Delete("/api/v1/shares/"+share.ID).Code)
+
+	// Only the owner shares a clip
+	stranger := kit.Client(app, kit.CreateUser())
+	as.Equal(http.StatusNotFound, stranger.Post("/api/v1/clips/"+clip.ID.String()+"/shares", nil).Code)
+}
+
+func (as *ActionSuite) Test_CollectionFeed() {
+	kit := testkit.New(as.T())
+	kit.Config.Public.BaseURL = "https://share.example.com"
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+
+	var public, private CollectionResponse
+	client.Post("/api/v1/collections", CollectionPayload{Name: "Reading List", Public: true}).JSON(&public)
+	client.Post("/api/v1/collections", CollectionPayload{Name: "Private"}).JSON(&private)
+	clip := kit.CreateClip(user, testkit.WithTags("go"), testkit.WithClip(func(c *models.Clip) {
+		c.Title = "Feed item"
+		c.URL = "https://example.com/item"
+		c.CollectionID = nulls.NewUUID(uuid.FromStringOrNil(public.ID))
+	}))
+
+	feeds := kit.Client(newPublicApp(kit.DB), user)
+	feeds.Token = ""
+	res := feeds.Get("/feeds/" + public.ID)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	as.Equal("application/atom+xml; charset=utf-8", res.Header().Get("Content-Type"))
+	var feed atomFeed
+	as.NoError(xml.Unmarshal(res.Body.Bytes(), &feed))
+	as.Equal("Reading List", feed.Title)
+	as.Equal([]atomLink{{Href: "https://share.example.com/feeds/" + public.ID, Rel: "self"}}, feed.Links)
+	as.Len(feed.Entries, 1)
+	as.Equal("urn:uuid:"+clip.ID.String(), feed.Entries[0].ID)
+	as.Equal("https://example.com/item", feed.Entries[0].Link.Href)
+	as.Equal([]atomCategory{{Term: "go"}}, feed.Entries[0].Categories)
+
+	as.Equal(http.StatusNotFound, feeds.Get("/feeds/"+private.ID).Code)
+	as.Equal(http.StatusNotFound, feeds.Get("/feeds/nope").Code)
+}
+
+func (as *ActionSuite) Test_Shares_Limits() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+	visitor := kit.Client(newPublicApp(kit.DB), user)
+	visitor.Token = ""
+	clip := kit.CreateClip(user, testkit.WithContent("# Secret"), testkit.WithMedia("pic.png", []byte("png")))
+	sharesPath := "/api/v1/clips/" + clip.ID.String() + "/shares"
+
+	as.Equal(http.StatusBadRequest, client.Post(sharesPath, SharePayload{MaxViews: -1}).Code)
+	as.Equal(http.StatusBadRequest, client.Post(sharesPath, SharePayload{MaxViews: 2, BurnAfterReading: true}).Code)
+
+	// A limited link is read max_views times; its media stay available to the last page
+	var twice ShareResponse
+	res := client.Post(sharesPath, SharePayload{MaxViews: 2})
+	as.Equal(http.StatusCreated, res.Code, res.Body.String())
+	res.JSON(&twice)
+	as.Equal(2, twice.MaxViews)
+	as.Equal(http.StatusOK, visitor.Get(twice.URL).Code)
+	as.Equal(http.StatusOK, visitor.Get(twice.URL).Code)
+	as.Equal(http.StatusGone, visitor.Get(twice.URL).Code)
+	as.Equal(http.StatusOK, visitor.Get(twice.URL+"/media/pic.png").Code)
+
+	var list struct {
+		Shares []ShareResponse `json:"shares"`
+	}
+	client.Get(sharesPath).JSON(&list)
+	as.Equal(2, list.Shares[0].Views)
+	as.True(list.Shares[0].Expired)
+
+	// Burned links are gone once the grace period is over
+	var burn ShareResponse
+	client.Post(sharesPath, SharePayload{BurnAfterReading: true}).JSON(&burn)
+	as.Equal(1, burn.MaxViews)
+	as.Equal(http.StatusOK, visitor.Get(burn.URL).Code)
+	as.Equal(http.StatusGone, visitor.Get(burn.URL).Code)
+	share, err := models.FindShareByToken(kit.DB, burn.URL[strings.LastIndex(burn.URL, "/")+1:])
+	as.NoError(err)
+	share.ExhaustedAt = nulls.NewTime(time.Now().Add(-shareViewGrace - time.Minute))
+	as.NoError(kit.DB.UpdateColumns(share, "exhausted_at"))
+	as.Equal(http.StatusGone, visitor.Get(burn.URL+"/media/pic.png").Code)
+
+	// Expired links
+	var expiring ShareResponse
+	client.Post(sharesPath, SharePayload{ExpiresInHours: 1}).JSON(&expiring)
+	as.NotNil(expiring.ExpiresAt)
+	as.Equal(http.StatusOK, visitor.Get(expiring.URL).Code)
+	share, err = models.FindShareByToken(kit.DB, expiring.URL[strings.LastIndex(expiring.URL, "/")+1:])
+	as.NoError(err)
+	share.ExpiresAt = nulls.NewTime(time.Now().Add(-time.Second))
+	as.NoError(kit.DB.UpdateColumns(share, "expires_at"))
+	as.Equal(http.StatusGone, visitor.Get(expiring.URL).Code)
+	as.Equal(http.StatusGone, visitor.Get(expiring.URL+"/media/pic.png").Code)
+}
+
+func (as *ActionSuite) Test_Shares_Password() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+	visitor := kit.Client(newPublicApp(kit.DB), user)
+	visitor.Token = ""
+	clip := kit.CreateClip(user, testkit.WithContent("# Sensitive"), testkit.WithMedia("pic.png", []byte("png")))
+
+	var share ShareResponse
+	res := client.Post("/api/v1/clips/"+clip.ID.String()+"/shares", SharePayload{Password: "open sesame"})
+	as.Equal(http.StatusCreated, res.Code, res.Body.String())
+	res.JSON(&share)
+	as.True(share.Protected)
+	stored, err := models.FindShareByToken(kit.DB, share.URL[strings.LastIndex(share.URL, "/")+1:])
+	as.NoError(err)
+	as.NotContains(stored.PasswordHash.String, "open sesame")
+
+	// The page asks for the passphrase; media and reports are locked
+	res = visitor.Get(share.URL)
+	as.Equal(http.StatusUnauthorized, res.Code)
+	as.Contains(res.Body.String(), `name="password"`)
+	as.NotContains(res.Body.String(), "Sensitive")
+	as.Equal(http.StatusUnauthorized, visitor.Get(share.URL+"/media/pic.png").Code)
+	as.Equal(http.StatusUnauthorized, visitor.Post(share.URL+"/report", ReportPayload{Reason: "spam"}).Code)
+
+	form := "application/x-www-form-urlencoded"
+	res = visitor.Upload(share.URL, strings.NewReader("password=guess"), form)
+	as.Equal(http.StatusUnauthorized, res.Code)
+	as.Contains(res.Body.String(), "Wrong passphrase")
+
+	res = visitor.Upload(share.URL, strings.NewReader("password=open+sesame"), form)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	as.Contains(res.Body.String(), "Sensitive</h1>")
+
+	// The session unlocks the page and its media
+	visitor.Header = http.Header{"Cookie": {strings.Split(res.Header().Get("Set-Cookie"), ";")[0]}}
+	as.Equal(http.StatusOK, visitor.Get(share.URL).Code)
+	as.Equal(http.StatusOK, visitor.Get(share.URL+"/media/pic.png").Code)
+}
+
+func (as *ActionSuite) Test_Shares_MediaTypeFromContent() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	app := newKitApp(kit)
+	client := kit.Client(app, user)
+	clip := kit.CreateClip(user,
+		testkit.WithMedia("real.png", []byte("\x89PNG\r\n\x1a\n0000")),
+		testkit.WithMedia("page.png", []byte("<html><script>alert(document.cookie)</script></html>")))
+	var share ShareResponse
+	client.Post("/api/v1/clips/"+clip.ID.String()+"/shares", nil).JSON(&share)
+	visitor := kit.Client(app, user)
+	visitor.Token = ""
+
+	res := visitor.Get(share.URL + "/media/real.png")
+	as.Equal(http.StatusOK, res.Code)
+	as.Equal("image/png", res.Header().Get("Content-Type"))
+
+	// HTML named .png is downloaded, never rendered
+	res = visitor.Get(share.URL + "/media/page.png")
+	as.Equal(http.StatusOK, res.Code)
+	as.Equal("application/octet-stream", res.Header().Get("Content-Type"))
+	as.Contains(res.Header().Get("Content-Disposition"), "attachment")
+	as.Equal("nosniff", res.Header().Get("X-Content-Type-Options"))
+}
+
+func (as *ActionSuite) Test_Shares_RevokedWithTheClip() {
+	kit := testkit.New(as.T())
+	from := kit.CreateUser()
+	to := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), from)
+	share := func(clip *models.Clip) *models.Share {
+		var resp ShareResponse
+		client.Post("/api/v1/clips/"+clip.ID.String()+"/shares", nil).JSON(&resp)
+		s, err := models.FindShareByToken(kit.DB, resp.URL[strings.LastIndex(resp.URL, "/")+1:])
+		as.Require().NoError(err)
+		return s
+	}
+
+	// A clip given to another user is no longer served by the old links
+	given := kit.CreateClip(from)
+	share(given)
+	_, _, err := TransferClips(kit.DB, from, to, []uuid.UUID{given.ID}, false)
+	as.NoError(err)
+	count, err := kit.DB.Where("clip_id = ?", given.ID).Count(&models.Share{})
+	as.NoError(err)
+	as.Zero(count)
+
+	// Purged clips leave no share behind
+	purged := kit.CreateClip(from)
+	share(purged)
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/clips/"+purged.ID.String()).Code)
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/trash/"+purged.ID.String()).Code)
+	count, err = kit.DB.Where("clip_id = ?", purged.ID).Count(&models.Share{})
+	as.NoError(err)
+	as.Zero(count)
+}
+
+func (as *ActionSuite) Test_Shares_PassphraseAttempts() {
+	kit := testkit.New(as.T())
+	kit.Config.Logging.TrustProxy = true
+	kit.Config.Public.PassphraseAttemptsPerMinute = 3
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+	app := newPublicApp(kit.DB)
+	visitor := func(ip string) *testkit.Client {
+		v := kit.Client(app, user)
+		v.Token = ""
+		v.Header = http.Header{"X-Forwarded-For": {ip}}
+		return v
+	}
+	var first, second ShareResponse
+	for _, share := range []*ShareResponse{&first, &second} {
+		clip := kit.CreateClip(user)
+		client.Post("/api/v1/clips/"+clip.ID.String()+"/shares", SharePayload{Password: "open sesame"}).JSON(share)
+	}
+	unlock := func(v *testkit.Client, share ShareResponse, password string) int {
+		return v.Upload(share.URL, strings.NewReader(url.Values{"password": {password}}.Encode()), "application/x-www-form-urlencoded").Code
+	}
+
+	// Guessing locks the link out, even for the right passphrase
+	attacker := visitor("198.51.100.7")
+	for range 3 {
+		as.Equal(http.StatusUnauthorized, unlock(attacker, first, "guess"))
+	}
+	as.Equal(http.StatusTooManyRequests, unlock(attacker, first, "open sesame"))
+	as.Equal(http.StatusTooManyRequests, unlock(visitor("203.0.113.9"), first, "open sesame"), "per link")
+
+	// and the address out of every link
+	as.Equal(http.StatusTooManyRequests, unlock(attacker, second, "guess"), "per address")
+	as.Equal(http.StatusOK, unlock(visitor("203.0.113.9"), second, "open sesame"))
+}
+
+func (as *ActionSuite) Test_Shares_RateLimited() {
+	kit := testkit.New(as.T())
+	kit.Config.RateLimit.Enabled = true
+	kit.Config.RateLimit.PerIP.RequestsPerMinute = 60
+	kit.Config.RateLimit.PerIP.Burst = 2
+	user := kit.CreateUser()
+	app := newKitApp(kit)
+	var share ShareResponse
+	kit.Client(app, user).Post("/api/v1/clips/"+kit.CreateClip(user).ID.String()+"/shares", nil).JSON(&share)
+	path := share.URL[strings.Index(share.URL, "/s/"):]
+
+	for _, handler := range []http.Handler{newKitApp(kit), newPublicApp(kit.DB)} {
+		visitor := kit.Client(handler, user)
+		visitor.Token = ""
+		as.Equal(http.StatusOK, visitor.Get(path).Code)
+		as.Equal(http.StatusOK, visitor.Get(path).Code)
+		as.Equal(http.StatusTooManyRequests, visitor.Get(path).Code)
+		as.Equal(http.StatusTooManyRequests, visitor.Post(path+"/report", ReportPayload{Reason: "spam"}).Code)
+	}
+}
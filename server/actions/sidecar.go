@@ -0,0 +1,155 @@
+package actions
+
+import (
+	"encoding/json"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"time"
+
+	"server/internal/extract"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+)
+
+// With clips.sidecar, clip folders get a clip.json next to their page: the
+// clip's metadata and a manifest of its files with their hashes, for tools
+// reading the clip directory without parsing frontmatter. It describes the
+// capture, not its state (read, archived, collection), and is rewritten when
+// the capture or its tags change. Versions archive it with the rest of the
+// capture. Notes (storage.layout: obsidian) have none.
+
+// sidecarName is the name of the sidecar in clip folders
+const sidecarName = "clip.json"
+
+// sidecarSchema is the version of the sidecar's format
+const sidecarSchema = 1
+
+// ClipSidecar is the content of a clip's sidecar
+type ClipSidecar struct {
+	Schema    int               `json:"schema"`
+	ID        string            `json:"id"`
+	Title     string            `json:"title"`
+	URL       string            `json:"url"`
+	Domain    string            `json:"domain"`
+	Mode      string            `json:"mode"`
+	Tags      []string          `json:"tags"`
+	Notes     string            `json:"notes,omitempty"`
+	Language  string            `json:"language,omitempty"`
+	Location  *ClipLocation     `json:"location,omitempty"`
+	Metadata  *extract.Metadata `json:"metadata,omitempty"`
+	Page      string            `json:"page"`  // Main file, relative to the folder
+	Files     []SidecarFile     `json:"files"` // Page files and media
+	ClippedAt time.Time         `json:"clipped_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// SidecarFile is a file of a clip's capture
+type SidecarFile struct {
+	Path   string `json:"path"` // Relative to the folder, with slashes
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// writeClipSidecar writes the sidecar of the clip in folderPath, when
+// clips.sidecar is on. The clip's tags must be loaded.
+func writeClipSidecar(folderPath string, clip *models.Clip) error {
+	if !GetConfig().Clips.Sidecar || clip.IsNote() {
+		return nil
+	}
+
+	sidecar := ClipSidecar{
+		Schema:    sidecarSchema,
+		ID:        clip.ID.String(),
+		Title:     clip.Title,
+		URL:       clip.URL,
+		Domain:    clip.Domain,
+		Mode:      clip.Mode,
+		Tags:      clip.Tags,
+		Notes:     clip.Notes.String,
+		Language:  clip.Language.String,
+		Location:  clipLocation(clip),
+		Metadata:  clipMetadata(clip),
+		Page:      clipMainFile(folderPath, clip),
+		Files:     []SidecarFile{},
+		ClippedAt: clip.CreatedAt.UTC(),
+		UpdatedAt: clip.UpdatedAt.UTC(),
+	}
+	if sidecar.Tags == nil {
+		sidecar.Tags = []string{}
+	}
+
+	err := filepath.WalkDir(folderPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(folderPath, p)
+		if d.IsDir() {
+			if rel == versionsDirName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if rel == sidecarName {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hash, err := hashFile(p)
+		if err != nil {
+			return err
+		}
+		sidecar.Files = append(sidecar.Files, SidecarFile{Path: filepath.ToSlash(rel), Size: info.Size(), SHA256: hash})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileSync(filepath.Join(folderPath, sidecarName), append(data, '\n'), 0644)
+}
+
+// refreshClipSidecar rewrites the sidecar of a clip whose capture or tags
+// changed. Failures are only logged: the sidecar is derived from the clip.
+func refreshClipSidecar(tx *pop.Connection, clip *models.Clip) {
+	if write := clipSidecarWriter(tx, clip); write != nil {
+		write()
+	}
+}
+
+// refreshClipSidecarOnCommit is refreshClipSidecar in a request: the sidecar
+// is rewritten once the transaction commits, so a request rolled back leaves
+// the previous one describing the clip as it still is
+func refreshClipSidecarOnCommit(c buffalo.Context, tx *pop.Connection, clip *models.Clip) {
+	if write := clipSidecarWriter(tx, clip); write != nil {
+		onCommit(c, write)
+	}
+}
+
+// clipSidecarWriter returns what writes the sidecar of the clip as it is when
+// called, its storage included, or nil without sidecars. The owner is loaded
+// in tx.
+func clipSidecarWriter(tx *pop.Connection, clip *models.Clip) func() {
+	if !GetConfig().Clips.Sidecar || clip.IsNote() {
+		return nil
+	}
+	user := &models.User{}
+	if err := tx.Find(user, clip.UserID); err != nil {
+		log.Printf("Failed to write the sidecar of clip %s: %v", clip.ID, err)
+		return nil
+	}
+	return func() {
+		folderPath := filepath.Join(clipRoot(GetConfig(), user, clip), clip.Path)
+		if err := writeClipSidecar(folderPath, clip); err != nil {
+			log.Printf("Failed to write the sidecar of clip %s: %v", clip.ID, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package actions
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
+
+	"github.com/gofrs/uuid"
+)
+
+func (as *ActionSuite) Test_ClipSidecar() {
+	kit := testkit.New(as.T())
+	kit.Config.Clips.Sidecar = true
+	kit.Config.Clips.DuplicateWindowSeconds = -1
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	var buf bytes.Buffer
+	as.NoError(png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 10, 10))))
+	sum := sha256.Sum256(buf.Bytes())
+	payload := ClipPayload{
+		Title:    "Sidecar",
+		URL:      "https://example.com/sidecar",
+		Markdown: "# Sidecar\n\n![hero](media/hero.png)",
+		Mode:     "article",
+		Tags:     []string{"go"},
+		Images:   []ImagePayload{{Filename: "hero.png", Data: base64.StdEncoding.EncodeToString(buf.Bytes())}},
+	}
+	var clip ClipResponse
+	res := client.Post("/api/v1/clips", payload)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+	folder := filepath.Join(kit.StorageRoot, filepath.Dir(clip.Path))
+
+	read := func(p string) ClipSidecar {
+		var sidecar ClipSidecar
+		data, err := os.ReadFile(p)
+		as.Require().NoError(err)
+		as.NoError(json.Unmarshal(data, &sidecar))
+		return sidecar
+	}
+	sidecar := read(filepath.Join(folder, sidecarName))
+	as.Equal(clip.ID, sidecar.ID)
+	as.Equal("https://example.com/sidecar", sidecar.URL)
+	as.Equal([]string{"go"}, sidecar.Tags)
+	as.Equal(filepath.Base(clip.Path), sidecar.Page)
+	as.Len(sidecar.Files, 2)
+	as.Contains(sidecar.Files, SidecarFile{Path: "media/hero.png", Size: int64(buf.Len()), SHA256: hex.EncodeToString(sum[:])})
+
+	// Tag changes rewrite it
+	res = client.Post("/api/v1/clips/bulk", BulkPayload{Operation: bulkAddTags, IDs: []string{clip.ID}, Tags: []string{"web"}})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	as.Equal([]string{"go", "web"}, read(filepath.Join(folder, sidecarName)).Tags)
+
+	// Only once committed: a bulk change rolled back leaves it as it was
+	res = client.Post("/api/v1/clips/bulk", BulkPayload{Operation: bulkAddTags, IDs: []string{clip.ID, uuid.Must(uuid.NewV4()).String()}, Tags: []string{"rust"}})
+	as.Equal(http.StatusUnprocessableEntity, res.Code, res.Body.String())
+	as.Equal([]string{"go", "web"}, read(filepath.Join(folder, sidecarName)).Tags)
+
+	// Versions keep theirs
+	payload.Title = "Sidecar v2"
+	payload.Images = nil
+	res = client.Post("/api/v1/clips", payload)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	as.Equal("Sidecar", read(filepath.Join(folder, versionsDirName, "v1", sidecarName)).Title)
+	sidecar = read(filepath.Join(folder, sidecarName))
+	as.Equal("Sidecar v2", sidecar.Title)
+	as.Len(sidecar.Files, 1)
+
+	// Off by default
+	kit.Config.Clips.Sidecar = false
+	payload.URL = "https://example.com/other"
+	res = client.Post("/api/v1/clips", payload)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&clip)
+	as.NoFileExists(filepath.Join(kit.StorageRoot, filepath.Dir(clip.Path), sidecarName))
+}
@@ -0,0 +1,73 @@
+package actions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"server/internal/config"
+	"server/internal/imaging"
+	"server/models"
+)
+
+// Published clips get a social preview card (mirror.social_cards) so links
+// to them unfurl with an image in Slack, Discord and the like: the title and
+// site over the clip's first image, or over a plain color. Cards are cached
+// per clip in web-clips/.cards/<clip id>-<key>.png, the key changing with
+// the title and cover.
+
+// cardDir is the social card cache, next to the clip folders
+const cardDir = ".cards"
+
+// firstMediaImage matches the first image of a page stored in its media folder
+var firstMediaImage = regexp.MustCompile(`!\[[^\]]*\]\(media/([^)\s]+)\)`)
+
+// socialCard returns the preview card of a clip whose folder and page
+// markdown are given, rendering it unless cached
+func socialCard(cfg *config.Config, user *models.User, clip *models.Clip, folder, markdown string) ([]byte, error) {
+	site := clip.Domain
+	if meta := clipMetadata(clip); meta != nil && meta.SiteName != "" {
+		site = meta.SiteName
+	}
+
+	// The cover, when it is not too large to decode
+	var cover []byte
+	key := sha256.New()
+	fmt.Fprintf(key, "%s\x00%s\x00", clip.Title, site)
+	if m := firstMediaImage.FindStringSubmatch(markdown); m != nil {
+		path := clipMediaFile(clip, folder, filepath.Base(m[1]))
+		if info, err := os.Stat(path); path != "" && err == nil && info.Size() <= cfg.Images.MaxSizeBytes {
+			if cover, err = readClipFile(path); err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(key, "%s\x00%d\x00%d", info.Name(), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+
+	cache := filepath.Join(userClipDir(cfg, user), "web-clips", cardDir)
+	cached := filepath.Join(cache, clip.ID.String()+"-"+hex.EncodeToString(key.Sum(nil))[:16]+".png")
+	if data, err := os.ReadFile(cached); err == nil {
+		return data, nil
+	}
+
+	data, err := imaging.Card(clip.Title, site, cover)
+	if err != nil {
+		return nil, err
+	}
+	// Caching is best effort: replace the clip's previous card
+	stale, _ := filepath.Glob(filepath.Join(cache, clip.ID.String()+"-*.png"))
+	if err := os.MkdirAll(cache, 0755); err == nil {
+		tmp := cached + ".tmp"
+		if err := os.WriteFile(tmp, data, 0644); err == nil && os.Rename(tmp, cached) == nil {
+			for _, old := range stale {
+				os.Remove(old)
+			}
+		} else {
+			os.Remove(tmp)
+		}
+	}
+	return data, nil
+}
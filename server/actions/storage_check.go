@@ -0,0 +1,227 @@
+package actions
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"server/models"
+
+	"github.com/gobuffalo/pop/v6"
+)
+
+// The storage check compares clip rows with the files in storage: rows whose
+// folder or note is gone, clip folders and notes without a row, and media
+// linked from a page but missing on disk. Each kind can be reconciled:
+// rows without files are deleted, files without a row get one back (see
+// ReindexClips), and links to missing media are replaced by their text.
+// Files without a row in a directory several users share (the base path)
+// are only reported, as their owner is unknown.
+
+// Kinds of storage issues
+const (
+	issueMissingFiles = "missing_files" // Clip row whose folder or note is gone
+	issueOrphanFiles  = "orphan_files"  // Clip folder or note without a row
+	issueMissingMedia = "missing_media" // Media linked from a page but not on disk
+)
+
+// pageMediaLinks matches links and embeds of media in a clip's page, with
+// their text
+var pageMediaLinks = regexp.MustCompile(`!?\[([^\]]*)\]\(media/([^)\s]+)\)`)
+
+// StorageIssue is a mismatch between a clip row and its files
+type StorageIssue struct {
+	Kind   string `json:"kind"`
+	User   string `json:"user"` // Email of the clip's owner
+	ClipID string `json:"clip_id,omitempty"`
+	Path   string `json:"path"`             // Clip folder or note
+	Detail string `json:"detail,omitempty"` // Name of the missing media, or why it wasn't fixed
+	Fixed  bool   `json:"fixed"`
+}
+
+// StorageFixes picks the kinds of issues CheckStorage reconciles
+type StorageFixes struct {
+	Missing bool // Delete the rows of clips whose files are gone
+	Orphans bool // Rebuild the rows of clip folders and notes without one
+	Media   bool // Replace links to missing media by their text
+}
+
+// CheckStorage checks the clips of one user, or every user without an
+// email, against their files and returns the issues found, fixing those
+// fixes selects. Fixes are written in tx, which the caller commits.
+func CheckStorage(tx *pop.Connection, email string, fixes StorageFixes) ([]StorageIssue, error) {
+	users := models.Users{}
+	q := tx.Order("email ASC")
+	if email != "" {
+		q = q.Where("email = ?", email)
+	}
+	if err := q.All(&users); err != nil {
+		return nil, err
+	}
+	if email != "" && len(users) == 0 {
+		return nil, fmt.Errorf("user not found: %s", email)
+	}
+
+	cfg := GetConfig()
+	var issues []StorageIssue
+	for i := range users {
+		user := &users[i]
+		clips := models.Clips{}
+		if err := tx.Where("user_id = ?", user.ID).Order("created_at ASC").All(&clips); err != nil {
+			return nil, err
+		}
+
+		for j := range clips {
+			clip := &clips[j]
+			root := clipRoot(cfg, user, clip)
+			p := filepath.Join(root, clip.Path)
+			if clip.DeletedAt.Valid {
+				p = filepath.Join(root, trashDirName, clip.Path)
+			}
+
+			if _, err := os.Stat(p); os.IsNotExist(err) {
+				issue := StorageIssue{Kind: issueMissingFiles, User: user.Email, ClipID: clip.ID.String(), Path: p}
+				if fixes.Missing {
+					if err := purgeClip(tx, clip, root); err != nil {
+						return nil, err
+					}
+					issue.Fixed = true
+				}
+				issues = append(issues, issue)
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			if clip.DeletedAt.Valid {
+				continue
+			}
+
+			missing, err := checkClipMedia(clip, p, fixes.Media)
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range missing {
+				issues = append(issues, StorageIssue{
+					Kind: issueMissingMedia, User: user.Email, ClipID: clip.ID.String(), Path: p, Detail: name, Fixed: fixes.Media,
+				})
+			}
+			if len(missing) > 0 && fixes.Media {
+				refreshClipSidecar(tx, clip)
+			}
+		}
+	}
+
+	// Users without a clip directory share the base path: its orphans can't
+	// be given to any of them
+	owners := map[string][]*models.User{}
+	var orphans []string
+	for i := range users {
+		found, err := ReindexClips(tx, &users[i], true)
+		if err != nil {
+			return nil, err
+		}
+		for _, orphan := range found {
+			if owners[orphan.Path] == nil {
+				orphans = append(orphans, orphan.Path)
+			}
+			owners[orphan.Path] = append(owners[orphan.Path], &users[i])
+		}
+	}
+	restored := map[string]string{}
+	if fixes.Orphans {
+		for i := range users {
+			clips, err := reindexClips(tx, &users[i], false, func(p string) bool {
+				return len(owners[p]) == 1
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, clip := range clips {
+				restored[clip.Path] = clip.ID
+			}
+		}
+	}
+	for _, p := range orphans {
+		issue := StorageIssue{Kind: issueOrphanFiles, Path: p, ClipID: restored[p], Fixed: restored[p] != ""}
+		if len(owners[p]) == 1 {
+			issue.User = owners[p][0].Email
+		} else {
+			issue.Detail = fmt.Sprintf("shared by %d users: restore it with clips reindex --email", len(owners[p]))
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+// checkClipMedia returns the names of the media the pages of clip, stored at
+// p, link to but which are not on disk. With fix, the links are replaced by
+// their text.
+func checkClipMedia(clip *models.Clip, p string, fix bool) ([]string, error) {
+	pages := []string{p}
+	if !clip.IsNote() {
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		pages = nil
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") {
+				pages = append(pages, filepath.Join(p, entry.Name()))
+			}
+		}
+	}
+
+	var missing []string
+	for _, page := range pages {
+		data, err := readClipFile(page)
+		if err != nil {
+			return nil, err
+		}
+		content := string(data)
+
+		var found []string
+		exists := func(name string) bool {
+			file := clipMediaFile(clip, p, name)
+			if file == "" {
+				return true // Not one of the clip's media
+			}
+			if _, err := os.Stat(file); err != nil {
+				found = append(found, name)
+				return false
+			}
+			return true
+		}
+
+		var fixed string
+		if clip.IsNote() {
+			fixed = wikiLinks.ReplaceAllStringFunc(content, func(link string) string {
+				m := wikiLinks.FindStringSubmatch(link)
+				if ext := path.Ext(m[2]); ext == "" || ext == ".md" || exists(m[2]) {
+					return link // A link to another note, or a present attachment
+				}
+				return strings.TrimPrefix(m[2], noteMediaPrefix(clip.ID))
+			})
+		} else {
+			fixed = pageMediaLinks.ReplaceAllStringFunc(content, func(link string) string {
+				m := pageMediaLinks.FindStringSubmatch(link)
+				name, err := url.PathUnescape(m[2])
+				if err != nil || exists(name) {
+					return link
+				}
+				return m[1]
+			})
+		}
+
+		missing = append(missing, found...)
+		if fix && len(found) > 0 {
+			if err := writeFileSync(page, []byte(fixed), 0644); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,63 @@
+package actions
+
+import (
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
+	"server/models"
+)
+
+func (as *ActionSuite) Test_CheckStorage() {
+	kit := testkit.New(as.T())
+	newKitApp(kit) // Installs the kit's config
+	user := kit.CreateUser()
+	other := kit.CreateUser()
+
+	gone := kit.CreateClip(user)
+	as.NoError(os.RemoveAll(filepath.Join(kit.StorageRoot, gone.Path)))
+	broken := kit.CreateClip(user,
+		testkit.WithContent("![hero](media/hero.png) ![kept](media/ok.png) [the%20doc](media/the%20doc.pdf)"),
+		testkit.WithMedia("ok.png", []byte("png")))
+	orphan := kit.CreateClip(user, testkit.WithClip(func(c *models.Clip) { c.Title = "Orphan" }))
+	as.NoError(kit.DB.RawQuery("DELETE FROM clips WHERE id = ?", orphan.ID).Exec())
+	fine := kit.CreateClip(other)
+
+	page := func() string {
+		content, err := readClipMarkdown(filepath.Join(kit.StorageRoot, broken.Path))
+		as.NoError(err)
+		return stripFrontmatter(content)
+	}
+	before := page()
+
+	issues, err := CheckStorage(kit.DB, "", StorageFixes{})
+	as.NoError(err)
+	as.ElementsMatch([]StorageIssue{
+		{Kind: issueMissingFiles, User: user.Email, ClipID: gone.ID.String(), Path: filepath.Join(kit.StorageRoot, gone.Path)},
+		{Kind: issueMissingMedia, User: user.Email, ClipID: broken.ID.String(), Path: filepath.Join(kit.StorageRoot, broken.Path), Detail: "hero.png"},
+		{Kind: issueMissingMedia, User: user.Email, ClipID: broken.ID.String(), Path: filepath.Join(kit.StorageRoot, broken.Path), Detail: "the doc.pdf"},
+		{Kind: issueOrphanFiles, Path: filepath.Join(kit.StorageRoot, orphan.Path), Detail: "shared by 2 users: restore it with clips reindex --email"},
+	}, issues)
+	as.Equal(before, page())
+	as.NoError(kit.DB.Find(&models.Clip{}, gone.ID))
+
+	issues, err = CheckStorage(kit.DB, user.Email, StorageFixes{Missing: true, Orphans: true, Media: true})
+	as.NoError(err)
+	as.Len(issues, 4)
+	for _, issue := range issues {
+		as.True(issue.Fixed, issue.Kind)
+	}
+	as.Error(kit.DB.Find(&models.Clip{}, gone.ID))
+	as.Equal("hero ![kept](media/ok.png) the%20doc\n", page())
+	restored := &models.Clip{}
+	as.NoError(kit.DB.Where("title = ?", "Orphan").First(restored))
+	as.Equal(orphan.Path, restored.Path)
+	as.NoError(kit.DB.Find(&models.Clip{}, fine.ID))
+
+	issues, err = CheckStorage(kit.DB, "", StorageFixes{})
+	as.NoError(err)
+	as.Empty(issues)
+
+	_, err = CheckStorage(kit.DB, "nobody@example.com", StorageFixes{})
+	as.Error(err)
+}
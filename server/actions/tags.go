@@ -0,0 +1,261 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Users can attach rules to their tags: aliases are names written as the
+// tag (js for javascript), and implied tags are added along with it
+// (kubernetes implies devops), implied tags of implied tags included. Names
+// match whatever their case. Rules apply whenever tags are written (saves,
+// bulk add-tags); clips tagged before a rule was set keep their tags.
+
+// tagRules holds a user's aliases and implications by lowercased name
+type tagRules struct {
+	aliases map[string]string   // Alias to tag
+	implies map[string][]string // Tag to the tags it implies
+}
+
+// newTagRules gathers the rules of tags
+func newTagRules(tags models.Tags) tagRules {
+	rules := tagRules{aliases: map[string]string{}, implies: map[string][]string{}}
+	for _, tag := range tags {
+		for _, alias := range tag.AliasList() {
+			rules.aliases[strings.ToLower(alias)] = tag.Name
+		}
+		if implied := tag.ImpliedList(); len(implied) > 0 {
+			rules.implies[strings.ToLower(tag.Name)] = implied
+		}
+	}
+	return rules
+}
+
+// loadTagRules reads the rules of the user's tags
+func loadTagRules(tx *pop.Connection, userID uuid.UUID) (tagRules, error) {
+	tags, err := models.FindTagsByUserID(tx, userID)
+	if err != nil {
+		return tagRules{}, err
+	}
+	return newTagRules(tags), nil
+}
+
+// alias returns the tag name is written as
+func (r tagRules) alias(name string) string {
+	if tag, ok := r.aliases[strings.ToLower(name)]; ok {
+		return tag
+	}
+	return name
+}
+
+// resolveTags returns the tags to write for names: the user's aliases are
+// replaced by their tags and implied tags added, then, when tags.taxonomy is
+// set, tags are mapped to the taxonomy. Tags outside the taxonomy make an
+// *UnknownTagsError or are dropped, depending on the mode; implied ones are
+// always dropped, as the user didn't ask for them.
+func resolveTags(tx *pop.Connection, userID uuid.UUID, names []string) ([]string, error) {
+	names = models.CleanTagNames(names)
+	if len(names) == 0 {
+		return names, nil
+	}
+
+	rules, err := loadTagRules(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var t taxonomy
+	mode := GetConfig().Tags.Taxonomy
+	if mode != "" {
+		tags, err := models.FindTaxonomyTags(tx)
+		if err != nil {
+			return nil, err
+		}
+		t = newTaxonomy(tags)
+	}
+	return rules.resolve(names, t, mode)
+}
+
+// resolve applies the rules to names, and the taxonomy t when not nil
+func (r tagRules) resolve(names []string, t taxonomy, mode string) ([]string, error) {
+	type pending struct {
+		name    string
+		implied bool
+	}
+	queue := make([]pending, len(names))
+	for i, name := range names {
+		queue[i] = pending{name: name}
+	}
+
+	resolved := make([]string, 0, len(names))
+	seen := map[string]bool{}
+	var unknown []string
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+
+		tag := r.alias(next.name)
+		if t != nil {
+			var ok bool
+			if tag, ok = t.lookup(tag); !ok {
+				if !next.implied {
+					unknown = append(unknown, next.name)
+				}
+				continue
+			}
+		}
+		if seen[strings.ToLower(tag)] {
+			continue // Also ends implication cycles
+		}
+		seen[strings.ToLower(tag)] = true
+		resolved = append(resolved, tag)
+		for _, implied := range r.implies[strings.ToLower(tag)] {
+			queue = append(queue, pending{name: implied, implied: true})
+		}
+	}
+	if len(unknown) > 0 && mode != taxonomyDrop {
+		return nil, &UnknownTagsError{Tags: unknown}
+	}
+	return resolved, nil
+}
+
+// TagRulesPayload is the request body for setting a tag's rules
+type TagRulesPayload struct {
+	Aliases []string `json:"aliases"` // Names written as the tag
+	Implies []string `json:"implies"` // Tags added along with it
+}
+
+// TagResponse is the API representation of a tag
+type TagResponse struct {
+	Name    string   `json:"name"`
+	Clips   int      `json:"clips"` // Clips carrying it, trash excluded
+	Aliases []string `json:"aliases"`
+	Implies []string `json:"implies"`
+}
+
+// listTags returns the user's tags with their rules
+func listTags(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	tags, err := models.FindTagsByUserID(tx, userID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	counts, err := models.CountClipsByTag(tx, userID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	resp := make([]TagResponse, len(tags))
+	for i := range tags {
+		resp[i] = tagToResponse(&tags[i], counts[tags[i].ID])
+	}
+
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"tags": resp,
+	}))
+}
+
+// updateTagRules replaces the aliases and implied tags of a tag, creating
+// the tag if the user has none by that name
+func updateTagRules(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	name := strings.TrimSpace(c.Param("name"))
+	if name == "" {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid tag name"))
+	}
+
+	var req TagRulesPayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	tags, err := models.FindTagsByUserID(tx, userID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	others := models.Tags{}
+	for _, tag := range tags {
+		if tag.Name != name {
+			others = append(others, tag)
+		}
+	}
+	rules := newTagRules(others)
+
+	// A tag is either an alias or a tag with rules, so aliases resolve in
+	// one step
+	if tag, ok := rules.aliases[strings.ToLower(name)]; ok {
+		return c.Error(http.StatusConflict, fmt.Errorf("%q is an alias of %q", name, tag))
+	}
+	var aliases []string
+	for _, alias := range models.CleanTagNames(req.Aliases) {
+		if strings.EqualFold(alias, name) {
+			continue
+		}
+		if tag, ok := rules.aliases[strings.ToLower(alias)]; ok {
+			return c.Error(http.StatusConflict, fmt.Errorf("%q is already an alias of %q", alias, tag))
+		}
+		for _, other := range others {
+			if strings.EqualFold(other.Name, alias) && (len(other.AliasList()) > 0 || len(other.ImpliedList()) > 0) {
+				return c.Error(http.StatusConflict, fmt.Errorf("%q has its own rules", other.Name))
+			}
+		}
+		aliases = append(aliases, alias)
+	}
+	var implied []string
+	for _, tag := range models.CleanTagNames(req.Implies) {
+		if !strings.EqualFold(tag, name) {
+			implied = append(implied, tag)
+		}
+	}
+
+	tag, err := models.FindOrCreateTag(tx, userID, name)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	tag.SetAliasList(aliases)
+	tag.SetImpliedList(implied)
+	if err := tx.UpdateColumns(tag, "aliases", "implies", "updated_at"); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	counts, err := models.CountClipsByTag(tx, userID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	return c.Render(http.StatusOK, r.JSON(tagToResponse(tag, counts[tag.ID])))
+}
+
+// tagToResponse converts a tag model to its API representation
+func tagToResponse(tag *models.Tag, clips int) TagResponse {
+	resp := TagResponse{
+		Name:    tag.Name,
+		Clips:   clips,
+		Aliases: tag.AliasList(),
+		Implies: tag.ImpliedList(),
+	}
+	if resp.Aliases == nil {
+		resp.Aliases = []string{}
+	}
+	if resp.Implies == nil {
+		resp.Implies = []string{}
+	}
+	return resp
+}
@@ -0,0 +1,65 @@
+package actions
+
+import (
+	"net/http"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_TagRules() {
+	kit := testkit.New(as.T())
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+
+	res := client.Put("/api/v1/tags/javascript", TagRulesPayload{Aliases: []string{"js", "JavaScript"}})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	var tag TagResponse
+	res.JSON(&tag)
+	as.Equal([]string{"js"}, tag.Aliases)
+	as.Equal(http.StatusOK, client.Put("/api/v1/tags/kubernetes", TagRulesPayload{Aliases: []string{"k8s"}, Implies: []string{"devops"}}).Code)
+	as.Equal(http.StatusOK, client.Put("/api/v1/tags/devops", TagRulesPayload{Implies: []string{"ops", "k8s"}}).Code)
+
+	// Aliases resolve in one step
+	as.Equal(http.StatusConflict, client.Put("/api/v1/tags/ecmascript", TagRulesPayload{Aliases: []string{"JS"}}).Code)
+	as.Equal(http.StatusConflict, client.Put("/api/v1/tags/js", TagRulesPayload{Implies: []string{"web"}}).Code)
+	as.Equal(http.StatusConflict, client.Put("/api/v1/tags/containers", TagRulesPayload{Aliases: []string{"kubernetes"}}).Code)
+
+	save := func(url string, tags ...string) []string {
+		res := client.Post("/api/v1/clips", ClipPayload{Title: "Page", URL: url, Mode: "article", Markdown: "# Page", Tags: tags})
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		var clip ClipResponse
+		res.JSON(&clip)
+		var detail ClipDetail
+		client.Get("/api/v1/clips/" + clip.ID).JSON(&detail)
+		return detail.Tags
+	}
+
+	// Implied tags follow, cycles included
+	as.ElementsMatch([]string{"javascript", "kubernetes", "devops", "ops", "read"}, save("https://example.com/a", "JS", "K8S", "read"))
+	as.ElementsMatch([]string{"javascript"}, save("https://example.com/b", "javascript", "js"))
+
+	// Implied tags outside the taxonomy are left out
+	kit.Config.Tags.Taxonomy = taxonomyReject
+	admin := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{admin.Email}
+	adminClient := kit.Client(newKitApp(kit), admin)
+	for _, name := range []string{"kubernetes", "devops"} {
+		as.Equal(http.StatusCreated, adminClient.Post("/api/v1/admin/taxonomy", TaxonomyTagPayload{Name: name}).Code)
+	}
+	as.ElementsMatch([]string{"kubernetes", "devops"}, save("https://example.com/c", "k8s"))
+	kit.Config.Tags.Taxonomy = ""
+
+	var list struct {
+		Tags []TagResponse `json:"tags"`
+	}
+	client.Get("/api/v1/tags").JSON(&list)
+	clips := map[string]int{}
+	for _, tag := range list.Tags {
+		clips[tag.Name] = tag.Clips
+	}
+	as.Equal(map[string]int{"devops": 2, "javascript": 2, "kubernetes": 2, "ops": 1, "read": 1}, clips)
+
+	// Clearing the rules stops them
+	as.Equal(http.StatusOK, client.Put("/api/v1/tags/javascript", TagRulesPayload{}).Code)
+	as.ElementsMatch([]string{"js"}, save("https://example.com/d", "js"))
+}
@@ -0,0 +1,233 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// Team instances can restrict tags to a controlled vocabulary: with
+// tags.taxonomy set, the tags of saved clips and bulk updates must be in the
+// taxonomy admins manage, or one of its aliases, which is replaced by the
+// tag it stands for. Names match whatever their case. Other tags are refused
+// ("reject") or left out ("drop"); see resolveTags. Tags clips already carry
+// are kept when the taxonomy changes.
+
+// Taxonomy modes (tags.taxonomy)
+const (
+	taxonomyReject = "reject"
+	taxonomyDrop   = "drop"
+)
+
+// taxonomy maps the lowercased names and aliases of the taxonomy's tags to
+// their names
+type taxonomy map[string]string
+
+// newTaxonomy maps the names and aliases of tags
+func newTaxonomy(tags models.TaxonomyTags) taxonomy {
+	t := taxonomy{}
+	for _, tag := range tags {
+		t[strings.ToLower(tag.Name)] = tag.Name
+		for _, alias := range tag.AliasList() {
+			t[strings.ToLower(alias)] = tag.Name
+		}
+	}
+	return t
+}
+
+// lookup returns the tag name stands for, if any
+func (t taxonomy) lookup(name string) (string, bool) {
+	tag, ok := t[strings.ToLower(name)]
+	return tag, ok
+}
+
+// UnknownTagsError lists the tags of a request that aren't in the taxonomy
+type UnknownTagsError struct {
+	Tags []string
+}
+
+func (e *UnknownTagsError) Error() string {
+	return fmt.Sprintf("tags not in the taxonomy: %s", strings.Join(e.Tags, ", "))
+}
+
+// TaxonomyTagPayload is the request body for creating or updating a tag of
+// the taxonomy
+type TaxonomyTagPayload struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Aliases     []string `json:"aliases"` // Names mapped to the tag
+}
+
+// TaxonomyTagResponse is the API representation of a tag of the taxonomy
+type TaxonomyTagResponse struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Aliases     []string  `json:"aliases"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TaxonomyResponse is the taxonomy and whether it is enforced
+type TaxonomyResponse struct {
+	Mode string                `json:"mode"` // reject, drop, or empty when any tag is allowed
+	Tags []TaxonomyTagResponse `json:"tags"`
+}
+
+// getTaxonomy returns the taxonomy, for clients to suggest its tags
+func getTaxonomy(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	tags, err := models.FindTaxonomyTags(tx)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	resp := TaxonomyResponse{
+		Mode: GetConfig().Tags.Taxonomy,
+		Tags: make([]TaxonomyTagResponse, len(tags)),
+	}
+	for i := range tags {
+		resp.Tags[i] = taxonomyTagToResponse(&tags[i])
+	}
+	return c.Render(http.StatusOK, r.JSON(resp))
+}
+
+// adminCreateTaxonomyTag adds a tag to the taxonomy
+func adminCreateTaxonomyTag(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	var req TaxonomyTagPayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	tag := &models.TaxonomyTag{ID: uuid.Must(uuid.NewV4())}
+	if status, err := applyTaxonomyTagPayload(tx, tag, req); err != nil {
+		return c.Error(status, err)
+	}
+
+	verrs, err := tx.ValidateAndCreate(tag)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	return c.Render(http.StatusCreated, r.JSON(taxonomyTagToResponse(tag)))
+}
+
+// adminUpdateTaxonomyTag replaces a tag's name, description and aliases.
+// Clips keep the tags they carry.
+func adminUpdateTaxonomyTag(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	tagID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid tag ID"))
+	}
+
+	var req TaxonomyTagPayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	tag := &models.TaxonomyTag{}
+	if err := tx.Find(tag, tagID); err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("tag not found"))
+	}
+	if status, err := applyTaxonomyTagPayload(tx, tag, req); err != nil {
+		return c.Error(status, err)
+	}
+
+	verrs, err := tx.ValidateAndUpdate(tag)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	return c.Render(http.StatusOK, r.JSON(taxonomyTagToResponse(tag)))
+}
+
+// adminDeleteTaxonomyTag removes a tag from the taxonomy; clips carrying it
+// keep it
+func adminDeleteTaxonomyTag(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	tagID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid tag ID"))
+	}
+
+	tag := &models.TaxonomyTag{}
+	if err := tx.Find(tag, tagID); err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("tag not found"))
+	}
+	if err := tx.Destroy(tag); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	return c.Render(http.StatusNoContent, nil)
+}
+
+// applyTaxonomyTagPayload copies the payload onto the tag, making sure its
+// name and aliases don't stand for another tag of the taxonomy. It returns
+// the status to fail the request with.
+func applyTaxonomyTagPayload(tx *pop.Connection, tag *models.TaxonomyTag, req TaxonomyTagPayload) (int, error) {
+	tag.Name = strings.TrimSpace(req.Name)
+	tag.Description = nulls.String{}
+	if description := strings.TrimSpace(req.Description); description != "" {
+		tag.Description = nulls.NewString(description)
+	}
+	var aliases []string
+	for _, alias := range models.CleanTagNames(req.Aliases) {
+		if !strings.EqualFold(alias, tag.Name) {
+			aliases = append(aliases, alias)
+		}
+	}
+	tag.SetAliasList(aliases)
+
+	tags, err := models.FindTaxonomyTags(tx)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	others := models.TaxonomyTags{}
+	for _, other := range tags {
+		if other.ID != tag.ID {
+			others = append(others, other)
+		}
+	}
+	taken := newTaxonomy(others)
+	for _, name := range append([]string{tag.Name}, tag.AliasList()...) {
+		if other, ok := taken.lookup(name); ok {
+			return http.StatusConflict, fmt.Errorf("%q already stands for the tag %q", name, other)
+		}
+	}
+	return 0, nil
+}
+
+// taxonomyTagToResponse converts a taxonomy tag model to its API representation
+func taxonomyTagToResponse(tag *models.TaxonomyTag) TaxonomyTagResponse {
+	aliases := tag.AliasList()
+	if aliases == nil {
+		aliases = []string{}
+	}
+	return TaxonomyTagResponse{
+		ID:          tag.ID.String(),
+		Name:        tag.Name,
+		Description: tag.Description.String,
+		Aliases:     aliases,
+		CreatedAt:   tag.CreatedAt,
+		UpdatedAt:   tag.UpdatedAt,
+	}
+}
@@ -0,0 +1,83 @@
+package actions
+
+import (
+	"net/http"
+
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_Taxonomy() {
+	kit := testkit.New(as.T())
+	admin := kit.CreateUser()
+	kit.Config.Admin.Emails = []string{admin.Email}
+	app := newKitApp(kit)
+	client := kit.Client(app, admin)
+
+	var golang, rust TaxonomyTagResponse
+	res := client.Post("/api/v1/admin/taxonomy", TaxonomyTagPayload{Name: "golang", Aliases: []string{"Go", "golang"}})
+	as.Equal(http.StatusCreated, res.Code, res.Body.String())
+	res.JSON(&golang)
+	as.Equal([]string{"Go"}, golang.Aliases)
+	res = client.Post("/api/v1/admin/taxonomy", TaxonomyTagPayload{Name: "rust", Description: "The language"})
+	as.Equal(http.StatusCreated, res.Code, res.Body.String())
+	res.JSON(&rust)
+
+	// Names and aliases stand for a single tag
+	as.Equal(http.StatusConflict, client.Post("/api/v1/admin/taxonomy", TaxonomyTagPayload{Name: "GO"}).Code)
+	as.Equal(http.StatusConflict, client.Put("/api/v1/admin/taxonomy/"+rust.ID, TaxonomyTagPayload{Name: "rust", Aliases: []string{"Golang"}}).Code)
+	as.Equal(http.StatusUnprocessableEntity, client.Post("/api/v1/admin/taxonomy", TaxonomyTagPayload{Name: " "}).Code)
+	res = client.Put("/api/v1/admin/taxonomy/"+rust.ID, TaxonomyTagPayload{Name: "rust", Aliases: []string{"rs"}})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+
+	user := kit.Client(app, kit.CreateUser())
+	as.Equal(http.StatusForbidden, user.Post("/api/v1/admin/taxonomy", TaxonomyTagPayload{Name: "mine"}).Code)
+	var taxonomy TaxonomyResponse
+	user.Get("/api/v1/taxonomy").JSON(&taxonomy)
+	as.Equal("", taxonomy.Mode)
+	as.Len(taxonomy.Tags, 2)
+
+	var ids []string
+	save := func(tags ...string) (*testkit.Response, []string) {
+		res := user.Post("/api/v1/clips", ClipPayload{Title: "Page", URL: "https://example.com/" + tags[0], Mode: "article", Markdown: "# Page", Tags: tags})
+		var clip ClipResponse
+		res.JSON(&clip)
+		ids = append(ids, clip.ID)
+		var detail ClipDetail
+		user.Get("/api/v1/clips/" + clip.ID).JSON(&detail)
+		return res, detail.Tags
+	}
+
+	// Without tags.taxonomy, any tag goes
+	res, tags := save("anything", "Go")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	as.ElementsMatch([]string{"anything", "Go"}, tags)
+
+	// Aliases are mapped, unknown tags refused...
+	kit.Config.Tags.Taxonomy = taxonomyReject
+	res, tags = save("GO", "RS", "golang")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	as.ElementsMatch([]string{"golang", "rust"}, tags)
+	before := clipRejections.Values()[rejectUnknownTags]
+	res, _ = save("golang", "cooking")
+	as.Equal(http.StatusUnprocessableEntity, res.Code)
+	as.Contains(res.Body.String(), "cooking")
+	as.Equal(before+1, clipRejections.Values()[rejectUnknownTags])
+
+	ids = ids[:2] // The clips saved
+	res = user.Post("/api/v1/clips/bulk", BulkPayload{Operation: bulkAddTags, IDs: ids, Tags: []string{"cooking"}})
+	as.Equal(http.StatusBadRequest, res.Code, res.Body.String())
+	as.Equal(http.StatusOK, user.Post("/api/v1/clips/bulk", BulkPayload{Operation: bulkAddTags, IDs: ids, Tags: []string{"rs"}}).Code)
+
+	// ...or dropped
+	kit.Config.Tags.Taxonomy = taxonomyDrop
+	res, tags = save("cooking", "go")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	as.Equal([]string{"golang"}, tags)
+
+	// Removing a tag from the taxonomy leaves clips alone
+	as.Equal(http.StatusNoContent, client.Delete("/api/v1/admin/taxonomy/"+golang.ID).Code)
+	as.Equal(http.StatusNotFound, client.Delete("/api/v1/admin/taxonomy/"+golang.ID).Code)
+	res, tags = save("golang", "rust")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	as.Equal([]string{"rust"}, tags)
+}
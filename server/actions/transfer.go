@@ -0,0 +1,286 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"server/internal/services"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// When accounts are consolidated, an admin can hand clips over to another
+// user. Clip folders move into the target's clip directory, and tags and
+// collections are matched by name in the target's library, created if missing.
+
+// TransferredClip is a clip moved (or, in a dry run, selected) by an ownership transfer
+type TransferredClip struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Path    string `json:"path"`
+	Trashed bool   `json:"trashed"`
+	From    string `json:"from"` // Folder before the transfer
+	To      string `json:"to"`   // Folder after the transfer
+}
+
+// movedFolder is a clip folder already moved
+type movedFolder struct {
+	src, dst string
+}
+
+// folderMoves moves clip folders, remembering them so they can be moved back
+// if a later step fails
+type folderMoves []movedFolder
+
+// move moves src to dst; a missing src (files already gone) is not an error
+func (m *folderMoves) move(src, dst string) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	if err := moveDir(src, dst); err != nil {
+		return err
+	}
+	*m = append(*m, movedFolder{src, dst})
+	return nil
+}
+
+// undo moves the folders back, most recent first
+func (m folderMoves) undo() {
+	for i := len(m) - 1; i >= 0; i-- {
+		if err := moveDir(m[i].dst, m[i].src); err != nil {
+			log.Printf("failed to move %s back to %s: %v", m[i].dst, m[i].src, err)
+		}
+	}
+}
+
+// TransferClips gives clips of one user to another. With no clipIDs the whole
+// library, trash included, is transferred. The transfer is all or nothing:
+// folders already moved are moved back on failure, and tx is expected to be
+// rolled back by the caller. The folders are moved before tx commits: undo
+// moves them back, for the caller to run if tx is rolled back after all.
+// With dryRun nothing changes.
+func TransferClips(tx *pop.Connection, from, to *models.User, clipIDs []uuid.UUID, dryRun bool) (transferred []TransferredClip, undo func(), err error) {
+	if from.ID == to.ID {
+		return nil, nil, fmt.Errorf("clips already belong to %s", to.Email)
+	}
+
+	clips := models.Clips{}
+	q := tx.Where("user_id = ?", from.ID)
+	var ids []interface{}
+	seen := map[uuid.UUID]bool{}
+	for _, id := range clipIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > 0 {
+		q = q.Where("id IN (?)", ids...)
+	}
+	if err := q.Order("created_at ASC").All(&clips); err != nil {
+		return nil, nil, err
+	}
+	if len(clips) < len(ids) {
+		return nil, nil, fmt.Errorf("%d of the clips do not belong to %s", len(ids)-len(clips), from.Email)
+	}
+
+	cfg := GetConfig()
+	transferred = make([]TransferredClip, len(clips))
+	for i, clip := range clips {
+		// Clips stored in a collection's absolute path stay where they are
+		fromDir, toDir := clipRoot(cfg, from, &clip), clipRoot(cfg, to, &clip)
+		rel := clip.Path
+		if clip.DeletedAt.Valid {
+			rel = filepath.Join(trashDirName, clip.Path)
+		}
+		transferred[i] = TransferredClip{
+			ID:      clip.ID.String(),
+			Title:   clip.Title,
+			Path:    clip.Path,
+			Trashed: clip.DeletedAt.Valid,
+			From:    filepath.Join(fromDir, rel),
+			To:      filepath.Join(toDir, rel),
+		}
+		if filepath.Clean(fromDir) != filepath.Clean(toDir) {
+			if _, err := os.Stat(transferred[i].To); err == nil {
+				return nil, nil, fmt.Errorf("%s already exists in the target's clip directory", rel)
+			}
+		}
+	}
+	if dryRun {
+		return transferred, func() {}, nil
+	}
+
+	var moves folderMoves
+	for i := range clips {
+		clip := &clips[i]
+		if t := transferred[i]; filepath.Clean(t.From) != filepath.Clean(t.To) {
+			files, err := clipMoves(clip, clipRoot(cfg, from, clip), clipRoot(cfg, to, clip))
+			if err != nil {
+				moves.undo()
+				return nil, nil, err
+			}
+			for _, move := range files {
+				if err := moves.move(move.src, move.dst); err != nil {
+					moves.undo()
+					return nil, nil, fmt.Errorf("failed to move %s: %w", move.src, err)
+				}
+			}
+		}
+		if err := transferClipRow(tx, clip, to); err != nil {
+			moves.undo()
+			return nil, nil, fmt.Errorf("failed to transfer clip %s: %w", clip.ID, err)
+		}
+	}
+	return transferred, moves.undo, nil
+}
+
+// transferClipRow rewrites a clip's ownership, re-creating its tags and
+// collection in the target's library and revoking its share links
+func transferClipRow(tx *pop.Connection, clip *models.Clip, to *models.User) error {
+	if err := clip.LoadTags(tx); err != nil {
+		return err
+	}
+
+	var collection nulls.UUID
+	if clip.CollectionID.Valid {
+		src := &models.Collection{}
+		if err := tx.Find(src, clip.CollectionID.UUID); err != nil {
+			return err
+		}
+		dst, err := models.FindCollectionByName(tx, to.ID, src.Name)
+		if err != nil {
+			dst = &models.Collection{ID: uuid.Must(uuid.NewV4()), UserID: to.ID, Name: src.Name, StoragePath: src.StoragePath}
+			if err := tx.Create(dst); err != nil {
+				return err
+			}
+		}
+		collection = nulls.NewUUID(dst.ID)
+	}
+
+	clip.UserID = to.ID
+	clip.CollectionID = collection
+	clip.IdempotencyKey = nulls.String{} // Keys are scoped to the user who sent them
+	if err := tx.Update(clip); err != nil {
+		return err
+	}
+	if err := models.SetClipTags(tx, clip, clip.Tags); err != nil {
+		return err
+	}
+	// The previous owner's share links are revoked, not handed over
+	if err := tx.RawQuery("DELETE FROM shares WHERE clip_id = ?", clip.ID).Exec(); err != nil {
+		return err
+	}
+	return tx.RawQuery("UPDATE highlights SET user_id = ? WHERE clip_id = ?", to.ID, clip.ID).Exec()
+}
+
+// moveDir moves a folder, copying it when src and dst are on different
+// filesystems
+func moveDir(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyTree(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyTree copies the files under src to dst
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+// adminTransferClips gives a user's clips (all of them without clip_ids) to
+// another user
+func adminTransferClips(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+
+	var req struct {
+		To      string   `json:"to"`
+		ClipIDs []string `json:"clip_ids"`
+		DryRun  bool     `json:"dry_run"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+	if req.To == "" {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("to is required"))
+	}
+	ids := make([]uuid.UUID, len(req.ClipIDs))
+	for i, s := range req.ClipIDs {
+		id, err := uuid.FromString(s)
+		if err != nil {
+			return c.Error(http.StatusBadRequest, fmt.Errorf("invalid clip ID: %s", s))
+		}
+		ids[i] = id
+	}
+
+	from, to := &models.User{}, &models.User{}
+	if err := tx.Where("email = ?", c.Param("email")).First(from); err != nil {
+		return c.Error(http.StatusNotFound, services.ErrUserNotFound)
+	}
+	if err := tx.Where("email = ?", req.To).First(to); err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("%w: %s", services.ErrUserNotFound, req.To))
+	}
+
+	transferred, undo, err := TransferClips(tx, from, to, ids, req.DryRun)
+	if err != nil {
+		return c.Error(http.StatusConflict, err)
+	}
+	onRollback(c, undo)
+	if !req.DryRun {
+		if err := recordAudit(c, tx, auditTransfer, "from", from.Email, "to", to.Email, "clips", len(transferred)); err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+	}
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"dry_run": req.DryRun,
+		"from":    from.Email,
+		"to":      to.Email,
+		"clips":   transferred,
+	}))
+}
@@ -0,0 +1,132 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gofrs/uuid"
+)
+
+func (as *ActionSuite) Test_TransferClips() {
+	kit := testkit.New(as.T())
+	from := kit.CreateUser()
+	to := kit.CreateUser(func(u *models.User) { u.ClipDirectory = nulls.NewString(as.T().TempDir()) })
+	kit.Config.Admin.Emails = []string{from.Email}
+	client := kit.Client(newKitApp(kit), from)
+
+	collection := &models.Collection{ID: uuid.Must(uuid.NewV4()), UserID: from.ID, Name: "Reading"}
+	as.NoError(kit.DB.Create(collection))
+	clip := kit.CreateClip(from, testkit.WithTags("go", "web"), testkit.WithClip(func(c *models.Clip) {
+		c.CollectionID = nulls.NewUUID(collection.ID)
+		c.IdempotencyKey = nulls.NewString("key-1")
+	}))
+	highlight := &models.Highlight{ID: uuid.Must(uuid.NewV4()), ClipID: clip.ID, UserID: from.ID, Text: "quote", Color: "yellow"}
+	as.NoError(kit.DB.Create(highlight))
+
+	trashed := kit.CreateClip(from, testkit.WithClip(func(c *models.Clip) { c.DeletedAt = nulls.NewTime(time.Now()) }))
+	as.NoError(moveToTrash(kit.StorageRoot, trashed.Path))
+	kept := kit.CreateClip(from)
+
+	var resp struct {
+		DryRun bool              `json:"dry_run"`
+		Clips  []TransferredClip `json:"clips"`
+	}
+	// A clip listed twice is transferred once
+	body := map[string]interface{}{"to": to.Email, "clip_ids": []string{clip.ID.String(), trashed.ID.String(), clip.ID.String()}, "dry_run": true}
+	res := client.Post("/api/v1/admin/users/"+from.Email+"/transfer", body)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&resp)
+	as.True(resp.DryRun)
+	as.Len(resp.Clips, 2)
+	_, err := os.Stat(filepath.Join(kit.StorageRoot, clip.Path))
+	as.NoError(err)
+
+	body["dry_run"] = false
+	res = client.Post("/api/v1/admin/users/"+from.Email+"/transfer", body)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+
+	// Folders moved into the target's clip directory, the trash stays trash
+	toDir := to.ClipDirectory.String
+	for _, path := range []string{filepath.Join(toDir, clip.Path), filepath.Join(toDir, trashDirName, trashed.Path), filepath.Join(kit.StorageRoot, kept.Path)} {
+		_, err := os.Stat(path)
+		as.NoError(err, path)
+	}
+	for _, path := range []string{filepath.Join(kit.StorageRoot, clip.Path), filepath.Join(kit.StorageRoot, trashDirName, trashed.Path)} {
+		_, err := os.Stat(path)
+		as.True(os.IsNotExist(err), path)
+	}
+
+	moved := &models.Clip{}
+	as.NoError(kit.DB.Find(moved, clip.ID))
+	as.Equal(to.ID, moved.UserID)
+	as.False(moved.IdempotencyKey.Valid)
+	as.NoError(moved.LoadTags(kit.DB))
+	as.ElementsMatch([]string{"go", "web"}, moved.Tags)
+	target, err := models.FindCollectionByName(kit.DB, to.ID, "Reading")
+	as.NoError(err)
+	as.Equal(nulls.NewUUID(target.ID), moved.CollectionID)
+	as.NoError(kit.DB.Reload(highlight))
+	as.Equal(to.ID, highlight.UserID)
+	as.NoError(kit.DB.Find(moved, trashed.ID))
+	as.Equal(to.ID, moved.UserID)
+
+	// The rest of the library follows when no clip is selected
+	transferred, _, err := TransferClips(kit.DB, from, to, nil, false)
+	as.NoError(err)
+	as.Len(transferred, 1)
+	as.Equal(kept.ID.String(), transferred[0].ID)
+	_, err = os.Stat(filepath.Join(toDir, kept.Path))
+	as.NoError(err)
+}
+
+func (as *ActionSuite) Test_TransferClips_Undo() {
+	kit := testkit.New(as.T())
+	from := kit.CreateUser()
+	to := kit.CreateUser(func(u *models.User) { u.ClipDirectory = nulls.NewString(as.T().TempDir()) })
+	clip := kit.CreateClip(from)
+	newKitApp(kit) // The transfer reads the kit's config
+
+	// Folders move before the transaction commits, and back if it does not
+	_, undo, err := TransferClips(kit.DB, from, to, []uuid.UUID{clip.ID}, false)
+	as.Require().NoError(err)
+	_, err = os.Stat(filepath.Join(to.ClipDirectory.String, clip.Path))
+	as.NoError(err)
+	undo()
+	_, err = os.Stat(filepath.Join(kit.StorageRoot, clip.Path))
+	as.NoError(err)
+	_, err = os.Stat(filepath.Join(to.ClipDirectory.String, clip.Path))
+	as.True(os.IsNotExist(err))
+}
+
+func (as *ActionSuite) Test_TransferClips_Rejected() {
+	kit := testkit.New(as.T())
+	from := kit.CreateUser()
+	to := kit.CreateUser(func(u *models.User) { u.ClipDirectory = nulls.NewString(as.T().TempDir()) })
+	kit.Config.Admin.Emails = []string{from.Email}
+	client := kit.Client(newKitApp(kit), from)
+	clip := kit.CreateClip(from)
+	other := kit.CreateClip(to)
+
+	// Clips of someone else
+	res := client.Post("/api/v1/admin/users/"+from.Email+"/transfer", map[string]interface{}{"to": to.Email, "clip_ids": []string{other.ID.String()}})
+	as.Equal(http.StatusConflict, res.Code)
+
+	// A folder with the same name in the target's storage
+	as.NoError(os.MkdirAll(filepath.Join(to.ClipDirectory.String, clip.Path), 0755))
+	res = client.Post("/api/v1/admin/users/"+from.Email+"/transfer", map[string]interface{}{"to": to.Email})
+	as.Equal(http.StatusConflict, res.Code)
+
+	res = client.Post("/api/v1/admin/users/"+from.Email+"/transfer", map[string]interface{}{"to": "nobody@example.com"})
+	as.Equal(http.StatusNotFound, res.Code)
+
+	as.NoError(kit.DB.Reload(clip))
+	as.Equal(from.ID, clip.UserID)
+	_, err := os.Stat(filepath.Join(kit.StorageRoot, clip.Path))
+	as.NoError(err)
+}
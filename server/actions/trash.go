@@ -0,0 +1,278 @@
+package actions
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// trashDirName is the folder (under the user's clip directory) holding trashed clip files
+const trashDirName = ".trash"
+
+// TrashedClip represents a clip in the trash
+type TrashedClip struct {
+	ClipSummary
+	DeletedAt time.Time  `json:"deleted_at"`
+	PurgeAt   *time.Time `json:"purge_at,omitempty"` // When retention will permanently delete it
+}
+
+// ListTrashResponse is the response from GET /api/v1/trash
+type ListTrashResponse struct {
+	Clips         []TrashedClip `json:"clips"`
+	RetentionDays int           `json:"retention_days"`
+}
+
+// listTrash returns the user's trashed clips
+func listTrash(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	clips, err := models.FindTrashedClipsByUserID(tx, userID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	if err := clips.LoadTags(tx); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	retentionDays := GetConfig().Storage.TrashRetentionDays
+	trashed := make([]TrashedClip, len(clips))
+	for i := range clips {
+		trashed[i] = TrashedClip{
+			ClipSummary: clipToSummary(&clips[i]),
+			DeletedAt:   clips[i].DeletedAt.Time,
+		}
+		if retentionDays > 0 {
+			purgeAt := clips[i].DeletedAt.Time.AddDate(0, 0, retentionDays)
+			trashed[i].PurgeAt = &purgeAt
+		}
+	}
+
+	return c.Render(http.StatusOK, r.JSON(ListTrashResponse{
+		Clips:         trashed,
+		RetentionDays: retentionDays,
+	}))
+}
+
+// restoreClip moves a clip out of the trash
+func restoreClip(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	clipID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid clip ID"))
+	}
+
+	clip, err := models.FindTrashedClipByIDAndUser(tx, clipID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("clip not found in trash"))
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, userID); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	if err := restoreFromTrash(clipRoot(GetConfig(), user, clip), clip.Path); err != nil {
+		if os.IsExist(err) {
+			return c.Error(http.StatusConflict, fmt.Errorf("a folder already exists at %s", clip.Path))
+		}
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	clip.DeletedAt = nulls.Time{}
+	if err := tx.Update(clip); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if err := clip.LoadTags(tx); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	return c.Render(http.StatusOK, r.JSON(clipToSummary(clip)))
+}
+
+// purgeTrashedClip permanently deletes a single trashed clip
+func purgeTrashedClip(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	clipID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid clip ID"))
+	}
+
+	clip, err := models.FindTrashedClipByIDAndUser(tx, clipID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("clip not found in trash"))
+	}
+
+	if isDryRun(c) {
+		return renderDryRun(c, purgeChanges(clip))
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, userID); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	if err := purgeClip(tx, clip, clipRoot(GetConfig(), user, clip)); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if err := recordAudit(c, tx, auditClipPurge, "clip_id", clip.ID.String()); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	return c.Render(http.StatusNoContent, nil)
+}
+
+// emptyTrash permanently deletes all of the user's trashed clips
+func emptyTrash(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	clips, err := models.FindTrashedClipsByUserID(tx, userID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	if isDryRun(c) {
+		var changes []string
+		for i := range clips {
+			changes = append(changes, purgeChanges(&clips[i])...)
+		}
+		return renderDryRun(c, changes)
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, userID); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	for i := range clips {
+		if err := purgeClip(tx, &clips[i], clipRoot(GetConfig(), user, &clips[i])); err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+		if err := recordAudit(c, tx, auditClipPurge, "clip_id", clips[i].ID.String()); err != nil {
+			return c.Error(http.StatusInternalServerError, err)
+		}
+	}
+
+	return c.Render(http.StatusOK, r.JSON(map[string]int{"purged": len(clips)}))
+}
+
+// moveToTrash moves a clip folder into the user's .trash folder
+func moveToTrash(clipDir, clipPath string) error {
+	src := filepath.Join(clipDir, clipPath)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil // Nothing on disk to move
+	}
+
+	dst := filepath.Join(clipDir, trashDirName, clipPath)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create trash directory: %w", err)
+	}
+	// A stale copy from an earlier delete/restore cycle would block the rename
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+// restoreFromTrash moves a clip folder from .trash back to its original location
+func restoreFromTrash(clipDir, clipPath string) error {
+	src := filepath.Join(clipDir, trashDirName, clipPath)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil // Files were kept in place (delete_files=false) or are gone
+	}
+
+	dst := filepath.Join(clipDir, clipPath)
+	if _, err := os.Stat(dst); err == nil {
+		return os.ErrExist
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+// purgeClip removes a trashed clip's files and database record
+func purgeClip(tx *pop.Connection, clip *models.Clip, clipDir string) error {
+	trashPath := filepath.Join(clipDir, trashDirName, clip.Path)
+	if err := os.RemoveAll(trashPath); err != nil {
+		return fmt.Errorf("failed to delete trashed files at %s: %w", trashPath, err)
+	}
+	// A note's attachments stay in the vault while it is in the trash
+	if clip.IsNote() {
+		if err := removeNoteAttachments(filepath.Join(clipDir, clip.Path), clip.ID); err != nil {
+			return fmt.Errorf("failed to delete the attachments of %s: %w", clip.Path, err)
+		}
+	}
+	for _, table := range []string{"clip_versions", "highlights", "clips_tags", "clip_views", "shares"} {
+		if err := tx.RawQuery("DELETE FROM "+table+" WHERE clip_id = ?", clip.ID).Exec(); err != nil {
+			return err
+		}
+	}
+	return tx.Destroy(clip)
+}
+
+// PurgeExpiredTrash permanently deletes clips trashed longer than the retention
+// period and returns them. With dryRun it only returns the clips it would purge.
+func PurgeExpiredTrash(db *pop.Connection, dryRun bool) (models.Clips, error) {
+	retentionDays := GetConfig().Storage.TrashRetentionDays
+	if retentionDays <= 0 {
+		return nil, nil
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	clips, err := models.FindExpiredTrashedClips(db, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return clips, nil
+	}
+
+	var purged models.Clips
+	for i := range clips {
+		user := &models.User{}
+		if err := db.Find(user, clips[i].UserID); err != nil {
+			log.Printf("trash purge: user %s not found for clip %s: %v", clips[i].UserID, clips[i].ID, err)
+			continue
+		}
+		if err := purgeClip(db, &clips[i], clipRoot(GetConfig(), user, &clips[i])); err != nil {
+			log.Printf("trash purge: failed to purge clip %s: %v", clips[i].ID, err)
+			continue
+		}
+		purged = append(purged, clips[i])
+	}
+
+	return purged, nil
+}
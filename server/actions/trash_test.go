@@ -0,0 +1,52 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+func (as *ActionSuite) Test_ListTrash_Unauthorized() {
+	res := as.JSON("/api/v1/trash").Get()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_RestoreClip_Unauthorized() {
+	res := as.JSON("/api/v1/trash/550e8400-e29b-41d4-a716-446655440000/restore").Post(nil)
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_PurgeTrashedClip_Unauthorized() {
+	res := as.JSON("/api/v1/trash/550e8400-e29b-41d4-a716-446655440000").Delete()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_EmptyTrash_Unauthorized() {
+	res := as.JSON("/api/v1/trash").Delete()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_MoveToTrashAndRestore() {
+	clipDir := as.T().TempDir()
+	clipPath := filepath.Join("web-clips", "20260101_120000_example-com")
+	as.NoError(os.MkdirAll(filepath.Join(clipDir, clipPath), 0755))
+	as.NoError(os.WriteFile(filepath.Join(clipDir, clipPath, "page.md"), []byte("# Page"), 0644))
+
+	as.NoError(moveToTrash(clipDir, clipPath))
+	as.NoDirExists(filepath.Join(clipDir, clipPath))
+	as.FileExists(filepath.Join(clipDir, trashDirName, clipPath, "page.md"))
+
+	as.NoError(restoreFromTrash(clipDir, clipPath))
+	as.FileExists(filepath.Join(clipDir, clipPath, "page.md"))
+	as.NoDirExists(filepath.Join(clipDir, trashDirName, clipPath))
+}
+
+func (as *ActionSuite) Test_RestoreFromTrash_Conflict() {
+	clipDir := as.T().TempDir()
+	clipPath := filepath.Join("web-clips", "20260101_120000_example-com")
+	as.NoError(os.MkdirAll(filepath.Join(clipDir, trashDirName, clipPath), 0755))
+	as.NoError(os.MkdirAll(filepath.Join(clipDir, clipPath), 0755))
+
+	err := restoreFromTrash(clipDir, clipPath)
+	as.True(os.IsExist(err))
+}
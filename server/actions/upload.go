@@ -0,0 +1,260 @@
+package actions
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/config"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop/v6"
+)
+
+// Multipart clip uploads send the ClipPayload as JSON in the "clip" part,
+// with images listed without data, and each image as an "images" file part.
+// Images are streamed to disk as they arrive instead of being held in memory
+// as base64 strings.
+const (
+	multipartClipField  = "clip"
+	multipartImageField = "images"
+)
+
+// maxClipFieldBytes caps the JSON part of a multipart upload (markdown and
+// page HTML are kept in memory either way)
+const maxClipFieldBytes = 64 << 20
+
+// uploadError is a rejected multipart upload and the status to answer with
+type uploadError struct {
+	status int
+	reason string // Counted in clipRejections
+	msg    string
+}
+
+func (e *uploadError) Error() string { return e.msg }
+
+// isMultipartRequest reports whether the request body is multipart/form-data
+func isMultipartRequest(req *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// methodOverride is buffalo's _method form override, except for multipart
+// bodies: it would parse them into memory before the handler can stream them
+func methodOverride(res http.ResponseWriter, req *http.Request) {
+	if isMultipartRequest(req) {
+		return
+	}
+	buffalo.MethodOverride(res, req)
+}
+
+// createClipMultipart handles a multipart POST /api/v1/clips. Images are
+// spooled next to the clip folders so saving them is a rename.
+func createClipMultipart(c buffalo.Context) error {
+	cfg := GetConfig()
+	if cfg == nil {
+		return clipError(c, http.StatusInternalServerError, "Configuration not loaded")
+	}
+
+	tx := c.Value("tx").(*pop.Connection)
+	userID, _ := c.Value("user_id").(string)
+	user := &models.User{}
+	if err := tx.Find(user, userID); err != nil {
+		return rejectClip(c, http.StatusUnauthorized, rejectAuth, "User not found")
+	}
+
+	spool, err := newStagingDir(userClipDir(cfg, user))
+	if err != nil {
+		return clipError(c, http.StatusInternalServerError, "Failed to create clip directory")
+	}
+	defer os.RemoveAll(spool) // Saved images were moved out already
+
+	req, err := readMultipartClip(c.Request(), spool, cfg)
+	if over := bodyOverLimit(c); over != nil {
+		return rejectOversizeBody(c, over)
+	}
+	if err != nil {
+		status, reason := http.StatusBadRequest, rejectInvalidPayload
+		var uerr *uploadError
+		if errors.As(err, &uerr) {
+			status, reason = uerr.status, uerr.reason
+		}
+		return rejectClip(c, status, reason, err.Error())
+	}
+
+	return saveClip(c, req)
+}
+
+// readMultipartClip reads a multipart upload, streaming each image into dir
+// while enforcing the image size limits. Image parts are matched to the
+// payload's images by filename; unlisted ones are added to the payload.
+func readMultipartClip(req *http.Request, dir string, cfg *config.Config) (ClipPayload, error) {
+	var payload ClipPayload
+	mr, err := req.MultipartReader()
+	if err != nil {
+		return payload, &uploadError{http.StatusBadRequest, rejectInvalidPayload, "Invalid multipart body"}
+	}
+
+	var uploads []ImagePayload
+	var total int64
+	sawClip := false
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return payload, &uploadError{http.StatusBadRequest, rejectInvalidPayload, "Invalid multipart body"}
+		}
+
+		switch part.FormName() {
+		case multipartClipField:
+			data, err := io.ReadAll(io.LimitReader(part, maxClipFieldBytes+1))
+			if err != nil {
+				return payload, &uploadError{http.StatusBadRequest, rejectInvalidPayload, "Invalid multipart body"}
+			}
+			if len(data) > maxClipFieldBytes {
+				return payload, &uploadError{http.StatusRequestEntityTooLarge, rejectQuota, fmt.Sprintf("Clip data exceeds %d bytes", maxClipFieldBytes)}
+			}
+			if err := json.Unmarshal(data, &payload); err != nil {
+				return payload, &uploadError{http.StatusBadRequest, rejectInvalidPayload, "Invalid request body"}
+			}
+			sawClip = true
+
+		case multipartImageField:
+			img := ImagePayload{
+				Filename: part.FileName(),
+				file:     filepath.Join(dir, fmt.Sprintf("upload-%d", len(uploads))),
+			}
+			img.size, err = spoolPart(part, img.file, cfg.Images.MaxSizeBytes)
+			if errors.Is(err, errImageTooLarge) {
+				return payload, &uploadError{http.StatusRequestEntityTooLarge, rejectOversizeImage,
+					fmt.Sprintf("Image %s exceeds max size of %d bytes", img.Filename, cfg.Images.MaxSizeBytes)}
+			}
+			if err != nil {
+				return payload, &uploadError{http.StatusBadRequest, rejectInvalidPayload, fmt.Sprintf("Failed to read image: %s", img.Filename)}
+			}
+			total += img.size
+			if total > cfg.Images.MaxTotalBytes {
+				return payload, &uploadError{http.StatusRequestEntityTooLarge, rejectQuota,
+					fmt.Sprintf("Total image size exceeds limit of %d bytes", cfg.Images.MaxTotalBytes)}
+			}
+			uploads = append(uploads, img)
+		}
+		part.Close()
+	}
+
+	if !sawClip {
+		return payload, &uploadError{http.StatusBadRequest, rejectInvalidPayload, fmt.Sprintf("Missing %q part", multipartClipField)}
+	}
+
+	for _, upload := range uploads {
+		matched := false
+		for i := range payload.Images {
+			img := &payload.Images[i]
+			if img.file == "" && img.Data == "" && img.Filename == upload.Filename {
+				img.file, img.size = upload.file, upload.size
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			payload.Images = append(payload.Images, upload)
+		}
+	}
+	for _, img := range payload.Images {
+		if img.file == "" && img.Data == "" && !(cfg.Images.FetchRemote && img.remote()) {
+			return payload, &uploadError{http.StatusBadRequest, rejectInvalidPayload, fmt.Sprintf("No data for image: %s", img.Filename)}
+		}
+	}
+	return payload, nil
+}
+
+// errImageTooLarge is returned by spoolPart when the content exceeds its limit
+var errImageTooLarge = errors.New("image too large")
+
+// spoolPart streams a file part (or any content) to path, flushed to disk,
+// and returns its size
+func spoolPart(src io.Reader, path string, limit int64) (int64, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, io.LimitReader(src, limit+1))
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return n, err
+	}
+	if n > limit {
+		return n, errImageTooLarge
+	}
+	return n, nil
+}
+
+// dataSize returns the decoded size of the image, checking base64 data is valid
+func (img ImagePayload) dataSize() (int64, error) {
+	if img.file != "" {
+		return img.size, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(img.Data)
+	return int64(len(data)), err
+}
+
+// bytes returns the image content
+func (img ImagePayload) bytes() ([]byte, error) {
+	if img.file != "" {
+		return os.ReadFile(img.file)
+	}
+	return base64.StdEncoding.DecodeString(img.Data)
+}
+
+// head returns up to the first n bytes of the image, without loading a
+// spooled upload entirely
+func (img ImagePayload) head(n int) ([]byte, error) {
+	if img.file == "" {
+		data, err := img.bytes()
+		if len(data) > n {
+			data = data[:n]
+		}
+		return data, err
+	}
+	f, err := os.Open(img.file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, n)
+	read, err := io.ReadFull(f, buf)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return buf[:read], err
+}
+
+// save writes the image to path, flushed to disk. A spooled upload is moved
+// there, which needs no copy as spool and clip folders share a filesystem,
+// unless it has to be encrypted.
+func (img ImagePayload) save(path string) error {
+	if img.file != "" && !GetConfig().Storage.Encryption.Enabled {
+		if err := clipFS.Rename(img.file, path); err == nil {
+			return nil
+		}
+	}
+	data, err := img.bytes()
+	if err != nil {
+		return err
+	}
+	return writeFileSync(path, data, 0644)
+}
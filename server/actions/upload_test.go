@@ -0,0 +1,103 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
+)
+
+// multipartClip builds a multipart upload with the payload as the "clip"
+// part and one "images" part per file
+func multipartClip(payload ClipPayload, files map[string][]byte) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for name, data := range files {
+		part, _ := w.CreateFormFile(multipartImageField, name)
+		part.Write(data)
+	}
+	meta, _ := json.Marshal(payload)
+	w.WriteField(multipartClipField, string(meta))
+	w.Close()
+	return body, w.FormDataContentType()
+}
+
+// stagingDirs lists leftover staging folders in the storage root
+func stagingDirs(kit *testkit.Kit) []string {
+	matches, _ := filepath.Glob(filepath.Join(kit.StorageRoot, "web-clips", ".staging-*"))
+	return matches
+}
+
+func (as *ActionSuite) Test_IsMultipartRequest() {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/clips", nil)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=xyz")
+	as.True(isMultipartRequest(req))
+
+	req.Header.Set("Content-Type", "application/json")
+	as.False(isMultipartRequest(req))
+}
+
+func (as *ActionSuite) Test_CreateClip_Multipart() {
+	kit := testkit.New(as.T())
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	payload := ClipPayload{
+		Title:    "Upload",
+		URL:      "https://example.com/upload",
+		Markdown: "![](media/photo.png)",
+		Mode:     "article",
+		Images:   []ImagePayload{{Filename: "photo.png", OriginalURL: "https://example.com/photo.png"}},
+	}
+	body, contentType := multipartClip(payload, map[string][]byte{
+		"photo.png": []byte(pngMagic + "png bytes"),
+		"extra.jpg": []byte("\xff\xd8\xffjpg bytes"), // Not listed in the payload
+	})
+
+	var resp ClipResponse
+	res := client.Upload("/api/v1/clips", body, contentType)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&resp)
+
+	mediaDir := filepath.Join(kit.StorageRoot, filepath.Dir(resp.Path), "media")
+	data, err := os.ReadFile(filepath.Join(mediaDir, "photo.png"))
+	as.NoError(err)
+	as.Equal(pngMagic+"png bytes", string(data))
+	data, err = os.ReadFile(filepath.Join(mediaDir, "extra.jpg"))
+	as.NoError(err)
+	as.Equal("\xff\xd8\xffjpg bytes", string(data))
+
+	as.Empty(stagingDirs(kit))
+}
+
+func (as *ActionSuite) Test_CreateClip_MultipartLimits() {
+	kit := testkit.New(as.T())
+	kit.Config.Images.MaxSizeBytes = 4
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	payload := ClipPayload{Title: "Big", URL: "https://example.com/big", Mode: "article"}
+	body, contentType := multipartClip(payload, map[string][]byte{"big.png": []byte("too many bytes")})
+	res := client.Upload("/api/v1/clips", body, contentType)
+	as.Equal(http.StatusRequestEntityTooLarge, res.Code, res.Body.String())
+
+	// No payload part
+	body = &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	w.WriteField("title", "No clip part")
+	w.Close()
+	res = client.Upload("/api/v1/clips", body, w.FormDataContentType())
+	as.Equal(http.StatusBadRequest, res.Code)
+
+	// Listed image without a file part or data
+	payload.Images = []ImagePayload{{Filename: "missing.png"}}
+	body, contentType = multipartClip(payload, nil)
+	res = client.Upload("/api/v1/clips", body, contentType)
+	as.Equal(http.StatusBadRequest, res.Code)
+	as.Contains(res.Body.String(), "missing.png")
+
+	as.Empty(stagingDirs(kit))
+}
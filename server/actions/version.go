@@ -0,0 +1,15 @@
+package actions
+
+import (
+	"net/http"
+
+	"server/internal/buildinfo"
+
+	"github.com/gobuffalo/buffalo"
+)
+
+// getVersion returns the build of the server, for bug reports
+// GET /api/v1/version
+func getVersion(c buffalo.Context) error {
+	return c.Render(http.StatusOK, r.JSON(buildinfo.Get()))
+}
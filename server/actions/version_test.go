@@ -0,0 +1,24 @@
+package actions
+
+import (
+	"net/http"
+	"runtime"
+
+	"server/internal/buildinfo"
+	"server/internal/testkit"
+)
+
+func (as *ActionSuite) Test_Version() {
+	kit := testkit.New(as.T())
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	var info buildinfo.Info
+	res := client.Get("/api/v1/version")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&info)
+	as.Equal(buildinfo.Version, info.Version)
+	as.Equal(runtime.Version(), info.GoVersion)
+
+	client.Token = ""
+	as.Equal(http.StatusUnauthorized, client.Get("/api/v1/version").Code)
+}
@@ -0,0 +1,346 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"server/internal/textdiff"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// versionsDirName is the folder (under a clip folder) holding earlier captures
+const versionsDirName = "versions"
+
+// ClipVersionResponse describes one capture of a clip
+type ClipVersionResponse struct {
+	Version   int       `json:"version"`
+	Title     string    `json:"title"`
+	Mode      string    `json:"mode"`
+	Path      string    `json:"path"` // Relative to the user's clip directory
+	Current   bool      `json:"current"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListVersionsResponse is the response from GET /api/v1/clips/{id}/versions
+type ListVersionsResponse struct {
+	ClipID   string                `json:"clip_id"`
+	Versions []ClipVersionResponse `json:"versions"`
+}
+
+// DiffResponse is the response from GET /api/v1/clips/{id}/versions/diff
+type DiffResponse struct {
+	ClipID string `json:"clip_id"`
+	From   int    `json:"from"`
+	To     int    `json:"to"`
+	Diff   string `json:"diff"` // Unified diff of the markdown content; empty when identical
+}
+
+// listClipVersions returns every capture recorded for a clip
+func listClipVersions(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	clipID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid clip ID"))
+	}
+
+	// Fetch clip with ownership check
+	clip, err := models.FindClipByIDAndUser(tx, clipID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("clip not found"))
+	}
+
+	versions, err := clipVersions(tx, clip)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	resp := ListVersionsResponse{
+		ClipID:   clip.ID.String(),
+		Versions: make([]ClipVersionResponse, len(versions)),
+	}
+	for i, v := range versions {
+		resp.Versions[i] = ClipVersionResponse{
+			Version:   v.Version,
+			Title:     v.Title,
+			Mode:      v.Mode,
+			Path:      filepath.Join(clip.Path, v.Path),
+			Current:   v.Path == "",
+			CreatedAt: v.CreatedAt,
+		}
+	}
+
+	return c.Render(http.StatusOK, r.JSON(resp))
+}
+
+// diffClipVersions compares the markdown of two captures (defaults to the two most recent)
+func diffClipVersions(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	clipID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid clip ID"))
+	}
+
+	// Fetch clip with ownership check
+	clip, err := models.FindClipByIDAndUser(tx, clipID, userID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("clip not found"))
+	}
+
+	versions, err := clipVersions(tx, clip)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	latest := versions[len(versions)-1].Version
+	to, err := parseVersionParam(c.Param("to"), latest)
+	if err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+	from, err := parseVersionParam(c.Param("from"), to-1)
+	if err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
+
+	fromVersion := findVersion(versions, from)
+	toVersion := findVersion(versions, to)
+	if fromVersion == nil || toVersion == nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("version not found"))
+	}
+
+	user := &models.User{}
+	if err := tx.Find(user, userID); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	folderPath := filepath.Join(clipRoot(GetConfig(), user, clip), clip.Path)
+
+	fromContent, err := readClipMarkdown(filepath.Join(folderPath, fromVersion.Path))
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("content for version %d not found", from))
+	}
+	toContent, err := readClipMarkdown(filepath.Join(folderPath, toVersion.Path))
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("content for version %d not found", to))
+	}
+
+	diff, err := textdiff.Unified(
+		stripFrontmatter(fromContent),
+		stripFrontmatter(toContent),
+		fmt.Sprintf("v%d", from),
+		fmt.Sprintf("v%d", to),
+		3,
+	)
+	if err != nil {
+		if errors.Is(err, textdiff.ErrTooLarge) {
+			return c.Error(http.StatusUnprocessableEntity, err)
+		}
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	return c.Render(http.StatusOK, r.JSON(DiffResponse{
+		ClipID: clip.ID.String(),
+		From:   from,
+		To:     to,
+		Diff:   diff,
+	}))
+}
+
+// recordClipVersion writes the new capture of a clip in a staging folder,
+// then swaps it in, the current capture going to versions/vN
+func recordClipVersion(c buffalo.Context, tx *pop.Connection, clipDir string, clip *models.Clip, req ClipPayload, text clipText) error {
+	versions, err := clipVersions(tx, clip)
+	if err != nil {
+		return clipError(c, http.StatusInternalServerError, "Failed to load clip versions")
+	}
+	current := versions[len(versions)-1]
+	current.Path = filepath.Join(versionsDirName, fmt.Sprintf("v%d", current.Version))
+
+	staging, err := newStagingDir(clipDir)
+	if err != nil {
+		return clipError(c, http.StatusInternalServerError, "Failed to create clip directory")
+	}
+	fileName, err := writeClipFiles(staging, req, text.Recognized)
+	if err != nil {
+		os.RemoveAll(staging)
+		return clipError(c, http.StatusInternalServerError, err.Error())
+	}
+	undo, err := swapCapture(staging, filepath.Join(clipDir, clip.Path), current.Path)
+	if err != nil {
+		os.RemoveAll(staging)
+		c.Logger().Errorf("Failed to archive clip version: %v", err)
+		return clipError(c, http.StatusInternalServerError, "Failed to archive previous version")
+	}
+	// The rows are committed after the response; restore the previous
+	// capture if that fails
+	onRollback(c, undo)
+
+	// Legacy clips have no version rows yet, so the synthesized one is created here
+	if current.ID == uuid.Nil {
+		current.ID = uuid.Must(uuid.NewV4())
+		err = tx.Create(&current)
+	} else {
+		err = tx.Update(&current)
+	}
+	if err != nil {
+		c.Logger().Errorf("Failed to save clip version: %v", err)
+		return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+	}
+
+	clip.Title = req.Title
+	clip.Mode = req.Mode
+	clip.NormalizedURL = normalizeURL(req.URL)
+	clip.Domain = clipDomain(req.URL)
+	clip.Notes = nulls.NewString(req.Notes)
+	text.apply(clip)
+	applyLocation(clip, req)
+	applyMetadata(clip, req)
+	applyLanguage(clip, req)
+	if err := tx.Update(clip); err != nil {
+		c.Logger().Errorf("Failed to update clip metadata: %v", err)
+		return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+	}
+	if err := models.SetClipTags(tx, clip, req.Tags); err != nil {
+		c.Logger().Errorf("Failed to save clip tags: %v", err)
+		return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+	}
+
+	next := newClipVersion(clip, current.Version+1)
+	if err := tx.Create(next); err != nil {
+		c.Logger().Errorf("Failed to save clip version: %v", err)
+		return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+	}
+	refreshClipSidecarOnCommit(c, tx, clip)
+	if err := clipSaved(c, tx, clip, models.EventClipUpdated, auditClipUpdate); err != nil {
+		c.Logger().Errorf("Failed to record clip: %v", err)
+		return clipError(c, http.StatusInternalServerError, "Failed to save clip")
+	}
+
+	return c.Render(http.StatusOK, r.JSON(ClipResponse{
+		Success: true,
+		Path:    filepath.Join(clip.Path, fileName),
+		ID:      clip.ID.String(),
+		Version: next.Version,
+	}))
+}
+
+// newClipVersion builds the version row for a clip's current capture
+func newClipVersion(clip *models.Clip, version int) *models.ClipVersion {
+	return &models.ClipVersion{
+		ID:      uuid.Must(uuid.NewV4()),
+		ClipID:  clip.ID,
+		Version: version,
+		Title:   clip.Title,
+		Mode:    clip.Mode,
+	}
+}
+
+// clipVersions returns a clip's versions, synthesizing version 1 for clips
+// created before versioning existed
+func clipVersions(tx *pop.Connection, clip *models.Clip) (models.ClipVersions, error) {
+	versions, err := models.FindClipVersions(tx, clip.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		versions = models.ClipVersions{{
+			ClipID:    clip.ID,
+			Version:   1,
+			Title:     clip.Title,
+			Mode:      clip.Mode,
+			CreatedAt: clip.CreatedAt,
+			UpdatedAt: clip.CreatedAt,
+		}}
+	}
+	return versions, nil
+}
+
+// swapCapture replaces the capture of a clip folder by the one staged,
+// moving the current capture to archive (versions/vN) and the versions
+// folder along. The complete folder is assembled in staging and renamed into
+// place. undo puts the previous capture back, and removes the new one.
+func swapCapture(staging, folderPath, archive string) (undo func(), err error) {
+	var steps []func()
+	undo = func() {
+		for i := len(steps) - 1; i >= 0; i-- {
+			steps[i]()
+		}
+		os.RemoveAll(staging)
+	}
+	defer func() {
+		if err != nil {
+			for i := len(steps) - 1; i >= 0; i-- {
+				steps[i]()
+			}
+		}
+	}()
+
+	versionsDir := filepath.Join(folderPath, versionsDirName)
+	stagedVersions := filepath.Join(staging, versionsDirName)
+	if _, statErr := os.Stat(versionsDir); statErr == nil {
+		if err = clipFS.Rename(versionsDir, stagedVersions); err != nil {
+			return nil, err
+		}
+		steps = append(steps, func() { os.Rename(stagedVersions, versionsDir) })
+	} else if err = clipFS.MkdirAll(stagedVersions, 0755); err != nil {
+		return nil, err
+	}
+
+	// The rest of the folder is the current capture
+	archived := filepath.Join(staging, archive)
+	if err = clipFS.MkdirAll(filepath.Dir(archived), 0755); err != nil {
+		return nil, err
+	}
+	if err = clipFS.Rename(folderPath, archived); err != nil {
+		return nil, err
+	}
+	steps = append(steps, func() { os.Rename(archived, folderPath) })
+
+	if err = publishClipFolder(staging, folderPath); err != nil {
+		return nil, err
+	}
+	steps = append(steps, func() { os.Rename(folderPath, staging) })
+	return undo, nil
+}
+
+// parseVersionParam parses a version query parameter, falling back to def when empty
+func parseVersionParam(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil || v < 1 {
+		return 0, fmt.Errorf("invalid version: %s", value)
+	}
+	return v, nil
+}
+
+// findVersion returns the version with the given number, or nil
+func findVersion(versions models.ClipVersions, version int) *models.ClipVersion {
+	for i := range versions {
+		if versions[i].Version == version {
+			return &versions[i]
+		}
+	}
+	return nil
+}
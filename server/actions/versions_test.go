@@ -0,0 +1,102 @@
+package actions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"server/internal/testkit"
+	"server/models"
+)
+
+func (as *ActionSuite) Test_ListClipVersions_Unauthorized() {
+	res := as.JSON("/api/v1/clips/550e8400-e29b-41d4-a716-446655440000/versions").Get()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_DiffClipVersions_Unauthorized() {
+	res := as.JSON("/api/v1/clips/550e8400-e29b-41d4-a716-446655440000/versions/diff").Get()
+	as.Equal(http.StatusUnauthorized, res.Code)
+}
+
+func (as *ActionSuite) Test_SwapCapture() {
+	folderPath := filepath.Join(as.T().TempDir(), "clip")
+	staging := filepath.Join(filepath.Dir(folderPath), ".staging-1")
+	as.NoError(os.MkdirAll(filepath.Join(folderPath, "media"), 0755))
+	as.NoError(os.MkdirAll(filepath.Join(folderPath, versionsDirName, "v1"), 0755))
+	as.NoError(os.WriteFile(filepath.Join(folderPath, "page.md"), []byte("# Second"), 0644))
+	as.NoError(os.WriteFile(filepath.Join(folderPath, "media", "a.png"), []byte("png"), 0644))
+	as.NoError(os.WriteFile(filepath.Join(folderPath, versionsDirName, "v1", "page.md"), []byte("# First"), 0644))
+	as.NoError(os.MkdirAll(staging, 0755))
+	as.NoError(os.WriteFile(filepath.Join(staging, "page.md"), []byte("# Third"), 0644))
+
+	undo, err := swapCapture(staging, folderPath, filepath.Join(versionsDirName, "v2"))
+	as.Require().NoError(err)
+	read := func(path string) string {
+		data, err := os.ReadFile(filepath.Join(folderPath, path))
+		as.NoError(err)
+		return string(data)
+	}
+	as.Equal("# Third", read("page.md"))
+	as.Equal("# Second", read("versions/v2/page.md"))
+	as.Equal("png", read("versions/v2/media/a.png"))
+	as.Equal("# First", read("versions/v1/page.md"))
+	as.NoDirExists(filepath.Join(folderPath, "media"))
+	as.NoDirExists(staging)
+
+	// Undone when the transaction rolls back
+	undo()
+	as.Equal("# Second", read("page.md"))
+	as.Equal("png", read("media/a.png"))
+	as.Equal("# First", read("versions/v1/page.md"))
+	as.NoDirExists(filepath.Join(folderPath, versionsDirName, "v2"))
+	as.NoDirExists(staging)
+}
+
+func (as *ActionSuite) Test_Reclip_RecordsVersion() {
+	kit := testkit.New(as.T())
+	kit.Config.Clips.DuplicateWindowSeconds = -1
+	user := kit.CreateUser()
+	client := kit.Client(newKitApp(kit), user)
+	clipPage := func(markdown string) ClipResponse {
+		var resp ClipResponse
+		res := client.Post("/api/v1/clips", ClipPayload{Title: "Page", URL: "https://example.com/page", Mode: "article", Markdown: markdown})
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		res.JSON(&resp)
+		return resp
+	}
+	first := clipPage("First capture")
+	second := clipPage("Second capture")
+	as.Equal(first.ID, second.ID)
+	as.Equal(2, second.Version)
+
+	clip := &models.Clip{}
+	as.NoError(kit.DB.Find(clip, first.ID))
+	folderPath := filepath.Join(userClipDir(kit.Config, user), clip.Path)
+	current, err := readClipMarkdown(folderPath)
+	as.NoError(err)
+	as.Contains(current, "Second capture")
+	previous, err := readClipMarkdown(filepath.Join(folderPath, versionsDirName, "v1"))
+	as.NoError(err)
+	as.Contains(previous, "First capture")
+
+	// Re-clips are announced like new clips
+	count, err := kit.DB.Where("user_id = ? AND type = ?", user.ID, models.EventClipUpdated).Count(&models.Event{})
+	as.NoError(err)
+	as.Equal(1, count)
+}
+
+func (as *ActionSuite) Test_ParseVersionParam() {
+	v, err := parseVersionParam("", 3)
+	as.NoError(err)
+	as.Equal(3, v)
+
+	v, err = parseVersionParam("2", 3)
+	as.NoError(err)
+	as.Equal(2, v)
+
+	_, err = parseVersionParam("0", 3)
+	as.Error(err)
+	_, err = parseVersionParam("abc", 3)
+	as.Error(err)
+}
@@ -0,0 +1,511 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"server/internal/safehttp"
+	"server/internal/webhook"
+	"server/models"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// maxDeliveryLog caps the deliveries returned by GET /webhooks/{id}/deliveries
+const maxDeliveryLog = 100
+
+// webhookRetryWindow is how recent a failed delivery must be to be retried,
+// so enabling retries doesn't resend old failures
+const webhookRetryWindow = 24 * time.Hour
+
+// WebhookPayload is the request body for registering a webhook
+type WebhookPayload struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"` // Defaults to every event
+}
+
+// WebhookResponse is the API representation of a webhook. Secret is only
+// returned when the webhook is created and when its secret is rotated.
+type WebhookResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	Rotating  bool      `json:"rotating"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SecretRotationPayload is the request body for rotating a webhook secret
+type SecretRotationPayload struct {
+	GraceHours *int `json:"grace_hours"` // Defaults to webhooks.secret_grace_hours; 0 drops the old secret now
+}
+
+// SecretRotationResponse describes a webhook's secret rotation. Secret is
+// only set in the response to a rotation.
+type SecretRotationResponse struct {
+	Rotating                bool       `json:"rotating"`
+	Secret                  string     `json:"secret,omitempty"`
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"`
+}
+
+// VerifyPayload is a received delivery to check: the raw body and the
+// Webclipper-Signature header exactly as the receiver got them
+type VerifyPayload struct {
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// VerifyResponse tells whether a signature is valid and which secret made it
+type VerifyResponse struct {
+	Valid  bool   `json:"valid"`
+	Secret string `json:"secret,omitempty"` // "current" or "previous"
+	Error  string `json:"error,omitempty"`
+}
+
+// WebhookDeliveryResponse is the API representation of a webhook delivery
+type WebhookDeliveryResponse struct {
+	ID             string          `json:"id"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	ReplayOf       string          `json:"replay_of,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// webhookEvent is the body posted to receivers. ID identifies the event and
+// is kept when a delivery is replayed, so receivers can drop duplicates.
+type webhookEvent struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// listWebhooks returns the user's webhooks
+func listWebhooks(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	webhooks, err := models.FindWebhooksByUserID(tx, userID)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	resp := make([]WebhookResponse, len(webhooks))
+	for i := range webhooks {
+		resp[i] = webhookToResponse(&webhooks[i])
+	}
+
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"webhooks": resp,
+	}))
+}
+
+// createWebhook registers a webhook and returns its signing secret
+func createWebhook(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	var req WebhookPayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+
+	target, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return c.Error(http.StatusUnprocessableEntity, fmt.Errorf("url must be an http or https URL"))
+	}
+	if !GetConfig().Webhooks.AllowPrivate && isInternalHost(target.Hostname()) {
+		return c.Error(http.StatusUnprocessableEntity, fmt.Errorf("url must not point to an internal address"))
+	}
+	if len(req.Events) == 0 {
+		req.Events = models.WebhookEvents
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	hook := &models.Webhook{
+		ID:     uuid.Must(uuid.NewV4()),
+		UserID: userID,
+		URL:    target.String(),
+		Events: strings.Join(req.Events, ","),
+		Secret: secret,
+	}
+	verrs, err := tx.ValidateAndCreate(hook)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	resp := webhookToResponse(hook)
+	resp.Secret = hook.Secret
+	return c.Render(http.StatusCreated, r.JSON(resp))
+}
+
+// isInternalHost reports whether host is localhost or an IP address the
+// server must not post to. Names resolving to one are refused when
+// delivering (safehttp).
+func isInternalHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && !safehttp.Allowed(addr)
+}
+
+// deleteWebhook removes a webhook and its delivery log
+func deleteWebhook(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	hook, err := findUserWebhook(c, tx)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.RawQuery("DELETE FROM webhook_deliveries WHERE webhook_id = ?", hook.ID).Exec(); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if err := tx.Destroy(hook); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	return c.Render(http.StatusNoContent, nil)
+}
+
+// getWebhookSecretRotation reports whether a rotated-out secret still signs deliveries
+func getWebhookSecretRotation(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	hook, err := findUserWebhook(c, tx)
+	if err != nil {
+		return err
+	}
+
+	return c.Render(http.StatusOK, r.JSON(secretRotationToResponse(hook, time.Now())))
+}
+
+// rotateWebhookSecret replaces a webhook's secret. Deliveries are signed with
+// both secrets during the grace period so receivers can switch without
+// rejecting events; rotating again during it drops the oldest secret.
+func rotateWebhookSecret(c buffalo.Context) error {
+	cfg := GetConfig()
+	if cfg == nil {
+		return c.Error(http.StatusInternalServerError, fmt.Errorf("configuration not loaded"))
+	}
+
+	tx := c.Value("tx").(*pop.Connection)
+	hook, err := findUserWebhook(c, tx)
+	if err != nil {
+		return err
+	}
+
+	var req SecretRotationPayload
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(&req); err != nil {
+			return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		}
+	}
+	grace := cfg.Webhooks.SecretGraceHours
+	if req.GraceHours != nil {
+		grace = *req.GraceHours
+	}
+	if grace < 0 {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("grace_hours must not be negative"))
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	now := time.Now()
+	if grace > 0 {
+		hook.PreviousSecret = nulls.NewString(hook.Secret)
+		hook.PreviousSecretExpiresAt = nulls.NewTime(now.Add(time.Duration(grace) * time.Hour))
+	} else {
+		hook.PreviousSecret = nulls.String{}
+		hook.PreviousSecretExpiresAt = nulls.Time{}
+	}
+	hook.Secret = secret
+
+	if err := tx.Update(hook); err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	resp := secretRotationToResponse(hook, now)
+	resp.Secret = hook.Secret
+	return c.Render(http.StatusOK, r.JSON(resp))
+}
+
+// verifyWebhookSignature checks a delivery as a receiver got it against the
+// webhook's secrets, to debug signature verification in receivers
+func verifyWebhookSignature(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	hook, err := findUserWebhook(c, tx)
+	if err != nil {
+		return err
+	}
+
+	var req VerifyPayload
+	if err := c.Bind(&req); err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid request body"))
+	}
+	if req.Signature == "" {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("signature is required"))
+	}
+
+	now := time.Now()
+	secrets := hook.Secrets(now)
+	matched, err := webhook.Verify(req.Signature, []byte(req.Payload), now, secrets...)
+	if err != nil {
+		return c.Render(http.StatusOK, r.JSON(VerifyResponse{Valid: false, Error: err.Error()}))
+	}
+
+	resp := VerifyResponse{Valid: true, Secret: "current"}
+	if matched > 0 {
+		resp.Secret = "previous"
+	}
+	return c.Render(http.StatusOK, r.JSON(resp))
+}
+
+// listWebhookDeliveries returns a webhook's recent deliveries, newest first.
+// ?status=failed lists only failed ones.
+func listWebhookDeliveries(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	hook, err := findUserWebhook(c, tx)
+	if err != nil {
+		return err
+	}
+
+	status := c.Param("status")
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliverySucceeded, models.WebhookDeliveryFailed:
+	default:
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid status: %s", status))
+	}
+
+	deliveries, err := models.FindWebhookDeliveries(tx, hook.ID, status, maxDeliveryLog)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+
+	resp := make([]WebhookDeliveryResponse, len(deliveries))
+	for i := range deliveries {
+		resp[i] = webhookDeliveryToResponse(&deliveries[i])
+	}
+
+	return c.Render(http.StatusOK, r.JSON(map[string]interface{}{
+		"deliveries": resp,
+	}))
+}
+
+// replayWebhookDelivery sends a failed delivery's payload again, signed with
+// the current secrets, as a new delivery
+func replayWebhookDelivery(c buffalo.Context) error {
+	tx := c.Value("tx").(*pop.Connection)
+	hook, err := findUserWebhook(c, tx)
+	if err != nil {
+		return err
+	}
+
+	deliveryID, err := uuid.FromString(c.Param("delivery_id"))
+	if err != nil {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("invalid delivery ID"))
+	}
+	original, err := models.FindWebhookDelivery(tx, deliveryID, hook.ID)
+	if err != nil {
+		return c.Error(http.StatusNotFound, fmt.Errorf("delivery not found"))
+	}
+	if original.Status != models.WebhookDeliveryFailed {
+		return c.Error(http.StatusConflict, fmt.Errorf("only failed deliveries can be replayed"))
+	}
+
+	replay := newWebhookReplay(original)
+	verrs, err := tx.ValidateAndCreate(replay)
+	if err != nil {
+		return c.Error(http.StatusInternalServerError, err)
+	}
+	if verrs.HasAny() {
+		return c.Error(http.StatusUnprocessableEntity, verrs)
+	}
+
+	db := appDB(c)
+	onCommit(c, func() { goBackground(func() { runWebhookDelivery(db, replay.ID) }) })
+
+	return c.Render(http.StatusAccepted, r.JSON(webhookDeliveryToResponse(replay)))
+}
+
+// newWebhookReplay returns a pending delivery retrying original
+func newWebhookReplay(original *models.WebhookDelivery) *models.WebhookDelivery {
+	return &models.WebhookDelivery{
+		ID:        uuid.Must(uuid.NewV4()),
+		WebhookID: original.WebhookID,
+		Event:     original.Event,
+		Payload:   original.Payload,
+		EventID:   original.EventID,
+		Status:    models.WebhookDeliveryPending,
+		ReplayOf:  nulls.NewUUID(original.ID),
+	}
+}
+
+// RetryWebhookDeliveries replays the deliveries that failed in the last
+// webhookRetryWindow, until their event has had webhooks.max_attempts
+// deliveries, and returns how many it sent again
+func RetryWebhookDeliveries(db *pop.Connection) (int, error) {
+	maxAttempts := GetConfig().Webhooks.MaxAttempts
+	if maxAttempts <= 1 {
+		return 0, nil
+	}
+
+	deliveries, err := models.FindRetryableWebhookDeliveries(db, time.Now().Add(-webhookRetryWindow))
+	if err != nil {
+		return 0, err
+	}
+
+	retried := 0
+	for i := range deliveries {
+		attempts, err := deliveries[i].Attempts(db)
+		if err != nil {
+			log.Printf("webhook delivery %s: failed to count attempts: %v", deliveries[i].ID, err)
+			continue
+		}
+		if attempts >= maxAttempts {
+			continue
+		}
+		replay := newWebhookReplay(&deliveries[i])
+		if err := db.Create(replay); err != nil {
+			return retried, err
+		}
+		runWebhookDelivery(db, replay.ID)
+		retried++
+	}
+	return retried, nil
+}
+
+// findUserWebhook loads the {id} webhook of the current user, or renders the error
+func findUserWebhook(c buffalo.Context, tx *pop.Connection) (*models.Webhook, error) {
+	userIDStr := c.Value("user_id").(string)
+	userID, err := uuid.FromString(userIDStr)
+	if err != nil {
+		return nil, c.Error(http.StatusUnauthorized, fmt.Errorf("invalid user"))
+	}
+
+	webhookID, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return nil, c.Error(http.StatusBadRequest, fmt.Errorf("invalid webhook ID"))
+	}
+
+	hook, err := models.FindWebhookByIDAndUser(tx, webhookID, userID)
+	if err != nil {
+		return nil, c.Error(http.StatusNotFound, fmt.Errorf("webhook not found"))
+	}
+	return hook, nil
+}
+
+// runWebhookDelivery posts a delivery to its webhook and records the outcome
+func runWebhookDelivery(db *pop.Connection, deliveryID uuid.UUID) {
+	cfg := GetConfig()
+	if cfg == nil {
+		log.Printf("webhook delivery %s: configuration not loaded", deliveryID)
+		return
+	}
+
+	delivery := &models.WebhookDelivery{}
+	if err := db.Find(delivery, deliveryID); err != nil {
+		log.Printf("webhook delivery %s: failed to load: %v", deliveryID, err)
+		return
+	}
+	hook := &models.Webhook{}
+	if err := db.Find(hook, delivery.WebhookID); err != nil {
+		log.Printf("webhook delivery %s: failed to load webhook: %v", deliveryID, err)
+		return
+	}
+
+	result := webhook.NewSender(cfg.Webhooks).Send(webhook.Request{
+		URL:        hook.URL,
+		Event:      delivery.Event,
+		DeliveryID: delivery.ID.String(),
+		Body:       []byte(delivery.Payload),
+		Secrets:    hook.Secrets(time.Now()),
+	})
+
+	if result.StatusCode != 0 {
+		delivery.ResponseStatus = nulls.NewInt(result.StatusCode)
+	}
+	if result.Err != nil {
+		log.Printf("webhook delivery %s failed: %v", deliveryID, result.Err)
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.Error = nulls.NewString(result.Err.Error())
+	} else {
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.DeliveredAt = nulls.NewTime(time.Now())
+	}
+
+	if err := db.Update(delivery); err != nil {
+		log.Printf("webhook delivery %s: failed to record result: %v", deliveryID, err)
+	}
+}
+
+// webhookToResponse converts a webhook model to its API representation
+func webhookToResponse(w *models.Webhook) WebhookResponse {
+	return WebhookResponse{
+		ID:        w.ID.String(),
+		URL:       w.URL,
+		Events:    w.EventList(),
+		Rotating:  w.Rotating(time.Now()),
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+// secretRotationToResponse describes a webhook's secret rotation at now
+func secretRotationToResponse(w *models.Webhook, now time.Time) SecretRotationResponse {
+	resp := SecretRotationResponse{Rotating: w.Rotating(now)}
+	if resp.Rotating {
+		resp.PreviousSecretExpiresAt = &w.PreviousSecretExpiresAt.Time
+	}
+	return resp
+}
+
+// webhookDeliveryToResponse converts a delivery model to its API representation
+func webhookDeliveryToResponse(d *models.WebhookDelivery) WebhookDeliveryResponse {
+	resp := WebhookDeliveryResponse{
+		ID:             d.ID.String(),
+		Event:          d.Event,
+		Status:         d.Status,
+		ResponseStatus: d.ResponseStatus.Int,
+		Error:          d.Error.String,
+		Payload:        json.RawMessage(d.Payload),
+		CreatedAt:      d.CreatedAt,
+	}
+	if d.ReplayOf.Valid {
+		resp.ReplayOf = d.ReplayOf.UUID.String()
+	}
+	if d.DeliveredAt.Valid {
+		resp.DeliveredAt = &d.DeliveredAt.Time
+	}
+	return resp
+}
@@ -0,0 +1,214 @@
+package actions
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"server/internal/testkit"
+	"server/internal/webhook"
+	"server/models"
+)
+
+// webhookReceiver records the deliveries it gets and answers with status
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	bodies   [][]byte
+	headers  []http.Header
+	received chan struct{}
+}
+
+func newWebhookReceiver(as *ActionSuite) (*webhookReceiver, string) {
+	rec := &webhookReceiver{status: http.StatusOK, received: make(chan struct{}, 10)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		rec.bodies = append(rec.bodies, body)
+		rec.headers = append(rec.headers, r.Header.Clone())
+		status := rec.status
+		rec.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	as.T().Cleanup(srv.Close)
+	return rec, srv.URL
+}
+
+func (rec *webhookReceiver) setStatus(status int) {
+	rec.mu.Lock()
+	rec.status = status
+	rec.mu.Unlock()
+}
+
+// waitForDelivery waits until a delivery is no longer pending and returns it
+func (as *ActionSuite) waitForDelivery(kit *testkit.Kit, hook WebhookResponse, status string) models.WebhookDelivery {
+	var found models.WebhookDelivery
+	as.Eventually(func() bool {
+		deliveries := models.WebhookDeliveries{}
+		if err := kit.DB.Where("webhook_id = ? AND status = ?", hook.ID, status).Order("created_at DESC").All(&deliveries); err != nil || len(deliveries) == 0 {
+			return false
+		}
+		found = deliveries[0]
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	return found
+}
+
+func (as *ActionSuite) Test_Webhooks_DeliverSignedEvents() {
+	kit := testkit.New(as.T())
+	kit.Config.Webhooks.AllowPrivate = true // Receivers are httptest servers
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+	rec, url := newWebhookReceiver(as)
+
+	var hook WebhookResponse
+	res := client.Post("/api/v1/webhooks", WebhookPayload{URL: url})
+	as.Equal(http.StatusCreated, res.Code, res.Body.String())
+	res.JSON(&hook)
+	as.NotEmpty(hook.Secret)
+	as.Equal(models.WebhookEvents, hook.Events)
+
+	res = client.Post("/api/v1/clips", ClipPayload{Title: "Hooked", URL: "https://example.com/hooked", Mode: "article"})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+
+	as.waitForDelivery(kit, hook, models.WebhookDeliverySucceeded)
+	rec.mu.Lock()
+	as.Len(rec.bodies, 1)
+	body, header := rec.bodies[0], rec.headers[0]
+	rec.mu.Unlock()
+	as.Contains(string(body), `"title":"Hooked"`)
+	as.Equal(models.EventClipCreated, header.Get(webhook.EventHeader))
+	_, err := webhook.Verify(header.Get(webhook.SignatureHeader), body, time.Now(), hook.Secret)
+	as.NoError(err)
+
+	// The secret is never listed
+	res = client.Get("/api/v1/webhooks")
+	as.Equal(http.StatusOK, res.Code)
+	as.NotContains(res.Body.String(), hook.Secret)
+
+	// Rejected clips are not announced
+	res = client.Post("/api/v1/clips", ClipPayload{URL: "https://example.com", Dedupe: "sometimes"})
+	as.Equal(http.StatusBadRequest, res.Code)
+	time.Sleep(50 * time.Millisecond)
+	count, err := kit.DB.Where("webhook_id = ?", hook.ID).Count(&models.WebhookDelivery{})
+	as.NoError(err)
+	as.Equal(1, count)
+}
+
+func (as *ActionSuite) Test_Webhooks_SecretRotation() {
+	kit := testkit.New(as.T())
+	kit.Config.Webhooks.AllowPrivate = true // Receivers are httptest servers
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	var hook WebhookResponse
+	client.Post("/api/v1/webhooks", WebhookPayload{URL: "https://example.com/hook"}).JSON(&hook)
+	path := "/api/v1/webhooks/" + hook.ID
+
+	var rotation SecretRotationResponse
+	res := client.Get(path + "/secret-rotation")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&rotation)
+	as.False(rotation.Rotating)
+	as.Empty(rotation.Secret)
+
+	res = client.Post(path+"/secret-rotation", nil)
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&rotation)
+	as.True(rotation.Rotating)
+	as.NotEmpty(rotation.Secret)
+	as.NotEqual(hook.Secret, rotation.Secret)
+	as.NotNil(rotation.PreviousSecretExpiresAt)
+
+	payload := `{"id":"evt","event":"clip.created"}`
+	verify := func(signature string) VerifyResponse {
+		var resp VerifyResponse
+		res := client.Post(path+"/verify", VerifyPayload{Payload: payload, Signature: signature})
+		as.Equal(http.StatusOK, res.Code, res.Body.String())
+		res.JSON(&resp)
+		return resp
+	}
+
+	as.Equal(VerifyResponse{Valid: true, Secret: "current"}, verify(webhook.Sign([]byte(payload), time.Now(), rotation.Secret)))
+	as.Equal(VerifyResponse{Valid: true, Secret: "previous"}, verify(webhook.Sign([]byte(payload), time.Now(), hook.Secret)))
+	as.False(verify(webhook.Sign([]byte(payload), time.Now(), "wrong")).Valid)
+	as.False(verify(webhook.Sign([]byte(payload), time.Now().Add(-time.Hour), rotation.Secret)).Valid)
+
+	// Without a grace period the old secret stops working at once
+	current := rotation.Secret
+	res = client.Post(path+"/secret-rotation", map[string]int{"grace_hours": 0})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&rotation)
+	as.False(rotation.Rotating)
+	as.False(verify(webhook.Sign([]byte(payload), time.Now(), current)).Valid)
+
+	other := kit.Client(newKitApp(kit), kit.CreateUser())
+	as.Equal(http.StatusNotFound, other.Get(path+"/secret-rotation").Code)
+}
+
+func (as *ActionSuite) Test_Webhooks_ReplayFailedDelivery() {
+	kit := testkit.New(as.T())
+	kit.Config.Webhooks.AllowPrivate = true // Receivers are httptest servers
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+	rec, url := newWebhookReceiver(as)
+	rec.setStatus(http.StatusServiceUnavailable)
+
+	var hook WebhookResponse
+	client.Post("/api/v1/webhooks", WebhookPayload{URL: url, Events: []string{models.EventClipCreated}}).JSON(&hook)
+	path := "/api/v1/webhooks/" + hook.ID
+
+	res := client.Post("/api/v1/clips", ClipPayload{Title: "Retry me", URL: "https://example.com/retry", Mode: "article"})
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	failed := as.waitForDelivery(kit, hook, models.WebhookDeliveryFailed)
+	as.Equal(http.StatusServiceUnavailable, failed.ResponseStatus.Int)
+
+	var log struct {
+		Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+	}
+	res = client.Get(path + "/deliveries?status=failed")
+	as.Equal(http.StatusOK, res.Code, res.Body.String())
+	res.JSON(&log)
+	as.Len(log.Deliveries, 1)
+	as.Equal(failed.ID.String(), log.Deliveries[0].ID)
+
+	rec.setStatus(http.StatusNoContent)
+	var replay WebhookDeliveryResponse
+	res = client.Post(path+"/deliveries/"+failed.ID.String()+"/replay", nil)
+	as.Equal(http.StatusAccepted, res.Code, res.Body.String())
+	res.JSON(&replay)
+	as.Equal(failed.ID.String(), replay.ReplayOf)
+
+	succeeded := as.waitForDelivery(kit, hook, models.WebhookDeliverySucceeded)
+	as.Equal(replay.ID, succeeded.ID.String())
+	as.Equal(failed.Payload, succeeded.Payload)
+
+	// Only failed deliveries are replayed
+	res = client.Post(path+"/deliveries/"+succeeded.ID.String()+"/replay", nil)
+	as.Equal(http.StatusConflict, res.Code)
+	as.Equal(http.StatusBadRequest, client.Get(path+"/deliveries?status=bogus").Code)
+
+	as.Equal(http.StatusNoContent, client.Delete(path).Code)
+	count, err := kit.DB.Where("webhook_id = ?", hook.ID).Count(&models.WebhookDelivery{})
+	as.NoError(err)
+	as.Zero(count)
+}
+
+func (as *ActionSuite) Test_Webhooks_RejectInternalReceivers() {
+	kit := testkit.New(as.T())
+	client := kit.Client(newKitApp(kit), kit.CreateUser())
+
+	for _, url := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/hook",
+		"http://10.0.0.5/hook",
+		"http://localhost/hook",
+	} {
+		res := client.Post("/api/v1/webhooks", WebhookPayload{URL: url})
+		as.Equal(http.StatusUnprocessableEntity, res.Code, url)
+		as.Contains(res.Body.String(), "internal address", url)
+	}
+
+	kit.Config.Webhooks.AllowPrivate = true
+	as.Equal(http.StatusCreated, client.Post("/api/v1/webhooks", WebhookPayload{URL: "http://127.0.0.1:8080/hook"}).Code)
+}
@@ -0,0 +1,96 @@
+// Package api holds the OpenAPI description of the public HTTP API.
+package api
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is the OpenAPI 3 document (openapi.yaml) the SDKs are generated from.
+//
+//go:embed openapi.yaml
+var Spec []byte
+
+// JSON returns the spec as JSON, served on /openapi.json. Keys keep the
+// order of the file.
+func JSON() ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(Spec, &doc); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, &doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeJSON writes a YAML node as JSON
+func writeJSON(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		return writeJSON(buf, node.Content[0])
+	case yaml.AliasNode:
+		return writeJSON(buf, node.Alias)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(node.Content[i].Value)
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := writeJSON(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case yaml.ScalarNode:
+		return writeScalar(buf, node)
+	default:
+		return fmt.Errorf("line %d: unexpected YAML node", node.Line)
+	}
+	return nil
+}
+
+// writeScalar writes a scalar as its JSON type: numbers, booleans and null
+// stay unquoted, quoted values are strings
+func writeScalar(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.ShortTag() {
+	case "!!int", "!!float":
+		if _, err := strconv.ParseFloat(node.Value, 64); err == nil {
+			buf.WriteString(node.Value)
+			return nil
+		}
+	case "!!bool":
+		var b bool
+		if err := node.Decode(&b); err != nil {
+			return err
+		}
+		buf.WriteString(strconv.FormatBool(b))
+		return nil
+	case "!!null":
+		buf.WriteString("null")
+		return nil
+	}
+	value, _ := json.Marshal(node.Value)
+	buf.Write(value)
+	return nil
+}
@@ -0,0 +1,989 @@
+// Code generated by sdkgen from api/openapi.yaml. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ErrorResponse is the ErrorResponse schema of the API spec.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail is the ErrorDetail schema of the API spec.
+type ErrorDetail struct {
+	Code      string `json:"code"` // Machine-readable reason, the status' (not_found, bad_request...) or a more specific one (like the reason a clip was rejected for)
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"` // The request's ID, also sent as X-Request-ID and found in the server's logs
+}
+
+// BuildInfo is the BuildInfo schema of the API spec.
+type BuildInfo struct {
+	Version   string `json:"version"`            // Release version, dev for builds without one
+	Commit    string `json:"commit,omitempty"`   // Git commit built
+	Date      string `json:"date,omitempty"`     // Build (or commit) date, RFC 3339
+	Modified  bool   `json:"modified,omitempty"` // Built from a working tree with uncommitted changes
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"` // GOOS/GOARCH
+}
+
+// RefreshPayload is the RefreshPayload schema of the API spec.
+type RefreshPayload struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ExtensionExchangePayload is the ExtensionExchangePayload schema of the API spec.
+type ExtensionExchangePayload struct {
+	Code         string `json:"code"`          // From the redirect of the OAuth callback
+	CodeVerifier string `json:"code_verifier"` // Whose base64url SHA-256 was the code_challenge
+}
+
+// DeviceCodeResponse is the DeviceCodeResponse schema of the API spec.
+type DeviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"` // Secret of the client, to poll with
+	UserCode                string `json:"user_code"`   // To show the user, as XXXX-XXXX
+	VerificationUri         string `json:"verification_uri"`
+	VerificationUriComplete string `json:"verification_uri_complete"` // The verification_uri with the user code filled in
+	ExpiresIn               int    `json:"expires_in"`                // Seconds until the codes expire
+	Interval                int    `json:"interval"`                  // Seconds to wait between polls
+}
+
+// DeviceTokenPayload is the DeviceTokenPayload schema of the API spec.
+type DeviceTokenPayload struct {
+	DeviceCode string `json:"device_code"`
+}
+
+// TokenResponse is the TokenResponse schema of the API spec.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresAt    int64  `json:"expires_at"` // Expiry of the access token, in Unix seconds
+}
+
+// LogoutResponse is the LogoutResponse schema of the API spec.
+type LogoutResponse struct {
+	Success bool `json:"success"`
+}
+
+// UploadStartPayload is the UploadStartPayload schema of the API spec.
+type UploadStartPayload struct {
+	ContentType string `json:"content_type"` // application/json, or multipart/form-data with its boundary
+}
+
+// UploadSession is the UploadSession schema of the API spec.
+type UploadSession struct {
+	ID            string    `json:"id"`
+	Offset        int64     `json:"offset"` // Bytes received; the next chunk starts here
+	MaxChunkBytes int64     `json:"max_chunk_bytes"`
+	MaxBytes      int64     `json:"max_bytes"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// OffsetMismatch is the OffsetMismatch schema of the API spec.
+type OffsetMismatch struct {
+	Error  ErrorDetail `json:"error"`
+	Offset int64       `json:"offset"` // The session's offset, to resume from
+}
+
+// FetchClipPayload is the FetchClipPayload schema of the API spec.
+type FetchClipPayload struct {
+	URL   string   `json:"url"`
+	Title string   `json:"title,omitempty"` // Overrides the extracted title
+	Mode  string   `json:"mode,omitempty"`  // article (default) or bookmark
+	Tags  []string `json:"tags,omitempty"`
+	Notes string   `json:"notes,omitempty"`
+}
+
+// ClipVersion is the ClipVersion schema of the API spec.
+type ClipVersion struct {
+	Version   int       `json:"version"`
+	Title     string    `json:"title"`
+	Mode      string    `json:"mode"`
+	Path      string    `json:"path"` // Relative to the user's clip directory
+	Current   bool      `json:"current"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListVersionsResponse is the ListVersionsResponse schema of the API spec.
+type ListVersionsResponse struct {
+	ClipID   string        `json:"clip_id"`
+	Versions []ClipVersion `json:"versions"`
+}
+
+// DiffResponse is the DiffResponse schema of the API spec.
+type DiffResponse struct {
+	ClipID string `json:"clip_id"`
+	From   int    `json:"from"`
+	To     int    `json:"to"`
+	Diff   string `json:"diff"` // Unified diff of the markdown; empty when identical
+}
+
+// HighlightPayload is the HighlightPayload schema of the API spec.
+type HighlightPayload struct {
+	Text        string `json:"text"`
+	Selector    string `json:"selector,omitempty"` // Anchor in the page (CSS selector or XPath)
+	StartOffset int    `json:"start_offset,omitempty"`
+	EndOffset   int    `json:"end_offset,omitempty"`
+	Color       string `json:"color,omitempty"` // Defaults to yellow
+	Comment     string `json:"comment,omitempty"`
+}
+
+// Highlight is the Highlight schema of the API spec.
+type Highlight struct {
+	ID          string    `json:"id"`
+	ClipID      string    `json:"clip_id"`
+	Text        string    `json:"text"`
+	Selector    string    `json:"selector,omitempty"`
+	StartOffset int       `json:"start_offset"`
+	EndOffset   int       `json:"end_offset"`
+	Color       string    `json:"color"`
+	Comment     string    `json:"comment,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ListHighlightsResponse is the ListHighlightsResponse schema of the API spec.
+type ListHighlightsResponse struct {
+	Highlights []Highlight `json:"highlights"`
+}
+
+// Delivery is the Delivery schema of the API spec.
+type Delivery struct {
+	ID          string     `json:"id"`
+	ClipID      string     `json:"clip_id"`
+	DeviceEmail string     `json:"device_email"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	SentAt      *time.Time `json:"sent_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ConfigResponse is the ConfigResponse schema of the API spec.
+type ConfigResponse struct {
+	ClipDirectory  string       `json:"clipDirectory"`
+	DefaultFormat  ClipMode     `json:"defaultFormat"`
+	DefaultTags    []string     `json:"defaultTags"`    // Added to every new clip
+	FolderTemplate string       `json:"folderTemplate"` // The user's folder template; empty for clips.folder_template or the built-in names
+	Images         ImagesConfig `json:"images"`
+}
+
+// ConfigPayload is the ConfigPayload schema of the API spec.
+type ConfigPayload struct {
+	DefaultFormat  string             `json:"defaultFormat,omitempty"` // Mode of clips sent without one (a ClipMode); empty for article
+	DefaultTags    []string           `json:"defaultTags,omitempty"`
+	FolderTemplate string             `json:"folderTemplate,omitempty"` // Go template of new clips' folders, as in PUT /api/v1/templates/naming
+	Images         *ImagesPreferences `json:"images,omitempty"`
+}
+
+// ImagesPreferences: Omitted fields use the server's images settings
+type ImagesPreferences struct {
+	ConvertToWebp    *bool `json:"convertToWebp,omitempty"`
+	PreserveOriginal *bool `json:"preserveOriginal,omitempty"`
+	MaxDimensionPx   int   `json:"maxDimensionPx,omitempty"` // At most the server's images.max_dimension_px
+}
+
+// ImagesConfig is the ImagesConfig schema of the API spec.
+type ImagesConfig struct {
+	MaxSizeBytes     int64    `json:"maxSizeBytes"`
+	MaxDimensionPx   int      `json:"maxDimensionPx"`
+	MaxTotalBytes    int64    `json:"maxTotalBytes"`
+	ConvertToWebp    bool     `json:"convertToWebp"`    // PNG and JPEG images are stored as WebP (images.convert_to_webp)
+	PreserveOriginal bool     `json:"preserveOriginal"` // Resized and converted images keep their original in media/originals (images.preserve_original)
+	AllowedFormats   []string `json:"allowedFormats"`   // Image formats accepted, recognized by content (images.allowed_formats); others are rejected with 415
+	FetchRemote      bool     `json:"fetchRemote"`      // Images may be sent with an empty data and only their originalUrl, for the server to download (images.fetch_remote)
+}
+
+// ClipMode is the ClipMode schema of the API spec.
+type ClipMode string
+
+const (
+	ClipModeArticle    ClipMode = "article"
+	ClipModeBookmark   ClipMode = "bookmark"
+	ClipModeScreenshot ClipMode = "screenshot"
+	ClipModeSelection  ClipMode = "selection"
+	ClipModeFullpage   ClipMode = "fullpage"
+	ClipModePDF        ClipMode = "pdf"
+)
+
+// ClipPayload is the ClipPayload schema of the API spec.
+type ClipPayload struct {
+	Title      string         `json:"title"`
+	URL        string         `json:"url"`
+	Markdown   string         `json:"markdown"`
+	HTML       string         `json:"html,omitempty"` // Page HTML, for fullpage mode
+	Tags       []string       `json:"tags"`
+	Notes      string         `json:"notes"`
+	Images     []ImagePayload `json:"images"`
+	Mode       ClipMode       `json:"mode,omitempty"`
+	Dedupe     string         `json:"dedupe,omitempty"`     // "reject" (default) or "merge" when the URL was just clipped
+	Toc        bool           `json:"toc,omitempty"`        // Add a table of contents of the page's headings (default markdown.toc.enabled)
+	FaviconURL string         `json:"faviconUrl,omitempty"` // Icon the page declares, tried before /favicon.ico (favicons.enabled)
+	Latitude   *float64       `json:"latitude,omitempty"`
+	Longitude  *float64       `json:"longitude,omitempty"`
+	Place      string         `json:"place,omitempty"`
+}
+
+// ImagePayload is the ImagePayload schema of the API spec.
+type ImagePayload struct {
+	Filename    string `json:"filename"`
+	Data        string `json:"data"`        // Base64 image data; may be empty when the server fetches remote images
+	OriginalURL string `json:"originalUrl"` // Where the image was found; downloaded by the server when data is empty (images.fetch_remote)
+}
+
+// ClipResponse is the ClipResponse schema of the API spec.
+type ClipResponse struct {
+	Success   bool         `json:"success"`
+	Path      string       `json:"path,omitempty"`
+	ID        string       `json:"id,omitempty"`
+	Version   int          `json:"version,omitempty"`   // Version number when the URL was clipped before
+	Duplicate bool         `json:"duplicate,omitempty"` // Set on 409 when the URL was just clipped
+	JobID     string       `json:"job_id,omitempty"`    // Set on 202, the job processing the clip
+	Error     *ErrorDetail `json:"error,omitempty"`
+}
+
+// ListClipsResponse is the ListClipsResponse schema of the API spec.
+type ListClipsResponse struct {
+	Clips      []ClipSummary `json:"clips"`
+	Page       int           `json:"page,omitempty"` // Omitted when paginating with after=
+	PerPage    int           `json:"per_page"`
+	Total      int           `json:"total"`
+	TotalPages int           `json:"total_pages"`
+	NextCursor string        `json:"next_cursor,omitempty"` // Pass as after= for the next page
+}
+
+// ClipSummary is the ClipSummary schema of the API spec.
+type ClipSummary struct {
+	ID           string        `json:"id"`
+	Title        string        `json:"title"`
+	URL          string        `json:"url"`
+	Domain       string        `json:"domain"`
+	Mode         string        `json:"mode"`
+	Tags         []string      `json:"tags"`
+	Notes        string        `json:"notes,omitempty"`
+	Excerpt      string        `json:"excerpt,omitempty"`
+	Location     *ClipLocation `json:"location,omitempty"`
+	CollectionID string        `json:"collection_id,omitempty"`
+	FaviconURL   string        `json:"favicon_url,omitempty"` // Icon of the clip's site, once fetched (favicons.enabled)
+	Metadata     *ClipMetadata `json:"metadata,omitempty"`
+	Language     string        `json:"language,omitempty"` // ISO 639-1 code of the content language, declared by the page or detected
+	ArchivedAt   *time.Time    `json:"archived_at,omitempty"`
+	ReadAt       *time.Time    `json:"read_at,omitempty"`
+	DisabledAt   *time.Time    `json:"disabled_at,omitempty"` // Set when an admin took the clip down from share links, feeds and the mirror
+	Score        int           `json:"score,omitempty"`       // Relevance to the words of q, summed over the fields they are found in (title 8, highlights 4, notes 2, body 1)
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+// ClipMetadata: What the clipped page declares about itself (OpenGraph, meta tags, JSON-LD), for fullpage and server-fetched clips
+type ClipMetadata struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Published   string `json:"published,omitempty"` // Publication date, RFC 3339
+	SiteName    string `json:"site_name,omitempty"`
+	Image       string `json:"image,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Language    string `json:"language,omitempty"`
+}
+
+// ClipLocation is the ClipLocation schema of the API spec.
+type ClipLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Place     string  `json:"place,omitempty"`
+}
+
+// ClipDetail is the ClipDetail schema of the API spec.
+type ClipDetail struct {
+	ClipSummary
+	Path    string      `json:"path"`
+	Content string      `json:"content,omitempty"` // Markdown content
+	Images  []ClipImage `json:"images,omitempty"`
+}
+
+// RecentView is the RecentView schema of the API spec.
+type RecentView struct {
+	ClipSummary
+	ViewedAt time.Time `json:"viewed_at"` // Last time the clip was opened
+	Views    int       `json:"views"`     // Times the clip was opened
+}
+
+// ListRecentViewsResponse is the ListRecentViewsResponse schema of the API spec.
+type ListRecentViewsResponse struct {
+	Clips []RecentView `json:"clips"`
+}
+
+// ClipImage is the ClipImage schema of the API spec.
+type ClipImage struct {
+	Filename string `json:"filename"`
+	Path     string `json:"path"` // Relative path for serving
+	MimeType string `json:"mime_type"`
+}
+
+// BulkPayload is the BulkPayload schema of the API spec.
+type BulkPayload struct {
+	Operation    string   `json:"operation"`
+	IDs          []string `json:"ids"`
+	Tags         []string `json:"tags,omitempty"`          // For add-tags and remove-tags
+	CollectionID string   `json:"collection_id,omitempty"` // For move-to-collection; empty removes clips from their collection
+}
+
+// BulkResponse is the BulkResponse schema of the API spec.
+type BulkResponse struct {
+	Operation string           `json:"operation"`
+	Applied   bool             `json:"applied"` // False when any item failed and nothing was changed
+	Results   []BulkItemResult `json:"results"`
+}
+
+// BulkUpdatePayload is the BulkUpdatePayload schema of the API spec.
+type BulkUpdatePayload struct {
+	IDs          []string `json:"ids"`
+	AddTags      []string `json:"add_tags,omitempty"`
+	RemoveTags   []string `json:"remove_tags,omitempty"`
+	CollectionID *string  `json:"collection_id,omitempty"` // Moves the clips to this collection; empty removes them from their collection
+	Archived     *bool    `json:"archived,omitempty"`
+	Read         *bool    `json:"read,omitempty"`
+}
+
+// BulkUpdateResponse is the BulkUpdateResponse schema of the API spec.
+type BulkUpdateResponse struct {
+	Operations []string         `json:"operations"` // Bulk operations applied to each clip, in order
+	Applied    bool             `json:"applied"`    // False when any item failed and nothing was changed
+	Results    []BulkItemResult `json:"results"`
+}
+
+// BulkItemResult is the BulkItemResult schema of the API spec.
+type BulkItemResult struct {
+	ID      string `json:"id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// CollectionPayload is the CollectionPayload schema of the API spec.
+type CollectionPayload struct {
+	Name        string `json:"name"`
+	StoragePath string `json:"storage_path,omitempty"` // Where clips moved to the collection are stored, a subpath of the clip directory or, for admins, an absolute path under admin.allowed_paths outside storage.base_path
+	Public      bool   `json:"public,omitempty"`       // Published by the read-only mirror
+}
+
+// CollectionUpdatePayload is the CollectionUpdatePayload schema of the API spec.
+type CollectionUpdatePayload struct {
+	Name   string `json:"name,omitempty"`
+	Public bool   `json:"public,omitempty"`
+}
+
+// Collection is the Collection schema of the API spec.
+type Collection struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	StoragePath string    `json:"storage_path,omitempty"`
+	Public      bool      `json:"public"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ListCollectionsResponse is the ListCollectionsResponse schema of the API spec.
+type ListCollectionsResponse struct {
+	Collections []Collection `json:"collections"`
+}
+
+// Share is the Share schema of the API spec.
+type Share struct {
+	ID         string     `json:"id"`
+	ClipID     string     `json:"clip_id"`
+	URL        string     `json:"url"`                   // Public link, built on public.base_url
+	DisabledAt *time.Time `json:"disabled_at,omitempty"` // Set when an admin disabled the link
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	MaxViews   int        `json:"max_views,omitempty"`
+	Views      int        `json:"views"`     // Page views so far
+	Expired    bool       `json:"expired"`   // Past expires_at or out of views
+	Protected  bool       `json:"protected"` // Visitors must enter a passphrase
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// SharePayload: Limits of a new share link; without any it never expires
+type SharePayload struct {
+	ExpiresInHours   int    `json:"expires_in_hours,omitempty"`
+	MaxViews         int    `json:"max_views,omitempty"`
+	BurnAfterReading bool   `json:"burn_after_reading,omitempty"` // Expire the link after a single view (max_views 1)
+	Password         string `json:"password,omitempty"`           // Passphrase visitors must enter; only its hash is stored
+}
+
+// ListSharesResponse is the ListSharesResponse schema of the API spec.
+type ListSharesResponse struct {
+	Shares []Share `json:"shares"`
+}
+
+// Job is the Job schema of the API spec.
+type Job struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Status     string                 `json:"status"`
+	Attempts   int                    `json:"attempts"`
+	Result     map[string]interface{} `json:"result,omitempty"` // Set once succeeded; depends on the job type
+	Error      string                 `json:"error,omitempty"`  // Error of the last failed attempt
+	CreatedAt  time.Time              `json:"created_at"`
+	StartedAt  *time.Time             `json:"started_at,omitempty"`
+	FinishedAt *time.Time             `json:"finished_at,omitempty"`
+}
+
+// UsageMinute is the UsageMinute schema of the API spec.
+type UsageMinute struct {
+	At        time.Time `json:"at"` // Start of the minute
+	Requests  int       `json:"requests"`
+	Errors    int       `json:"errors"`    // 4xx and 5xx responses, but 429
+	Throttled int       `json:"throttled"` // 429 responses
+}
+
+// TokenUsage is the TokenUsage schema of the API spec.
+type TokenUsage struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Revoked    bool       `json:"revoked"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Requests   int        `json:"requests"` // Requests made with the token in the window
+}
+
+// APIUsage is the APIUsage schema of the API spec.
+type APIUsage struct {
+	Since           time.Time              `json:"since"` // Start of the counts (the window, or the server start if later)
+	WindowMinutes   int                    `json:"window_minutes"`
+	Requests        int                    `json:"requests"`
+	Errors          int                    `json:"errors"`
+	Throttled       int                    `json:"throttled"`
+	SessionRequests int                    `json:"session_requests"` // Requests made with a login session rather than a service token
+	Minutes         []UsageMinute          `json:"minutes"`          // Minutes with requests, oldest first
+	RateLimit       map[string]interface{} `json:"rate_limit"`
+	Tokens          []TokenUsage           `json:"tokens"` // The user's service tokens, most recently used first
+}
+
+// Profile is the Profile schema of the API spec.
+type Profile struct {
+	ID        string        `json:"id"`
+	Email     string        `json:"email"`
+	Name      string        `json:"name"`
+	Admin     bool          `json:"admin"` // The user may call /api/v1/admin (admin.emails)
+	CreatedAt time.Time     `json:"created_at"`
+	Storage   StorageStatus `json:"storage"`
+	Usage     DiskUsage     `json:"usage"`
+	Limits    ProfileLimits `json:"limits"`
+	Token     ProfileToken  `json:"token"`
+}
+
+// StorageStatus is the StorageStatus schema of the API spec.
+type StorageStatus struct {
+	Path   string `json:"path"`   // The user's clip directory
+	Custom bool   `json:"custom"` // Set by an admin, instead of storage.base_path
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"` // Why the directory can't be written to
+}
+
+// ProfileLimits: The server's limits, 0 when there is none
+type ProfileLimits struct {
+	MaxClipBytes      int64 `json:"max_clip_bytes"`
+	MaxImageBytes     int64 `json:"max_image_bytes"`
+	MaxImagesBytes    int64 `json:"max_images_bytes"` // All the images of a clip
+	MaxUploadBytes    int64 `json:"max_upload_bytes"`
+	RequestsPerMinute int   `json:"requests_per_minute"` // Per credential, when rate limiting is enabled
+}
+
+// ProfileToken: The credential of the request; id, name, prefix and created_at are set for service tokens
+type ProfileToken struct {
+	Type       string     `json:"type"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	ID         string     `json:"id,omitempty"`
+	Name       string     `json:"name,omitempty"`
+	Prefix     string     `json:"prefix,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// DiskUsage is the DiskUsage schema of the API spec.
+type DiskUsage struct {
+	Email        string                 `json:"email"`
+	Clips        int                    `json:"clips"` // Clips not in the trash
+	Bytes        int64                  `json:"bytes"`
+	TrashedClips int                    `json:"trashed_clips"`
+	TrashedBytes int64                  `json:"trashed_bytes"`
+	Modes        map[string]interface{} `json:"modes"`       // Clips not in the trash, by mode (each with clips and bytes)
+	ComputedAt   time.Time              `json:"computed_at"` // When the files were last walked
+}
+
+// GetConfig calls GET /api/v1/config: Get the user's settings and the server's limits.
+func (c *Client) GetConfig(ctx context.Context) (*ConfigResponse, error) {
+	out := &ConfigResponse{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/config", nil, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpdateConfig calls PUT /api/v1/config: Replace the user's settings.
+func (c *Client) UpdateConfig(ctx context.Context, body ConfigPayload) (*ConfigResponse, error) {
+	out := &ConfigResponse{}
+	if err := c.do(ctx, http.MethodPut, "/api/v1/config", nil, nil, body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetVersion calls GET /api/v1/version: Get the server's build.
+func (c *Client) GetVersion(ctx context.Context) (*BuildInfo, error) {
+	out := &BuildInfo{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/version", nil, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RefreshToken calls POST /auth/refresh: Exchange a refresh token for new tokens.
+func (c *Client) RefreshToken(ctx context.Context, body RefreshPayload) (*TokenResponse, error) {
+	out := &TokenResponse{}
+	if err := c.do(ctx, http.MethodPost, "/auth/refresh", nil, nil, body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ExchangeExtensionCode calls POST /auth/extension/exchange: Exchange the extension's login code for tokens.
+func (c *Client) ExchangeExtensionCode(ctx context.Context, body ExtensionExchangePayload) (*TokenResponse, error) {
+	out := &TokenResponse{}
+	if err := c.do(ctx, http.MethodPost, "/auth/extension/exchange", nil, nil, body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RequestDeviceCode calls POST /auth/device/code: Start a device login.
+func (c *Client) RequestDeviceCode(ctx context.Context) (*DeviceCodeResponse, error) {
+	out := &DeviceCodeResponse{}
+	if err := c.do(ctx, http.MethodPost, "/auth/device/code", nil, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeviceToken calls POST /auth/device/token: Poll for the tokens of a device login.
+func (c *Client) DeviceToken(ctx context.Context, body DeviceTokenPayload) (*TokenResponse, error) {
+	out := &TokenResponse{}
+	if err := c.do(ctx, http.MethodPost, "/auth/device/token", nil, nil, body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Logout calls POST /auth/logout: Log out.
+func (c *Client) Logout(ctx context.Context) (*LogoutResponse, error) {
+	out := &LogoutResponse{}
+	if err := c.do(ctx, http.MethodPost, "/auth/logout", nil, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListClipsParams holds the optional parameters of ListClips.
+type ListClipsParams struct {
+	Page       int
+	PerPage    int
+	After      string // Cursor from next_cursor; takes precedence over page
+	Q          string // Search query: words and "phrases" that must all match, field terms (tag:, site:, mode:, lang:, before:, after:, is:read|unread|archived), - to negate any term
+	Fields     string // Comma-separated fields the words of q are searched in (title, highlights, notes, body); all by default
+	Sort       string // date (newest first, the default) or relevance (highest score first; page with page=, not after=)
+	Tag        string
+	Mode       string
+	Domain     string
+	Lang       string // ISO 639-1 language code, e.g. "fr"
+	Collection string
+	Archived   string // "true", "false" (default) or "all"
+	Read       string // "true" or "false" keeps only read or unread clips
+}
+
+// ListClips calls GET /api/v1/clips: List clips, newest first.
+func (c *Client) ListClips(ctx context.Context, params *ListClipsParams) (*ListClipsResponse, error) {
+	query := url.Values{}
+	if params != nil {
+		if params.Page != 0 {
+			query.Set("page", strconv.Itoa(params.Page))
+		}
+		if params.PerPage != 0 {
+			query.Set("per_page", strconv.Itoa(params.PerPage))
+		}
+		if params.After != "" {
+			query.Set("after", params.After)
+		}
+		if params.Q != "" {
+			query.Set("q", params.Q)
+		}
+		if params.Fields != "" {
+			query.Set("fields", params.Fields)
+		}
+		if params.Sort != "" {
+			query.Set("sort", params.Sort)
+		}
+		if params.Tag != "" {
+			query.Set("tag", params.Tag)
+		}
+		if params.Mode != "" {
+			query.Set("mode", params.Mode)
+		}
+		if params.Domain != "" {
+			query.Set("domain", params.Domain)
+		}
+		if params.Lang != "" {
+			query.Set("lang", params.Lang)
+		}
+		if params.Collection != "" {
+			query.Set("collection", params.Collection)
+		}
+		if params.Archived != "" {
+			query.Set("archived", params.Archived)
+		}
+		if params.Read != "" {
+			query.Set("read", params.Read)
+		}
+	}
+	out := &ListClipsResponse{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/clips", query, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateClipParams holds the optional parameters of CreateClip.
+type CreateClipParams struct {
+	IdempotencyKey string // Retries with the same key replay the saved clip instead of creating another one
+	Prefer         string // "respond-async" saves a new clip and returns 202 with the job extracting its text (OCR of screenshots, PDF text layer). Only text extraction is deferred: remote images are fetched, and images downscaled and converted to WebP, before the response. Re-clips of a saved URL are answered synchronously.
+}
+
+// CreateClip calls POST /api/v1/clips: Save a clip.
+func (c *Client) CreateClip(ctx context.Context, body ClipPayload, params *CreateClipParams) (*ClipResponse, error) {
+	header := http.Header{}
+	if params != nil {
+		if params.IdempotencyKey != "" {
+			header.Set("Idempotency-Key", params.IdempotencyKey)
+		}
+		if params.Prefer != "" {
+			header.Set("Prefer", params.Prefer)
+		}
+	}
+	out := &ClipResponse{}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/clips", nil, header, body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StartUpload calls POST /api/v1/uploads: Start a chunked upload of a clip.
+func (c *Client) StartUpload(ctx context.Context, body UploadStartPayload) (*UploadSession, error) {
+	out := &UploadSession{}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/uploads", nil, nil, body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetUpload calls GET /api/v1/uploads/{id}: Get an upload session, to resume at its offset.
+func (c *Client) GetUpload(ctx context.Context, id string) (*UploadSession, error) {
+	out := &UploadSession{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/uploads/"+url.PathEscape(id), nil, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AbortUpload calls DELETE /api/v1/uploads/{id}: Abort an upload.
+func (c *Client) AbortUpload(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/uploads/"+url.PathEscape(id), nil, nil, nil, nil)
+}
+
+// FinalizeUpload calls POST /api/v1/uploads/{id}/finalize: Save the uploaded body as a clip.
+func (c *Client) FinalizeUpload(ctx context.Context, id string) (*ClipResponse, error) {
+	out := &ClipResponse{}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/uploads/"+url.PathEscape(id)+"/finalize", nil, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BulkClips calls POST /api/v1/clips/bulk: Apply one operation to many clips, all or nothing.
+func (c *Client) BulkClips(ctx context.Context, body BulkPayload) (*BulkResponse, error) {
+	out := &BulkResponse{}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/clips/bulk", nil, nil, body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BulkUpdateClips calls POST /api/v1/clips/bulk-update: Apply several changes to many clips, all or nothing.
+func (c *Client) BulkUpdateClips(ctx context.Context, body BulkUpdatePayload) (*BulkUpdateResponse, error) {
+	out := &BulkUpdateResponse{}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/clips/bulk-update", nil, nil, body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FetchClip calls POST /api/v1/clips/fetch: Fetch a page on the server and save it as a clip.
+func (c *Client) FetchClip(ctx context.Context, body FetchClipPayload) (*ClipResponse, error) {
+	out := &ClipResponse{}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/clips/fetch", nil, nil, body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListRecentViewsParams holds the optional parameters of ListRecentViews.
+type ListRecentViewsParams struct {
+	Limit int // Number of clips, 1 to 100 (default 20)
+}
+
+// ListRecentViews calls GET /api/v1/clips/recent-views: List the clips the user opened last, most recent first.
+func (c *Client) ListRecentViews(ctx context.Context, params *ListRecentViewsParams) (*ListRecentViewsResponse, error) {
+	query := url.Values{}
+	if params != nil {
+		if params.Limit != 0 {
+			query.Set("limit", strconv.Itoa(params.Limit))
+		}
+	}
+	out := &ListRecentViewsResponse{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/clips/recent-views", query, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetClip calls GET /api/v1/clips/{id}: Get a clip with its content.
+func (c *Client) GetClip(ctx context.Context, id string) (*ClipDetail, error) {
+	out := &ClipDetail{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/clips/"+url.PathEscape(id), nil, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteClipParams holds the optional parameters of DeleteClip.
+type DeleteClipParams struct {
+	DeleteFiles string // "false" keeps the files in place
+}
+
+// DeleteClip calls DELETE /api/v1/clips/{id}: Move a clip to the trash.
+func (c *Client) DeleteClip(ctx context.Context, id string, params *DeleteClipParams) error {
+	query := url.Values{}
+	if params != nil {
+		if params.DeleteFiles != "" {
+			query.Set("delete_files", params.DeleteFiles)
+		}
+	}
+	return c.do(ctx, http.MethodDelete, "/api/v1/clips/"+url.PathEscape(id), query, nil, nil, nil)
+}
+
+// GetClipFavicon calls GET /api/v1/clips/{id}/favicon: Get the icon of a clip's site.
+func (c *Client) GetClipFavicon(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodGet, "/api/v1/clips/"+url.PathEscape(id)+"/favicon", nil, nil, nil, nil)
+}
+
+// ListShares calls GET /api/v1/clips/{id}/shares: List the public links to a clip.
+func (c *Client) ListShares(ctx context.Context, id string) (*ListSharesResponse, error) {
+	out := &ListSharesResponse{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/clips/"+url.PathEscape(id)+"/shares", nil, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateShare calls POST /api/v1/clips/{id}/shares: Create a public link to a clip.
+func (c *Client) CreateShare(ctx context.Context, id string, body SharePayload) (*Share, error) {
+	out := &Share{}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/clips/"+url.PathEscape(id)+"/shares", nil, nil, body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetClipMedia calls GET /api/v1/clips/{id}/media/{filename}: Get a media file of a clip.
+func (c *Client) GetClipMedia(ctx context.Context, id string, filename string) error {
+	return c.do(ctx, http.MethodGet, "/api/v1/clips/"+url.PathEscape(id)+"/media/"+url.PathEscape(filename), nil, nil, nil, nil)
+}
+
+// ExportClipParams holds the optional parameters of ExportClip.
+type ExportClipParams struct {
+	Format string // epub (default) or markdown
+	Toc    string // Add a table of contents (true/false); defaults to the user's setting
+}
+
+// ExportClip calls GET /api/v1/clips/{id}/export: Download a clip as EPUB or markdown.
+func (c *Client) ExportClip(ctx context.Context, id string, params *ExportClipParams) error {
+	query := url.Values{}
+	if params != nil {
+		if params.Format != "" {
+			query.Set("format", params.Format)
+		}
+		if params.Toc != "" {
+			query.Set("toc", params.Toc)
+		}
+	}
+	return c.do(ctx, http.MethodGet, "/api/v1/clips/"+url.PathEscape(id)+"/export", query, nil, nil, nil)
+}
+
+// SendClipToKindle calls POST /api/v1/clips/{id}/send-to-kindle: Email a clip's EPUB to the user's Kindle address.
+func (c *Client) SendClipToKindle(ctx context.Context, id string) (*Delivery, error) {
+	out := &Delivery{}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/clips/"+url.PathEscape(id)+"/send-to-kindle", nil, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListClipVersions calls GET /api/v1/clips/{id}/versions: List the captures of a clip.
+func (c *Client) ListClipVersions(ctx context.Context, id string) (*ListVersionsResponse, error) {
+	out := &ListVersionsResponse{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/clips/"+url.PathEscape(id)+"/versions", nil, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DiffClipVersionsParams holds the optional parameters of DiffClipVersions.
+type DiffClipVersionsParams struct {
+	From int // Version to compare from (default the one before to)
+	To   int // Version to compare to (default the latest)
+}
+
+// DiffClipVersions calls GET /api/v1/clips/{id}/versions/diff: Compare the markdown of two captures of a clip.
+func (c *Client) DiffClipVersions(ctx context.Context, id string, params *DiffClipVersionsParams) (*DiffResponse, error) {
+	query := url.Values{}
+	if params != nil {
+		if params.From != 0 {
+			query.Set("from", strconv.Itoa(params.From))
+		}
+		if params.To != 0 {
+			query.Set("to", strconv.Itoa(params.To))
+		}
+	}
+	out := &DiffResponse{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/clips/"+url.PathEscape(id)+"/versions/diff", query, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListHighlights calls GET /api/v1/clips/{id}/highlights: List the highlights of a clip.
+func (c *Client) ListHighlights(ctx context.Context, id string) (*ListHighlightsResponse, error) {
+	out := &ListHighlightsResponse{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/clips/"+url.PathEscape(id)+"/highlights", nil, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateHighlight calls POST /api/v1/clips/{id}/highlights: Highlight or annotate a clip.
+func (c *Client) CreateHighlight(ctx context.Context, id string, body HighlightPayload) (*Highlight, error) {
+	out := &Highlight{}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/clips/"+url.PathEscape(id)+"/highlights", nil, nil, body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpdateHighlight calls PUT /api/v1/clips/{id}/highlights/{highlight_id}: Replace a highlight's anchor, color and comment.
+func (c *Client) UpdateHighlight(ctx context.Context, id string, highlightId string, body HighlightPayload) (*Highlight, error) {
+	out := &Highlight{}
+	if err := c.do(ctx, http.MethodPut, "/api/v1/clips/"+url.PathEscape(id)+"/highlights/"+url.PathEscape(highlightId), nil, nil, body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteHighlight calls DELETE /api/v1/clips/{id}/highlights/{highlight_id}: Remove a highlight.
+func (c *Client) DeleteHighlight(ctx context.Context, id string, highlightId string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/clips/"+url.PathEscape(id)+"/highlights/"+url.PathEscape(highlightId), nil, nil, nil, nil)
+}
+
+// DeleteShare calls DELETE /api/v1/shares/{id}: Revoke a share link.
+func (c *Client) DeleteShare(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/shares/"+url.PathEscape(id), nil, nil, nil, nil)
+}
+
+// ListCollections calls GET /api/v1/collections: List collections.
+func (c *Client) ListCollections(ctx context.Context) (*ListCollectionsResponse, error) {
+	out := &ListCollectionsResponse{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/collections", nil, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateCollection calls POST /api/v1/collections: Create a collection.
+func (c *Client) CreateCollection(ctx context.Context, body CollectionPayload) (*Collection, error) {
+	out := &Collection{}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/collections", nil, nil, body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpdateCollection calls PUT /api/v1/collections/{id}: Rename a collection or change whether it is public.
+func (c *Client) UpdateCollection(ctx context.Context, id string, body CollectionUpdatePayload) (*Collection, error) {
+	out := &Collection{}
+	if err := c.do(ctx, http.MethodPut, "/api/v1/collections/"+url.PathEscape(id), nil, nil, body, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteCollection calls DELETE /api/v1/collections/{id}: Delete a collection, keeping its clips.
+func (c *Client) DeleteCollection(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/collections/"+url.PathEscape(id), nil, nil, nil, nil)
+}
+
+// GetJob calls GET /api/v1/jobs/{id}: Get the status of a background job.
+func (c *Client) GetJob(ctx context.Context, id string) (*Job, error) {
+	out := &Job{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/jobs/"+url.PathEscape(id), nil, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetAPIUsage calls GET /api/v1/usage/api: Get the user's API requests of the last hour.
+func (c *Client) GetAPIUsage(ctx context.Context) (*APIUsage, error) {
+	out := &APIUsage{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/usage/api", nil, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetProfile calls GET /api/v1/me: Get the authenticated user's account.
+func (c *Client) GetProfile(ctx context.Context) (*Profile, error) {
+	out := &Profile{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/me", nil, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetDiskUsageParams holds the optional parameters of GetDiskUsage.
+type GetDiskUsageParams struct {
+	Refresh bool // Measure again rather than use the cached figures
+}
+
+// GetDiskUsage calls GET /api/v1/me/usage: Get the storage taken by the user's clips.
+func (c *Client) GetDiskUsage(ctx context.Context, params *GetDiskUsageParams) (*DiskUsage, error) {
+	query := url.Values{}
+	if params != nil {
+		if params.Refresh {
+			query.Set("refresh", "true")
+		}
+	}
+	out := &DiskUsage{}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/me/usage", query, nil, nil, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"server/api"
+	"server/internal/openapi"
+)
+
+// TestGeneratedCodeIsUpToDate fails when api/openapi.yaml changed without
+// running `go generate ./client`
+func TestGeneratedCodeIsUpToDate(t *testing.T) {
+	spec, err := openapi.Parse(api.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := spec.GenerateGo("client", "api/openapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("client.gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("client.gen.go is out of date; run `go generate ./client`")
+	}
+
+	// The extension is not part of server-only checkouts (e.g. Docker builds)
+	got, err = os.ReadFile("../../extension/src/api/client.gen.ts")
+	if os.IsNotExist(err) {
+		t.Skip("extension sources not found")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err = spec.GenerateTS("api/openapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("extension/src/api/client.gen.ts is out of date; run `go generate ./client`")
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch {
+		case calls == 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case calls == 2 || r.Method == http.MethodPost:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": {"code": "service_unavailable", "message": "down"}}`))
+		default:
+			w.Write([]byte(`{"id": "job", "kind": "process", "status": "done", "attempts": 1, "created_at": "2026-01-01T00:00:00Z", "updated_at": "2026-01-01T00:00:00Z"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "token")
+	if _, err := c.GetJob(context.Background(), "job"); err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+
+	// A POST is only retried without reaching the handler (429)
+	calls = 1
+	var apiErr *APIError
+	if _, err := c.CreateCollection(context.Background(), CollectionPayload{Name: "x"}); !errors.As(err, &apiErr) || apiErr.Code != "service_unavailable" {
+		t.Fatalf("expected the 503, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("POST retried after a 503: %d attempts", calls)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/auth/refresh" {
+			w.Write([]byte(`{"access_token": "new", "refresh_token": "refresh-2", "expires_at": 1}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"deleted": false}`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, "expired")
+	c.Refresh = "refresh-1"
+	var saved *TokenResponse
+	c.OnRefresh = func(tokens *TokenResponse) { saved = tokens }
+	if err := c.DeleteClip(context.Background(), "id", nil); err != nil {
+		t.Fatalf("DeleteClip: %v", err)
+	}
+	if c.Token != "new" || c.Refresh != "refresh-2" || saved == nil {
+		t.Errorf("tokens not renewed: %q %q %v", c.Token, c.Refresh, saved)
+	}
+}
+
+func TestAllClips(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("after") {
+		case "":
+			w.Write([]byte(`{"clips": [{"id": "1"}, {"id": "2"}], "per_page": 2, "total": 3, "total_pages": 2, "next_cursor": "c2"}`))
+		case "c2":
+			w.Write([]byte(`{"clips": [{"id": "3"}], "per_page": 2, "total": 3, "total_pages": 2}`))
+		}
+	}))
+	defer srv.Close()
+
+	var ids []string
+	for clip, err := range New(srv.URL, "token").AllClips(context.Background(), &ListClipsParams{PerPage: 2, Page: 5}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, clip.ID)
+	}
+	if strings.Join(ids, ",") != "1,2,3" {
+		t.Errorf("unexpected clips: %v", ids)
+	}
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"iter"
+)
+
+// AllClips iterates over the clips matching params, fetching the next page
+// (of params.PerPage clips, or the server's default) with the after= cursor
+// as the loop advances. params.Page is ignored. Iteration ends at the first
+// error, yielded with an empty ClipSummary.
+func (c *Client) AllClips(ctx context.Context, params *ListClipsParams) iter.Seq2[ClipSummary, error] {
+	return func(yield func(ClipSummary, error) bool) {
+		page := ListClipsParams{}
+		if params != nil {
+			page = *params
+		}
+		page.Page = 0
+		for {
+			res, err := c.ListClips(ctx, &page)
+			if err != nil {
+				yield(ClipSummary{}, err)
+				return
+			}
+			for _, clip := range res.Clips {
+				if !yield(clip, nil) {
+					return
+				}
+			}
+			if res.NextCursor == "" {
+				return
+			}
+			page.After = res.NextCursor
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Requests are sent again, up to Client.MaxRetries times, when they did not
+// reach the server or it answered 502, 503 or 504, if they can safely run
+// twice: GET, HEAD, PUT and DELETE, and POSTs with an Idempotency-Key. A 429
+// (rate limit) means the request was not handled, so any request is sent
+// again, after the Retry-After the server asked for.
+
+const (
+	retryBackoff    = 500 * time.Millisecond // Wait before the first retry, doubled for each next one
+	maxRetryBackoff = time.Minute
+)
+
+// retryable tells whether a request can be sent again after it may have
+// reached the server
+func retryable(method string, header http.Header) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return header.Get("Idempotency-Key") != ""
+}
+
+// retryStatus tells whether a response status is worth sending the request
+// again for
+func retryStatus(status int, method string, header http.Header) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return retryable(method, header)
+	}
+	return false
+}
+
+// backoff returns the wait before retry n (from 0): the response's
+// Retry-After in seconds when it has one, else an exponential backoff
+func backoff(n int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return min(time.Duration(seconds)*time.Second, maxRetryBackoff)
+		}
+	}
+	return min(retryBackoff<<min(n, 10), maxRetryBackoff)
+}
+
+// wait sleeps for d, or until ctx is done
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
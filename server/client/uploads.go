@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// DefaultChunkSize is the size of the chunks Upload sends, unless the server
+// allows less
+const DefaultChunkSize = 4 << 20
+
+// AppendUpload calls PUT /api/v1/uploads/{id}: Append a chunk to an upload.
+// offset must be the session's; on a mismatch the *APIError (409) holds the
+// OffsetMismatch to resume from.
+func (c *Client) AppendUpload(ctx context.Context, id string, offset int64, chunk []byte) (*UploadSession, error) {
+	query := url.Values{}
+	query.Set("offset", strconv.FormatInt(offset, 10))
+	out := &UploadSession{}
+	if err := c.send(ctx, http.MethodPut, "/api/v1/uploads/"+url.PathEscape(id), query, nil, chunk, "application/octet-stream", out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UploadClip saves a clip through a chunked upload, for payloads too large
+// for one CreateClip (like full-page screenshots).
+func (c *Client) UploadClip(ctx context.Context, payload ClipPayload) (*ClipResponse, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return c.Upload(ctx, body, "application/json")
+}
+
+// Upload sends a POST /api/v1/clips body (JSON, or multipart/form-data with
+// its boundary in contentType) in chunks, then saves it as a clip. Chunks
+// the server did not get, or got twice after a retry, are resumed from the
+// session's offset.
+func (c *Client) Upload(ctx context.Context, body []byte, contentType string) (*ClipResponse, error) {
+	session, err := c.StartUpload(ctx, UploadStartPayload{ContentType: contentType})
+	if err != nil {
+		return nil, err
+	}
+	chunkSize := int64(DefaultChunkSize)
+	if session.MaxChunkBytes > 0 && session.MaxChunkBytes < chunkSize {
+		chunkSize = session.MaxChunkBytes
+	}
+
+	size := int64(len(body))
+	for offset := session.Offset; offset < size; {
+		end := min(offset+chunkSize, size)
+		next, err := c.AppendUpload(ctx, session.ID, offset, body[offset:end])
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+			var mismatch OffsetMismatch
+			if json.Unmarshal(apiErr.Body, &mismatch) != nil || mismatch.Offset == offset || mismatch.Offset > size {
+				return nil, err
+			}
+			offset = mismatch.Offset
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("upload %s at offset %d: %w", session.ID, offset, err)
+		}
+		offset = next.Offset
+	}
+	return c.FinalizeUpload(ctx, session.ID)
+}
@@ -0,0 +1,745 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"server/actions"
+	"server/internal/admin"
+	"server/internal/atrest"
+	"server/internal/clipclient"
+	"server/internal/config"
+	"server/models"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/spf13/cobra"
+)
+
+// newRootCmd builds the command tree. Running without a subcommand (or with
+// an unknown one, for backward compatibility) starts the server.
+func newRootCmd() *cobra.Command {
+	cobra.EnablePrefixMatching = true // Allow abbreviations like `web-clipper us li`
+
+	root := &cobra.Command{
+		Use:   "web-clipper",
+		Short: "Web Clipper - Clip Management Service",
+		Example: `  sudo -u web-clipper web-clipper users list
+  sudo -u web-clipper web-clipper tokens create --email admin@example.com --name 'API Token'
+  sudo -u web-clipper web-clipper audit list --action token.create --since 2026-01-01
+  sudo -u web-clipper web-clipper migrate
+  sudo -u web-clipper web-clipper migrate down --steps=2
+  sudo -u web-clipper web-clipper config check
+  web-clipper clip https://example.com/article --tags reading,go
+  web-clipper users list --remote https://clips.example.com --token wc_...
+  source <(web-clipper completion bash)`,
+		Args:               cobra.ArbitraryArgs,
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+		SilenceUsage:       true,
+		Run: func(cmd *cobra.Command, args []string) {
+			serve()
+		},
+	}
+
+	root.AddCommand(
+		newUsersCmd(),
+		newTokensCmd(),
+		newClipsCmd(),
+		newStorageCmd(),
+		newAuditCmd(),
+		newMigrateCmd(),
+		newBackupCmd(),
+		newRestoreCmd(),
+		newInstanceCmd(),
+		newConfigCmd(),
+		newLoginCmd(),
+		newClipCmd(),
+		newDevCmd(),
+		newVersionCmd(),
+	)
+	return root
+}
+
+// addRemoteFlags adds --remote/--token to an admin command group; when --remote
+// is set, its subcommands go through the server's admin API instead of the
+// local database and config.
+func addRemoteFlags(cmd *cobra.Command) {
+	var server, token string
+	cmd.PersistentFlags().StringVar(&server, "remote", "", "Manage a remote instance through its admin API (https://host)")
+	cmd.PersistentFlags().StringVar(&token, "token", "", "API token for --remote (defaults to the saved login)")
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if server == "" {
+			return nil
+		}
+		return admin.UseRemote(server, token)
+	}
+}
+
+func newUsersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "users",
+		Short: "Manage users",
+	}
+	addRemoteFlags(cmd)
+
+	var email, path, address string
+	var dryRun bool
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List all users",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.ListUsers(cmd.Context())
+		},
+	}
+
+	show := &cobra.Command{
+		Use:   "show",
+		Short: "Show user details",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.ShowUser(cmd.Context(), email)
+		},
+	}
+
+	setStorage := &cobra.Command{
+		Use:   "set-storage",
+		Short: "Set storage path (omit --path to reset to the default)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.SetStoragePath(cmd.Context(), email, path, dryRun)
+		},
+	}
+	setStorage.Flags().StringVar(&path, "path", "", "Storage path")
+	setStorage.Flags().BoolVar(&dryRun, "dry-run", false, "Validate and print the change without applying it")
+	setStorage.MarkFlagDirname("path")
+
+	migrateStorage := &cobra.Command{
+		Use:   "migrate-storage",
+		Short: "Copy a user's clips to a new storage path, then switch to it (resumable)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Stop cleanly on Ctrl-C so the checkpoint is saved
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return admin.MigrateStorage(ctx, email, path)
+		},
+	}
+	migrateStorage.Flags().StringVar(&path, "path", "", "New storage path (omit to move back to the default)")
+	migrateStorage.MarkFlagDirname("path")
+
+	setKindle := &cobra.Command{
+		Use:   "set-kindle",
+		Short: "Set Send to Kindle address (omit --address to clear it)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.SetKindleEmail(cmd.Context(), email, address)
+		},
+	}
+	setKindle.Flags().StringVar(&address, "address", "", "Kindle device address (name@kindle.com)")
+
+	disable := &cobra.Command{
+		Use:   "disable",
+		Short: "Disable user",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.DisableUser(cmd.Context(), email)
+		},
+	}
+
+	enable := &cobra.Command{
+		Use:   "enable",
+		Short: "Enable user",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.EnableUser(cmd.Context(), email)
+		},
+	}
+
+	var refresh bool
+	usage := &cobra.Command{
+		Use:   "usage",
+		Short: "Show the storage taken by each user's clips, largest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.ShowDiskUsage(cmd.Context(), func(tx *pop.Connection) ([]admin.DiskUsageRow, error) {
+				actions.App() // Loads the server config storage paths come from
+				usages, err := actions.DiskUsages(tx, email, refresh)
+				rows := make([]admin.DiskUsageRow, len(usages))
+				for i, u := range usages {
+					modes := make(map[string]admin.ModeUsageRow, len(u.Modes))
+					for name, m := range u.Modes {
+						modes[name] = admin.ModeUsageRow(m)
+					}
+					rows[i] = admin.DiskUsageRow{
+						Email:        u.Email,
+						Clips:        u.Clips,
+						Bytes:        u.Bytes,
+						TrashedClips: u.TrashedClips,
+						TrashedBytes: u.TrashedBytes,
+						Modes:        modes,
+						ComputedAt:   u.ComputedAt,
+					}
+				}
+				return rows, err
+			})
+		},
+	}
+	usage.Flags().StringVar(&email, "email", "", "Only this user (default: every user)")
+	usage.Flags().BoolVar(&refresh, "refresh", false, "Walk the clip files again rather than use figures cached for storage.usage_cache_minutes")
+
+	for _, sub := range []*cobra.Command{show, setStorage, migrateStorage, setKindle, disable, enable} {
+		sub.Flags().StringVar(&email, "email", "", "User email")
+		sub.MarkFlagRequired("email")
+	}
+
+	cmd.AddCommand(list, show, setStorage, migrateStorage, setKindle, disable, enable, usage)
+	return cmd
+}
+
+func newTokensCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tokens",
+		Short: "Manage service tokens",
+	}
+	addRemoteFlags(cmd)
+
+	var email, name, expiry, id, reason string
+
+	create := &cobra.Command{
+		Use:   "create",
+		Short: "Create service token",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.CreateToken(cmd.Context(), email, name, expiry)
+		},
+	}
+	create.Flags().StringVar(&email, "email", "", "User email")
+	create.Flags().StringVar(&name, "name", "", "Token name (e.g. 'Production API', 'CI/CD Pipeline')")
+	create.Flags().StringVar(&expiry, "expiry", "", "Expiry such as 30d, 24h, 2y or never (default 365d)")
+	create.MarkFlagRequired("email")
+	create.MarkFlagRequired("name")
+	create.RegisterFlagCompletionFunc("expiry", cobra.FixedCompletions(
+		[]string{"30d", "90d", "365d", "never"}, cobra.ShellCompDirectiveNoFileComp))
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List user tokens",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.ListTokens(cmd.Context(), email)
+		},
+	}
+	list.Flags().StringVar(&email, "email", "", "User email")
+	list.MarkFlagRequired("email")
+
+	revoke := &cobra.Command{
+		Use:   "revoke",
+		Short: "Revoke token",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.RevokeToken(cmd.Context(), id, reason)
+		},
+	}
+	revoke.Flags().StringVar(&id, "id", "", "Token ID")
+	revoke.Flags().StringVar(&reason, "reason", "", "Reason recorded with the revocation")
+	revoke.MarkFlagRequired("id")
+
+	cmd.AddCommand(create, list, revoke)
+	return cmd
+}
+
+func newClipsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clips",
+		Short: "Inspect users' clips",
+	}
+	addRemoteFlags(cmd)
+
+	var email string
+	var page, perPage int
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List a user's clips",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if page < 1 {
+				return fmt.Errorf("--page must be at least 1")
+			}
+			if perPage < 1 || perPage > 100 {
+				return fmt.Errorf("--per-page must be between 1 and 100")
+			}
+			return admin.ListClips(cmd.Context(), email, page, perPage)
+		},
+	}
+	list.Flags().StringVar(&email, "email", "", "User email")
+	list.Flags().IntVar(&page, "page", 1, "Page number")
+	list.Flags().IntVar(&perPage, "per-page", 20, "Clips per page (max 100)")
+	list.MarkFlagRequired("email")
+
+	var dryRun bool
+	purgeTrash := &cobra.Command{
+		Use:   "purge-trash",
+		Short: "Permanently delete clips trashed longer than storage.trash_retention_days",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.PurgeTrash(cmd.Context(), dryRun, func(dryRun bool) (models.Clips, error) {
+				actions.App() // Loads the server config the sweep relies on
+				return actions.PurgeExpiredTrash(models.DB, dryRun)
+			})
+		},
+	}
+	purgeTrash.Flags().BoolVar(&dryRun, "dry-run", false, "List the clips that would be deleted without deleting them")
+
+	cleanFolders := &cobra.Command{
+		Use:   "clean-folders",
+		Short: "Remove empty or partial clip folders left by failed captures (older than storage.janitor_min_age_hours)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.CleanFolders(cmd.Context(), dryRun, func(dryRun bool) ([]admin.LeftoverRow, error) {
+				actions.App() // Loads the server config the janitor relies on
+				leftovers, err := actions.CleanClipFolders(models.DB, dryRun)
+				rows := make([]admin.LeftoverRow, len(leftovers))
+				for i, l := range leftovers {
+					rows[i] = admin.LeftoverRow{Path: l.Path, Reason: l.Reason, ModifiedAt: l.ModifiedAt}
+				}
+				return rows, err
+			})
+		},
+	}
+	cleanFolders.Flags().BoolVar(&dryRun, "dry-run", false, "List the leftovers that would be removed without removing them")
+
+	expire := &cobra.Command{
+		Use:   "expire",
+		Short: "Move the clips past their owner's retention rules to the trash",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.ExpireClips(cmd.Context(), email, dryRun, func(dryRun bool) ([]admin.ExpiredRow, error) {
+				actions.App() // Loads the server config the sweep relies on
+				clips, err := actions.ExpireClips(models.DB, email, dryRun)
+				rows := make([]admin.ExpiredRow, len(clips))
+				for i, c := range clips {
+					rows[i] = admin.ExpiredRow(c)
+				}
+				return rows, err
+			})
+		},
+	}
+	expire.Flags().StringVar(&email, "email", "", "Only expire this user's clips (default: every user)")
+	expire.Flags().BoolVar(&dryRun, "dry-run", false, "List the clips that would be moved to the trash without moving them")
+
+	var from, to string
+	var clipIDs []string
+	transfer := &cobra.Command{
+		Use:   "transfer",
+		Short: "Give clips (the whole library without --clip) to another user, moving their folders",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.TransferClips(cmd.Context(), from, to, clipIDs, dryRun,
+				func(tx *pop.Connection, from, to *models.User, clipIDs []uuid.UUID, dryRun bool) ([]admin.TransferredRow, func(), error) {
+					actions.App() // Loads the server config the transfer relies on
+					clips, undo, err := actions.TransferClips(tx, from, to, clipIDs, dryRun)
+					rows := make([]admin.TransferredRow, len(clips))
+					for i, c := range clips {
+						rows[i] = admin.TransferredRow(c)
+					}
+					return rows, undo, err
+				})
+		},
+	}
+	transfer.Flags().StringVar(&from, "from", "", "Email of the current owner")
+	transfer.Flags().StringVar(&to, "to", "", "Email of the new owner")
+	transfer.Flags().StringSliceVar(&clipIDs, "clip", nil, "Clip ID to transfer (repeatable; default: all clips, trash included)")
+	transfer.Flags().BoolVar(&dryRun, "dry-run", false, "List the clips that would be transferred without moving them")
+	transfer.MarkFlagRequired("from")
+	transfer.MarkFlagRequired("to")
+
+	var filter actions.ClipFilter
+	grep := &cobra.Command{
+		Use:   "grep <query>",
+		Short: "Search clips like the API does and print the matching lines of their files",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter.Query = args[0]
+			return admin.GrepClips(cmd.Context(), func() ([]admin.GrepRow, error) {
+				actions.App() // Loads the server config storage paths come from
+				matches, err := actions.GrepClips(models.DB, email, filter)
+				rows := make([]admin.GrepRow, len(matches))
+				for i, m := range matches {
+					rows[i] = admin.GrepRow{Path: m.Path, Line: m.Line, Field: m.Field, Text: m.Text}
+				}
+				return rows, err
+			})
+		},
+	}
+	grep.Flags().StringVar(&email, "email", "", "Only search this user's clips (default: every user)")
+	grep.Flags().StringSliceVar(&filter.Tags, "tag", nil, "Only clips with this tag (repeatable)")
+	grep.Flags().StringVar(&filter.Mode, "mode", "", "Only clips of this mode (article, bookmark, screenshot...)")
+	grep.Flags().StringVar(&filter.Domain, "domain", "", "Only clips from this domain and its subdomains")
+	grep.Flags().StringVar(&filter.From, "from", "", "Only clips clipped on or after this date (YYYY-MM-DD)")
+	grep.Flags().StringVar(&filter.To, "to", "", "Only clips clipped on or before this date (YYYY-MM-DD)")
+	grep.Flags().StringVar(&filter.Archived, "archived", "all", "Archived clips: true, false or all")
+
+	reindex := &cobra.Command{
+		Use:   "reindex",
+		Short: "Recreate the missing clips of a user from the clip folders and notes in their storage",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.ReindexClips(cmd.Context(), email, dryRun,
+				func(tx *pop.Connection, user *models.User, dryRun bool) ([]admin.ReindexedRow, error) {
+					actions.App() // Loads the server config storage paths come from
+					clips, err := actions.ReindexClips(tx, user, dryRun)
+					rows := make([]admin.ReindexedRow, len(clips))
+					for i, c := range clips {
+						rows[i] = admin.ReindexedRow(c)
+					}
+					return rows, err
+				})
+		},
+	}
+	reindex.Flags().StringVar(&email, "email", "", "User email")
+	reindex.Flags().BoolVar(&dryRun, "dry-run", false, "List the clips that would be restored without restoring them")
+	reindex.MarkFlagRequired("email")
+
+	cmd.AddCommand(list, purgeTrash, cleanFolders, expire, transfer, grep, reindex)
+	return cmd
+}
+
+func newAuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Review security-relevant events",
+	}
+	addRemoteFlags(cmd)
+
+	var q admin.AuditQuery
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List stored audit logs (sign-ins, tokens, deletions, admin changes), most recent first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.ListAuditLogs(cmd.Context(), q, func(q admin.AuditQuery) (models.AuditLogs, error) {
+				return actions.FindAuditLogs(models.DB, actions.AuditQuery(q))
+			})
+		},
+	}
+	list.Flags().StringVar(&q.Action, "action", "", "Only this action (e.g. auth.login, token.create, clip.delete)")
+	list.Flags().StringVar(&q.Email, "email", "", "Only events of this user")
+	list.Flags().StringVar(&q.ClipID, "clip", "", "Only events of this clip ID")
+	list.Flags().StringVar(&q.Since, "since", "", "From this date (YYYY-MM-DD or RFC 3339)")
+	list.Flags().StringVar(&q.Until, "until", "", "Up to this date, included")
+	list.Flags().IntVar(&q.Limit, "limit", 100, "Maximum number of events (max 1000)")
+
+	cmd.AddCommand(list)
+	return cmd
+}
+
+func newStorageCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "storage",
+		Short: "Maintain clip storage",
+	}
+	addRemoteFlags(cmd)
+
+	var dryRun bool
+	dedupe := &cobra.Command{
+		Use:   "dedupe",
+		Short: "Store identical media once, hard-linking existing clips' copies to it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.DedupeMedia(cmd.Context(), dryRun, func(dryRun bool) (*admin.DedupeResult, error) {
+				actions.App() // Loads the server config storage paths come from
+				result, err := actions.DedupeMedia(models.DB, dryRun)
+				if err != nil {
+					return nil, err
+				}
+				return (*admin.DedupeResult)(result), nil
+			})
+		},
+	}
+	dedupe.Flags().BoolVar(&dryRun, "dry-run", false, "Report the space that would be saved without changing anything")
+
+	var email string
+	var fixes actions.StorageFixes
+	var fixAll bool
+	check := &cobra.Command{
+		Use:   "check",
+		Short: "Report clips whose files are gone, clip files without a clip and missing media, and fix them",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if fixAll {
+				fixes = actions.StorageFixes{Missing: true, Orphans: true, Media: true}
+			}
+			return admin.CheckStorage(cmd.Context(), func(tx *pop.Connection) ([]admin.StorageIssueRow, error) {
+				actions.App() // Loads the server config storage paths come from
+				issues, err := actions.CheckStorage(tx, email, fixes)
+				rows := make([]admin.StorageIssueRow, len(issues))
+				for i, issue := range issues {
+					rows[i] = admin.StorageIssueRow(issue)
+				}
+				return rows, err
+			})
+		},
+	}
+	check.Flags().StringVar(&email, "email", "", "Only check this user's clips (default: every user)")
+	check.Flags().BoolVar(&fixes.Missing, "fix-missing", false, "Delete the clips whose folder or note is gone")
+	check.Flags().BoolVar(&fixes.Orphans, "fix-orphans", false, "Recreate the clips of clip folders and notes without one (like clips reindex)")
+	check.Flags().BoolVar(&fixes.Media, "fix-media", false, "Replace links to missing media in pages by their text")
+	check.Flags().BoolVar(&fixAll, "fix", false, "Apply every fix")
+
+	keygen := &cobra.Command{
+		Use:   "keygen",
+		Short: "Print a new key for storage.encryption (keep it safe: clips can't be read without it)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			secret, recipient, err := atrest.GenerateKey()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "# public key: %s\n%s\n", recipient, secret)
+			return nil
+		},
+	}
+
+	cmd.AddCommand(dedupe, check, keygen)
+	return cmd
+}
+
+func newMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Run database migrations",
+		Long: `Run the database migrations built into the binary. With MIGRATION_DIR set,
+those of that folder are run instead.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.RunMigrations()
+		},
+	}
+
+	var steps int
+	down := &cobra.Command{
+		Use:   "down",
+		Short: "Roll back the last migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.RollbackMigrations(steps)
+		},
+	}
+	down.Flags().IntVar(&steps, "steps", 1, "Number of migrations to roll back")
+
+	var version string
+	to := &cobra.Command{
+		Use:   "to",
+		Short: "Apply or roll back migrations until the given version is the last one applied",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.MigrateTo(version)
+		},
+	}
+	to.Flags().StringVar(&version, "version", "", "Migration version, as listed by migrate status")
+	to.MarkFlagRequired("version")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show migration status",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.ShowMigrationStatus()
+		},
+	}, down, to)
+	return cmd
+}
+
+func newBackupCmd() *cobra.Command {
+	var out string
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Write a snapshot of the database and clip storage to a .tar.gz archive",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return admin.Backup(ctx, out)
+		},
+	}
+	cmd.Flags().StringVar(&out, "out", "", "Archive to write (web-clipper-backup.tar.gz)")
+	cmd.MarkFlagRequired("out")
+	cmd.MarkFlagFilename("out", "gz")
+	return cmd
+}
+
+func newRestoreCmd() *cobra.Command {
+	var in string
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore the database and clip storage from a backup archive (stop the server first)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.Restore(cmd.Context(), in, force)
+		},
+	}
+	cmd.Flags().StringVar(&in, "in", "", "Archive written by backup")
+	cmd.Flags().BoolVar(&force, "force", false, "Replace a database that already has users")
+	cmd.MarkFlagRequired("in")
+	cmd.MarkFlagFilename("in", "gz")
+	return cmd
+}
+
+func newInstanceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "instance",
+		Short: "Move a whole instance: configuration, database and clip storage",
+	}
+
+	var out string
+	var encrypt bool
+	var keys admin.InstanceKeys
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "Write the configuration (secrets included), database and clip storage to one archive",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return admin.ExportInstance(ctx, out, encrypt, keys)
+		},
+	}
+	export.Flags().StringVar(&out, "out", "", "Archive to write (web-clipper-instance.tar.gz.age)")
+	export.Flags().BoolVar(&encrypt, "encrypt", false, "Encrypt the archive with age, to --recipient keys or a passphrase")
+	export.Flags().StringArrayVar(&keys.Recipients, "recipient", nil, "age public key (age1...) to encrypt to; repeatable")
+	export.MarkFlagRequired("out")
+
+	var in, configPath string
+	var force bool
+	importCmd := &cobra.Command{
+		Use:   "import",
+		Short: "Restore an instance export: configuration, database and clip storage (stop the server first)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return admin.ImportInstance(ctx, in, configPath, keys, force)
+		},
+	}
+	importCmd.Flags().StringVar(&in, "in", "", "Archive written by instance export")
+	importCmd.Flags().StringVar(&configPath, "config", config.DefaultConfigPaths[0], "Where to write the imported config file")
+	importCmd.Flags().StringVar(&keys.IdentityFile, "identity", "", "age identity file to decrypt with (default: the passphrase)")
+	importCmd.Flags().BoolVar(&force, "force", false, "Replace an existing config file and a database that already has users")
+	importCmd.MarkFlagRequired("in")
+
+	for _, sub := range []*cobra.Command{export, importCmd} {
+		sub.Flags().StringVar(&keys.PassphraseFile, "passphrase-file", "", "File holding the passphrase (default: $"+admin.PassphraseEnv+")")
+	}
+
+	cmd.AddCommand(export, importCmd)
+	return cmd
+}
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+
+	var path string
+	var quiet bool
+	check := &cobra.Command{
+		Use:   "check",
+		Short: "Validate the config file and print the effective config, secrets redacted (exits 1 on errors)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.CheckConfig(path, quiet)
+		},
+	}
+	check.Flags().StringVar(&path, "config", "", "Config file to check (default: the one the server loads)")
+	check.Flags().BoolVarP(&quiet, "quiet", "q", false, "Only print problems")
+
+	cmd.AddCommand(check)
+	return cmd
+}
+
+func newLoginCmd() *cobra.Command {
+	var server, token string
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Save credentials for a remote instance",
+		Long: `Save credentials for a remote instance. Without --token, log in with the
+OAuth provider: open the printed address in a browser and enter the code.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return clipclient.Login(cmd.Context(), server, token)
+		},
+	}
+	cmd.Flags().StringVar(&server, "server", "", "Server URL (https://host)")
+	cmd.Flags().StringVar(&token, "token", "", "API token (wc_...), instead of logging in with a browser")
+	cmd.MarkFlagRequired("server")
+	return cmd
+}
+
+func newClipCmd() *cobra.Command {
+	var opts clipclient.ClipOptions
+	cmd := &cobra.Command{
+		Use:   "clip <url>",
+		Short: "Clip a URL via the remote server",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.URL = args[0]
+			return clipclient.Clip(cmd.Context(), opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.Tags, "tags", "", "Comma-separated tags")
+	cmd.Flags().StringVar(&opts.Mode, "mode", "", "Clip mode: article or bookmark")
+	cmd.Flags().StringVar(&opts.Title, "title", "", "Title (defaults to the page title)")
+	cmd.Flags().StringVar(&opts.Notes, "notes", "", "Notes")
+	cmd.Flags().StringVar(&opts.Server, "server", "", "Server URL (overrides the saved login)")
+	cmd.Flags().StringVar(&opts.Token, "token", "", "API token (overrides the saved login)")
+	cmd.RegisterFlagCompletionFunc("mode", cobra.FixedCompletions(
+		[]string{"article", "bookmark"}, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+func newDevCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dev",
+		Short: "Development helpers",
+	}
+
+	var opts admin.SeedOptions
+	seed := &cobra.Command{
+		Use:   "seed",
+		Short: "Generate demo users and clips (with files) for development and load tests",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return admin.Seed(ctx, opts)
+		},
+	}
+	seed.Flags().IntVar(&opts.Clips, "clips", 100, "Number of clips to generate")
+	seed.Flags().IntVar(&opts.Users, "users", 3, "Number of demo users (demoN@example.com) to spread clips over")
+	seed.Flags().StringVar(&opts.Email, "email", "", "Add every clip to this existing user instead of demo users")
+	seed.Flags().Int64Var(&opts.Seed, "seed", 1, "Random seed; the same seed generates the same data")
+	seed.Flags().BoolVar(&opts.Force, "force", false, "Seed even when dev mode is off")
+
+	cmd.AddCommand(seed)
+	return cmd
+}
+
+func newVersionCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Show the version, commit and build date (with --remote, the server's too)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return admin.ShowVersion(cmd.Context())
+		},
+	}
+	addRemoteFlags(cmd)
+	return cmd
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCommandTree(t *testing.T) {
+	root := newRootCmd()
+	for _, path := range [][]string{
+		{"users", "list"},
+		{"users", "set-storage"},
+		{"users", "migrate-storage"},
+		{"users", "usage"},
+		{"storage", "keygen"},
+		{"tokens", "revoke"},
+		{"clips", "list"},
+		{"clips", "expire"},
+		{"audit", "list"},
+		{"migrate", "status"},
+		{"migrate", "down"},
+		{"migrate", "to"},
+		{"backup"},
+		{"restore"},
+		{"instance", "export"},
+		{"instance", "import"},
+		{"dev", "seed"},
+		{"clip"},
+	} {
+		cmd, _, err := root.Find(path)
+		if err != nil || cmd.Name() != path[len(path)-1] {
+			t.Errorf("Find(%v) = %v, %v", path, cmd, err)
+		}
+	}
+
+	// Abbreviations resolve to the unique matching command
+	if cmd, _, err := root.Find([]string{"tok", "rev"}); err != nil || cmd.Name() != "revoke" {
+		t.Errorf("abbreviated Find = %v, %v", cmd, err)
+	}
+}
+
+func TestRequiredFlags(t *testing.T) {
+	root := newRootCmd()
+	root.SetOut(new(bytes.Buffer))
+	root.SetErr(new(bytes.Buffer))
+	root.SetArgs([]string{"users", "show"})
+	if err := root.Execute(); err == nil {
+		t.Error("expected error without --email")
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		root := newRootCmd()
+		out := new(bytes.Buffer)
+		root.SetOut(out)
+		root.SetArgs([]string{"completion", shell})
+		if err := root.Execute(); err != nil {
+			t.Fatalf("completion %s failed: %v", shell, err)
+		}
+		if !bytes.Contains(out.Bytes(), []byte("web-clipper")) {
+			t.Errorf("completion %s script does not mention web-clipper", shell)
+		}
+	}
+}
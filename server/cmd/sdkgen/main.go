@@ -0,0 +1,61 @@
+// Command sdkgen generates the Go and TypeScript API clients from the
+// OpenAPI spec. It is run by `go generate ./client`.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"server/internal/openapi"
+)
+
+func main() {
+	specPath := flag.String("spec", "api/openapi.yaml", "OpenAPI spec to read")
+	goOut := flag.String("go", "", "Go file to write (package client)")
+	tsOut := flag.String("ts", "", "TypeScript file to write")
+	flag.Parse()
+
+	if err := run(*specPath, *goOut, *tsOut); err != nil {
+		fmt.Fprintln(os.Stderr, "sdkgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(specPath, goOut, tsOut string) error {
+	data, err := os.ReadFile(specPath)
+	if err != nil {
+		return err
+	}
+	spec, err := openapi.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	// Generated files name the spec relative to the server module
+	source := filepath.ToSlash(filepath.Join(filepath.Base(filepath.Dir(specPath)), filepath.Base(specPath)))
+
+	if goOut != "" {
+		src, err := spec.GenerateGo("client", source)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(goOut, src, 0644); err != nil {
+			return err
+		}
+	}
+	if tsOut != "" {
+		src, err := spec.GenerateTS(source)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(tsOut), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(tsOut, src, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -30,7 +30,14 @@ var _ = grift.Namespace("users", func() {
 	grift.Add("set-storage", func(c *grift.Context) error {
 		email := getArg(c, "email")
 		path := getArg(c, "path")
-		return admin.SetStoragePath(context.Background(), email, path)
+		return admin.SetStoragePath(context.Background(), email, path, false)
+	})
+
+	grift.Desc("set-kindle", "Set Send to Kindle address for a user (--email=x --address=y)")
+	grift.Add("set-kindle", func(c *grift.Context) error {
+		email := getArg(c, "email")
+		address := getArg(c, "address")
+		return admin.SetKindleEmail(context.Background(), email, address)
 	})
 
 	grift.Desc("disable", "Disable a user account (--email=x)")
@@ -0,0 +1,452 @@
+package admin
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"server/internal/progress"
+	"server/models"
+
+	"github.com/gobuffalo/pop/v6"
+)
+
+// A backup is a gzipped tar archive: manifest.json, a snapshot of the
+// database taken with SQLite's backup API, then the files of every storage
+// directory under storage/<root>/. The database is snapshotted before the
+// files are read, so a clip saved while the backup runs may only have its
+// files in it: `clips reindex` brings its row back after a restore.
+
+// Entries of a backup archive
+const (
+	backupVersion      = 1
+	backupManifestName = "manifest.json"
+	backupDatabaseName = "database.sqlite3"
+	backupStorageDir   = "storage"
+)
+
+// backupManifest describes the content of a backup
+type backupManifest struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+	Roots     []backupRoot    `json:"roots"`
+	Config    *instanceConfig `json:"config,omitempty"` // Instance exports only
+}
+
+// backupRoot is a storage directory, archived under storage/<Name>/
+type backupRoot struct {
+	Name string `json:"name"`
+	Path string `json:"path"`           // Where it was backed up from
+	Base bool   `json:"base,omitempty"` // The base path, restored to the configured one
+}
+
+// backupStats counts what a backup or restore copied
+type backupStats struct {
+	Files int
+	Links int // Files hard-linked to another one (deduplicated media)
+	Bytes int64
+}
+
+// Backup writes a backup of the database and of every storage directory
+// (the base path, users' clip directories and absolute collection paths) to
+// out. The archive is written next to out and renamed once complete.
+func Backup(ctx context.Context, out string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	roots, err := storageRoots(models.DB, cfg.Storage.BasePath)
+	if err != nil {
+		return err
+	}
+
+	tmp := out + ".partial"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	stats, err := writeBackup(ctx, f, models.DB, roots, nil, tmp)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, out)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("backup failed: %w", err)
+	}
+
+	fmt.Printf("Backed up the database and %d files (%s) from %d storage directories to %s\n",
+		stats.Files, progress.FormatBytes(stats.Bytes), len(roots), out)
+	return nil
+}
+
+// Restore restores a backup written by Backup: the files of its base path go
+// to the configured base path, other storage directories to where they were,
+// and the database replaces the configured one. The server must be stopped;
+// a database that already has users is only replaced with force.
+func Restore(ctx context.Context, in string, force bool) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	dbPath, err := databasePath(models.DB)
+	if err != nil {
+		return err
+	}
+	if !force {
+		// A missing users table is a database yet to be created
+		if n, err := models.DB.Count(&models.Users{}); err == nil && n > 0 {
+			return fmt.Errorf("the database already has %d users: stop the server and pass --force to replace it", n)
+		}
+	}
+
+	f, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	manifest, stats, err := readBackup(ctx, f, dbPath, cfg.Storage.BasePath)
+	if err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+
+	fmt.Printf("Restored the database and %d files (%s) from the backup of %s\n",
+		stats.Files, progress.FormatBytes(stats.Bytes), manifest.CreatedAt.Format(time.RFC3339))
+	return nil
+}
+
+// databasePath returns the file of a SQLite connection
+func databasePath(db *pop.Connection) (string, error) {
+	if db.Dialect.Name() != "sqlite3" {
+		return "", fmt.Errorf("backups support SQLite databases only, not %s", db.Dialect.Name())
+	}
+	return db.Dialect.Details().Database, nil
+}
+
+// storageRoots lists the directories clips are stored in: the base path,
+// then the clip directories of users and absolute storage paths of
+// collections and clips that aren't inside another one.
+func storageRoots(db *pop.Connection, base string) ([]backupRoot, error) {
+	users := models.Users{}
+	if err := db.Select("clip_directory").Where("clip_directory IS NOT NULL AND clip_directory != ''").All(&users); err != nil {
+		return nil, err
+	}
+	collections := models.Collections{}
+	if err := db.Select("storage_path").Where("storage_path IS NOT NULL AND storage_path != ''").All(&collections); err != nil {
+		return nil, err
+	}
+	clips := models.Clips{}
+	if err := db.Select("storage_path").Where("storage_path IS NOT NULL AND storage_path != ''").All(&clips); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, user := range users {
+		paths = append(paths, user.ClipDirectory.String)
+	}
+	for _, collection := range collections {
+		paths = append(paths, collection.StoragePath.String)
+	}
+	for _, clip := range clips {
+		paths = append(paths, clip.StoragePath.String)
+	}
+	sort.Strings(paths)
+
+	base = filepath.Clean(base)
+	roots := []backupRoot{{Name: "0", Path: base, Base: true}}
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			continue // Inside the user's clip directory
+		}
+		p = filepath.Clean(p)
+		covered := false
+		for _, root := range roots {
+			if rel, err := filepath.Rel(root.Path, p); err == nil && filepath.IsLocal(rel) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			roots = append(roots, backupRoot{Name: strconv.Itoa(len(roots)), Path: p})
+		}
+	}
+	return roots, nil
+}
+
+// writeBackup writes the backup archive of db and roots, with the instance
+// configuration if any, to w, leaving out the file skip (the archive itself,
+// when written inside a root)
+func writeBackup(ctx context.Context, w io.Writer, db *pop.Connection, roots []backupRoot, conf *instanceConfig, skip string) (*backupStats, error) {
+	dbPath, err := databasePath(db)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	stats := &backupStats{}
+
+	manifest, err := json.MarshalIndent(backupManifest{Version: backupVersion, CreatedAt: time.Now().UTC(), Roots: roots, Config: conf}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: backupManifestName, Mode: 0644, Size: int64(len(manifest)), ModTime: time.Now()}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "web-clipper-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	snapshot := filepath.Join(dir, backupDatabaseName)
+	if err := copyDatabase(ctx, dbPath, snapshot); err != nil {
+		return nil, fmt.Errorf("failed to snapshot the database: %w", err)
+	}
+	if _, err := addBackupFile(tw, snapshot, backupDatabaseName); err != nil {
+		return nil, err
+	}
+
+	skip, _ = filepath.Abs(skip)
+	linked := map[[2]uint64]string{}
+	for _, root := range roots {
+		if _, err := os.Stat(root.Path); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.WalkDir(root.Path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if abs, _ := filepath.Abs(p); abs == skip {
+				return nil
+			}
+			rel, err := filepath.Rel(root.Path, p)
+			if err != nil {
+				return err
+			}
+			name := path.Join(backupStorageDir, root.Name, filepath.ToSlash(rel))
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			switch {
+			case d.IsDir():
+				return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: int64(info.Mode().Perm()), ModTime: info.ModTime()})
+			case !d.Type().IsRegular():
+				return nil // Clip storage only holds regular files
+			}
+
+			// Hard links to one file (deduplicated media) are stored once
+			if id, ok := fileID(info); ok {
+				if target, seen := linked[id]; seen {
+					stats.Links++
+					return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeLink, Name: name, Linkname: target, Mode: int64(info.Mode().Perm()), ModTime: info.ModTime()})
+				}
+				linked[id] = name
+			}
+			n, err := addBackupFile(tw, p, name)
+			stats.Files++
+			stats.Bytes += n
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to back up %s: %w", root.Path, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return stats, gz.Close()
+}
+
+// addBackupFile writes the file at p to the archive as name
+func addBackupFile(tw *tar.Writer, p, name string) (int64, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	hdr := &tar.Header{Name: name, Mode: int64(info.Mode().Perm()), Size: info.Size(), ModTime: info.ModTime()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return 0, err
+	}
+	return io.Copy(tw, f)
+}
+
+// fileID identifies a file with several hard links (ok is false otherwise)
+func fileID(info fs.FileInfo) (id [2]uint64, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink < 2 {
+		return id, false
+	}
+	return [2]uint64{uint64(st.Dev), uint64(st.Ino)}, true
+}
+
+// readBackup restores the archive read from r: storage directories to
+// their paths (the base one to base), then the database over dbPath
+func readBackup(ctx context.Context, r io.Reader, dbPath, base string) (*backupManifest, *backupStats, error) {
+	tr, manifest, err := openBackup(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	stats, err := restoreBackup(ctx, tr, manifest, dbPath, base)
+	return manifest, stats, err
+}
+
+// openBackup reads the manifest of the archive read from r, leaving the
+// returned reader at the entries that follow
+func openBackup(r io.Reader) (*tar.Reader, *backupManifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a backup archive: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != backupManifestName {
+		return nil, nil, fmt.Errorf("not a backup archive: %s is missing", backupManifestName)
+	}
+	manifest := &backupManifest{}
+	if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %w", backupManifestName, err)
+	}
+	if manifest.Version > backupVersion {
+		return nil, nil, fmt.Errorf("backup version %d is newer than this web-clipper supports (%d)", manifest.Version, backupVersion)
+	}
+	return tr, manifest, nil
+}
+
+// restoreBackup restores the entries of an archive opened with openBackup
+func restoreBackup(ctx context.Context, tr *tar.Reader, manifest *backupManifest, dbPath, base string) (*backupStats, error) {
+	dirs := map[string]string{}
+	for _, root := range manifest.Roots {
+		dirs[root.Name] = root.Path
+		if root.Base {
+			dirs[root.Name] = base
+		}
+	}
+
+	// target maps an archive name under storage/ to its path on disk
+	target := func(name string) (string, error) {
+		parts := strings.SplitN(strings.TrimSuffix(name, "/"), "/", 3)
+		if len(parts) < 2 || parts[0] != backupStorageDir || dirs[parts[1]] == "" {
+			return "", fmt.Errorf("unexpected entry %s", name)
+		}
+		if len(parts) == 2 {
+			return dirs[parts[1]], nil
+		}
+		rel := filepath.FromSlash(parts[2])
+		if !filepath.IsLocal(rel) {
+			return "", fmt.Errorf("unsafe entry %s", name)
+		}
+		return filepath.Join(dirs[parts[1]], rel), nil
+	}
+
+	// The snapshot is copied over the database once the files are in place
+	dir, err := os.MkdirTemp("", "web-clipper-restore-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	snapshot := ""
+
+	stats := &backupStats{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Name == backupDatabaseName {
+			snapshot = filepath.Join(dir, backupDatabaseName)
+			if _, err := extractFile(tr, snapshot, 0600); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		p, err := target(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(p, 0755); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			n, err := extractFile(tr, p, fs.FileMode(hdr.Mode).Perm())
+			if err != nil {
+				return nil, err
+			}
+			stats.Files++
+			stats.Bytes += n
+		case tar.TypeLink:
+			src, err := target(hdr.Linkname)
+			if err != nil {
+				return nil, err
+			}
+			os.Remove(p)
+			if err := os.Link(src, p); err != nil {
+				return nil, err
+			}
+			stats.Links++
+		default:
+			return nil, fmt.Errorf("unexpected entry %s", hdr.Name)
+		}
+	}
+
+	if snapshot == "" {
+		return nil, fmt.Errorf("the backup has no database")
+	}
+	if err := copyDatabase(ctx, snapshot, dbPath); err != nil {
+		return nil, fmt.Errorf("failed to restore the database: %w", err)
+	}
+	return stats, nil
+}
+
+// extractFile writes the current archive entry to p
+func extractFile(r io.Reader, p string, perm fs.FileMode) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return 0, err
+	}
+	out, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
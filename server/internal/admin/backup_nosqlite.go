@@ -0,0 +1,13 @@
+//go:build !sqlite
+
+package admin
+
+import (
+	"context"
+	"fmt"
+)
+
+// copyDatabase needs the SQLite driver, only built in with -tags sqlite
+func copyDatabase(ctx context.Context, src, dst string) error {
+	return fmt.Errorf("SQLite support was not compiled into the binary (build with -tags sqlite)")
+}
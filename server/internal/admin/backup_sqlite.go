@@ -0,0 +1,72 @@
+//go:build sqlite
+
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// copyDatabase copies the SQLite database at src over the one at dst
+// (created if missing) with SQLite's online backup API: the copy is a
+// consistent snapshot even while the server writes to src.
+func copyDatabase(ctx context.Context, src, dst string) error {
+	srcDB, err := sql.Open("sqlite3", src)
+	if err != nil {
+		return err
+	}
+	defer srcDB.Close()
+	dstDB, err := sql.Open("sqlite3", dst)
+	if err != nil {
+		return err
+	}
+	defer dstDB.Close()
+
+	srcConn, err := srcDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer srcConn.Close()
+	dstConn, err := dstDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", dst, err)
+	}
+	defer dstConn.Close()
+
+	return dstConn.Raw(func(d interface{}) error {
+		return srcConn.Raw(func(s interface{}) error {
+			dc, ok := d.(*sqlite3.SQLiteConn)
+			sc, ok2 := s.(*sqlite3.SQLiteConn)
+			if !ok || !ok2 {
+				return fmt.Errorf("not a SQLite connection")
+			}
+			backup, err := dc.Backup("main", sc, "main")
+			if err != nil {
+				return err
+			}
+			// Copy every page in one step; retry while another connection
+			// holds a lock
+			for {
+				done, err := backup.Step(-1)
+				if err != nil {
+					backup.Close()
+					return err
+				}
+				if done {
+					break
+				}
+				select {
+				case <-ctx.Done():
+					backup.Close()
+					return ctx.Err()
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+			return backup.Finish()
+		})
+	})
+}
@@ -0,0 +1,121 @@
+package admin
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"server/internal/testkit"
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+)
+
+func TestBackupRestore(t *testing.T) {
+	kit := testkit.New(t)
+	if kit.DB.Dialect.Name() != "sqlite3" {
+		t.Skip("backups snapshot SQLite databases only")
+	}
+	user := kit.CreateUser()
+	clip := kit.CreateClip(user, testkit.WithMedia("hero.png", []byte("png")))
+	own := filepath.Join(t.TempDir(), "own")
+	other := kit.CreateUser(func(u *models.User) { u.ClipDirectory = nulls.NewString(own) })
+	otherClip := kit.CreateClip(other)
+
+	// A deduplicated medium, hard-linked into the clip
+	media := filepath.Join(kit.StorageRoot, clip.Path, "media")
+	if err := os.Link(filepath.Join(media, "hero.png"), filepath.Join(media, "copy.png")); err != nil {
+		t.Fatal(err)
+	}
+
+	roots, err := storageRoots(kit.DB, kit.StorageRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 2 || !roots[0].Base || roots[1].Path != own {
+		t.Fatalf("storageRoots = %+v", roots)
+	}
+
+	var archive bytes.Buffer
+	stats, err := writeBackup(context.Background(), &archive, kit.DB, roots, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Links != 1 {
+		t.Errorf("links = %d, want 1", stats.Links)
+	}
+
+	// Restore to a new base path and database; the user's own directory
+	// goes back where it was
+	if err := os.RemoveAll(own); err != nil {
+		t.Fatal(err)
+	}
+	base := t.TempDir()
+	dbPath := filepath.Join(t.TempDir(), "restored.sqlite3")
+	manifest, restored, err := readBackup(context.Background(), &archive, dbPath, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Version != backupVersion || restored.Files != stats.Files || restored.Links != 1 {
+		t.Errorf("restored %+v of %+v", restored, stats)
+	}
+
+	a, err := os.Stat(filepath.Join(base, clip.Path, "media", "hero.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.Stat(filepath.Join(base, clip.Path, "media", "copy.png"))
+	if err != nil || !os.SameFile(a, b) {
+		t.Errorf("copy.png is not a hard link of hero.png: %v", err)
+	}
+	if entries, err := os.ReadDir(filepath.Join(own, otherClip.Path)); err != nil || len(entries) == 0 {
+		t.Errorf("the user's clip directory wasn't restored: %v", err)
+	}
+
+	db, err := pop.NewConnection(&pop.ConnectionDetails{Dialect: "sqlite3", Database: dbPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Find(&models.Clip{}, clip.ID); err != nil {
+		t.Errorf("clip not restored: %v", err)
+	}
+	if n, err := db.Count(&models.Users{}); err != nil || n != 2 {
+		t.Errorf("users = %d, %v", n, err)
+	}
+}
+
+func TestRestoreRejectsUnsafeEntries(t *testing.T) {
+	if _, _, err := readBackup(context.Background(), bytes.NewReader([]byte("not a backup")), "db", t.TempDir()); err == nil {
+		t.Error("expected an error for an invalid archive")
+	}
+
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	for _, entry := range [][2]string{
+		{backupManifestName, `{"version": 1, "roots": [{"name": "0", "path": "/srv/clips", "base": true}]}`},
+		{"storage/0/../../evil.sh", "#!/bin/sh"},
+	} {
+		tw.WriteHeader(&tar.Header{Name: entry[0], Mode: 0644, Size: int64(len(entry[1]))})
+		tw.Write([]byte(entry[1]))
+	}
+	tw.Close()
+	gz.Close()
+
+	base := t.TempDir()
+	if _, _, err := readBackup(context.Background(), &archive, filepath.Join(base, "db"), filepath.Join(base, "clips")); err == nil {
+		t.Error("expected an error for an entry outside the storage directory")
+	}
+	if _, err := os.Stat(filepath.Join(base, "evil.sh")); !os.IsNotExist(err) {
+		t.Error("an entry was written outside the storage directory")
+	}
+}
@@ -0,0 +1,458 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// clipRow is the subset of clip fields shown by the clips commands.
+type clipRow struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	URL       string    `json:"url"`
+	Mode      string    `json:"mode"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListClips lists a user's most recent clips.
+func ListClips(ctx context.Context, email string, page, perPage int) error {
+	if email == "" {
+		return fmt.Errorf("--email is required")
+	}
+
+	var (
+		rows  []clipRow
+		total int
+		err   error
+	)
+	if remote != nil {
+		rows, total, err = listRemoteClips(ctx, email, page, perPage)
+	} else {
+		rows, total, err = listLocalClips(email, page, perPage)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list clips: %w", err)
+	}
+
+	if len(rows) == 0 {
+		fmt.Printf("No clips found for user: %s\n", email)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tMODE\tCREATED\tTITLE\tURL")
+	fmt.Fprintln(w, "--\t----\t-------\t-----\t---")
+	for _, c := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			c.ID, c.Mode, c.CreatedAt.Format("2006-01-02 15:04:05"), c.Title, c.URL)
+	}
+	w.Flush()
+	fmt.Printf("\nShowing %d of %d clips (page %d)\n", len(rows), total, page)
+
+	return nil
+}
+
+// listLocalClips reads clips directly from the database
+func listLocalClips(email string, page, perPage int) ([]clipRow, int, error) {
+	user := &models.User{}
+	if err := models.DB.Where("email = ?", email).First(user); err != nil {
+		return nil, 0, fmt.Errorf("user not found: %s", email)
+	}
+
+	clips, total, err := models.FindClipsByUserID(models.DB, user.ID, page, perPage)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows := make([]clipRow, len(clips))
+	for i, c := range clips {
+		rows[i] = clipRow{ID: c.ID.String(), Title: c.Title, URL: c.URL, Mode: c.Mode, CreatedAt: c.CreatedAt}
+	}
+	return rows, total, nil
+}
+
+// listRemoteClips fetches clips through the admin API
+func listRemoteClips(ctx context.Context, email string, page, perPage int) ([]clipRow, int, error) {
+	var resp struct {
+		Clips []clipRow `json:"clips"`
+		Total int       `json:"total"`
+	}
+	path := fmt.Sprintf("%s/clips?page=%d&per_page=%d", userPath(email), page, perPage)
+	if err := remote.Do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, 0, remoteError(err)
+	}
+	return resp.Clips, resp.Total, nil
+}
+
+// purgedRow is a clip selected by the trash retention sweep.
+type purgedRow struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Title     string    `json:"title"`
+	Path      string    `json:"path"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// PurgeTrash runs the trash retention sweep now. Locally it calls purge (the
+// server's sweep, which needs the server config); in remote mode it goes
+// through the admin API. With dryRun nothing is deleted.
+func PurgeTrash(ctx context.Context, dryRun bool, purge func(dryRun bool) (models.Clips, error)) error {
+	var rows []purgedRow
+	if remote != nil {
+		var resp struct {
+			Clips []purgedRow `json:"clips"`
+		}
+		path := fmt.Sprintf("/api/v1/admin/trash/purge?dry_run=%t", dryRun)
+		if err := remote.Do(ctx, http.MethodPost, path, nil, &resp); err != nil {
+			return fmt.Errorf("failed to purge trash: %w", remoteError(err))
+		}
+		rows = resp.Clips
+	} else {
+		clips, err := purge(dryRun)
+		if err != nil {
+			return fmt.Errorf("failed to purge trash: %w", err)
+		}
+		if !dryRun {
+			recordAudit("trash.purge", "clips", len(clips))
+		}
+		for _, c := range clips {
+			rows = append(rows, purgedRow{
+				ID: c.ID.String(), UserID: c.UserID.String(), Title: c.Title, Path: c.Path, DeletedAt: c.DeletedAt.Time,
+			})
+		}
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No trashed clips past the retention period.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSER ID\tDELETED\tPATH\tTITLE")
+	fmt.Fprintln(w, "--\t-------\t-------\t----\t-----")
+	for _, c := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			c.ID, c.UserID, c.DeletedAt.Format("2006-01-02 15:04:05"), c.Path, c.Title)
+	}
+	w.Flush()
+
+	if dryRun {
+		fmt.Printf("\nDry run: would permanently delete %d clips (no changes made)\n", len(rows))
+	} else {
+		fmt.Printf("\nPermanently deleted %d clips\n", len(rows))
+	}
+	return nil
+}
+
+// LeftoverRow is a leftover of a failed capture removed by the janitor.
+type LeftoverRow struct {
+	Path       string    `json:"path"`
+	Reason     string    `json:"reason"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// CleanFolders runs the clip folder janitor now. Locally it calls clean (the
+// server's janitor, which needs the server config); in remote mode it goes
+// through the admin API. With dryRun nothing is removed.
+func CleanFolders(ctx context.Context, dryRun bool, clean func(dryRun bool) ([]LeftoverRow, error)) error {
+	var rows []LeftoverRow
+	if remote != nil {
+		var resp struct {
+			Removed []LeftoverRow `json:"removed"`
+		}
+		path := fmt.Sprintf("/api/v1/admin/janitor?dry_run=%t", dryRun)
+		if err := remote.Do(ctx, http.MethodPost, path, nil, &resp); err != nil {
+			return fmt.Errorf("failed to clean clip folders: %w", remoteError(err))
+		}
+		rows = resp.Removed
+	} else {
+		var err error
+		rows, err = clean(dryRun)
+		if err != nil {
+			return fmt.Errorf("failed to clean clip folders: %w", err)
+		}
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No leftover clip folders.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REASON\tMODIFIED\tPATH")
+	fmt.Fprintln(w, "------\t--------\t----")
+	for _, l := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", l.Reason, l.ModifiedAt.Format("2006-01-02 15:04:05"), l.Path)
+	}
+	w.Flush()
+
+	if dryRun {
+		fmt.Printf("\nDry run: would remove %d leftovers (no changes made)\n", len(rows))
+	} else {
+		fmt.Printf("\nRemoved %d leftovers\n", len(rows))
+	}
+	return nil
+}
+
+// ExpiredRow is a clip moved to the trash by a retention rule.
+type ExpiredRow struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Title     string    `json:"title"`
+	Path      string    `json:"path"`
+	Mode      string    `json:"mode"`
+	CreatedAt time.Time `json:"created_at"`
+	Rule      string    `json:"rule"`
+}
+
+// ExpireClips applies the retention rules now, for one user or every user
+// without an email. Locally it calls expire (the server's sweep, which needs
+// the server config); in remote mode it goes through the admin API. With
+// dryRun nothing is moved to the trash.
+func ExpireClips(ctx context.Context, email string, dryRun bool, expire func(dryRun bool) ([]ExpiredRow, error)) error {
+	var rows []ExpiredRow
+	if remote != nil {
+		var resp struct {
+			Expired []ExpiredRow `json:"expired"`
+		}
+		query := url.Values{"dry_run": {strconv.FormatBool(dryRun)}}
+		if email != "" {
+			query.Set("email", email)
+		}
+		if err := remote.Do(ctx, http.MethodPost, "/api/v1/admin/retention?"+query.Encode(), nil, &resp); err != nil {
+			return fmt.Errorf("failed to expire clips: %w", remoteError(err))
+		}
+		rows = resp.Expired
+	} else {
+		var err error
+		rows, err = expire(dryRun)
+		if err != nil {
+			return fmt.Errorf("failed to expire clips: %w", err)
+		}
+		if !dryRun {
+			recordAudit("clips.expire", "email", email, "clips", len(rows))
+		}
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No clips past their retention rules.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSER\tCREATED\tRULE\tPATH\tTITLE")
+	fmt.Fprintln(w, "--\t----\t-------\t----\t----\t-----")
+	for _, c := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			c.ID, c.Email, c.CreatedAt.Format("2006-01-02 15:04:05"), c.Rule, c.Path, c.Title)
+	}
+	w.Flush()
+
+	if dryRun {
+		fmt.Printf("\nDry run: would move %d clips to the trash (no changes made)\n", len(rows))
+	} else {
+		fmt.Printf("\nMoved %d clips to the trash\n", len(rows))
+	}
+	return nil
+}
+
+// TransferredRow is a clip given to another user.
+type TransferredRow struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Path    string `json:"path"`
+	Trashed bool   `json:"trashed"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+// TransferFunc transfers clips between users within tx; it is the server's
+// transfer, which needs the server config. undo moves the folders back if tx
+// does not commit.
+type TransferFunc func(tx *pop.Connection, from, to *models.User, clipIDs []uuid.UUID, dryRun bool) (rows []TransferredRow, undo func(), err error)
+
+// TransferClips gives clips (the whole library without clipIDs) of the user
+// fromEmail to the user toEmail, moving their folders into the target's
+// storage. In remote mode it goes through the admin API. With dryRun nothing
+// changes.
+func TransferClips(ctx context.Context, fromEmail, toEmail string, clipIDs []string, dryRun bool, transfer TransferFunc) error {
+	if fromEmail == "" || toEmail == "" {
+		return fmt.Errorf("--from and --to are required")
+	}
+
+	var rows []TransferredRow
+	if remote != nil {
+		var resp struct {
+			Clips []TransferredRow `json:"clips"`
+		}
+		body := map[string]interface{}{"to": toEmail, "clip_ids": clipIDs, "dry_run": dryRun}
+		if err := remote.Do(ctx, http.MethodPost, userPath(fromEmail)+"/transfer", body, &resp); err != nil {
+			return fmt.Errorf("failed to transfer clips: %w", remoteError(err))
+		}
+		rows = resp.Clips
+	} else {
+		var err error
+		rows, err = transferLocalClips(fromEmail, toEmail, clipIDs, dryRun, transfer)
+		if err != nil {
+			return fmt.Errorf("failed to transfer clips: %w", err)
+		}
+		if !dryRun {
+			recordAudit("clips.transfer", "from", fromEmail, "to", toEmail, "clips", len(rows))
+		}
+	}
+
+	if len(rows) == 0 {
+		fmt.Printf("No clips to transfer from %s.\n", fromEmail)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTRASHED\tPATH\tTITLE")
+	fmt.Fprintln(w, "--\t-------\t----\t-----")
+	for _, c := range rows {
+		fmt.Fprintf(w, "%s\t%t\t%s\t%s\n", c.ID, c.Trashed, c.To, c.Title)
+	}
+	w.Flush()
+
+	if dryRun {
+		fmt.Printf("\nDry run: would transfer %d clips from %s to %s (no changes made)\n", len(rows), fromEmail, toEmail)
+	} else {
+		fmt.Printf("\nTransferred %d clips from %s to %s\n", len(rows), fromEmail, toEmail)
+	}
+	return nil
+}
+
+// transferLocalClips runs the transfer in a database transaction, moving the
+// folders back if it does not commit
+func transferLocalClips(fromEmail, toEmail string, clipIDs []string, dryRun bool, transfer TransferFunc) ([]TransferredRow, error) {
+	ids := make([]uuid.UUID, len(clipIDs))
+	for i, s := range clipIDs {
+		id, err := uuid.FromString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid clip ID: %s", s)
+		}
+		ids[i] = id
+	}
+
+	var rows []TransferredRow
+	var undo func()
+	err := models.DB.Transaction(func(tx *pop.Connection) error {
+		from, to := &models.User{}, &models.User{}
+		if err := tx.Where("email = ?", fromEmail).First(from); err != nil {
+			return fmt.Errorf("user not found: %s", fromEmail)
+		}
+		if err := tx.Where("email = ?", toEmail).First(to); err != nil {
+			return fmt.Errorf("user not found: %s", toEmail)
+		}
+		var err error
+		rows, undo, err = transfer(tx, from, to, ids, dryRun)
+		return err
+	})
+	if err != nil && undo != nil {
+		undo()
+	}
+	return rows, err
+}
+
+// GrepRow is a line of a clip matching a text search.
+type GrepRow struct {
+	Path  string
+	Line  int    // 0 for a match in a field
+	Field string // Searched field the match is in, when not in the page file
+	Text  string
+}
+
+// GrepClips prints the matching lines of the clips found by a text search,
+// grep style (path:line:text). grep is the server's search, which reads the
+// clip files; there is no remote mode.
+func GrepClips(ctx context.Context, grep func() ([]GrepRow, error)) error {
+	if remote != nil {
+		return fmt.Errorf("grep reads clip files on the server and cannot run with --remote")
+	}
+
+	rows, err := grep()
+	if err != nil {
+		return fmt.Errorf("failed to search clips: %w", err)
+	}
+	if len(rows) == 0 {
+		fmt.Fprintln(os.Stderr, "No matching clips.")
+		return nil
+	}
+	for _, r := range rows {
+		if r.Field != "" {
+			fmt.Printf("%s:[%s]:%s\n", r.Path, r.Field, r.Text)
+		} else {
+			fmt.Printf("%s:%d:%s\n", r.Path, r.Line, r.Text)
+		}
+	}
+	return nil
+}
+
+// ReindexedRow is a clip rebuilt from its files.
+type ReindexedRow struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Path   string `json:"path"`
+	Source string `json:"source"`
+}
+
+// ReindexFunc rebuilds the missing clips of user within tx; it is the
+// server's reindex, which needs the server config.
+type ReindexFunc func(tx *pop.Connection, user *models.User, dryRun bool) ([]ReindexedRow, error)
+
+// ReindexClips recreates the missing rows of a user's clips from the clip
+// folders and notes in their storage. reindex reads the clip files; there is
+// no remote mode. With dryRun nothing changes.
+func ReindexClips(ctx context.Context, email string, dryRun bool, reindex ReindexFunc) error {
+	if email == "" {
+		return fmt.Errorf("--email is required")
+	}
+	if remote != nil {
+		return fmt.Errorf("reindex reads clip files on the server and cannot run with --remote")
+	}
+
+	var rows []ReindexedRow
+	err := models.DB.Transaction(func(tx *pop.Connection) error {
+		user := &models.User{}
+		if err := tx.Where("email = ?", email).First(user); err != nil {
+			return fmt.Errorf("user not found: %s", email)
+		}
+		var err error
+		rows, err = reindex(tx, user, dryRun)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reindex clips: %w", err)
+	}
+
+	if len(rows) == 0 {
+		fmt.Printf("No missing clips for %s.\n", email)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSOURCE\tPATH\tTITLE")
+	fmt.Fprintln(w, "--\t------\t----\t-----")
+	for _, c := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.ID, c.Source, c.Path, c.Title)
+	}
+	w.Flush()
+
+	if dryRun {
+		fmt.Printf("\nDry run: would restore %d clips for %s (no changes made)\n", len(rows), email)
+	} else {
+		fmt.Printf("\nRestored %d clips for %s\n", len(rows), email)
+	}
+	return nil
+}
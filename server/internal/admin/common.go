@@ -5,60 +5,25 @@ import (
 	"strings"
 
 	"server/internal/config"
+	"server/internal/logging"
 	"server/internal/repository"
 	"server/internal/services"
 	"server/models"
 )
 
-// CLILogger implements services.Logger for CLI output.
-type CLILogger struct{}
-
-func (l *CLILogger) Info(msg string, args ...interface{}) {
-	fmt.Printf("[INFO] %s", msg)
-	for i := 0; i < len(args); i += 2 {
-		if i+1 < len(args) {
-			fmt.Printf(" %v=%v", args[i], args[i+1])
-		}
-	}
-	fmt.Println()
-}
-
-func (l *CLILogger) Warn(msg string, args ...interface{}) {
-	fmt.Printf("[WARN] %s", msg)
-	for i := 0; i < len(args); i += 2 {
-		if i+1 < len(args) {
-			fmt.Printf(" %v=%v", args[i], args[i+1])
-		}
-	}
-	fmt.Println()
-}
-
-func (l *CLILogger) Error(msg string, args ...interface{}) {
-	fmt.Printf("[ERROR] %s", msg)
-	for i := 0; i < len(args); i += 2 {
-		if i+1 < len(args) {
-			fmt.Printf(" %v=%v", args[i], args[i+1])
-		}
-	}
-	fmt.Println()
-}
-
 // buildServices creates the service instances for user CLI commands.
 func buildServices() (services.UserService, error) {
-	// Find config file (searches production and development paths)
-	configPath, err := config.FindConfigPath()
-	if err != nil {
-		return nil, fmt.Errorf("failed to find config: %w", err)
+	if remote != nil {
+		return &remoteUserService{client: remote}, nil
 	}
 
-	// Load config
-	cfg, err := config.Load(configPath)
+	cfg, err := loadConfig()
 	if err != nil {
-		return nil, fmt.Errorf("failed to load config: %w", err)
+		return nil, err
 	}
 
 	// Create logger
-	logger := &CLILogger{}
+	logger := logging.CLI()
 
 	// Create repository
 	repo := repository.NewPopUserRepository(models.DB)
@@ -72,10 +37,29 @@ func buildServices() (services.UserService, error) {
 	return userService, nil
 }
 
+// loadConfig finds and loads the server config file.
+func loadConfig() (*config.Config, error) {
+	// Find config file (searches production and development paths)
+	configPath, err := config.FindConfigPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find config: %w", err)
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return cfg, nil
+}
+
 // buildTokenServices creates service instances for token management.
 func buildTokenServices() (services.TokenService, error) {
+	if remote != nil {
+		return &remoteTokenService{client: remote}, nil
+	}
+
 	// Create logger
-	logger := &CLILogger{}
+	logger := logging.CLI()
 
 	// Create repositories
 	userRepo := repository.NewPopUserRepository(models.DB)
@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"server/internal/config"
+)
+
+// CheckConfig validates the config file the server would load (or path):
+// the variables it references without a default must be set, then
+// config.Check. Unless quiet, it prints the effective config, override and
+// defaults applied, with its secrets redacted. It fails on errors, not on
+// warnings.
+func CheckConfig(path string, quiet bool) error {
+	if path == "" {
+		var err error
+		if path, err = config.FindConfigPath(); err != nil {
+			return err
+		}
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	files := config.Files(path)
+	fmt.Printf("Config: %s\n", strings.Join(files, " + "))
+
+	var problems []config.Problem
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		names, defaults := config.EnvRefs(string(data))
+		for _, name := range names {
+			if _, ok := os.LookupEnv(name); !ok && !defaults[name] {
+				problems = append(problems, config.Problem{
+					Severity: config.SeverityWarning,
+					Field:    filepath.Base(file),
+					Message:  fmt.Sprintf("$%s is not set, its value is empty", name),
+				})
+			}
+		}
+	}
+	problems = append(problems, cfg.Check()...)
+
+	errors := 0
+	for _, p := range problems {
+		fmt.Println(p)
+		if p.Severity == config.SeverityError {
+			errors++
+		}
+	}
+	if len(problems) == 0 {
+		fmt.Println("No problems found.")
+	}
+
+	if !quiet {
+		out, err := config.Redact(cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nEffective config (secrets redacted):\n\n%s", out)
+	}
+	if errors > 0 {
+		return fmt.Errorf("the config has %d errors", errors)
+	}
+	return nil
+}
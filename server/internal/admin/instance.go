@@ -0,0 +1,370 @@
+package admin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"server/internal/config"
+	"server/internal/progress"
+	"server/models"
+
+	"filippo.io/age"
+	"github.com/gobuffalo/pop/v6"
+)
+
+// An instance export is a backup (see backup.go) whose manifest also holds
+// the configuration: the config file, its .local.yaml override and the
+// environment variables they reference, so secrets kept in the environment
+// move with the instance. Exports are meant to be encrypted with age, to a
+// passphrase or to age recipients; `age -d` decrypts them too.
+
+// instanceConfig is the configuration of an exported instance
+type instanceConfig struct {
+	Files map[string]string `json:"files"`         // The config file and its override, by file name
+	Env   map[string]string `json:"env,omitempty"` // Variables the files reference, as set when exported
+}
+
+// PassphraseEnv holds the passphrase of encrypted instance exports when no
+// passphrase file is given
+const PassphraseEnv = "WEB_CLIPPER_PASSPHRASE"
+
+// instanceEnvFile is where imported environment variables are written, next
+// to the config file
+const instanceEnvFile = "clipper.env"
+
+// ageHeader starts every age-encrypted file
+const ageHeader = "age-encryption.org/v1"
+
+// InstanceKeys are how an instance export is encrypted or decrypted: to age
+// recipients or with age identities, otherwise with a passphrase
+type InstanceKeys struct {
+	Recipients     []string // Public keys (age1...) to encrypt to
+	IdentityFile   string   // File of private keys (AGE-SECRET-KEY-1...) to decrypt with
+	PassphraseFile string   // File holding the passphrase (default: $WEB_CLIPPER_PASSPHRASE)
+}
+
+// passphrase returns the passphrase from the passphrase file or environment
+func (k InstanceKeys) passphrase() (string, error) {
+	if k.PassphraseFile != "" {
+		data, err := os.ReadFile(k.PassphraseFile)
+		if err != nil {
+			return "", err
+		}
+		if p := strings.TrimRight(string(data), "\r\n"); p != "" {
+			return p, nil
+		}
+		return "", fmt.Errorf("%s is empty", k.PassphraseFile)
+	}
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return p, nil
+	}
+	return "", fmt.Errorf("set %s or pass --passphrase-file", PassphraseEnv)
+}
+
+// recipients returns what an export is encrypted to
+func (k InstanceKeys) recipients() ([]age.Recipient, error) {
+	if len(k.Recipients) > 0 {
+		var recipients []age.Recipient
+		for _, s := range k.Recipients {
+			r, err := age.ParseX25519Recipient(s)
+			if err != nil {
+				return nil, err
+			}
+			recipients = append(recipients, r)
+		}
+		return recipients, nil
+	}
+	p, err := k.passphrase()
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.NewScryptRecipient(p)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Recipient{r}, nil
+}
+
+// identities returns what an export is decrypted with
+func (k InstanceKeys) identities() ([]age.Identity, error) {
+	if k.IdentityFile != "" {
+		f, err := os.Open(k.IdentityFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return age.ParseIdentities(f)
+	}
+	p, err := k.passphrase()
+	if err != nil {
+		return nil, err
+	}
+	id, err := age.NewScryptIdentity(p)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Identity{id}, nil
+}
+
+// ExportInstance writes the database, every storage directory and the
+// configuration (secrets included) to out, encrypted with keys unless
+// encrypt is false. The archive is written next to out and renamed once
+// complete.
+func ExportInstance(ctx context.Context, out string, encrypt bool, keys InstanceKeys) error {
+	configPath, err := config.FindConfigPath()
+	if err != nil {
+		return fmt.Errorf("failed to find config: %w", err)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	conf, err := readInstanceConfig(configPath)
+	if err != nil {
+		return err
+	}
+	roots, err := storageRoots(models.DB, cfg.Storage.BasePath)
+	if err != nil {
+		return err
+	}
+	var recipients []age.Recipient
+	if encrypt {
+		if recipients, err = keys.recipients(); err != nil {
+			return err
+		}
+	}
+
+	tmp := out + ".partial"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	stats, err := writeInstance(ctx, f, models.DB, conf, roots, recipients, tmp)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, out)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("export failed: %w", err)
+	}
+
+	fmt.Printf("Exported the configuration, the database and %d files (%s) from %d storage directories to %s\n",
+		stats.Files, progress.FormatBytes(stats.Bytes), len(roots), out)
+	if !encrypt {
+		fmt.Println("The archive is not encrypted and holds the instance's secrets: keep it safe, or export with --encrypt")
+	}
+	return nil
+}
+
+// writeInstance writes the export archive to w, encrypted to recipients
+// when there are any
+func writeInstance(ctx context.Context, w io.Writer, db *pop.Connection, conf *instanceConfig, roots []backupRoot, recipients []age.Recipient, skip string) (*backupStats, error) {
+	if len(recipients) == 0 {
+		return writeBackup(ctx, w, db, roots, conf, skip)
+	}
+	enc, err := age.Encrypt(w, recipients...)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := writeBackup(ctx, enc, db, roots, conf, skip)
+	if err != nil {
+		return nil, err
+	}
+	return stats, enc.Close()
+}
+
+// readInstanceConfig reads the config file at configPath, its override and
+// the environment variables they reference that are set
+func readInstanceConfig(configPath string) (*instanceConfig, error) {
+	conf := &instanceConfig{Files: map[string]string{}, Env: map[string]string{}}
+	localPath := strings.TrimSuffix(configPath, ".yaml") + ".local.yaml"
+	for _, p := range []string{configPath, localPath} {
+		data, err := os.ReadFile(p)
+		if os.IsNotExist(err) && p == localPath {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		conf.Files[filepath.Base(p)] = string(data)
+		names, _ := config.EnvRefs(string(data))
+		for _, name := range names {
+			if value, ok := os.LookupEnv(name); ok {
+				conf.Env[name] = value
+			}
+		}
+	}
+	return conf, nil
+}
+
+// ImportInstance restores an export written by ExportInstance, decrypting it
+// with keys when it is encrypted: the config files go to configPath (and its
+// .local.yaml), the variables they reference to clipper.env next to it, the
+// files of the base path to the imported base path, other storage
+// directories to where they were, and the database replaces the configured
+// one. The server must be stopped; an existing config file or a database
+// that already has users is only replaced with force.
+func ImportInstance(ctx context.Context, in, configPath string, keys InstanceKeys, force bool) error {
+	if !force {
+		if _, err := os.Stat(configPath); err == nil {
+			return fmt.Errorf("%s already exists: pass --force to replace it", configPath)
+		}
+		if n, err := models.DB.Count(&models.Users{}); err == nil && n > 0 {
+			return fmt.Errorf("the database already has %d users: stop the server and pass --force to replace it", n)
+		}
+	}
+	dbPath, err := databasePath(models.DB)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	manifest, stats, err := readInstance(ctx, f, keys, dbPath, configPath)
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+
+	fmt.Printf("Imported the configuration, the database and %d files (%s) from the export of %s\n",
+		stats.Files, progress.FormatBytes(stats.Bytes), manifest.CreatedAt.Format(time.RFC3339))
+	if len(manifest.Config.Env) > 0 {
+		fmt.Printf("The config references environment variables, written to %s: set them before starting the server\n",
+			filepath.Join(filepath.Dir(configPath), instanceEnvFile))
+	}
+	return nil
+}
+
+// readInstance decrypts the export read from r if needed, then restores it
+func readInstance(ctx context.Context, r io.Reader, keys InstanceKeys, dbPath, configPath string) (*backupManifest, *backupStats, error) {
+	br := bufio.NewReader(r)
+	r = br
+	if header, _ := br.Peek(len(ageHeader)); string(header) == ageHeader {
+		identities, err := keys.identities()
+		if err != nil {
+			return nil, nil, err
+		}
+		if r, err = age.Decrypt(br, identities...); err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt: %w", err)
+		}
+	}
+
+	tr, manifest, err := openBackup(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if manifest.Config == nil {
+		return nil, nil, errors.New("not an instance export: restore backups with `restore`")
+	}
+
+	// The config is loaded from a staging directory for its base path, and
+	// only put in place once the files and database are restored
+	dir, err := os.MkdirTemp("", "web-clipper-import-")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(dir)
+	staged, err := stageInstanceConfig(manifest.Config, dir, filepath.Base(configPath))
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, value := range manifest.Config.Env {
+		if _, ok := os.LookupEnv(name); !ok {
+			os.Setenv(name, value)
+		}
+	}
+	cfg, err := config.Load(staged)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid imported config: %w", err)
+	}
+
+	stats, err := restoreBackup(ctx, tr, manifest, dbPath, cfg.Storage.BasePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := installInstanceConfig(manifest.Config, staged, configPath); err != nil {
+		return nil, nil, err
+	}
+	return manifest, stats, nil
+}
+
+// stageInstanceConfig writes the config files to dir, the main one as name,
+// and returns its path
+func stageInstanceConfig(conf *instanceConfig, dir, name string) (string, error) {
+	// The main file is the one that isn't an override
+	main := ""
+	for file := range conf.Files {
+		if !strings.HasSuffix(file, ".local.yaml") {
+			main = file
+		}
+	}
+	if main == "" {
+		return "", errors.New("the export has no config file")
+	}
+	staged := filepath.Join(dir, name)
+	if err := os.WriteFile(staged, []byte(conf.Files[main]), 0600); err != nil {
+		return "", err
+	}
+	if local, ok := conf.Files[strings.TrimSuffix(main, ".yaml")+".local.yaml"]; ok {
+		if err := os.WriteFile(strings.TrimSuffix(staged, ".yaml")+".local.yaml", []byte(local), 0600); err != nil {
+			return "", err
+		}
+	}
+	return staged, nil
+}
+
+// installInstanceConfig copies the staged config files to configPath (an
+// override the export doesn't have is removed) and writes the environment
+// variables next to them
+func installInstanceConfig(conf *instanceConfig, staged, configPath string) error {
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return err
+	}
+	localPath := strings.TrimSuffix(configPath, ".yaml") + ".local.yaml"
+	for src, dst := range map[string]string{
+		staged: configPath,
+		strings.TrimSuffix(staged, ".yaml") + ".local.yaml": localPath,
+	} {
+		data, err := os.ReadFile(src)
+		if os.IsNotExist(err) {
+			if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, data, 0600); err != nil {
+			return err
+		}
+	}
+	if len(conf.Env) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(conf.Env))
+	for name := range conf.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var env bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&env, "%s=%q\n", name, conf.Env[name])
+	}
+	return os.WriteFile(filepath.Join(filepath.Dir(configPath), instanceEnvFile), env.Bytes(), 0600)
+}
@@ -0,0 +1,109 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"server/internal/testkit"
+
+	"filippo.io/age"
+)
+
+func TestExportImportInstance(t *testing.T) {
+	kit := testkit.New(t)
+	if kit.DB.Dialect.Name() != "sqlite3" {
+		t.Skip("backups snapshot SQLite databases only")
+	}
+	user := kit.CreateUser()
+	clip := kit.CreateClip(user, testkit.WithMedia("hero.png", []byte("png")))
+
+	// The exported config moves the clips to a new base path and keeps its
+	// secret in the environment
+	base := filepath.Join(t.TempDir(), "clips")
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "clipper.yaml")
+	main := "storage:\n  base_path: " + base + "\njwt:\n  secret: \"${TEST_JWT_SECRET}\"\n"
+	if err := os.WriteFile(configPath, []byte(main), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "clipper.local.yaml"), []byte("metrics:\n  enabled: true\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_JWT_SECRET", "s3cret")
+
+	conf, err := readInstanceConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.Files) != 2 || conf.Env["TEST_JWT_SECRET"] != "s3cret" {
+		t.Fatalf("readInstanceConfig = %+v", conf)
+	}
+	roots, err := storageRoots(kit.DB, kit.StorageRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	stats, err := writeInstance(context.Background(), &archive, kit.DB, conf, roots, []age.Recipient{identity.Recipient()}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(archive.String(), ageHeader) || strings.Contains(archive.String(), "s3cret") {
+		t.Fatal("the export is not encrypted")
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "key.txt")
+	if err := os.WriteFile(keyFile, []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := age.GenerateX25519Identity()
+	otherFile := filepath.Join(t.TempDir(), "other.txt")
+	os.WriteFile(otherFile, []byte(other.String()+"\n"), 0600)
+	target := filepath.Join(t.TempDir(), "etc", "clipper.yaml")
+	dbPath := filepath.Join(t.TempDir(), "imported.sqlite3")
+	encrypted := archive.Bytes()
+	if _, _, err := readInstance(context.Background(), bytes.NewReader(encrypted), InstanceKeys{IdentityFile: otherFile}, dbPath, target); err == nil {
+		t.Error("expected an error decrypting with another key")
+	}
+
+	os.Unsetenv("TEST_JWT_SECRET")
+	manifest, restored, err := readInstance(context.Background(), bytes.NewReader(encrypted), InstanceKeys{IdentityFile: keyFile}, dbPath, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Files != stats.Files || manifest.Config == nil {
+		t.Errorf("restored %+v of %+v", restored, stats)
+	}
+	if _, err := os.Stat(filepath.Join(base, clip.Path, "media", "hero.png")); err != nil {
+		t.Errorf("clip files not restored to the imported base path: %v", err)
+	}
+	if data, err := os.ReadFile(target); err != nil || string(data) != main {
+		t.Errorf("config = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(target), "clipper.local.yaml")); err != nil {
+		t.Errorf("override not imported: %v", err)
+	}
+	if env, err := os.ReadFile(filepath.Join(filepath.Dir(target), instanceEnvFile)); err != nil || string(env) != "TEST_JWT_SECRET=\"s3cret\"\n" {
+		t.Errorf("env = %q, %v", env, err)
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		t.Errorf("database not imported: %v", err)
+	}
+
+	// A plain backup is not an instance export
+	archive.Reset()
+	if _, err := writeBackup(context.Background(), &archive, kit.DB, roots, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readInstance(context.Background(), &archive, InstanceKeys{}, dbPath, target); err == nil || !strings.Contains(err.Error(), "not an instance export") {
+		t.Errorf("importing a backup: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"server/migrations"
 	"server/models"
 
 	"github.com/gobuffalo/pop/v6"
@@ -13,15 +14,9 @@ import (
 func RunMigrations() error {
 	fmt.Println("Running database migrations...")
 
-	// Get migration directory from environment or use default
-	migrationDir := os.Getenv("MIGRATION_DIR")
-	if migrationDir == "" {
-		migrationDir = "./migrations"
-	}
-
-	mig, err := pop.NewFileMigrator(migrationDir, models.DB)
+	mig, err := newMigrator()
 	if err != nil {
-		return fmt.Errorf("failed to create migrator: %v", err)
+		return err
 	}
 
 	if err := mig.Up(); err != nil {
@@ -36,15 +31,9 @@ func RunMigrations() error {
 func ShowMigrationStatus() error {
 	fmt.Println("Migration status:")
 
-	// Get migration directory from environment or use default
-	migrationDir := os.Getenv("MIGRATION_DIR")
-	if migrationDir == "" {
-		migrationDir = "./migrations"
-	}
-
-	mig, err := pop.NewFileMigrator(migrationDir, models.DB)
+	mig, err := newMigrator()
 	if err != nil {
-		return fmt.Errorf("failed to create migrator: %v", err)
+		return err
 	}
 
 	if err := mig.Status(os.Stdout); err != nil {
@@ -53,3 +42,110 @@ func ShowMigrationStatus() error {
 
 	return nil
 }
+
+// RollbackMigrations rolls back the last steps applied migrations.
+func RollbackMigrations(steps int) error {
+	mig, err := newMigrator()
+	if err != nil {
+		return err
+	}
+	return rollbackMigrations(mig, steps)
+}
+
+func rollbackMigrations(mig *pop.Migrator, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("--steps must be at least 1")
+	}
+	fmt.Printf("Rolling back %d migrations...\n", steps)
+	if err := mig.Down(steps); err != nil {
+		return fmt.Errorf("rollback failed: %v", err)
+	}
+	fmt.Println("Rollback completed successfully")
+	return nil
+}
+
+// MigrateTo applies or rolls back migrations until version is the last one
+// applied.
+func MigrateTo(version string) error {
+	mig, err := newMigrator()
+	if err != nil {
+		return err
+	}
+	return migrateTo(mig, version)
+}
+
+func migrateTo(mig *pop.Migrator, version string) error {
+	if err := mig.CreateSchemaMigrations(); err != nil {
+		return err
+	}
+	c := mig.Connection
+	applied := []string{}
+	if err := c.Store.Select(&applied, fmt.Sprintf("SELECT version FROM %s", c.MigrationTableName())); err != nil {
+		return fmt.Errorf("failed to read applied migrations: %v", err)
+	}
+	isApplied := map[string]bool{}
+	for _, v := range applied {
+		isApplied[v] = true
+	}
+
+	// Migrations of other databases (.postgres.up.sql on SQLite) don't count
+	known := false
+	later, pending := map[string]bool{}, map[string]bool{}
+	for _, mf := range mig.UpMigrations.Migrations {
+		if mf.DBType != "all" && mf.DBType != c.Dialect.Name() {
+			continue
+		}
+		switch {
+		case mf.Version == version:
+			known = true
+			if !isApplied[mf.Version] {
+				pending[mf.Version] = true
+			}
+		case mf.Version > version && isApplied[mf.Version]:
+			later[mf.Version] = true
+		case mf.Version < version && !isApplied[mf.Version]:
+			pending[mf.Version] = true
+		}
+	}
+	if !known {
+		return fmt.Errorf("no migration has version %s (see migrate status)", version)
+	}
+
+	if len(later) > 0 {
+		fmt.Printf("Rolling back %d migrations...\n", len(later))
+		if err := mig.Down(len(later)); err != nil {
+			return fmt.Errorf("rollback failed: %v", err)
+		}
+	}
+	if len(pending) > 0 {
+		fmt.Printf("Applying %d migrations...\n", len(pending))
+		if _, err := mig.UpTo(len(pending)); err != nil {
+			return fmt.Errorf("migration failed: %v", err)
+		}
+	}
+	fmt.Printf("Database is at migration %s\n", version)
+	return nil
+}
+
+// newMigrator returns the migrations built into the binary, or those of
+// MIGRATION_DIR when it is set (which also rewrites its schema.sql)
+func newMigrator() (*pop.Migrator, error) {
+	migrationDir := os.Getenv("MIGRATION_DIR")
+	if migrationDir == "" {
+		box, err := pop.NewMigrationBox(migrations.FS, models.DB)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create migrator: %v", err)
+		}
+		return &box.Migrator, nil
+	}
+
+	mig, err := pop.NewFileMigrator(migrationDir, models.DB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrator: %v", err)
+	}
+	// schema.sql is SQLite's; dumping PostgreSQL's would need pg_dump
+	if models.DB.Dialect.Name() != "sqlite3" {
+		mig.SchemaPath = ""
+	}
+	return &mig.Migrator, nil
+}
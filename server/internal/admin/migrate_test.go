@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"sort"
+	"testing"
+
+	"server/internal/testkit"
+	"server/migrations"
+
+	"github.com/gobuffalo/pop/v6"
+)
+
+func TestRollbackAndMigrateTo(t *testing.T) {
+	db := testkit.NewDB(t)
+	// A migrator runs each migration's file once, so every operation gets
+	// its own
+	migrator := func() *pop.Migrator {
+		box, err := pop.NewMigrationBox(migrations.FS, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &box.Migrator
+	}
+	var versions []string
+	for _, mf := range migrator().UpMigrations.Migrations {
+		if mf.DBType == "all" || mf.DBType == db.Dialect.Name() {
+			versions = append(versions, mf.Version)
+		}
+	}
+	sort.Strings(versions)
+	last := versions[len(versions)-1]
+	applied := func() int {
+		n, err := db.Count(db.MigrationTableName())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	lastApplied := func() bool {
+		// The last migration adds abuse_reports.reporter_hash
+		return db.RawQuery("SELECT reporter_hash FROM abuse_reports").Exec() == nil
+	}
+	if !lastApplied() {
+		t.Fatal("abuse_reports.reporter_hash wasn't added")
+	}
+
+	if err := rollbackMigrations(migrator(), 0); err == nil {
+		t.Error("expected an error rolling back 0 steps")
+	}
+	if err := rollbackMigrations(migrator(), 2); err != nil {
+		t.Fatal(err)
+	}
+	if applied() != len(versions)-2 || lastApplied() {
+		t.Errorf("%d migrations applied after rolling back 2 of %d", applied(), len(versions))
+	}
+
+	if err := migrateTo(migrator(), last); err != nil {
+		t.Fatal(err)
+	}
+	if applied() != len(versions) || !lastApplied() {
+		t.Errorf("%d migrations applied, want %d", applied(), len(versions))
+	}
+	if err := migrateTo(migrator(), versions[len(versions)-4]); err != nil {
+		t.Fatal(err)
+	}
+	if applied() != len(versions)-3 {
+		t.Errorf("%d migrations applied, want %d", applied(), len(versions)-3)
+	}
+	if err := migrateTo(migrator(), "19990101000000"); err == nil {
+		t.Error("expected an error for an unknown version")
+	}
+}
@@ -0,0 +1,144 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"server/internal/clipclient"
+	"server/internal/services"
+)
+
+// remote is set by UseRemote; commands then go through the admin API
+// instead of the local database and config.
+var remote *clipclient.Client
+
+// UseRemote routes the users, tokens and clips commands through the admin API
+// of the given server. An empty token falls back to the saved login credentials.
+func UseRemote(server, token string) error {
+	creds := &clipclient.Credentials{Server: server, Token: token}
+	if token == "" {
+		saved, err := clipclient.LoadCredentials()
+		if err != nil {
+			return err
+		}
+		creds.Token, creds.RefreshToken = saved.Token, saved.RefreshToken
+	}
+
+	client, err := clipclient.New(creds)
+	if err != nil {
+		return err
+	}
+	remote = client
+	return nil
+}
+
+// remoteError translates admin API errors back into service errors
+func remoteError(err error) error {
+	var httpErr *clipclient.HTTPError
+	if !errors.As(err, &httpErr) {
+		return err
+	}
+	switch {
+	case httpErr.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("this token's user is not listed in admin.emails on the server")
+	case httpErr.Message == services.ErrUserNotFound.Error():
+		return services.ErrUserNotFound
+	case httpErr.Message == services.ErrUserAlreadyDisabled.Error():
+		return services.ErrUserAlreadyDisabled
+	case httpErr.Message == services.ErrUserAlreadyEnabled.Error():
+		return services.ErrUserAlreadyEnabled
+	case httpErr.StatusCode != http.StatusUnauthorized:
+		return errors.New(httpErr.Message)
+	}
+	return err
+}
+
+// userPath returns the admin API path for a user
+func userPath(email string) string {
+	return "/api/v1/admin/users/" + url.PathEscape(email)
+}
+
+// remoteUserService implements services.UserService over the admin API.
+type remoteUserService struct {
+	client *clipclient.Client
+}
+
+func (s *remoteUserService) List(ctx context.Context) ([]services.UserInfo, error) {
+	var resp struct {
+		Users []services.UserInfo `json:"users"`
+	}
+	if err := s.client.Do(ctx, http.MethodGet, "/api/v1/admin/users", nil, &resp); err != nil {
+		return nil, remoteError(err)
+	}
+	return resp.Users, nil
+}
+
+func (s *remoteUserService) Get(ctx context.Context, email string) (*services.UserInfo, error) {
+	user := &services.UserInfo{}
+	if err := s.client.Do(ctx, http.MethodGet, userPath(email), nil, user); err != nil {
+		return nil, remoteError(err)
+	}
+	return user, nil
+}
+
+func (s *remoteUserService) SetStoragePath(ctx context.Context, email, path string) error {
+	body := map[string]string{"path": path}
+	return remoteError(s.client.Do(ctx, http.MethodPut, userPath(email)+"/storage", body, nil))
+}
+
+func (s *remoteUserService) CheckStoragePath(ctx context.Context, email, path string) error {
+	body := map[string]interface{}{"path": path, "dry_run": true}
+	return remoteError(s.client.Do(ctx, http.MethodPut, userPath(email)+"/storage", body, nil))
+}
+
+func (s *remoteUserService) SetKindleEmail(ctx context.Context, email, address string) error {
+	body := map[string]string{"address": address}
+	return remoteError(s.client.Do(ctx, http.MethodPut, userPath(email)+"/kindle", body, nil))
+}
+
+func (s *remoteUserService) Disable(ctx context.Context, email string) error {
+	return remoteError(s.client.Do(ctx, http.MethodPost, userPath(email)+"/disable", nil, nil))
+}
+
+func (s *remoteUserService) Enable(ctx context.Context, email string) error {
+	return remoteError(s.client.Do(ctx, http.MethodPost, userPath(email)+"/enable", nil, nil))
+}
+
+func (s *remoteUserService) IsEnabled(ctx context.Context, userID string) (bool, error) {
+	return false, fmt.Errorf("checking users by ID is not supported in remote mode")
+}
+
+// remoteTokenService implements services.TokenService over the admin API.
+type remoteTokenService struct {
+	client *clipclient.Client
+}
+
+func (s *remoteTokenService) Create(ctx context.Context, email, name string, expiryDuration string) (string, error) {
+	body := map[string]string{"name": name, "expiry": expiryDuration}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := s.client.Do(ctx, http.MethodPost, userPath(email)+"/tokens", body, &resp); err != nil {
+		return "", remoteError(err)
+	}
+	return resp.Token, nil
+}
+
+func (s *remoteTokenService) List(ctx context.Context, email string) ([]services.TokenInfo, error) {
+	var resp struct {
+		Tokens []services.TokenInfo `json:"tokens"`
+	}
+	if err := s.client.Do(ctx, http.MethodGet, userPath(email)+"/tokens", nil, &resp); err != nil {
+		return nil, remoteError(err)
+	}
+	return resp.Tokens, nil
+}
+
+func (s *remoteTokenService) Revoke(ctx context.Context, tokenID, reason string) error {
+	body := map[string]string{"reason": reason}
+	path := "/api/v1/admin/tokens/" + url.PathEscape(tokenID) + "/revoke"
+	return remoteError(s.client.Do(ctx, http.MethodPost, path, body, nil))
+}
@@ -0,0 +1,47 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server/internal/clipclient"
+	"server/internal/services"
+)
+
+func TestRemoteUserService(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v1/admin/users/a@example.com":
+			json.NewEncoder(w).Encode(services.UserInfo{Email: "a@example.com", Name: "A"})
+		case "POST /api/v1/admin/users/a@example.com/disable":
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": services.ErrUserAlreadyDisabled.Error()})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": services.ErrUserNotFound.Error()})
+		}
+	}))
+	defer srv.Close()
+
+	client, err := clipclient.New(&clipclient.Credentials{Server: srv.URL, Token: "wc_test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := &remoteUserService{client: client}
+	ctx := context.Background()
+
+	user, err := svc.Get(ctx, "a@example.com")
+	if err != nil || user.Name != "A" {
+		t.Fatalf("Get() = %+v, %v", user, err)
+	}
+	if err := svc.Disable(ctx, "a@example.com"); err != services.ErrUserAlreadyDisabled {
+		t.Errorf("Disable() error = %v, want ErrUserAlreadyDisabled", err)
+	}
+	if _, err := svc.Get(ctx, "missing@example.com"); err != services.ErrUserNotFound {
+		t.Errorf("Get() error = %v, want ErrUserNotFound", err)
+	}
+}
@@ -0,0 +1,312 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"server/internal/progress"
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// SeedOptions controls the demo data generated by Seed.
+type SeedOptions struct {
+	Clips int    // Number of clips to create
+	Users int    // Number of fake users to spread them over (ignored with Email)
+	Email string // Put every clip under this existing user instead
+	Seed  int64  // Random seed; the same seed generates the same data
+	Force bool   // Allow seeding when dev mode is off
+}
+
+// seedBatchSize is the number of clips inserted per transaction
+const seedBatchSize = 200
+
+// Seed generates fake users and clips, with files on disk, for development,
+// UI work and load-testing the list and search endpoints.
+func Seed(ctx context.Context, opts SeedOptions) error {
+	if remote != nil {
+		return fmt.Errorf("seed writes to the local database and cannot run with --remote")
+	}
+	if opts.Clips < 1 {
+		return fmt.Errorf("--clips must be at least 1")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if !cfg.DevMode.Enabled && !opts.Force {
+		return fmt.Errorf("refusing to seed with dev mode off (set DEV_MODE=true or pass --force)")
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	users, err := seedUsers(opts, rng)
+	if err != nil {
+		return err
+	}
+
+	bar := progress.New(os.Stderr, "Seeding clips", opts.Clips, 0)
+	now := time.Now()
+	for start := 0; start < opts.Clips; start += seedBatchSize {
+		if err := ctx.Err(); err != nil {
+			bar.Finish()
+			return fmt.Errorf("interrupted after %d clips", start)
+		}
+
+		n := seedBatchSize
+		if start+n > opts.Clips {
+			n = opts.Clips - start
+		}
+		err := models.DB.Transaction(func(tx *pop.Connection) error {
+			for i := start; i < start+n; i++ {
+				user := users[rng.Intn(len(users))]
+				clipDir := cfg.Storage.BasePath
+				if user.ClipDirectory.Valid && user.ClipDirectory.String != "" {
+					clipDir = user.ClipDirectory.String
+				}
+				if err := seedClip(tx, rng, clipDir, user, i, now); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			bar.Finish()
+			return fmt.Errorf("failed to seed clips: %w", err)
+		}
+		bar.Add(n, 0)
+	}
+	bar.Finish()
+
+	fmt.Printf("Seeded %d clips for %d users:\n", opts.Clips, len(users))
+	for _, u := range users {
+		fmt.Printf("  %s\n", u.Email)
+	}
+	return nil
+}
+
+// seedUsers returns the users to seed clips for, creating demo users as needed
+func seedUsers(opts SeedOptions, rng *rand.Rand) ([]*models.User, error) {
+	if opts.Email != "" {
+		user := &models.User{}
+		if err := models.DB.Where("email = ?", opts.Email).First(user); err != nil {
+			return nil, fmt.Errorf("user not found: %s", opts.Email)
+		}
+		return []*models.User{user}, nil
+	}
+	if opts.Users < 1 {
+		return nil, fmt.Errorf("--users must be at least 1")
+	}
+
+	users := make([]*models.User, opts.Users)
+	for i := range users {
+		email := fmt.Sprintf("demo%d@example.com", i+1)
+		name := seedFirstNames[rng.Intn(len(seedFirstNames))] + " " + seedLastNames[rng.Intn(len(seedLastNames))]
+		user, err := models.FindOrCreateByOAuthID(models.DB, "seed-"+email, email, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create user %s: %w", email, err)
+		}
+		users[i] = user
+	}
+	return users, nil
+}
+
+// seedClip creates one clip with its folder and files
+func seedClip(tx *pop.Connection, rng *rand.Rand, clipDir string, user *models.User, n int, now time.Time) error {
+	site := seedSites[rng.Intn(len(seedSites))]
+	topic := seedTopics[rng.Intn(len(seedTopics))]
+	title := fmt.Sprintf(seedTitles[rng.Intn(len(seedTitles))], topic)
+	slug := seedSlug(title)
+	clipURL := fmt.Sprintf("https://%s/%s/%s-%d", site, seedSections[rng.Intn(len(seedSections))], slug, n)
+
+	// Weighted towards articles, like real libraries
+	mode := seedModes[rng.Intn(len(seedModes))]
+
+	// Mostly recent clips, with a long tail over two years
+	age := time.Duration(rng.ExpFloat64() * float64(60*24*time.Hour))
+	if age > 730*24*time.Hour {
+		age = time.Duration(rng.Int63n(int64(730 * 24 * time.Hour)))
+	}
+	createdAt := now.Add(-age).Truncate(time.Second)
+
+	// Same layout as captured clips: time, site and clip ID prefix
+	id := uuid.Must(uuid.NewV4())
+	folder := fmt.Sprintf("%s_%s_%s", createdAt.Format("20060102_150405"), seedSlug(site), id.String()[:8])
+	relPath := filepath.Join("web-clips", folder)
+
+	tags := make([]string, 0, 4)
+	for i := rng.Intn(5); i > 0; i-- {
+		tags = append(tags, seedTags[rng.Intn(len(seedTags))])
+	}
+	tags = append(tags, strings.ToLower(strings.ReplaceAll(topic, " ", "-")))
+
+	body := seedBody(rng, topic)
+	clip := &models.Clip{
+		ID:            id,
+		UserID:        user.ID,
+		Title:         title,
+		URL:           clipURL,
+		NormalizedURL: clipURL, // Generated URLs are already in normalized form
+		Domain:        site,
+		Path:          relPath,
+		Mode:          mode,
+		ContentText:   nulls.NewString(body),
+		Excerpt:       nulls.NewString(seedExcerpt(body)),
+		CreatedAt:     createdAt,
+		UpdatedAt:     createdAt,
+	}
+	if rng.Intn(4) == 0 {
+		clip.Notes = nulls.NewString(seedNotes[rng.Intn(len(seedNotes))])
+	}
+	if rng.Intn(10) == 0 {
+		clip.ArchivedAt = nulls.NewTime(createdAt.Add(time.Duration(rng.Int63n(int64(age) + 1))))
+	}
+
+	if err := tx.Create(clip); err != nil {
+		return err
+	}
+	if err := models.SetClipTags(tx, clip, tags); err != nil {
+		return err
+	}
+
+	folderPath := filepath.Join(clipDir, relPath)
+	if err := os.MkdirAll(folderPath, 0755); err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	fmt.Fprintf(&sb, "title: %q\n", title)
+	fmt.Fprintf(&sb, "url: %s\n", clipURL)
+	fmt.Fprintf(&sb, "clipped_at: %s\n", createdAt.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "source: %s\n", site)
+	fmt.Fprintf(&sb, "mode: %s\n", mode)
+	sb.WriteString("tags:\n")
+	for _, tag := range models.CleanTagNames(tags) {
+		fmt.Fprintf(&sb, "  - %s\n", tag)
+	}
+	fmt.Fprintf(&sb, "notes: %q\n", clip.Notes.String)
+	sb.WriteString("---\n\n")
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+
+	// Screenshots always have an image; some articles do too
+	if mode == "screenshot" || (mode == "article" && rng.Intn(3) == 0) {
+		mediaDir := filepath.Join(folderPath, "media")
+		if err := os.MkdirAll(mediaDir, 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(mediaDir, "image-1.png"), seedImage(rng), 0644); err != nil {
+			return err
+		}
+		sb.WriteString("![](media/image-1.png)\n\n")
+	}
+	if mode != "bookmark" {
+		sb.WriteString(body)
+		sb.WriteString("\n")
+	}
+
+	return os.WriteFile(filepath.Join(folderPath, slug+".md"), []byte(sb.String()), 0644)
+}
+
+// seedBody returns a few paragraphs of plausible text about the topic
+func seedBody(rng *rand.Rand, topic string) string {
+	paragraphs := make([]string, 2+rng.Intn(4))
+	for i := range paragraphs {
+		sentences := make([]string, 3+rng.Intn(4))
+		for j := range sentences {
+			sentences[j] = fmt.Sprintf(seedSentences[rng.Intn(len(seedSentences))], topic)
+		}
+		paragraphs[i] = strings.Join(sentences, " ")
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// seedExcerpt returns the start of the body, like the excerpts of real clips
+func seedExcerpt(body string) string {
+	if len(body) <= 200 {
+		return body
+	}
+	return body[:200] + "..."
+}
+
+// seedImage renders a small gradient PNG
+func seedImage(rng *rand.Rand) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 160, 90))
+	base := color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+	for y := 0; y < 90; y++ {
+		for x := 0; x < 160; x++ {
+			img.Set(x, y, color.RGBA{base.R + uint8(x/2), base.G + uint8(y), base.B, 255})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// seedSlug lowercases s and replaces runs of other characters with dashes
+func seedSlug(s string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+			dash = false
+		} else if !dash && sb.Len() > 0 {
+			sb.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimRight(sb.String(), "-")
+}
+
+var (
+	seedFirstNames = []string{"Alex", "Sam", "Jordan", "Taylor", "Morgan", "Casey", "Riley", "Jamie", "Avery", "Quinn"}
+	seedLastNames  = []string{"Martin", "Nguyen", "Garcia", "Dubois", "Smith", "Kowalski", "Rossi", "Tanaka", "Okafor", "Larsen"}
+
+	seedModes    = []string{"article", "article", "article", "article", "bookmark", "bookmark", "selection", "selection", "screenshot", "fullpage"}
+	seedSites    = []string{"go.dev", "blog.golang.org", "news.ycombinator.com", "github.com", "medium.com", "arstechnica.com", "nytimes.com", "lemonde.fr", "wikipedia.org", "dev.to", "stackoverflow.com", "theverge.com", "smashingmagazine.com", "martinfowler.com", "lwn.net"}
+	seedSections = []string{"blog", "articles", "posts", "news", "wiki", "2025", "2026", "guides"}
+	seedTopics   = []string{"Go", "Rust", "PostgreSQL", "SQLite", "Kubernetes", "Machine Learning", "Climate", "Typography", "Accessibility", "Remote Work", "Cooking", "Photography", "Productivity", "Security", "Web Performance", "Distributed Systems"}
+	seedTags     = []string{"reading", "to-read", "reference", "work", "ideas", "research", "tutorial", "favorites", "inspiration", "later"}
+
+	seedTitles = []string{
+		"A practical guide to %s",
+		"What I learned after ten years of %s",
+		"%s in production: lessons learned",
+		"Why %s matters more than you think",
+		"The state of %s in 2026",
+		"Getting started with %s",
+		"%s: common mistakes and how to avoid them",
+		"An opinionated introduction to %s",
+	}
+	seedSentences = []string{
+		"Most teams discover the limits of %s only after it reaches production.",
+		"The simplest approach to %s is often the one that lasts.",
+		"We measured the impact of %s over six months and the results surprised us.",
+		"Documentation about %s tends to skip the hard parts.",
+		"A small change in how we think about %s made everything else easier.",
+		"There is no silver bullet for %s, but there are good defaults.",
+		"The community around %s has grown quickly in the last few years.",
+		"Benchmarks of %s rarely reflect real workloads.",
+	}
+	seedNotes = []string{
+		"Share with the team",
+		"Good overview, revisit later",
+		"Compare with last year's article",
+		"Useful for the onboarding doc",
+		"Check the linked benchmarks",
+	}
+)
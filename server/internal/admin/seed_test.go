@@ -0,0 +1,38 @@
+package admin
+
+import (
+	"bytes"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+func TestSeedSlug(t *testing.T) {
+	for in, want := range map[string]string{
+		"Go in production: lessons learned": "go-in-production-lessons-learned",
+		"news.ycombinator.com":              "news-ycombinator-com",
+		"  Spaces  ":                        "spaces",
+	} {
+		if got := seedSlug(in); got != want {
+			t.Errorf("seedSlug(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSeedIsDeterministic(t *testing.T) {
+	a := seedBody(rand.New(rand.NewSource(42)), "Go")
+	b := seedBody(rand.New(rand.NewSource(42)), "Go")
+	if a != b {
+		t.Error("the same seed generated different text")
+	}
+}
+
+func TestSeedImage(t *testing.T) {
+	img, err := png.Decode(bytes.NewReader(seedImage(rand.New(rand.NewSource(1)))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 160 || b.Dy() != 90 {
+		t.Errorf("image size = %v", b)
+	}
+}
@@ -0,0 +1,301 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"server/internal/logging"
+	"server/internal/progress"
+	"server/internal/services"
+	"server/models"
+
+	"github.com/gobuffalo/pop/v6"
+)
+
+// trashDir is the folder trashed clips are kept in, inside a storage path
+const trashDir = ".trash"
+
+// checkpointFile is written to the destination while MigrateStorage runs
+const checkpointFile = ".migrate-storage.json"
+
+// MigrateStorage copies a user's clip folders (including trashed ones) to a
+// new storage path and then switches the user to it. An empty path means the
+// default base path. Progress goes to stderr; if the copy is interrupted,
+// running the same command again resumes from the checkpoint. The old files
+// are left in place.
+func MigrateStorage(ctx context.Context, email, path string) error {
+	if remote != nil {
+		return fmt.Errorf("migrate-storage copies files on the server and cannot run with --remote")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	logger := logging.CLI()
+	storage := services.NewStorageService(cfg, logger)
+	if err := storage.Validate(path); err != nil {
+		return err
+	}
+
+	user := &models.User{}
+	if err := models.DB.Where("email = ?", email).First(user); err != nil {
+		return fmt.Errorf("user not found: %s", email)
+	}
+
+	src := cfg.Storage.BasePath
+	if user.ClipDirectory.Valid && user.ClipDirectory.String != "" {
+		src = user.ClipDirectory.String
+	}
+	dst := cfg.Storage.BasePath
+	if path != "" {
+		dst = path
+	}
+	if filepath.Clean(src) == filepath.Clean(dst) {
+		return fmt.Errorf("clips are already stored in %s", dst)
+	}
+
+	clips := models.Clips{}
+	if err := models.DB.Where("user_id = ?", user.ID).Order("created_at ASC").All(&clips); err != nil {
+		return fmt.Errorf("failed to load clips: %w", err)
+	}
+
+	cp, err := progress.LoadCheckpoint(filepath.Join(dst, checkpointFile),
+		fmt.Sprintf("migrate-storage %s %s -> %s", email, src, dst))
+	if err != nil {
+		return err
+	}
+	if cp.Resumed() {
+		fmt.Fprintf(os.Stderr, "Resuming: %d of %d clips already copied\n", len(cp.Done), len(clips))
+	}
+
+	// Size everything up front so the ETA is based on bytes
+	folders := make([]string, len(clips))
+	sizes := make([]int64, len(clips))
+	var totalBytes int64
+	for i, clip := range clips {
+		folders[i] = clip.Path
+		if clip.DeletedAt.Valid {
+			folders[i] = filepath.Join(trashDir, clip.Path)
+		}
+		sizes[i] = dirSize(filepath.Join(src, folders[i]))
+		totalBytes += sizes[i]
+	}
+
+	bar := progress.New(os.Stderr, "Copying clips", len(clips), totalBytes)
+	for i, clip := range clips {
+		key := clip.ID.String()
+		if cp.IsDone(key) {
+			bar.Skip(1, sizes[i])
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			bar.Finish()
+			if err := cp.Save(); err != nil {
+				return err
+			}
+			return fmt.Errorf("interrupted after %d of %d clips; run the same command again to resume", len(cp.Done), len(clips))
+		}
+
+		n, err := copyDir(filepath.Join(src, folders[i]), filepath.Join(dst, folders[i]))
+		if err != nil {
+			bar.Finish()
+			if saveErr := cp.Save(); saveErr != nil {
+				logger.Warn("failed to save checkpoint", "error", saveErr)
+			}
+			return fmt.Errorf("failed to copy clip %s (%s): %w", key, clip.Path, err)
+		}
+		if err := cp.Mark(key, n); err != nil {
+			return err
+		}
+		bar.Add(1, n)
+	}
+	bar.Finish()
+
+	svc, err := buildServices()
+	if err != nil {
+		return err
+	}
+	if err := svc.SetStoragePath(ctx, email, path); err != nil {
+		return fmt.Errorf("copied clips but failed to update storage path: %w", err)
+	}
+	recordAudit("user.storage", "email", email, "path", path, "clips", len(clips))
+	if err := cp.Remove(); err != nil {
+		logger.Warn("failed to remove checkpoint", "error", err)
+	}
+
+	fmt.Printf("Copied %d clips (%s) to %s\n", len(clips), progress.FormatBytes(totalBytes), dst)
+	fmt.Printf("The old files in %s were left in place; remove them once you have checked the new location.\n", src)
+	return nil
+}
+
+// dirSize returns the total size of the regular files under dir (0 if missing)
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// copyDir copies the regular files under src to dst, overwriting partial
+// copies from an interrupted run. A missing src (no files on disk) copies nothing.
+func copyDir(src, dst string) (int64, error) {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	var copied int64
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil // Clip folders only hold regular files
+		}
+		n, err := copyFile(p, target)
+		copied += n
+		return err
+	})
+	return copied, err
+}
+
+// copyFile copies one file, keeping its permissions
+func copyFile(src, dst string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return 0, err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// DedupeResult summarizes a media deduplication pass.
+type DedupeResult struct {
+	Files        int   `json:"files"`
+	Linked       int   `json:"linked"`
+	SavedBytes   int64 `json:"saved_bytes"`
+	RemovedBlobs int   `json:"removed_blobs"`
+}
+
+// DedupeMedia moves existing clips' media into the content-addressed store,
+// hard-linking duplicates to one copy. Locally it calls dedupe (the server's,
+// which needs the server config); in remote mode it goes through the admin
+// API. With dryRun nothing changes.
+func DedupeMedia(ctx context.Context, dryRun bool, dedupe func(dryRun bool) (*DedupeResult, error)) error {
+	var result *DedupeResult
+	if remote != nil {
+		var resp struct {
+			Result *DedupeResult `json:"result"`
+		}
+		path := fmt.Sprintf("/api/v1/admin/storage/dedupe?dry_run=%t", dryRun)
+		if err := remote.Do(ctx, http.MethodPost, path, nil, &resp); err != nil {
+			return fmt.Errorf("failed to deduplicate media: %w", remoteError(err))
+		}
+		result = resp.Result
+	} else {
+		var err error
+		result, err = dedupe(dryRun)
+		if err != nil {
+			return fmt.Errorf("failed to deduplicate media: %w", err)
+		}
+	}
+
+	verb, removed := "Linked", "Removed"
+	if dryRun {
+		verb, removed = "Dry run: would link", "Dry run: would remove"
+	}
+	fmt.Printf("%s %d duplicate media files of %d, saving %s\n", verb, result.Linked, result.Files, progress.FormatBytes(result.SavedBytes))
+	fmt.Printf("%s %d unreferenced media blobs\n", removed, result.RemovedBlobs)
+	return nil
+}
+
+// StorageIssueRow is a mismatch between a clip row and its files.
+type StorageIssueRow struct {
+	Kind   string `json:"kind"`
+	User   string `json:"user"`
+	ClipID string `json:"clip_id"`
+	Path   string `json:"path"`
+	Detail string `json:"detail"`
+	Fixed  bool   `json:"fixed"`
+}
+
+// CheckStorage reports clip rows whose files are gone, files without a row
+// and missing media, fixing what check was asked to. check is the server's
+// check, which reads the clip files; there is no remote mode. It runs in a
+// database transaction, rolled back on failure.
+func CheckStorage(ctx context.Context, check func(tx *pop.Connection) ([]StorageIssueRow, error)) error {
+	if remote != nil {
+		return fmt.Errorf("check reads clip files on the server and cannot run with --remote")
+	}
+
+	var rows []StorageIssueRow
+	err := models.DB.Transaction(func(tx *pop.Connection) error {
+		var err error
+		rows, err = check(tx)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to check storage: %w", err)
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No storage issues.")
+		return nil
+	}
+
+	fixed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ISSUE\tFIXED\tUSER\tCLIP\tPATH\tDETAIL")
+	fmt.Fprintln(w, "-----\t-----\t----\t----\t----\t------")
+	for _, r := range rows {
+		if r.Fixed {
+			fixed++
+		}
+		clipID := r.ClipID
+		if clipID == "" {
+			clipID = "-"
+		}
+		fmt.Fprintf(w, "%s\t%t\t%s\t%s\t%s\t%s\n", r.Kind, r.Fixed, r.User, clipID, r.Path, r.Detail)
+	}
+	w.Flush()
+
+	fmt.Printf("\nFound %d storage issues, fixed %d\n", len(rows), fixed)
+	return nil
+}
@@ -25,6 +25,7 @@ func CreateToken(ctx context.Context, email, name, expiry string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create token: %w", err)
 	}
+	recordAudit("token.create", "email", email, "name", name)
 
 	// Display token (only time it's shown!)
 	fmt.Println("")
@@ -104,6 +105,7 @@ func RevokeToken(ctx context.Context, id, reason string) error {
 	if err := svc.Revoke(ctx, id, reason); err != nil {
 		return fmt.Errorf("failed to revoke token: %w", err)
 	}
+	recordAudit("token.revoke", "token_id", id)
 
 	fmt.Printf("Token revoked: %s\n", id)
 	return nil
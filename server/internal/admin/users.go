@@ -4,9 +4,16 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"text/tabwriter"
+	"time"
 
+	"server/internal/progress"
 	"server/internal/services"
+	"server/models"
+
+	"github.com/gobuffalo/pop/v6"
 )
 
 // ListUsers lists all users with their status and storage information.
@@ -72,22 +79,38 @@ func ShowUser(ctx context.Context, email string) error {
 	fmt.Printf("Name:         %s\n", user.Name)
 	fmt.Printf("Status:       %s\n", status)
 	fmt.Printf("Storage Path: %s\n", valueOrDefault(user.ClipDirectory, "(default)"))
+	fmt.Printf("Kindle:       %s\n", valueOrDefault(user.KindleEmail, "(not set)"))
 	fmt.Printf("Created:      %s\n", user.CreatedAt)
 	fmt.Printf("Updated:      %s\n", user.UpdatedAt)
 
 	return nil
 }
 
-// SetStoragePath sets storage path for a user.
-func SetStoragePath(ctx context.Context, email, path string) error {
+// SetStoragePath sets storage path for a user. With dryRun it only validates
+// the change and prints what it would do.
+func SetStoragePath(ctx context.Context, email, path string, dryRun bool) error {
 	svc, err := buildServices()
 	if err != nil {
 		return err
 	}
 
+	if dryRun {
+		if err := svc.CheckStoragePath(ctx, email, path); err != nil {
+			return fmt.Errorf("failed to set storage path: %w", err)
+		}
+		user, err := svc.Get(ctx, email)
+		if err != nil {
+			return fmt.Errorf("user not found: %s", email)
+		}
+		fmt.Printf("Dry run: would change storage path for %s from '%s' to '%s' (no changes made)\n",
+			email, valueOrDefault(user.ClipDirectory, "(default)"), valueOrDefault(path, "(default)"))
+		return nil
+	}
+
 	if err := svc.SetStoragePath(ctx, email, path); err != nil {
 		return fmt.Errorf("failed to set storage path: %w", err)
 	}
+	recordAudit("user.storage", "email", email, "path", path)
 
 	if path == "" {
 		fmt.Printf("Storage path reset to default for user: %s\n", email)
@@ -98,6 +121,26 @@ func SetStoragePath(ctx context.Context, email, path string) error {
 	return nil
 }
 
+// SetKindleEmail sets the Send to Kindle device address for a user.
+func SetKindleEmail(ctx context.Context, email, address string) error {
+	svc, err := buildServices()
+	if err != nil {
+		return err
+	}
+
+	if err := svc.SetKindleEmail(ctx, email, address); err != nil {
+		return fmt.Errorf("failed to set kindle address: %w", err)
+	}
+
+	if address == "" {
+		fmt.Printf("Kindle address cleared for user: %s\n", email)
+	} else {
+		fmt.Printf("Kindle address set to '%s' for user: %s\n", address, email)
+	}
+
+	return nil
+}
+
 // DisableUser disables a user account.
 func DisableUser(ctx context.Context, email string) error {
 	svc, err := buildServices()
@@ -112,6 +155,7 @@ func DisableUser(ctx context.Context, email string) error {
 		}
 		return fmt.Errorf("failed to disable user: %w", err)
 	}
+	recordAudit("user.disable", "email", email)
 
 	fmt.Printf("User disabled: %s\n", email)
 	return nil
@@ -131,7 +175,73 @@ func EnableUser(ctx context.Context, email string) error {
 		}
 		return fmt.Errorf("failed to enable user: %w", err)
 	}
+	recordAudit("user.enable", "email", email)
 
 	fmt.Printf("User enabled: %s\n", email)
 	return nil
 }
+
+// ModeUsageRow is the storage taken by the clips of one mode.
+type ModeUsageRow struct {
+	Clips int
+	Bytes int64
+}
+
+// DiskUsageRow is the storage taken by a user's clips.
+type DiskUsageRow struct {
+	Email        string
+	Clips        int
+	Bytes        int64
+	TrashedClips int
+	TrashedBytes int64
+	Modes        map[string]ModeUsageRow
+	ComputedAt   time.Time
+}
+
+// ShowDiskUsage prints the storage taken by the clips of each user, largest
+// first. usage is the server's, which walks the clip files (or reads its
+// cache); there is no remote mode.
+func ShowDiskUsage(ctx context.Context, usage func(tx *pop.Connection) ([]DiskUsageRow, error)) error {
+	if remote != nil {
+		return fmt.Errorf("usage reads clip files on the server and cannot run with --remote")
+	}
+
+	var rows []DiskUsageRow
+	err := models.DB.Transaction(func(tx *pop.Connection) error {
+		var err error
+		rows, err = usage(tx)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to measure disk usage: %w", err)
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No users found.")
+		return nil
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "EMAIL\tCLIPS\tSIZE\tTRASH\tMODES\tMEASURED")
+	fmt.Fprintln(w, "-----\t-----\t----\t-----\t-----\t--------")
+	for _, u := range rows {
+		names := make([]string, 0, len(u.Modes))
+		for name := range u.Modes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		modes := make([]string, len(names))
+		for i, name := range names {
+			modes[i] = fmt.Sprintf("%s %d (%s)", name, u.Modes[name].Clips, progress.FormatBytes(u.Modes[name].Bytes))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d (%s)\t%s\t%s\n",
+			u.Email, u.Clips, progress.FormatBytes(u.Bytes), u.TrashedClips, progress.FormatBytes(u.TrashedBytes),
+			strings.Join(modes, ", "), u.ComputedAt.Local().Format("2006-01-02 15:04:05"))
+		total += u.Bytes + u.TrashedBytes
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d users, %s in total\n", len(rows), progress.FormatBytes(total))
+	return nil
+}
@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"server/internal/buildinfo"
+)
+
+// ShowVersion prints the build of this binary, then with --remote that of
+// the server, from GET /api/v1/version.
+func ShowVersion(ctx context.Context) error {
+	fmt.Println("Web Clipper")
+	printBuild(buildinfo.Get())
+	if remote == nil {
+		return nil
+	}
+
+	var server buildinfo.Info
+	if err := remote.Do(ctx, http.MethodGet, "/api/v1/version", nil, &server); err != nil {
+		return remoteError(err)
+	}
+	fmt.Println()
+	fmt.Printf("Server (%s)\n", remote.Server)
+	printBuild(server)
+	return nil
+}
+
+func printBuild(info buildinfo.Info) {
+	commit := info.Commit
+	if commit == "" {
+		commit = "unknown"
+	} else if info.Modified {
+		commit += " (modified)"
+	}
+	date := info.Date
+	if date == "" {
+		date = "unknown"
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Version:\t%s\n", info.Version)
+	fmt.Fprintf(w, "Commit:\t%s\n", commit)
+	fmt.Fprintf(w, "Built:\t%s\n", date)
+	fmt.Fprintf(w, "Go:\t%s\n", info.GoVersion)
+	fmt.Fprintf(w, "Platform:\t%s\n", info.Platform)
+	w.Flush()
+}
@@ -0,0 +1,122 @@
+// Package atrest encrypts clip files at rest with age (X25519 keys), so
+// clips on shared disks or in buckets are not plaintext. Encrypted files
+// start with the age header; files without it are read as they are, which
+// keeps clips written before encryption was enabled readable.
+package atrest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// header starts every age file
+const header = "age-encryption.org/v1\n"
+
+// ErrNoKey is returned when reading an encrypted file without a key
+var ErrNoKey = errors.New("the file is encrypted and no storage.encryption key is configured")
+
+// Key encrypts and decrypts files. A nil Key reads plaintext files only.
+type Key struct {
+	identity *age.X25519Identity
+}
+
+// ParseKey reads an age secret key (AGE-SECRET-KEY-1...)
+func ParseKey(s string) (*Key, error) {
+	identity, err := age.ParseX25519Identity(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	return &Key{identity: identity}, nil
+}
+
+// LoadKey returns the key given inline or, without one, in keyFile (as
+// written by age-keygen: comment lines are skipped). It returns nil when
+// neither is set.
+func LoadKey(key, keyFile string) (*Key, error) {
+	if key != "" {
+		return ParseKey(key)
+	}
+	if keyFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the encryption key: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return ParseKey(line)
+		}
+	}
+	return nil, fmt.Errorf("no key in %s", keyFile)
+}
+
+// GenerateKey returns a new secret key and its public recipient
+func GenerateKey() (secret, recipient string, err error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", "", err
+	}
+	return identity.String(), identity.Recipient().String(), nil
+}
+
+// Encrypt returns data encrypted with the key
+func (k *Key) Encrypt(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(data) + 256)
+	w, err := age.Encrypt(&buf, k.identity.Recipient())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// IsEncrypted reports whether a file content starts like an encrypted file
+func IsEncrypted(head []byte) bool {
+	return bytes.HasPrefix(head, []byte(header))
+}
+
+// Reader returns the plaintext of r: decrypted when it is encrypted, as it
+// is otherwise
+func (k *Key) Reader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(len(header))
+	if !IsEncrypted(head) {
+		return br, nil
+	}
+	if k == nil {
+		return nil, ErrNoKey
+	}
+	plain, err := age.Decrypt(br, k.identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plain, nil
+}
+
+// ReadFile returns the plaintext of the file at path
+func (k *Key) ReadFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := k.Reader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return io.ReadAll(r)
+}
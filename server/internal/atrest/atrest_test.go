@@ -0,0 +1,66 @@
+package atrest
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptReadFile(t *testing.T) {
+	secret, recipient, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recipient == "" {
+		t.Fatal("no recipient")
+	}
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key.txt")
+	os.WriteFile(keyFile, []byte("# created: now\n# public key: "+recipient+"\n"+secret+"\n"), 0600)
+	key, err := LoadKey("", keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := key.Encrypt([]byte("# Secret clip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(sealed) || bytes.Contains(sealed, []byte("Secret")) {
+		t.Fatal("the content is not encrypted")
+	}
+	encrypted := filepath.Join(dir, "page.md")
+	plain := filepath.Join(dir, "old.md")
+	os.WriteFile(encrypted, sealed, 0644)
+	os.WriteFile(plain, []byte("# Old clip"), 0644)
+
+	if data, err := key.ReadFile(encrypted); err != nil || string(data) != "# Secret clip" {
+		t.Errorf("ReadFile(encrypted) = %q, %v", data, err)
+	}
+	if data, err := key.ReadFile(plain); err != nil || string(data) != "# Old clip" {
+		t.Errorf("ReadFile(plain) = %q, %v", data, err)
+	}
+
+	// Without a key, only plaintext files can be read
+	var none *Key
+	if data, err := none.ReadFile(plain); err != nil || string(data) != "# Old clip" {
+		t.Errorf("nil ReadFile(plain) = %q, %v", data, err)
+	}
+	if _, err := none.ReadFile(encrypted); !errors.Is(err, ErrNoKey) {
+		t.Errorf("nil ReadFile(encrypted) = %v", err)
+	}
+
+	other, _, _ := GenerateKey()
+	otherKey, _ := ParseKey(other)
+	if _, err := otherKey.ReadFile(encrypted); err == nil {
+		t.Error("expected an error decrypting with another key")
+	}
+	if key, err := LoadKey("", ""); key != nil || err != nil {
+		t.Errorf("LoadKey without a key = %v, %v", key, err)
+	}
+	if _, err := ParseKey("not a key"); err == nil {
+		t.Error("expected an error parsing an invalid key")
+	}
+}
@@ -0,0 +1,62 @@
+// Package buildinfo identifies the build of the binary, for bug reports: its
+// version, git commit and date, set at build time with
+//
+//	-ldflags "-X server/internal/buildinfo.Version=v1.2.0 -X server/internal/buildinfo.Commit=abc1234 -X server/internal/buildinfo.Date=2026-10-16T12:00:00Z"
+//
+// Builds without them (go build, go run) fall back on the VCS information Go
+// embeds.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = "" // RFC 3339
+)
+
+// Info describes the build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // Built from a working tree with uncommitted changes
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"` // GOOS/GOARCH
+}
+
+// Get returns the build's information.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if info.Commit != "" {
+		return info
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Commit = setting.Value
+				if len(info.Commit) > 12 {
+					info.Commit = info.Commit[:12]
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return info
+}
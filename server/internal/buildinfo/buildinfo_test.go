@@ -0,0 +1,19 @@
+package buildinfo
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	defer func(version, commit, date string) { Version, Commit, Date = version, commit, date }(Version, Commit, Date)
+	Version, Commit, Date = "v1.2.0", "abc1234", "2026-10-16T12:00:00Z"
+
+	info := Get()
+	if info.Version != "v1.2.0" || info.Commit != "abc1234" || info.Date != "2026-10-16T12:00:00Z" {
+		t.Errorf("build-time values not used: %+v", info)
+	}
+	if info.GoVersion != runtime.Version() || info.Platform != runtime.GOOS+"/"+runtime.GOARCH {
+		t.Errorf("unexpected toolchain: %+v", info)
+	}
+}
@@ -0,0 +1,243 @@
+// Package clipclient talks to a remote Web Clipper instance over its HTTP API.
+package clipclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"server/client"
+)
+
+// Credentials identify the remote instance and the API token used to reach it:
+// a service token, or the access and refresh tokens of a device login.
+type Credentials struct {
+	Server       string `json:"server"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// CredentialsPath returns the location of the saved credentials file
+// (e.g. ~/.config/web-clipper/credentials.json).
+func CredentialsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "web-clipper", "credentials.json"), nil
+}
+
+// LoadCredentials reads saved credentials, letting WEB_CLIPPER_SERVER and
+// WEB_CLIPPER_TOKEN override them.
+func LoadCredentials() (*Credentials, error) {
+	creds := &Credentials{}
+	if path, err := CredentialsPath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			if err := json.Unmarshal(data, creds); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+		}
+	}
+
+	if server := os.Getenv("WEB_CLIPPER_SERVER"); server != "" {
+		creds.Server = server
+	}
+	if token := os.Getenv("WEB_CLIPPER_TOKEN"); token != "" {
+		creds.Token, creds.RefreshToken = token, ""
+	}
+	return creds, nil
+}
+
+// SaveCredentials writes credentials readable only by the current user.
+func SaveCredentials(creds *Credentials) error {
+	path, err := CredentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// Client is an authenticated API client: API for the endpoints of the SDK,
+// Do for the others (the admin API).
+type Client struct {
+	Server string
+	Token  string
+	HTTP   *http.Client
+	API    *client.Client
+}
+
+// New creates a client for the given credentials. With a refresh token, an
+// expired access token is renewed and the new tokens are saved.
+func New(creds *Credentials) (*Client, error) {
+	if creds.Server == "" {
+		return nil, fmt.Errorf("no server configured (run `web-clipper login --server=...`)")
+	}
+	if creds.Token == "" {
+		return nil, fmt.Errorf("no API token configured (run `web-clipper login --server=...`)")
+	}
+	server := strings.TrimRight(creds.Server, "/")
+	httpClient := &http.Client{Timeout: 2 * time.Minute}
+	api := client.New(server, creds.Token)
+	api.HTTP = httpClient
+	c := &Client{
+		Server: server,
+		Token:  creds.Token,
+		HTTP:   httpClient,
+		API:    api,
+	}
+	if creds.RefreshToken != "" {
+		api.Refresh = creds.RefreshToken
+		api.OnRefresh = func(tokens *client.TokenResponse) {
+			c.Token = tokens.AccessToken
+			saved := &Credentials{Server: server, Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken}
+			if err := SaveCredentials(saved); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to save the renewed token: %v\n", err)
+			}
+		}
+	}
+	return c, nil
+}
+
+// Ping verifies the server is reachable and accepts the token.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.API.GetConfig(ctx)
+	return c.httpError(err)
+}
+
+// FetchClip asks the server to fetch, extract and save a page. A rejected
+// clip returns the server's ClipResponse with the error.
+func (c *Client) FetchClip(ctx context.Context, req client.FetchClipPayload) (*client.ClipResponse, error) {
+	result, err := c.API.FetchClip(ctx, req)
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		result = &client.ClipResponse{}
+		if json.Unmarshal(apiErr.Body, result) == nil && result.Error != nil && result.Error.Message != "" {
+			return result, errors.New(result.Error.Message)
+		}
+	}
+	return result, c.httpError(err)
+}
+
+// HTTPError is returned when the server answers with an error status.
+type HTTPError struct {
+	StatusCode int
+	Message    string
+	RequestID  string // To look the request up in the server's logs
+}
+
+func (e *HTTPError) Error() string {
+	if e.StatusCode == http.StatusUnauthorized {
+		return "server rejected the API token (HTTP 401)"
+	}
+	if e.RequestID != "" {
+		return fmt.Sprintf("server returned HTTP %d: %s (request %s)", e.StatusCode, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("server returned HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// httpError returns the SDK's errors as *HTTPError, the others as they are
+func (c *Client) httpError(err error) error {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		return &HTTPError{StatusCode: apiErr.StatusCode, Message: apiErr.Message, RequestID: apiErr.RequestID}
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("request to %s failed: %w", c.Server, err)
+	}
+	return err
+}
+
+// Do sends a JSON request and decodes the JSON response into out.
+// Error statuses are returned as *HTTPError. A 401 renews the access token
+// of a device login and sends the request again.
+func (c *Client) Do(ctx context.Context, method, path string, in, out interface{}) error {
+	err := c.do(ctx, method, path, in, out)
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusUnauthorized && c.API.Refresh != "" {
+		tokens, refreshErr := c.API.RefreshToken(ctx, client.RefreshPayload{RefreshToken: c.API.Refresh})
+		if refreshErr != nil {
+			return fmt.Errorf("failed to renew the access token: %w", c.httpError(refreshErr))
+		}
+		c.API.Token, c.API.Refresh = tokens.AccessToken, tokens.RefreshToken
+		c.API.OnRefresh(tokens)
+		return c.do(ctx, method, path, in, out)
+	}
+	return err
+}
+
+// do sends one request for Do
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.Server+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to %s failed: %w", c.Server, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if out != nil && len(data) > 0 {
+		json.Unmarshal(data, out) // Error bodies may not match out; status is checked below
+	}
+
+	if resp.StatusCode >= 400 {
+		message, requestID := errorMessage(data)
+		return &HTTPError{StatusCode: resp.StatusCode, Message: message, RequestID: requestID}
+	}
+	return nil
+}
+
+// errorMessage extracts the message and request ID from the error envelope
+// ({"error": {"message", "request_id"}}), or the plain {"error": "message"}
+// of older servers, falling back to the raw body
+func errorMessage(data []byte) (string, string) {
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		var detail client.ErrorDetail
+		if json.Unmarshal(body.Error, &detail) == nil && detail.Message != "" {
+			return detail.Message, detail.RequestID
+		}
+		var message string
+		if json.Unmarshal(body.Error, &message) == nil && message != "" {
+			return message, ""
+		}
+	}
+	return strings.TrimSpace(string(data)), ""
+}
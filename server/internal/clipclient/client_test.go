@@ -0,0 +1,154 @@
+package clipclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"server/client"
+)
+
+func TestCredentialsRoundTrip(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("WEB_CLIPPER_SERVER", "")
+	t.Setenv("WEB_CLIPPER_TOKEN", "")
+
+	if err := SaveCredentials(&Credentials{Server: "https://clips.example.com", Token: "wc_abc"}); err != nil {
+		t.Fatalf("SaveCredentials() failed: %v", err)
+	}
+
+	path, _ := CredentialsPath()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("credentials file missing: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("credentials mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	t.Setenv("WEB_CLIPPER_TOKEN", "wc_override")
+	creds, err := LoadCredentials()
+	if err != nil {
+		t.Fatalf("LoadCredentials() failed: %v", err)
+	}
+	if creds.Server != "https://clips.example.com" || creds.Token != "wc_override" {
+		t.Errorf("unexpected credentials: %+v", creds)
+	}
+	if filepath.Base(path) != "credentials.json" {
+		t.Errorf("unexpected path %s", path)
+	}
+}
+
+func TestFetchClip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer wc_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req client.FetchClipPayload
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/api/v1/clips/fetch" || req.URL != "https://example.com" || len(req.Tags) != 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(client.ClipResponse{Error: &client.ErrorDetail{Code: "bad_request", Message: "bad request"}})
+			return
+		}
+		json.NewEncoder(w).Encode(client.ClipResponse{Success: true, ID: "123", Path: "web-clips/x/page.md"})
+	}))
+	defer srv.Close()
+
+	c, err := New(&Credentials{Server: srv.URL + "/", Token: "wc_test"})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	result, err := c.FetchClip(context.Background(), client.FetchClipPayload{URL: "https://example.com", Tags: []string{"a", "b"}})
+	if err != nil {
+		t.Fatalf("FetchClip() failed: %v", err)
+	}
+	if result.ID != "123" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	if _, err := c.FetchClip(context.Background(), client.FetchClipPayload{URL: "https://other.com"}); err == nil || err.Error() != "bad request" {
+		t.Errorf("expected server error message, got %v", err)
+	}
+
+	c.API.Token = "wrong"
+	_, err = c.FetchClip(context.Background(), client.FetchClipPayload{URL: "https://example.com"})
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 for rejected token, got %v", err)
+	}
+}
+
+func TestNewRequiresCredentials(t *testing.T) {
+	if _, err := New(&Credentials{Token: "x"}); err == nil {
+		t.Error("expected error without server")
+	}
+	if _, err := New(&Credentials{Server: "https://x"}); err == nil {
+		t.Error("expected error without token")
+	}
+}
+
+func TestDeviceLogin(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("WEB_CLIPPER_SERVER", "")
+	t.Setenv("WEB_CLIPPER_TOKEN", "")
+
+	polls, refused := 0, false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/device/code":
+			json.NewEncoder(w).Encode(client.DeviceCodeResponse{DeviceCode: "device", UserCode: "BCDF-GHJK", VerificationUri: "http://x/auth/device", Interval: 1})
+		case "/auth/device/token":
+			var req client.DeviceTokenPayload
+			json.NewDecoder(r.Body).Decode(&req)
+			polls++
+			code := "authorization_pending"
+			if refused {
+				code = "access_denied"
+			} else if polls > 1 && req.DeviceCode == "device" {
+				json.NewEncoder(w).Encode(client.TokenResponse{AccessToken: "access", RefreshToken: "refresh"})
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(client.ErrorResponse{Error: client.ErrorDetail{Code: code, Message: code}})
+		case "/api/v1/config":
+			if r.Header.Get("Authorization") != "Bearer access" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(client.ConfigResponse{})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	if err := Login(context.Background(), srv.URL, ""); err != nil {
+		t.Fatalf("Login() failed: %v", err)
+	}
+	if polls != 2 {
+		t.Errorf("polled %d times, want 2", polls)
+	}
+	creds, err := LoadCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.Token != "access" || creds.RefreshToken != "refresh" {
+		t.Errorf("unexpected credentials: %+v", creds)
+	}
+
+	refused = true
+	if _, err := DeviceLogin(context.Background(), srv.URL, io.Discard); err == nil || err.Error() != "the login was refused" {
+		t.Errorf("expected a refused login, got %v", err)
+	}
+}
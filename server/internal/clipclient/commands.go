@@ -0,0 +1,151 @@
+package clipclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"server/client"
+)
+
+// Login saves credentials for later commands once the server accepts them:
+// the token, or without one the tokens of a device login.
+func Login(ctx context.Context, server, token string) error {
+	if server == "" {
+		return fmt.Errorf("--server is required")
+	}
+
+	creds := &Credentials{Server: server, Token: token}
+	if token == "" {
+		tokens, err := DeviceLogin(ctx, server, os.Stdout)
+		if err != nil {
+			return err
+		}
+		creds.Token, creds.RefreshToken = tokens.AccessToken, tokens.RefreshToken
+	}
+	c, err := New(creds)
+	if err != nil {
+		return err
+	}
+	if err := c.Ping(ctx); err != nil {
+		return err
+	}
+	creds.Server = c.Server
+
+	if err := SaveCredentials(creds); err != nil {
+		return fmt.Errorf("failed to save credentials: %w", err)
+	}
+	path, _ := CredentialsPath()
+	fmt.Printf("Logged in to %s (credentials saved to %s)\n", creds.Server, path)
+	return nil
+}
+
+// DeviceLogin logs in with the OAuth device flow: it prints the code the
+// user enters in a browser, then polls the server until the login is
+// approved, refused or expired.
+func DeviceLogin(ctx context.Context, server string, w io.Writer) (*client.TokenResponse, error) {
+	server = strings.TrimRight(server, "/")
+	api := client.New(server, "")
+	api.HTTP = &http.Client{Timeout: 30 * time.Second}
+	code, err := api.RequestDeviceCode(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the login on %s: %w", server, err)
+	}
+	fmt.Fprintf(w, "To log in, open %s and enter the code %s\n", code.VerificationUri, code.UserCode)
+	fmt.Fprintf(w, "(or open %s)\n", code.VerificationUriComplete)
+
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		tokens, err := api.DeviceToken(ctx, client.DeviceTokenPayload{DeviceCode: code.DeviceCode})
+		var apiErr *client.APIError
+		if err == nil || !errors.As(err, &apiErr) {
+			return tokens, err
+		}
+		switch apiErr.Code {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		case "access_denied":
+			return nil, fmt.Errorf("the login was refused")
+		case "expired_token":
+			return nil, fmt.Errorf("the code expired before the login was approved, run login again")
+		default:
+			return nil, &HTTPError{StatusCode: apiErr.StatusCode, Message: apiErr.Message, RequestID: apiErr.RequestID}
+		}
+	}
+}
+
+// ClipOptions are the flags of `web-clipper clip`.
+type ClipOptions struct {
+	URL    string
+	Title  string
+	Mode   string
+	Tags   string // Comma-separated
+	Notes  string
+	Server string // Overrides saved credentials
+	Token  string
+}
+
+// Clip asks the remote server to fetch and save a URL, printing the result.
+func Clip(ctx context.Context, opts ClipOptions) error {
+	if opts.URL == "" {
+		return fmt.Errorf("a URL is required")
+	}
+
+	creds, err := LoadCredentials()
+	if err != nil {
+		return err
+	}
+	if opts.Server != "" {
+		creds.Server = opts.Server
+	}
+	if opts.Token != "" {
+		creds.Token = opts.Token
+	}
+
+	c, err := New(creds)
+	if err != nil {
+		return err
+	}
+
+	var tags []string
+	for _, tag := range strings.Split(opts.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	result, err := c.FetchClip(ctx, client.FetchClipPayload{
+		URL:   opts.URL,
+		Title: opts.Title,
+		Mode:  opts.Mode,
+		Tags:  tags,
+		Notes: opts.Notes,
+	})
+	if err != nil {
+		if result != nil && result.Duplicate {
+			return fmt.Errorf("%w (existing clip %s)", err, result.ID)
+		}
+		return err
+	}
+
+	fmt.Printf("Clipped: %s\n", result.Path)
+	fmt.Printf("ID:      %s\n", result.ID)
+	if result.Version > 1 {
+		fmt.Printf("Version: %d\n", result.Version)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Package cloud uploads files to a folder of a user's Dropbox or Google
+// Drive, through their APIs, with the OAuth token the user granted.
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"server/internal/config"
+
+	"golang.org/x/oauth2"
+)
+
+// Providers
+const (
+	Dropbox     = "dropbox"
+	GoogleDrive = "google_drive"
+)
+
+// Providers lists the supported providers
+var Providers = []string{Dropbox, GoogleDrive}
+
+// Folder is a folder of a user's cloud storage
+type Folder interface {
+	// Put writes data to rel (slash-separated, relative to the folder),
+	// creating its parent folders and replacing the file there
+	Put(ctx context.Context, rel string, data []byte) error
+}
+
+// App returns the configured app of a provider, nil when the provider is
+// unknown or not configured
+func App(cfg config.CloudConfig, provider string) *config.CloudAppConfig {
+	var app config.CloudAppConfig
+	switch provider {
+	case Dropbox:
+		app = cfg.Dropbox
+	case GoogleDrive:
+		app = cfg.GoogleDrive
+	}
+	if app.ClientID == "" {
+		return nil
+	}
+	return &app
+}
+
+// OAuthConfig returns the OAuth config of a provider's app, users being
+// sent back to redirectURL
+func OAuthConfig(provider string, app config.CloudAppConfig, redirectURL string) *oauth2.Config {
+	conf := &oauth2.Config{
+		ClientID:     app.ClientID,
+		ClientSecret: app.ClientSecret,
+		RedirectURL:  redirectURL,
+	}
+	switch provider {
+	case Dropbox:
+		conf.Endpoint = oauth2.Endpoint{
+			AuthURL:  baseURL(app, "https://www.dropbox.com") + "/oauth2/authorize",
+			TokenURL: baseURL(app, "https://api.dropboxapi.com") + "/oauth2/token",
+		}
+	case GoogleDrive:
+		conf.Endpoint = oauth2.Endpoint{
+			AuthURL:  baseURL(app, "https://accounts.google.com") + "/o/oauth2/auth",
+			TokenURL: baseURL(app, "https://oauth2.googleapis.com") + "/token",
+		}
+		// Only the files the app creates, not the rest of the user's Drive
+		conf.Scopes = []string{"https://www.googleapis.com/auth/drive.file"}
+	}
+	return conf
+}
+
+// AuthCodeOptions are the options of the authorization URL of a provider,
+// asking for a refresh token so uploads keep working once the user left
+func AuthCodeOptions(provider string) []oauth2.AuthCodeOption {
+	switch provider {
+	case Dropbox:
+		return []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("token_access_type", "offline")}
+	case GoogleDrive:
+		return []oauth2.AuthCodeOption{oauth2.AccessTypeOffline, oauth2.ApprovalForce}
+	}
+	return nil
+}
+
+// New returns a folder of a provider, named by a slash-separated path from
+// the root of the user's storage. Its requests are made with client, which
+// is expected to add the user's token.
+func New(provider string, app config.CloudAppConfig, client *http.Client, folder string) (Folder, error) {
+	folder = strings.Trim(folder, "/")
+	switch provider {
+	case Dropbox:
+		return &dropboxFolder{client: client, baseURL: baseURL(app, "https://content.dropboxapi.com"), root: folder}, nil
+	case GoogleDrive:
+		return &driveFolder{client: client, baseURL: baseURL(app, "https://www.googleapis.com"), root: folder, ids: map[string]string{}}, nil
+	}
+	return nil, fmt.Errorf("unknown cloud provider: %s", provider)
+}
+
+// baseURL returns the app's BaseURL, or the provider's
+func baseURL(app config.CloudAppConfig, def string) string {
+	if app.BaseURL != "" {
+		return strings.TrimSuffix(app.BaseURL, "/")
+	}
+	return def
+}
+
+// apiError describes a failed API response
+func apiError(provider string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s: %s: %s", provider, resp.Status, strings.TrimSpace(string(body)))
+}
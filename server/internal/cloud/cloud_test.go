@@ -0,0 +1,178 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"server/internal/config"
+)
+
+func TestDropboxPut(t *testing.T) {
+	files := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var arg struct {
+			Path string `json:"path"`
+			Mode string `json:"mode"`
+		}
+		if r.URL.Path != "/2/files/upload" || json.Unmarshal([]byte(r.Header.Get("Dropbox-API-Arg")), &arg) != nil || arg.Mode != "overwrite" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		files[arg.Path] = string(data)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	folder, err := New(Dropbox, config.CloudAppConfig{BaseURL: srv.URL}, srv.Client(), "/Web Clipper/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := folder.Put(context.Background(), "web-clips/Café/page.md", []byte("# Café")); err != nil {
+		t.Fatal(err)
+	}
+	if files["/Web Clipper/web-clips/Café/page.md"] != "# Café" {
+		t.Errorf("files = %v", files)
+	}
+}
+
+// fakeDrive is an in-memory Drive API
+type fakeDrive struct {
+	mu    sync.Mutex
+	files map[string]driveFile // By ID
+}
+
+type driveFile struct {
+	name, parent, mimeType, content string
+}
+
+var driveQuery = regexp.MustCompile(`^name = '(.*)' and '(.*)' in parents and mimeType (!?=) '.*' and trashed = false$`)
+
+func (f *fakeDrive) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/drive/v3/files":
+		m := driveQuery.FindStringSubmatch(r.URL.Query().Get("q"))
+		var ids []string
+		for id, file := range f.files {
+			if m != nil && file.name == m[1] && file.parent == m[2] && (file.mimeType == folderType) == (m[3] == "=") {
+				ids = append(ids, fmt.Sprintf(`{"id":%q}`, id))
+			}
+		}
+		fmt.Fprintf(w, `{"files":[%s]}`, strings.Join(ids, ","))
+	case r.Method == http.MethodPost && r.URL.Path == "/drive/v3/files":
+		var meta struct {
+			Name     string   `json:"name"`
+			MimeType string   `json:"mimeType"`
+			Parents  []string `json:"parents"`
+		}
+		json.NewDecoder(r.Body).Decode(&meta)
+		fmt.Fprintf(w, `{"id":%q}`, f.add(driveFile{name: meta.Name, parent: meta.Parents[0], mimeType: meta.MimeType}))
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/drive/v3/files":
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		var meta struct {
+			Name    string   `json:"name"`
+			Parents []string `json:"parents"`
+		}
+		part, _ := mr.NextPart()
+		json.NewDecoder(part).Decode(&meta)
+		part, _ = mr.NextPart()
+		content, _ := io.ReadAll(part)
+		fmt.Fprintf(w, `{"id":%q}`, f.add(driveFile{name: meta.Name, parent: meta.Parents[0], content: string(content)}))
+	case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/upload/drive/v3/files/"):
+		id := strings.TrimPrefix(r.URL.Path, "/upload/drive/v3/files/")
+		file := f.files[id]
+		content, _ := io.ReadAll(r.Body)
+		file.content = string(content)
+		f.files[id] = file
+		fmt.Fprintf(w, `{"id":%q}`, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeDrive) add(file driveFile) string {
+	id := fmt.Sprintf("id%d", len(f.files)+1)
+	f.files[id] = file
+	return id
+}
+
+// path returns the path of a file from the root of the Drive
+func (f *fakeDrive) path(id string) string {
+	file := f.files[id]
+	if file.parent == "root" {
+		return file.name
+	}
+	return f.path(file.parent) + "/" + file.name
+}
+
+func TestDrivePut(t *testing.T) {
+	drive := &fakeDrive{files: map[string]driveFile{}}
+	srv := httptest.NewServer(drive)
+	defer srv.Close()
+
+	ctx := context.Background()
+	folder, err := New(GoogleDrive, config.CloudAppConfig{BaseURL: srv.URL}, srv.Client(), "Web Clipper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, put := range []struct{ rel, content string }{
+		{"web-clips/a/page.md", "# A"},
+		{"web-clips/a/media/hero.png", "png"},
+		{"web-clips/a/page.md", "# A, edited"},
+	} {
+		if err := folder.Put(ctx, put.rel, []byte(put.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A second upload looks the folders up instead of creating them again
+	other, _ := New(GoogleDrive, config.CloudAppConfig{BaseURL: srv.URL}, srv.Client(), "Web Clipper")
+	if err := other.Put(ctx, "web-clips/b/page.md", []byte("# B")); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	for id, file := range drive.files {
+		if file.mimeType != folderType {
+			got[drive.path(id)] = file.content
+		}
+	}
+	want := map[string]string{
+		"Web Clipper/web-clips/a/page.md":        "# A, edited",
+		"Web Clipper/web-clips/a/media/hero.png": "png",
+		"Web Clipper/web-clips/b/page.md":        "# B",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+	if len(drive.files) != 8 { // 5 folders, 3 files
+		t.Errorf("%d files and folders, want 8", len(drive.files))
+	}
+}
+
+func TestApp(t *testing.T) {
+	cfg := config.CloudConfig{Dropbox: config.CloudAppConfig{ClientID: "key"}}
+	if App(cfg, Dropbox) == nil {
+		t.Error("dropbox is configured")
+	}
+	if App(cfg, GoogleDrive) != nil || App(cfg, "box") != nil {
+		t.Error("only dropbox is configured")
+	}
+	conf := OAuthConfig(GoogleDrive, cfg.GoogleDrive, "https://clipper.example.com/callback")
+	if !strings.HasPrefix(conf.AuthCodeURL("state", AuthCodeOptions(GoogleDrive)...), "https://accounts.google.com/o/oauth2/auth?access_type=offline") {
+		t.Errorf("auth URL = %s", conf.AuthCodeURL("state"))
+	}
+}
@@ -0,0 +1,174 @@
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// folderType is the MIME type of Google Drive folders
+const folderType = "application/vnd.google-apps.folder"
+
+// driveFolder uploads with the Drive v3 API. Drive finds files by parent
+// and name rather than by path, so folders are looked up (and created)
+// level by level and their IDs kept.
+type driveFolder struct {
+	client  *http.Client
+	baseURL string
+	root    string
+
+	mu  sync.Mutex
+	ids map[string]string // Folder IDs by path
+}
+
+func (d *driveFolder) Put(ctx context.Context, rel string, data []byte) error {
+	dir, name := path.Split(path.Join(d.root, rel))
+	parent, err := d.folderID(ctx, strings.Trim(dir, "/"))
+	if err != nil {
+		return err
+	}
+	id, err := d.find(ctx, parent, name, false)
+	if err != nil {
+		return err
+	}
+
+	// An existing file gets a new revision
+	if id != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPatch, d.baseURL+"/upload/drive/v3/files/"+url.PathEscape(id)+"?uploadType=media", bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		return d.do(req, nil)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	meta, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(meta).Encode(map[string]interface{}{"name": name, "parents": []string{parent}}); err != nil {
+		return err
+	}
+	content, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/octet-stream"}})
+	if err != nil {
+		return err
+	}
+	if _, err := content.Write(data); err != nil {
+		return err
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.baseURL+"/upload/drive/v3/files?uploadType=multipart", &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "multipart/related; boundary="+mw.Boundary())
+	return d.do(req, nil)
+}
+
+// folderID returns the ID of the folder at p ("root" for the root of the
+// Drive), creating it when it does not exist
+func (d *driveFolder) folderID(ctx context.Context, p string) (string, error) {
+	if p == "" || p == "." {
+		return "root", nil
+	}
+	d.mu.Lock()
+	id, ok := d.ids[p]
+	d.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	parent, err := d.folderID(ctx, path.Dir(p))
+	if err != nil {
+		return "", err
+	}
+	name := path.Base(p)
+	if id, err = d.find(ctx, parent, name, true); err != nil {
+		return "", err
+	}
+	if id == "" {
+		meta, err := json.Marshal(map[string]interface{}{"name": name, "mimeType": folderType, "parents": []string{parent}})
+		if err != nil {
+			return "", err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.baseURL+"/drive/v3/files?fields=id", bytes.NewReader(meta))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		var created struct {
+			ID string `json:"id"`
+		}
+		if err := d.do(req, &created); err != nil {
+			return "", err
+		}
+		id = created.ID
+	}
+
+	d.mu.Lock()
+	d.ids[p] = id
+	d.mu.Unlock()
+	return id, nil
+}
+
+// find returns the ID of the file or folder named name in parent, "" when
+// there is none
+func (d *driveFolder) find(ctx context.Context, parent, name string, folder bool) (string, error) {
+	op := "!="
+	if folder {
+		op = "="
+	}
+	q := fmt.Sprintf("name = '%s' and '%s' in parents and mimeType %s '%s' and trashed = false", driveQuote(name), driveQuote(parent), op, folderType)
+	query := url.Values{"q": {q}, "fields": {"files(id)"}, "spaces": {"drive"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.baseURL+"/drive/v3/files?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	var list struct {
+		Files []struct {
+			ID string `json:"id"`
+		} `json:"files"`
+	}
+	if err := d.do(req, &list); err != nil {
+		return "", err
+	}
+	if len(list.Files) == 0 {
+		return "", nil
+	}
+	return list.Files[0].ID, nil
+}
+
+// do sends a request and decodes its JSON response into out, when not nil
+func (d *driveFolder) do(req *http.Request, out interface{}) error {
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return apiError(GoogleDrive, resp)
+	}
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// driveQuote escapes a string for a Drive query literal
+func driveQuote(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
@@ -0,0 +1,63 @@
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf16"
+)
+
+// dropboxFolder uploads with the files/upload endpoint, which creates
+// parent folders (files up to 150MB)
+type dropboxFolder struct {
+	client  *http.Client
+	baseURL string
+	root    string
+}
+
+func (d *dropboxFolder) Put(ctx context.Context, rel string, data []byte) error {
+	arg, err := json.Marshal(map[string]interface{}{
+		"path": path.Join("/", d.root, rel),
+		"mode": "overwrite",
+		"mute": true, // No notification on the user's devices for each file
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.baseURL+"/2/files/upload", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Dropbox-API-Arg", asciiJSON(arg))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return apiError(Dropbox, resp)
+	}
+	return nil
+}
+
+// asciiJSON escapes the non-ASCII characters of a JSON document, as HTTP
+// headers carry ASCII only
+func asciiJSON(doc []byte) string {
+	var b strings.Builder
+	for _, r := range string(doc) {
+		if r < 0x80 {
+			b.WriteRune(r)
+			continue
+		}
+		for _, u := range utf16.Encode([]rune{r}) {
+			fmt.Fprintf(&b, `\u%04x`, u)
+		}
+	}
+	return b.String()
+}
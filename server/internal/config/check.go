@@ -0,0 +1,294 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DevJWTSecret is the JWT secret of dev mode, and the default of the example
+// config: fine on a laptop, never on a server
+const DevJWTSecret = "dev-secret-change-in-production"
+
+// minJWTSecretLen is the shortest JWT secret accepted outside dev mode: 32
+// characters of `openssl rand -base64 32` or similar
+const minJWTSecretLen = 32
+
+// imageFormats are the formats images.allowed_formats may list
+var imageFormats = []string{"png", "jpeg", "gif", "webp", "avif", "bmp", "ico", "svg", "pdf"}
+
+// Severity of a Problem
+const (
+	SeverityError   = "error"   // The server would not work (or not safely)
+	SeverityWarning = "warning" // Likely a mistake
+)
+
+// Problem is something wrong with a config, found by Check
+type Problem struct {
+	Severity string
+	Field    string // YAML path, e.g. jwt.secret
+	Message  string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.Severity, p.Field, p.Message)
+}
+
+// Check validates a loaded config: secrets, OAuth, storage (which must be
+// writable, so it touches the disk) and limits.
+func (c *Config) Check() []Problem {
+	var problems []Problem
+	add := func(severity, field, format string, args ...interface{}) {
+		problems = append(problems, Problem{Severity: severity, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	// Outside dev mode, what only weakens a dev instance is an error
+	strict := SeverityError
+	if c.DevMode.Enabled {
+		strict = SeverityWarning
+	}
+
+	switch secret := c.JWT.Secret; {
+	case secret == "":
+		add(SeverityError, "jwt.secret", "is required")
+	case secret == DevJWTSecret:
+		add(strict, "jwt.secret", "is the dev mode default; set JWT_SECRET (e.g. openssl rand -base64 32)")
+	case len(secret) < minJWTSecretLen:
+		add(strict, "jwt.secret", "is %d characters, use at least %d", len(secret), minJWTSecretLen)
+	}
+	if c.JWT.ExpiryHours < 0 {
+		add(SeverityError, "jwt.expiry_hours", "must be positive")
+	}
+	if c.Logging.ProxyHops < 0 {
+		add(SeverityError, "logging.proxy_hops", "must be positive")
+	}
+
+	problems = append(problems, c.checkOAuth()...)
+	problems = append(problems, c.checkStorage()...)
+
+	img := c.Images
+	if img.MaxSizeBytes < 0 {
+		add(SeverityError, "images.max_size_bytes", "must be positive")
+	}
+	if img.MaxTotalBytes < img.MaxSizeBytes {
+		add(SeverityError, "images.max_total_bytes", "(%d) is below images.max_size_bytes (%d): no clip could hold the largest image", img.MaxTotalBytes, img.MaxSizeBytes)
+	}
+	if img.MaxDimensionPx < 16 || img.MaxDimensionPx > 16384 {
+		add(SeverityError, "images.max_dimension_px", "(%d) must be between 16 and 16384", img.MaxDimensionPx)
+	}
+	if img.WebPQuality < 1 || img.WebPQuality > 100 {
+		add(SeverityError, "images.webp_quality", "(%d) must be between 1 and 100", img.WebPQuality)
+	}
+	for _, format := range img.AllowedFormats {
+		switch {
+		case !slices.Contains(imageFormats, format):
+			add(SeverityError, "images.allowed_formats", "unknown format %q (known: %s)", format, strings.Join(imageFormats, ", "))
+		case format == "svg":
+			add(SeverityWarning, "images.allowed_formats", "svg images can carry scripts")
+		}
+	}
+	if limit := c.Server.MaxClipBodyBytes; limit > 0 && limit < img.MaxTotalBytes {
+		add(SeverityWarning, "server.max_clip_body_bytes", "(%d) is below images.max_total_bytes (%d): clips with their images would be rejected", limit, img.MaxTotalBytes)
+	}
+	if img.FetchAllowPrivate && !c.DevMode.Enabled {
+		add(SeverityWarning, "images.fetch_allow_private", "lets clients make the server fetch internal addresses")
+	}
+	if c.Webhooks.RetentionDays < 0 {
+		add(SeverityError, "webhooks.retention_days", "must be positive")
+	}
+	if c.Webhooks.AllowPrivate && !c.DevMode.Enabled {
+		add(SeverityWarning, "webhooks.allow_private", "lets users make the server post to internal addresses")
+	}
+
+	if c.RateLimit.Enabled && c.RateLimit.Store == "redis" {
+		if u, err := url.Parse(c.RateLimit.RedisURL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") {
+			add(SeverityError, "rate_limit.redis_url", "must be a redis:// or rediss:// URL for store: redis")
+		}
+	}
+	if c.Storage.Encryption.Enabled && c.Storage.Encryption.Key == "" && c.Storage.Encryption.KeyFile == "" {
+		add(SeverityError, "storage.encryption", "is enabled without a key or key_file")
+	}
+	return problems
+}
+
+// oauthProviders lists the values of oauth.provider
+const oauthProviders = "google, keycloak, github, gitlab or oidc"
+
+// checkOAuth checks that OAuth is configured completely, or not at all
+func (c *Config) checkOAuth() []Problem {
+	o := c.OAuth
+	var problems []Problem
+	add := func(severity, field, message string) {
+		problems = append(problems, Problem{Severity: severity, Field: field, Message: message})
+	}
+
+	if o.Provider == "" && o.ClientID == "" && o.ClientSecret == "" {
+		if !c.DevMode.Enabled {
+			add(SeverityWarning, "oauth", "is not configured: only service tokens can log in")
+		}
+		return nil
+	}
+	switch o.Provider {
+	case "google", "github", "gitlab":
+	case "keycloak":
+		if o.Keycloak.BaseURL == "" {
+			add(SeverityError, "oauth.keycloak.base_url", "is required for the keycloak provider")
+		}
+		if o.Keycloak.Realm == "" {
+			add(SeverityError, "oauth.keycloak.realm", "is required for the keycloak provider")
+		}
+	case "oidc":
+		if o.OIDC.IssuerURL == "" {
+			add(SeverityError, "oauth.oidc.issuer_url", "is required for the oidc provider")
+		}
+	case "":
+		add(SeverityError, "oauth.provider", "is required ("+oauthProviders+")")
+	default:
+		add(SeverityError, "oauth.provider", fmt.Sprintf("%q is not supported (%s)", o.Provider, oauthProviders))
+	}
+	for _, base := range []struct{ field, url string }{
+		{"oauth.keycloak.base_url", o.Keycloak.BaseURL},
+		{"oauth.github.base_url", o.GitHub.BaseURL},
+		{"oauth.gitlab.base_url", o.GitLab.BaseURL},
+		{"oauth.oidc.issuer_url", o.OIDC.IssuerURL},
+	} {
+		if u, err := url.Parse(base.url); base.url != "" && (err != nil || u.Scheme == "" || u.Host == "") {
+			add(SeverityError, base.field, "must be an absolute URL")
+		}
+	}
+	if o.ClientID == "" {
+		add(SeverityError, "oauth.client_id", "is required")
+	}
+	if o.ClientSecret == "" {
+		add(SeverityError, "oauth.client_secret", "is required")
+	}
+	if len(o.ExtensionIDs) == 0 {
+		add(SeverityWarning, "oauth.extension_ids", "is empty: the browser extension cannot log in")
+	}
+	if u, err := url.Parse(o.RedirectURL); err != nil || u.Scheme == "" || u.Host == "" {
+		add(SeverityError, "oauth.redirect_url", "must be the absolute URL of /auth/callback")
+	} else if u.Path != "/auth/callback" {
+		add(SeverityWarning, "oauth.redirect_url", "does not end in /auth/callback")
+	}
+	return problems
+}
+
+// checkStorage checks that the base path exists, or can be created, and is
+// writable
+func (c *Config) checkStorage() []Problem {
+	const field = "storage.base_path"
+	path := c.Storage.BasePath
+	if path == "" {
+		return []Problem{{SeverityError, field, "is required"}}
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		if !c.Storage.CreateMissing {
+			return []Problem{{SeverityError, field, fmt.Sprintf("%s does not exist (or set storage.create_missing)", path)}}
+		}
+		// Created at startup: its closest existing parent must be writable
+		parent := filepath.Dir(filepath.Clean(path))
+		for {
+			if _, err := os.Stat(parent); err == nil || parent == filepath.Dir(parent) {
+				break
+			}
+			parent = filepath.Dir(parent)
+		}
+		path = parent
+	} else if err != nil {
+		return []Problem{{SeverityError, field, err.Error()}}
+	} else if !info.IsDir() {
+		return []Problem{{SeverityError, field, fmt.Sprintf("%s is not a directory", path)}}
+	}
+
+	f, err := os.CreateTemp(path, ".config-check-*")
+	if err != nil {
+		return []Problem{{SeverityError, field, fmt.Sprintf("%s is not writable: %v", path, err)}}
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
+
+// envRef matches ${VAR}, ${VAR:-default} and $VAR; the second group is set
+// when the reference has a default
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// EnvRefs returns the environment variables a config file references, in
+// order, with whether each has a default
+func EnvRefs(data string) (names []string, defaults map[string]bool) {
+	defaults = map[string]bool{}
+	for _, m := range envRef.FindAllStringSubmatch(data, -1) {
+		name := m[1] + m[3]
+		if _, seen := defaults[name]; !seen {
+			names = append(names, name)
+		}
+		defaults[name] = defaults[name] || m[2] != ""
+	}
+	return names, defaults
+}
+
+// Files returns the config file at path and its .local.yaml override when
+// there is one, in the order Load merges them
+func Files(path string) []string {
+	files := []string{path}
+	localPath := strings.TrimSuffix(path, ".yaml") + ".local.yaml"
+	if _, err := os.Stat(localPath); err == nil {
+		files = append(files, localPath)
+	}
+	return files
+}
+
+// redacted replaces the value of secrets in Redact
+const redacted = "[redacted]"
+
+// secretKeys are the config keys holding secrets
+var secretKeys = regexp.MustCompile(`(^|_)(secret|password|token|key|dsn)$`)
+
+// Redact returns the config as YAML with its secrets replaced, and the
+// password of URLs removed, to show or share it.
+func Redact(cfg *Config) ([]byte, error) {
+	var doc yaml.Node
+	if err := doc.Encode(cfg); err != nil {
+		return nil, err
+	}
+	redactNode(&doc)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), enc.Close()
+}
+
+func redactNode(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if value.Kind == yaml.ScalarNode && value.Value != "" && secretKeys.MatchString(key.Value) {
+				value.SetString(redacted)
+				continue
+			}
+			if value.Kind == yaml.ScalarNode && (strings.HasSuffix(key.Value, "url") || key.Value == "remote") {
+				// redis://:password@host, https://token@github.com/...
+				if u, err := url.Parse(value.Value); err == nil && u.User != nil {
+					if _, ok := u.User.Password(); !ok {
+						u.User = url.User("xxxxx")
+					}
+					value.SetString(u.Redacted())
+				}
+			}
+		}
+	}
+	for _, child := range node.Content {
+		redactNode(child)
+	}
+}
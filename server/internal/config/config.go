@@ -41,40 +41,114 @@ func FindConfigPath() (string, error) {
 }
 
 type Config struct {
-	Server  ServerConfig  `yaml:"server"`
-	OAuth   OAuthConfig   `yaml:"oauth"`
-	Storage StorageConfig `yaml:"storage"`
-	Images  ImagesConfig  `yaml:"images"`
-	JWT     JWTConfig     `yaml:"jwt"`
-	DevMode DevModeConfig `yaml:"dev_mode"`
-	Admin   AdminConfig   `yaml:"admin"`
+	Server      ServerConfig      `yaml:"server"`
+	OAuth       OAuthConfig       `yaml:"oauth"`
+	Storage     StorageConfig     `yaml:"storage"`
+	Images      ImagesConfig      `yaml:"images"`
+	JWT         JWTConfig         `yaml:"jwt"`
+	DevMode     DevModeConfig     `yaml:"dev_mode"`
+	Admin       AdminConfig       `yaml:"admin"`
+	SMTP        SMTPConfig        `yaml:"smtp"`
+	Kindle      KindleConfig      `yaml:"kindle"`
+	OCR         OCRConfig         `yaml:"ocr"`
+	PDF         PDFConfig         `yaml:"pdf"`
+	Clips       ClipsConfig       `yaml:"clips"`
+	Tags        TagsConfig        `yaml:"tags"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks"`
+	Uploads     UploadsConfig     `yaml:"uploads"`
+	Jobs        JobsConfig        `yaml:"jobs"`
+	Metrics     MetricsConfig     `yaml:"metrics"`
+	Mirror      MirrorConfig      `yaml:"mirror"`
+	Replication ReplicationConfig `yaml:"replication"`
+	GitSync     GitSyncConfig     `yaml:"git_sync"`
+	Cloud       CloudConfig       `yaml:"cloud"`
+	Public      PublicConfig      `yaml:"public"`
+	Health      HealthConfig      `yaml:"health"`
+	Markdown    MarkdownConfig    `yaml:"markdown"`
+	Favicons    FaviconsConfig    `yaml:"favicons"`
+	Scheduler   SchedulerConfig   `yaml:"scheduler"`
+	Logging     LoggingConfig     `yaml:"logging"`
+	Errors      ErrorsConfig      `yaml:"error_reporting"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
 }
 
 type AdminConfig struct {
 	AllowedPaths []string `yaml:"allowed_paths"`
+	Emails       []string `yaml:"emails"` // Users allowed to call /api/v1/admin (empty disables the admin API)
 }
 
 type DevModeConfig struct {
-	Enabled bool   `yaml:"enabled"`
-	UserID  string `yaml:"user_id"`
-	Email   string `yaml:"email"`
-	Name    string `yaml:"name"`
+	Enabled bool        `yaml:"enabled"`
+	UserID  string      `yaml:"user_id"`
+	Email   string      `yaml:"email"`
+	Name    string      `yaml:"name"`
+	Chaos   ChaosConfig `yaml:"chaos"`
+}
+
+// ChaosConfig injects storage faults into clip writes, in dev mode only, to
+// exercise the error handling of clip creation
+type ChaosConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	ErrorRate    float64  `yaml:"error_rate"`     // Share of file operations failing with EIO
+	NoSpaceRate  float64  `yaml:"no_space_rate"`  // Share of writes failing with ENOSPC
+	WriteDelayMs int      `yaml:"write_delay_ms"` // Added to every write and sync
+	Ops          []string `yaml:"ops"`            // open, mkdir, rename, write, sync (all when empty)
+}
+
+// LoggingConfig is the format of the server's log records, audit events
+// included
+type LoggingConfig struct {
+	Format     string `yaml:"format"`      // json (default) or text
+	Level      string `yaml:"level"`       // debug, info (default), warn or error
+	TrustProxy bool   `yaml:"trust_proxy"` // Take the client from X-Forwarded-For rather than the peer, and keep X-Request-ID
+	ProxyHops  int    `yaml:"proxy_hops"`  // Proxies appending to X-Forwarded-For: the client is this many entries from the right (default 1)
+}
+
+// ErrorsConfig sends panics and 5xx responses to Sentry or a compatible
+// service when DSN is set
+type ErrorsConfig struct {
+	DSN         string `yaml:"dsn"`
+	Environment string `yaml:"environment"` // Defaults to GO_ENV
+}
+
+// RateLimitConfig limits the requests to /auth and /api/v1 by client
+// address and, once authenticated, by credential (each service token, and
+// the login sessions of a user)
+type RateLimitConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Store    string        `yaml:"store"`     // memory (default, per instance) or redis (shared)
+	RedisURL string        `yaml:"redis_url"` // redis://[:password@]host[:port][/db], for store: redis
+	PerIP    RateLimitRule `yaml:"per_ip"`
+	PerUser  RateLimitRule `yaml:"per_user"`
+}
+
+// RateLimitRule lets burst requests through at once, then requests_per_minute
+type RateLimitRule struct {
+	RequestsPerMinute int `yaml:"requests_per_minute"`
+	Burst             int `yaml:"burst"` // Defaults to requests_per_minute
 }
 
 type ServerConfig struct {
-	Port    string `yaml:"port"`
-	Host    string `yaml:"host"`
-	BaseURL string `yaml:"base_url"`
+	Port             string `yaml:"port"`
+	Host             string `yaml:"host"`
+	BaseURL          string `yaml:"base_url"`
+	MaxBodyBytes     int64  `yaml:"max_body_bytes"`      // Request bodies but clips' (-1 = unlimited)
+	MaxClipBodyBytes int64  `yaml:"max_clip_body_bytes"` // Clips, quick clips and upload chunks (-1 = unlimited)
 }
 
 type OAuthConfig struct {
-	Provider       string         `yaml:"provider"`
+	Provider       string         `yaml:"provider"` // google, keycloak, github, gitlab or oidc
 	ClientID       string         `yaml:"client_id"`
 	ClientSecret   string         `yaml:"client_secret"`
 	RedirectURL    string         `yaml:"redirect_url"`
+	Scopes         []string       `yaml:"scopes"`          // Instead of the provider's defaults
 	AllowedDomains []string       `yaml:"allowed_domains"` // Email domains allowed to sign up (empty = all allowed)
 	AllowedEmails  []string       `yaml:"allowed_emails"`  // Specific emails allowed (whitelist)
+	ExtensionIDs   []string       `yaml:"extension_ids"`   // Browser extensions allowed to log in: the host label of their redirect URI (empty = none)
 	Keycloak       KeycloakConfig `yaml:"keycloak"`
+	GitHub         GitHubConfig   `yaml:"github"`
+	GitLab         GitLabConfig   `yaml:"gitlab"`
+	OIDC           OIDCConfig     `yaml:"oidc"`
 }
 
 type KeycloakConfig struct {
@@ -82,9 +156,52 @@ type KeycloakConfig struct {
 	BaseURL string `yaml:"base_url"`
 }
 
+type GitHubConfig struct {
+	BaseURL string `yaml:"base_url"` // GitHub Enterprise Server (https://github.example.com); empty for github.com
+}
+
+type GitLabConfig struct {
+	BaseURL string `yaml:"base_url"` // Self-managed GitLab; empty for gitlab.com
+}
+
+// OIDCConfig is any OpenID Connect provider, found through the discovery
+// document of its issuer
+type OIDCConfig struct {
+	IssuerURL string `yaml:"issuer_url"`
+}
+
 type StorageConfig struct {
-	BasePath      string `yaml:"base_path"`
-	CreateMissing bool   `yaml:"create_missing"`
+	BasePath           string `yaml:"base_path"`
+	CreateMissing      bool   `yaml:"create_missing"`
+	TrashRetentionDays int    `yaml:"trash_retention_days"`  // 0 = keep trashed clips until emptied manually
+	JanitorMinAgeHours int    `yaml:"janitor_min_age_hours"` // Leftovers of failed captures older than this are removed (-1 = never)
+	DedupeMedia        bool   `yaml:"dedupe_media"`          // Store identical media once, hard-linked into the clips
+	UsageCacheMinutes  int    `yaml:"usage_cache_minutes"`   // Disk usage is measured again after this (-1 = every time)
+
+	Encryption  EncryptionConfig  `yaml:"encryption"`
+	Compression CompressionConfig `yaml:"compression"`
+
+	// "folders" (a folder per clip in web-clips) or "obsidian" (a note per
+	// clip in ObsidianFolder, media in its ObsidianAttachments folder)
+	Layout              string `yaml:"layout"`
+	ObsidianFolder      string `yaml:"obsidian_folder"`      // Relative to the clip directory
+	ObsidianAttachments string `yaml:"obsidian_attachments"` // Relative to the notes folder
+}
+
+// EncryptionConfig encrypts clip files at rest with an age X25519 key
+// (AGE-SECRET-KEY-1...). The key decrypts files whenever it is set; Enabled
+// decides whether new files are encrypted.
+type EncryptionConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Key     string `yaml:"key"`      // Typically "${CLIPPER_ENCRYPTION_KEY}", e.g. fetched from a KMS at startup
+	KeyFile string `yaml:"key_file"` // Read when key is empty, as written by age-keygen
+}
+
+// CompressionConfig gzips large pages and notes (.md and .html files) when
+// they are written. Compressed files are read whatever Enabled is.
+type CompressionConfig struct {
+	Enabled  bool `yaml:"enabled"`
+	MinBytes int  `yaml:"min_bytes"` // Smaller files are stored as they are
 }
 
 type ImagesConfig struct {
@@ -92,6 +209,212 @@ type ImagesConfig struct {
 	MaxDimensionPx   int   `yaml:"max_dimension_px"`
 	MaxTotalBytes    int64 `yaml:"max_total_bytes"`
 	PreserveOriginal bool  `yaml:"preserve_original"`
+	ConvertToWebP    bool  `yaml:"convert_to_webp"` // Store PNG and JPEG images as WebP
+	WebPQuality      int   `yaml:"webp_quality"`    // 0-100
+	// Formats accepted in clips, by content (see imaging.Sniff)
+	AllowedFormats []string `yaml:"allowed_formats"`
+	// Images sent with only their originalUrl are downloaded by the server
+	FetchRemote         bool `yaml:"fetch_remote"`
+	FetchTimeoutSeconds int  `yaml:"fetch_timeout_seconds"` // Per image
+	FetchAllowPrivate   bool `yaml:"fetch_allow_private"`   // Allow internal addresses (development only)
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"` // Must be on the Kindle "approved senders" list
+}
+
+type KindleConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+type OCRConfig struct {
+	Engine         string `yaml:"engine"`         // "", "tesseract" or "http" (empty = disabled)
+	TesseractPath  string `yaml:"tesseract_path"` // Defaults to "tesseract" from PATH
+	Languages      string `yaml:"languages"`      // Tesseract language codes, e.g. "eng+fra"
+	HTTPURL        string `yaml:"http_url"`
+	HTTPToken      string `yaml:"http_token"`
+	TimeoutSeconds int    `yaml:"timeout_seconds"`
+}
+
+type PDFConfig struct {
+	PdftotextPath  string `yaml:"pdftotext_path"` // Defaults to "pdftotext" (poppler-utils) from PATH
+	TimeoutSeconds int    `yaml:"timeout_seconds"`
+}
+
+type ClipsConfig struct {
+	DuplicateWindowSeconds int  `yaml:"duplicate_window_seconds"` // Re-clips within this window are duplicates (-1 = disabled)
+	FetchTimeoutSeconds    int  `yaml:"fetch_timeout_seconds"`    // Server-side page fetches (POST /api/v1/clips/fetch)
+	RawHTML                bool `yaml:"raw_html"`                 // Save fullpage HTML as captured, scripts and trackers included
+	Sidecar                bool `yaml:"sidecar"`                  // Write a clip.json with the clip's metadata and file hashes in clip folders
+
+	// Go template of the frontmatter (the YAML between the --- lines), for
+	// users without their own; empty for the built-in layout
+	FrontmatterTemplate string `yaml:"frontmatter_template"`
+
+	// Go templates naming new clip folders (relative to web-clips, slashes
+	// for subfolders) and page files, for users without their own; empty
+	// for YYYYMMDD_HHMMSS_site-slug_<ID> and the title slug
+	FolderTemplate string `yaml:"folder_template"`
+	FileTemplate   string `yaml:"file_template"`
+}
+
+// TagsConfig restricts the tags clips can carry on team instances
+type TagsConfig struct {
+	// Controlled vocabulary: "" (any tag), "reject" (clips with tags outside
+	// the admin-defined taxonomy are refused) or "drop" (such tags are left out)
+	Taxonomy string `yaml:"taxonomy"`
+}
+
+type WebhooksConfig struct {
+	TimeoutSeconds   int  `yaml:"timeout_seconds"`    // Per delivery attempt
+	SecretGraceHours int  `yaml:"secret_grace_hours"` // How long a rotated-out secret still signs deliveries
+	MaxAttempts      int  `yaml:"max_attempts"`       // Deliveries per event, failed ones retried by the webhook_retry task (1 disables retries)
+	AllowPrivate     bool `yaml:"allow_private"`      // Allow internal addresses (development only)
+	RetentionDays    int  `yaml:"retention_days"`     // Dispatched events and finished deliveries are deleted by the outbox_prune task after this
+}
+
+type UploadsConfig struct {
+	MaxChunkBytes   int64 `yaml:"max_chunk_bytes"`   // Per chunk; keep below proxy body limits
+	MaxBytes        int64 `yaml:"max_bytes"`         // Per assembled upload
+	SessionTTLHours int   `yaml:"session_ttl_hours"` // Unfinished uploads are deleted after this
+}
+
+type JobsConfig struct {
+	Workers        int `yaml:"workers"`         // Jobs run concurrently
+	TimeoutSeconds int `yaml:"timeout_seconds"` // Per attempt
+	MaxAttempts    int `yaml:"max_attempts"`    // Before a job is marked failed
+}
+
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"` // Serve /metrics in the Prometheus text format
+	Token   string `yaml:"token"`   // Bearer token scrapers must send (optional)
+}
+
+type MirrorConfig struct {
+	Enabled         bool     `yaml:"enabled"`          // Publish public collections as a static site
+	Directory       string   `yaml:"directory"`        // Local directory to publish to (optional)
+	S3              S3Config `yaml:"s3"`               // S3 website bucket to publish to (optional)
+	IntervalMinutes int      `yaml:"interval_minutes"` // How often the mirror is republished
+	Title           string   `yaml:"title"`            // Title of the mirror's index page
+	BaseURL         string   `yaml:"base_url"`         // Public URL the mirror is served at, for absolute links in previews
+	SocialCards     bool     `yaml:"social_cards"`     // Add a preview image (card.png) and OpenGraph tags to clip pages
+}
+
+// ReplicationConfig copies clip files to warm standbys as they are written
+type ReplicationConfig struct {
+	Enabled         bool            `yaml:"enabled"`
+	Directory       string          `yaml:"directory"`        // Secondary path, typically on another disk (optional)
+	S3              S3Config        `yaml:"s3"`               // Bucket to copy the files to (optional)
+	Targets         []ReplicaConfig `yaml:"targets"`          // More standbys, each a directory or a bucket
+	IntervalSeconds int             `yaml:"interval_seconds"` // How often new and changed clips are copied
+}
+
+// ReplicaConfig is a standby of replication.targets: a directory or a bucket
+type ReplicaConfig struct {
+	Directory string   `yaml:"directory"`
+	S3        S3Config `yaml:"s3"`
+}
+
+// GitSyncConfig makes clip directories git repositories, committing each
+// new or changed clip
+type GitSyncConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Remote      string `yaml:"remote"`       // Where storage.base_path's repository is pushed after each pass (optional)
+	Branch      string `yaml:"branch"`       // Default: main
+	AuthorName  string `yaml:"author_name"`  // Of the commits
+	AuthorEmail string `yaml:"author_email"` // Of the commits
+	GitPath     string `yaml:"git_path"`     // Binary path (default: "git" from PATH)
+}
+
+// CloudConfig lets users connect a Dropbox or Google Drive folder their new
+// clips are uploaded to, with the OAuth app of each provider
+type CloudConfig struct {
+	Dropbox        CloudAppConfig `yaml:"dropbox"`
+	GoogleDrive    CloudAppConfig `yaml:"google_drive"`
+	Folder         string         `yaml:"folder"`          // Folder clips are uploaded to unless the user picks one
+	TimeoutSeconds int            `yaml:"timeout_seconds"` // Of each API request
+}
+
+// CloudAppConfig is the OAuth app of a cloud storage provider, offered to
+// users when ClientID is set
+type CloudAppConfig struct {
+	ClientID     string `yaml:"client_id"`     // Dropbox: the app key
+	ClientSecret string `yaml:"client_secret"` // Dropbox: the app secret
+	BaseURL      string `yaml:"base_url"`      // Serves the OAuth and API endpoints instead of the provider (tests, proxies)
+}
+
+// SchedulerConfig sets when the in-process maintenance tasks run: cron
+// expressions ("0 3 * * *"), @hourly/@daily/@weekly/@monthly or
+// "@every 10m" by task name; "off" disables a task
+type SchedulerConfig struct {
+	Tasks map[string]string `yaml:"tasks"`
+}
+
+// DefaultSchedule is when each maintenance task runs unless configured
+var DefaultSchedule = map[string]string{
+	"trash_purge":           "@hourly",
+	"retention":             "@hourly",
+	"upload_purge":          "@hourly",
+	"janitor":               "@hourly",
+	"token_cleanup":         "@daily",
+	"usage_refresh":         "30 3 * * *",
+	"webhook_retry":         "*/10 * * * *",
+	"outbox_prune":          "@daily",
+	"git_sync":              "*/5 * * * *",
+	"replication_reconcile": "0 4 * * *",
+}
+
+// PublicConfig configures the routes anyone can read (share links, public
+// collection feeds), which may be served on their own domain when the API
+// is private
+type PublicConfig struct {
+	BaseURL            string `yaml:"base_url"`             // URL share and feed links are built on (default server.base_url)
+	Listen             string `yaml:"listen"`               // Separate address serving only the public routes, e.g. ":3001" (optional)
+	AutoDisableReports int    `yaml:"auto_disable_reports"` // Share links with this many open abuse reports are disabled pending review (0 = never)
+	// Passphrase attempts a minute on each share link, and from each client
+	// address, whether rate_limit is enabled or not
+	PassphraseAttemptsPerMinute int `yaml:"passphrase_attempts_per_minute"`
+	ReportsPerMinute            int `yaml:"reports_per_minute"` // Abuse reports from each client address, likewise
+}
+
+type S3Config struct {
+	Bucket          string `yaml:"bucket"`
+	Region          string `yaml:"region"`
+	Endpoint        string `yaml:"endpoint"` // For S3-compatible services; defaults to AWS
+	Prefix          string `yaml:"prefix"`   // Key prefix the objects are written under
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+}
+
+// MarkdownConfig configures the rendering of clips to HTML (exports, mirror)
+type MarkdownConfig struct {
+	Renderer string    `yaml:"renderer"` // Registered renderer (default gfm)
+	Disable  []string  `yaml:"disable"`  // Extensions turned off: tables, footnotes, task_lists, math, highlight
+	TOC      TOCConfig `yaml:"toc"`
+}
+
+// TOCConfig adds a table of contents to long clips, when saved and rendered
+type TOCConfig struct {
+	Enabled     bool `yaml:"enabled"`      // Default when the clip request doesn't say
+	MinHeadings int  `yaml:"min_headings"` // Clips with fewer headings get none
+	MaxDepth    int  `yaml:"max_depth"`    // Heading levels listed
+}
+
+// FaviconsConfig fetches the icon of clipped sites into a per-domain cache
+type FaviconsConfig struct {
+	Enabled        bool  `yaml:"enabled"`
+	TimeoutSeconds int   `yaml:"timeout_seconds"` // Per fetch
+	MaxBytes       int64 `yaml:"max_bytes"`
+	MaxAgeDays     int   `yaml:"max_age_days"` // Cached icons are fetched again after this
+}
+
+type HealthConfig struct {
+	ConsistencySample int `yaml:"consistency_sample"` // Recent clips whose folders /health/ready checks (-1 = no check)
+	MaxDriftPercent   int `yaml:"max_drift_percent"`  // Drifted share of the sample above which the instance is unready
 }
 
 type JWTConfig struct {
@@ -143,24 +466,15 @@ func Load(path string) (*Config, error) {
 		}
 	}
 
-	// Apply defaults
-	if cfg.Images.MaxSizeBytes == 0 {
-		cfg.Images.MaxSizeBytes = 5 * 1024 * 1024 // 5MB
-	}
-	if cfg.Images.MaxDimensionPx == 0 {
-		cfg.Images.MaxDimensionPx = 2048
-	}
-	if cfg.Images.MaxTotalBytes == 0 {
-		cfg.Images.MaxTotalBytes = 25 * 1024 * 1024 // 25MB
-	}
-	if cfg.JWT.ExpiryHours == 0 {
-		cfg.JWT.ExpiryHours = 24
-	}
+	applyDefaults(&cfg)
 
 	// Override dev mode from environment variable (handles string "true"/"false")
 	if devMode := os.Getenv("DEV_MODE"); devMode != "" {
 		cfg.DevMode.Enabled = strings.ToLower(devMode) == "true" || devMode == "1"
 	}
+	if chaos := os.Getenv("DEV_CHAOS"); chaos != "" {
+		cfg.DevMode.Chaos.Enabled = strings.ToLower(chaos) == "true" || chaos == "1"
+	}
 
 	// Dev mode defaults
 	if cfg.DevMode.Enabled {
@@ -177,7 +491,204 @@ func Load(path string) (*Config, error) {
 		if cfg.JWT.Secret == "" {
 			cfg.JWT.Secret = "dev-secret-change-in-production"
 		}
+		// Chaos without rates (DEV_CHAOS=true alone) fails a few writes
+		chaos := &cfg.DevMode.Chaos
+		if chaos.Enabled && chaos.ErrorRate == 0 && chaos.NoSpaceRate == 0 && chaos.WriteDelayMs == 0 {
+			chaos.ErrorRate = 0.05
+			chaos.NoSpaceRate = 0.02
+		}
 	}
 
 	return &cfg, nil
 }
+
+// Default returns a config with only the built-in defaults applied, for tests
+// and tools that run without a config file.
+func Default() *Config {
+	cfg := &Config{}
+	applyDefaults(cfg)
+	return cfg
+}
+
+// applyDefaults fills in unset limits and timeouts
+func applyDefaults(cfg *Config) {
+	if cfg.Logging.Format == "" {
+		cfg.Logging.Format = "json"
+	}
+	if cfg.Logging.Level == "" {
+		cfg.Logging.Level = "info"
+	}
+	if cfg.Logging.ProxyHops == 0 {
+		cfg.Logging.ProxyHops = 1
+	}
+	if cfg.RateLimit.Store == "" {
+		cfg.RateLimit.Store = "memory"
+	}
+	if cfg.RateLimit.PerIP.RequestsPerMinute == 0 {
+		cfg.RateLimit.PerIP.RequestsPerMinute = 600
+	}
+	if cfg.RateLimit.PerUser.RequestsPerMinute == 0 {
+		cfg.RateLimit.PerUser.RequestsPerMinute = 300
+	}
+	if cfg.Images.MaxSizeBytes == 0 {
+		cfg.Images.MaxSizeBytes = 5 * 1024 * 1024 // 5MB
+	}
+	if cfg.Images.MaxDimensionPx == 0 {
+		cfg.Images.MaxDimensionPx = 2048
+	}
+	if cfg.Images.MaxTotalBytes == 0 {
+		cfg.Images.MaxTotalBytes = 25 * 1024 * 1024 // 25MB
+	}
+	if cfg.Server.MaxBodyBytes == 0 {
+		cfg.Server.MaxBodyBytes = 1 << 20 // 1MB
+	}
+	if cfg.Server.MaxClipBodyBytes == 0 {
+		// The images in base64, with room for the page's HTML
+		cfg.Server.MaxClipBodyBytes = cfg.Images.MaxTotalBytes/3*4 + 32<<20
+	}
+	if cfg.Images.WebPQuality == 0 {
+		cfg.Images.WebPQuality = 80
+	}
+	if cfg.Favicons.TimeoutSeconds == 0 {
+		cfg.Favicons.TimeoutSeconds = 5
+	}
+	if cfg.Favicons.MaxBytes == 0 {
+		cfg.Favicons.MaxBytes = 256 * 1024
+	}
+	if cfg.Favicons.MaxAgeDays == 0 {
+		cfg.Favicons.MaxAgeDays = 30
+	}
+	if cfg.Markdown.TOC.MinHeadings == 0 {
+		cfg.Markdown.TOC.MinHeadings = 3
+	}
+	if cfg.Markdown.TOC.MaxDepth == 0 {
+		cfg.Markdown.TOC.MaxDepth = 3
+	}
+	if cfg.Images.FetchTimeoutSeconds == 0 {
+		cfg.Images.FetchTimeoutSeconds = 10
+	}
+	if len(cfg.Images.AllowedFormats) == 0 {
+		cfg.Images.AllowedFormats = []string{"png", "jpeg", "gif", "webp", "avif", "bmp", "ico", "pdf"}
+	}
+	if cfg.JWT.ExpiryHours == 0 {
+		cfg.JWT.ExpiryHours = 24
+	}
+	if cfg.SMTP.Port == 0 {
+		cfg.SMTP.Port = 587
+	}
+	if cfg.OCR.TimeoutSeconds == 0 {
+		cfg.OCR.TimeoutSeconds = 60
+	}
+	if cfg.PDF.TimeoutSeconds == 0 {
+		cfg.PDF.TimeoutSeconds = 30
+	}
+	if cfg.Storage.JanitorMinAgeHours == 0 {
+		cfg.Storage.JanitorMinAgeHours = 24
+	}
+	if cfg.Storage.UsageCacheMinutes == 0 {
+		cfg.Storage.UsageCacheMinutes = 60
+	}
+	if cfg.Storage.Compression.MinBytes <= 0 {
+		cfg.Storage.Compression.MinBytes = 64 << 10
+	}
+	if cfg.Storage.Layout == "" {
+		cfg.Storage.Layout = "folders"
+	}
+	if cfg.Storage.ObsidianFolder == "" {
+		cfg.Storage.ObsidianFolder = "Clips"
+	}
+	if cfg.Storage.ObsidianAttachments == "" {
+		cfg.Storage.ObsidianAttachments = "attachments"
+	}
+	if cfg.Clips.DuplicateWindowSeconds == 0 {
+		cfg.Clips.DuplicateWindowSeconds = 120
+	}
+	if cfg.Clips.FetchTimeoutSeconds == 0 {
+		cfg.Clips.FetchTimeoutSeconds = 30
+	}
+	if cfg.Public.PassphraseAttemptsPerMinute == 0 {
+		cfg.Public.PassphraseAttemptsPerMinute = 5
+	}
+	if cfg.Public.ReportsPerMinute == 0 {
+		cfg.Public.ReportsPerMinute = 2
+	}
+	if cfg.Webhooks.TimeoutSeconds == 0 {
+		cfg.Webhooks.TimeoutSeconds = 10
+	}
+	if cfg.Webhooks.SecretGraceHours == 0 {
+		cfg.Webhooks.SecretGraceHours = 24
+	}
+	if cfg.Webhooks.MaxAttempts == 0 {
+		cfg.Webhooks.MaxAttempts = 3
+	}
+	if cfg.Webhooks.RetentionDays == 0 {
+		cfg.Webhooks.RetentionDays = 30
+	}
+	if cfg.Scheduler.Tasks == nil {
+		cfg.Scheduler.Tasks = map[string]string{}
+	}
+	for name, spec := range DefaultSchedule {
+		if _, ok := cfg.Scheduler.Tasks[name]; !ok {
+			cfg.Scheduler.Tasks[name] = spec
+		}
+	}
+	if cfg.Uploads.MaxChunkBytes == 0 {
+		cfg.Uploads.MaxChunkBytes = 4 * 1024 * 1024 // 4MB
+	}
+	if cfg.Uploads.MaxBytes == 0 {
+		cfg.Uploads.MaxBytes = 256 * 1024 * 1024 // 256MB
+	}
+	if cfg.Uploads.SessionTTLHours == 0 {
+		cfg.Uploads.SessionTTLHours = 24
+	}
+	if cfg.Health.ConsistencySample == 0 {
+		cfg.Health.ConsistencySample = 20
+	}
+	if cfg.Health.MaxDriftPercent == 0 {
+		cfg.Health.MaxDriftPercent = 50
+	}
+	if cfg.Mirror.IntervalMinutes == 0 {
+		cfg.Mirror.IntervalMinutes = 60
+	}
+	if cfg.Mirror.Title == "" {
+		cfg.Mirror.Title = "Reading list"
+	}
+	if cfg.Mirror.S3.Region == "" {
+		cfg.Mirror.S3.Region = "us-east-1"
+	}
+	if cfg.Replication.IntervalSeconds == 0 {
+		cfg.Replication.IntervalSeconds = 60
+	}
+	if cfg.Cloud.Folder == "" {
+		cfg.Cloud.Folder = "Web Clipper"
+	}
+	if cfg.Cloud.TimeoutSeconds == 0 {
+		cfg.Cloud.TimeoutSeconds = 60
+	}
+	if cfg.GitSync.Branch == "" {
+		cfg.GitSync.Branch = "main"
+	}
+	if cfg.GitSync.AuthorName == "" {
+		cfg.GitSync.AuthorName = "Web Clipper"
+	}
+	if cfg.GitSync.AuthorEmail == "" {
+		cfg.GitSync.AuthorEmail = "web-clipper@localhost"
+	}
+	if cfg.Replication.S3.Region == "" {
+		cfg.Replication.S3.Region = "us-east-1"
+	}
+	for i := range cfg.Replication.Targets {
+		if cfg.Replication.Targets[i].S3.Region == "" {
+			cfg.Replication.Targets[i].S3.Region = "us-east-1"
+		}
+	}
+	if cfg.Jobs.Workers == 0 {
+		cfg.Jobs.Workers = 2
+	}
+	if cfg.Jobs.TimeoutSeconds == 0 {
+		cfg.Jobs.TimeoutSeconds = 300
+	}
+	if cfg.Jobs.MaxAttempts == 0 {
+		cfg.Jobs.MaxAttempts = 3
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -116,4 +117,108 @@ jwt:
 	if cfg.JWT.ExpiryHours != 24 {
 		t.Errorf("expected default ExpiryHours 24, got %d", cfg.JWT.ExpiryHours)
 	}
+
+	if cfg.SMTP.Port != 587 {
+		t.Errorf("expected default SMTP port 587, got %d", cfg.SMTP.Port)
+	}
+
+	if cfg.OCR.TimeoutSeconds != 60 {
+		t.Errorf("expected default OCR timeout 60, got %d", cfg.OCR.TimeoutSeconds)
+	}
+	if cfg.PDF.TimeoutSeconds != 30 {
+		t.Errorf("expected default PDF timeout 30, got %d", cfg.PDF.TimeoutSeconds)
+	}
+	if cfg.Clips.DuplicateWindowSeconds != 120 {
+		t.Errorf("expected default duplicate window 120, got %d", cfg.Clips.DuplicateWindowSeconds)
+	}
+	if cfg.Clips.FetchTimeoutSeconds != 30 {
+		t.Errorf("expected default fetch timeout 30, got %d", cfg.Clips.FetchTimeoutSeconds)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cfg := Default()
+	cfg.JWT.Secret = "0123456789abcdef0123456789abcdef"
+	cfg.Storage.BasePath = t.TempDir()
+	cfg.OAuth = OAuthConfig{Provider: "google", ClientID: "id", ClientSecret: "secret", RedirectURL: "https://clips.example.com/auth/callback",
+		ExtensionIDs: []string{"abcdefghijklmnopabcdefghijklmnop"}}
+	if problems := cfg.Check(); len(problems) != 0 {
+		t.Fatalf("valid config: %v", problems)
+	}
+
+	cfg.JWT.Secret = DevJWTSecret
+	cfg.OAuth = OAuthConfig{Provider: "keycloak", ClientID: "id"}
+	cfg.Storage.BasePath = filepath.Join(t.TempDir(), "missing")
+	cfg.Images.MaxTotalBytes = cfg.Images.MaxSizeBytes - 1
+	cfg.Images.AllowedFormats = []string{"png", "tiff"}
+	fields := map[string]string{}
+	for _, p := range cfg.Check() {
+		fields[p.Field] = p.Severity
+	}
+	for _, field := range []string{"jwt.secret", "oauth.keycloak.base_url", "oauth.keycloak.realm", "oauth.client_secret",
+		"oauth.redirect_url", "storage.base_path", "images.max_total_bytes", "images.allowed_formats"} {
+		if fields[field] != SeverityError {
+			t.Errorf("%s: expected an error, got %v", field, fields)
+		}
+	}
+	if fields["oauth.extension_ids"] != SeverityWarning {
+		t.Errorf("oauth.extension_ids: expected a warning, got %v", fields)
+	}
+
+	// Dev mode only warns about its secret
+	cfg.DevMode.Enabled = true
+	for _, p := range cfg.Check() {
+		if p.Field == "jwt.secret" && p.Severity != SeverityWarning {
+			t.Errorf("dev mode: %v", p)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	cfg := Default()
+	cfg.JWT.Secret = "jwt-secret"
+	cfg.SMTP.Password = "smtp-password"
+	cfg.RateLimit.RedisURL = "redis://:redis-password@cache:6379/0"
+	cfg.GitSync.Remote = "https://gh-token@github.com/me/clips.git"
+	out, err := Redact(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"jwt-secret", "smtp-password", "redis-password", "gh-token"} {
+		if strings.Contains(string(out), secret) {
+			t.Errorf("%s not redacted", secret)
+		}
+	}
+	if !strings.Contains(string(out), "cache:6379") || !strings.Contains(string(out), "  secret: '[redacted]'") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+func TestEnvRefs(t *testing.T) {
+	names, defaults := EnvRefs("a: ${A}\nb: ${B:-x}\nc: $C\nd: ${A:-y}")
+	if strings.Join(names, ",") != "A,B,C" || !defaults["A"] || !defaults["B"] || defaults["C"] {
+		t.Errorf("unexpected refs: %v %v", names, defaults)
+	}
+}
+
+func TestCheckOAuthProviders(t *testing.T) {
+	cfg := Default()
+	cfg.OAuth = OAuthConfig{ClientID: "id", ClientSecret: "secret", RedirectURL: "https://clips.example.com/auth/callback",
+		ExtensionIDs: []string{"abcdefghijklmnopabcdefghijklmnop"}}
+	for provider, want := range map[string]string{"github": "", "gitlab": "", "oidc": "oauth.oidc.issuer_url", "okta": "oauth.provider"} {
+		cfg.OAuth.Provider = provider
+		var got string
+		for _, p := range cfg.checkOAuth() {
+			got = p.Field
+		}
+		if got != want {
+			t.Errorf("%s: got a problem with %q, want %q", provider, got, want)
+		}
+	}
+
+	cfg.OAuth.Provider = "gitlab"
+	cfg.OAuth.GitLab.BaseURL = "gitlab.example.com"
+	if problems := cfg.checkOAuth(); len(problems) != 1 || problems[0].Field != "oauth.gitlab.base_url" {
+		t.Errorf("relative base URL: %v", problems)
+	}
 }
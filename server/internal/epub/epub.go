@@ -0,0 +1,193 @@
+// Package epub builds minimal EPUB 3 documents from clip content.
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Resource is a binary file (typically an image) embedded in the book.
+type Resource struct {
+	Filename string // Path relative to the chapter, e.g. "media/hero.png"
+	Data     []byte
+}
+
+// Book describes a single-chapter EPUB document.
+type Book struct {
+	Identifier string
+	Title      string
+	Author     string
+	Language   string
+	Source     string // Original URL
+	BodyHTML   string // HTML fragment for the chapter body
+	Resources  []Resource
+}
+
+// Build renders the book as an EPUB archive.
+func Build(b Book) ([]byte, error) {
+	if b.Title == "" {
+		b.Title = "Untitled"
+	}
+	if b.Language == "" {
+		b.Language = "en"
+	}
+	if b.Identifier == "" {
+		return nil, fmt.Errorf("epub identifier is required")
+	}
+
+	body, err := toXHTML(b.BodyHTML)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert body to XHTML: %w", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	// The mimetype entry must be first and stored uncompressed
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := mw.Write([]byte("application/epub+zip")); err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name    string
+		content []byte
+	}{
+		{"META-INF/container.xml", []byte(containerXML)},
+		{"OEBPS/content.opf", []byte(packageDocument(b))},
+		{"OEBPS/nav.xhtml", []byte(navDocument(b))},
+		{"OEBPS/chapter.xhtml", []byte(chapterDocument(b, body))},
+	}
+	for _, res := range b.Resources {
+		files = append(files, struct {
+			name    string
+			content []byte
+		}{path.Join("OEBPS", res.Filename), res.Data})
+	}
+
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(f.content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+// packageDocument generates the OPF manifest and spine
+func packageDocument(b Book) string {
+	var manifest strings.Builder
+	for i, res := range b.Resources {
+		mediaType := mime.TypeByExtension(path.Ext(res.Filename))
+		if mediaType == "" {
+			mediaType = "application/octet-stream"
+		}
+		manifest.WriteString(fmt.Sprintf("    <item id=\"res%d\" href=\"%s\" media-type=\"%s\"/>\n",
+			i, html.EscapeString(res.Filename), mediaType))
+	}
+
+	var source string
+	if b.Source != "" {
+		source = fmt.Sprintf("    <dc:source>%s</dc:source>\n", html.EscapeString(b.Source))
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="bookid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="bookid">urn:uuid:%s</dc:identifier>
+    <dc:title>%s</dc:title>
+    <dc:creator>%s</dc:creator>
+    <dc:language>%s</dc:language>
+%s    <meta property="dcterms:modified">%s</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="chapter" href="chapter.xhtml" media-type="application/xhtml+xml"/>
+%s  </manifest>
+  <spine>
+    <itemref idref="chapter"/>
+  </spine>
+</package>
+`,
+		html.EscapeString(b.Identifier),
+		html.EscapeString(b.Title),
+		html.EscapeString(b.Author),
+		html.EscapeString(b.Language),
+		source,
+		time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		manifest.String())
+}
+
+// navDocument generates the EPUB 3 navigation document
+func navDocument(b Book) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>%s</title></head>
+<body>
+  <nav epub:type="toc">
+    <ol><li><a href="chapter.xhtml">%s</a></li></ol>
+  </nav>
+</body>
+</html>
+`, html.EscapeString(b.Title), html.EscapeString(b.Title))
+}
+
+// chapterDocument wraps the XHTML body in a complete document
+func chapterDocument(b Book, body string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="%s">
+<head><title>%s</title></head>
+<body>
+<h1>%s</h1>
+%s
+</body>
+</html>
+`, html.EscapeString(b.Language), html.EscapeString(b.Title), html.EscapeString(b.Title), body)
+}
+
+// toXHTML re-serializes an HTML fragment so void elements are self-closed
+// and entities are normalized, as required by EPUB readers
+func toXHTML(fragment string) (string, error) {
+	ctx := &xhtml.Node{Type: xhtml.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := xhtml.ParseFragment(strings.NewReader(fragment), ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		if err := xhtml.Render(&buf, n); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,61 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	data, err := Build(Book{
+		Identifier: "550e8400-e29b-41d4-a716-446655440000",
+		Title:      "Test & Title",
+		Author:     "example.com",
+		BodyHTML:   "<p>Hello<br>world</p><img src=\"media/a.png\">",
+		Resources:  []Resource{{Filename: "media/a.png", Data: []byte("png")}},
+	})
+	if err != nil {
+		t.Fatalf("Build() failed: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("output is not a zip archive: %v", err)
+	}
+
+	if zr.File[0].Name != "mimetype" || zr.File[0].Method != zip.Store {
+		t.Errorf("expected uncompressed mimetype as first entry, got %s", zr.File[0].Name)
+	}
+
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+
+	for _, name := range []string{"META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml", "OEBPS/chapter.xhtml", "OEBPS/media/a.png"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("missing entry %s", name)
+		}
+	}
+
+	if !strings.Contains(files["OEBPS/content.opf"], "Test &amp; Title") {
+		t.Errorf("expected escaped title in package document")
+	}
+	if !strings.Contains(files["OEBPS/chapter.xhtml"], "<br/>") {
+		t.Errorf("expected self-closed void elements in chapter, got %s", files["OEBPS/chapter.xhtml"])
+	}
+}
+
+func TestBuildRequiresIdentifier(t *testing.T) {
+	if _, err := Build(Book{Title: "x"}); err == nil {
+		t.Error("expected error for missing identifier")
+	}
+}
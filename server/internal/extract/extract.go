@@ -0,0 +1,393 @@
+// Package extract fetches web pages and converts their main content to markdown.
+package extract
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DefaultMaxBytes caps the size of a fetched page.
+const DefaultMaxBytes = 5 * 1024 * 1024
+
+// userAgent identifies the fetcher to sites.
+const userAgent = "Mozilla/5.0 (compatible; WebClipper/1.0; +https://github.com/jpoutrin/web-clipper)"
+
+// Page is the extracted content of a web page.
+type Page struct {
+	URL      string // Final URL after redirects
+	Title    string
+	Markdown string
+	Meta     Metadata
+}
+
+// Fetcher downloads pages over HTTP.
+type Fetcher struct {
+	Client   *http.Client
+	MaxBytes int64
+}
+
+// NewFetcher returns a Fetcher using client, with the default size limit.
+// Pages submitted by users must be fetched with a safehttp client.
+func NewFetcher(client *http.Client) *Fetcher {
+	return &Fetcher{
+		Client:   client,
+		MaxBytes: DefaultMaxBytes,
+	}
+}
+
+// Fetch downloads an HTML page and extracts its main content.
+func (f *Fetcher) Fetch(ctx context.Context, pageURL string) (*Page, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid URL: %s", pageURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", pageURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: HTTP %d", pageURL, resp.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "" &&
+		mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("unsupported content type: %s", mediaType)
+	}
+
+	maxBytes := f.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", pageURL, err)
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, fmt.Errorf("page exceeds %d bytes", maxBytes)
+	}
+
+	page, err := Extract(string(body), resp.Request.URL)
+	if err != nil {
+		return nil, err
+	}
+	page.URL = resp.Request.URL.String()
+	return page, nil
+}
+
+// Extract parses an HTML document and converts its main content to markdown.
+// Relative links and images are resolved against base.
+func Extract(document string, base *url.URL) (*Page, error) {
+	doc, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	page := &Page{Title: findTitle(doc), Meta: findMetadata(doc, base)}
+	if base != nil {
+		page.URL = base.String()
+	}
+
+	content := findContent(doc)
+	if content == nil {
+		return page, nil
+	}
+
+	r := &renderer{base: base}
+	r.block(content)
+	page.Markdown = cleanMarkdown(r.sb.String())
+	return page, nil
+}
+
+// findTitle prefers og:title, then <title>, then the first <h1>
+func findTitle(doc *html.Node) string {
+	if meta := findFirst(doc, func(n *html.Node) bool {
+		return n.DataAtom == atom.Meta && (attr(n, "property") == "og:title" || attr(n, "name") == "twitter:title")
+	}); meta != nil {
+		if title := strings.TrimSpace(attr(meta, "content")); title != "" {
+			return title
+		}
+	}
+	for _, a := range []atom.Atom{atom.Title, atom.H1} {
+		if n := findFirst(doc, func(n *html.Node) bool { return n.DataAtom == a }); n != nil {
+			if title := strings.TrimSpace(collapseSpace(textContent(n))); title != "" {
+				return title
+			}
+		}
+	}
+	return ""
+}
+
+// findContent picks the element most likely to hold the article
+func findContent(doc *html.Node) *html.Node {
+	candidates := []func(*html.Node) bool{
+		func(n *html.Node) bool { return n.DataAtom == atom.Article },
+		func(n *html.Node) bool { return n.DataAtom == atom.Main },
+		func(n *html.Node) bool { return attr(n, "role") == "main" },
+		func(n *html.Node) bool { return n.DataAtom == atom.Body },
+	}
+	for _, match := range candidates {
+		if n := findFirst(doc, match); n != nil {
+			return n
+		}
+	}
+	return nil
+}
+
+// skipped are elements that never contribute to the article text
+var skipped = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Nav: true,
+	atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Form: true,
+	atom.Iframe: true, atom.Svg: true, atom.Button: true, atom.Template: true,
+	atom.Head: true, atom.Select: true, atom.Input: true, atom.Textarea: true,
+}
+
+// renderer converts a DOM subtree to markdown
+type renderer struct {
+	sb   strings.Builder
+	base *url.URL
+}
+
+// block renders n and its children as block-level markdown
+func (r *renderer) block(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.node(c)
+	}
+}
+
+func (r *renderer) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.sb.WriteString(collapseSpace(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	if skipped[n.DataAtom] || attr(n, "hidden") != "" || attr(n, "aria-hidden") == "true" {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		if text := strings.TrimSpace(r.inline(n)); text != "" {
+			r.sb.WriteString("\n\n" + strings.Repeat("#", level) + " " + text + "\n\n")
+		}
+	case atom.P, atom.Div, atom.Section, atom.Figure, atom.Table, atom.Dl:
+		r.sb.WriteString("\n\n")
+		r.block(n)
+		r.sb.WriteString("\n\n")
+	case atom.Tr, atom.Dt, atom.Dd, atom.Figcaption:
+		r.sb.WriteString("\n")
+		r.block(n)
+		r.sb.WriteString("\n")
+	case atom.Td, atom.Th:
+		r.block(n)
+		r.sb.WriteString(" ")
+	case atom.Br:
+		r.sb.WriteString("  \n")
+	case atom.Hr:
+		r.sb.WriteString("\n\n---\n\n")
+	case atom.Pre:
+		code := strings.Trim(textContent(n), "\n")
+		r.sb.WriteString("\n\n```\n" + code + "\n```\n\n")
+	case atom.Blockquote:
+		inner := &renderer{base: r.base}
+		inner.block(n)
+		quoted := cleanMarkdown(inner.sb.String())
+		if quoted != "" {
+			r.sb.WriteString("\n\n> " + strings.ReplaceAll(strings.TrimRight(quoted, "\n"), "\n", "\n> ") + "\n\n")
+		}
+	case atom.Ul, atom.Ol:
+		r.list(n, n.DataAtom == atom.Ol)
+	case atom.A, atom.Img, atom.Strong, atom.B, atom.Em, atom.I, atom.Code:
+		r.sb.WriteString(r.inlineElement(n))
+	default:
+		// Transparent containers (span, tbody, ...)
+		r.block(n)
+	}
+}
+
+// list renders ul/ol items, indenting nested lists
+func (r *renderer) list(n *html.Node, ordered bool) {
+	r.sb.WriteString("\n\n")
+	i := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+			continue
+		}
+		i++
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", i)
+		}
+
+		inner := &renderer{base: r.base}
+		inner.block(c)
+		item := cleanMarkdown(inner.sb.String())
+		lines := strings.Split(strings.TrimRight(item, "\n"), "\n")
+		for j, line := range lines {
+			if j == 0 {
+				lines[j] = marker + line
+			} else if strings.TrimSpace(line) != "" {
+				lines[j] = strings.Repeat(" ", len(marker)) + line
+			}
+		}
+		r.sb.WriteString(strings.Join(lines, "\n") + "\n")
+	}
+	r.sb.WriteString("\n")
+}
+
+// inline renders the children of n as inline markdown
+func (r *renderer) inline(n *html.Node) string {
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case html.TextNode:
+			sb.WriteString(collapseSpace(c.Data))
+		case html.ElementNode:
+			if !skipped[c.DataAtom] {
+				sb.WriteString(r.inlineElement(c))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// inlineElement renders a single inline element
+func (r *renderer) inlineElement(n *html.Node) string {
+	switch n.DataAtom {
+	case atom.A:
+		text := strings.TrimSpace(r.inline(n))
+		href := r.resolve(attr(n, "href"))
+		if text == "" || href == "" || strings.HasPrefix(href, "javascript:") {
+			return text
+		}
+		return fmt.Sprintf("[%s](%s)", text, href)
+	case atom.Img:
+		src := r.resolve(attr(n, "src"))
+		if src == "" || strings.HasPrefix(src, "data:") {
+			return ""
+		}
+		return fmt.Sprintf("![%s](%s)", attr(n, "alt"), src)
+	case atom.Strong, atom.B:
+		if text := strings.TrimSpace(r.inline(n)); text != "" {
+			return "**" + text + "**"
+		}
+		return ""
+	case atom.Em, atom.I:
+		if text := strings.TrimSpace(r.inline(n)); text != "" {
+			return "*" + text + "*"
+		}
+		return ""
+	case atom.Code:
+		if text := textContent(n); text != "" {
+			return "`" + text + "`"
+		}
+		return ""
+	case atom.Br:
+		return "  \n"
+	default:
+		// Block content nested in inline context (e.g. a div inside a link)
+		if isBlock(n) {
+			inner := &renderer{base: r.base}
+			inner.node(n)
+			return inner.sb.String()
+		}
+		return r.inline(n)
+	}
+}
+
+// resolve turns a possibly relative reference into an absolute URL
+func (r *renderer) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || r.base == nil {
+		return ref
+	}
+	u, err := r.base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// isBlock reports whether n renders as a markdown block
+func isBlock(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.P, atom.Div, atom.Section, atom.Ul, atom.Ol, atom.Pre, atom.Blockquote,
+		atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Table, atom.Hr, atom.Figure:
+		return true
+	}
+	return false
+}
+
+var (
+	spaceRe     = regexp.MustCompile(`[ \t\r\n\f]+`)
+	blankLineRe = regexp.MustCompile(`\n[ \t]*\n(?:[ \t]*\n)+`)
+	lineSpaceRe = regexp.MustCompile(`(?m)^ (\S)`) // Left over from collapsed whitespace; deeper indents are list nesting
+)
+
+// collapseSpace folds runs of whitespace into a single space
+func collapseSpace(s string) string {
+	return spaceRe.ReplaceAllString(s, " ")
+}
+
+// cleanMarkdown trims stray leading spaces and collapses blank lines
+func cleanMarkdown(md string) string {
+	md = lineSpaceRe.ReplaceAllString(md, "$1")
+	md = blankLineRe.ReplaceAllString(md, "\n\n")
+	return strings.TrimSpace(md) + "\n"
+}
+
+// textContent returns the concatenated text of n's descendants
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textContent(c))
+	}
+	return sb.String()
+}
+
+// findFirst returns the first node in document order matching fn
+func findFirst(n *html.Node, fn func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && fn(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findFirst(c, fn); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// attr returns the value of an attribute, or ""
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
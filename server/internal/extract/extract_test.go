@@ -0,0 +1,160 @@
+package extract
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const samplePage = `<!DOCTYPE html>
+<html><head>
+<title>Fallback title</title>
+<meta property="og:title" content="Sample Article">
+<script>var x = 1;</script>
+</head>
+<body>
+<nav><a href="/">Home</a></nav>
+<article>
+  <h1>Sample   Article</h1>
+  <p>First <strong>bold</strong> and <em>italic</em> with a <a href="/docs">link</a>.</p>
+  <ul><li>one</li><li>two <code>x</code></li></ul>
+  <blockquote><p>Quoted text</p></blockquote>
+  <pre>func main() {}
+</pre>
+  <img src="img/a.png" alt="Diagram">
+</article>
+<footer>Copyright</footer>
+</body></html>`
+
+func TestExtract(t *testing.T) {
+	base, _ := url.Parse("https://example.com/posts/1")
+	page, err := Extract(samplePage, base)
+	if err != nil {
+		t.Fatalf("Extract() failed: %v", err)
+	}
+
+	if page.Title != "Sample Article" {
+		t.Errorf("Title = %q, want %q", page.Title, "Sample Article")
+	}
+
+	expected := "# Sample Article\n\n" +
+		"First **bold** and *italic* with a [link](https://example.com/docs).\n\n" +
+		"- one\n- two `x`\n\n" +
+		"> Quoted text\n\n" +
+		"```\nfunc main() {}\n```\n\n" +
+		"![Diagram](https://example.com/posts/img/a.png)\n"
+	if page.Markdown != expected {
+		t.Errorf("Markdown =\n%s\nwant:\n%s", page.Markdown, expected)
+	}
+
+	for _, unwanted := range []string{"Home", "Copyright", "var x"} {
+		if strings.Contains(page.Markdown, unwanted) {
+			t.Errorf("Markdown contains %q", unwanted)
+		}
+	}
+}
+
+func TestExtractTitleFallback(t *testing.T) {
+	page, err := Extract("<html><head><title> Plain  title </title></head><body><p>Hi</p></body></html>", nil)
+	if err != nil {
+		t.Fatalf("Extract() failed: %v", err)
+	}
+	if page.Title != "Plain title" {
+		t.Errorf("Title = %q, want %q", page.Title, "Plain title")
+	}
+	if page.Markdown != "Hi\n" {
+		t.Errorf("Markdown = %q, want %q", page.Markdown, "Hi\n")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(samplePage))
+		case "/file.zip":
+			w.Header().Set("Content-Type", "application/zip")
+			w.Write([]byte("PK"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	f := NewFetcher(&http.Client{Timeout: 5 * time.Second})
+	page, err := f.Fetch(context.Background(), srv.URL+"/page")
+	if err != nil {
+		t.Fatalf("Fetch() failed: %v", err)
+	}
+	if page.Title != "Sample Article" || page.URL != srv.URL+"/page" {
+		t.Errorf("unexpected page: %+v", page)
+	}
+
+	if _, err := f.Fetch(context.Background(), srv.URL+"/file.zip"); err == nil {
+		t.Error("expected error for non-HTML content")
+	}
+	if _, err := f.Fetch(context.Background(), srv.URL+"/missing"); err == nil {
+		t.Error("expected error for 404")
+	}
+	if _, err := f.Fetch(context.Background(), "ftp://example.com"); err == nil {
+		t.Error("expected error for non-HTTP URL")
+	}
+
+	f.MaxBytes = 10
+	if _, err := f.Fetch(context.Background(), srv.URL+"/page"); err == nil {
+		t.Error("expected error for oversized page")
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	base, _ := url.Parse("https://example.com/posts/1")
+	meta := ParseMetadata(`<html><head>
+<meta property="og:title" content="OG Title">
+<meta name="description" content="Plain description">
+<meta property="og:description" content="  OpenGraph
+  description ">
+<meta property="og:image" content="/img/card.png">
+<meta property="og:site_name" content="Example">
+<meta property="article:author" content="https://example.com/authors/ada">
+<script type="application/ld+json">{"@graph": [{"@type": "WebSite"},
+  {"@type": "Article", "author": [{"@type": "Person", "name": "Ada Lovelace"}], "datePublished": "2024-03-01T09:30:00+01:00"}]}</script>
+</head><body></body></html>`, base)
+
+	want := Metadata{
+		Title:       "OG Title",
+		Description: "OpenGraph description",
+		Author:      "Ada Lovelace",
+		Published:   "2024-03-01T09:30:00+01:00",
+		SiteName:    "Example",
+		Image:       "https://example.com/img/card.png",
+	}
+	if meta != want {
+		t.Errorf("ParseMetadata() = %+v, want %+v", meta, want)
+	}
+
+	meta = ParseMetadata(`<meta name="author" content="Grace"><meta name="date" content="2023-12-24">`, nil)
+	if meta.Author != "Grace" || meta.Published != "2023-12-24T00:00:00Z" {
+		t.Errorf("ParseMetadata() = %+v", meta)
+	}
+	if meta := ParseMetadata(`<meta name="date" content="last week">`, nil); !meta.IsZero() {
+		t.Errorf("expected an unparseable date to be dropped, got %+v", meta)
+	}
+
+	// The lang attribute wins over meta tags
+	for doc, want := range map[string]string{
+		`<html lang="fr-FR"><head><meta property="og:locale" content="en_US"></head></html>`: "fr",
+		`<meta property="og:locale" content="pt_BR">`:                                        "pt",
+		`<meta http-equiv="Content-Language" content="de">`:                                  "de",
+		`<script type="application/ld+json">{"inLanguage": "es"}</script>`:                   "es",
+		`<html lang="not a language"></html>`:                                                "",
+	} {
+		if got := ParseMetadata(doc, nil).Language; got != want {
+			t.Errorf("ParseMetadata(%s).Language = %q, want %q", doc, got, want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package extract
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"server/internal/lang"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Metadata is what a page declares about itself in its head: OpenGraph and
+// Twitter cards, standard meta tags and JSON-LD.
+type Metadata struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Published   string `json:"published,omitempty"` // RFC 3339
+	SiteName    string `json:"site_name,omitempty"`
+	Image       string `json:"image,omitempty"`    // Absolute URL
+	Type        string `json:"type,omitempty"`     // og:type (article, website, ...)
+	Language    string `json:"language,omitempty"` // Declared language (ISO 639-1)
+}
+
+// IsZero reports whether no metadata was found
+func (m Metadata) IsZero() bool {
+	return m == Metadata{}
+}
+
+// Merge returns m with its empty fields taken from other
+func (m Metadata) Merge(other Metadata) Metadata {
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&m.Title, other.Title}, {&m.Description, other.Description}, {&m.Author, other.Author},
+		{&m.Published, other.Published}, {&m.SiteName, other.SiteName}, {&m.Image, other.Image}, {&m.Type, other.Type},
+		{&m.Language, other.Language},
+	} {
+		if *f.dst == "" {
+			*f.dst = f.src
+		}
+	}
+	return m
+}
+
+// metaKeys lists, per field, the meta tags read in order of preference
+var metaKeys = []struct {
+	field func(*Metadata) *string
+	keys  []string
+}{
+	{func(m *Metadata) *string { return &m.Title }, []string{"og:title", "twitter:title"}},
+	{func(m *Metadata) *string { return &m.Description }, []string{"og:description", "twitter:description", "description"}},
+	{func(m *Metadata) *string { return &m.Author }, []string{"author", "article:author", "twitter:creator", "dc.creator"}},
+	{func(m *Metadata) *string { return &m.Published }, []string{"article:published_time", "datepublished", "date", "pubdate", "dc.date.issued", "dc.date"}},
+	{func(m *Metadata) *string { return &m.SiteName }, []string{"og:site_name", "application-name"}},
+	{func(m *Metadata) *string { return &m.Image }, []string{"og:image", "og:image:url", "twitter:image"}},
+	{func(m *Metadata) *string { return &m.Type }, []string{"og:type"}},
+	{func(m *Metadata) *string { return &m.Language }, []string{"og:locale", "content-language", "dc.language", "language"}},
+}
+
+// ParseMetadata reads the metadata of an HTML document. Relative image URLs
+// are resolved against base.
+func ParseMetadata(document string, base *url.URL) Metadata {
+	doc, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		return Metadata{}
+	}
+	return findMetadata(doc, base)
+}
+
+func findMetadata(doc *html.Node, base *url.URL) Metadata {
+	// Meta tags by property, name, itemprop or http-equiv, lowercased; the
+	// first wins
+	tags := map[string]string{}
+	var ld []*html.Node
+	htmlLang := ""
+	walk(doc, func(n *html.Node) {
+		switch {
+		case n.DataAtom == atom.Html:
+			htmlLang = attr(n, "lang")
+		case n.DataAtom == atom.Meta:
+			content := strings.TrimSpace(collapseSpace(attr(n, "content")))
+			for _, key := range []string{attr(n, "property"), attr(n, "name"), attr(n, "itemprop"), attr(n, "http-equiv")} {
+				key = strings.ToLower(key)
+				if _, seen := tags[key]; key != "" && content != "" && !seen {
+					tags[key] = content
+				}
+			}
+		case n.DataAtom == atom.Script && strings.EqualFold(attr(n, "type"), "application/ld+json"):
+			ld = append(ld, n)
+		}
+	})
+
+	var meta Metadata
+	for _, mk := range metaKeys {
+		for _, key := range mk.keys {
+			if v := tags[key]; v != "" {
+				*mk.field(&meta) = v
+				break
+			}
+		}
+	}
+	// Profile URLs are no author names; JSON-LD may have the name
+	if strings.HasPrefix(meta.Author, "http://") || strings.HasPrefix(meta.Author, "https://") {
+		meta.Author = ""
+	}
+	for _, n := range ld {
+		meta = meta.Merge(parseJSONLD(textContent(n)))
+	}
+	// The document's lang attribute is the most reliable
+	if code := lang.Normalize(htmlLang); code != "" {
+		meta.Language = code
+	} else {
+		meta.Language = lang.Normalize(meta.Language)
+	}
+	meta.Published = normalizeDate(meta.Published)
+	if meta.Image != "" && base != nil {
+		if u, err := base.Parse(meta.Image); err == nil {
+			meta.Image = u.String()
+		}
+	}
+	return meta
+}
+
+// parseJSONLD reads the headline, description, author, publication date and
+// language of a JSON-LD object (or list or @graph of objects)
+func parseJSONLD(data string) Metadata {
+	var v interface{}
+	if json.Unmarshal([]byte(data), &v) != nil {
+		return Metadata{}
+	}
+
+	var meta Metadata
+	var visit func(v interface{})
+	visit = func(v interface{}) {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, item := range v {
+				visit(item)
+			}
+		case map[string]interface{}:
+			if graph, ok := v["@graph"]; ok {
+				visit(graph)
+			}
+			meta = meta.Merge(Metadata{
+				Title:       jsonString(v["headline"]),
+				Description: jsonString(v["description"]),
+				Author:      jsonName(v["author"]),
+				Published:   jsonString(v["datePublished"]),
+				Language:    jsonString(v["inLanguage"]),
+			})
+		}
+	}
+	visit(v)
+	return meta
+}
+
+// jsonName returns the name of a JSON-LD person: a string, an object with a
+// name, or the first of a list
+func jsonName(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]interface{}:
+		return jsonString(v["name"])
+	case []interface{}:
+		if len(v) > 0 {
+			return jsonName(v[0])
+		}
+	}
+	return ""
+}
+
+func jsonString(v interface{}) string {
+	s, _ := v.(string)
+	return strings.TrimSpace(s)
+}
+
+// dateLayouts are the publication date formats pages use
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// normalizeDate returns a date in RFC 3339, or "" when it can't be parsed
+func normalizeDate(s string) string {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return ""
+}
+
+// walk calls fn for every element of n's subtree, in document order
+func walk(n *html.Node, fn func(*html.Node)) {
+	if n.Type == html.ElementNode {
+		fn(n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
@@ -0,0 +1,131 @@
+package fsys
+
+import (
+	"io/fs"
+	"math/rand"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Operations faults can be limited to
+const (
+	OpOpen   = "open"
+	OpMkdir  = "mkdir" // MkdirAll and MkdirTemp
+	OpRename = "rename"
+	OpWrite  = "write"
+	OpSync   = "sync"
+)
+
+// Faults describes the faults a Faulty file system injects
+type Faults struct {
+	ErrorRate   float64       // Share of operations failing with EIO
+	NoSpaceRate float64       // Share of writes failing with ENOSPC, after writing half the data
+	WriteDelay  time.Duration // Added to every write and sync
+	Ops         []string      // Operations ErrorRate applies to (all when empty)
+	Seed        int64         // Seeds the fault choices (the time when 0)
+}
+
+// Faulty wraps a file system to fail some of its operations
+type Faulty struct {
+	fs     FS
+	faults Faults
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewFaulty returns fsys with faults injected
+func NewFaulty(fsys FS, faults Faults) *Faulty {
+	seed := faults.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Faulty{fs: fsys, faults: faults, rand: rand.New(rand.NewSource(seed))}
+}
+
+// chance reports whether an event of the given probability happens
+func (f *Faulty) chance(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rand.Float64() < rate
+}
+
+// fail returns the error injected into an operation, if any
+func (f *Faulty) fail(op, path string) error {
+	if len(f.faults.Ops) > 0 {
+		found := false
+		for _, o := range f.faults.Ops {
+			found = found || o == op
+		}
+		if !found {
+			return nil
+		}
+	}
+	if f.chance(f.faults.ErrorRate) {
+		return &fs.PathError{Op: op, Path: path, Err: syscall.EIO}
+	}
+	return nil
+}
+
+func (f *Faulty) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if err := f.fail(OpOpen, name); err != nil {
+		return nil, err
+	}
+	file, err := f.fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &faultyFile{File: file, fs: f, name: name}, nil
+}
+
+func (f *Faulty) MkdirAll(path string, perm fs.FileMode) error {
+	if err := f.fail(OpMkdir, path); err != nil {
+		return err
+	}
+	return f.fs.MkdirAll(path, perm)
+}
+
+func (f *Faulty) MkdirTemp(dir, pattern string) (string, error) {
+	if err := f.fail(OpMkdir, dir); err != nil {
+		return "", err
+	}
+	return f.fs.MkdirTemp(dir, pattern)
+}
+
+func (f *Faulty) Rename(oldpath, newpath string) error {
+	if err := f.fail(OpRename, oldpath); err != nil {
+		return err
+	}
+	return f.fs.Rename(oldpath, newpath)
+}
+
+// faultyFile injects the faults of its file system into writes and syncs
+type faultyFile struct {
+	File
+	fs   *Faulty
+	name string
+}
+
+func (f *faultyFile) Write(p []byte) (int, error) {
+	time.Sleep(f.fs.faults.WriteDelay)
+	if err := f.fs.fail(OpWrite, f.name); err != nil {
+		return 0, err
+	}
+	if f.fs.chance(f.fs.faults.NoSpaceRate) {
+		n, _ := f.File.Write(p[:len(p)/2])
+		return n, &fs.PathError{Op: OpWrite, Path: f.name, Err: syscall.ENOSPC}
+	}
+	return f.File.Write(p)
+}
+
+func (f *faultyFile) Sync() error {
+	time.Sleep(f.fs.faults.WriteDelay)
+	if err := f.fs.fail(OpSync, f.name); err != nil {
+		return err
+	}
+	return f.File.Sync()
+}
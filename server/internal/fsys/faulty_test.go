@@ -0,0 +1,118 @@
+package fsys
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func writeFile(fsys FS, path string, data []byte) error {
+	f, err := fsys.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func TestFaultyNoFaults(t *testing.T) {
+	dir := t.TempDir()
+	fsys := NewFaulty(OS{}, Faults{})
+
+	if err := fsys.MkdirAll(filepath.Join(dir, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(fsys, filepath.Join(dir, "a", "page.md"), []byte("# Page")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Rename(filepath.Join(dir, "a"), filepath.Join(dir, "b")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "b", "page.md"))
+	if err != nil || string(data) != "# Page" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+}
+
+func TestFaultyErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "page.md")
+
+	// Limited to syncs, so the file is written but not flushed
+	fsys := NewFaulty(OS{}, Faults{ErrorRate: 1, Ops: []string{OpSync}})
+	if err := fsys.MkdirAll(filepath.Join(dir, "a"), 0755); err != nil {
+		t.Fatalf("mkdir failed: %v", err)
+	}
+	err := writeFile(fsys, path, []byte("# Page"))
+	if !errors.Is(err, syscall.EIO) {
+		t.Fatalf("err = %v, want EIO", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "# Page" {
+		t.Errorf("data = %q", data)
+	}
+
+	fsys = NewFaulty(OS{}, Faults{ErrorRate: 1})
+	for op, err := range map[string]error{
+		OpOpen:   writeFile(fsys, path, nil),
+		OpMkdir:  fsys.MkdirAll(filepath.Join(dir, "b"), 0755),
+		OpRename: fsys.Rename(path, path+".old"),
+	} {
+		if !errors.Is(err, syscall.EIO) {
+			t.Errorf("%s: err = %v, want EIO", op, err)
+		}
+	}
+	if _, err := fsys.MkdirTemp(dir, "tmp-"); !errors.Is(err, syscall.EIO) {
+		t.Errorf("MkdirTemp: err = %v, want EIO", err)
+	}
+}
+
+func TestFaultyNoSpace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "page.md")
+	fsys := NewFaulty(OS{}, Faults{NoSpaceRate: 1})
+
+	err := writeFile(fsys, path, []byte("12345678"))
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Fatalf("err = %v, want ENOSPC", err)
+	}
+	// Like a disk filling up, part of the data made it
+	if data, _ := os.ReadFile(path); string(data) != "1234" {
+		t.Errorf("data = %q, want a partial write", data)
+	}
+}
+
+func TestFaultyRates(t *testing.T) {
+	dir := t.TempDir()
+	fsys := NewFaulty(OS{}, Faults{ErrorRate: 0.5, Ops: []string{OpMkdir}, Seed: 1})
+
+	failed := 0
+	for i := 0; i < 200; i++ {
+		if err := fsys.MkdirAll(dir, 0755); err != nil {
+			failed++
+		}
+	}
+	if failed < 60 || failed > 140 {
+		t.Errorf("%d of 200 failed, want about half", failed)
+	}
+}
+
+func TestFaultyWriteDelay(t *testing.T) {
+	fsys := NewFaulty(OS{}, Faults{WriteDelay: 20 * time.Millisecond})
+
+	start := time.Now()
+	if err := writeFile(fsys, filepath.Join(t.TempDir(), "page.md"), []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("write took %v, want the delay on write and sync", elapsed)
+	}
+}
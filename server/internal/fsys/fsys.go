@@ -0,0 +1,46 @@
+// Package fsys is the small file system surface clip folders are written
+// through, so tests and dev mode can inject storage faults (I/O errors, a
+// full disk, slow writes) into the code saving clips.
+package fsys
+
+import (
+	"io"
+	"io/fs"
+	"os"
+)
+
+// FS creates files and folders
+type FS interface {
+	// OpenFile opens a file like os.OpenFile; a folder opened read-only can
+	// be synced to flush its entries
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+	MkdirAll(path string, perm fs.FileMode) error
+	MkdirTemp(dir, pattern string) (string, error)
+	Rename(oldpath, newpath string) error
+}
+
+// File is an open file
+type File interface {
+	io.Writer
+	Sync() error
+	Close() error
+}
+
+// OS is the operating system's file system
+type OS struct{}
+
+func (OS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (OS) MkdirAll(path string, perm fs.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (OS) MkdirTemp(dir, pattern string) (string, error) {
+	return os.MkdirTemp(dir, pattern)
+}
+
+func (OS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
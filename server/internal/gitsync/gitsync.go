@@ -0,0 +1,140 @@
+// Package gitsync keeps a clip directory in a git repository, committing
+// changes and pushing them to a remote, by running the git binary.
+package gitsync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// remoteName is the name of the remote pushed to
+const remoteName = "origin"
+
+// gitignore leaves the server's hidden working folders (trash, staging,
+// caches, deduplicated media) out of the repository
+const gitignore = "# Written by web-clipper: its working folders are not versioned\n.*/\n"
+
+// Repo is a working tree whose changes are committed as a fixed author
+type Repo struct {
+	Dir         string
+	Git         string // Binary path (default: "git" from PATH)
+	Branch      string // Default: main
+	AuthorName  string
+	AuthorEmail string
+}
+
+// Open makes the directory a repository unless it is one already, and sets
+// the remote pushed to when remote is not empty
+func (r *Repo) Open(ctx context.Context, remote string) error {
+	if _, err := os.Stat(filepath.Join(r.Dir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(r.Dir, 0755); err != nil {
+			return err
+		}
+		if _, err := r.run(ctx, "init", "-q", "-b", r.branch()); err != nil {
+			return err
+		}
+		ignore := filepath.Join(r.Dir, ".gitignore")
+		if _, err := os.Stat(ignore); os.IsNotExist(err) {
+			if err := os.WriteFile(ignore, []byte(gitignore), 0644); err != nil {
+				return err
+			}
+		}
+		if _, err := r.Commit(ctx, "Version the clip directory", ".gitignore"); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if remote == "" {
+		return nil
+	}
+	current, err := r.run(ctx, "remote", "get-url", remoteName)
+	switch {
+	case err != nil:
+		_, err = r.run(ctx, "remote", "add", remoteName, remote)
+	case strings.TrimSpace(current) != remote:
+		_, err = r.run(ctx, "remote", "set-url", remoteName, remote)
+	}
+	return err
+}
+
+// Commit commits the changes under paths (relative to the repository,
+// removals included) and reports whether there were any. Other changes are
+// left out of the commit.
+func (r *Repo) Commit(ctx context.Context, message string, paths ...string) (bool, error) {
+	var changed []string
+	for _, p := range paths {
+		if _, err := os.Lstat(filepath.Join(r.Dir, p)); err == nil {
+			changed = append(changed, p)
+			continue
+		}
+		// A removed path is only known to git when it was committed
+		tracked, err := r.run(ctx, "ls-files", "--", p)
+		if err != nil {
+			return false, err
+		}
+		if tracked != "" {
+			changed = append(changed, p)
+		}
+	}
+	if len(changed) == 0 {
+		return false, nil
+	}
+
+	pathspec := append([]string{"--"}, changed...)
+	if _, err := r.run(ctx, append([]string{"add", "-A"}, pathspec...)...); err != nil {
+		return false, err
+	}
+	// diff --quiet exits with 1 when there are changes
+	_, err := r.run(ctx, append([]string{"diff", "--cached", "--quiet"}, pathspec...)...)
+	var exit *exec.ExitError
+	if err == nil {
+		return false, nil
+	} else if !errors.As(err, &exit) || exit.ExitCode() != 1 {
+		return false, err
+	}
+	if _, err := r.run(ctx, append([]string{"commit", "-q", "--no-verify", "-m", message}, pathspec...)...); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Push pushes the branch to the remote set by Open
+func (r *Repo) Push(ctx context.Context) error {
+	_, err := r.run(ctx, "push", "-q", remoteName, "HEAD:refs/heads/"+r.branch())
+	return err
+}
+
+func (r *Repo) branch() string {
+	if r.Branch == "" {
+		return "main"
+	}
+	return r.Branch
+}
+
+// run runs a git command in the repository and returns its output
+func (r *Repo) run(ctx context.Context, args ...string) (string, error) {
+	bin := r.Git
+	if bin == "" {
+		bin = "git"
+	}
+	command := args[0]
+	args = append([]string{"-C", r.Dir, "-c", "user.name=" + r.AuthorName, "-c", "user.email=" + r.AuthorEmail}, args...)
+
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
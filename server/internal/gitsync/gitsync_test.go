@@ -0,0 +1,72 @@
+package gitsync
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommitPush(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	if out, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+
+	repo := &Repo{Dir: filepath.Join(dir, "clips"), AuthorName: "Clipper", AuthorEmail: "clipper@example.com"}
+	if err := repo.Open(ctx, remote); err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(repo.Dir, "web-clips", "a"), 0755)
+	os.MkdirAll(filepath.Join(repo.Dir, ".trash"), 0755)
+	os.WriteFile(filepath.Join(repo.Dir, "web-clips", "a", "page.md"), []byte("# A"), 0644)
+	os.WriteFile(filepath.Join(repo.Dir, "web-clips", "other.md"), []byte("# Other"), 0644)
+	os.WriteFile(filepath.Join(repo.Dir, ".trash", "old.md"), []byte("# Old"), 0644)
+
+	committed, err := repo.Commit(ctx, "Clip: A", "web-clips/a")
+	if err != nil || !committed {
+		t.Fatalf("Commit = %v, %v", committed, err)
+	}
+	// Only the given paths are committed, once
+	if committed, err := repo.Commit(ctx, "Clip: A", "web-clips/a", "web-clips/missing"); err != nil || committed {
+		t.Fatalf("Commit without changes = %v, %v", committed, err)
+	}
+	if files := git(t, repo.Dir, "ls-files"); files != ".gitignore\nweb-clips/a/page.md" {
+		t.Errorf("tracked files = %q", files)
+	}
+
+	// Removals are committed, hidden folders are ignored
+	os.RemoveAll(filepath.Join(repo.Dir, "web-clips", "a"))
+	if committed, err := repo.Commit(ctx, "Trash: A", "web-clips/a"); err != nil || !committed {
+		t.Fatalf("Commit removal = %v, %v", committed, err)
+	}
+	if _, err := repo.Commit(ctx, "Sync", "."); err != nil {
+		t.Fatal(err)
+	}
+	if files := git(t, repo.Dir, "ls-files"); files != ".gitignore\nweb-clips/other.md" {
+		t.Errorf("tracked files = %q", files)
+	}
+
+	if err := repo.Push(ctx); err != nil {
+		t.Fatal(err)
+	}
+	log := git(t, remote, "log", "--format=%an %s", "main")
+	if log != "Clipper Sync\nClipper Trash: A\nClipper Clip: A\nClipper Version the clip directory" {
+		t.Errorf("pushed log = %q", log)
+	}
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
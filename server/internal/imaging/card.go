@@ -0,0 +1,163 @@
+package imaging
+
+import (
+	"bytes"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Social cards are shown at 1200x630 by Slack, Discord and most unfurlers
+const (
+	CardWidth  = 1200
+	CardHeight = 630
+
+	cardMargin    = 80
+	cardTitleSize = 64
+	cardSiteSize  = 34
+	cardMaxLines  = 4
+)
+
+// MinCoverWidth is the narrowest image used as a card background; smaller
+// ones would be upscaled into a blur
+const MinCoverWidth = 600
+
+// cardColors are the backgrounds of cards without a cover, picked per site
+var cardColors = []color.RGBA{
+	{0x1f, 0x3a, 0x5f, 0xff}, {0x2d, 0x4a, 0x3e, 0xff}, {0x5b, 0x2a, 0x4e, 0xff},
+	{0x6b, 0x3a, 0x1f, 0xff}, {0x2b, 0x2d, 0x42, 0xff}, {0x1e, 0x50, 0x5c, 0xff},
+}
+
+var (
+	cardFontsOnce sync.Once
+	cardFontsErr  error
+	titleFont     *opentype.Font
+	siteFont      *opentype.Font
+)
+
+func loadCardFonts() error {
+	cardFontsOnce.Do(func() {
+		if titleFont, cardFontsErr = opentype.Parse(gobold.TTF); cardFontsErr == nil {
+			siteFont, cardFontsErr = opentype.Parse(goregular.TTF)
+		}
+	})
+	return cardFontsErr
+}
+
+// Card renders a social preview card as a PNG: the title and site name over
+// a color picked from the site, or over cover (a PNG or JPEG) cropped to
+// fill the card and darkened. A cover that can't be used is ignored.
+func Card(title, site string, cover []byte) ([]byte, error) {
+	if err := loadCardFonts(); err != nil {
+		return nil, err
+	}
+
+	card := image.NewRGBA(image.Rect(0, 0, CardWidth, CardHeight))
+	if bg := cardCover(cover); bg != nil {
+		draw.Draw(card, card.Bounds(), bg, image.Point{}, draw.Src)
+		draw.Draw(card, card.Bounds(), image.NewUniform(color.RGBA{0, 0, 0, 0x99}), image.Point{}, draw.Over)
+	} else {
+		h := fnv.New32a()
+		h.Write([]byte(site))
+		draw.Draw(card, card.Bounds(), image.NewUniform(cardColors[h.Sum32()%uint32(len(cardColors))]), image.Point{}, draw.Src)
+	}
+
+	titleFace, err := opentype.NewFace(titleFont, &opentype.FaceOptions{Size: cardTitleSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+	siteFace, err := opentype.NewFace(siteFont, &opentype.FaceOptions{Size: cardSiteSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	defer siteFace.Close()
+
+	white := image.NewUniform(color.White)
+	lineHeight := titleFace.Metrics().Height.Ceil() + 8
+	y := cardMargin + titleFace.Metrics().Ascent.Ceil()
+	for _, line := range wrapText(titleFace, title, CardWidth-2*cardMargin, cardMaxLines) {
+		d := &font.Drawer{Dst: card, Src: white, Face: titleFace, Dot: fixed.P(cardMargin, y)}
+		d.DrawString(line)
+		y += lineHeight
+	}
+	d := &font.Drawer{Dst: card, Src: image.NewUniform(color.RGBA{0xdd, 0xdd, 0xdd, 0xff}), Face: siteFace,
+		Dot: fixed.P(cardMargin, CardHeight-cardMargin)}
+	d.DrawString(site)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, card); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// cardCover returns cover cropped and scaled to the card, or nil when it is
+// not a PNG or JPEG of at least MinCoverWidth
+func cardCover(cover []byte) *image.RGBA {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(cover))
+	if err != nil || (format != "png" && format != "jpeg") || cfg.Width < MinCoverWidth {
+		return nil
+	}
+	img, err := decodeOriented(cover, format)
+	if err != nil {
+		return nil
+	}
+
+	// Crop the middle to the card's aspect ratio
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dx()*CardHeight/CardWidth
+	if h > b.Dy() {
+		w, h = b.Dy()*CardWidth/CardHeight, b.Dy()
+	}
+	crop := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(crop, crop.Bounds(), img, image.Pt((b.Dx()-w)/2, (b.Dy()-h)/2), draw.Src)
+	return resize(crop, CardWidth, CardHeight)
+}
+
+// wrapText splits text into lines no wider than width, ending the last of
+// maxLines with an ellipsis when text doesn't fit
+func wrapText(face font.Face, text string, width, maxLines int) []string {
+	limit := fixed.I(width)
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := strings.TrimSpace(line + " " + word)
+		if font.MeasureString(face, candidate) <= limit || line == "" {
+			line = candidate
+			continue
+		}
+		lines = append(lines, line)
+		line = word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		lines[maxLines-1] += "…"
+	}
+	// A single word wider than the card, or the ellipsis, is cut to fit
+	for i, l := range lines {
+		for font.MeasureString(face, l) > limit {
+			r := []rune(strings.TrimSuffix(l, "…"))
+			if len(r) <= 1 {
+				break
+			}
+			l = string(r[:len(r)-1]) + "…"
+		}
+		lines[i] = l
+	}
+	return lines
+}
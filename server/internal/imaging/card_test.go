@@ -0,0 +1,64 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/basicfont"
+)
+
+func TestCard(t *testing.T) {
+	data, err := Card("A fairly long article title that needs wrapping over a few lines", "example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	card, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := card.Bounds(); b.Dx() != CardWidth || b.Dy() != CardHeight {
+		t.Errorf("card is %dx%d", b.Dx(), b.Dy())
+	}
+
+	// A large enough cover fills the card, darkened
+	cover := image.NewRGBA(image.Rect(0, 0, 800, 800))
+	for i := range cover.Pix {
+		cover.Pix[i] = 0xff
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, cover)
+	data, err = Card("Title", "example.com", buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	card, _ = png.Decode(bytes.NewReader(data))
+	r, g, b, _ := card.At(CardWidth-2, CardHeight/2).RGBA()
+	if r != g || g != b || r>>8 < 0x50 || r>>8 > 0x70 {
+		t.Errorf("expected darkened white at the edge, got %v", card.At(CardWidth-2, CardHeight/2))
+	}
+
+	// Small covers are ignored
+	small := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	small.Set(0, 0, color.White)
+	buf.Reset()
+	png.Encode(&buf, small)
+	if _, err := Card("Title", "example.com", buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	face := basicfont.Face7x13 // 7px per character
+	lines := wrapText(face, strings.Repeat("word ", 100), 400, 3)
+	if len(lines) != 3 || !strings.HasSuffix(lines[2], "…") {
+		t.Errorf("expected 3 lines ending with an ellipsis, got %q", lines)
+	}
+	lines = wrapText(face, strings.Repeat("x", 500), 400, 3)
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], "…") {
+		t.Errorf("expected a long word cut to fit, got %q", lines)
+	}
+}
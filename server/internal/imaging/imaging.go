@@ -0,0 +1,143 @@
+// Package imaging downscales PNG and JPEG images with the standard library
+// codecs and converts them to WebP. Other formats are left to the caller to
+// keep as they are. Sniff tells formats apart by content, and Card renders
+// social preview cards.
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+// jpegQuality is used when re-encoding downscaled JPEGs
+const jpegQuality = 90
+
+// Fit downscales an image so neither side exceeds maxDim, keeping its aspect
+// ratio and format. It returns nil when the image already fits or is not a
+// PNG or JPEG.
+func Fit(data []byte, maxDim int) ([]byte, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "png" && format != "jpeg") {
+		return nil, nil // Not an image we can resize
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+	width, height := cfg.Width, cfg.Height
+	if orientation >= 5 { // Stored sideways
+		width, height = height, width
+	}
+	if maxDim <= 0 || (width <= maxDim && height <= maxDim) {
+		return nil, nil
+	}
+
+	src, err := decodeOriented(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	dstW, dstH := fitSize(width, height, maxDim)
+	dst := resize(src, dstW, dstH)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeOriented decodes a PNG or JPEG to RGBA, applying the EXIF
+// orientation of JPEGs. Premultiplied RGBA keeps transparent pixels from
+// bleeding into their neighbours when resizing.
+func decodeOriented(data []byte, format string) (*image.RGBA, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	if format == "jpeg" {
+		rgba = orient(rgba, jpegOrientation(data))
+	}
+	return rgba, nil
+}
+
+// fitSize scales width and height down so the longest side is maxDim
+func fitSize(width, height, maxDim int) (int, int) {
+	if width >= height {
+		h := (height*maxDim + width/2) / width
+		return maxDim, max(h, 1)
+	}
+	w := (width*maxDim + height/2) / height
+	return max(w, 1), maxDim
+}
+
+// resize downscales src to width x height by averaging the source pixels
+// each destination pixel covers, horizontally then vertically
+func resize(src *image.RGBA, width, height int) *image.RGBA {
+	b := src.Bounds()
+	tmp := image.NewRGBA(image.Rect(0, 0, width, b.Dy()))
+	cols := areaWeights(b.Dx(), width)
+	for y := 0; y < b.Dy(); y++ {
+		for x, taps := range cols {
+			blend(tmp.Pix[tmp.PixOffset(x, y):], src.Pix[src.PixOffset(0, y):], 4, taps)
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	rows := areaWeights(b.Dy(), height)
+	for y, taps := range rows {
+		for x := 0; x < width; x++ {
+			blend(dst.Pix[dst.PixOffset(x, y):], tmp.Pix[tmp.PixOffset(x, 0):], tmp.Stride, taps)
+		}
+	}
+	return dst
+}
+
+// tap is a source pixel index and its share of a destination pixel
+type tap struct {
+	index  int
+	weight float64
+}
+
+// areaWeights returns, for each of the dst pixels along an axis, the source
+// pixels it covers and how much of each
+func areaWeights(src, dst int) [][]tap {
+	scale := float64(src) / float64(dst)
+	weights := make([][]tap, dst)
+	for i := range weights {
+		start, end := float64(i)*scale, float64(i+1)*scale
+		for s := int(start); s < src && float64(s) < end; s++ {
+			w := min(end, float64(s+1)) - max(start, float64(s))
+			if w > 0 {
+				weights[i] = append(weights[i], tap{s, w / scale})
+			}
+		}
+	}
+	return weights
+}
+
+// blend writes to out the weighted sum of the RGBA pixels at in, stride
+// bytes apart
+func blend(out, in []byte, stride int, taps []tap) {
+	var sum [4]float64
+	for _, t := range taps {
+		p := in[t.index*stride:]
+		for c := 0; c < 4; c++ {
+			sum[c] += float64(p[c]) * t.weight
+		}
+	}
+	for c := 0; c < 4; c++ {
+		out[c] = uint8(min(sum[c]+0.5, 255))
+	}
+}
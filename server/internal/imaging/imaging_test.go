@@ -0,0 +1,120 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decode(t *testing.T, data []byte) (image.Image, string) {
+	t.Helper()
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img, format
+}
+
+func TestFitPNG(t *testing.T) {
+	// Left half red, right half transparent
+	src := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 200; x++ {
+			src.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+
+	out, err := Fit(encodePNG(t, src), 100)
+	if err != nil || out == nil {
+		t.Fatalf("expected a resized image, got %v", err)
+	}
+	img, format := decode(t, out)
+	if format != "png" || img.Bounds().Dx() != 100 || img.Bounds().Dy() != 50 {
+		t.Fatalf("got %s %v, want png 100x50", format, img.Bounds())
+	}
+	if c := color.NRGBAModel.Convert(img.At(10, 10)).(color.NRGBA); c != (color.NRGBA{R: 255, A: 255}) {
+		t.Errorf("red half became %v", c)
+	}
+	if _, _, _, a := img.At(90, 10).RGBA(); a != 0 {
+		t.Errorf("transparent half became opaque")
+	}
+}
+
+func TestFitKeepsWhatFits(t *testing.T) {
+	small := encodePNG(t, image.NewRGBA(image.Rect(0, 0, 50, 80)))
+	for _, data := range [][]byte{small, []byte("%PDF-1.4"), nil} {
+		if out, err := Fit(data, 100); out != nil || err != nil {
+			t.Errorf("expected the data to be kept, got %d bytes, %v", len(out), err)
+		}
+	}
+	if out, _ := Fit(small, 0); out != nil {
+		t.Error("expected no limit with maxDim 0")
+	}
+}
+
+// withOrientation inserts an Exif APP1 segment with the orientation into a JPEG
+func withOrientation(data []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], exifOrientationTag)
+	binary.BigEndian.PutUint16(entry[2:], 3) // SHORT
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	payload := append(append([]byte("Exif\x00\x00"), tiff...), entry...)
+
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+	return append(append([]byte{0xFF, 0xD8}, segment...), data[2:]...)
+}
+
+func TestFitJPEGOrientation(t *testing.T) {
+	// Stored 400x200 with a white band on top; displayed rotated 90° clockwise
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			c := color.RGBA{A: 255}
+			if y < 40 {
+				c = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := withOrientation(buf.Bytes(), 6)
+	if o := jpegOrientation(data); o != 6 {
+		t.Fatalf("orientation = %d, want 6", o)
+	}
+
+	out, err := Fit(data, 100)
+	if err != nil || out == nil {
+		t.Fatalf("expected a resized image, got %v", err)
+	}
+	img, format := decode(t, out)
+	if format != "jpeg" || img.Bounds().Dx() != 50 || img.Bounds().Dy() != 100 {
+		t.Fatalf("got %s %v, want jpeg 50x100", format, img.Bounds())
+	}
+	// The top band ends up on the right
+	if r, _, _, _ := img.At(48, 50).RGBA(); r>>8 < 200 {
+		t.Error("expected the right edge to be white")
+	}
+	if r, _, _, _ := img.At(2, 50).RGBA(); r>>8 > 50 {
+		t.Error("expected the left edge to be black")
+	}
+}
@@ -0,0 +1,94 @@
+package imaging
+
+import (
+	"bytes"
+)
+
+// SniffLen is how many leading bytes Sniff needs
+const SniffLen = 512
+
+// Formats recognized by Sniff
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatGIF  = "gif"
+	FormatWebP = "webp"
+	FormatAVIF = "avif"
+	FormatBMP  = "bmp"
+	FormatICO  = "ico"
+	FormatSVG  = "svg"
+	FormatPDF  = "pdf"
+)
+
+// Sniff returns the format of a file from its first bytes, whatever its name
+// says, or "" when it is none of the known formats (HTML, executables, ...).
+func Sniff(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		return FormatJPEG
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return FormatGIF
+	case len(head) >= 12 && bytes.Equal(head[:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WEBP")):
+		return FormatWebP
+	case len(head) >= 12 && bytes.Equal(head[4:8], []byte("ftyp")) &&
+		(bytes.Equal(head[8:12], []byte("avif")) || bytes.Equal(head[8:12], []byte("avis"))):
+		return FormatAVIF
+	case len(head) >= 14 && bytes.HasPrefix(head, []byte("BM")):
+		return FormatBMP
+	case bytes.HasPrefix(head, []byte("\x00\x00\x01\x00")):
+		return FormatICO
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return FormatPDF
+	case isSVG(head):
+		return FormatSVG
+	}
+	return ""
+}
+
+// isSVG reports whether head starts an SVG document: an <svg> root, possibly
+// after an XML declaration, comments or a doctype
+func isSVG(head []byte) bool {
+	text := bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	text = bytes.TrimLeft(text, " \t\r\n")
+	if !bytes.HasPrefix(text, []byte("<")) {
+		return false
+	}
+	root := bytes.Index(text, []byte("<svg"))
+	if root < 0 {
+		return false
+	}
+	// Anything before the root must be a prolog, not markup like <html>
+	for prolog := text[:root]; len(prolog) > 0; {
+		prolog = bytes.TrimLeft(prolog, " \t\r\n")
+		if len(prolog) == 0 {
+			break
+		}
+		if !bytes.HasPrefix(prolog, []byte("<?")) && !bytes.HasPrefix(prolog, []byte("<!")) {
+			return false
+		}
+		end := bytes.IndexByte(prolog, '>')
+		if end < 0 {
+			return false
+		}
+		prolog = prolog[end+1:]
+	}
+	return true
+}
+
+// ContentType returns the MIME type of a format returned by Sniff, or "" for
+// an unknown one
+func ContentType(format string) string {
+	switch format {
+	case FormatPNG, FormatJPEG, FormatGIF, FormatWebP, FormatAVIF, FormatBMP:
+		return "image/" + format
+	case FormatICO:
+		return "image/x-icon"
+	case FormatSVG:
+		return "image/svg+xml"
+	case FormatPDF:
+		return "application/pdf"
+	}
+	return ""
+}
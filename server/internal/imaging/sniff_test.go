@@ -0,0 +1,51 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"png", encodePNG(t, image.NewRGBA(image.Rect(0, 0, 2, 2))), FormatPNG},
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), FormatJPEG},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00"), FormatGIF},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), FormatWebP},
+		{"avif", []byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00"), FormatAVIF},
+		{"bmp", []byte("BM\x46\x00\x00\x00\x00\x00\x00\x00\x36\x00\x00\x00"), FormatBMP},
+		{"ico", []byte("\x00\x00\x01\x00\x01\x00\x10\x10"), FormatICO},
+		{"pdf", []byte("%PDF-1.7\n"), FormatPDF},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), FormatSVG},
+		{"svg with prolog", []byte("\xef\xbb\xbf<?xml version=\"1.0\"?>\n<!-- logo -->\n<!DOCTYPE svg>\n<svg/>"), FormatSVG},
+		{"html", []byte("<!DOCTYPE html><html><body><svg/></body></html>"), ""},
+		{"script", []byte("<script>alert(1)</script>"), ""},
+		{"executable", []byte("MZ\x90\x00\x03\x00\x00\x00"), ""},
+		{"elf", []byte("\x7fELF\x02\x01\x01"), ""},
+		{"mp4", []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00"), ""},
+		{"text", []byte("not an image"), ""},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := Sniff(tt.head); got != tt.want {
+			t.Errorf("%s: Sniff = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	for format, want := range map[string]string{
+		FormatPNG: "image/png",
+		FormatICO: "image/x-icon",
+		FormatSVG: "image/svg+xml",
+		FormatPDF: "application/pdf",
+		"":        "",
+	} {
+		if got := ContentType(format); got != want {
+			t.Errorf("ContentType(%q) = %q, want %q", format, got, want)
+		}
+	}
+}
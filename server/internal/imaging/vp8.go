@@ -0,0 +1,730 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+)
+
+// A minimal VP8 key frame encoder (RFC 6386), enough to store still images
+// as lossy WebP. Every macroblock is predicted as a whole, with the best of
+// the DC, vertical, horizontal and TrueMotion modes, and the default
+// coefficient probabilities are used, so the output is larger than what
+// libwebp would produce at the same quality, but decodes with any WebP
+// decoder.
+
+// maxVP8Dimension is the largest width or height a VP8 frame can have
+const maxVP8Dimension = 16383
+
+// Intra prediction modes of 16x16 luma and 8x8 chroma blocks
+const (
+	predDC = iota
+	predVE // Vertical: copies the row above
+	predHE // Horizontal: copies the column on the left
+	predTM // TrueMotion: above + left - above-left
+)
+
+// quantizer holds the DC and AC quantizer steps of each kind of block
+type quantizer struct {
+	y1, y2, uv [2]int32
+}
+
+// newQuantizer returns the steps a decoder derives from the quantizer index
+// (section 14.1)
+func newQuantizer(index int) quantizer {
+	var q quantizer
+	q.y1 = [2]int32{int32(dcQuant[index]), int32(acQuant[index])}
+	q.y2 = [2]int32{int32(dcQuant[index]) * 2, int32(acQuant[index]) * 155 / 100}
+	if q.y2[1] < 8 {
+		q.y2[1] = 8
+	}
+	q.uv = [2]int32{int32(dcQuant[min(index, 117)]), int32(acQuant[index])}
+	return q
+}
+
+// macroblock holds the modes and quantized coefficients of a macroblock
+type macroblock struct {
+	yMode, uvMode uint8
+	y2            [16]int16     // Luma DC coefficients after the Walsh-Hadamard transform
+	y             [16][16]int16 // Luma blocks; their DC is in y2
+	uv            [8][16]int16  // 4 U then 4 V blocks
+	skip          bool          // No coefficient to code
+}
+
+// vp8Encoder encodes one frame
+type vp8Encoder struct {
+	width, height int
+	mbw, mbh      int
+	q             quantizer
+	qIndex        int
+	filterLevel   int
+
+	// Source and reconstructed planes, padded to whole macroblocks
+	yStride, uvStride int
+	srcY, srcU, srcV  []uint8
+	recY, recU, recV  []uint8
+
+	mbs []macroblock
+}
+
+// encodeVP8 encodes img as a VP8 key frame. qIndex (0-127) is the quantizer
+// index: the higher, the smaller and blurrier.
+func encodeVP8(img *image.RGBA, qIndex int) ([]byte, error) {
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 || b.Dx() > maxVP8Dimension || b.Dy() > maxVP8Dimension {
+		return nil, errors.New("vp8: unsupported image size")
+	}
+
+	e := &vp8Encoder{
+		width:       b.Dx(),
+		height:      b.Dy(),
+		mbw:         (b.Dx() + 15) / 16,
+		mbh:         (b.Dy() + 15) / 16,
+		q:           newQuantizer(qIndex),
+		qIndex:      qIndex,
+		filterLevel: min(qIndex/2, 63),
+	}
+	e.loadYUV(img)
+	e.mbs = make([]macroblock, e.mbw*e.mbh)
+	for mby := 0; mby < e.mbh; mby++ {
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			e.encodeMacroblock(mbx, mby, &e.mbs[mby*e.mbw+mbx])
+		}
+	}
+	return e.frame(), nil
+}
+
+// loadYUV converts img to 4:2:0 YUV with the BT.601 coefficients libwebp
+// uses, repeating the last row and column to fill the padding
+func (e *vp8Encoder) loadYUV(img *image.RGBA) {
+	e.yStride, e.uvStride = e.mbw*16, e.mbw*8
+	rows, uvRows := e.mbh*16, e.mbh*8
+	e.srcY, e.recY = make([]uint8, e.yStride*rows), make([]uint8, e.yStride*rows)
+	e.srcU, e.recU = make([]uint8, e.uvStride*uvRows), make([]uint8, e.uvStride*uvRows)
+	e.srcV, e.recV = make([]uint8, e.uvStride*uvRows), make([]uint8, e.uvStride*uvRows)
+
+	b := img.Bounds()
+	pixel := func(x, y int) (int32, int32, int32) {
+		p := img.Pix[img.PixOffset(b.Min.X+min(x, e.width-1), b.Min.Y+min(y, e.height-1)):]
+		return int32(p[0]), int32(p[1]), int32(p[2])
+	}
+
+	const half = 1 << 15
+	for y := 0; y < rows; y++ {
+		for x := 0; x < e.yStride; x++ {
+			r, g, b := pixel(x, y)
+			e.srcY[y*e.yStride+x] = uint8((16839*r + 33059*g + 6420*b + half + 16<<16) >> 16)
+		}
+	}
+	for y := 0; y < uvRows; y++ {
+		for x := 0; x < e.uvStride; x++ {
+			var r, g, b int32
+			for _, d := range [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+				pr, pg, pb := pixel(2*x+d[0], 2*y+d[1])
+				r, g, b = r+pr, g+pg, b+pb
+			}
+			e.srcU[y*e.uvStride+x] = clipUV(-9719*r - 19081*g + 28800*b)
+			e.srcV[y*e.uvStride+x] = clipUV(28800*r - 24116*g - 4684*b)
+		}
+	}
+}
+
+// clipUV scales a chroma value computed from the sum of 4 pixels
+func clipUV(v int32) uint8 {
+	return clip8((v + 1<<17 + 128<<18) >> 18)
+}
+
+func clip8(v int32) uint8 {
+	return uint8(min(max(v, 0), 255))
+}
+
+// encodeMacroblock picks the prediction modes of a macroblock, quantizes its
+// residuals and reconstructs it the way a decoder will, for the prediction of
+// the next ones
+func (e *vp8Encoder) encodeMacroblock(mbx, mby int, mb *macroblock) {
+	var pred [256]uint8
+
+	// Luma, as one 16x16 block whose 4x4 DC coefficients go through Y2
+	x, y := mbx*16, mby*16
+	mb.yMode = bestMode(e.srcY, e.recY, e.yStride, x, y, 16, pred[:])
+	predict(pred[:], e.recY, e.yStride, x, y, 16, mb.yMode)
+
+	var coeffs [16][16]int32
+	var dc [16]int32
+	for n := range coeffs {
+		bx, by := (n%4)*4, (n/4)*4
+		forwardDCT(e.srcY[(y+by)*e.yStride+x+bx:], e.yStride, pred[by*16+bx:], 16, &coeffs[n])
+		dc[n] = coeffs[n][0]
+	}
+	var wht [16]int32
+	forwardWHT(&dc, &wht)
+	for i, c := range wht {
+		mb.y2[i] = quantize(c, e.q.y2[min(i, 1)], i == 0)
+	}
+	for n := range coeffs {
+		for i := 1; i < 16; i++ {
+			mb.y[n][i] = quantize(coeffs[n][i], e.q.y1[1], false)
+		}
+	}
+
+	for i, l := range mb.y2 {
+		wht[i] = int32(l) * e.q.y2[min(i, 1)]
+	}
+	inverseWHT(&wht, &dc)
+	for n := range coeffs {
+		var c [16]int32
+		c[0] = dc[n]
+		for i := 1; i < 16; i++ {
+			c[i] = int32(mb.y[n][i]) * e.q.y1[1]
+		}
+		bx, by := (n%4)*4, (n/4)*4
+		inverseDCT(&c, pred[by*16+bx:], 16, e.recY[(y+by)*e.yStride+x+bx:], e.yStride)
+	}
+
+	// Chroma, both planes with the same mode
+	x, y = mbx*8, mby*8
+	mb.uvMode = bestChromaMode(e, x, y, pred[:64])
+	for p, planes := range [2][2][]uint8{{e.srcU, e.recU}, {e.srcV, e.recV}} {
+		src, rec := planes[0], planes[1]
+		predict(pred[:64], rec, e.uvStride, x, y, 8, mb.uvMode)
+		for n := 0; n < 4; n++ {
+			bx, by := (n%2)*4, (n/2)*4
+			var c [16]int32
+			forwardDCT(src[(y+by)*e.uvStride+x+bx:], e.uvStride, pred[by*8+bx:], 8, &c)
+			levels := &mb.uv[p*4+n]
+			for i := range c {
+				levels[i] = quantize(c[i], e.q.uv[min(i, 1)], i == 0)
+				c[i] = int32(levels[i]) * e.q.uv[min(i, 1)]
+			}
+			inverseDCT(&c, pred[by*8+bx:], 8, rec[(y+by)*e.uvStride+x+bx:], e.uvStride)
+		}
+	}
+
+	mb.skip = mb.y2 == [16]int16{} && mb.y == [16][16]int16{} && mb.uv == [8][16]int16{}
+}
+
+// availableModes lists the modes that can predict a block: those needing the
+// row above or the column on the left are skipped on the frame's edges,
+// where they would only see the decoder's fixed border values
+func availableModes(x, y int) []uint8 {
+	modes := []uint8{predDC}
+	if y > 0 {
+		modes = append(modes, predVE)
+	}
+	if x > 0 {
+		modes = append(modes, predHE)
+	}
+	if x > 0 && y > 0 {
+		modes = append(modes, predTM)
+	}
+	return modes
+}
+
+// bestMode returns the mode whose prediction of the n x n block at (x, y)
+// is closest to the source
+func bestMode(src, rec []uint8, stride, x, y, n int, pred []uint8) uint8 {
+	best, bestErr := uint8(predDC), -1
+	for _, mode := range availableModes(x, y) {
+		predict(pred, rec, stride, x, y, n, mode)
+		if err := sse(src[y*stride+x:], stride, pred, n); bestErr < 0 || err < bestErr {
+			best, bestErr = mode, err
+		}
+	}
+	return best
+}
+
+// bestChromaMode is bestMode for both chroma planes together
+func bestChromaMode(e *vp8Encoder, x, y int, pred []uint8) uint8 {
+	best, bestErr := uint8(predDC), -1
+	for _, mode := range availableModes(x, y) {
+		predict(pred, e.recU, e.uvStride, x, y, 8, mode)
+		err := sse(e.srcU[y*e.uvStride+x:], e.uvStride, pred, 8)
+		predict(pred, e.recV, e.uvStride, x, y, 8, mode)
+		err += sse(e.srcV[y*e.uvStride+x:], e.uvStride, pred, 8)
+		if bestErr < 0 || err < bestErr {
+			best, bestErr = mode, err
+		}
+	}
+	return best
+}
+
+// sse is the sum of squared differences between a block and its prediction
+func sse(src []uint8, stride int, pred []uint8, n int) int {
+	sum := 0
+	for j := 0; j < n; j++ {
+		for i := 0; i < n; i++ {
+			d := int(src[j*stride+i]) - int(pred[j*n+i])
+			sum += d * d
+		}
+	}
+	return sum
+}
+
+// predict fills pred with the mode's prediction of the n x n block at (x, y)
+// from the reconstructed pixels above and on the left (section 12.2)
+func predict(pred, rec []uint8, stride, x, y, n int, mode uint8) {
+	top := func(i int) int32 { return int32(rec[(y-1)*stride+x+i]) }
+	left := func(j int) int32 { return int32(rec[(y+j)*stride+x-1]) }
+
+	switch mode {
+	case predVE:
+		for j := 0; j < n; j++ {
+			copy(pred[j*n:j*n+n], rec[(y-1)*stride+x:])
+		}
+	case predHE:
+		for j := 0; j < n; j++ {
+			v := uint8(left(j))
+			for i := 0; i < n; i++ {
+				pred[j*n+i] = v
+			}
+		}
+	case predTM:
+		corner := int32(rec[(y-1)*stride+x-1])
+		for j := 0; j < n; j++ {
+			for i := 0; i < n; i++ {
+				pred[j*n+i] = clip8(left(j) + top(i) - corner)
+			}
+		}
+	default:
+		// DC: the average of the available borders, 128 with neither
+		var sum, count int32
+		if y > 0 {
+			for i := 0; i < n; i++ {
+				sum += top(i)
+			}
+			count += int32(n)
+		}
+		if x > 0 {
+			for j := 0; j < n; j++ {
+				sum += left(j)
+			}
+			count += int32(n)
+		}
+		v := uint8(128)
+		if count > 0 {
+			v = uint8((sum + count/2) / count)
+		}
+		for i := range pred[:n*n] {
+			pred[i] = v
+		}
+	}
+}
+
+// forwardDCT transforms the difference between a 4x4 block and its
+// prediction (libwebp's integer approximation)
+func forwardDCT(src []uint8, srcStride int, pred []uint8, predStride int, out *[16]int32) {
+	var tmp [16]int32
+	for i := 0; i < 4; i++ {
+		s, p := src[i*srcStride:], pred[i*predStride:]
+		d0 := int32(s[0]) - int32(p[0])
+		d1 := int32(s[1]) - int32(p[1])
+		d2 := int32(s[2]) - int32(p[2])
+		d3 := int32(s[3]) - int32(p[3])
+		a0, a1, a2, a3 := d0+d3, d1+d2, d1-d2, d0-d3
+		tmp[i*4+0] = (a0 + a1) * 8
+		tmp[i*4+1] = (a2*2217 + a3*5352 + 1812) >> 9
+		tmp[i*4+2] = (a0 - a1) * 8
+		tmp[i*4+3] = (a3*2217 - a2*5352 + 937) >> 9
+	}
+	for i := 0; i < 4; i++ {
+		a0 := tmp[0+i] + tmp[12+i]
+		a1 := tmp[4+i] + tmp[8+i]
+		a2 := tmp[4+i] - tmp[8+i]
+		a3 := tmp[0+i] - tmp[12+i]
+		out[0+i] = (a0 + a1 + 7) >> 4
+		out[4+i] = (a2*2217 + a3*5352 + 12000) >> 16
+		if a3 != 0 {
+			out[4+i]++
+		}
+		out[8+i] = (a0 - a1 + 7) >> 4
+		out[12+i] = (a3*2217 - a2*5352 + 51000) >> 16
+	}
+}
+
+// forwardWHT transforms the DC coefficients of the 16 luma blocks
+func forwardWHT(in, out *[16]int32) {
+	var tmp [16]int32
+	for i := 0; i < 4; i++ {
+		r := in[i*4:]
+		a0, a1 := r[0]+r[2], r[1]+r[3]
+		a2, a3 := r[1]-r[3], r[0]-r[2]
+		tmp[i*4+0] = a0 + a1
+		tmp[i*4+1] = a3 + a2
+		tmp[i*4+2] = a3 - a2
+		tmp[i*4+3] = a0 - a1
+	}
+	for i := 0; i < 4; i++ {
+		a0, a1 := tmp[0+i]+tmp[8+i], tmp[4+i]+tmp[12+i]
+		a2, a3 := tmp[4+i]-tmp[12+i], tmp[0+i]-tmp[8+i]
+		out[0+i] = (a0 + a1) >> 1
+		out[4+i] = (a3 + a2) >> 1
+		out[8+i] = (a3 - a2) >> 1
+		out[12+i] = (a0 - a1) >> 1
+	}
+}
+
+// inverseWHT is the decoder's inverse of forwardWHT (section 14.3)
+func inverseWHT(in, out *[16]int32) {
+	var m [16]int32
+	for i := 0; i < 4; i++ {
+		a0 := in[0+i] + in[12+i]
+		a1 := in[4+i] + in[8+i]
+		a2 := in[4+i] - in[8+i]
+		a3 := in[0+i] - in[12+i]
+		m[0+i] = a0 + a1
+		m[8+i] = a0 - a1
+		m[4+i] = a3 + a2
+		m[12+i] = a3 - a2
+	}
+	for i := 0; i < 4; i++ {
+		dc := m[0+i*4] + 3
+		a0 := dc + m[3+i*4]
+		a1 := m[1+i*4] + m[2+i*4]
+		a2 := m[1+i*4] - m[2+i*4]
+		a3 := dc - m[3+i*4]
+		out[i*4+0] = (a0 + a1) >> 3
+		out[i*4+1] = (a3 + a2) >> 3
+		out[i*4+2] = (a0 - a1) >> 3
+		out[i*4+3] = (a3 - a2) >> 3
+	}
+}
+
+// inverseDCT adds the decoder's inverse transform of a 4x4 block's
+// dequantized coefficients to its prediction (section 14.4)
+func inverseDCT(in *[16]int32, pred []uint8, predStride int, dst []uint8, dstStride int) {
+	const (
+		c1 = 85627 // 65536 * cos(pi/8) * sqrt(2)
+		c2 = 35468 // 65536 * sin(pi/8) * sqrt(2)
+	)
+	var m [4][4]int32
+	for i := 0; i < 4; i++ {
+		a := in[i] + in[8+i]
+		b := in[i] - in[8+i]
+		c := (in[4+i]*c2)>>16 - (in[12+i]*c1)>>16
+		d := (in[4+i]*c1)>>16 + (in[12+i]*c2)>>16
+		m[i] = [4]int32{a + d, b + c, b - c, a - d}
+	}
+	for j := 0; j < 4; j++ {
+		dc := m[0][j] + 4
+		a := dc + m[2][j]
+		b := dc - m[2][j]
+		c := (m[1][j]*c2)>>16 - (m[3][j]*c1)>>16
+		d := (m[1][j]*c1)>>16 + (m[3][j]*c2)>>16
+		p, out := pred[j*predStride:], dst[j*dstStride:]
+		out[0] = clip8(int32(p[0]) + (a+d)>>3)
+		out[1] = clip8(int32(p[1]) + (b+c)>>3)
+		out[2] = clip8(int32(p[2]) + (b-c)>>3)
+		out[3] = clip8(int32(p[3]) + (a-d)>>3)
+	}
+}
+
+// maxLevel is the largest quantized coefficient a token can code
+const maxLevel = 2048
+
+// quantize divides a coefficient by its quantizer step, rounding AC
+// coefficients towards zero a little more to favor zeros
+func quantize(c, step int32, dc bool) int16 {
+	bias := step / 3
+	if dc {
+		bias = step / 2
+	}
+	level := (abs32(c) + bias) / step
+	level = min(level, maxLevel)
+	if c < 0 {
+		level = -level
+	}
+	return int16(level)
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// frame writes the frame: its header, the first partition with the frame
+// settings and modes, then the coefficient partition (section 9)
+func (e *vp8Encoder) frame() []byte {
+	skipped := 0
+	for i := range e.mbs {
+		if e.mbs[i].skip {
+			skipped++
+		}
+	}
+	skipProb := uint8(min(max(255*(len(e.mbs)-skipped)/len(e.mbs), 1), 255))
+
+	first := newBoolEncoder()
+	first.putLiteral(0, 1) // Color space
+	first.putLiteral(0, 1) // Clamping required
+	first.putLiteral(0, 1) // No segmentation
+	first.putLiteral(0, 1) // Normal loop filter
+	first.putLiteral(uint32(e.filterLevel), 6)
+	first.putLiteral(0, 3) // Sharpness
+	first.putLiteral(0, 1) // No loop filter deltas
+	first.putLiteral(0, 2) // One coefficient partition
+	first.putLiteral(uint32(e.qIndex), 7)
+	for i := 0; i < 5; i++ {
+		first.putLiteral(0, 1) // No quantizer delta
+	}
+	first.putLiteral(0, 1) // Refresh entropy probabilities
+	for i := range coeffUpdateProbs {
+		for j := range coeffUpdateProbs[i] {
+			for k := range coeffUpdateProbs[i][j] {
+				for _, p := range coeffUpdateProbs[i][j][k] {
+					first.putBit(false, p) // Keep the default probabilities
+				}
+			}
+		}
+	}
+	first.putLiteral(1, 1) // Macroblocks can be skipped
+	first.putLiteral(uint32(skipProb), 8)
+
+	tokens := newBoolEncoder()
+	var leftNz, leftY2 uint8
+	upNz, upY2 := make([]uint8, e.mbw), make([]uint8, e.mbw)
+	for mby := 0; mby < e.mbh; mby++ {
+		leftNz, leftY2 = 0, 0
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			mb := &e.mbs[mby*e.mbw+mbx]
+			first.putBit(mb.skip, skipProb)
+			first.putBit(true, 145) // 16x16 luma prediction
+			switch mb.yMode {
+			case predDC:
+				first.putBit(false, 156)
+				first.putBit(false, 163)
+			case predVE:
+				first.putBit(false, 156)
+				first.putBit(true, 163)
+			case predHE:
+				first.putBit(true, 156)
+				first.putBit(false, 128)
+			case predTM:
+				first.putBit(true, 156)
+				first.putBit(true, 128)
+			}
+			first.putBit(mb.uvMode != predDC, 142)
+			if mb.uvMode != predDC {
+				first.putBit(mb.uvMode != predVE, 114)
+				if mb.uvMode != predVE {
+					first.putBit(mb.uvMode == predTM, 183)
+				}
+			}
+
+			if mb.skip {
+				leftNz, upNz[mbx], leftY2, upY2[mbx] = 0, 0, 0, 0
+				continue
+			}
+			nz := tokens.putBlock(&mb.y2, planeY2, 0, leftY2+upY2[mbx])
+			leftY2, upY2[mbx] = nz, nz
+			leftNz, upNz[mbx] = e.putResiduals(tokens, mb, leftNz, upNz[mbx])
+		}
+	}
+
+	firstData, tokenData := first.flush(), tokens.flush()
+	data := make([]byte, 10, 10+len(firstData)+len(tokenData))
+	tag := uint32(len(firstData))<<5 | 1<<4 // Key frame, version 0, shown
+	data[0], data[1], data[2] = byte(tag), byte(tag>>8), byte(tag>>16)
+	copy(data[3:], []byte{0x9d, 0x01, 0x2a})
+	binary.LittleEndian.PutUint16(data[6:], uint16(e.width))
+	binary.LittleEndian.PutUint16(data[8:], uint16(e.height))
+	data = append(data, firstData...)
+	return append(data, tokenData...)
+}
+
+// putResiduals codes the luma and chroma blocks of a macroblock. The
+// non-zero flags of the blocks along its left and bottom edges, packed in 8
+// bits (4 luma, then 2 U and 2 V), give the context of the next macroblocks.
+func (e *vp8Encoder) putResiduals(enc *boolEncoder, mb *macroblock, left, up uint8) (uint8, uint8) {
+	bit := func(flags uint8, i int) uint8 { return flags >> i & 1 }
+	var newLeft, newUp uint8
+
+	for y := 0; y < 4; y++ {
+		nz := bit(left, y)
+		for x := 0; x < 4; x++ {
+			above := bit(up, x)
+			if y > 0 {
+				above = bit(newUp, x)
+			}
+			nz = enc.putBlock(&mb.y[y*4+x], planeYAfterY2, 1, nz+above)
+			newUp = newUp&^(1<<x) | nz<<x
+		}
+		newLeft |= nz << y
+	}
+	for c := 4; c < 8; c += 2 {
+		for y := 0; y < 2; y++ {
+			nz := bit(left, c+y)
+			for x := 0; x < 2; x++ {
+				above := bit(up, c+x)
+				if y > 0 {
+					above = bit(newUp, c+x)
+				}
+				nz = enc.putBlock(&mb.uv[(c-4)*2+y*2+x], planeUV, 0, nz+above)
+				newUp = newUp&^(1<<(c+x)) | nz<<(c+x)
+			}
+			newLeft |= nz << (c + y)
+		}
+	}
+	return newLeft, newUp
+}
+
+// putBlock codes the coefficients of a block from first on, in zigzag order
+// (section 13), and returns 1 if any was coded
+func (e *boolEncoder) putBlock(levels *[16]int16, plane, first int, ctx uint8) uint8 {
+	last := -1
+	for i := 15; i >= first; i-- {
+		if levels[zigzag[i]] != 0 {
+			last = i
+			break
+		}
+	}
+
+	probs := &defaultCoeffProbs[plane]
+	p := &probs[coeffBands[first]][ctx]
+	e.putBit(last >= 0, p[0])
+	if last < 0 {
+		return 0
+	}
+
+	for i := first; i <= last; i++ {
+		level := levels[zigzag[i]]
+		v := int(level)
+		if v < 0 {
+			v = -v
+		}
+		if v == 0 {
+			e.putBit(false, p[1])
+			p = &probs[coeffBands[i+1]][0]
+			continue
+		}
+		e.putBit(true, p[1])
+		e.putLevel(v, p)
+		e.putBit(level < 0, 128)
+
+		ctx := 2
+		if v == 1 {
+			ctx = 1
+		}
+		p = &probs[coeffBands[i+1]][ctx]
+		if i < 15 {
+			e.putBit(i < last, p[0]) // End of block
+		}
+	}
+	return 1
+}
+
+// putLevel codes the token of a non-zero coefficient magnitude and its extra
+// bits
+func (e *boolEncoder) putLevel(v int, p *[numProbs]uint8) {
+	if v == 1 {
+		e.putBit(false, p[2])
+		return
+	}
+	e.putBit(true, p[2])
+	switch {
+	case v <= 4:
+		e.putBit(false, p[3])
+		e.putBit(v != 2, p[4])
+		if v != 2 {
+			e.putBit(v == 4, p[5])
+		}
+	case v <= 6: // DCT_CAT1
+		e.putBit(true, p[3])
+		e.putBit(false, p[6])
+		e.putBit(false, p[7])
+		e.putBit(v == 6, 159)
+	case v <= 10: // DCT_CAT2
+		e.putBit(true, p[3])
+		e.putBit(false, p[6])
+		e.putBit(true, p[7])
+		e.putBit((v-7)&2 != 0, 165)
+		e.putBit((v-7)&1 != 0, 145)
+	default: // DCT_CAT3 to DCT_CAT6
+		e.putBit(true, p[3])
+		e.putBit(true, p[6])
+		cat := 0
+		for cat < 3 && v >= 3+(8<<(cat+1)) {
+			cat++
+		}
+		e.putBit(cat >= 2, p[8])
+		e.putBit(cat&1 != 0, p[9+cat/2])
+		extra := v - (3 + 8<<cat)
+		bits := catProbs[cat]
+		for i, prob := range bits {
+			e.putBit(extra>>(len(bits)-1-i)&1 != 0, prob)
+		}
+	}
+}
+
+// boolEncoder is the boolean entropy encoder of VP8 (section 7.3)
+type boolEncoder struct {
+	buf      []byte
+	rng      uint32
+	bottom   uint32
+	bitCount int
+}
+
+func newBoolEncoder() *boolEncoder {
+	return &boolEncoder{rng: 255, bitCount: 24}
+}
+
+// putBit codes a bit whose probability of being false is prob/256
+func (e *boolEncoder) putBit(bit bool, prob uint8) {
+	split := 1 + ((e.rng-1)*uint32(prob))>>8
+	if bit {
+		e.bottom += split
+		e.rng -= split
+	} else {
+		e.rng = split
+	}
+	for e.rng < 128 {
+		e.rng <<= 1
+		if e.bottom&(1<<31) != 0 {
+			e.carry()
+		}
+		e.bottom <<= 1
+		e.bitCount--
+		if e.bitCount == 0 {
+			e.buf = append(e.buf, byte(e.bottom>>24))
+			e.bottom &= 1<<24 - 1
+			e.bitCount = 8
+		}
+	}
+}
+
+// putLiteral codes the n low bits of v with even probabilities, most
+// significant first
+func (e *boolEncoder) putLiteral(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		e.putBit(v>>i&1 != 0, 128)
+	}
+}
+
+// carry propagates a carry into the bytes already written
+func (e *boolEncoder) carry() {
+	i := len(e.buf) - 1
+	for ; i >= 0 && e.buf[i] == 255; i-- {
+		e.buf[i] = 0
+	}
+	if i >= 0 {
+		e.buf[i]++
+	}
+}
+
+// flush writes out the remaining bits and returns the coded data
+func (e *boolEncoder) flush() []byte {
+	c := e.bitCount
+	v := e.bottom
+	if v&(1<<(32-c)) != 0 {
+		e.carry()
+	}
+	v <<= c & 7
+	for c >>= 3; c > 0; c-- {
+		v <<= 8
+	}
+	for i := 0; i < 4; i++ {
+		e.buf = append(e.buf, byte(v>>24))
+		v <<= 8
+	}
+	return e.buf
+}
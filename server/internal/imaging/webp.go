@@ -0,0 +1,81 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+)
+
+// EncodeWebP encodes img as a lossy WebP. quality goes from 0 (smallest) to
+// 100 (best). WebP without an alpha chunk is opaque, so transparent pixels
+// are stored as black.
+func EncodeWebP(img image.Image, quality int) ([]byte, error) {
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+
+	frame, err := encodeVP8(rgba, qualityToIndex(quality))
+	if err != nil {
+		return nil, err
+	}
+
+	// A simple (lossy) WebP file is a RIFF container with a single VP8 chunk
+	size := len(frame) + len(frame)%2
+	out := make([]byte, 0, 20+size)
+	out = append(out, "RIFF"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(4+8+size))
+	out = append(out, "WEBPVP8 "...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(frame)))
+	out = append(out, frame...)
+	if len(frame)%2 == 1 {
+		out = append(out, 0) // Chunks are padded to an even size
+	}
+	return out, nil
+}
+
+// qualityToIndex maps a quality from 0 to 100 to a VP8 quantizer index,
+// from 127 (coarsest) to 0
+func qualityToIndex(quality int) int {
+	quality = min(max(quality, 0), 100)
+	return (100 - quality) * 127 / 100
+}
+
+// ToWebP converts a PNG or JPEG image to WebP at quality (see EncodeWebP).
+// It returns nil, keeping the image as it is, when it is in another format,
+// has transparency, is too large for WebP or wouldn't get smaller.
+func ToWebP(data []byte, quality int) ([]byte, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "png" && format != "jpeg") {
+		return nil, nil
+	}
+	if cfg.Width > maxVP8Dimension || cfg.Height > maxVP8Dimension {
+		return nil, nil
+	}
+
+	img, err := decodeOriented(data, format)
+	if err != nil {
+		return nil, err
+	}
+	if !opaque(img) {
+		return nil, nil
+	}
+
+	out, err := EncodeWebP(img, quality)
+	if err != nil || len(out) >= len(data) {
+		return nil, err
+	}
+	return out, nil
+}
+
+// opaque reports whether every pixel of img is fully opaque
+func opaque(img *image.RGBA) bool {
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] != 0xff {
+			return false
+		}
+	}
+	return true
+}
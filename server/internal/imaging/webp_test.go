@@ -0,0 +1,123 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+// testPicture draws gradients, flat areas and sharp edges, with a size that
+// isn't a multiple of the 16 pixel macroblocks
+func testPicture(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{uint8(x * 255 / width), uint8(y * 255 / height), 160, 255}
+			if x > width/2 && y > height/2 {
+				c = color.RGBA{240, 240, 240, 255} // Flat
+			}
+			if (x/6+y/6)%5 == 0 && x < width/2 {
+				c = color.RGBA{20, 20, 20, 255} // Stripes
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// toRGB converts decoded YCbCr with libwebp's (limited range) BT.601
+// coefficients; image/color's YCbCr model is full range
+func toRGB(y, cb, cr uint8) (int, int, int) {
+	mult := func(v uint8, k int) int { return int(v) * k >> 8 }
+	clip := func(v int) int { return min(max(v>>6, 0), 255) }
+	return clip(mult(y, 19077) + mult(cr, 26149) - 14234),
+		clip(mult(y, 19077) - mult(cb, 6419) - mult(cr, 13320) + 8708),
+		clip(mult(y, 19077) + mult(cb, 33050) - 17685)
+}
+
+// psnr compares a decoded WebP to its source
+func psnr(t *testing.T, src *image.RGBA, data []byte) float64 {
+	t.Helper()
+	img, err := webp.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	ycc := img.(*image.YCbCr)
+	if ycc.Bounds() != src.Bounds() {
+		t.Fatalf("decoded %v, want %v", ycc.Bounds(), src.Bounds())
+	}
+
+	var sum float64
+	for y := 0; y < src.Bounds().Dy(); y++ {
+		for x := 0; x < src.Bounds().Dx(); x++ {
+			r, g, b := toRGB(ycc.Y[ycc.YOffset(x, y)], ycc.Cb[ycc.COffset(x, y)], ycc.Cr[ycc.COffset(x, y)])
+			s := src.RGBAAt(x, y)
+			for _, d := range []int{r - int(s.R), g - int(s.G), b - int(s.B)} {
+				sum += float64(d * d)
+			}
+		}
+	}
+	mse := sum / float64(3*src.Bounds().Dx()*src.Bounds().Dy())
+	return 10 * math.Log10(255*255/mse)
+}
+
+func TestEncodeWebP(t *testing.T) {
+	src := testPicture(203, 117)
+	var sizes []int
+	for _, tc := range []struct {
+		quality int
+		psnr    float64 // Minimum
+	}{{20, 23}, {80, 33}, {100, 40}} {
+		data, err := EncodeWebP(src, tc.quality)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p := psnr(t, src, data); p < tc.psnr {
+			t.Errorf("quality %d: PSNR %.1f dB, want at least %.0f", tc.quality, p, tc.psnr)
+		}
+		sizes = append(sizes, len(data))
+	}
+	if sizes[0] >= sizes[1] || sizes[1] >= sizes[2] {
+		t.Errorf("expected the size to grow with the quality, got %v", sizes)
+	}
+
+	// Smaller than a macroblock
+	tiny := testPicture(1, 1)
+	data, err := EncodeWebP(tiny, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	psnr(t, tiny, data)
+}
+
+func TestToWebP(t *testing.T) {
+	// Noise, like in photos, which PNG compresses poorly
+	src := testPicture(320, 200)
+	seed := uint32(1)
+	for i := range src.Pix {
+		if i%4 != 3 {
+			seed = seed*1664525 + 1013904223
+			src.Pix[i] = uint8(min(max(int(src.Pix[i])+int(seed>>28)-8, 0), 255))
+		}
+	}
+	data := encodePNG(t, src)
+	out, err := ToWebP(data, 80)
+	if err != nil || out == nil {
+		t.Fatalf("expected a WebP, got %v", err)
+	}
+	if len(out) >= len(data) {
+		t.Errorf("WebP is %d bytes, PNG %d", len(out), len(data))
+	}
+	psnr(t, src, out)
+
+	transparent := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for _, data := range [][]byte{encodePNG(t, transparent), []byte("GIF89a"), nil} {
+		if out, err := ToWebP(data, 80); out != nil || err != nil {
+			t.Errorf("expected the data to be kept, got %d bytes, %v", len(out), err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Package lang guesses the language of a text, as an ISO 639-1 code.
+//
+// Texts in a script used by one language (Japanese kana, Hangul, Greek, ...)
+// are recognized by their script; Latin texts by their most common words,
+// which takes a sentence or two to be reliable. Detection gives up rather
+// than guess: an empty code means unknown.
+package lang
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minHits is the number of common words a Latin text needs to be detected
+const minHits = 3
+
+// stopwords are frequent words of each language written in Latin script,
+// picked to tell them apart
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "that", "it", "for", "was", "with", "on", "as", "are", "this", "be", "by", "not", "you", "have", "from", "or", "which", "but", "they", "were", "has", "been", "their", "would", "an"},
+	"fr": {"le", "la", "les", "des", "est", "et", "une", "un", "du", "que", "qui", "dans", "pour", "pas", "sur", "ce", "il", "au", "avec", "sont", "plus", "ne", "se", "nous", "vous", "cette", "aux", "par", "mais", "été"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "ein", "eine", "zu", "den", "mit", "von", "sich", "des", "auf", "für", "im", "dem", "auch", "es", "wird", "werden", "sind", "nach", "bei", "oder", "wie", "aus", "wir", "ich"},
+	"es": {"el", "la", "los", "las", "de", "que", "y", "en", "un", "una", "es", "por", "con", "para", "del", "se", "no", "lo", "como", "más", "pero", "sus", "al", "fue", "este", "esta", "son", "ha", "también", "muy"},
+	"it": {"il", "la", "di", "che", "e", "un", "una", "per", "non", "è", "sono", "della", "del", "con", "gli", "le", "si", "nel", "anche", "questo", "come", "più", "ma", "alla", "dei", "delle", "ha", "da", "loro", "essere"},
+	"pt": {"o", "a", "os", "as", "de", "que", "e", "um", "uma", "é", "não", "do", "da", "em", "para", "com", "por", "se", "mais", "na", "no", "dos", "das", "como", "mas", "foi", "ao", "ele", "isso", "são"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "zijn", "met", "voor", "die", "er", "ook", "maar", "als", "aan", "bij", "om", "wordt", "worden", "door", "nog", "naar", "dit", "ze", "wel", "hij"},
+	"sv": {"och", "att", "det", "som", "en", "är", "på", "för", "av", "med", "den", "till", "inte", "har", "om", "ett", "var", "jag", "men", "så", "de", "från", "kan", "sig", "vi", "eller", "också", "när", "efter", "hade"},
+	"pl": {"i", "w", "na", "się", "nie", "z", "do", "to", "że", "jest", "jak", "o", "ale", "po", "co", "tak", "od", "za", "przez", "są", "czy", "jego", "dla", "tylko", "już", "być", "może", "które", "był", "oraz"},
+}
+
+// languagesOf maps each stopword to the languages using it
+var languagesOf = func() map[string][]string {
+	m := map[string][]string{}
+	for code, words := range stopwords {
+		for _, w := range words {
+			m[w] = append(m[w], code)
+		}
+	}
+	return m
+}()
+
+// scripts are the scripts identifying a language on their own
+var scripts = []struct {
+	table *unicode.RangeTable
+	code  string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+	{unicode.Georgian, "ka"},
+	{unicode.Armenian, "hy"},
+}
+
+// Detect returns the language of text, or "" when it can't tell
+func Detect(text string) string {
+	var latin, cyrillic, ukrainian, han, kana, total int
+	other := map[string]int{}
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		total++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+			if strings.ContainsRune("іїєґІЇЄҐ", r) {
+				ukrainian++
+			}
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		default:
+			for _, s := range scripts {
+				if unicode.Is(s.table, r) {
+					other[s.code]++
+					break
+				}
+			}
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+
+	// A script making up most letters decides
+	switch {
+	case kana > 0 && (kana+han)*2 > total:
+		return "ja"
+	case han*2 > total:
+		return "zh"
+	case cyrillic*2 > total:
+		if ukrainian > 0 {
+			return "uk"
+		}
+		return "ru"
+	}
+	for code, n := range other {
+		if n*2 > total {
+			return code
+		}
+	}
+	if latin*2 <= total {
+		return ""
+	}
+	return detectLatin(text)
+}
+
+// detectLatin counts the common words of each language in text; the one
+// with clearly more than the others wins
+func detectLatin(text string) string {
+	hits := map[string]int{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		for _, code := range languagesOf[word] {
+			hits[code]++
+		}
+	}
+
+	best, first := "", 0
+	for code, n := range hits {
+		if n > first || (n == first && code < best) {
+			best, first = code, n
+		}
+	}
+	second := 0
+	for code, n := range hits {
+		if code != best && n > second {
+			second = n
+		}
+	}
+	// Require a margin: close languages share many words
+	if first < minHits || first*4 < second*5 {
+		return ""
+	}
+	return best
+}
+
+// Normalize returns the primary language of a language tag such as "en-US"
+// or "pt_BR", or "" when it isn't one
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if len(tag) < 2 || len(tag) > 3 {
+		return ""
+	}
+	for _, r := range tag {
+		if r < 'a' || r > 'z' {
+			return ""
+		}
+	}
+	return tag
+}
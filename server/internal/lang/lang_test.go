@@ -0,0 +1,49 @@
+package lang
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct{ text, want string }{
+		{"The quick brown fox jumps over the lazy dog. It was the best of times and it was the worst of times.", "en"},
+		{"Le petit prince est un livre qui a été écrit par Antoine de Saint-Exupéry. Il raconte l'histoire d'un enfant.", "fr"},
+		{"Der schnelle braune Fuchs springt über den faulen Hund. Das ist nicht so einfach, wie es aussieht.", "de"},
+		{"El rápido zorro marrón salta sobre el perro perezoso. Es una historia que se cuenta en muchos libros.", "es"},
+		{"La volpe veloce salta sopra il cane pigro. Questo è un libro che non ha bisogno di introduzione.", "it"},
+		{"A rápida raposa marrom pula sobre o cão preguiçoso. Não é uma história que se conta todos os dias.", "pt"},
+		{"De snelle bruine vos springt over de luie hond. Het is niet zo eenvoudig als het lijkt, maar wel leuk.", "nl"},
+		{"Den snabba bruna räven hoppar över den lata hunden. Det är inte så lätt som det ser ut och jag vet det.", "sv"},
+		{"Szybki brązowy lis przeskakuje nad leniwym psem. To nie jest tak proste, jak się wydaje, ale jest fajne.", "pl"},
+		{"東京は日本の首都です。ここにはたくさんの人が住んでいます。", "ja"},
+		{"北京是中国的首都。", "zh"},
+		{"Москва — столица России.", "ru"},
+		{"Київ — столиця України, і це місто є великим.", "uk"},
+		{"서울은 한국의 수도입니다", "ko"},
+		{"Η Αθήνα είναι η πρωτεύουσα της Ελλάδας.", "el"},
+		{"Hello world", ""}, // Too short to tell
+		{"1234 !!", ""},
+		{"", ""},
+	} {
+		if got := Detect(tc.text); got != tc.want {
+			t.Errorf("Detect(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for tag, want := range map[string]string{
+		"en":      "en",
+		"en-US":   "en",
+		"pt_BR":   "pt",
+		" FR ":    "fr",
+		"zh-Hant": "zh",
+		"":        "",
+		"english": "",
+		"x":       "",
+		"12-34":   "",
+		"fil":     "fil",
+	} {
+		if got := Normalize(tag); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Package logging sets up the structured logger (log/slog) the server and
+// the CLI write through, adapts it to Buffalo's logger, and writes audit
+// events: one record per security-relevant operation, with the same
+// attributes whatever the operation, so they can be filtered and shipped.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"server/internal/config"
+
+	"github.com/gobuffalo/logger"
+)
+
+// AuditMessage is the message of audit records
+const AuditMessage = "audit"
+
+// New returns a logger writing cfg.Format records (json or text) of
+// cfg.Level and above to w
+func New(w io.Writer, cfg config.LoggingConfig) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: ParseLevel(cfg.Level)}
+	if cfg.Format == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// Setup makes a logger writing to stderr the default. Lines written with the
+// log package go through it too, as info records.
+func Setup(cfg config.LoggingConfig) *slog.Logger {
+	l := New(os.Stderr, cfg)
+	slog.SetDefault(l)
+	return l
+}
+
+// CLI returns a logger for commands: text records without time on stdout,
+// next to the command's output
+func CLI() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+// ParseLevel returns the level named s (debug, info, warn or error), info
+// when it names none
+func ParseLevel(s string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// Audit writes an audit event: action (clip.create, auth.login, ...) and the
+// attributes identifying who did it on what, user_id, clip_id and ip among
+// them. Events are info records of message AuditMessage.
+func Audit(ctx context.Context, l *slog.Logger, action string, args ...any) {
+	l.Log(ctx, slog.LevelInfo, AuditMessage, append([]any{"action", action}, args...)...)
+}
+
+// Buffalo adapts a logger to Buffalo's, which the request logging middleware
+// and c.Logger() use
+func Buffalo(l *slog.Logger) logger.FieldLogger {
+	return buffaloLogger{l}
+}
+
+type buffaloLogger struct {
+	l *slog.Logger
+}
+
+func (b buffaloLogger) WithField(key string, value interface{}) logger.FieldLogger {
+	return buffaloLogger{b.l.With(key, value)}
+}
+
+func (b buffaloLogger) WithFields(fields map[string]interface{}) logger.FieldLogger {
+	args := make([]any, 0, 2*len(fields))
+	for key, value := range fields {
+		args = append(args, key, value)
+	}
+	return buffaloLogger{b.l.With(args...)}
+}
+
+func (b buffaloLogger) log(level slog.Level, msg string) {
+	b.l.Log(context.Background(), level, strings.TrimSuffix(msg, "\n"))
+}
+
+func (b buffaloLogger) Debugf(format string, args ...interface{}) {
+	b.log(slog.LevelDebug, fmt.Sprintf(format, args...))
+}
+func (b buffaloLogger) Infof(format string, args ...interface{}) {
+	b.log(slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+func (b buffaloLogger) Printf(format string, args ...interface{}) {
+	b.log(slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+func (b buffaloLogger) Warnf(format string, args ...interface{}) {
+	b.log(slog.LevelWarn, fmt.Sprintf(format, args...))
+}
+func (b buffaloLogger) Errorf(format string, args ...interface{}) {
+	b.log(slog.LevelError, fmt.Sprintf(format, args...))
+}
+func (b buffaloLogger) Fatalf(format string, args ...interface{}) {
+	b.log(slog.LevelError, fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+func (b buffaloLogger) Debug(args ...interface{}) { b.log(slog.LevelDebug, fmt.Sprint(args...)) }
+func (b buffaloLogger) Info(args ...interface{})  { b.log(slog.LevelInfo, fmt.Sprint(args...)) }
+func (b buffaloLogger) Warn(args ...interface{})  { b.log(slog.LevelWarn, fmt.Sprint(args...)) }
+func (b buffaloLogger) Error(args ...interface{}) { b.log(slog.LevelError, fmt.Sprint(args...)) }
+func (b buffaloLogger) Fatal(args ...interface{}) {
+	b.log(slog.LevelError, fmt.Sprint(args...))
+	os.Exit(1)
+}
+func (b buffaloLogger) Panic(args ...interface{}) {
+	msg := fmt.Sprint(args...)
+	b.log(slog.LevelError, msg)
+	panic(msg)
+}
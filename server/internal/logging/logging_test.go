@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"server/internal/config"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, config.LoggingConfig{Level: "warn"})
+	l.Info("dropped")
+	l.Warn("kept", "clip_id", "c1")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("not one JSON record: %q", buf.String())
+	}
+	if record["msg"] != "kept" || record["level"] != "WARN" || record["clip_id"] != "c1" {
+		t.Errorf("record = %v", record)
+	}
+
+	buf.Reset()
+	New(&buf, config.LoggingConfig{Format: "text"}).Info("plain", "n", 1)
+	if !strings.Contains(buf.String(), "msg=plain n=1") {
+		t.Errorf("text record = %q", buf.String())
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]slog.Level{
+		"debug": slog.LevelDebug, "WARN": slog.LevelWarn, "error": slog.LevelError,
+		"": slog.LevelInfo, "loud": slog.LevelInfo,
+	} {
+		if got := ParseLevel(s); got != want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestAudit(t *testing.T) {
+	var buf bytes.Buffer
+	Audit(context.Background(), New(&buf, config.LoggingConfig{Level: "info"}), "clip.delete", "user_id", "u1", "clip_id", "c1", "ip", "192.0.2.1")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]any{"msg": AuditMessage, "action": "clip.delete", "user_id": "u1", "clip_id": "c1", "ip": "192.0.2.1"} {
+		if record[key] != want {
+			t.Errorf("%s = %v, want %v", key, record[key], want)
+		}
+	}
+}
+
+func TestBuffalo(t *testing.T) {
+	var buf bytes.Buffer
+	l := Buffalo(New(&buf, config.LoggingConfig{}))
+	l.WithField("method", "GET").WithFields(map[string]interface{}{"status": 200}).Infof("/api/v1/clips\n")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["msg"] != "/api/v1/clips" || record["method"] != "GET" || record["status"] != float64(200) {
+		t.Errorf("record = %v", record)
+	}
+}
@@ -0,0 +1,148 @@
+// Package mailer sends outbound email through a configured SMTP relay.
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"server/internal/config"
+)
+
+// Attachment is a file attached to an outgoing message.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Message is an outgoing email.
+type Message struct {
+	To          string
+	Subject     string
+	Body        string
+	Attachments []Attachment
+}
+
+// Mailer sends messages via SMTP.
+type Mailer struct {
+	cfg config.SMTPConfig
+}
+
+// New creates a Mailer from SMTP configuration.
+func New(cfg config.SMTPConfig) *Mailer {
+	return &Mailer{cfg: cfg}
+}
+
+// Configured reports whether enough settings are present to send mail.
+func (m *Mailer) Configured() bool {
+	return m.cfg.Host != "" && m.cfg.From != ""
+}
+
+// Send delivers a message, using STARTTLS when the server supports it.
+func (m *Mailer) Send(msg Message) error {
+	if !m.Configured() {
+		return fmt.Errorf("SMTP is not configured")
+	}
+
+	body, err := buildMIME(m.cfg.From, msg)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	conn, err := net.DialTimeout("tcp", addr, 30*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.cfg.Host}); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+
+	if m.cfg.Username != "" {
+		auth := smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(m.cfg.From); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM rejected: %w", err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("SMTP RCPT TO rejected: %w", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP DATA failed: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// buildMIME encodes the message as multipart/mixed with base64 attachments
+func buildMIME(from string, msg Message) ([]byte, error) {
+	boundaryBytes := make([]byte, 16)
+	if _, err := rand.Read(boundaryBytes); err != nil {
+		return nil, err
+	}
+	boundary := hex.EncodeToString(boundaryBytes)
+
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("From: %s\r\n", from))
+	buf.WriteString(fmt.Sprintf("To: %s\r\n", msg.To))
+	buf.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject)))
+	buf.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary))
+
+	buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(msg.Body)
+	buf.WriteString("\r\n")
+
+	for _, att := range msg.Attachments {
+		buf.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+		buf.WriteString(fmt.Sprintf("Content-Type: %s\r\n", att.ContentType))
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+		buf.WriteString(fmt.Sprintf("Content-Disposition: attachment; filename=%q\r\n\r\n",
+			strings.ReplaceAll(att.Filename, `"`, "")))
+
+		encoded := base64.StdEncoding.EncodeToString(att.Data)
+		for len(encoded) > 76 {
+			buf.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+		buf.WriteString(encoded + "\r\n")
+	}
+
+	buf.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
+	return buf.Bytes(), nil
+}
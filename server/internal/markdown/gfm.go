@@ -0,0 +1,397 @@
+package markdown
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gobuffalo/github_flavored_markdown"
+	"github.com/sourcegraph/syntaxhighlight"
+)
+
+// gfm renders GitHub Flavored Markdown (tables, task lists) and handles
+// footnotes, math and the highlighting of languages GFM leaves plain around
+// it: they are replaced by placeholders before rendering and by their HTML
+// after, so the GFM sanitizer never sees (or strips) them.
+type gfm struct {
+	opts Options
+}
+
+func newGFM(opts Options) Renderer {
+	return &gfm{opts: opts}
+}
+
+// placeholders are the tokens of a render: plain words, so the markdown
+// renderer leaves them alone, with a random prefix, so a document can't
+// contain them
+type placeholders struct {
+	prefix   string
+	math     *regexp.Regexp
+	footnote *regexp.Regexp
+}
+
+func newPlaceholders() *placeholders {
+	b := make([]byte, 8)
+	rand.Read(b)
+	prefix := "MDX" + hex.EncodeToString(b)
+	return &placeholders{
+		prefix:   prefix,
+		math:     regexp.MustCompile(prefix + `MATH(\d+)XMD`),
+		footnote: regexp.MustCompile(prefix + `FOOT(\d+)XMD`),
+	}
+}
+
+func (p *placeholders) mathToken(i int) string {
+	return fmt.Sprintf("%sMATH%dXMD", p.prefix, i)
+}
+
+func (p *placeholders) footnoteToken(n int) string {
+	return fmt.Sprintf("%sFOOT%dXMD", p.prefix, n)
+}
+
+func (g *gfm) Render(src string) string {
+	var maths []mathSpan
+	var notes *footnotes
+	tokens := newPlaceholders()
+	src = mapText(src, func(text string) string {
+		if !g.opts.Enabled(Tables) {
+			text = disableTables(text)
+		}
+		if g.opts.Enabled(Math) {
+			text = extractMath(text, &maths, tokens)
+		}
+		return text
+	})
+	if g.opts.Enabled(Footnotes) {
+		src, notes = extractFootnotes(src, tokens)
+	}
+
+	out := string(github_flavored_markdown.Markdown([]byte(src)))
+
+	if !g.opts.Enabled(TaskLists) {
+		out = strings.NewReplacer(
+			`<input type="checkbox" checked="" disabled="">`, "[x]",
+			`<input type="checkbox" disabled="">`, "[ ]",
+		).Replace(out)
+	}
+	if g.opts.Enabled(Highlight) {
+		out = highlight(out)
+	} else {
+		out = unhighlight(out)
+	}
+	if len(maths) > 0 {
+		out = restoreMath(out, maths, tokens)
+	}
+	if notes != nil {
+		out = notes.render(out, g, tokens)
+	}
+	return out
+}
+
+// codeFence opens or closes a fenced code block
+var codeFence = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+
+// mapText applies fn to the parts of a markdown document outside code:
+// fenced blocks are left out, and inline code spans are hidden from fn.
+func mapText(src string, fn func(string) string) string {
+	var out, text strings.Builder
+	flush := func() {
+		out.WriteString(mapInline(text.String(), fn))
+		text.Reset()
+	}
+
+	fence := ""
+	for _, line := range strings.SplitAfter(src, "\n") {
+		m := codeFence.FindStringSubmatch(line)
+		switch {
+		case fence == "" && m != nil:
+			flush()
+			fence = m[1]
+			out.WriteString(line)
+		case fence != "":
+			out.WriteString(line)
+			if m != nil && m[1][0] == fence[0] && len(m[1]) >= len(fence) &&
+				strings.TrimSpace(line[len(m[0]):]) == "" {
+				fence = ""
+			}
+		default:
+			text.WriteString(line)
+		}
+	}
+	flush()
+	return out.String()
+}
+
+// mapInline applies fn to text with its code spans swapped out
+func mapInline(text string, fn func(string) string) string {
+	var spans []string
+	var masked strings.Builder
+	for i := 0; i < len(text); {
+		if text[i] != '`' {
+			masked.WriteByte(text[i])
+			i++
+			continue
+		}
+		n := 0
+		for i+n < len(text) && text[i+n] == '`' {
+			n++
+		}
+		ticks := text[i : i+n]
+		end := -1
+		for j := i + n; j < len(text); {
+			k := strings.Index(text[j:], ticks)
+			if k < 0 {
+				break
+			}
+			k += j
+			if k+n == len(text) || text[k+n] != '`' {
+				end = k + n
+				break
+			}
+			for k < len(text) && text[k] == '`' {
+				k++
+			}
+			j = k
+		}
+		if end < 0 {
+			masked.WriteString(ticks)
+			i += n
+			continue
+		}
+		fmt.Fprintf(&masked, "\x00%d\x00", len(spans))
+		spans = append(spans, text[i:end])
+		i = end
+	}
+
+	result := fn(masked.String())
+	for i, span := range spans {
+		result = strings.Replace(result, fmt.Sprintf("\x00%d\x00", i), span, 1)
+	}
+	return result
+}
+
+// tableDelimiter is the row under a table header, like |---|:--:|
+var tableDelimiter = regexp.MustCompile(`(?m)^ {0,3}\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)+\|?[ \t]*$`)
+
+// disableTables escapes the pipes of table delimiter rows so no table is
+// recognized
+func disableTables(text string) string {
+	return tableDelimiter.ReplaceAllStringFunc(text, func(row string) string {
+		return strings.ReplaceAll(row, "|", `\|`)
+	})
+}
+
+// mathSpan is a formula taken out of the markdown
+type mathSpan struct {
+	tex     string
+	display bool
+}
+
+var (
+	displayMath = regexp.MustCompile(`(?s)\$\$(.+?)\$\$`)
+	inlineMath  = regexp.MustCompile(`\$([^\s$](?:[^$\n]*[^\s$\\])?)\$`)
+)
+
+// extractMath replaces $$display$$ and $inline$ formulas by placeholders. An
+// inline formula can't start or end with a space nor be followed by a digit,
+// which keeps prices like "$5 and $10" as text.
+func extractMath(text string, maths *[]mathSpan, tokens *placeholders) string {
+	token := func(tex string, display bool) string {
+		*maths = append(*maths, mathSpan{tex: tex, display: display})
+		return tokens.mathToken(len(*maths) - 1)
+	}
+	text = displayMath.ReplaceAllStringFunc(text, func(m string) string {
+		return token(strings.TrimSpace(m[2:len(m)-2]), true)
+	})
+
+	var out strings.Builder
+	last := 0
+	for _, loc := range inlineMath.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		if (start > 0 && text[start-1] == '\\') || (end < len(text) && text[end] >= '0' && text[end] <= '9') {
+			continue
+		}
+		out.WriteString(text[last:start])
+		out.WriteString(token(text[loc[2]:loc[3]], false))
+		last = end
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+// restoreMath puts formulas back with the delimiters KaTeX's auto-render
+// looks for, in elements a page can style. Tokens without a formula are
+// left as they are.
+func restoreMath(out string, maths []mathSpan, tokens *placeholders) string {
+	render := func(t string) string {
+		i, err := strconv.Atoi(tokens.math.FindStringSubmatch(t)[1])
+		if err != nil || i >= len(maths) {
+			return t
+		}
+		m := maths[i]
+		if m.display {
+			return `<div class="math math-display">\[` + html.EscapeString(m.tex) + `\]</div>`
+		}
+		return `<span class="math math-inline">\(` + html.EscapeString(m.tex) + `\)</span>`
+	}
+	out = regexp.MustCompile(`<p>`+tokens.math.String()+`</p>`).ReplaceAllStringFunc(out, func(p string) string {
+		t := tokens.math.FindString(p)
+		if m := render(t); m != t {
+			return m
+		}
+		return p
+	})
+	return tokens.math.ReplaceAllStringFunc(out, render)
+}
+
+// footnotes are the definitions of a document, numbered in order of use
+type footnotes struct {
+	defs  map[string]string
+	order []string // Labels by number - 1
+}
+
+var (
+	footnoteDef = regexp.MustCompile(`^ {0,3}\[\^([^\]\s]+)\]:[ \t]?(.*)$`)
+	footnoteRef = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+)
+
+// extractFootnotes takes out the [^label]: definitions, with their indented
+// continuation lines, and replaces references to them by placeholders
+func extractFootnotes(src string, tokens *placeholders) (string, *footnotes) {
+	notes := &footnotes{defs: map[string]string{}}
+	var kept strings.Builder
+	current := ""
+	src = mapText(src, func(text string) string {
+		kept.Reset()
+		for _, line := range strings.SplitAfter(text, "\n") {
+			if m := footnoteDef.FindStringSubmatch(strings.TrimRight(line, "\n")); m != nil {
+				current = m[1]
+				notes.defs[current] = m[2]
+				continue
+			}
+			if current != "" {
+				trimmed := strings.TrimRight(line, "\n")
+				if strings.HasPrefix(trimmed, "    ") || strings.HasPrefix(trimmed, "\t") || trimmed == "" {
+					notes.defs[current] += "\n" + strings.TrimPrefix(strings.TrimPrefix(trimmed, "\t"), "    ")
+					continue
+				}
+				current = ""
+			}
+			kept.WriteString(line)
+		}
+		return kept.String()
+	})
+	if len(notes.defs) == 0 {
+		return src, nil
+	}
+
+	numbers := map[string]int{}
+	src = mapText(src, func(text string) string {
+		return footnoteRef.ReplaceAllStringFunc(text, func(ref string) string {
+			label := ref[2 : len(ref)-1]
+			if _, ok := notes.defs[label]; !ok {
+				return ref
+			}
+			if numbers[label] == 0 {
+				notes.order = append(notes.order, label)
+				numbers[label] = len(notes.order)
+			}
+			return tokens.footnoteToken(numbers[label])
+		})
+	})
+	return src, notes
+}
+
+// render replaces the reference placeholders and appends the footnotes
+// that were referenced. Tokens without a footnote are left as they are.
+func (f *footnotes) render(out string, g *gfm, tokens *placeholders) string {
+	seen := map[string]bool{}
+	out = tokens.footnote.ReplaceAllStringFunc(out, func(t string) string {
+		n := tokens.footnote.FindStringSubmatch(t)[1]
+		if i, err := strconv.Atoi(n); err != nil || i < 1 || i > len(f.order) {
+			return t
+		}
+		id := ""
+		if !seen[n] {
+			id = fmt.Sprintf(` id="fnref-%s"`, n)
+			seen[n] = true
+		}
+		return fmt.Sprintf(`<sup class="footnote-ref"%s><a href="#fn-%s">%s</a></sup>`, id, n, n)
+	})
+	if len(f.order) == 0 {
+		return out
+	}
+
+	inner := &gfm{opts: Options{Disabled: append([]string{Footnotes}, g.opts.Disabled...)}}
+	var b strings.Builder
+	b.WriteString(out)
+	b.WriteString("<section class=\"footnotes\">\n<ol>\n")
+	for i, label := range f.order {
+		note := strings.TrimSpace(inner.Render(strings.TrimSpace(f.defs[label])))
+		backref := fmt.Sprintf(` <a href="#fnref-%d" class="footnote-backref">&#8617;</a>`, i+1)
+		if strings.HasSuffix(note, "</p>") {
+			note = strings.TrimSuffix(note, "</p>") + backref + "</p>"
+		} else {
+			note += backref
+		}
+		fmt.Fprintf(&b, "<li id=\"fn-%d\">%s</li>\n", i+1, note)
+	}
+	b.WriteString("</ol>\n</section>\n")
+	return b.String()
+}
+
+// codeBlock is a fenced code block with a language, as GFM renders it
+var codeBlock = regexp.MustCompile(`(?s)(<div class="highlight highlight-([^"]+)"><pre>)(.*?)(</pre></div>)`)
+
+// plainLanguages are never highlighted
+var plainLanguages = map[string]bool{"text": true, "txt": true, "plain": true, "plaintext": true}
+
+// highlightClasses are GFM's own token classes
+var highlightClasses = syntaxhighlight.HTMLConfig{
+	String:        "s",
+	Keyword:       "k",
+	Comment:       "c",
+	Type:          "n",
+	Literal:       "o",
+	Punctuation:   "p",
+	Plaintext:     "n",
+	Tag:           "tag",
+	HTMLTag:       "htm",
+	HTMLAttrName:  "atn",
+	HTMLAttrValue: "atv",
+	Decimal:       "m",
+}
+
+// highlight highlights the code blocks GFM left plain (it only knows Go and
+// diffs) with a generic lexer that suits most C-like languages
+func highlight(out string) string {
+	return codeBlock.ReplaceAllStringFunc(out, func(block string) string {
+		m := codeBlock.FindStringSubmatch(block)
+		if plainLanguages[strings.ToLower(m[2])] || strings.Contains(m[3], "<span") {
+			return block
+		}
+		var b strings.Builder
+		src := []byte(html.UnescapeString(m[3]))
+		if err := syntaxhighlight.Print(syntaxhighlight.NewScanner(src), &b, syntaxhighlight.HTMLPrinter(highlightClasses)); err != nil {
+			return block
+		}
+		return m[1] + b.String() + m[4]
+	})
+}
+
+// highlightedPre is a code block, possibly highlighted
+var highlightedPre = regexp.MustCompile(`(?s)<pre>.*?</pre>`)
+
+// highlightSpan is a token span added by syntax highlighting
+var highlightSpan = regexp.MustCompile(`</?span[^>]*>`)
+
+// unhighlight removes the token spans of highlighted code blocks
+func unhighlight(out string) string {
+	return highlightedPre.ReplaceAllStringFunc(out, func(pre string) string {
+		return highlightSpan.ReplaceAllString(pre, "")
+	})
+}
@@ -0,0 +1,99 @@
+// Package markdown renders clip markdown to HTML for the pages the server
+// builds itself (exports, the public mirror). Renderers are pluggable: the
+// built-in "gfm" renders GitHub Flavored Markdown and adds footnotes and
+// math on top, each extension toggled per instance.
+package markdown
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Extensions
+const (
+	Tables    = "tables"
+	Footnotes = "footnotes"
+	TaskLists = "task_lists"
+	Math      = "math"      // $inline$ and $$display$$, passed through for KaTeX
+	Highlight = "highlight" // Syntax highlighting of fenced code
+)
+
+// AllExtensions lists every extension, all on by default
+var AllExtensions = []string{Tables, Footnotes, TaskLists, Math, Highlight}
+
+// Options configure a renderer
+type Options struct {
+	Disabled []string // Extensions turned off
+}
+
+// Enabled reports whether an extension is on
+func (o Options) Enabled(ext string) bool {
+	for _, d := range o.Disabled {
+		if d == ext {
+			return false
+		}
+	}
+	return true
+}
+
+// Renderer renders markdown to sanitized HTML
+type Renderer interface {
+	Render(markdown string) string
+}
+
+// Factory builds a renderer
+type Factory func(Options) Renderer
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{"gfm": newGFM}
+)
+
+// Register makes a renderer available under name
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = factory
+}
+
+// New returns the renderer registered as name ("gfm" when empty)
+func New(name string, opts Options) (Renderer, error) {
+	if name == "" {
+		name = "gfm"
+	}
+	for _, ext := range opts.Disabled {
+		if !isExtension(ext) {
+			return nil, fmt.Errorf("unknown markdown extension: %s", ext)
+		}
+	}
+
+	mu.RLock()
+	factory, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown markdown renderer %q (available: %v)", name, Names())
+	}
+	return factory(opts), nil
+}
+
+// Names lists the registered renderers
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isExtension(ext string) bool {
+	for _, e := range AllExtensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, src string, disabled ...string) string {
+	t.Helper()
+	r, err := New("", Options{Disabled: disabled})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r.Render(src)
+}
+
+func assertContains(t *testing.T, out string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("%q missing from:\n%s", w, out)
+		}
+	}
+}
+
+func assertNotContains(t *testing.T, out string, unwanted ...string) {
+	t.Helper()
+	for _, u := range unwanted {
+		if strings.Contains(out, u) {
+			t.Errorf("%q found in:\n%s", u, out)
+		}
+	}
+}
+
+const table = "| a | b |\n|---|:-:|\n| 1 | 2 |\n"
+
+func TestTables(t *testing.T) {
+	assertContains(t, render(t, table), "<table>", "<td>1</td>")
+
+	out := render(t, table, Tables)
+	assertNotContains(t, out, "<table>")
+	assertContains(t, out, "| 1 | 2 |")
+}
+
+func TestTaskLists(t *testing.T) {
+	src := "- [ ] todo\n- [x] done\n"
+	assertContains(t, render(t, src), `<input type="checkbox" disabled="">`, `<input type="checkbox" checked="" disabled="">`)
+	assertContains(t, render(t, src, TaskLists), "<li>[ ] todo</li>", "<li>[x] done</li>")
+}
+
+func TestMath(t *testing.T) {
+	src := "Euler: $e^{i\\pi}+1=0$, for $5 or $10, not `$code$`.\n\n$$\na < b_1\n$$\n\n```\n$$not math$$\n```\n"
+	out := render(t, src)
+	assertContains(t, out,
+		`<span class="math math-inline">\(e^{i\pi}+1=0\)</span>`,
+		"for $5 or $10",
+		"<code>$code$</code>",
+		`<div class="math math-display">\[a &lt; b_1\]</div>`,
+		"$$not math$$",
+	)
+	assertNotContains(t, out, "MDXMATH", "<em>")
+
+	out = render(t, src, Math)
+	assertNotContains(t, out, "math-inline", "math-display")
+}
+
+func TestLiteralPlaceholders(t *testing.T) {
+	// Text that looks like the placeholders of older versions is kept
+	src := "Tokens MDXMATH0XMD and MDXFOOT1XMD, `MDXMATH7XMD`.\n\nMDXMATH9XMD\n\n$x$ and a note[^1].\n\n[^1]: Note.\n"
+	out := render(t, src)
+	assertContains(t, out,
+		"Tokens MDXMATH0XMD and MDXFOOT1XMD, <code>MDXMATH7XMD</code>.",
+		"<p>MDXMATH9XMD</p>",
+		`<span class="math math-inline">\(x\)</span>`,
+		`note<sup class="footnote-ref" id="fnref-1">`,
+	)
+}
+
+func TestFootnotes(t *testing.T) {
+	src := "Claim[^1], another[^long] and the first again[^1]. Unknown[^x].\n\n" +
+		"[^1]: Source *one*.\n[^long]: Spans\n    two lines.\n\nAfter.\n"
+	out := render(t, src)
+	assertContains(t, out,
+		`Claim<sup class="footnote-ref" id="fnref-1"><a href="#fn-1">1</a></sup>`,
+		`another<sup class="footnote-ref" id="fnref-2"><a href="#fn-2">2</a></sup>`,
+		`again<sup class="footnote-ref"><a href="#fn-1">1</a></sup>`,
+		"Unknown[^x]",
+		`<li id="fn-1"><p>Source <em>one</em>. <a href="#fnref-1" class="footnote-backref">`,
+		"Spans\ntwo lines.",
+		"<p>After.</p>",
+	)
+	if strings.Index(out, "After.") > strings.Index(out, `class="footnotes"`) {
+		t.Errorf("footnotes not at the end:\n%s", out)
+	}
+
+	assertNotContains(t, render(t, src, Footnotes), "footnote")
+}
+
+func TestHighlight(t *testing.T) {
+	src := "```python\ndef f(x): return \"<x>\"  # hi\n```\n\n```text\nif plain\n```\n"
+	out := render(t, src)
+	assertContains(t, out, `<span class="k">def</span>`, `<span class="s">&#34;&lt;x&gt;&#34;</span>`, "<pre>if plain\n</pre>")
+
+	out = render(t, src, Highlight)
+	assertNotContains(t, out, "<span")
+	assertContains(t, out, `def f(x): return &#34;&lt;x&gt;&#34;  # hi`)
+}
+
+func TestSanitized(t *testing.T) {
+	out := render(t, "<script>alert(1)</script>\n\n$<img src=x onerror=alert(1)>$\n\n[^1]\n\n[^1]: <script>x</script>\n")
+	assertNotContains(t, out, "<script", "<img")
+}
+
+type upper struct{}
+
+func (upper) Render(src string) string { return strings.ToUpper(src) }
+
+func TestRegister(t *testing.T) {
+	Register("upper", func(Options) Renderer { return upper{} })
+	r, err := New("upper", Options{})
+	if err != nil || r.Render("hi") != "HI" {
+		t.Fatalf("got %v, %v", r, err)
+	}
+
+	if _, err := New("nope", Options{}); err == nil {
+		t.Error("unknown renderer accepted")
+	}
+	if _, err := New("gfm", Options{Disabled: []string{"emoji"}}); err == nil {
+		t.Error("unknown extension accepted")
+	}
+}
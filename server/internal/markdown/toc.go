@@ -0,0 +1,168 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// TOC markers delimit a generated table of contents, so it is found again
+// (and not generated twice) and its own heading is not listed
+const (
+	tocStart = "<!-- toc -->"
+	tocEnd   = "<!-- /toc -->"
+)
+
+// TOCOptions configure a table of contents
+type TOCOptions struct {
+	MinHeadings int // Fewer headings than this get no table of contents
+	MaxDepth    int // Heading levels listed below the top one (0 = all)
+}
+
+// Heading is an ATX heading (## Title) of a markdown document
+type Heading struct {
+	Level  int
+	Text   string // Without inline markup
+	Anchor string // As the gfm renderer names it
+}
+
+var (
+	atxHeading   = regexp.MustCompile(`^ {0,3}(#{1,6})[ \t]+(.+?)(?:[ \t]+#+)?[ \t]*$`)
+	inlineLink   = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	inlineMarkup = strings.NewReplacer("**", "", "__", "", "*", "", "`", "", "~~", "")
+)
+
+// Headings returns the headings of src, outside fenced code and any table of
+// contents already there
+func Headings(src string) []Heading {
+	var headings []Heading
+	fence := ""
+	inTOC := false
+	for _, line := range strings.Split(src, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		case strings.HasPrefix(trimmed, "```"), strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+			continue
+		case trimmed == tocStart:
+			inTOC = true
+			continue
+		case trimmed == tocEnd:
+			inTOC = false
+			continue
+		case inTOC:
+			continue
+		}
+
+		m := atxHeading.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		text := strings.TrimSpace(inlineMarkup.Replace(inlineLink.ReplaceAllString(m[2], "$1")))
+		if text == "" {
+			continue
+		}
+		headings = append(headings, Heading{Level: len(m[1]), Text: text, Anchor: anchorName(text)})
+	}
+	return headings
+}
+
+// HasTOC reports whether src already has a generated table of contents
+func HasTOC(src string) bool {
+	return strings.Contains(src, tocStart)
+}
+
+// TOC returns a table of contents for src as a markdown section linking to
+// its headings, or "" when it has too few. The document's title, a single
+// H1 heading, is not listed.
+func TOC(src string, opts TOCOptions) string {
+	headings := Headings(src)
+	if len(headings) > 0 && headings[0].Level == 1 && countLevel(headings, 1) == 1 {
+		headings = headings[1:]
+	}
+	if len(headings) == 0 || len(headings) < opts.MinHeadings {
+		return ""
+	}
+
+	top := 6
+	for _, h := range headings {
+		top = min(top, h.Level)
+	}
+	var sb strings.Builder
+	sb.WriteString(tocStart + "\n## Contents\n\n")
+	for _, h := range headings {
+		depth := h.Level - top
+		if opts.MaxDepth > 0 && depth >= opts.MaxDepth {
+			continue
+		}
+		sb.WriteString(strings.Repeat("  ", depth) + "- [" + escapeLinkText(h.Text) + "](#" + h.Anchor + ")\n")
+	}
+	sb.WriteString(tocEnd + "\n")
+	return sb.String()
+}
+
+// InsertTOC adds a table of contents to src after its title (a leading H1),
+// or at the top. src is returned as is when it has one already or too few
+// headings.
+func InsertTOC(src string, opts TOCOptions) string {
+	if HasTOC(src) {
+		return src
+	}
+	toc := TOC(src, opts)
+	if toc == "" {
+		return src
+	}
+
+	lines := strings.SplitAfter(src, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if m := atxHeading.FindStringSubmatch(strings.TrimRight(line, "\n")); m != nil && len(m[1]) == 1 {
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n"
+			}
+			return strings.Join(lines[:i], "") + line + "\n" + toc + "\n" + strings.Join(lines[i+1:], "")
+		}
+		break
+	}
+	return toc + "\n" + src
+}
+
+func countLevel(headings []Heading, level int) int {
+	n := 0
+	for _, h := range headings {
+		if h.Level == level {
+			n++
+		}
+	}
+	return n
+}
+
+func escapeLinkText(text string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`).Replace(text)
+}
+
+// anchorName names a heading's anchor the way the gfm renderer does: its
+// letters and digits in lowercase, other runs of characters as one dash
+func anchorName(text string) string {
+	var anchor []rune
+	dash := false
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			if dash && len(anchor) > 0 {
+				anchor = append(anchor, '-')
+			}
+			dash = false
+			anchor = append(anchor, unicode.ToLower(r))
+		} else {
+			dash = true
+		}
+	}
+	return string(anchor)
+}
@@ -0,0 +1,68 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+const longArticle = `# The Article
+
+Intro.
+
+## Getting **Started**
+
+### Install [the tool](https://example.com)
+
+` + "```sh\n# not a heading\n```" + `
+
+#### Too deep
+
+## Going Further
+
+## FAQ & Notes
+`
+
+func TestTOC(t *testing.T) {
+	toc := TOC(longArticle, TOCOptions{MinHeadings: 3, MaxDepth: 2})
+	want := tocStart + "\n## Contents\n\n" +
+		"- [Getting Started](#getting-started)\n" +
+		"  - [Install the tool](#install-the-tool)\n" +
+		"- [Going Further](#going-further)\n" +
+		"- [FAQ & Notes](#faq-notes)\n" +
+		tocEnd + "\n"
+	if toc != want {
+		t.Errorf("got:\n%s\nwant:\n%s", toc, want)
+	}
+
+	if toc := TOC(longArticle, TOCOptions{MinHeadings: 10}); toc != "" {
+		t.Errorf("expected no TOC below min headings, got:\n%s", toc)
+	}
+	if toc := TOC(longArticle, TOCOptions{}); !strings.Contains(toc, "    - [Too deep](#too-deep)") {
+		t.Errorf("expected every level without a max depth, got:\n%s", toc)
+	}
+}
+
+func TestInsertTOC(t *testing.T) {
+	out := InsertTOC(longArticle, TOCOptions{MinHeadings: 3})
+	if !strings.HasPrefix(out, "# The Article\n\n"+tocStart) {
+		t.Errorf("expected the TOC after the title, got:\n%s", out)
+	}
+	if again := InsertTOC(out, TOCOptions{MinHeadings: 3}); again != out {
+		t.Errorf("expected a single TOC, got:\n%s", again)
+	}
+	if len(Headings(out)) != len(Headings(longArticle)) {
+		t.Error("expected the TOC heading not to be listed")
+	}
+
+	untitled := "## One\n\n## Two\n"
+	if out := InsertTOC(untitled, TOCOptions{}); !strings.HasPrefix(out, tocStart) || !strings.HasSuffix(out, untitled) {
+		t.Errorf("expected the TOC at the top, got:\n%s", out)
+	}
+}
+
+func TestTOCLinksMatchAnchors(t *testing.T) {
+	out := render(t, InsertTOC(longArticle, TOCOptions{}))
+	for _, h := range Headings(longArticle) {
+		assertContains(t, out, `href="#`+h.Anchor+`"`, `name="`+h.Anchor+`"`)
+	}
+}
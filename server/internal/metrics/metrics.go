@@ -0,0 +1,125 @@
+// Package metrics keeps in-process counters and serves them in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry holds the counters and gauges served together
+type Registry struct {
+	mu       sync.Mutex
+	counters []*CounterVec
+	gauges   []*GaugeFunc
+}
+
+// Default is the registry served at /metrics
+var Default = &Registry{}
+
+// CounterVec is a counter partitioned by the values of one label
+type CounterVec struct {
+	name  string
+	help  string
+	label string
+
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+// NewCounterVec registers a counter partitioned by label. Known label values
+// start at zero so their series exist before the first increment.
+func (r *Registry) NewCounterVec(name, help, label string, known ...string) *CounterVec {
+	v := &CounterVec{name: name, help: help, label: label, values: map[string]uint64{}}
+	for _, value := range known {
+		v.values[value] = 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters = append(r.counters, v)
+	return v
+}
+
+// Inc adds one to the counter for a label value
+func (v *CounterVec) Inc(value string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[value]++
+}
+
+// Values returns a copy of the counts by label value
+func (v *CounterVec) Values() map[string]uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	values := make(map[string]uint64, len(v.values))
+	for k, n := range v.values {
+		values[k] = n
+	}
+	return values
+}
+
+// GaugeFunc is a gauge whose value is read when the metrics are served
+type GaugeFunc struct {
+	name  string
+	help  string
+	value func() float64
+}
+
+// NewGaugeFunc registers a gauge reporting value
+func (r *Registry) NewGaugeFunc(name, help string, value func() float64) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, value: value}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gauges = append(r.gauges, g)
+	return g
+}
+
+// WriteText writes the registry's counters, then its gauges, in the
+// Prometheus text format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	counters := append([]*CounterVec(nil), r.counters...)
+	gauges := append([]*GaugeFunc(nil), r.gauges...)
+	r.mu.Unlock()
+
+	for _, v := range counters {
+		values := v.Values()
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", v.name, v.help, v.name); err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if _, err := fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", v.name, v.label, labelEscaper.Replace(k), values[k]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, g := range gauges {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", g.name, g.help, g.name, g.name, g.value()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// labelEscaper escapes label values as the text format expects
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Handler serves the registry in the Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounterVec(t *testing.T) {
+	r := &Registry{}
+	v := r.NewCounterVec("test_rejections_total", "Rejections by reason", "reason", "quota", "auth")
+	v.Inc("auth")
+	v.Inc("auth")
+	v.Inc(`odd "reason"`)
+
+	values := v.Values()
+	if values["auth"] != 2 || values["quota"] != 0 {
+		t.Errorf("unexpected values: %v", values)
+	}
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	want := `# HELP test_rejections_total Rejections by reason
+# TYPE test_rejections_total counter
+test_rejections_total{reason="auth"} 2
+test_rejections_total{reason="odd \"reason\""} 1
+test_rejections_total{reason="quota"} 0
+`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+}
+
+func TestGaugeFunc(t *testing.T) {
+	r := &Registry{}
+	lag := 1.5
+	r.NewGaugeFunc("test_lag_seconds", "Lag", func() float64 { return lag })
+	r.NewCounterVec("test_total", "Total", "kind")
+
+	var out strings.Builder
+	if err := r.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_total Total
+# TYPE test_total counter
+# HELP test_lag_seconds Lag
+# TYPE test_lag_seconds gauge
+test_lag_seconds 1.5
+`
+	if got := out.String(); got != want {
+		t.Errorf("unexpected output:\n%s", got)
+	}
+}
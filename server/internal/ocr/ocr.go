@@ -0,0 +1,137 @@
+// Package ocr recognizes text in images using a pluggable engine.
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"server/internal/config"
+)
+
+// Engine recognizes text in an image.
+type Engine interface {
+	Recognize(ctx context.Context, image []byte, filename string) (string, error)
+}
+
+// New returns the engine selected in configuration, or nil when OCR is disabled.
+func New(cfg config.OCRConfig) (Engine, error) {
+	switch cfg.Engine {
+	case "":
+		return nil, nil
+	case "tesseract":
+		return &TesseractEngine{Path: cfg.TesseractPath, Languages: cfg.Languages}, nil
+	case "http":
+		if cfg.HTTPURL == "" {
+			return nil, fmt.Errorf("ocr.http_url is required for the http engine")
+		}
+		return &HTTPEngine{
+			URL:    cfg.HTTPURL,
+			Token:  cfg.HTTPToken,
+			Client: &http.Client{Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown OCR engine: %s", cfg.Engine)
+	}
+}
+
+// TesseractEngine shells out to the tesseract binary.
+type TesseractEngine struct {
+	Path      string // Binary path (default: "tesseract" from PATH)
+	Languages string // e.g. "eng+fra"
+}
+
+// Recognize pipes the image through `tesseract stdin stdout`.
+func (e *TesseractEngine) Recognize(ctx context.Context, image []byte, filename string) (string, error) {
+	bin := e.Path
+	if bin == "" {
+		bin = "tesseract"
+	}
+
+	args := []string{"stdin", "stdout"}
+	if e.Languages != "" {
+		args = append(args, "-l", e.Languages)
+	}
+
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Stdin = bytes.NewReader(image)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract failed on %s: %w: %s", filename, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// HTTPEngine posts images to an external OCR service.
+//
+// The image is sent as multipart field "file"; the service must answer with
+// either JSON {"text": "..."} or a text/plain body.
+type HTTPEngine struct {
+	URL    string
+	Token  string // Optional bearer token
+	Client *http.Client
+}
+
+// Recognize uploads the image and returns the recognized text.
+func (e *HTTPEngine) Recognize(ctx context.Context, image []byte, filename string) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(image); err != nil {
+		return "", err
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if e.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.Token)
+	}
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("OCR service request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10*1024*1024))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OCR service returned %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		var result struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(data, &result); err != nil {
+			return "", fmt.Errorf("invalid OCR service response: %w", err)
+		}
+		return strings.TrimSpace(result.Text), nil
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,70 @@
+package ocr
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"server/internal/config"
+)
+
+func TestNew(t *testing.T) {
+	engine, err := New(config.OCRConfig{})
+	if err != nil || engine != nil {
+		t.Errorf("expected nil engine when disabled, got %v, %v", engine, err)
+	}
+
+	if _, err := New(config.OCRConfig{Engine: "http"}); err == nil {
+		t.Error("expected error when http engine has no URL")
+	}
+
+	if _, err := New(config.OCRConfig{Engine: "bogus"}); err == nil {
+		t.Error("expected error for unknown engine")
+	}
+
+	engine, err = New(config.OCRConfig{Engine: "tesseract", Languages: "eng"})
+	if err != nil {
+		t.Fatalf("New(tesseract) failed: %v", err)
+	}
+	if _, ok := engine.(*TesseractEngine); !ok {
+		t.Errorf("expected *TesseractEngine, got %T", engine)
+	}
+}
+
+func TestHTTPEngine(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		if header.Filename != "shot.png" || string(data) != "image-bytes" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text": "  Hello OCR \n"}`))
+	}))
+	defer srv.Close()
+
+	engine := &HTTPEngine{URL: srv.URL, Token: "secret"}
+	text, err := engine.Recognize(context.Background(), []byte("image-bytes"), "shot.png")
+	if err != nil {
+		t.Fatalf("Recognize() failed: %v", err)
+	}
+	if text != "Hello OCR" {
+		t.Errorf("expected 'Hello OCR', got %q", text)
+	}
+
+	engine.Token = "wrong"
+	if _, err := engine.Recognize(context.Background(), []byte("image-bytes"), "shot.png"); err == nil {
+		t.Error("expected error on non-200 response")
+	}
+}
@@ -0,0 +1,355 @@
+package openapi
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+)
+
+// goGen accumulates generated Go code and the imports it needs
+type goGen struct {
+	spec    *Spec
+	b       strings.Builder
+	imports map[string]bool
+}
+
+func (g *goGen) p(format string, args ...interface{}) {
+	fmt.Fprintf(&g.b, format, args...)
+	g.b.WriteByte('\n')
+}
+
+// GenerateGo returns the Go types and client methods for the spec. The
+// generated methods rely on the hand-written Client.do in the same package;
+// operations with a raw body are left to hand-written methods.
+func (s *Spec) GenerateGo(pkg, source string) ([]byte, error) {
+	g := &goGen{spec: s, imports: map[string]bool{"context": true}}
+
+	for _, name := range s.Components.Schemas.Keys {
+		if err := g.schemaType(name, s.Components.Schemas.Values[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	for _, op := range s.Operations() {
+		if op.RawBody() {
+			continue
+		}
+		if err := g.operation(op); err != nil {
+			return nil, fmt.Errorf("%s: %w", op.OperationID, err)
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "// Code generated by sdkgen from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	out.WriteString("import (\n")
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		fmt.Fprintf(&out, "\t%q\n", imp)
+	}
+	out.WriteString(")\n\n")
+	out.WriteString(g.b.String())
+
+	src, err := format.Source([]byte(out.String()))
+	if err != nil {
+		return nil, fmt.Errorf("generated Go code does not compile: %w", err)
+	}
+	return src, nil
+}
+
+// schemaType emits the named type for a component schema
+func (g *goGen) schemaType(name string, schema *Schema) error {
+	typeName := goName(name)
+	g.doc(typeName, schema.Description)
+
+	if schema.Type == "string" && len(schema.Enum) > 0 {
+		g.p("type %s string\n", typeName)
+		g.p("const (")
+		for _, v := range schema.Enum {
+			g.p("\t%s%s %s = %q", typeName, goName(v), typeName, v)
+		}
+		g.p(")\n")
+		return nil
+	}
+
+	if schema.Type != "object" && len(schema.AllOf) == 0 {
+		t, err := g.goType(schema, true)
+		if err != nil {
+			return err
+		}
+		g.p("type %s %s\n", typeName, t)
+		return nil
+	}
+
+	g.p("type %s struct {", typeName)
+	parts := schema.AllOf
+	if len(parts) == 0 {
+		parts = []*Schema{schema}
+	}
+	for _, part := range parts {
+		if part.Ref != "" {
+			g.p("\t%s", goName(RefName(part.Ref)))
+			continue
+		}
+		if err := g.fields(part); err != nil {
+			return err
+		}
+	}
+	g.p("}\n")
+	return nil
+}
+
+// fields emits the struct fields of an inline object schema
+func (g *goGen) fields(schema *Schema) error {
+	required := map[string]bool{}
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+	for _, name := range schema.Properties.Keys {
+		prop := schema.Properties.Values[name]
+		t, err := g.goType(prop, required[name])
+		if err != nil {
+			return fmt.Errorf("property %s: %w", name, err)
+		}
+		tag := name
+		if !required[name] {
+			tag += ",omitempty"
+		}
+		line := fmt.Sprintf("\t%s %s `json:%q`", goName(name), t, tag)
+		if prop.Description != "" {
+			line += " // " + prop.Description
+		}
+		g.p("%s", line)
+	}
+	return nil
+}
+
+// goType returns the Go type for a schema. Optional numbers, times and
+// objects are pointers so their zero value can be told from "absent", as are
+// nullable strings and booleans.
+func (g *goGen) goType(schema *Schema, required bool) (string, error) {
+	ptr := ""
+	if !required || schema.Nullable {
+		ptr = "*"
+	}
+
+	if schema.Ref != "" {
+		target, err := g.spec.Resolve(schema)
+		if err != nil {
+			return "", err
+		}
+		name := goName(RefName(schema.Ref))
+		if target.Type == "object" || len(target.AllOf) > 0 {
+			return ptr + name, nil
+		}
+		return name, nil
+	}
+
+	switch schema.Type {
+	case "string":
+		if schema.Format == "date-time" {
+			g.imports["time"] = true
+			return ptr + "time.Time", nil
+		}
+		if schema.Nullable {
+			return "*string", nil
+		}
+		return "string", nil
+	case "integer":
+		if schema.Format == "int64" {
+			return "int64", nil
+		}
+		return "int", nil
+	case "number":
+		return ptr + "float64", nil
+	case "boolean":
+		if schema.Nullable {
+			return "*bool", nil
+		}
+		return "bool", nil
+	case "array":
+		if schema.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		item, err := g.goType(schema.Items, true)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case "object":
+		return "map[string]interface{}", nil
+	default:
+		return "", fmt.Errorf("unsupported type %q", schema.Type)
+	}
+}
+
+// operation emits the parameters struct and client method of an operation
+func (g *goGen) operation(op *Operation) error {
+	method := goName(op.OperationID)
+	query := op.ParamsIn("query")
+	header := op.ParamsIn("header")
+	path := op.ParamsIn("path")
+
+	paramsType := ""
+	if len(query)+len(header) > 0 {
+		paramsType = method + "Params"
+		g.p("// %s holds the optional parameters of %s.", paramsType, method)
+		g.p("type %s struct {", paramsType)
+		for _, p := range append(append([]Parameter{}, query...), header...) {
+			t, err := g.paramType(p)
+			if err != nil {
+				return err
+			}
+			line := fmt.Sprintf("\t%s %s", goName(p.Name), t)
+			if p.Description != "" {
+				line += " // " + p.Description
+			}
+			g.p("%s", line)
+		}
+		g.p("}\n")
+	}
+
+	args := []string{"ctx context.Context"}
+	for _, p := range path {
+		args = append(args, tsName(p.Name)+" string")
+	}
+	body := op.BodySchema()
+	if body != nil {
+		t, err := g.goType(body, true)
+		if err != nil {
+			return err
+		}
+		args = append(args, "body "+strings.TrimPrefix(t, "*"))
+	}
+	if paramsType != "" {
+		args = append(args, "params *"+paramsType)
+	}
+
+	out := ""
+	if schema := g.spec.SuccessSchema(op); schema != nil {
+		t, err := g.goType(schema, true)
+		if err != nil {
+			return err
+		}
+		out = strings.TrimPrefix(t, "*")
+	}
+
+	g.p("// %s calls %s %s: %s.", method, op.Method, op.Path, strings.TrimSuffix(op.Summary, "."))
+	if out != "" {
+		g.p("func (c *Client) %s(%s) (*%s, error) {", method, strings.Join(args, ", "), out)
+	} else {
+		g.p("func (c *Client) %s(%s) error {", method, strings.Join(args, ", "))
+	}
+
+	queryArg, headerArg := "nil", "nil"
+	if len(query) > 0 {
+		g.imports["net/url"] = true
+		g.p("\tquery := url.Values{}")
+		queryArg = "query"
+	}
+	if len(header) > 0 {
+		g.p("\theader := http.Header{}")
+		headerArg = "header"
+	}
+	if paramsType != "" {
+		g.p("\tif params != nil {")
+		for _, p := range query {
+			g.setParam("query", p)
+		}
+		for _, p := range header {
+			g.setParam("header", p)
+		}
+		g.p("\t}")
+	}
+
+	bodyArg := "nil"
+	if body != nil {
+		bodyArg = "body"
+	}
+	g.imports["net/http"] = true
+	call := fmt.Sprintf("c.do(ctx, http.Method%s, %s, %s, %s, %s, %%s)",
+		strings.ToUpper(op.Method[:1])+strings.ToLower(op.Method[1:]), g.pathExpr(op.Path), queryArg, headerArg, bodyArg)
+	if out != "" {
+		g.p("\tout := &%s{}", out)
+		g.p("\tif err := "+call+"; err != nil {", "out")
+		g.p("\t\treturn nil, err")
+		g.p("\t}")
+		g.p("\treturn out, nil")
+	} else {
+		g.p("\treturn "+call, "nil")
+	}
+	g.p("}\n")
+	return nil
+}
+
+// paramType returns the Go type of a query or header parameter
+func (g *goGen) paramType(p Parameter) (string, error) {
+	if p.Schema == nil {
+		return "string", nil
+	}
+	switch p.Schema.Type {
+	case "string", "":
+		return "string", nil
+	case "integer":
+		return "int", nil
+	case "boolean":
+		return "bool", nil
+	default:
+		return "", fmt.Errorf("parameter %s: unsupported type %q", p.Name, p.Schema.Type)
+	}
+}
+
+// setParam emits the code copying a non-zero parameter into query or header
+func (g *goGen) setParam(dest string, p Parameter) {
+	field := "params." + goName(p.Name)
+	t, _ := g.paramType(p)
+	switch t {
+	case "int":
+		g.imports["strconv"] = true
+		g.p("\t\tif %s != 0 {", field)
+		g.p("\t\t\t%s.Set(%q, strconv.Itoa(%s))", dest, p.Name, field)
+	case "bool":
+		g.p("\t\tif %s {", field)
+		g.p("\t\t\t%s.Set(%q, \"true\")", dest, p.Name)
+	default:
+		g.p("\t\tif %s != \"\" {", field)
+		g.p("\t\t\t%s.Set(%q, %s)", dest, p.Name, field)
+	}
+	g.p("\t\t}")
+}
+
+// pathExpr returns a Go expression building the path, escaping parameters
+func (g *goGen) pathExpr(path string) string {
+	if !strings.Contains(path, "{") {
+		return fmt.Sprintf("%q", path)
+	}
+	g.imports["net/url"] = true
+	var parts []string
+	for path != "" {
+		start := strings.Index(path, "{")
+		if start < 0 {
+			parts = append(parts, fmt.Sprintf("%q", path))
+			break
+		}
+		end := strings.Index(path, "}")
+		if start > 0 {
+			parts = append(parts, fmt.Sprintf("%q", path[:start]))
+		}
+		parts = append(parts, "url.PathEscape("+tsName(path[start+1:end])+")")
+		path = path[end+1:]
+	}
+	return strings.Join(parts, "+")
+}
+
+// doc emits a type's doc comment
+func (g *goGen) doc(name, description string) {
+	if description == "" {
+		g.p("// %s is the %s schema of the API spec.", name, name)
+		return
+	}
+	g.p("// %s: %s", name, strings.TrimSpace(description))
+}
@@ -0,0 +1,369 @@
+package openapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tsGen accumulates generated TypeScript code
+type tsGen struct {
+	spec *Spec
+	b    strings.Builder
+}
+
+func (g *tsGen) p(format string, args ...interface{}) {
+	fmt.Fprintf(&g.b, format, args...)
+	g.b.WriteByte('\n')
+}
+
+// GenerateTS returns TypeScript types and a fetch-based client for the spec
+func (s *Spec) GenerateTS(source string) ([]byte, error) {
+	g := &tsGen{spec: s}
+	g.p("// Code generated by sdkgen from %s. DO NOT EDIT.", source)
+	g.p("")
+
+	for _, name := range s.Components.Schemas.Keys {
+		if err := g.schemaType(name, s.Components.Schemas.Values[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	for _, op := range s.Operations() {
+		if op.RawBody() {
+			continue
+		}
+		if params := append(op.ParamsIn("query"), op.ParamsIn("header")...); len(params) > 0 {
+			g.p("export interface %sParams {", goName(op.OperationID))
+			for _, p := range params {
+				g.comment("  ", p.Description)
+				g.p("  %s?: %s;", tsName(p.Name), g.paramType(p))
+			}
+			g.p("}")
+			g.p("")
+		}
+	}
+
+	g.b.WriteString(tsRuntime)
+
+	g.p("export class WebClipperClient extends BaseClient {")
+	first := true
+	for _, op := range s.Operations() {
+		if op.RawBody() {
+			continue
+		}
+		if !first {
+			g.p("")
+		}
+		first = false
+		if err := g.operation(op); err != nil {
+			return nil, fmt.Errorf("%s: %w", op.OperationID, err)
+		}
+	}
+	g.p("}")
+	return []byte(g.b.String()), nil
+}
+
+// schemaType emits the interface or type alias for a component schema
+func (g *tsGen) schemaType(name string, schema *Schema) error {
+	g.comment("", schema.Description)
+
+	if schema.Type != "object" && len(schema.AllOf) == 0 {
+		t, err := g.tsType(schema)
+		if err != nil {
+			return err
+		}
+		g.p("export type %s = %s;", name, t)
+		g.p("")
+		return nil
+	}
+
+	var extends []string
+	var inline []*Schema
+	for _, part := range schema.AllOf {
+		if part.Ref != "" {
+			extends = append(extends, RefName(part.Ref))
+		} else {
+			inline = append(inline, part)
+		}
+	}
+	if len(schema.AllOf) == 0 {
+		inline = []*Schema{schema}
+	}
+
+	if len(extends) > 0 {
+		g.p("export interface %s extends %s {", name, strings.Join(extends, ", "))
+	} else {
+		g.p("export interface %s {", name)
+	}
+	for _, part := range inline {
+		required := map[string]bool{}
+		for _, r := range part.Required {
+			required[r] = true
+		}
+		for _, prop := range part.Properties.Keys {
+			sc := part.Properties.Values[prop]
+			t, err := g.tsType(sc)
+			if err != nil {
+				return fmt.Errorf("property %s: %w", prop, err)
+			}
+			opt := "?"
+			if required[prop] {
+				opt = ""
+			}
+			g.comment("  ", sc.Description)
+			g.p("  %s%s: %s;", prop, opt, t)
+		}
+	}
+	g.p("}")
+	g.p("")
+	return nil
+}
+
+// tsType returns the TypeScript type for a schema
+func (g *tsGen) tsType(schema *Schema) (string, error) {
+	t, err := g.baseType(schema)
+	if err != nil {
+		return "", err
+	}
+	if schema.Nullable {
+		t += " | null"
+	}
+	return t, nil
+}
+
+func (g *tsGen) baseType(schema *Schema) (string, error) {
+	if schema.Ref != "" {
+		if _, err := g.spec.Resolve(schema); err != nil {
+			return "", err
+		}
+		return RefName(schema.Ref), nil
+	}
+	switch schema.Type {
+	case "string":
+		if len(schema.Enum) > 0 {
+			quoted := make([]string, len(schema.Enum))
+			for i, v := range schema.Enum {
+				quoted[i] = "'" + v + "'"
+			}
+			return strings.Join(quoted, " | "), nil
+		}
+		return "string", nil
+	case "integer", "number":
+		return "number", nil
+	case "boolean":
+		return "boolean", nil
+	case "array":
+		if schema.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		item, err := g.tsType(schema.Items)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(item, " ") {
+			item = "(" + item + ")"
+		}
+		return item + "[]", nil
+	case "object":
+		return "Record<string, unknown>", nil
+	default:
+		return "", fmt.Errorf("unsupported type %q", schema.Type)
+	}
+}
+
+// paramType returns the TypeScript type of a query or header parameter
+func (g *tsGen) paramType(p Parameter) string {
+	if p.Schema == nil {
+		return "string"
+	}
+	switch p.Schema.Type {
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	default:
+		return "string"
+	}
+}
+
+// operation emits a client method
+func (g *tsGen) operation(op *Operation) error {
+	var args []string
+	for _, p := range op.ParamsIn("path") {
+		args = append(args, tsName(p.Name)+": string")
+	}
+	if body := op.BodySchema(); body != nil {
+		t, err := g.tsType(body)
+		if err != nil {
+			return err
+		}
+		args = append(args, "body: "+t)
+	}
+	query := op.ParamsIn("query")
+	header := op.ParamsIn("header")
+	if len(query)+len(header) > 0 {
+		args = append(args, fmt.Sprintf("params: %sParams = {}", goName(op.OperationID)))
+	}
+
+	out := "void"
+	if schema := g.spec.SuccessSchema(op); schema != nil {
+		t, err := g.tsType(schema)
+		if err != nil {
+			return err
+		}
+		out = t
+	}
+
+	// Options go one per line so long parameter lists stay readable
+	var opts []string
+	if op.BodySchema() != nil {
+		opts = append(opts, "      body,")
+	}
+	for _, group := range []struct {
+		key    string
+		params []Parameter
+	}{{"query", query}, {"headers", header}} {
+		if len(group.params) == 0 {
+			continue
+		}
+		opts = append(opts, "      "+group.key+": {")
+		for _, p := range group.params {
+			opts = append(opts, fmt.Sprintf("        %s: params.%s,", quoteKey(p.Name), tsName(p.Name)))
+		}
+		opts = append(opts, "      },")
+	}
+
+	call := fmt.Sprintf("this.request<%s>('%s', %s", out, op.Method, g.pathExpr(op.Path))
+	switch {
+	case len(opts) == 1:
+		call += ", { " + strings.TrimSuffix(strings.TrimSpace(opts[0]), ",") + " })"
+	case len(opts) > 1:
+		call += ", {\n" + strings.Join(opts, "\n") + "\n    })"
+	default:
+		call += ")"
+	}
+
+	g.p("  /** %s (%s %s) */", strings.TrimSuffix(op.Summary, "."), op.Method, op.Path)
+	g.p("  %s(%s): Promise<%s> {", tsName(op.OperationID), strings.Join(args, ", "), out)
+	g.p("    return %s;", call)
+	g.p("  }")
+	return nil
+}
+
+// pathExpr returns a template literal building the path
+func (g *tsGen) pathExpr(path string) string {
+	if !strings.Contains(path, "{") {
+		return "'" + path + "'"
+	}
+	var sb strings.Builder
+	sb.WriteByte('`')
+	for path != "" {
+		start := strings.Index(path, "{")
+		if start < 0 {
+			sb.WriteString(path)
+			break
+		}
+		end := strings.Index(path, "}")
+		sb.WriteString(path[:start])
+		sb.WriteString("${encodeURIComponent(" + tsName(path[start+1:end]) + ")}")
+		path = path[end+1:]
+	}
+	sb.WriteByte('`')
+	return sb.String()
+}
+
+// comment emits a JSDoc comment when there is a description
+func (g *tsGen) comment(indent, description string) {
+	if description = strings.TrimSpace(description); description != "" {
+		g.p("%s/** %s */", indent, description)
+	}
+}
+
+// quoteKey quotes object keys that are not valid identifiers
+func quoteKey(name string) string {
+	if strings.ContainsAny(name, "-. ") {
+		return "'" + name + "'"
+	}
+	return name
+}
+
+// tsRuntime is the request plumbing shared by the generated methods
+const tsRuntime = `export interface ClientOptions {
+  /** Server URL, e.g. http://localhost:8080 */
+  baseUrl: string;
+  /** OAuth access token or service token */
+  token?: string;
+  fetch?: typeof fetch;
+}
+
+interface RequestOptions {
+  body?: unknown;
+  query?: Record<string, string | number | boolean | undefined>;
+  headers?: Record<string, string | undefined>;
+}
+
+/** Error status returned by the server, with the code and request ID of its error envelope */
+export class ApiError extends Error {
+  constructor(
+    public readonly status: number,
+    message: string,
+    public readonly body: unknown,
+    public readonly code?: string,
+    public readonly requestId?: string
+  ) {
+    super(message);
+    this.name = 'ApiError';
+  }
+}
+
+class BaseClient {
+  constructor(protected readonly options: ClientOptions) {}
+
+  protected async request<T>(method: string, path: string, opts: RequestOptions = {}): Promise<T> {
+    const url = new URL(path, this.options.baseUrl);
+    for (const [key, value] of Object.entries(opts.query ?? {})) {
+      if (value !== undefined && value !== '') {
+        url.searchParams.set(key, String(value));
+      }
+    }
+
+    const headers: Record<string, string> = { Accept: 'application/json' };
+    if (this.options.token) {
+      headers.Authorization = ` + "`Bearer ${this.options.token}`" + `;
+    }
+    if (opts.body !== undefined) {
+      headers['Content-Type'] = 'application/json';
+    }
+    for (const [key, value] of Object.entries(opts.headers ?? {})) {
+      if (value !== undefined) {
+        headers[key] = value;
+      }
+    }
+
+    const doFetch = this.options.fetch ?? fetch;
+    const response = await doFetch(url.toString(), {
+      method,
+      headers,
+      body: opts.body === undefined ? undefined : JSON.stringify(opts.body),
+    });
+
+    const text = await response.text();
+    let data: unknown = undefined;
+    try {
+      data = text ? JSON.parse(text) : undefined;
+    } catch {
+      data = text; // Error pages from proxies are not JSON
+    }
+    if (!response.ok) {
+      // {"error": {code, message, request_id}}, or {"error": message} from older servers
+      const error = (data as { error?: string | { code?: string; message?: string; request_id?: string } } | undefined)
+        ?.error;
+      if (error && typeof error === 'object') {
+        throw new ApiError(response.status, error.message ?? ` + "`HTTP ${response.status}`" + `, data, error.code, error.request_id);
+      }
+      throw new ApiError(response.status, error ?? ` + "`HTTP ${response.status}`" + `, data);
+    }
+    return data as T;
+  }
+}
+
+`
@@ -0,0 +1,72 @@
+package openapi
+
+import (
+	"strings"
+	"unicode"
+)
+
+// initialisms are written in capitals in Go names, as golint expects
+var initialisms = map[string]string{
+	"id":   "ID",
+	"url":  "URL",
+	"html": "HTML",
+	"api":  "API",
+	"json": "JSON",
+	"pdf":  "PDF",
+	"ids":  "IDs",
+	"urls": "URLs",
+}
+
+// words splits a property, parameter or operation name into lowercase words:
+// "collection_id", "originalUrl" and "Idempotency-Key" give
+// [collection id], [original url] and [idempotency key].
+func words(name string) []string {
+	var out []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			out = append(out, strings.ToLower(string(cur)))
+			cur = nil
+		}
+	}
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-' || r == ' ' || r == '.':
+			flush()
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))):
+			flush()
+			cur = append(cur, r)
+		default:
+			cur = append(cur, r)
+		}
+	}
+	flush()
+	return out
+}
+
+// goName returns the exported Go identifier for name
+func goName(name string) string {
+	var sb strings.Builder
+	for _, w := range words(name) {
+		if ini, ok := initialisms[w]; ok {
+			sb.WriteString(ini)
+			continue
+		}
+		sb.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return sb.String()
+}
+
+// tsName returns the lowerCamelCase TypeScript identifier for name
+func tsName(name string) string {
+	var sb strings.Builder
+	for i, w := range words(name) {
+		if i == 0 {
+			sb.WriteString(w)
+			continue
+		}
+		sb.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return sb.String()
+}
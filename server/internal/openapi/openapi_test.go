@@ -0,0 +1,120 @@
+package openapi
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"server/api"
+)
+
+func loadSpec(t *testing.T) *Spec {
+	t.Helper()
+	spec, err := Parse(api.Spec)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return spec
+}
+
+func TestParse(t *testing.T) {
+	spec := loadSpec(t)
+
+	op, ok := spec.Operation(http.MethodGet, "/api/v1/clips/{id}")
+	if !ok {
+		t.Fatal("getClip not found")
+	}
+	if op.OperationID != "getClip" || len(op.ParamsIn("path")) != 1 {
+		t.Errorf("path-level parameters not merged: %+v", op)
+	}
+
+	if _, err := Parse([]byte("paths:\n  /x:\n    get:\n      responses: {}\n")); err == nil {
+		t.Error("expected an error for a missing operationId")
+	}
+	if _, err := Parse([]byte("components:\n  schemas:\n    A:\n      $ref: '#/components/schemas/B'\n")); err == nil {
+		t.Error("expected an error for an unknown reference")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	spec := loadSpec(t)
+
+	tests := []struct {
+		method, path, want string
+	}{
+		{http.MethodGet, "/api/v1/clips", "listClips"},
+		{http.MethodPost, "/api/v1/clips/bulk", "bulkClips"},
+		{http.MethodGet, "/api/v1/clips/abc", "getClip"},
+		{http.MethodDelete, "/api/v1/collections/abc", "deleteCollection"},
+		{http.MethodPut, "/api/v1/clips/abc", ""},
+	}
+	for _, tt := range tests {
+		op, ok := spec.Match(tt.method, tt.path)
+		got := ""
+		if ok {
+			got = op.OperationID
+		}
+		if got != tt.want {
+			t.Errorf("Match(%s %s) = %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	spec := loadSpec(t)
+	schema := &Schema{Ref: "#/components/schemas/ClipDetail"}
+
+	valid := `{"id":"1","title":"T","url":"u","domain":"d","mode":"article","tags":null,
+		"created_at":"2026-10-16T12:00:00Z","path":"web-clips/x","location":{"latitude":1.5,"longitude":2}}`
+	if err := spec.Validate(schema, []byte(valid)); err != nil {
+		t.Errorf("valid document rejected: %v", err)
+	}
+
+	tests := []struct {
+		name, doc, want string
+	}{
+		{"missing required", `{"id":"1","title":"T","url":"u","domain":"d","mode":"m","tags":[],"created_at":"2026-10-16T12:00:00Z"}`, `missing required property "path"`},
+		{"undocumented property", `{"id":"1","title":"T","url":"u","domain":"d","mode":"m","tags":[],"created_at":"2026-10-16T12:00:00Z","path":"p","secret":1}`, `undocumented property "secret"`},
+		{"wrong type", `{"id":1,"title":"T","url":"u","domain":"d","mode":"m","tags":[],"created_at":"2026-10-16T12:00:00Z","path":"p"}`, "$.id: expected string"},
+		{"bad date", `{"id":"1","title":"T","url":"u","domain":"d","mode":"m","tags":[],"created_at":"yesterday","path":"p"}`, "invalid date-time"},
+		{"nested", `{"id":"1","title":"T","url":"u","domain":"d","mode":"m","tags":[2],"created_at":"2026-10-16T12:00:00Z","path":"p"}`, "$.tags[0]: expected string"},
+	}
+	for _, tt := range tests {
+		err := spec.Validate(schema, []byte(tt.doc))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	spec := loadSpec(t)
+
+	if err := spec.ValidateResponse(http.MethodDelete, "/api/v1/clips/x", http.StatusNoContent, nil); err != nil {
+		t.Errorf("empty 204: %v", err)
+	}
+	if err := spec.ValidateResponse(http.MethodGet, "/api/v1/clips/x", http.StatusNotFound, []byte(`{"error":{"code":"not_found","message":"clip not found","request_id":"r1"}}`)); err != nil {
+		t.Errorf("404 error body: %v", err)
+	}
+	if err := spec.ValidateResponse(http.MethodGet, "/api/v1/clips/x", http.StatusTeapot, []byte(`{}`)); err == nil {
+		t.Error("expected an error for an undocumented status")
+	}
+}
+
+func TestNames(t *testing.T) {
+	tests := []struct{ in, goWant, tsWant string }{
+		{"collection_id", "CollectionID", "collectionId"},
+		{"originalUrl", "OriginalURL", "originalUrl"},
+		{"Idempotency-Key", "IdempotencyKey", "idempotencyKey"},
+		{"ids", "IDs", "ids"},
+		{"getClip", "GetClip", "getClip"},
+	}
+	for _, tt := range tests {
+		if got := goName(tt.in); got != tt.goWant {
+			t.Errorf("goName(%q) = %q, want %q", tt.in, got, tt.goWant)
+		}
+		if got := tsName(tt.in); got != tt.tsWant {
+			t.Errorf("tsName(%q) = %q, want %q", tt.in, got, tt.tsWant)
+		}
+	}
+}
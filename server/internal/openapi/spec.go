@@ -0,0 +1,398 @@
+// Package openapi reads the subset of OpenAPI 3 used by api/openapi.yaml,
+// validates JSON documents against its schemas and generates the Go and
+// TypeScript client SDKs from it.
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is an OpenAPI document. Paths, schemas and properties keep the order
+// of the file so generated code follows it.
+type Spec struct {
+	Info       Info               `yaml:"info"`
+	Paths      Ordered[*PathItem] `yaml:"paths"`
+	Components Components         `yaml:"components"`
+
+	ops []*Operation
+}
+
+// Info is the document's metadata
+type Info struct {
+	Title   string `yaml:"title"`
+	Version string `yaml:"version"`
+}
+
+// Components holds the reusable schemas and responses
+type Components struct {
+	Schemas   Ordered[*Schema]   `yaml:"schemas"`
+	Responses Ordered[*Response] `yaml:"responses"`
+}
+
+// PathItem is the set of operations on one path
+type PathItem struct {
+	Parameters []Parameter `yaml:"parameters"`
+	Get        *Operation  `yaml:"get"`
+	Post       *Operation  `yaml:"post"`
+	Put        *Operation  `yaml:"put"`
+	Patch      *Operation  `yaml:"patch"`
+	Delete     *Operation  `yaml:"delete"`
+}
+
+// Operation is one method on a path
+type Operation struct {
+	OperationID string             `yaml:"operationId"`
+	Summary     string             `yaml:"summary"`
+	Parameters  []Parameter        `yaml:"parameters"`
+	RequestBody *RequestBody       `yaml:"requestBody"`
+	Responses   Ordered[*Response] `yaml:"responses"`
+
+	// Set by Parse
+	Method string `yaml:"-"`
+	Path   string `yaml:"-"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string  `yaml:"name"`
+	In          string  `yaml:"in"`
+	Description string  `yaml:"description"`
+	Required    bool    `yaml:"required"`
+	Schema      *Schema `yaml:"schema"`
+}
+
+// RequestBody is an operation's JSON body
+type RequestBody struct {
+	Required bool                 `yaml:"required"`
+	Content  map[string]MediaType `yaml:"content"`
+}
+
+// Response is one documented response status
+type Response struct {
+	Ref         string               `yaml:"$ref"`
+	Description string               `yaml:"description"`
+	Content     map[string]MediaType `yaml:"content"`
+}
+
+// MediaType is the schema of a body for one content type
+type MediaType struct {
+	Schema *Schema `yaml:"schema"`
+}
+
+// Schema is a JSON schema. Objects reject undeclared properties unless
+// additionalProperties is true, so new response fields must be documented.
+type Schema struct {
+	Ref                  string           `yaml:"$ref"`
+	Type                 string           `yaml:"type"`
+	Format               string           `yaml:"format"`
+	Description          string           `yaml:"description"`
+	Enum                 []string         `yaml:"enum"`
+	Nullable             bool             `yaml:"nullable"`
+	Required             []string         `yaml:"required"`
+	Properties           Ordered[*Schema] `yaml:"properties"`
+	AdditionalProperties bool             `yaml:"additionalProperties"`
+	Items                *Schema          `yaml:"items"`
+	AllOf                []*Schema        `yaml:"allOf"`
+}
+
+// Ordered is a YAML mapping that remembers the order of its keys
+type Ordered[T any] struct {
+	Keys   []string
+	Values map[string]T
+}
+
+// Get returns the value for key
+func (o Ordered[T]) Get(key string) (T, bool) {
+	v, ok := o.Values[key]
+	return v, ok
+}
+
+// UnmarshalYAML decodes a mapping, keeping its key order
+func (o *Ordered[T]) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping", node.Line)
+	}
+	o.Values = make(map[string]T, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		var v T
+		if err := node.Content[i+1].Decode(&v); err != nil {
+			return err
+		}
+		o.Keys = append(o.Keys, key)
+		o.Values[key] = v
+	}
+	return nil
+}
+
+// Parse reads an OpenAPI document and checks that every operation has an
+// ID and every reference resolves.
+func Parse(data []byte) (*Spec, error) {
+	spec := &Spec{}
+	if err := yaml.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	spec.ops = spec.collectOperations()
+
+	seen := map[string]bool{}
+	for _, op := range spec.ops {
+		if op.OperationID == "" {
+			return nil, fmt.Errorf("%s %s: missing operationId", op.Method, op.Path)
+		}
+		if seen[op.OperationID] {
+			return nil, fmt.Errorf("duplicate operationId %q", op.OperationID)
+		}
+		seen[op.OperationID] = true
+		for _, status := range op.Responses.Keys {
+			if _, err := spec.response(op.Responses.Values[status]); err != nil {
+				return nil, fmt.Errorf("%s: %w", op.OperationID, err)
+			}
+		}
+	}
+	for _, name := range spec.Components.Schemas.Keys {
+		if err := spec.checkRefs(spec.Components.Schemas.Values[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	return spec, nil
+}
+
+// Operations returns every operation in file order
+func (s *Spec) Operations() []*Operation {
+	return s.ops
+}
+
+// collectOperations lists the operations of every path, merging path-level
+// parameters into each one
+func (s *Spec) collectOperations() []*Operation {
+	var ops []*Operation
+	for _, path := range s.Paths.Keys {
+		item := s.Paths.Values[path]
+		for _, m := range []struct {
+			method string
+			op     *Operation
+		}{
+			{http.MethodGet, item.Get},
+			{http.MethodPost, item.Post},
+			{http.MethodPut, item.Put},
+			{http.MethodPatch, item.Patch},
+			{http.MethodDelete, item.Delete},
+		} {
+			if m.op == nil {
+				continue
+			}
+			m.op.Method = m.method
+			m.op.Path = path
+			m.op.Parameters = append(append([]Parameter{}, item.Parameters...), m.op.Parameters...)
+			ops = append(ops, m.op)
+		}
+	}
+	return ops
+}
+
+// Operation returns the operation for method and path template
+func (s *Spec) Operation(method, path string) (*Operation, bool) {
+	for _, op := range s.Operations() {
+		if op.Method == method && op.Path == path {
+			return op, true
+		}
+	}
+	return nil, false
+}
+
+// ParamsIn returns the operation's parameters in the given location
+func (op *Operation) ParamsIn(in string) []Parameter {
+	var params []Parameter
+	for _, p := range op.Parameters {
+		if p.In == in {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// BodySchema returns the JSON request body schema, if any
+func (op *Operation) BodySchema() *Schema {
+	if op.RequestBody == nil {
+		return nil
+	}
+	return op.RequestBody.Content["application/json"].Schema
+}
+
+// RawBody tells whether the operation takes a body that is not JSON (like
+// the chunks of an upload). The generators leave such operations to
+// hand-written code.
+func (op *Operation) RawBody() bool {
+	if op.RequestBody == nil {
+		return false
+	}
+	_, ok := op.RequestBody.Content["application/json"]
+	return !ok
+}
+
+// ResponseSchema returns the JSON schema documented for a status code, or
+// nil when that status has no body. It errors if the status is undocumented.
+func (s *Spec) ResponseSchema(op *Operation, status int) (*Schema, error) {
+	res, ok := op.Responses.Get(fmt.Sprint(status))
+	if !ok {
+		return nil, fmt.Errorf("%s: undocumented status %d", op.OperationID, status)
+	}
+	res, err := s.response(res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Content["application/json"].Schema, nil
+}
+
+// SuccessSchema returns the schema of the operation's first 2xx response
+func (s *Spec) SuccessSchema(op *Operation) *Schema {
+	for _, status := range op.Responses.Keys {
+		if strings.HasPrefix(status, "2") {
+			res, _ := s.response(op.Responses.Values[status])
+			if res != nil {
+				return res.Content["application/json"].Schema
+			}
+		}
+	}
+	return nil
+}
+
+// response follows a response reference
+func (s *Spec) response(res *Response) (*Response, error) {
+	if res.Ref == "" {
+		return res, nil
+	}
+	name := strings.TrimPrefix(res.Ref, "#/components/responses/")
+	target, ok := s.Components.Responses.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown response %q", res.Ref)
+	}
+	return target, nil
+}
+
+// Resolve follows a schema reference
+func (s *Spec) Resolve(schema *Schema) (*Schema, error) {
+	if schema.Ref == "" {
+		return schema, nil
+	}
+	target, ok := s.Components.Schemas.Get(RefName(schema.Ref))
+	if !ok {
+		return nil, fmt.Errorf("unknown schema %q", schema.Ref)
+	}
+	return target, nil
+}
+
+// RefName returns the component name a schema reference points to
+func RefName(ref string) string {
+	return strings.TrimPrefix(ref, "#/components/schemas/")
+}
+
+// checkRefs reports references that do not resolve
+func (s *Spec) checkRefs(schema *Schema) error {
+	if schema == nil {
+		return nil
+	}
+	if _, err := s.Resolve(schema); err != nil {
+		return err
+	}
+	for _, name := range schema.Properties.Keys {
+		if err := s.checkRefs(schema.Properties.Values[name]); err != nil {
+			return err
+		}
+	}
+	for _, sub := range schema.AllOf {
+		if err := s.checkRefs(sub); err != nil {
+			return err
+		}
+	}
+	return s.checkRefs(schema.Items)
+}
+
+// fields flattens an object schema (including allOf parts) into its
+// properties and required names
+func (s *Spec) fields(schema *Schema) (Ordered[*Schema], map[string]bool, error) {
+	props := Ordered[*Schema]{Values: map[string]*Schema{}}
+	required := map[string]bool{}
+
+	var collect func(*Schema) error
+	collect = func(sc *Schema) error {
+		sc, err := s.Resolve(sc)
+		if err != nil {
+			return err
+		}
+		for _, part := range sc.AllOf {
+			if err := collect(part); err != nil {
+				return err
+			}
+		}
+		for _, name := range sc.Properties.Keys {
+			if _, dup := props.Values[name]; !dup {
+				props.Keys = append(props.Keys, name)
+			}
+			props.Values[name] = sc.Properties.Values[name]
+		}
+		for _, name := range sc.Required {
+			required[name] = true
+		}
+		return nil
+	}
+	return props, required, collect(schema)
+}
+
+// Match returns the operation whose path template matches a request path,
+// preferring literal segments over parameters
+func (s *Spec) Match(method, path string) (*Operation, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	var best *Operation
+	bestParams := -1
+	for _, op := range s.Operations() {
+		if op.Method != method {
+			continue
+		}
+		tmpl := strings.Split(strings.Trim(op.Path, "/"), "/")
+		if len(tmpl) != len(segments) {
+			continue
+		}
+		params := 0
+		for i, seg := range tmpl {
+			if strings.HasPrefix(seg, "{") {
+				params++
+			} else if seg != segments[i] {
+				params = -1
+				break
+			}
+		}
+		if params >= 0 && (best == nil || params < bestParams) {
+			best, bestParams = op, params
+		}
+	}
+	return best, best != nil
+}
+
+// ValidateResponse checks a response to method and path: its status must be
+// documented and its body must match that status' schema.
+func (s *Spec) ValidateResponse(method, path string, status int, body []byte) error {
+	op, ok := s.Match(method, path)
+	if !ok {
+		return fmt.Errorf("%s %s is not in the API spec", method, path)
+	}
+	schema, err := s.ResponseSchema(op, status)
+	if err != nil {
+		return err
+	}
+	if schema == nil {
+		if len(bytes.TrimSpace(body)) > 0 && !bytes.Equal(bytes.TrimSpace(body), []byte("null")) {
+			return fmt.Errorf("%s: status %d has no documented body, got %q", op.OperationID, status, body)
+		}
+		return nil
+	}
+	if err := s.Validate(schema, body); err != nil {
+		return fmt.Errorf("%s (%d): %w", op.OperationID, status, err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every place a document differs from its schema
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "response does not match the API spec:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Validate checks a JSON document against a schema. Objects must have their
+// required properties and, unless the schema allows it, nothing else.
+func (s *Spec) Validate(schema *Schema, data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	var problems []string
+	s.validate(schema, v, "$", &problems)
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func (s *Spec) validate(schema *Schema, v interface{}, at string, problems *[]string) {
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	schema, err := s.Resolve(schema)
+	if err != nil {
+		fail("%v", err)
+		return
+	}
+	if v == nil {
+		if !schema.Nullable {
+			fail("is null")
+		}
+		return
+	}
+
+	if len(schema.AllOf) > 0 || schema.Type == "object" {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			fail("expected object, got %s", jsonType(v))
+			return
+		}
+		props, required, err := s.fields(schema)
+		if err != nil {
+			fail("%v", err)
+			return
+		}
+		for _, name := range props.Keys {
+			if val, ok := obj[name]; ok {
+				s.validate(props.Values[name], val, at+"."+name, problems)
+			} else if required[name] {
+				fail("missing required property %q", name)
+			}
+		}
+		if !schema.AdditionalProperties {
+			var extra []string
+			for name := range obj {
+				if _, ok := props.Values[name]; !ok {
+					extra = append(extra, name)
+				}
+			}
+			sort.Strings(extra)
+			for _, name := range extra {
+				fail("undocumented property %q", name)
+			}
+		}
+		return
+	}
+
+	switch schema.Type {
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			fail("expected array, got %s", jsonType(v))
+			return
+		}
+		for i, item := range arr {
+			s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", at, i), problems)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			fail("expected string, got %s", jsonType(v))
+			return
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				fail("invalid date-time %q", str)
+			}
+		}
+		if len(schema.Enum) > 0 && !contains(schema.Enum, str) {
+			fail("%q is not one of %s", str, strings.Join(schema.Enum, ", "))
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			fail("expected integer, got %s", jsonType(v))
+			return
+		}
+		if _, err := n.Int64(); err != nil {
+			fail("expected integer, got %s", n)
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			fail("expected number, got %s", jsonType(v))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail("expected boolean, got %s", jsonType(v))
+		}
+	}
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package passhash
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashVerify(t *testing.T) {
+	hash, err := Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Errorf("unexpected encoding %q", hash)
+	}
+	if !Verify("correct horse", hash) {
+		t.Error("Verify rejected the password")
+	}
+	if Verify("battery staple", hash) {
+		t.Error("Verify accepted a wrong password")
+	}
+
+	// Salts are random
+	other, _ := Hash("correct horse")
+	if other == hash {
+		t.Error("two hashes of a password are equal")
+	}
+}
+
+func TestVerify_Malformed(t *testing.T) {
+	for _, encoded := range []string{
+		"",
+		"plain",
+		"$argon2i$v=19$m=19456,t=2,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=16$m=19456,t=2,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=19456,t=0,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=19456,t=2,p=1$!!$aGFzaA",
+		"$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$",
+	} {
+		if Verify("", encoded) {
+			t.Errorf("Verify accepted %q", encoded)
+		}
+	}
+}
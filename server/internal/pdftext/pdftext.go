@@ -0,0 +1,69 @@
+// Package pdftext extracts the text layer of PDF documents.
+package pdftext
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// magic is the header every PDF file starts with.
+var magic = []byte("%PDF-")
+
+// IsPDF reports whether data looks like a PDF document.
+func IsPDF(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Extractor shells out to pdftotext (poppler-utils).
+type Extractor struct {
+	Path string // Binary path (default: "pdftotext" from PATH)
+}
+
+// Extract returns the document's text with whitespace normalized.
+// Scanned PDFs without a text layer yield an empty string.
+func (e *Extractor) Extract(ctx context.Context, data []byte) (string, error) {
+	if !IsPDF(data) {
+		return "", fmt.Errorf("not a PDF document")
+	}
+
+	bin := e.Path
+	if bin == "" {
+		bin = "pdftotext"
+	}
+
+	cmd := exec.CommandContext(ctx, bin, "-q", "-enc", "UTF-8", "-", "-")
+	cmd.Stdin = bytes.NewReader(data)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("pdftotext failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return normalize(stdout.String()), nil
+}
+
+// normalize collapses runs of spaces and blank lines left by page layout
+func normalize(text string) string {
+	text = strings.ReplaceAll(text, "\f", "\n") // Page breaks
+	lines := strings.Split(text, "\n")
+
+	var out []string
+	blank := false
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			blank = len(out) > 0
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,65 @@
+package pdftext
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+)
+
+func TestIsPDF(t *testing.T) {
+	if !IsPDF([]byte("%PDF-1.7\n...")) {
+		t.Error("expected PDF header to be detected")
+	}
+	if IsPDF([]byte("\x89PNG\r\n")) {
+		t.Error("PNG detected as PDF")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	input := "  Title   here \n\n\n\fSecond   page\nline two\n\n"
+	expected := "Title here\n\nSecond page\nline two"
+	if got := normalize(input); got != expected {
+		t.Errorf("normalize() = %q, want %q", got, expected)
+	}
+}
+
+func TestExtractRejectsNonPDF(t *testing.T) {
+	e := &Extractor{}
+	if _, err := e.Extract(context.Background(), []byte("hello")); err == nil {
+		t.Error("expected error for non-PDF input")
+	}
+}
+
+func TestExtractMissingBinary(t *testing.T) {
+	e := &Extractor{Path: "/nonexistent/pdftotext"}
+	if _, err := e.Extract(context.Background(), []byte("%PDF-1.4\n")); err == nil {
+		t.Error("expected error when pdftotext is missing")
+	}
+}
+
+func TestExtract(t *testing.T) {
+	if _, err := exec.LookPath("pdftotext"); err != nil {
+		t.Skip("pdftotext not installed")
+	}
+
+	text, err := (&Extractor{}).Extract(context.Background(), []byte(minimalPDF))
+	if err != nil {
+		t.Fatalf("Extract() failed: %v", err)
+	}
+	if text != "Hello PDF" {
+		t.Errorf("Extract() = %q, want %q", text, "Hello PDF")
+	}
+}
+
+// minimalPDF is a single-page document containing "Hello PDF"
+const minimalPDF = `%PDF-1.4
+1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj
+2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj
+3 0 obj << /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >> endobj
+4 0 obj << /Length 40 >> stream
+BT /F1 12 Tf 20 50 Td (Hello PDF) Tj ET
+endstream endobj
+5 0 obj << /Type /Font /Subtype /Type1 /BaseFont /Helvetica >> endobj
+trailer << /Root 1 0 R >>
+%%EOF
+`
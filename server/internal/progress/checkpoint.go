@@ -0,0 +1,111 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Checkpoint records which items of a job are complete. It is saved
+// periodically while the job runs, so rerunning the same job after an
+// interruption skips the finished items.
+type Checkpoint struct {
+	Job       string    `json:"job"`  // Identifies the job; a checkpoint from another job is rejected
+	Done      []string  `json:"done"` // Keys of completed items, in completion order
+	Bytes     int64     `json:"bytes"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	path     string
+	done     map[string]bool
+	unsaved  int
+	lastSave time.Time
+}
+
+const (
+	saveEvery    = 100
+	saveInterval = 5 * time.Second
+)
+
+// LoadCheckpoint reads the checkpoint at path, or starts a new one when the
+// file does not exist.
+func LoadCheckpoint(path, job string) (*Checkpoint, error) {
+	cp := &Checkpoint{Job: job, path: path, done: map[string]bool{}, lastSave: time.Now()}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+	if cp.Job != job {
+		return nil, fmt.Errorf("checkpoint %s belongs to another job (%s); remove it to start over", path, cp.Job)
+	}
+	for _, key := range cp.Done {
+		cp.done[key] = true
+	}
+	return cp, nil
+}
+
+// Resumed reports whether the checkpoint was loaded from a previous run
+func (c *Checkpoint) Resumed() bool {
+	return len(c.Done) > 0
+}
+
+// IsDone reports whether the item was completed
+func (c *Checkpoint) IsDone(key string) bool {
+	return c.done[key]
+}
+
+// Mark records a completed item, saving the checkpoint every few items
+func (c *Checkpoint) Mark(key string, bytes int64) error {
+	if c.done[key] {
+		return nil
+	}
+	c.done[key] = true
+	c.Done = append(c.Done, key)
+	c.Bytes += bytes
+	c.unsaved++
+
+	if c.unsaved >= saveEvery || time.Since(c.lastSave) >= saveInterval {
+		return c.Save()
+	}
+	return nil
+}
+
+// Save writes the checkpoint atomically (temp file + rename)
+func (c *Checkpoint) Save() error {
+	c.UpdatedAt = time.Now()
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	c.unsaved = 0
+	c.lastSave = time.Now()
+	return nil
+}
+
+// Remove deletes the checkpoint once the job has finished
+func (c *Checkpoint) Remove() error {
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
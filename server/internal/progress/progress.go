@@ -0,0 +1,156 @@
+// Package progress reports progress for long-running CLI jobs and records
+// checkpoints so an interrupted job can resume where it stopped.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Bar renders item and byte counts with an ETA. On a terminal it redraws a
+// single line; otherwise (logs, pipes) it prints a line every logInterval.
+type Bar struct {
+	w          io.Writer
+	label      string
+	total      int
+	totalBytes int64
+
+	done      int
+	bytes     int64
+	skipped   int   // Items done in an earlier run, excluded from the rate
+	skipBytes int64 // Bytes done in an earlier run, excluded from the rate
+
+	tty      bool
+	start    time.Time
+	lastDraw time.Time
+}
+
+const (
+	ttyInterval = 100 * time.Millisecond
+	logInterval = 10 * time.Second
+)
+
+// New creates a bar for total items; totalBytes may be 0 when sizes are unknown.
+func New(w io.Writer, label string, total int, totalBytes int64) *Bar {
+	return &Bar{
+		w:          w,
+		label:      label,
+		total:      total,
+		totalBytes: totalBytes,
+		tty:        isTerminal(w),
+		start:      time.Now(),
+	}
+}
+
+// Skip counts items already completed by a previous run
+func (b *Bar) Skip(items int, bytes int64) {
+	b.done += items
+	b.bytes += bytes
+	b.skipped += items
+	b.skipBytes += bytes
+	b.draw(false)
+}
+
+// Add counts items completed in this run
+func (b *Bar) Add(items int, bytes int64) {
+	b.done += items
+	b.bytes += bytes
+	b.draw(false)
+}
+
+// Finish draws the final state and ends the line
+func (b *Bar) Finish() {
+	b.draw(true)
+	if b.tty {
+		fmt.Fprintln(b.w)
+	}
+}
+
+func (b *Bar) draw(force bool) {
+	interval := logInterval
+	if b.tty {
+		interval = ttyInterval
+	}
+	now := time.Now()
+	if !force && now.Sub(b.lastDraw) < interval {
+		return
+	}
+	b.lastDraw = now
+
+	if b.tty {
+		fmt.Fprintf(b.w, "\r\033[K%s", b.String())
+	} else {
+		fmt.Fprintln(b.w, b.String())
+	}
+}
+
+// String formats the current state, e.g.
+// "Copying  1200/20000 (6%)  120.5 MB/1.9 GB  ETA 3m12s"
+func (b *Bar) String() string {
+	var sb strings.Builder
+	sb.WriteString(b.label)
+	fmt.Fprintf(&sb, "  %d/%d", b.done, b.total)
+	if b.total > 0 {
+		fmt.Fprintf(&sb, " (%d%%)", b.done*100/b.total)
+	}
+	if b.totalBytes > 0 {
+		fmt.Fprintf(&sb, "  %s/%s", FormatBytes(b.bytes), FormatBytes(b.totalBytes))
+	}
+	if eta, ok := b.ETA(); ok {
+		fmt.Fprintf(&sb, "  ETA %s", eta.Round(time.Second))
+	}
+	return sb.String()
+}
+
+// ETA estimates the remaining time from this run's throughput, by bytes when
+// sizes are known and by items otherwise.
+func (b *Bar) ETA() (time.Duration, bool) {
+	elapsed := time.Since(b.start)
+	if elapsed <= 0 {
+		return 0, false
+	}
+	if b.totalBytes > 0 {
+		done := b.bytes - b.skipBytes
+		if done <= 0 {
+			return 0, false
+		}
+		remaining := b.totalBytes - b.bytes
+		return time.Duration(float64(elapsed) * float64(remaining) / float64(done)), true
+	}
+	done := b.done - b.skipped
+	if done <= 0 {
+		return 0, false
+	}
+	remaining := b.total - b.done
+	return time.Duration(float64(elapsed) * float64(remaining) / float64(done)), true
+}
+
+// FormatBytes formats a byte count with a binary unit (KB, MB, GB, ...)
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// isTerminal reports whether w is a character device such as a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,83 @@
+package progress
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{
+		0:           "0 B",
+		1023:        "1023 B",
+		1536:        "1.5 KB",
+		5 << 20:     "5.0 MB",
+		3 << 30 / 2: "1.5 GB",
+	} {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestBar(t *testing.T) {
+	out := new(bytes.Buffer)
+	bar := New(out, "Copying", 4, 400)
+
+	// Skipped work does not count toward the rate, so there is no ETA yet
+	bar.Skip(2, 200)
+	if _, ok := bar.ETA(); ok {
+		t.Error("expected no ETA before any work in this run")
+	}
+
+	bar.Add(1, 100)
+	if _, ok := bar.ETA(); !ok {
+		t.Error("expected an ETA after work in this run")
+	}
+	if s := bar.String(); !strings.HasPrefix(s, "Copying  3/4 (75%)  300 B/400 B") {
+		t.Errorf("String() = %q", s)
+	}
+
+	bar.Add(1, 100)
+	bar.Finish()
+	if !strings.Contains(out.String(), "4/4 (100%)") {
+		t.Errorf("final output = %q", out.String())
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.json")
+
+	cp, err := LoadCheckpoint(path, "copy a -> b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Resumed() {
+		t.Error("new checkpoint reports resumed")
+	}
+	cp.Mark("one", 10)
+	cp.Mark("two", 20)
+	if err := cp.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err = LoadCheckpoint(path, "copy a -> b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cp.Resumed() || !cp.IsDone("one") || !cp.IsDone("two") || cp.IsDone("three") || cp.Bytes != 30 {
+		t.Errorf("reloaded checkpoint = %+v", cp)
+	}
+
+	if _, err := LoadCheckpoint(path, "copy a -> c"); err == nil {
+		t.Error("expected an error for another job's checkpoint")
+	}
+
+	if err := cp.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if cp, _ := LoadCheckpoint(path, "copy a -> c"); cp == nil || cp.Resumed() {
+		t.Error("expected a fresh checkpoint after Remove")
+	}
+}
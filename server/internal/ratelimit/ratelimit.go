@@ -0,0 +1,112 @@
+// Package ratelimit limits how often clients make requests, with token
+// buckets kept in memory or, to share them between instances, in Redis.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit lets Burst requests through at once, the bucket refilling at
+// PerMinute requests a minute. Burst defaults to PerMinute.
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+// rate is how many requests a second the bucket refills with
+func (l Limit) rate() float64 {
+	return float64(l.PerMinute) / 60
+}
+
+// capacity is how many requests the bucket holds
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.PerMinute)
+}
+
+// Result is the answer to a request
+type Result struct {
+	Allowed    bool
+	Remaining  int           // Requests that can be made right away
+	RetryAfter time.Duration // When not allowed, until one can
+}
+
+// Store keeps the buckets of clients, by key
+type Store interface {
+	// Take takes a request out of the bucket of key, if it has one left
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// result returns the answer to a request, given the tokens left in its
+// bucket after taking it (when allowed)
+func result(allowed bool, tokens float64, limit Limit) Result {
+	res := Result{Allowed: allowed, Remaining: int(math.Floor(tokens))}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / limit.rate() * float64(time.Second))
+	}
+	return res
+}
+
+// bucket is the state of a client: its tokens at the time of its last
+// request
+type bucket struct {
+	tokens float64
+	at     time.Time
+}
+
+// take refills the bucket up to now and takes a token out of it if it has one
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	if elapsed := now.Sub(b.at).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(limit.capacity(), b.tokens+elapsed*limit.rate())
+	}
+	b.at = now
+	if b.tokens < 1 {
+		return result(false, b.tokens, limit)
+	}
+	b.tokens--
+	return result(true, b.tokens, limit)
+}
+
+// Memory keeps the buckets in the process: limits apply per instance and
+// reset on restart.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// NewMemory creates an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]*bucket{}}
+}
+
+// Take takes a request out of the bucket of key.
+func (m *Memory) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.swept) > time.Minute {
+		m.sweep(now)
+	}
+	b := m.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: limit.capacity(), at: now}
+		m.buckets[key] = b
+	}
+	return b.take(limit, now), nil
+}
+
+// sweep forgets the clients idle for an hour, whose bucket has refilled
+// unless their limit takes longer to
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if now.Sub(b.at) > time.Hour {
+			delete(m.buckets, key)
+		}
+	}
+	m.swept = now
+}
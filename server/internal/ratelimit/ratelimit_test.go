@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+	limit := Limit{PerMinute: 60, Burst: 2}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if res, _ := m.Take(ctx, "a", limit, now); !res.Allowed || res.Remaining != 1-i {
+			t.Fatalf("request %d: %+v", i, res)
+		}
+	}
+	res, _ := m.Take(ctx, "a", limit, now)
+	if res.Allowed || res.RetryAfter != time.Second {
+		t.Errorf("over the burst: %+v", res)
+	}
+	if res, _ := m.Take(ctx, "b", limit, now); !res.Allowed {
+		t.Error("keys share a bucket")
+	}
+
+	// A token a second comes back, up to the burst
+	if res, _ := m.Take(ctx, "a", limit, now.Add(1500*time.Millisecond)); !res.Allowed {
+		t.Errorf("not refilled: %+v", res)
+	}
+	if res, _ := m.Take(ctx, "a", limit, now.Add(time.Hour)); !res.Allowed || res.Remaining != 1 {
+		t.Errorf("refilled over the burst: %+v", res)
+	}
+
+	// Idle clients are forgotten
+	m.Take(ctx, "c", limit, now.Add(3*time.Hour))
+	if _, ok := m.buckets["b"]; ok {
+		t.Error("idle bucket kept")
+	}
+}
+
+func TestNewRedis(t *testing.T) {
+	r, err := NewRedis("redis://:secret@cache:6380/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.addr != "cache:6380" || r.password != "secret" || r.db != 2 || r.tls {
+		t.Errorf("unexpected store: %+v", r)
+	}
+	if r, _ = NewRedis("rediss://cache"); r.addr != "cache:6379" || !r.tls {
+		t.Errorf("unexpected store: %+v", r)
+	}
+	for _, bad := range []string{"http://cache", "redis://", "redis://cache/db"} {
+		if _, err := NewRedis(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestRedisTake(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	defer ln.Close()
+
+	commands := make(chan []string, 4)
+	replies := []string{"+OK\r\n", "*2\r\n:1\r\n$3\r\n4.5\r\n", "*2\r\n:0\r\n$4\r\n0.25\r\n"}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		for _, reply := range replies {
+			cmd, err := readReply(rd)
+			if err != nil {
+				return
+			}
+			var args []string
+			for _, arg := range cmd.([]any) {
+				args = append(args, arg.(string))
+			}
+			commands <- args
+			conn.Write([]byte(reply))
+		}
+	}()
+
+	r, err := NewRedis("redis://:pw@" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit := Limit{PerMinute: 60, Burst: 10}
+	res, err := r.Take(context.Background(), "ip:192.0.2.1", limit, time.Unix(1000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Allowed || res.Remaining != 4 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if auth := <-commands; strings.Join(auth, " ") != "AUTH pw" {
+		t.Errorf("unexpected AUTH: %v", auth)
+	}
+	eval := <-commands
+	if eval[0] != "EVAL" || eval[3] != "web-clipper:ratelimit:ip:192.0.2.1" || eval[4] != "10" || eval[5] != "1" || eval[6] != "1000.000000" {
+		t.Errorf("unexpected EVAL: %q", eval[3:])
+	}
+
+	res, err = r.Take(context.Background(), "ip:192.0.2.1", limit, time.Unix(1000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed || res.RetryAfter != 750*time.Millisecond {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
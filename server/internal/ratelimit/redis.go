@@ -0,0 +1,226 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// takeScript refills the bucket of KEYS[1] (ARGV: capacity, rate a second,
+// now in seconds) and takes a token out of it if it has one. It returns
+// whether it did and the tokens left, as a string since Redis truncates
+// numbers to integers.
+const takeScript = `
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'at')
+local tokens = tonumber(state[1]) or capacity
+local at = tonumber(state[2]) or now
+if now > at then
+  tokens = math.min(capacity, tokens + (now - at) * rate)
+  at = now
+end
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'at', tostring(at))
+redis.call('EXPIRE', KEYS[1], math.ceil(capacity / rate) + 1)
+return {allowed, tostring(tokens)}
+`
+
+// Redis keeps the buckets in a Redis server, for instances to share limits.
+// It has one connection, requests waiting for each other, which is plenty
+// for the traffic of a small instance.
+type Redis struct {
+	addr     string
+	password string
+	db       int
+	tls      bool
+	prefix   string // Of the keys
+	timeout  time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// NewRedis creates a Redis store from a URL
+// (redis://[:password@]host[:port][/db], rediss:// for TLS). The connection
+// is made on first use.
+func NewRedis(rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid Redis URL: expected redis://[:password@]host[:port][/db]")
+	}
+	r := &Redis{
+		addr:    u.Host,
+		tls:     u.Scheme == "rediss",
+		prefix:  "web-clipper:ratelimit:",
+		timeout: 2 * time.Second,
+	}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		r.password, _ = u.User.Password()
+		if r.password == "" {
+			r.password = u.User.Username() // redis://password@host
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid Redis URL: database %q is not a number", db)
+		}
+	}
+	return r, nil
+}
+
+// Take takes a request out of the bucket of key.
+func (r *Redis) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	reply, err := r.do(ctx, "EVAL", takeScript, "1", r.prefix+key,
+		strconv.FormatFloat(limit.capacity(), 'f', -1, 64),
+		strconv.FormatFloat(limit.rate(), 'f', -1, 64),
+		strconv.FormatFloat(float64(now.UnixMicro())/1e6, 'f', 6, 64))
+	if err != nil {
+		return Result{}, err
+	}
+	values, ok := reply.([]any)
+	if !ok || len(values) != 2 {
+		return Result{}, fmt.Errorf("redis: unexpected reply %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	tokensReply, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(tokensReply, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("redis: unexpected reply %v", reply)
+	}
+	return result(allowed == 1, tokens, limit), nil
+}
+
+// do sends a command and reads its reply, connecting first if needed. The
+// connection is dropped on errors, to start afresh on the next command.
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		if err := r.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := r.roundTrip(ctx, args)
+	if err != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+	return reply, err
+}
+
+// connect opens the connection, authenticates and selects the database
+func (r *Redis) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: r.timeout}
+	var conn net.Conn
+	var err error
+	if r.tls {
+		conn, err = (&tls.Dialer{NetDialer: dialer}).DialContext(ctx, "tcp", r.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", r.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	r.conn, r.rd = conn, bufio.NewReader(conn)
+
+	var setup [][]string
+	if r.password != "" {
+		setup = append(setup, []string{"AUTH", r.password})
+	}
+	if r.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.db)})
+	}
+	for _, args := range setup {
+		if _, err := r.roundTrip(ctx, args); err != nil {
+			conn.Close()
+			r.conn = nil
+			return fmt.Errorf("redis %s: %w", args[0], err)
+		}
+	}
+	return nil
+}
+
+// roundTrip writes a command and reads its reply
+func (r *Redis) roundTrip(ctx context.Context, args []string) (any, error) {
+	deadline := time.Now().Add(r.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	r.conn.SetDeadline(deadline)
+
+	var cmd strings.Builder
+	fmt.Fprintf(&cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(r.conn, cmd.String()); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return readReply(r.rd)
+}
+
+// readReply reads a RESP reply: strings are returned as string, integers as
+// int64, arrays as []any and nil ones as nil. Error replies are returned
+// as errors.
+func readReply(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2) // With its \r\n
+		if _, err := io.ReadFull(rd, data); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		values := make([]any, n)
+		for i := range values {
+			if values[i], err = readReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
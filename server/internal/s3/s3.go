@@ -0,0 +1,273 @@
+// Package s3 is a minimal client for S3 and S3-compatible object storage:
+// just enough (put, list, delete) to publish a static site to a bucket.
+// Requests are signed with AWS Signature Version 4.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"server/internal/config"
+)
+
+// Client talks to one bucket.
+type Client struct {
+	cfg        config.S3Config
+	httpClient *http.Client
+}
+
+// New creates a Client from S3 configuration.
+func New(cfg config.S3Config) *Client {
+	return &Client{cfg: cfg, httpClient: &http.Client{Timeout: time.Minute}}
+}
+
+// Configured reports whether a bucket and credentials are set.
+func (c *Client) Configured() bool {
+	return c.cfg.Bucket != "" && c.cfg.AccessKeyID != "" && c.cfg.SecretAccessKey != ""
+}
+
+// Put stores an object.
+func (c *Client) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	_, err := c.do(ctx, http.MethodPut, key, nil, header, data)
+	return err
+}
+
+// Get returns the content of an object.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	return c.send(ctx, http.MethodGet, key, nil, nil, nil, maxObjectBytes)
+}
+
+// Delete removes an object; deleting a missing object is not an error.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	_, err := c.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	return err
+}
+
+// listResult is the part of a ListObjectsV2 response we use
+type listResult struct {
+	Contents              []listObject `xml:"Contents"`
+	IsTruncated           bool         `xml:"IsTruncated"`
+	NextContinuationToken string       `xml:"NextContinuationToken"`
+}
+
+type listObject struct {
+	Key  string `xml:"Key"`
+	Size int64  `xml:"Size"`
+}
+
+// List returns the keys of the objects under prefix.
+func (c *Client) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := c.list(ctx, prefix, func(o listObject) { keys = append(keys, o.Key) })
+	return keys, err
+}
+
+// Sizes returns the size of the objects under prefix, by key.
+func (c *Client) Sizes(ctx context.Context, prefix string) (map[string]int64, error) {
+	sizes := map[string]int64{}
+	err := c.list(ctx, prefix, func(o listObject) { sizes[o.Key] = o.Size })
+	return sizes, err
+}
+
+// list calls fn with each object under prefix
+func (c *Client) list(ctx context.Context, prefix string, fn func(listObject)) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		body, err := c.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		var result listResult
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return fmt.Errorf("s3: invalid list response: %w", err)
+		}
+		for _, object := range result.Contents {
+			fn(object)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// objectURL returns the URL of key: path-style on a custom endpoint,
+// virtual-hosted on AWS
+func (c *Client) objectURL(key string, query url.Values) *url.URL {
+	u := &url.URL{Scheme: "https", Host: c.cfg.Bucket + ".s3." + c.cfg.Region + ".amazonaws.com", Path: "/" + key}
+	if c.cfg.Endpoint != "" {
+		if endpoint, err := url.Parse(strings.TrimSuffix(c.cfg.Endpoint, "/")); err == nil {
+			u = &url.URL{Scheme: endpoint.Scheme, Host: endpoint.Host, Path: endpoint.Path + "/" + c.cfg.Bucket + "/" + key}
+		}
+	}
+	u.RawPath = escapePath(u.Path)
+	u.RawQuery = canonicalQuery(query)
+	return u
+}
+
+// Response bodies read: objects, and the rest (listings, errors)
+const (
+	maxObjectBytes   = 1 << 30
+	maxResponseBytes = 16 << 20
+)
+
+// do sends a signed request and returns the response body
+func (c *Client) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) ([]byte, error) {
+	return c.send(ctx, method, key, query, header, body, maxResponseBytes)
+}
+
+// send sends a signed request and returns the response body, of at most
+// limit bytes
+func (c *Client) send(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.objectURL(key, query).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	sum := sha256.Sum256(body)
+	signV4(req, "s3", c.cfg.Region, c.cfg.AccessKeyID, c.cfg.SecretAccessKey, hex.EncodeToString(sum[:]), time.Now())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("s3: %s %s: response over %d bytes", method, key, limit)
+	}
+	if resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(data))
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		return nil, fmt.Errorf("s3: %s %s: %s: %s", method, key, resp.Status, msg)
+	}
+	return data, nil
+}
+
+// signV4 adds the headers of AWS Signature Version 4 to req. payloadHash is
+// the hex SHA-256 of the body; it is also sent as x-amz-content-sha256 for S3.
+func signV4(req *http.Request, service, region, accessKey, secretKey, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	// Host plus the x-amz-* headers are signed
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by name, as signed
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, escape(name)+"="+escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath escapes each segment of a path the way SigV4 expects
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// escape percent-encodes everything but the unreserved characters
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,140 @@
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"server/internal/config"
+)
+
+func TestSignV4(t *testing.T) {
+	// get-vanilla from the AWS Signature Version 4 test suite
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	emptyHash := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	signV4(req, "service", "us-east-1", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", emptyHash, now)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+}
+
+// fakeBucket is an in-memory S3 bucket served path-style
+type fakeBucket struct {
+	mu      sync.Mutex
+	objects map[string]string
+	types   map[string]string
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") || r.Header.Get("X-Amz-Content-Sha256") == "" {
+		http.Error(w, "unsigned", http.StatusForbidden)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		b.objects[key] = string(data)
+		b.types[key] = r.Header.Get("Content-Type")
+	case http.MethodDelete:
+		delete(b.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		if r.URL.Query().Get("list-type") == "" {
+			data, ok := b.objects[key]
+			if !ok {
+				http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(data))
+			return
+		}
+		var result listResult
+		var keys []string
+		for k := range b.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			result.Contents = append(result.Contents, listObject{Key: k, Size: int64(len(b.objects[k]))})
+		}
+		xml.NewEncoder(w).Encode(struct {
+			XMLName xml.Name `xml:"ListBucketResult"`
+			listResult
+		}{listResult: result})
+	}
+}
+
+func TestClient(t *testing.T) {
+	bucket := &fakeBucket{objects: map[string]string{}, types: map[string]string{}}
+	srv := httptest.NewServer(bucket)
+	defer srv.Close()
+
+	c := New(config.S3Config{Bucket: "bucket", Region: "eu-west-1", Endpoint: srv.URL, AccessKeyID: "key", SecretAccessKey: "secret"})
+	if !c.Configured() {
+		t.Fatal("client not configured")
+	}
+	ctx := context.Background()
+
+	if err := c.Put(ctx, "site/index.html", []byte("<h1>Hi</h1>"), "text/html"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put(ctx, "site/a b/index.json", []byte("{}"), "application/json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Put(ctx, "other.txt", []byte("x"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if bucket.objects["site/a b/index.json"] != "{}" || bucket.types["site/index.html"] != "text/html" {
+		t.Errorf("objects = %v, types = %v", bucket.objects, bucket.types)
+	}
+
+	keys, err := c.List(ctx, "site/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(keys, ",") != "site/a b/index.json,site/index.html" {
+		t.Errorf("List = %v", keys)
+	}
+
+	sizes, err := c.Sizes(ctx, "site/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 2 || sizes["site/index.html"] != 11 {
+		t.Errorf("Sizes = %v", sizes)
+	}
+
+	if data, err := c.Get(ctx, "site/a b/index.json"); err != nil || string(data) != "{}" {
+		t.Errorf("Get = %q, %v", data, err)
+	}
+	if _, err := c.Get(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Get of a missing object: %v", err)
+	}
+
+	if err := c.Delete(ctx, "site/index.html"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bucket.objects["site/index.html"]; ok {
+		t.Error("object not deleted")
+	}
+
+	c = New(config.S3Config{Bucket: "bucket", Region: "eu-west-1", Endpoint: srv.URL, AccessKeyID: "other", SecretAccessKey: "secret"})
+	if err := c.Put(ctx, "x", nil, ""); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Put with rejected credentials: %v", err)
+	}
+}
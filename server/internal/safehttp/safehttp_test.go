@@ -0,0 +1,83 @@
+package safehttp
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestAllowed(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::":    true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false, // Cloud metadata
+		"100.100.100.200":      false,
+		"0.0.0.0":              false,
+		"0.1.2.3":              false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.216.34": true,
+		"fd00::1":              false,
+		"fe80::1":              false,
+		"64:ff9b::7f00:1":      false,
+		"224.0.0.1":            false,
+		"255.255.255.255":      false,
+	} {
+		if got := Allowed(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Allowed(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestClientBlocksInternalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(time.Second, false).Get(srv.URL)
+	if !errors.Is(err, ErrBlocked) {
+		t.Fatalf("err = %v, want ErrBlocked", err)
+	}
+	// By name too: the check is on the address dialed
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	_, err = NewClient(time.Second, false).Get("http://localhost:" + port)
+	if !errors.Is(err, ErrBlocked) {
+		t.Fatalf("err = %v, want ErrBlocked", err)
+	}
+
+	res, err := NewClient(time.Second, true).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+}
+
+func TestClientRedirects(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		default:
+			http.Redirect(w, r, srv.URL+r.URL.Path, http.StatusFound) // Forever
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient(time.Second, true)
+	if _, err := client.Get(srv.URL + "/file"); err == nil {
+		t.Error("followed a redirect to file://")
+	}
+	if _, err := client.Get(srv.URL + "/loop"); err == nil {
+		t.Error("followed redirects forever")
+	}
+}
@@ -0,0 +1,198 @@
+// Package sanitize makes captured HTML pages safe to render: it removes
+// scripts, event handlers, embedded frames and trackers while keeping the
+// markup and styles that make a full-page capture look like the page.
+package sanitize
+
+import (
+	"bytes"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// removedElements are dropped with their content
+var removedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Noscript: true, // Only shown without scripts; mostly tracking pixels
+	atom.Iframe:   true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Applet:   true,
+	atom.Base:     true, // Would redirect the capture's relative media links
+}
+
+// urlAttributes hold URLs that can run code with a javascript: scheme
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true, "xlink:href": true,
+	"background": true, "poster": true, "data": true, "lowsrc": true, "dynsrc": true,
+}
+
+// removedAttributes are dropped from every element
+var removedAttributes = map[string]bool{
+	"srcdoc": true, // An inline document, scripts included
+	"ping":   true, // Tracks link clicks
+}
+
+// prefetchRels make the browser contact hosts before anything is shown
+var prefetchRels = map[string]bool{
+	"preload": true, "prefetch": true, "dns-prefetch": true, "preconnect": true,
+	"modulepreload": true, "prerender": true, "import": true,
+}
+
+// trackerHosts serve analytics and advertising pixels; subdomains included
+var trackerHosts = []string{
+	"google-analytics.com", "googletagmanager.com", "doubleclick.net",
+	"googlesyndication.com", "googleadservices.com", "facebook.net",
+	"scorecardresearch.com", "quantserve.com", "hotjar.com", "segment.io",
+	"mixpanel.com", "chartbeat.net", "newrelic.com", "nr-data.net",
+	"adnxs.com", "criteo.com", "taboola.com", "outbrain.com", "pixel.wp.com",
+	"bat.bing.com", "ads-twitter.com", "analytics.twitter.com", "px.ads.linkedin.com",
+}
+
+// HTML returns document with everything able to run code or track the
+// reader removed. Unparseable markup is fixed up the way browsers do.
+func HTML(document string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		return "", err
+	}
+	clean(doc)
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// clean sanitizes the children of n, recursively
+func clean(n *html.Node) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == html.ElementNode && removeElement(child) {
+			n.RemoveChild(child)
+		} else {
+			if child.Type == html.ElementNode {
+				child.Attr = cleanAttributes(child.Attr)
+			}
+			clean(child)
+		}
+		child = next
+	}
+}
+
+// removeElement reports whether an element must go with its content
+func removeElement(n *html.Node) bool {
+	if removedElements[n.DataAtom] {
+		return true
+	}
+	switch n.DataAtom {
+	case atom.Meta:
+		return strings.EqualFold(attr(n, "http-equiv"), "refresh")
+	case atom.Link:
+		for _, rel := range strings.Fields(strings.ToLower(attr(n, "rel"))) {
+			if prefetchRels[rel] {
+				return true
+			}
+		}
+	case atom.Img:
+		return isTracker(attr(n, "src")) || isPixel(n)
+	}
+	return false
+}
+
+// cleanAttributes drops event handlers, script URLs and styles running code
+func cleanAttributes(attrs []html.Attribute) []html.Attribute {
+	kept := attrs[:0]
+	for _, a := range attrs {
+		key := strings.ToLower(a.Key)
+		if a.Namespace != "" {
+			key = strings.ToLower(a.Namespace) + ":" + key
+		}
+		switch {
+		case strings.HasPrefix(key, "on"), removedAttributes[key]:
+			continue
+		case urlAttributes[key] && isScriptURL(a.Val):
+			continue
+		case key == "style" && isScriptStyle(a.Val):
+			continue
+		case key == "srcset" && isScriptURL(strings.TrimSpace(a.Val)):
+			continue
+		}
+		kept = append(kept, a)
+	}
+	return kept
+}
+
+// isScriptURL reports whether a URL runs code when followed or loaded
+func isScriptURL(raw string) bool {
+	// Browsers ignore control characters and whitespace in the scheme
+	var scheme strings.Builder
+	for _, r := range raw {
+		if r == ':' {
+			break
+		}
+		if r > ' ' {
+			scheme.WriteRune(r)
+		}
+	}
+	switch strings.ToLower(scheme.String()) {
+	case "javascript", "vbscript", "livescript":
+		return true
+	case "data":
+		media := strings.ToLower(strings.TrimSpace(raw[strings.IndexByte(raw, ':')+1:]))
+		return strings.HasPrefix(media, "text/html") || strings.HasPrefix(media, "image/svg") ||
+			strings.HasPrefix(media, "application/") || strings.HasPrefix(media, "text/javascript")
+	}
+	return false
+}
+
+// isScriptStyle reports whether an inline style can run code (old IE
+// expressions and behaviors, script URLs)
+func isScriptStyle(style string) bool {
+	s := strings.ToLower(strings.Join(strings.Fields(style), ""))
+	return strings.Contains(s, "expression(") || strings.Contains(s, "javascript:") ||
+		strings.Contains(s, "behavior:") || strings.Contains(s, "-moz-binding")
+}
+
+// isTracker reports whether a URL is on a known tracker host
+func isTracker(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, tracker := range trackerHosts {
+		if host == tracker || strings.HasSuffix(host, "."+tracker) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPixel reports whether an image is a remote 1x1 (or smaller) image, the
+// usual shape of a tracking pixel
+func isPixel(n *html.Node) bool {
+	u, err := url.Parse(strings.TrimSpace(attr(n, "src")))
+	if err != nil || u.Host == "" {
+		return false // Saved media are local
+	}
+	width, errW := strconv.Atoi(strings.TrimSuffix(attr(n, "width"), "px"))
+	height, errH := strconv.Atoi(strings.TrimSuffix(attr(n, "height"), "px"))
+	return errW == nil && errH == nil && width <= 1 && height <= 1
+}
+
+// attr returns the value of an element's attribute
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html><head>
+<title>Article</title>
+<base href="https://evil.example/">
+<meta http-equiv="refresh" content="0;url=https://evil.example/">
+<link rel="stylesheet" href="style.css">
+<link rel="preconnect" href="https://cdn.example">
+<style>body { color: #333 }</style>
+<script>alert(1)</script>
+</head>
+<body onload="steal()" class="page">
+<h1 style="color: red" onclick="x()">Title</h1>
+<p>Text <a href="javascript:alert(1)">bad</a> <a href=" JaVa&#09;Script:alert(1)">obfuscated</a> <a href="https://example.com/more" ping="https://t.example/">good</a></p>
+<img src="media/hero.png" alt="Hero" onerror="x()">
+<img src="https://www.google-analytics.com/collect?v=1">
+<img src="https://stats.example/p.gif" width="1" height="1">
+<img src="data:image/svg+xml;base64,PHN2Zz48L3N2Zz4=">
+<iframe src="https://ads.example/"></iframe>
+<iframe srcdoc="<script>alert(1)</script>"></iframe>
+<object data="flash.swf"></object>
+<noscript><img src="https://pixel.example/?noscript=1"></noscript>
+<div style="width: expression(alert(1))">Old IE</div>
+<svg><script>alert(1)</script><a xlink:href="javascript:alert(1)"><text>svg</text></a></svg>
+<form action="javascript:alert(1)"><button formaction="javascript:alert(1)">Go</button></form>
+</body></html>`
+
+	out, err := HTML(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, gone := range []string{
+		"<script", "alert", "onload", "onclick", "onerror", "<base", "refresh", "preconnect",
+		"google-analytics", "stats.example", "data:image/svg", "<iframe", "<object", "<noscript",
+		"pixel.example", "expression", "javascript", "ping=",
+	} {
+		if strings.Contains(strings.ToLower(out), gone) {
+			t.Errorf("%q left in:\n%s", gone, out)
+		}
+	}
+	for _, kept := range []string{
+		`<title>Article</title>`, `<link rel="stylesheet" href="style.css"/>`, `body { color: #333 }`,
+		`<body class="page">`, `<h1 style="color: red">Title</h1>`, `<a href="https://example.com/more">good</a>`,
+		`<img src="media/hero.png" alt="Hero"/>`, `<div>Old IE</div>`, `<text>svg</text>`, `<button>Go</button>`,
+	} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("%q missing from:\n%s", kept, out)
+		}
+	}
+}
+
+func TestHTMLFragment(t *testing.T) {
+	// A capture without html/body is completed the way browsers do
+	out, err := HTML(`<p onmouseover="x()">Hi</p>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "<html><head></head><body><p>Hi</p></body></html>" {
+		t.Errorf("got %s", out)
+	}
+}
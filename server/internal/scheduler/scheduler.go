@@ -0,0 +1,224 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Func is a task's work; the returned summary (e.g. "purged 3 clips") is
+// logged and shown in the status, empty when there was nothing to do
+type Func func(ctx context.Context) (string, error)
+
+// Status is the state of a scheduled task
+type Status struct {
+	Name        string     `json:"name"`
+	Schedule    string     `json:"schedule"`
+	Running     bool       `json:"running"`
+	NextRun     time.Time  `json:"next_run"`
+	LastRun     *time.Time `json:"last_run,omitempty"`
+	LastSeconds float64    `json:"last_seconds"`
+	LastResult  string     `json:"last_result,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	Runs        int        `json:"runs"`
+	Failures    int        `json:"failures"`
+}
+
+type task struct {
+	name     string
+	spec     string
+	schedule Schedule
+	fn       Func
+
+	// Guarded by the scheduler's mutex
+	next   time.Time
+	status Status
+}
+
+// Scheduler runs tasks when their schedule is due. A task is never run
+// twice at once: a run due while the previous one is still going is skipped.
+type Scheduler struct {
+	now func() time.Time
+
+	mu    sync.Mutex
+	tasks []*task
+	wake  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// New returns an empty scheduler
+func New() *Scheduler {
+	return &Scheduler{now: time.Now, wake: make(chan struct{}, 1)}
+}
+
+// Add schedules fn as name on the spec read by Parse
+func (s *Scheduler) Add(name, spec string, fn Func) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return fmt.Errorf("task %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tasks {
+		if t.name == name {
+			return fmt.Errorf("task %s is already scheduled", name)
+		}
+	}
+	t := &task{name: name, spec: spec, schedule: schedule, fn: fn}
+	t.next = schedule.Next(s.now())
+	s.tasks = append(s.tasks, t)
+	s.signal()
+	return nil
+}
+
+// Run starts the due tasks until the context is cancelled, then waits for
+// the running ones to return
+func (s *Scheduler) Run(ctx context.Context) {
+	defer s.wg.Wait()
+	for {
+		s.mu.Lock()
+		now := s.now()
+		var next time.Time
+		for _, t := range s.tasks {
+			if !t.next.After(now) {
+				s.start(ctx, t, now)
+			}
+			if next.IsZero() || t.next.Before(next) {
+				next = t.next
+			}
+		}
+		s.mu.Unlock()
+
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = next.Sub(now)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// RunNow starts a task immediately, outside its schedule, and waits for it
+func (s *Scheduler) RunNow(ctx context.Context, name string) (Status, error) {
+	s.mu.Lock()
+	t := s.find(name)
+	if t == nil {
+		s.mu.Unlock()
+		return Status{}, fmt.Errorf("unknown task %s", name)
+	}
+	if t.status.Running {
+		s.mu.Unlock()
+		return Status{}, fmt.Errorf("task %s is already running", name)
+	}
+	t.status.Running = true
+	s.mu.Unlock()
+
+	s.run(ctx, t)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statusOf(t), nil
+}
+
+// Statuses returns the state of every task, by name
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]Status, len(s.tasks))
+	for i, t := range s.tasks {
+		statuses[i] = s.statusOf(t)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// start runs a due task in the background and schedules its next run.
+// Called with the mutex held.
+func (s *Scheduler) start(ctx context.Context, t *task, now time.Time) {
+	t.next = t.schedule.Next(now)
+	if t.status.Running {
+		log.Printf("scheduler: %s is still running, skipping this run", t.name)
+		return
+	}
+	t.status.Running = true
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(ctx, t)
+	}()
+}
+
+// run calls the task and records the outcome; the task is marked running
+func (s *Scheduler) run(ctx context.Context, t *task) {
+	started := s.now()
+	result, err := safeCall(ctx, t.fn)
+	elapsed := s.now().Sub(started)
+
+	if err != nil {
+		log.Printf("scheduler: %s failed: %v", t.name, err)
+	} else if result != "" {
+		log.Printf("scheduler: %s: %s", t.name, result)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.status.Running = false
+	t.status.LastRun = &started
+	t.status.LastSeconds = elapsed.Seconds()
+	t.status.LastResult = result
+	t.status.LastError = ""
+	t.status.Runs++
+	if err != nil {
+		t.status.LastError = err.Error()
+		t.status.Failures++
+	}
+}
+
+// safeCall runs fn, turning a panic into an error so one broken task
+// doesn't take the server down
+func safeCall(ctx context.Context, fn Func) (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// statusOf copies a task's status. Called with the mutex held.
+func (s *Scheduler) statusOf(t *task) Status {
+	status := t.status
+	status.Name = t.name
+	status.Schedule = t.spec
+	status.NextRun = t.next
+	return status
+}
+
+// find returns the named task. Called with the mutex held.
+func (s *Scheduler) find(name string) *task {
+	for _, t := range s.tasks {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// signal wakes Run to take new tasks into account
+func (s *Scheduler) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
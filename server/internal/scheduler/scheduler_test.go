@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseNext(t *testing.T) {
+	from := time.Date(2026, 10, 16, 22, 47, 30, 0, time.UTC) // A Friday
+	for _, tc := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 10, 16, 22, 48, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC)},
+		{"30 3 * * *", time.Date(2026, 10, 17, 3, 30, 0, 0, time.UTC)},
+		{"0 4 * * 1-5", time.Date(2026, 10, 19, 4, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,15 * *", time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2026, 10, 23, 0, 0, 0, 0, time.UTC)}, // A Friday or the 13th
+		{"@every 90s", from.Add(90 * time.Second)},
+	} {
+		schedule, err := Parse(tc.spec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.spec, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(tc.want) {
+			t.Errorf("Parse(%q).Next = %v, want %v", tc.spec, got, tc.want)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "@every nope", "@every 1ms", "@yearly"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q): expected an error", spec)
+		}
+	}
+}
+
+func TestSchedulerRun(t *testing.T) {
+	s := New()
+	ran := make(chan struct{}, 10)
+	if err := s.Add("tick", "@every 1s", func(ctx context.Context) (string, error) {
+		ran <- struct{}{}
+		return "ticked", nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("tick", "@hourly", nil); err == nil {
+		t.Error("expected an error adding a task twice")
+	}
+	if err := s.Add("broken", "@daily", func(ctx context.Context) (string, error) {
+		panic("boom")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the task did not run")
+	}
+	cancel()
+	<-done
+
+	status, err := s.RunNow(context.Background(), "broken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.LastError != "panic: boom" || status.Failures != 1 || status.Running {
+		t.Errorf("broken status = %+v", status)
+	}
+	if _, err := s.RunNow(context.Background(), "missing"); err == nil {
+		t.Error("expected an error running an unknown task")
+	}
+
+	statuses := s.Statuses()
+	if len(statuses) != 2 || statuses[0].Name != "broken" || statuses[1].LastResult != "ticked" || statuses[1].Runs < 1 {
+		t.Errorf("statuses = %+v", statuses)
+	}
+}
+
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	s := New()
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	s.Add("slow", "@hourly", func(ctx context.Context) (string, error) {
+		started <- struct{}{}
+		<-release
+		return "", errors.New("stopped")
+	})
+
+	go s.RunNow(context.Background(), "slow")
+	<-started
+	if _, err := s.RunNow(context.Background(), "slow"); err == nil {
+		t.Error("expected an error running a task already running")
+	}
+	close(release)
+}
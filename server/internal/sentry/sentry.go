@@ -0,0 +1,196 @@
+// Package sentry is a minimal client for Sentry and compatible services
+// (GlitchTip, self-hosted Sentry): just enough to send error events, with a
+// stack trace and the request they happened in, to a project's store
+// endpoint.
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Client sends events to the project of a DSN.
+type Client struct {
+	endpoint    string // The project's store endpoint
+	key         string // The DSN's public key
+	environment string
+	httpClient  *http.Client
+}
+
+// New creates a Client from a DSN (https://<key>@<host>/<project id>).
+// Events are tagged with environment.
+func New(dsn, environment string) (*Client, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("invalid DSN: expected https://<key>@<host>/<project id>")
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	if i < 0 || path[i+1:] == "" {
+		return nil, fmt.Errorf("invalid DSN: no project ID")
+	}
+	return &Client{
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, path[:i], path[i+1:]),
+		key:         u.User.Username(),
+		environment: environment,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Event is an error event. ID, Timestamp, Platform and Environment are set
+// by Capture when empty.
+type Event struct {
+	ID          string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"` // error, fatal, warning...
+	Platform    string            `json:"platform"`
+	Environment string            `json:"environment,omitempty"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Transaction string            `json:"transaction,omitempty"` // The route, like "POST /api/v1/clips"
+	Exception   []Exception       `json:"exception,omitempty"`
+	Request     *Request          `json:"request,omitempty"`
+	User        *User             `json:"user,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"` // Searchable, like the request's ID
+}
+
+// Exception is an error and where it was raised.
+type Exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Stacktrace *Stacktrace `json:"stacktrace,omitempty"`
+}
+
+// Stacktrace lists the frames of a stack, outermost call first.
+type Stacktrace struct {
+	Frames []Frame `json:"frames"`
+}
+
+// Frame is a function call in a stack trace.
+type Frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// Request is the HTTP request an event happened in.
+type Request struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// User is who made the request.
+type User struct {
+	ID        string `json:"id,omitempty"`
+	IPAddress string `json:"ip_address,omitempty"`
+}
+
+// Capture sends an event.
+func (c *Client) Capture(ctx context.Context, e *Event) error {
+	if e.ID == "" {
+		id := make([]byte, 16)
+		rand.Read(id)
+		e.ID = hex.EncodeToString(id)
+	}
+	if e.Timestamp == "" {
+		e.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	}
+	if e.Platform == "" {
+		e.Platform = "go"
+	}
+	if e.Environment == "" {
+		e.Environment = c.environment
+	}
+	if e.Level == "" {
+		e.Level = "error"
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=web-clipper/1.0, sentry_key=%s", c.key))
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("sentry: %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// NewStacktrace returns the stack of the caller, skipping skip more frames.
+// Frames of packages under appPrefix (a module path) are marked in_app.
+func NewStacktrace(skip int, appPrefix string) *Stacktrace {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var st Stacktrace
+	for {
+		frame, more := frames.Next()
+		module, function := splitFunction(frame.Function)
+		st.Frames = append(st.Frames, Frame{
+			Function: function,
+			Module:   module,
+			Filename: frame.File,
+			Lineno:   frame.Line,
+			InApp:    module == appPrefix || strings.HasPrefix(module, appPrefix+"/"),
+		})
+		if !more {
+			break
+		}
+	}
+	// Sentry expects the innermost frame last
+	for i, j := 0, len(st.Frames)-1; i < j; i, j = i+1, j-1 {
+		st.Frames[i], st.Frames[j] = st.Frames[j], st.Frames[i]
+	}
+	return &st
+}
+
+// PanicStacktrace returns the stack of the panic being recovered, from a
+// deferred function: up to where it was first raised, leaving out the
+// functions (like deferred ones re-panicking) that ran since
+func PanicStacktrace(appPrefix string) *Stacktrace {
+	st := NewStacktrace(1, appPrefix)
+	for i, frame := range st.Frames {
+		if frame.Module == "runtime" && frame.Function == "gopanic" {
+			st.Frames = st.Frames[:i]
+			break
+		}
+	}
+	return st
+}
+
+// splitFunction splits a qualified function name
+// (server/actions.(*Type).Method) into its package and function
+func splitFunction(name string) (string, string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	return name[:slash+1+dot], name[slash+1+dot+1:]
+}
@@ -0,0 +1,70 @@
+package sentry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	c, err := New("https://public@sentry.example.com/prefix/42", "production")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.endpoint != "https://sentry.example.com/prefix/api/42/store/" || c.key != "public" {
+		t.Errorf("endpoint = %s, key = %s", c.endpoint, c.key)
+	}
+	for _, dsn := range []string{"", "sentry.example.com/42", "https://sentry.example.com/42", "https://public@sentry.example.com/"} {
+		if _, err := New(dsn, ""); err == nil {
+			t.Errorf("New(%q) succeeded", dsn)
+		}
+	}
+}
+
+func TestCapture(t *testing.T) {
+	var got Event
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/7/store/" {
+			http.NotFound(w, r)
+			return
+		}
+		auth = r.Header.Get("X-Sentry-Auth")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"id":"1"}`))
+	}))
+	defer srv.Close()
+
+	c, err := New(strings.Replace(srv.URL, "http://", "http://key@", 1)+"/7", "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := &Event{Exception: []Exception{{Type: "panic", Value: "boom", Stacktrace: NewStacktrace(0, "server")}}}
+	if err := c.Capture(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(auth, "sentry_key=key") {
+		t.Errorf("X-Sentry-Auth = %s", auth)
+	}
+	if len(got.ID) != 32 || got.Environment != "staging" || got.Level != "error" || got.Platform != "go" {
+		t.Errorf("event = %+v", got)
+	}
+
+	// The caller is the last frame, and is the app's
+	frames := got.Exception[0].Stacktrace.Frames
+	last := frames[len(frames)-1]
+	if last.Function != "TestCapture" || last.Module != "server/internal/sentry" || !last.InApp {
+		t.Errorf("last frame = %+v", last)
+	}
+	if frames[0].InApp {
+		t.Errorf("first frame = %+v", frames[0])
+	}
+
+	bad, _ := New(strings.Replace(srv.URL, "http://", "http://key@", 1)+"/8", "")
+	if err := bad.Capture(context.Background(), &Event{}); err == nil {
+		t.Error("expected an error for an unknown project")
+	}
+}
@@ -16,6 +16,13 @@ var (
 	// ErrPathNotAllowed is returned when a path is not in the allowed list.
 	ErrPathNotAllowed = errors.New("path not in allowed list")
 
+	// ErrPathAdminOnly is returned when a user who isn't an admin sets an
+	// absolute storage path.
+	ErrPathAdminOnly = errors.New("absolute storage paths can only be set by admins")
+
+	// ErrInvalidKindleEmail is returned when a Kindle device address is malformed.
+	ErrInvalidKindleEmail = errors.New("invalid kindle email address")
+
 	// ErrUserAlreadyDisabled is returned when trying to disable an already disabled user.
 	ErrUserAlreadyDisabled = errors.New("user is already disabled")
 
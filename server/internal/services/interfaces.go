@@ -13,13 +13,14 @@ type Logger interface {
 
 // UserInfo represents user information for display.
 type UserInfo struct {
-	ID            string
-	Email         string
-	Name          string
-	ClipDirectory string
-	Disabled      bool
-	CreatedAt     string
-	UpdatedAt     string
+	ID            string `json:"id"`
+	Email         string `json:"email"`
+	Name          string `json:"name"`
+	ClipDirectory string `json:"clip_directory"`
+	KindleEmail   string `json:"kindle_email"`
+	Disabled      bool   `json:"disabled"`
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
 }
 
 // UserService defines the interface for user management operations.
@@ -33,6 +34,12 @@ type UserService interface {
 	// SetStoragePath updates a user's custom storage path.
 	SetStoragePath(ctx context.Context, email, path string) error
 
+	// CheckStoragePath reports whether SetStoragePath would succeed, without changing anything.
+	CheckStoragePath(ctx context.Context, email, path string) error
+
+	// SetKindleEmail updates the Send to Kindle device address (empty clears it).
+	SetKindleEmail(ctx context.Context, email, address string) error
+
 	// Disable disables a user account.
 	Disable(ctx context.Context, email string) error
 
@@ -54,15 +61,15 @@ type StorageValidator interface {
 
 // TokenInfo represents API token information for display.
 type TokenInfo struct {
-	ID           string
-	Name         string
-	Prefix       string
-	ExpiresAt    string
-	LastUsedAt   string
-	Revoked      bool
-	RevokedAt    string
-	RevokedReason string
-	CreatedAt    string
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Prefix        string `json:"prefix"`
+	ExpiresAt     string `json:"expires_at"`
+	LastUsedAt    string `json:"last_used_at"`
+	Revoked       bool   `json:"revoked"`
+	RevokedAt     string `json:"revoked_at"`
+	RevokedReason string `json:"revoked_reason"`
+	CreatedAt     string `json:"created_at"`
 }
 
 // TokenService defines the interface for API token management operations.
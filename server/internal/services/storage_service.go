@@ -60,7 +60,7 @@ func (s *StorageService) Validate(path string) error {
 			if err != nil {
 				continue
 			}
-			if strings.HasPrefix(absPath, absAllowed) {
+			if within(absPath, absAllowed) {
 				allowed = true
 				break
 			}
@@ -84,3 +84,33 @@ func (s *StorageService) GetEffectivePath(userID, customPath string) (string, er
 	// Default: base_path/user_id
 	return filepath.Join(s.basePath, userID), nil
 }
+
+// ValidateCollectionPath checks a collection's storage override. A relative
+// path must stay inside the user's clip directory. An absolute path (another
+// mount) can only be set by admins, under one of the allowed paths, so it is
+// refused when none are configured, and never inside the base path, which
+// holds the directories of all users.
+func (s *StorageService) ValidateCollectionPath(path string, admin bool) error {
+	if !filepath.IsAbs(path) {
+		if !filepath.IsLocal(path) {
+			return ErrPathTraversal
+		}
+		return nil
+	}
+	if !admin {
+		return ErrPathAdminOnly
+	}
+	if len(s.allowedPaths) == 0 {
+		return ErrPathNotAllowed
+	}
+	if base, err := filepath.Abs(s.basePath); err == nil && s.basePath != "" && within(path, base) {
+		return fmt.Errorf("%w: it is inside storage.base_path", ErrPathNotAllowed)
+	}
+	return s.Validate(path)
+}
+
+// within reports whether path is root or inside it. Both are absolute.
+func within(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && (rel == "." || filepath.IsLocal(rel))
+}
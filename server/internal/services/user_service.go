@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"net/mail"
 
 	"server/internal/repository"
 	"server/models"
@@ -82,6 +83,48 @@ func (s *UserServiceImpl) SetStoragePath(ctx context.Context, email, path string
 	return nil
 }
 
+// CheckStoragePath reports whether SetStoragePath would succeed, without changing anything.
+func (s *UserServiceImpl) CheckStoragePath(ctx context.Context, email, path string) error {
+	if err := s.storageValidator.Validate(path); err != nil {
+		return err
+	}
+	if _, err := s.repo.FindByEmail(ctx, email); err != nil {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// SetKindleEmail updates the Send to Kindle device address (empty clears it).
+func (s *UserServiceImpl) SetKindleEmail(ctx context.Context, email, address string) error {
+	if address != "" {
+		if _, err := mail.ParseAddress(address); err != nil {
+			return ErrInvalidKindleEmail
+		}
+	}
+
+	user, err := s.repo.FindByEmail(ctx, email)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	if address == "" {
+		user.KindleEmail = nulls.String{}
+	} else {
+		user.KindleEmail = nulls.NewString(address)
+	}
+
+	if err := s.repo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	s.logger.Info("kindle address updated",
+		"email", email,
+		"kindle_email", address,
+	)
+
+	return nil
+}
+
 // Disable disables a user account.
 func (s *UserServiceImpl) Disable(ctx context.Context, email string) error {
 	user, err := s.repo.FindByEmail(ctx, email)
@@ -142,6 +185,7 @@ func userToInfo(u *models.User) UserInfo {
 		Email:         u.Email,
 		Name:          u.Name,
 		ClipDirectory: clipDir,
+		KindleEmail:   u.KindleEmail.String,
 		Disabled:      u.Disabled,
 		CreatedAt:     u.CreatedAt.Format("2006-01-02 15:04:05"),
 		UpdatedAt:     u.UpdatedAt.Format("2006-01-02 15:04:05"),
@@ -0,0 +1,88 @@
+package testkit
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"server/migrations"
+
+	"github.com/gobuffalo/pop/v6"
+)
+
+var dbCount int64
+
+// NewDB opens a database private to the test and applies all migrations: an
+// in-memory SQLite database, or with TEST_DATABASE_URL set (a postgres://
+// URL) a new database on that PostgreSQL server. It is closed, and the
+// PostgreSQL database dropped, when the test ends.
+func NewDB(t testing.TB) *pop.Connection {
+	t.Helper()
+
+	if url := os.Getenv("TEST_DATABASE_URL"); url != "" {
+		return newPostgresDB(t, url)
+	}
+
+	// A named shared-cache database lives as long as one connection is open;
+	// the name keeps databases of parallel tests apart
+	name := fmt.Sprintf("testkit-%d", atomic.AddInt64(&dbCount, 1))
+	db, err := pop.NewConnection(&pop.ConnectionDetails{
+		Dialect:  "sqlite3",
+		Database: "file:" + name,
+		Options:  map[string]string{"mode": "memory", "cache": "shared"},
+	})
+	if err != nil {
+		t.Fatalf("testkit: failed to create database: %v", err)
+	}
+	if err := db.Open(); err != nil {
+		t.Fatalf("testkit: failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// The fizz translator opens its own connection from Database alone; give
+	// it the full URI so it reads the schema of this database, not a file
+	db.Dialect.Details().Database = fmt.Sprintf("file:%s?mode=memory&cache=shared", name)
+
+	migrate(t, db)
+	return db
+}
+
+// newPostgresDB creates a database named after the process and test on the
+// server of url
+func newPostgresDB(t testing.TB, url string) *pop.Connection {
+	db, err := pop.NewConnection(&pop.ConnectionDetails{URL: url})
+	if err != nil {
+		t.Fatalf("testkit: failed to create database: %v", err)
+	}
+	details := db.Dialect.Details()
+	details.URL = "" // Connect to the database below, not the URL's
+	details.Database = fmt.Sprintf("testkit_%d_%d", os.Getpid(), atomic.AddInt64(&dbCount, 1))
+	if err := db.Dialect.CreateDB(); err != nil {
+		t.Fatalf("testkit: failed to create database: %v", err)
+	}
+	if err := db.Open(); err != nil {
+		db.Dialect.DropDB()
+		t.Fatalf("testkit: failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		if err := db.Dialect.DropDB(); err != nil {
+			t.Logf("testkit: %v", err)
+		}
+	})
+
+	migrate(t, db)
+	return db
+}
+
+// migrate applies the migrations built into the binary to db
+func migrate(t testing.TB, db *pop.Connection) {
+	mig, err := pop.NewMigrationBox(migrations.FS, db)
+	if err != nil {
+		t.Fatalf("testkit: failed to load migrations: %v", err)
+	}
+	if err := mig.Up(); err != nil {
+		t.Fatalf("testkit: failed to migrate database: %v", err)
+	}
+}
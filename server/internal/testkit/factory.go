@@ -0,0 +1,145 @@
+package testkit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"server/models"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gofrs/uuid"
+)
+
+// CreateUser inserts a user with a unique email (user-N@example.com);
+// options may change any field before the insert.
+func (k *Kit) CreateUser(opts ...func(*models.User)) *models.User {
+	k.T.Helper()
+
+	n := k.next()
+	user := &models.User{
+		ID:      uuid.Must(uuid.NewV4()),
+		Email:   fmt.Sprintf("user-%d@example.com", n),
+		Name:    fmt.Sprintf("Test User %d", n),
+		OAuthID: fmt.Sprintf("testkit-%d", n),
+	}
+	for _, opt := range opts {
+		opt(user)
+	}
+
+	if err := k.DB.Create(user); err != nil {
+		k.T.Fatalf("testkit: failed to create user: %v", err)
+	}
+	return user
+}
+
+// ClipOption customizes a clip made by CreateClip
+type ClipOption func(*clipSpec)
+
+type clipSpec struct {
+	clip    *models.Clip
+	tags    []string
+	content string
+	media   map[string][]byte
+}
+
+// WithTags sets the clip's tags
+func WithTags(tags ...string) ClipOption {
+	return func(s *clipSpec) { s.tags = tags }
+}
+
+// WithContent sets the markdown body written to the clip's folder
+func WithContent(markdown string) ClipOption {
+	return func(s *clipSpec) { s.content = markdown }
+}
+
+// WithMedia adds a file to the clip's media folder
+func WithMedia(filename string, data []byte) ClipOption {
+	return func(s *clipSpec) { s.media[filename] = data }
+}
+
+// WithClip changes clip fields (title, URL, mode, dates...) before the insert
+func WithClip(fn func(*models.Clip)) ClipOption {
+	return func(s *clipSpec) { fn(s.clip) }
+}
+
+// CreateClip inserts a clip for the user and writes its folder (a markdown
+// file and any media) under the kit's storage root, like a real capture.
+func (k *Kit) CreateClip(user *models.User, opts ...ClipOption) *models.Clip {
+	k.T.Helper()
+
+	n := k.next()
+	spec := &clipSpec{
+		clip: &models.Clip{
+			ID:     uuid.Must(uuid.NewV4()),
+			UserID: user.ID,
+			Title:  fmt.Sprintf("Test Clip %d", n),
+			URL:    fmt.Sprintf("https://example.com/articles/%d", n),
+			Domain: "example.com",
+			Mode:   "article",
+			Path:   filepath.Join("web-clips", fmt.Sprintf("%s_example-com_%08d", time.Now().Format("20060102_150405"), n)),
+		},
+		content: "Test content.",
+		media:   map[string][]byte{},
+	}
+	for _, opt := range opts {
+		opt(spec)
+	}
+	clip := spec.clip
+	if clip.NormalizedURL == "" {
+		clip.NormalizedURL = clip.URL
+	}
+
+	if err := k.DB.Create(clip); err != nil {
+		k.T.Fatalf("testkit: failed to create clip: %v", err)
+	}
+	if err := models.SetClipTags(k.DB, clip, spec.tags); err != nil {
+		k.T.Fatalf("testkit: failed to tag clip: %v", err)
+	}
+	clip.Tags = models.CleanTagNames(spec.tags)
+
+	k.writeClipFolder(user, clip, spec)
+	return clip
+}
+
+// writeClipFolder writes the clip's markdown file and media
+func (k *Kit) writeClipFolder(user *models.User, clip *models.Clip, spec *clipSpec) {
+	k.T.Helper()
+
+	root := k.StorageRoot
+	if user.ClipDirectory.Valid && user.ClipDirectory.String != "" {
+		root = user.ClipDirectory.String
+	}
+	folder := filepath.Join(root, clip.Path)
+	if err := os.MkdirAll(filepath.Join(folder, "media"), 0755); err != nil {
+		k.T.Fatalf("testkit: failed to create clip folder: %v", err)
+	}
+
+	slug := strings.ToLower(strings.Join(strings.Fields(clip.Title), "-"))
+	markdown := fmt.Sprintf("---\ntitle: %q\nurl: %s\nmode: %s\n---\n\n%s\n", clip.Title, clip.URL, clip.Mode, spec.content)
+	if err := os.WriteFile(filepath.Join(folder, slug+".md"), []byte(markdown), 0644); err != nil {
+		k.T.Fatalf("testkit: failed to write clip: %v", err)
+	}
+	for name, data := range spec.media {
+		if err := os.WriteFile(filepath.Join(folder, "media", name), data, 0644); err != nil {
+			k.T.Fatalf("testkit: failed to write media: %v", err)
+		}
+	}
+}
+
+// CreateToken creates a service token for the user and returns the
+// plaintext token (wc_...) to send as a Bearer token
+func (k *Kit) CreateToken(user *models.User) string {
+	k.T.Helper()
+
+	plaintext, token, err := models.GenerateToken(user.ID, fmt.Sprintf("Test Token %d", k.next()), nulls.Time{})
+	if err != nil {
+		k.T.Fatalf("testkit: failed to generate token: %v", err)
+	}
+	if err := k.DB.Create(token); err != nil {
+		k.T.Fatalf("testkit: failed to create token: %v", err)
+	}
+	return plaintext
+}
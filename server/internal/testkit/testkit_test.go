@@ -0,0 +1,69 @@
+package testkit
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"server/models"
+)
+
+func TestFactories(t *testing.T) {
+	kit := New(t)
+
+	user := kit.CreateUser(func(u *models.User) { u.Email = "ada@example.com" })
+	other := kit.CreateUser()
+	if other.Email == user.Email {
+		t.Fatal("users share an email")
+	}
+
+	clip := kit.CreateClip(user, WithTags("go", "go", "testing"), WithMedia("a.png", []byte("png")))
+	if len(clip.Tags) != 2 {
+		t.Errorf("Tags = %v", clip.Tags)
+	}
+
+	found, err := models.FindClipByIDAndUser(kit.DB, clip.ID, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := found.LoadTags(kit.DB); err != nil || len(found.Tags) != 2 {
+		t.Errorf("stored tags = %v, %v", found.Tags, err)
+	}
+	if _, err := os.Stat(filepath.Join(kit.StorageRoot, clip.Path, "media", "a.png")); err != nil {
+		t.Errorf("media not written: %v", err)
+	}
+
+	token := kit.CreateToken(user)
+	if _, err := models.FindTokenByHash(kit.DB, models.HashToken(token)); err != nil {
+		t.Errorf("token not stored: %v", err)
+	}
+}
+
+func TestDatabasesAreIsolated(t *testing.T) {
+	a, b := New(t), New(t)
+	a.CreateUser()
+
+	count, err := b.DB.Count(&models.User{})
+	if err != nil || count != 0 {
+		t.Errorf("second database has %d users (%v)", count, err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	kit := New(t)
+	user := kit.CreateUser()
+
+	var auth string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"ok":true}`))
+	})
+
+	res := kit.Client(handler, user).Post("/x", map[string]string{"a": "b"})
+	var body struct{ OK bool }
+	res.JSON(&body)
+	if !body.OK || auth == "" {
+		t.Errorf("body = %+v, auth = %q", body, auth)
+	}
+}
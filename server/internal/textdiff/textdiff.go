@@ -0,0 +1,168 @@
+// Package textdiff produces line-based unified diffs.
+package textdiff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaxCells bounds the LCS table size (lines(a) * lines(b)) to keep memory in check.
+const MaxCells = 4_000_000
+
+// ErrTooLarge is returned when the inputs are too large to diff.
+var ErrTooLarge = errors.New("inputs too large to diff")
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns a unified diff of a and b with the given number of context lines.
+// An empty string means the inputs are identical.
+func Unified(a, b, fromName, toName string, context int) (string, error) {
+	if a == b {
+		return "", nil
+	}
+
+	aLines := splitLines(a)
+	bLines := splitLines(b)
+	if len(aLines)*len(bLines) > MaxCells {
+		return "", ErrTooLarge
+	}
+
+	ops := diffLines(aLines, bLines)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", fromName, toName))
+
+	// Walk the edit script, emitting hunks around each run of changes
+	aLine, bLine := 1, 1
+	for i := 0; i < len(ops); {
+		if ops[i].kind == opEqual {
+			i++
+			aLine++
+			bLine++
+			continue
+		}
+
+		// Start hunk with leading context
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		hunkA := aLine - (i - start)
+		hunkB := bLine - (i - start)
+
+		// Extend the hunk until we see more than 2*context unchanged lines
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == opEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end += min(context, run-end)
+				break
+			}
+			end = run
+		}
+
+		var body strings.Builder
+		countA, countB := 0, 0
+		for _, o := range ops[start:end] {
+			switch o.kind {
+			case opEqual:
+				body.WriteString(" " + o.line + "\n")
+				countA++
+				countB++
+			case opDelete:
+				body.WriteString("-" + o.line + "\n")
+				countA++
+			case opInsert:
+				body.WriteString("+" + o.line + "\n")
+				countB++
+			}
+		}
+		sb.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", hunkA, countA, hunkB, countB))
+		sb.WriteString(body.String())
+
+		// Advance line counters past the hunk
+		for _, o := range ops[i:end] {
+			if o.kind != opInsert {
+				aLine++
+			}
+			if o.kind != opDelete {
+				bLine++
+			}
+		}
+		i = end
+	}
+
+	return sb.String(), nil
+}
+
+// diffLines computes an edit script from the longest common subsequence
+func diffLines(a, b []string) []op {
+	n, m := len(a), len(b)
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]op, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{opEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{opDelete, a[i]})
+			i++
+		default:
+			ops = append(ops, op{opInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, op{opDelete, a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, op{opInsert, b[j]})
+	}
+	return ops
+}
+
+// splitLines splits text into lines without trailing newline characters
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,44 @@
+package textdiff
+
+import "testing"
+
+func TestUnifiedIdentical(t *testing.T) {
+	diff, err := Unified("a\nb\n", "a\nb\n", "v1", "v2", 3)
+	if err != nil || diff != "" {
+		t.Errorf("expected empty diff, got %q, %v", diff, err)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\n"
+	b := "one\n2\nthree\nfour\nfive\n"
+
+	diff, err := Unified(a, b, "v1", "v2", 1)
+	if err != nil {
+		t.Fatalf("Unified() failed: %v", err)
+	}
+
+	expected := "--- v1\n+++ v2\n" +
+		"@@ -1,4 +1,5 @@\n" +
+		" one\n-two\n+2\n three\n four\n+five\n"
+	if diff != expected {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", diff, expected)
+	}
+}
+
+func TestUnifiedSeparateHunks(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\n"
+	b := "A\nb\nc\nd\ne\nf\ng\nh\nI\n"
+
+	diff, err := Unified(a, b, "v1", "v2", 1)
+	if err != nil {
+		t.Fatalf("Unified() failed: %v", err)
+	}
+
+	expected := "--- v1\n+++ v2\n" +
+		"@@ -1,2 +1,2 @@\n-a\n+A\n b\n" +
+		"@@ -8,2 +8,2 @@\n h\n-i\n+I\n"
+	if diff != expected {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", diff, expected)
+	}
+}
@@ -0,0 +1,167 @@
+// Package webhook signs and sends outbound webhook deliveries.
+//
+// Each delivery is a JSON POST carrying a signature header:
+//
+//	Webclipper-Signature: t=<unix time>,v1=<hex HMAC-SHA256>[,v1=<hex>]
+//
+// The HMAC is computed with the webhook secret over "<unix time>.<body>".
+// While a rotated secret is in its grace period, the header has one v1 value
+// per secret so receivers can switch over at their own pace.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"server/internal/config"
+	"server/internal/safehttp"
+)
+
+// Headers sent with every delivery
+const (
+	SignatureHeader = "Webclipper-Signature"
+	EventHeader     = "Webclipper-Event"
+	DeliveryHeader  = "Webclipper-Delivery"
+)
+
+// Tolerance is how far a signature timestamp may be from now before Verify
+// rejects it as a possible replay
+const Tolerance = 5 * time.Minute
+
+// secretPrefix marks webhook secrets so they are recognizable in config files
+const secretPrefix = "whsec_"
+
+// Verification errors
+var (
+	ErrMalformedSignature = errors.New("malformed signature header")
+	ErrTimestampExpired   = errors.New("timestamp outside the tolerance window")
+	ErrNoMatch            = errors.New("no signature matches the payload")
+)
+
+// NewSecret returns a random signing secret
+func NewSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return secretPrefix + hex.EncodeToString(buf), nil
+}
+
+// Sign returns the signature header value for body at time t, with one
+// signature per secret
+func Sign(body []byte, t time.Time, secrets ...string) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	parts := []string{"t=" + ts}
+	for _, secret := range secrets {
+		parts = append(parts, "v1="+signature(secret, ts, body))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Verify checks a signature header against body and returns the index of
+// the first secret that produced one of its signatures
+func Verify(header string, body []byte, now time.Time, secrets ...string) (int, error) {
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return -1, ErrMalformedSignature
+		}
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sigs = append(sigs, value)
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return -1, ErrMalformedSignature
+	}
+
+	if d := now.Sub(time.Unix(unix, 0)); d > Tolerance || d < -Tolerance {
+		return -1, ErrTimestampExpired
+	}
+
+	for i, secret := range secrets {
+		expected := signature(secret, ts, body)
+		for _, sig := range sigs {
+			if hmac.Equal([]byte(expected), []byte(sig)) {
+				return i, nil
+			}
+		}
+	}
+	return -1, ErrNoMatch
+}
+
+// signature is the hex HMAC-SHA256 of "<timestamp>.<body>"
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Request is one delivery attempt
+type Request struct {
+	URL        string
+	Event      string
+	DeliveryID string
+	Body       []byte
+	Secrets    []string // Current secret first, then one still in its grace period
+}
+
+// Result is the outcome of a delivery attempt. StatusCode is 0 when no
+// response was received.
+type Result struct {
+	StatusCode int
+	Err        error
+}
+
+// Sender posts deliveries to receivers
+type Sender struct {
+	client *http.Client
+}
+
+// NewSender creates a Sender from webhook configuration. Receivers are set
+// by users, so internal addresses are refused unless webhooks.allow_private.
+func NewSender(cfg config.WebhooksConfig) *Sender {
+	return &Sender{client: safehttp.NewClient(time.Duration(cfg.TimeoutSeconds)*time.Second, cfg.AllowPrivate)}
+}
+
+// Send posts a delivery. Any 2xx response is a success.
+func (s *Sender) Send(req Request) Result {
+	httpReq, err := http.NewRequest(http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return Result{Err: err}
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "WebClipper-Webhook/1.0")
+	httpReq.Header.Set(EventHeader, req.Event)
+	httpReq.Header.Set(DeliveryHeader, req.DeliveryID)
+	httpReq.Header.Set(SignatureHeader, Sign(req.Body, time.Now(), req.Secrets...))
+
+	res, err := s.client.Do(httpReq)
+	if err != nil {
+		return Result{Err: err}
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return Result{StatusCode: res.StatusCode, Err: fmt.Errorf("receiver responded %s", res.Status)}
+	}
+	return Result{StatusCode: res.StatusCode}
+}
@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"server/internal/config"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"event":"clip.created"}`)
+	now := time.Unix(1700000000, 0)
+
+	header := Sign(body, now, "new", "old")
+	if !strings.HasPrefix(header, "t=1700000000,v1=") || strings.Count(header, "v1=") != 2 {
+		t.Fatalf("unexpected header: %s", header)
+	}
+
+	if i, err := Verify(header, body, now, "new"); err != nil || i != 0 {
+		t.Errorf("current secret: got %d, %v", i, err)
+	}
+	if i, err := Verify(header, body, now, "other", "old"); err != nil || i != 1 {
+		t.Errorf("previous secret: got %d, %v", i, err)
+	}
+	if _, err := Verify(header, []byte(`{"event":"tampered"}`), now, "new"); err != ErrNoMatch {
+		t.Errorf("tampered body: got %v", err)
+	}
+	if _, err := Verify(header, body, now.Add(Tolerance+time.Second), "new"); err != ErrTimestampExpired {
+		t.Errorf("expired: got %v", err)
+	}
+	for _, bad := range []string{"", "v1=abc", "t=notanumber,v1=abc", "t=1700000000", "garbage"} {
+		if _, err := Verify(bad, body, now, "new"); err != ErrMalformedSignature {
+			t.Errorf("Verify(%q): got %v", bad, err)
+		}
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewSecret()
+	if !strings.HasPrefix(a, secretPrefix) || a == b {
+		t.Errorf("unexpected secrets %q, %q", a, b)
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got *http.Request
+	var gotBody []byte
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	sender := NewSender(config.WebhooksConfig{TimeoutSeconds: 5, AllowPrivate: true})
+	req := Request{
+		URL:        srv.URL,
+		Event:      "clip.created",
+		DeliveryID: "d1",
+		Body:       []byte(`{"ok":true}`),
+		Secrets:    []string{"s3cret"},
+	}
+
+	res := sender.Send(req)
+	if res.Err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("Send: %+v", res)
+	}
+	if got.Header.Get(EventHeader) != "clip.created" || got.Header.Get(DeliveryHeader) != "d1" {
+		t.Errorf("missing headers: %v", got.Header)
+	}
+	if _, err := Verify(got.Header.Get(SignatureHeader), gotBody, time.Now(), "s3cret"); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+
+	status = http.StatusInternalServerError
+	res = sender.Send(req)
+	if res.Err == nil || res.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected failure, got %+v", res)
+	}
+}
+
+func TestSendRefusesInternalAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	res := NewSender(config.WebhooksConfig{TimeoutSeconds: 5}).Send(Request{URL: srv.URL, Event: "clip.created", Body: []byte(`{}`)})
+	if res.Err == nil || called {
+		t.Errorf("delivered to a loopback receiver: %+v", res)
+	}
+}
@@ -0,0 +1,10 @@
+-- Rebuild the JSON array in clips.tags (re-added by the previous down migration)
+UPDATE clips SET tags = (
+  SELECT json_group_array(tags.name)
+  FROM clips_tags JOIN tags ON tags.id = clips_tags.tag_id
+  WHERE clips_tags.clip_id = clips.id
+)
+WHERE EXISTS (SELECT 1 FROM clips_tags WHERE clips_tags.clip_id = clips.id);
+
+DELETE FROM clips_tags;
+DELETE FROM tags;
@@ -0,0 +1,27 @@
+-- Copy the JSON array in clips.tags into tags / clips_tags.
+-- SQLite has no UUID function, so random v4 UUIDs are assembled from randomblob().
+
+INSERT INTO tags (id, user_id, name, created_at, updated_at)
+SELECT
+  lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+  substr(lower(hex(randomblob(2))), 2) || '-' ||
+  substr('89ab', 1 + (abs(random()) % 4), 1) || substr(lower(hex(randomblob(2))), 2) || '-' ||
+  lower(hex(randomblob(6))),
+  user_id, name, MIN(created_at), MIN(created_at)
+FROM (
+  SELECT DISTINCT clips.user_id AS user_id, trim(json_each.value) AS name, clips.created_at AS created_at
+  FROM clips, json_each(clips.tags)
+  WHERE clips.tags IS NOT NULL AND json_valid(clips.tags) AND trim(json_each.value) != ''
+)
+GROUP BY user_id, name;
+
+INSERT OR IGNORE INTO clips_tags (id, clip_id, tag_id, created_at, updated_at)
+SELECT
+  lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+  substr(lower(hex(randomblob(2))), 2) || '-' ||
+  substr('89ab', 1 + (abs(random()) % 4), 1) || substr(lower(hex(randomblob(2))), 2) || '-' ||
+  lower(hex(randomblob(6))),
+  clips.id, tags.id, clips.created_at, clips.created_at
+FROM clips, json_each(clips.tags)
+JOIN tags ON tags.user_id = clips.user_id AND tags.name = trim(json_each.value)
+WHERE clips.tags IS NOT NULL AND json_valid(clips.tags);
@@ -0,0 +1 @@
+UPDATE clips SET domain = '';
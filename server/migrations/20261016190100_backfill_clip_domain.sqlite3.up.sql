@@ -0,0 +1,9 @@
+-- Derive clips.domain (lowercased host) from the URL for existing clips:
+-- strip the scheme, then cut at the first path, query, fragment or port separator.
+UPDATE clips SET domain = substr(url, instr(url, '://') + 3) WHERE instr(url, '://') > 0;
+UPDATE clips SET domain = substr(domain, 1, instr(domain, '/') - 1) WHERE instr(domain, '/') > 0;
+UPDATE clips SET domain = substr(domain, 1, instr(domain, '?') - 1) WHERE instr(domain, '?') > 0;
+UPDATE clips SET domain = substr(domain, 1, instr(domain, '#') - 1) WHERE instr(domain, '#') > 0;
+UPDATE clips SET domain = substr(domain, instr(domain, '@') + 1) WHERE instr(domain, '@') > 0;
+UPDATE clips SET domain = substr(domain, 1, instr(domain, ':') - 1) WHERE instr(domain, ':') > 0;
+UPDATE clips SET domain = lower(domain);
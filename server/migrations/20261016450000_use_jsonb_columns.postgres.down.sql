@@ -0,0 +1,8 @@
+ALTER TABLE clips ALTER COLUMN metadata TYPE text USING metadata::text;
+ALTER TABLE tags ALTER COLUMN aliases DROP DEFAULT;
+ALTER TABLE tags ALTER COLUMN aliases TYPE text USING aliases::text;
+ALTER TABLE tags ALTER COLUMN aliases SET DEFAULT '[]';
+ALTER TABLE tags ALTER COLUMN implies DROP DEFAULT;
+ALTER TABLE tags ALTER COLUMN implies TYPE text USING implies::text;
+ALTER TABLE tags ALTER COLUMN implies SET DEFAULT '[]';
+ALTER TABLE taxonomy_tags ALTER COLUMN aliases TYPE text USING aliases::text;
@@ -0,0 +1,9 @@
+-- JSON documents stored as text on SQLite are JSONB on PostgreSQL
+ALTER TABLE clips ALTER COLUMN metadata TYPE jsonb USING metadata::jsonb;
+ALTER TABLE tags ALTER COLUMN aliases DROP DEFAULT;
+ALTER TABLE tags ALTER COLUMN aliases TYPE jsonb USING aliases::jsonb;
+ALTER TABLE tags ALTER COLUMN aliases SET DEFAULT '[]';
+ALTER TABLE tags ALTER COLUMN implies DROP DEFAULT;
+ALTER TABLE tags ALTER COLUMN implies TYPE jsonb USING implies::jsonb;
+ALTER TABLE tags ALTER COLUMN implies SET DEFAULT '[]';
+ALTER TABLE taxonomy_tags ALTER COLUMN aliases TYPE jsonb USING aliases::jsonb;
@@ -0,0 +1,11 @@
+// Package migrations holds the database migrations, embedded in the binary
+// so that `web-clipper migrate` runs them without this folder installed
+// next to it
+package migrations
+
+import "embed"
+
+// FS holds the fizz and SQL migrations (schema.sql is not one)
+//
+//go:embed *.fizz *.up.sql *.down.sql
+var FS embed.FS
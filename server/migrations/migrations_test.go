@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFSHoldsEveryMigration(t *testing.T) {
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".fizz") && !strings.HasSuffix(name, ".sql") || name == "schema.sql" {
+			continue
+		}
+		if _, err := fs.Stat(FS, name); err != nil {
+			t.Errorf("%s is not embedded: %v", name, err)
+		}
+	}
+	if _, err := fs.Stat(FS, "schema.sql"); err == nil {
+		t.Error("schema.sql is embedded")
+	}
+}
@@ -10,22 +10,9 @@ CREATE TABLE IF NOT EXISTS "users" (
 "clip_directory" TEXT,
 "created_at" DATETIME NOT NULL,
 "updated_at" DATETIME NOT NULL
-, "disabled" bool DEFAULT 'false');
+, "disabled" bool DEFAULT 'false', "kindle_email" TEXT, "frontmatter_template" TEXT, "folder_template" TEXT, "file_template" TEXT);
 CREATE UNIQUE INDEX "users_oauth_id_idx" ON "users" (oauth_id);
 CREATE INDEX "users_email_idx" ON "users" (email);
-CREATE TABLE IF NOT EXISTS "clips" (
-"id" TEXT PRIMARY KEY,
-"user_id" char(36) NOT NULL,
-"title" TEXT NOT NULL,
-"url" TEXT NOT NULL,
-"path" TEXT NOT NULL,
-"mode" TEXT NOT NULL DEFAULT 'article',
-"tags" TEXT,
-"notes" TEXT,
-"created_at" DATETIME NOT NULL,
-"updated_at" DATETIME NOT NULL
-);
-CREATE INDEX "clips_user_id_idx" ON "clips" (user_id);
 CREATE TABLE IF NOT EXISTS "api_tokens" (
 "id" TEXT PRIMARY KEY,
 "user_id" char(36) NOT NULL,
@@ -43,3 +30,299 @@ CREATE TABLE IF NOT EXISTS "api_tokens" (
 CREATE INDEX "api_tokens_user_id_idx" ON "api_tokens" (user_id);
 CREATE UNIQUE INDEX "api_tokens_token_hash_idx" ON "api_tokens" (token_hash);
 CREATE INDEX "api_tokens_prefix_idx" ON "api_tokens" (prefix);
+CREATE TABLE IF NOT EXISTS "kindle_deliveries" (
+"id" TEXT PRIMARY KEY,
+"user_id" char(36) NOT NULL,
+"clip_id" char(36) NOT NULL,
+"device_email" TEXT NOT NULL,
+"status" TEXT NOT NULL DEFAULT 'pending',
+"error" TEXT,
+"sent_at" DATETIME,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE INDEX "kindle_deliveries_user_id_idx" ON "kindle_deliveries" (user_id);
+CREATE INDEX "kindle_deliveries_clip_id_idx" ON "kindle_deliveries" (clip_id);
+CREATE TABLE IF NOT EXISTS "clip_versions" (
+"id" TEXT PRIMARY KEY,
+"clip_id" char(36) NOT NULL,
+"version" INTEGER NOT NULL,
+"path" TEXT NOT NULL DEFAULT '',
+"title" TEXT NOT NULL,
+"mode" TEXT NOT NULL,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX "clip_versions_clip_id_version_idx" ON "clip_versions" (clip_id, version);
+CREATE TABLE IF NOT EXISTS "highlights" (
+"id" TEXT PRIMARY KEY,
+"clip_id" char(36) NOT NULL,
+"user_id" char(36) NOT NULL,
+"text" TEXT NOT NULL,
+"selector" TEXT,
+"start_offset" INTEGER NOT NULL DEFAULT '0',
+"end_offset" INTEGER NOT NULL DEFAULT '0',
+"color" TEXT NOT NULL DEFAULT 'yellow',
+"comment" TEXT,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE INDEX "highlights_clip_id_idx" ON "highlights" (clip_id);
+CREATE TABLE IF NOT EXISTS "tags" (
+"id" TEXT PRIMARY KEY,
+"user_id" char(36) NOT NULL,
+"name" TEXT NOT NULL,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+, "aliases" TEXT NOT NULL DEFAULT '[]', "implies" TEXT NOT NULL DEFAULT '[]');
+CREATE UNIQUE INDEX "tags_user_id_name_idx" ON "tags" (user_id, name);
+CREATE TABLE IF NOT EXISTS "clips_tags" (
+"id" TEXT PRIMARY KEY,
+"clip_id" char(36) NOT NULL,
+"tag_id" char(36) NOT NULL,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX "clips_tags_clip_id_tag_id_idx" ON "clips_tags" (clip_id, tag_id);
+CREATE INDEX "clips_tags_tag_id_idx" ON "clips_tags" (tag_id);
+CREATE TABLE IF NOT EXISTS "clips" (
+"id" TEXT PRIMARY KEY,
+"user_id" char(36) NOT NULL,
+"title" TEXT NOT NULL,
+"url" TEXT NOT NULL,
+"path" TEXT NOT NULL,
+"mode" TEXT NOT NULL DEFAULT 'article',
+"notes" TEXT,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL,
+"deleted_at" DATETIME,
+"content_text" TEXT,
+"excerpt" TEXT,
+"normalized_url" TEXT NOT NULL DEFAULT '',
+"latitude" REAL,
+"longitude" REAL,
+"place" TEXT
+, "domain" TEXT NOT NULL DEFAULT '', "collection_id" char(36), "archived_at" DATETIME, "idempotency_key" TEXT, "storage_path" TEXT, "favicon" TEXT, "metadata" TEXT, "disabled_at" DATETIME, "language" TEXT, "read_at" DATETIME);
+CREATE INDEX "clips_latitude_longitude_idx" ON "clips" (latitude, longitude);
+CREATE INDEX "clips_user_id_normalized_url_idx" ON "clips" (user_id, normalized_url);
+CREATE INDEX "clips_deleted_at_idx" ON "clips" (deleted_at);
+CREATE INDEX "clips_user_id_idx" ON "clips" (user_id);
+CREATE TABLE IF NOT EXISTS "saved_searches" (
+"id" TEXT PRIMARY KEY,
+"user_id" char(36) NOT NULL,
+"name" TEXT NOT NULL,
+"filters" TEXT NOT NULL,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX "saved_searches_user_id_name_idx" ON "saved_searches" (user_id, name);
+CREATE INDEX "clips_user_id_domain_idx" ON "clips" (user_id, domain);
+CREATE TABLE IF NOT EXISTS "collections" (
+"id" TEXT PRIMARY KEY,
+"user_id" char(36) NOT NULL,
+"name" TEXT NOT NULL,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+, "storage_path" TEXT, "public" bool NOT NULL DEFAULT 'false');
+CREATE UNIQUE INDEX "collections_user_id_name_idx" ON "collections" (user_id, name);
+CREATE INDEX "clips_collection_id_idx" ON "clips" (collection_id);
+CREATE UNIQUE INDEX "clips_user_id_idempotency_key_idx" ON "clips" (user_id, idempotency_key);
+CREATE TABLE IF NOT EXISTS "webhooks" (
+"id" TEXT PRIMARY KEY,
+"user_id" char(36) NOT NULL,
+"url" TEXT NOT NULL,
+"events" TEXT NOT NULL,
+"secret" TEXT NOT NULL,
+"previous_secret" TEXT,
+"previous_secret_expires_at" DATETIME,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE INDEX "webhooks_user_id_idx" ON "webhooks" (user_id);
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+"id" TEXT PRIMARY KEY,
+"webhook_id" char(36) NOT NULL,
+"event" TEXT NOT NULL,
+"payload" TEXT NOT NULL,
+"status" TEXT NOT NULL DEFAULT 'pending',
+"response_status" INTEGER,
+"error" TEXT,
+"replay_of" char(36),
+"delivered_at" DATETIME,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+, "event_id" INTEGER);
+CREATE INDEX "webhook_deliveries_webhook_id_idx" ON "webhook_deliveries" (webhook_id);
+CREATE TABLE IF NOT EXISTS "upload_sessions" (
+"id" TEXT PRIMARY KEY,
+"user_id" char(36) NOT NULL,
+"content_type" TEXT NOT NULL,
+"size" bigint NOT NULL DEFAULT '0',
+"expires_at" DATETIME NOT NULL,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE INDEX "upload_sessions_user_id_idx" ON "upload_sessions" (user_id);
+CREATE INDEX "upload_sessions_expires_at_idx" ON "upload_sessions" (expires_at);
+CREATE TABLE IF NOT EXISTS "events" (
+"id" INTEGER PRIMARY KEY AUTOINCREMENT,
+"user_id" char(36) NOT NULL,
+"type" TEXT NOT NULL,
+"data" TEXT NOT NULL,
+"dispatched_at" DATETIME,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE TABLE sqlite_sequence(name,seq);
+CREATE INDEX "events_user_id_id_idx" ON "events" (user_id, id);
+CREATE INDEX "events_dispatched_at_idx" ON "events" (dispatched_at);
+CREATE TABLE IF NOT EXISTS "jobs" (
+"id" TEXT PRIMARY KEY,
+"user_id" char(36) NOT NULL,
+"type" TEXT NOT NULL,
+"payload" TEXT NOT NULL,
+"status" TEXT NOT NULL DEFAULT 'queued',
+"attempts" INTEGER NOT NULL DEFAULT '0',
+"result" TEXT,
+"error" TEXT,
+"run_at" DATETIME NOT NULL,
+"started_at" DATETIME,
+"finished_at" DATETIME,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE INDEX "jobs_status_run_at_idx" ON "jobs" (status, run_at);
+CREATE INDEX "jobs_user_id_idx" ON "jobs" (user_id);
+CREATE TABLE IF NOT EXISTS "shares" (
+"id" TEXT PRIMARY KEY,
+"user_id" char(36) NOT NULL,
+"clip_id" char(36) NOT NULL,
+"token" TEXT NOT NULL,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+, "disabled_at" DATETIME, "expires_at" DATETIME, "max_views" INTEGER, "views" INTEGER NOT NULL DEFAULT '0', "exhausted_at" DATETIME, "password_hash" TEXT);
+CREATE UNIQUE INDEX "shares_token_idx" ON "shares" (token);
+CREATE INDEX "shares_clip_id_idx" ON "shares" (clip_id);
+CREATE TABLE IF NOT EXISTS "abuse_reports" (
+"id" TEXT PRIMARY KEY,
+"share_id" char(36) NOT NULL,
+"clip_id" char(36) NOT NULL,
+"reason" TEXT NOT NULL,
+"details" TEXT,
+"reporter_email" TEXT,
+"status" TEXT NOT NULL DEFAULT 'open',
+"resolution_note" TEXT,
+"resolved_by" TEXT,
+"resolved_at" DATETIME,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+, "reporter_hash" TEXT NOT NULL DEFAULT '');
+CREATE INDEX "abuse_reports_status_created_at_idx" ON "abuse_reports" (status, created_at);
+CREATE INDEX "clips_user_id_language_idx" ON "clips" (user_id, language);
+CREATE TABLE IF NOT EXISTS "clip_rules" (
+"id" TEXT PRIMARY KEY,
+"user_id" char(36) NOT NULL,
+"domain" TEXT,
+"url_pattern" TEXT,
+"tags" TEXT NOT NULL,
+"collection_id" char(36),
+"mode" TEXT,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE INDEX "clip_rules_user_id_idx" ON "clip_rules" (user_id);
+CREATE TABLE IF NOT EXISTS "taxonomy_tags" (
+"id" TEXT PRIMARY KEY,
+"name" TEXT NOT NULL,
+"description" TEXT,
+"aliases" TEXT NOT NULL,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX "taxonomy_tags_name_idx" ON "taxonomy_tags" (name);
+CREATE TABLE IF NOT EXISTS "clip_views" (
+"id" TEXT PRIMARY KEY,
+"user_id" char(36) NOT NULL,
+"clip_id" char(36) NOT NULL,
+"views" INTEGER NOT NULL DEFAULT '0',
+"viewed_at" DATETIME NOT NULL,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX "clip_views_user_id_clip_id_idx" ON "clip_views" (user_id, clip_id);
+CREATE INDEX "clip_views_user_id_viewed_at_idx" ON "clip_views" (user_id, viewed_at);
+CREATE TABLE IF NOT EXISTS "storage_usages" (
+"id" TEXT PRIMARY KEY,
+"user_id" char(36) NOT NULL,
+"clips" INTEGER NOT NULL DEFAULT '0',
+"bytes" bigint NOT NULL DEFAULT '0',
+"trashed_clips" INTEGER NOT NULL DEFAULT '0',
+"trashed_bytes" bigint NOT NULL DEFAULT '0',
+"modes" TEXT NOT NULL DEFAULT '{}',
+"computed_at" DATETIME NOT NULL,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX "storage_usages_user_id_idx" ON "storage_usages" (user_id);
+CREATE TABLE IF NOT EXISTS "retention_rules" (
+"id" TEXT PRIMARY KEY,
+"user_id" char(36) NOT NULL,
+"mode" TEXT,
+"tag" TEXT,
+"max_age_days" INTEGER NOT NULL,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE INDEX "retention_rules_user_id_idx" ON "retention_rules" (user_id);
+CREATE TABLE IF NOT EXISTS "cloud_connections" (
+"id" TEXT PRIMARY KEY,
+"user_id" char(36) NOT NULL,
+"provider" TEXT NOT NULL,
+"folder" TEXT NOT NULL,
+"token" TEXT NOT NULL,
+"last_upload_at" DATETIME,
+"last_error" TEXT,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX "cloud_connections_user_id_provider_idx" ON "cloud_connections" (user_id, provider);
+CREATE TABLE IF NOT EXISTS "audit_logs" (
+"id" TEXT PRIMARY KEY,
+"action" TEXT NOT NULL,
+"source" TEXT NOT NULL,
+"actor" TEXT,
+"user_id" char(36),
+"clip_id" char(36),
+"ip" TEXT,
+"details" TEXT,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE INDEX "audit_logs_created_at_idx" ON "audit_logs" (created_at);
+CREATE INDEX "audit_logs_user_id_created_at_idx" ON "audit_logs" (user_id, created_at);
+CREATE INDEX "audit_logs_action_created_at_idx" ON "audit_logs" (action, created_at);
+CREATE TABLE IF NOT EXISTS "user_settings" (
+"id" TEXT PRIMARY KEY,
+"user_id" char(36) NOT NULL,
+"default_mode" TEXT,
+"default_tags" TEXT NOT NULL DEFAULT '[]',
+"convert_to_webp" bool,
+"preserve_original" bool,
+"max_dimension_px" INTEGER,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX "user_settings_user_id_idx" ON "user_settings" (user_id);
+CREATE TABLE IF NOT EXISTS "device_authorizations" (
+"id" TEXT PRIMARY KEY,
+"device_code_hash" TEXT NOT NULL,
+"user_code" TEXT NOT NULL,
+"user_id" char(36),
+"status" TEXT NOT NULL DEFAULT 'pending',
+"expires_at" DATETIME NOT NULL,
+"last_polled_at" DATETIME,
+"created_at" DATETIME NOT NULL,
+"updated_at" DATETIME NOT NULL
+, "client_ip" TEXT NOT NULL DEFAULT '', "client_agent" TEXT NOT NULL DEFAULT '');
+CREATE UNIQUE INDEX "device_authorizations_device_code_hash_idx" ON "device_authorizations" (device_code_hash);
+CREATE UNIQUE INDEX "device_authorizations_user_code_idx" ON "device_authorizations" (user_code);
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// Abuse report statuses
+const (
+	ReportOpen      = "open"      // Waiting for review
+	ReportDismissed = "dismissed" // Reviewed, nothing to do
+	ReportActioned  = "actioned"  // Reviewed, the share or clip was taken down
+)
+
+// ReportReasons are the reasons a shared clip can be reported for
+var ReportReasons = []string{"spam", "copyright", "illegal", "harassment", "other"}
+
+// AbuseReport is a visitor's report of a shared clip, reviewed by an admin
+type AbuseReport struct {
+	ID             uuid.UUID    `json:"id" db:"id"`
+	ShareID        uuid.UUID    `json:"share_id" db:"share_id"`
+	ClipID         uuid.UUID    `json:"clip_id" db:"clip_id"`
+	Reason         string       `json:"reason" db:"reason"`
+	Details        nulls.String `json:"details" db:"details"`
+	ReporterEmail  nulls.String `json:"reporter_email" db:"reporter_email"`
+	ReporterHash   string       `json:"-" db:"reporter_hash"` // Keyed hash of the reporter's address, to count reporters
+	Status         string       `json:"status" db:"status"`
+	ResolutionNote nulls.String `json:"resolution_note" db:"resolution_note"`
+	ResolvedBy     nulls.String `json:"resolved_by" db:"resolved_by"` // Email of the admin who reviewed it
+	ResolvedAt     nulls.Time   `json:"resolved_at" db:"resolved_at"`
+	CreatedAt      time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// AbuseReports is a slice of AbuseReport for collection operations
+type AbuseReports []AbuseReport
+
+// Validate validates the AbuseReport fields
+func (r *AbuseReport) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: r.ShareID, Name: "ShareID"},
+		&validators.UUIDIsPresent{Field: r.ClipID, Name: "ClipID"},
+		&validators.StringInclusion{Field: r.Reason, Name: "Reason", List: ReportReasons},
+		&validators.StringInclusion{Field: r.Status, Name: "Status", List: []string{ReportOpen, ReportDismissed, ReportActioned}},
+		&validators.StringLengthInRange{Field: r.Details.String, Name: "Details", Max: 2000},
+		&validators.StringLengthInRange{Field: r.ReporterEmail.String, Name: "ReporterEmail", Max: 255},
+	), nil
+}
+
+// FindAbuseReports returns the reports with the given status (all when
+// empty), oldest first
+func FindAbuseReports(tx *pop.Connection, status string) (AbuseReports, error) {
+	reports := AbuseReports{}
+	q := tx.Order("created_at ASC")
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	err := q.All(&reports)
+	return reports, err
+}
+
+// CountOpenReportersByShare returns the number of distinct reporters, by
+// ReporterHash, of the reports of a share waiting for review
+func CountOpenReportersByShare(tx *pop.Connection, shareID uuid.UUID) (int, error) {
+	return tx.Select("reporter_hash").Where("share_id = ? AND status = ?", shareID, ReportOpen).
+		GroupBy("reporter_hash").Count(&AbuseReport{})
+}
@@ -118,3 +118,15 @@ func FindTokenByHash(tx *pop.Connection, tokenHash string) (*ApiToken, error) {
 	err := tx.Where("token_hash = ?", tokenHash).First(token)
 	return token, err
 }
+
+// DeleteStaleTokens deletes the tokens that expired or were revoked before
+// the cutoff and returns how many were deleted
+func DeleteStaleTokens(tx *pop.Connection, cutoff time.Time) (int, error) {
+	q := tx.Where("(expires_at IS NOT NULL AND expires_at < ?) OR (revoked = ? AND revoked_at < ?)", cutoff, true, cutoff)
+	n, err := q.Count(&ApiToken{})
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	err = tx.RawQuery("DELETE FROM api_tokens WHERE (expires_at IS NOT NULL AND expires_at < ?) OR (revoked = ? AND revoked_at < ?)", cutoff, true, cutoff).Exec()
+	return n, err
+}
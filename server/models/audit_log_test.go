@@ -0,0 +1,17 @@
+package models
+
+import (
+	"github.com/gofrs/uuid"
+)
+
+func (ms *ModelSuite) Test_NewAuditLog() {
+	clipID := uuid.Must(uuid.NewV4())
+	a := NewAuditLog(AuditSourceAPI, "clip.delete", "user_id", "not-an-id", "clip_id", clipID, "ip", "192.0.2.1", "clips", 2)
+	ms.Equal("clip.delete", a.Action)
+	ms.False(a.UserID.Valid)
+	ms.Equal(clipID, a.ClipID.UUID)
+	ms.Equal("192.0.2.1", a.IP.String)
+	ms.JSONEq(`{"clips":2}`, a.Details.String)
+
+	ms.False(NewAuditLog(AuditSourceCLI, "user.enable").Details.Valid)
+}
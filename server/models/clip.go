@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/gobuffalo/nulls"
@@ -12,21 +13,46 @@ import (
 
 // Clip represents a saved web clip
 type Clip struct {
-	ID        uuid.UUID    `json:"id" db:"id"`
-	UserID    uuid.UUID    `json:"user_id" db:"user_id"`
-	Title     string       `json:"title" db:"title"`
-	URL       string       `json:"url" db:"url"`
-	Path      string       `json:"path" db:"path"`           // Relative path to clip folder
-	Mode      string       `json:"mode" db:"mode"`           // article, bookmark, screenshot, etc.
-	Tags      nulls.String `json:"tags" db:"tags"`           // JSON array stored as string
-	Notes     nulls.String `json:"notes" db:"notes"`
-	CreatedAt time.Time    `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time    `json:"updated_at" db:"updated_at"`
+	ID             uuid.UUID     `json:"id" db:"id"`
+	UserID         uuid.UUID     `json:"user_id" db:"user_id"`
+	Title          string        `json:"title" db:"title"`
+	URL            string        `json:"url" db:"url"`
+	NormalizedURL  string        `json:"normalized_url" db:"normalized_url"` // Used to detect re-clips of the same page
+	Domain         string        `json:"domain" db:"domain"`                 // Lowercased host, for domain filters
+	Path           string        `json:"path" db:"path"`                     // Relative path to clip folder
+	Mode           string        `json:"mode" db:"mode"`                     // article, bookmark, screenshot, etc.
+	Notes          nulls.String  `json:"notes" db:"notes"`
+	ContentText    nulls.String  `json:"content_text" db:"content_text"` // Extracted text (OCR, PDF) used for search
+	Excerpt        nulls.String  `json:"excerpt" db:"excerpt"`           // Short preview of extracted text
+	Latitude       nulls.Float64 `json:"latitude" db:"latitude"`
+	Longitude      nulls.Float64 `json:"longitude" db:"longitude"`
+	Place          nulls.String  `json:"place" db:"place"`       // Human-readable location name
+	Metadata       nulls.String  `json:"metadata" db:"metadata"` // JSON of what the page declares (OpenGraph, author, ...)
+	Language       nulls.String  `json:"language" db:"language"` // ISO 639-1 code of the content, declared or detected
+	Favicon        nulls.String  `json:"favicon" db:"favicon"`   // File name of the site's icon in the favicon cache
+	CollectionID   nulls.UUID    `json:"collection_id" db:"collection_id"`
+	StoragePath    nulls.String  `json:"-" db:"storage_path"`          // Storage override of the collection the folder was moved to
+	ArchivedAt     nulls.Time    `json:"archived_at" db:"archived_at"` // Archived clips are hidden from default listings
+	ReadAt         nulls.Time    `json:"read_at" db:"read_at"`         // Set when the user marked the clip read
+	IdempotencyKey nulls.String  `json:"-" db:"idempotency_key"`       // Idempotency-Key of the last request that saved the clip
+	DeletedAt      nulls.Time    `json:"deleted_at" db:"deleted_at"`   // Set when the clip is in the trash
+	DisabledAt     nulls.Time    `json:"disabled_at" db:"disabled_at"` // Taken down by an admin: hidden from share links, feeds and the mirror
+	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at" db:"updated_at"`
+
+	// Loaded from clips_tags with LoadTags
+	Tags []string `json:"tags" db:"-"`
 
 	// Associations
 	User User `json:"-" belongs_to:"user"`
 }
 
+// IsNote tells a clip stored as a single note (storage.layout: obsidian)
+// from one stored in a clip folder
+func (c *Clip) IsNote() bool {
+	return strings.HasSuffix(c.Path, ".md")
+}
+
 // Clips is a slice of Clip for collection operations
 type Clips []Clip
 
@@ -44,7 +70,7 @@ func (c *Clip) Validate(tx *pop.Connection) (*validate.Errors, error) {
 // FindClipsByUserID returns all clips for a user with pagination
 func FindClipsByUserID(tx *pop.Connection, userID uuid.UUID, page, perPage int) (Clips, int, error) {
 	clips := Clips{}
-	q := tx.Where("user_id = ?", userID).Order("created_at DESC")
+	q := tx.Where("user_id = ? AND deleted_at IS NULL", userID).Order("created_at DESC")
 
 	// Get total count
 	count, err := q.Count(&Clip{})
@@ -57,9 +83,46 @@ func FindClipsByUserID(tx *pop.Connection, userID uuid.UUID, page, perPage int)
 	return clips, count, err
 }
 
-// FindClipByIDAndUser finds a clip ensuring ownership
+// FindClipByIDAndUser finds a clip ensuring ownership (excludes trashed clips)
 func FindClipByIDAndUser(tx *pop.Connection, clipID, userID uuid.UUID) (*Clip, error) {
 	clip := &Clip{}
-	err := tx.Where("id = ? AND user_id = ?", clipID, userID).First(clip)
+	err := tx.Where("id = ? AND user_id = ? AND deleted_at IS NULL", clipID, userID).First(clip)
+	return clip, err
+}
+
+// FindClipByIdempotencyKey finds the clip saved by a request with the given Idempotency-Key
+func FindClipByIdempotencyKey(tx *pop.Connection, key string, userID uuid.UUID) (*Clip, error) {
+	clip := &Clip{}
+	err := tx.Where("idempotency_key = ? AND user_id = ?", key, userID).First(clip)
+	return clip, err
+}
+
+// FindTrashedClipByIDAndUser finds a clip in the user's trash
+func FindTrashedClipByIDAndUser(tx *pop.Connection, clipID, userID uuid.UUID) (*Clip, error) {
+	clip := &Clip{}
+	err := tx.Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", clipID, userID).First(clip)
+	return clip, err
+}
+
+// FindTrashedClipsByUserID returns the user's trashed clips, most recently deleted first
+func FindTrashedClipsByUserID(tx *pop.Connection, userID uuid.UUID) (Clips, error) {
+	clips := Clips{}
+	err := tx.Where("user_id = ? AND deleted_at IS NOT NULL", userID).Order("deleted_at DESC").All(&clips)
+	return clips, err
+}
+
+// FindExpiredTrashedClips returns trashed clips deleted before the cutoff (all users)
+func FindExpiredTrashedClips(tx *pop.Connection, cutoff time.Time) (Clips, error) {
+	clips := Clips{}
+	err := tx.Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).All(&clips)
+	return clips, err
+}
+
+// FindClipByURLAndUser finds the user's most recent non-trashed clip of a URL.
+// Clips saved before URL normalization are matched on their raw URL.
+func FindClipByURLAndUser(tx *pop.Connection, url, normalizedURL string, userID uuid.UUID) (*Clip, error) {
+	clip := &Clip{}
+	err := tx.Where("(normalized_url = ? OR url = ?) AND user_id = ? AND deleted_at IS NULL", normalizedURL, url, userID).
+		Order("created_at DESC").First(clip)
 	return clip, err
 }
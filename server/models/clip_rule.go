@@ -0,0 +1,92 @@
+package models
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// ClipRule files the clips of a domain or URL pattern automatically: it adds
+// tags to them, and can put them in a collection or set their mode
+type ClipRule struct {
+	ID           uuid.UUID    `json:"id" db:"id"`
+	UserID       uuid.UUID    `json:"user_id" db:"user_id"`
+	Domain       nulls.String `json:"domain" db:"domain"`           // Matches the domain and its subdomains
+	URLPattern   nulls.String `json:"url_pattern" db:"url_pattern"` // Matches the whole URL; * stands for any characters
+	Tags         string       `json:"tags" db:"tags"`               // JSON-encoded tag names
+	CollectionID nulls.UUID   `json:"collection_id" db:"collection_id"`
+	Mode         nulls.String `json:"mode" db:"mode"`
+	CreatedAt    time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// ClipRules is a slice of ClipRule for collection operations
+type ClipRules []ClipRule
+
+// Validate validates the ClipRule fields
+func (r *ClipRule) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.Validate(
+		&validators.UUIDIsPresent{Field: r.UserID, Name: "UserID"},
+	)
+	if !r.Domain.Valid && !r.URLPattern.Valid {
+		verrs.Add("domain", "domain or url_pattern is required")
+	}
+	if len(r.TagList()) == 0 && !r.CollectionID.Valid && !r.Mode.Valid {
+		verrs.Add("tags", "tags, collection_id or mode is required")
+	}
+	return verrs, nil
+}
+
+// TagList returns the tags the rule adds
+func (r *ClipRule) TagList() []string {
+	var tags []string
+	json.Unmarshal([]byte(r.Tags), &tags)
+	return tags
+}
+
+// SetTagList replaces the tags the rule adds
+func (r *ClipRule) SetTagList(tags []string) {
+	data, _ := json.Marshal(CleanTagNames(tags))
+	r.Tags = string(data)
+}
+
+// Matches reports whether a clip of rawURL, on domain, is filed by the rule
+func (r *ClipRule) Matches(rawURL, domain string) bool {
+	if r.Domain.Valid && domain != r.Domain.String && !strings.HasSuffix(domain, "."+r.Domain.String) {
+		return false
+	}
+	if r.URLPattern.Valid && !urlPatternRegexp(r.URLPattern.String).MatchString(rawURL) {
+		return false
+	}
+	return true
+}
+
+// urlPatternRegexp compiles a URL pattern, where * stands for any characters
+func urlPatternRegexp(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile("(?i)^" + strings.Join(parts, ".*") + "$")
+}
+
+// FindClipRulesByUserID returns the user's rules in the order they were created
+func FindClipRulesByUserID(tx *pop.Connection, userID uuid.UUID) (ClipRules, error) {
+	rules := ClipRules{}
+	err := tx.Where("user_id = ?", userID).Order("created_at ASC, id ASC").All(&rules)
+	return rules, err
+}
+
+// FindClipRuleByIDAndUser finds a rule ensuring ownership
+func FindClipRuleByIDAndUser(tx *pop.Connection, ruleID, userID uuid.UUID) (*ClipRule, error) {
+	rule := &ClipRule{}
+	err := tx.Where("id = ? AND user_id = ?", ruleID, userID).First(rule)
+	return rule, err
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// ClipVersion records one capture of a clip's URL
+type ClipVersion struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	ClipID    uuid.UUID `json:"clip_id" db:"clip_id"`
+	Version   int       `json:"version" db:"version"`
+	Path      string    `json:"path" db:"path"` // Relative to the clip folder; empty for the current capture
+	Title     string    `json:"title" db:"title"`
+	Mode      string    `json:"mode" db:"mode"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ClipVersions is a slice of ClipVersion for collection operations
+type ClipVersions []ClipVersion
+
+// Validate validates the ClipVersion fields
+func (v *ClipVersion) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: v.ClipID, Name: "ClipID"},
+		&validators.IntIsGreaterThan{Field: v.Version, Name: "Version", Compared: 0},
+	), nil
+}
+
+// FindClipVersions returns a clip's versions, oldest first
+func FindClipVersions(tx *pop.Connection, clipID uuid.UUID) (ClipVersions, error) {
+	versions := ClipVersions{}
+	err := tx.Where("clip_id = ?", clipID).Order("version ASC").All(&versions)
+	return versions, err
+}
+
+// FindClipVersion finds a specific version of a clip
+func FindClipVersion(tx *pop.Connection, clipID uuid.UUID, version int) (*ClipVersion, error) {
+	v := &ClipVersion{}
+	err := tx.Where("clip_id = ? AND version = ?", clipID, version).First(v)
+	return v, err
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// ClipView is when a user last opened a clip, and how often they did
+type ClipView struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	ClipID    uuid.UUID `json:"clip_id" db:"clip_id"`
+	Views     int       `json:"views" db:"views"`
+	ViewedAt  time.Time `json:"viewed_at" db:"viewed_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ClipViews is a slice of ClipView for collection operations
+type ClipViews []ClipView
+
+// RecordClipView counts a view of the clip by the user at now
+func RecordClipView(tx *pop.Connection, userID, clipID uuid.UUID, now time.Time) error {
+	n, err := tx.RawQuery(
+		"UPDATE clip_views SET views = views + 1, viewed_at = ?, updated_at = ? WHERE user_id = ? AND clip_id = ?",
+		now, now, userID, clipID,
+	).ExecWithCount()
+	if err != nil || n > 0 {
+		return err
+	}
+	return tx.Create(&ClipView{
+		ID:       uuid.Must(uuid.NewV4()),
+		UserID:   userID,
+		ClipID:   clipID,
+		Views:    1,
+		ViewedAt: now,
+	})
+}
+
+// FindRecentClipViews returns the user's latest views of clips they still
+// own and haven't trashed, most recent first
+func FindRecentClipViews(tx *pop.Connection, userID uuid.UUID, limit int) (ClipViews, error) {
+	views := ClipViews{}
+	err := tx.Where("user_id = ?", userID).
+		Where("clip_id IN (SELECT id FROM clips WHERE user_id = ? AND deleted_at IS NULL)", userID).
+		Order("viewed_at DESC").
+		Limit(limit).
+		All(&views)
+	return views, err
+}
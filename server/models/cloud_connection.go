@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// CloudConnection is a user's Dropbox or Google Drive folder their new clips
+// are uploaded to
+type CloudConnection struct {
+	ID           uuid.UUID    `json:"id" db:"id"`
+	UserID       uuid.UUID    `json:"user_id" db:"user_id"`
+	Provider     string       `json:"provider" db:"provider"` // "dropbox" or "google_drive"
+	Folder       string       `json:"folder" db:"folder"`     // Path from the root of the user's storage
+	Token        string       `json:"-" db:"token"`           // OAuth token (JSON), refreshed as it expires
+	LastUploadAt nulls.Time   `json:"last_upload_at" db:"last_upload_at"`
+	LastError    nulls.String `json:"last_error" db:"last_error"` // Of the last upload; cleared when one succeeds
+	CreatedAt    time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// CloudConnections is a slice of CloudConnection for collection operations
+type CloudConnections []CloudConnection
+
+// Validate validates the CloudConnection fields
+func (cc *CloudConnection) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: cc.UserID, Name: "UserID"},
+		&validators.StringIsPresent{Field: cc.Provider, Name: "Provider"},
+		&validators.StringIsPresent{Field: cc.Token, Name: "Token"},
+	), nil
+}
+
+// FindCloudConnectionsByUserID returns the user's connections by provider
+func FindCloudConnectionsByUserID(tx *pop.Connection, userID uuid.UUID) (CloudConnections, error) {
+	conns := CloudConnections{}
+	err := tx.Where("user_id = ?", userID).Order("provider ASC").All(&conns)
+	return conns, err
+}
+
+// FindCloudConnection finds the user's connection to a provider
+func FindCloudConnection(tx *pop.Connection, userID uuid.UUID, provider string) (*CloudConnection, error) {
+	conn := &CloudConnection{}
+	err := tx.Where("user_id = ? AND provider = ?", userID, provider).First(conn)
+	return conn, err
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// Collection is a named group of clips; each clip belongs to at most one
+type Collection struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	UserID      uuid.UUID    `json:"user_id" db:"user_id"`
+	Name        string       `json:"name" db:"name"`
+	StoragePath nulls.String `json:"storage_path" db:"storage_path"` // Where its clips are stored: a subpath of the user's clip directory or an absolute path
+	Public      bool         `json:"public" db:"public"`             // Published by the read-only mirror
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// Collections is a slice of Collection for collection operations
+type Collections []Collection
+
+// Validate validates the Collection fields
+func (c *Collection) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: c.UserID, Name: "UserID"},
+		&validators.StringIsPresent{Field: c.Name, Name: "Name"},
+		&validators.StringLengthInRange{Field: c.Name, Name: "Name", Max: 100},
+	), nil
+}
+
+// FindCollectionsByUserID returns the user's collections sorted by name
+func FindCollectionsByUserID(tx *pop.Connection, userID uuid.UUID) (Collections, error) {
+	collections := Collections{}
+	err := tx.Where("user_id = ?", userID).Order("name ASC").All(&collections)
+	return collections, err
+}
+
+// FindCollectionByIDAndUser finds a collection ensuring ownership
+func FindCollectionByIDAndUser(tx *pop.Connection, collectionID, userID uuid.UUID) (*Collection, error) {
+	collection := &Collection{}
+	err := tx.Where("id = ? AND user_id = ?", collectionID, userID).First(collection)
+	return collection, err
+}
+
+// FindPublicCollections returns every user's public collections sorted by name
+func FindPublicCollections(tx *pop.Connection) (Collections, error) {
+	collections := Collections{}
+	err := tx.Where("public = ?", true).Order("name ASC, created_at ASC").All(&collections)
+	return collections, err
+}
+
+// FindCollectionByName finds the user's collection with the given name
+func FindCollectionByName(tx *pop.Connection, userID uuid.UUID, name string) (*Collection, error) {
+	collection := &Collection{}
+	err := tx.Where("user_id = ? AND name = ?", userID, name).First(collection)
+	return collection, err
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// Statuses of a device authorization
+const (
+	DeviceAuthorizationPending  = "pending"  // Waiting for the user to log in
+	DeviceAuthorizationApproved = "approved" // Tokens can be issued to the device
+	DeviceAuthorizationDenied   = "denied"
+)
+
+// userCodeAlphabet has no vowels, so user codes spell no words, and no
+// look-alike characters
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// DeviceAuthorization is a login of the OAuth device flow (RFC 8628): a CLI
+// polls with the device code while the user logs in on a browser with the
+// user code.
+type DeviceAuthorization struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	DeviceCodeHash string     `json:"-" db:"device_code_hash"`  // HashToken of the device code
+	UserCode       string     `json:"user_code" db:"user_code"` // XXXX-XXXX
+	UserID         nulls.UUID `json:"user_id" db:"user_id"`     // Set on approval
+	Status         string     `json:"status" db:"status"`
+	ExpiresAt      time.Time  `json:"expires_at" db:"expires_at"`
+	LastPolledAt   nulls.Time `json:"last_polled_at" db:"last_polled_at"`
+	ClientIP       string     `json:"client_ip" db:"client_ip"`       // Of the device that requested the code
+	ClientAgent    string     `json:"client_agent" db:"client_agent"` // Its User-Agent, shown for the user to confirm
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Validate validates the DeviceAuthorization fields
+func (a *DeviceAuthorization) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: a.DeviceCodeHash, Name: "DeviceCodeHash"},
+		&validators.StringIsPresent{Field: a.UserCode, Name: "UserCode"},
+		&validators.StringInclusion{Field: a.Status, Name: "Status", List: []string{DeviceAuthorizationPending, DeviceAuthorizationApproved, DeviceAuthorizationDenied}},
+		&validators.TimeIsPresent{Field: a.ExpiresAt, Name: "ExpiresAt"},
+	), nil
+}
+
+// maxClientAgentLength caps the User-Agent stored with an authorization
+const maxClientAgentLength = 200
+
+// CreateDeviceAuthorization starts a device login valid for ttl for the
+// client at clientIP, returning it with its device code, which is only
+// stored hashed. Expired ones are deleted.
+func CreateDeviceAuthorization(tx *pop.Connection, ttl time.Duration, clientIP, clientAgent string) (*DeviceAuthorization, string, error) {
+	if err := tx.RawQuery("DELETE FROM device_authorizations WHERE expires_at <= ?", time.Now()).Exec(); err != nil {
+		return nil, "", err
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, "", fmt.Errorf("failed to generate device code: %w", err)
+	}
+	deviceCode := base64.RawURLEncoding.EncodeToString(random)
+	var userCode strings.Builder
+	for i := range 8 {
+		if i == 4 {
+			userCode.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(userCodeAlphabet))))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to generate user code: %w", err)
+		}
+		userCode.WriteByte(userCodeAlphabet[n.Int64()])
+	}
+
+	if runes := []rune(clientAgent); len(runes) > maxClientAgentLength {
+		clientAgent = string(runes[:maxClientAgentLength])
+	}
+	auth := &DeviceAuthorization{
+		ID:             uuid.Must(uuid.NewV4()),
+		DeviceCodeHash: HashToken(deviceCode),
+		UserCode:       userCode.String(),
+		Status:         DeviceAuthorizationPending,
+		ExpiresAt:      time.Now().Add(ttl),
+		ClientIP:       clientIP,
+		ClientAgent:    clientAgent,
+	}
+	verrs, err := tx.ValidateAndCreate(auth)
+	if err != nil {
+		return nil, "", err
+	}
+	if verrs.HasAny() {
+		return nil, "", verrs
+	}
+	return auth, deviceCode, nil
+}
+
+// NormalizeUserCode returns code as stored: upper case, dashes and spaces
+// ignored. It returns "" when code can't be a user code.
+func NormalizeUserCode(code string) string {
+	var letters []byte
+	for _, r := range strings.ToUpper(code) {
+		switch {
+		case r == '-' || r == ' ':
+			continue
+		case !strings.ContainsRune(userCodeAlphabet, r):
+			return ""
+		}
+		letters = append(letters, byte(r))
+	}
+	if len(letters) != 8 {
+		return ""
+	}
+	return string(letters[:4]) + "-" + string(letters[4:])
+}
+
+// FindDeviceAuthorizationByUserCode finds the unexpired authorization of a
+// user code, as typed by the user
+func FindDeviceAuthorizationByUserCode(tx *pop.Connection, userCode string) (*DeviceAuthorization, error) {
+	auth := &DeviceAuthorization{}
+	err := tx.Where("user_code = ? AND expires_at > ?", NormalizeUserCode(userCode), time.Now()).First(auth)
+	return auth, err
+}
+
+// FindDeviceAuthorizationByDeviceCode finds the authorization of a device
+// code, expired or not
+func FindDeviceAuthorizationByDeviceCode(tx *pop.Connection, deviceCode string) (*DeviceAuthorization, error) {
+	auth := &DeviceAuthorization{}
+	err := tx.Where("device_code_hash = ?", HashToken(deviceCode)).First(auth)
+	return auth, err
+}
+
+// RedeemDeviceAuthorization deletes an approved authorization so its device
+// code gets tokens once. It reports false when a concurrent poll redeemed it
+// first.
+func RedeemDeviceAuthorization(tx *pop.Connection, auth *DeviceAuthorization) (bool, error) {
+	n, err := tx.RawQuery("DELETE FROM device_authorizations WHERE id = ? AND status = ?",
+		auth.ID, DeviceAuthorizationApproved).ExecWithCount()
+	return n == 1, err
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// Event types
+const (
+	EventClipCreated = "clip.created"
+	EventClipUpdated = "clip.updated" // Re-clipped: a new version, or merged into the clip
+)
+
+// Event is a domain change recorded in the same transaction as the change
+// itself (an outbox), so it is delivered to webhooks and event streams even
+// if the process stops right after the commit. IDs increase, which lets
+// stream clients resume after the last event they saw.
+type Event struct {
+	ID           int64      `json:"id" db:"id"`
+	UserID       uuid.UUID  `json:"user_id" db:"user_id"`
+	Type         string     `json:"type" db:"type"`
+	Data         string     `json:"data" db:"data"`                   // JSON
+	DispatchedAt nulls.Time `json:"dispatched_at" db:"dispatched_at"` // When webhook deliveries were queued
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Events is a slice of Event for collection operations
+type Events []Event
+
+// Validate validates the Event fields
+func (e *Event) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: e.UserID, Name: "UserID"},
+		&validators.StringIsPresent{Field: e.Type, Name: "Type"},
+		&validators.StringIsPresent{Field: e.Data, Name: "Data"},
+	), nil
+}
+
+// FindUndispatchedEvents returns the oldest events not yet handed to webhooks
+func FindUndispatchedEvents(tx *pop.Connection, limit int) (Events, error) {
+	events := Events{}
+	err := tx.Where("dispatched_at IS NULL").Order("id ASC").Limit(limit).All(&events)
+	return events, err
+}
+
+// DeleteDispatchedEvents deletes the events handed to webhooks before
+// cutoff, and returns how many
+func DeleteDispatchedEvents(tx *pop.Connection, cutoff time.Time) (int, error) {
+	n, err := tx.RawQuery("DELETE FROM events WHERE dispatched_at IS NOT NULL AND dispatched_at < ?", cutoff).ExecWithCount()
+	return int(n), err
+}
+
+// FindUserEventsAfter returns the user's events with an ID above afterID, oldest first
+func FindUserEventsAfter(tx *pop.Connection, userID uuid.UUID, afterID int64, limit int) (Events, error) {
+	events := Events{}
+	err := tx.Where("user_id = ? AND id > ?", userID, afterID).Order("id ASC").Limit(limit).All(&events)
+	return events, err
+}
+
+// LatestEventID returns the ID of the user's most recent event, or 0
+func LatestEventID(tx *pop.Connection, userID uuid.UUID) (int64, error) {
+	event := &Event{}
+	err := tx.Where("user_id = ?", userID).Order("id DESC").First(event)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return event.ID, err
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// HighlightColors are the colors accepted from clients
+var HighlightColors = []string{"yellow", "green", "blue", "pink", "purple"}
+
+// Highlight is a text highlight or margin annotation attached to a clip
+type Highlight struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	ClipID      uuid.UUID    `json:"clip_id" db:"clip_id"`
+	UserID      uuid.UUID    `json:"user_id" db:"user_id"`
+	Text        string       `json:"text" db:"text"`                 // Highlighted text
+	Selector    nulls.String `json:"selector" db:"selector"`         // Anchor in the page (e.g. CSS selector or XPath)
+	StartOffset int          `json:"start_offset" db:"start_offset"` // Character offsets within the selected node
+	EndOffset   int          `json:"end_offset" db:"end_offset"`
+	Color       string       `json:"color" db:"color"`
+	Comment     nulls.String `json:"comment" db:"comment"` // Margin annotation
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// Highlights is a slice of Highlight for collection operations
+type Highlights []Highlight
+
+// Validate validates the Highlight fields
+func (h *Highlight) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: h.ClipID, Name: "ClipID"},
+		&validators.UUIDIsPresent{Field: h.UserID, Name: "UserID"},
+		&validators.StringIsPresent{Field: h.Text, Name: "Text"},
+		&validators.StringInclusion{Field: h.Color, Name: "Color", List: HighlightColors},
+		&validators.IntIsGreaterThan{Field: h.StartOffset, Name: "StartOffset", Compared: -1},
+		&validators.IntIsGreaterThan{Field: h.EndOffset, Name: "EndOffset", Compared: h.StartOffset - 1},
+	), nil
+}
+
+// FindHighlightsByClip returns a clip's highlights in document order
+func FindHighlightsByClip(tx *pop.Connection, clipID uuid.UUID) (Highlights, error) {
+	highlights := Highlights{}
+	err := tx.Where("clip_id = ?", clipID).Order("start_offset ASC, created_at ASC").All(&highlights)
+	return highlights, err
+}
+
+// FindHighlightByIDAndUser finds a highlight on a clip ensuring ownership
+func FindHighlightByIDAndUser(tx *pop.Connection, highlightID, clipID, userID uuid.UUID) (*Highlight, error) {
+	highlight := &Highlight{}
+	err := tx.Where("id = ? AND clip_id = ? AND user_id = ?", highlightID, clipID, userID).First(highlight)
+	return highlight, err
+}
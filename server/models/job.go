@@ -0,0 +1,100 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// Job types
+const (
+	JobProcessClip  = "clip.process" // Extracts text from a clip's screenshots and PDFs
+	JobFetchFavicon = "clip.favicon" // Fetches the icon of a clip's site
+	JobCloudUpload  = "clip.cloud"   // Uploads a clip to the user's cloud folders
+	JobSendToKindle = "clip.kindle"  // Emails a clip's EPUB to the user's Kindle address
+)
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job is background work run by the worker pool after its request returned.
+// Failed attempts are queued again with a delay until the attempts run out.
+type Job struct {
+	ID         uuid.UUID    `json:"id" db:"id"`
+	UserID     uuid.UUID    `json:"user_id" db:"user_id"`
+	Type       string       `json:"type" db:"type"`
+	Payload    string       `json:"payload" db:"payload"` // JSON arguments for the job type
+	Status     string       `json:"status" db:"status"`   // queued, running, succeeded, failed
+	Attempts   int          `json:"attempts" db:"attempts"`
+	Result     nulls.String `json:"result" db:"result"` // JSON
+	Error      nulls.String `json:"error" db:"error"`   // Of the last failed attempt
+	RunAt      time.Time    `json:"run_at" db:"run_at"` // Not picked up before this
+	StartedAt  nulls.Time   `json:"started_at" db:"started_at"`
+	FinishedAt nulls.Time   `json:"finished_at" db:"finished_at"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// Jobs is a slice of Job for collection operations
+type Jobs []Job
+
+// Validate validates the Job fields
+func (j *Job) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: j.UserID, Name: "UserID"},
+		&validators.StringIsPresent{Field: j.Type, Name: "Type"},
+		&validators.StringIsPresent{Field: j.Payload, Name: "Payload"},
+		&validators.StringInclusion{Field: j.Status, Name: "Status", List: []string{
+			JobQueued, JobRunning, JobSucceeded, JobFailed,
+		}},
+	), nil
+}
+
+// Done reports whether the job has finished, successfully or not
+func (j *Job) Done() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed
+}
+
+// FindJobByIDAndUser finds a job ensuring ownership
+func FindJobByIDAndUser(tx *pop.Connection, jobID, userID uuid.UUID) (*Job, error) {
+	job := &Job{}
+	err := tx.Where("id = ? AND user_id = ?", jobID, userID).First(job)
+	return job, err
+}
+
+// FindRunnableJobs returns queued jobs due at now, oldest first
+func FindRunnableJobs(tx *pop.Connection, now time.Time, limit int) (Jobs, error) {
+	jobs := Jobs{}
+	err := tx.Where("status = ? AND run_at <= ?", JobQueued, now).Order("run_at ASC").Limit(limit).All(&jobs)
+	return jobs, err
+}
+
+// ClaimJob marks a queued job running for a new attempt. It reports false
+// when another worker claimed it first.
+func ClaimJob(tx *pop.Connection, job *Job, now time.Time) (bool, error) {
+	n, err := tx.RawQuery(
+		"UPDATE jobs SET status = ?, attempts = attempts + 1, started_at = ?, updated_at = ? WHERE id = ? AND status = ?",
+		JobRunning, now, now, job.ID, JobQueued,
+	).ExecWithCount()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	return true, tx.Reload(job)
+}
+
+// FindStaleJobs returns jobs running since before, whose worker was lost
+// (typically to a restart)
+func FindStaleJobs(tx *pop.Connection, before time.Time) (Jobs, error) {
+	jobs := Jobs{}
+	err := tx.Where("status = ? AND started_at < ?", JobRunning, before).All(&jobs)
+	return jobs, err
+}
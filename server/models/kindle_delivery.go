@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// Delivery statuses
+const (
+	DeliveryPending = "pending"
+	DeliverySending = "sending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+)
+
+// KindleDelivery tracks an asynchronous Send to Kindle request
+type KindleDelivery struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	UserID      uuid.UUID    `json:"user_id" db:"user_id"`
+	ClipID      uuid.UUID    `json:"clip_id" db:"clip_id"`
+	DeviceEmail string       `json:"device_email" db:"device_email"`
+	Status      string       `json:"status" db:"status"` // pending, sending, sent, failed
+	Error       nulls.String `json:"error" db:"error"`
+	SentAt      nulls.Time   `json:"sent_at" db:"sent_at"`
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// KindleDeliveries is a slice of KindleDelivery for collection operations
+type KindleDeliveries []KindleDelivery
+
+// Validate validates the KindleDelivery fields
+func (d *KindleDelivery) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: d.UserID, Name: "UserID"},
+		&validators.UUIDIsPresent{Field: d.ClipID, Name: "ClipID"},
+		&validators.EmailIsPresent{Field: d.DeviceEmail, Name: "DeviceEmail"},
+		&validators.StringInclusion{Field: d.Status, Name: "Status", List: []string{
+			DeliveryPending, DeliverySending, DeliverySent, DeliveryFailed,
+		}},
+	), nil
+}
+
+// FindDeliveryByIDAndUser finds a delivery ensuring ownership
+func FindDeliveryByIDAndUser(tx *pop.Connection, deliveryID, userID uuid.UUID) (*KindleDelivery, error) {
+	delivery := &KindleDelivery{}
+	err := tx.Where("id = ? AND user_id = ?", deliveryID, userID).First(delivery)
+	return delivery, err
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// RetentionRule expires a user's clips older than MaxAgeDays: all of them,
+// or only those of a mode and/or with a tag
+type RetentionRule struct {
+	ID         uuid.UUID    `json:"id" db:"id"`
+	UserID     uuid.UUID    `json:"user_id" db:"user_id"`
+	Mode       nulls.String `json:"mode" db:"mode"`
+	Tag        nulls.String `json:"tag" db:"tag"`
+	MaxAgeDays int          `json:"max_age_days" db:"max_age_days"` // Since the clip was saved
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// RetentionRules is a slice of RetentionRule for collection operations
+type RetentionRules []RetentionRule
+
+// Validate validates the RetentionRule fields
+func (r *RetentionRule) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.Validate(
+		&validators.UUIDIsPresent{Field: r.UserID, Name: "UserID"},
+	)
+	if r.MaxAgeDays < 1 {
+		verrs.Add("max_age_days", "max_age_days must be at least 1")
+	}
+	return verrs, nil
+}
+
+// FindRetentionRulesByUserID returns the user's rules in the order they were
+// created
+func FindRetentionRulesByUserID(tx *pop.Connection, userID uuid.UUID) (RetentionRules, error) {
+	rules := RetentionRules{}
+	err := tx.Where("user_id = ?", userID).Order("created_at ASC, id ASC").All(&rules)
+	return rules, err
+}
+
+// FindRetentionRuleByIDAndUser finds a rule ensuring ownership
+func FindRetentionRuleByIDAndUser(tx *pop.Connection, ruleID, userID uuid.UUID) (*RetentionRule, error) {
+	rule := &RetentionRule{}
+	err := tx.Where("id = ? AND user_id = ?", ruleID, userID).First(rule)
+	return rule, err
+}
+
+// FindExpiredClips returns the clips the rule expires at now: not trashed,
+// saved more than MaxAgeDays before
+func FindExpiredClips(tx *pop.Connection, rule *RetentionRule, now time.Time) (Clips, error) {
+	clips := Clips{}
+	q := tx.Where("user_id = ? AND deleted_at IS NULL AND created_at < ?", rule.UserID, now.AddDate(0, 0, -rule.MaxAgeDays))
+	if rule.Mode.Valid {
+		q = q.Where("mode = ?", rule.Mode.String)
+	}
+	if rule.Tag.Valid {
+		q = q.Where("id IN (SELECT clips_tags.clip_id FROM clips_tags JOIN tags ON tags.id = clips_tags.tag_id WHERE tags.user_id = ? AND tags.name = ?)", rule.UserID, rule.Tag.String)
+	}
+	err := q.Order("created_at ASC").All(&clips)
+	return clips, err
+}
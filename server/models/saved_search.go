@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// SavedSearch is a named combination of clip filters
+type SavedSearch struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Name      string    `json:"name" db:"name"`
+	Filters   string    `json:"filters" db:"filters"` // JSON-encoded filter definition
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SavedSearches is a slice of SavedSearch for collection operations
+type SavedSearches []SavedSearch
+
+// Validate validates the SavedSearch fields
+func (s *SavedSearch) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: s.UserID, Name: "UserID"},
+		&validators.StringIsPresent{Field: s.Name, Name: "Name"},
+		&validators.StringLengthInRange{Field: s.Name, Name: "Name", Max: 100},
+	), nil
+}
+
+// FindSavedSearchesByUserID returns the user's saved searches sorted by name
+func FindSavedSearchesByUserID(tx *pop.Connection, userID uuid.UUID) (SavedSearches, error) {
+	searches := SavedSearches{}
+	err := tx.Where("user_id = ?", userID).Order("name ASC").All(&searches)
+	return searches, err
+}
+
+// FindSavedSearchByIDAndUser finds a saved search ensuring ownership
+func FindSavedSearchByIDAndUser(tx *pop.Connection, searchID, userID uuid.UUID) (*SavedSearch, error) {
+	search := &SavedSearch{}
+	err := tx.Where("id = ? AND user_id = ?", searchID, userID).First(search)
+	return search, err
+}
+
+// FindSavedSearchByName finds the user's saved search with the given name
+func FindSavedSearchByName(tx *pop.Connection, userID uuid.UUID, name string) (*SavedSearch, error) {
+	search := &SavedSearch{}
+	err := tx.Where("user_id = ? AND name = ?", userID, name).First(search)
+	return search, err
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// ShareTokenLength is the number of random bytes in a share token
+const ShareTokenLength = 16
+
+// Share is a public link to a clip: anyone with its token, and its
+// passphrase when set, can read the clip until it expires or its views run out
+type Share struct {
+	ID           uuid.UUID    `json:"id" db:"id"`
+	UserID       uuid.UUID    `json:"user_id" db:"user_id"`
+	ClipID       uuid.UUID    `json:"clip_id" db:"clip_id"`
+	Token        string       `json:"token" db:"token"`
+	DisabledAt   nulls.Time   `json:"disabled_at" db:"disabled_at"` // Set by an admin, e.g. pending review of an abuse report
+	ExpiresAt    nulls.Time   `json:"expires_at" db:"expires_at"`
+	MaxViews     nulls.Int    `json:"max_views" db:"max_views"`
+	Views        int          `json:"views" db:"views"`
+	ExhaustedAt  nulls.Time   `json:"exhausted_at" db:"exhausted_at"` // When the last of MaxViews was used
+	PasswordHash nulls.String `json:"-" db:"password_hash"`           // argon2id, see internal/passhash
+	CreatedAt    time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// Shares is a slice of Share for collection operations
+type Shares []Share
+
+// Validate validates the Share fields
+func (s *Share) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.Validate(
+		&validators.UUIDIsPresent{Field: s.UserID, Name: "UserID"},
+		&validators.UUIDIsPresent{Field: s.ClipID, Name: "ClipID"},
+		&validators.StringIsPresent{Field: s.Token, Name: "Token"},
+	)
+	if s.MaxViews.Valid && s.MaxViews.Int < 1 {
+		verrs.Add("max_views", "max_views must be at least 1")
+	}
+	return verrs, nil
+}
+
+// Expired reports whether the share's time limit has passed at now
+func (s *Share) Expired(now time.Time) bool {
+	return s.ExpiresAt.Valid && !now.Before(s.ExpiresAt.Time)
+}
+
+// UseShareView counts a view of the share at now. It reports false, without
+// counting it, when the share is expired or its views ran out, including
+// to concurrent requests.
+func UseShareView(tx *pop.Connection, share *Share, now time.Time) (bool, error) {
+	n, err := tx.RawQuery(
+		`UPDATE shares SET views = views + 1, updated_at = ?,
+			exhausted_at = CASE WHEN max_views IS NOT NULL AND views + 1 >= max_views THEN ? ELSE exhausted_at END
+		WHERE id = ? AND (max_views IS NULL OR views < max_views) AND (expires_at IS NULL OR expires_at > ?)`,
+		now, now, share.ID, now,
+	).ExecWithCount()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	return true, tx.Reload(share)
+}
+
+// NewShare returns a share of a clip with a new random token
+func NewShare(userID, clipID uuid.UUID) (*Share, error) {
+	b := make([]byte, ShareTokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate share token: %w", err)
+	}
+	return &Share{
+		ID:     uuid.Must(uuid.NewV4()),
+		UserID: userID,
+		ClipID: clipID,
+		Token:  base64.RawURLEncoding.EncodeToString(b),
+	}, nil
+}
+
+// FindSharesByClip returns the shares of a clip, oldest first
+func FindSharesByClip(tx *pop.Connection, clipID uuid.UUID) (Shares, error) {
+	shares := Shares{}
+	err := tx.Where("clip_id = ?", clipID).Order("created_at ASC").All(&shares)
+	return shares, err
+}
+
+// FindShareByIDAndUser finds a share ensuring ownership
+func FindShareByIDAndUser(tx *pop.Connection, shareID, userID uuid.UUID) (*Share, error) {
+	share := &Share{}
+	err := tx.Where("id = ? AND user_id = ?", shareID, userID).First(share)
+	return share, err
+}
+
+// FindShareByToken finds the share with the given token
+func FindShareByToken(tx *pop.Connection, token string) (*Share, error) {
+	share := &Share{}
+	err := tx.Where("token = ?", token).First(share)
+	return share, err
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+)
+
+// StorageUsage caches how much storage a user's clips take, as last
+// measured on disk
+type StorageUsage struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	UserID       uuid.UUID `json:"user_id" db:"user_id"`
+	Clips        int       `json:"clips" db:"clips"`
+	Bytes        int64     `json:"bytes" db:"bytes"`
+	TrashedClips int       `json:"trashed_clips" db:"trashed_clips"`
+	TrashedBytes int64     `json:"trashed_bytes" db:"trashed_bytes"`
+	Modes        string    `json:"modes" db:"modes"` // JSON: clips and bytes by clip mode
+	ComputedAt   time.Time `json:"computed_at" db:"computed_at"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// FindStorageUsage returns the cached usage of the user, or nil when none
+// was computed yet
+func FindStorageUsage(tx *pop.Connection, userID uuid.UUID) (*StorageUsage, error) {
+	usage := &StorageUsage{}
+	err := tx.Where("user_id = ?", userID).First(usage)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return usage, err
+}
+
+// SaveStorageUsage replaces the cached usage of usage.UserID
+func SaveStorageUsage(tx *pop.Connection, usage *StorageUsage) error {
+	if err := tx.RawQuery("DELETE FROM storage_usages WHERE user_id = ?", usage.UserID).Exec(); err != nil {
+		return err
+	}
+	usage.ID = uuid.Must(uuid.NewV4())
+	return tx.Create(usage)
+}
@@ -0,0 +1,236 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// Tag is a user's label that can be attached to many clips
+type Tag struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Name      string    `json:"name" db:"name"`
+	Aliases   string    `json:"aliases" db:"aliases"` // JSON-encoded names written as this tag
+	Implies   string    `json:"implies" db:"implies"` // JSON-encoded tags added along with this one
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Tags is a slice of Tag for collection operations
+type Tags []Tag
+
+// Validate validates the Tag fields
+func (t *Tag) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: t.UserID, Name: "UserID"},
+		&validators.StringIsPresent{Field: t.Name, Name: "Name"},
+	), nil
+}
+
+// AliasList returns the names written as the tag
+func (t *Tag) AliasList() []string {
+	var aliases []string
+	json.Unmarshal([]byte(t.Aliases), &aliases)
+	return aliases
+}
+
+// SetAliasList replaces the names written as the tag
+func (t *Tag) SetAliasList(aliases []string) {
+	data, _ := json.Marshal(CleanTagNames(aliases))
+	t.Aliases = string(data)
+}
+
+// ImpliedList returns the tags added along with the tag
+func (t *Tag) ImpliedList() []string {
+	var implied []string
+	json.Unmarshal([]byte(t.Implies), &implied)
+	return implied
+}
+
+// SetImpliedList replaces the tags added along with the tag
+func (t *Tag) SetImpliedList(implied []string) {
+	data, _ := json.Marshal(CleanTagNames(implied))
+	t.Implies = string(data)
+}
+
+// ClipTag joins a clip to one of its tags
+type ClipTag struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	ClipID    uuid.UUID `json:"clip_id" db:"clip_id"`
+	TagID     uuid.UUID `json:"tag_id" db:"tag_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TableName overrides the default table name
+func (ClipTag) TableName() string {
+	return "clips_tags"
+}
+
+// clipTagName is a row of the clips_tags/tags join used to load clip tags
+type clipTagName struct {
+	ClipID uuid.UUID `db:"clip_id"`
+	Name   string    `db:"name"`
+}
+
+// TableName overrides the default table name
+func (clipTagName) TableName() string {
+	return "clips_tags"
+}
+
+// CleanTagNames trims tag names and drops empty and repeated entries
+func CleanTagNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	cleaned := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		cleaned = append(cleaned, name)
+	}
+	return cleaned
+}
+
+// FindOrCreateTag returns the user's tag with the given name, creating it if needed
+func FindOrCreateTag(tx *pop.Connection, userID uuid.UUID, name string) (*Tag, error) {
+	tag := &Tag{}
+	err := tx.Where("user_id = ? AND name = ?", userID, name).First(tag)
+	if err == nil {
+		return tag, nil
+	}
+
+	tag = &Tag{ID: uuid.Must(uuid.NewV4()), UserID: userID, Name: name, Aliases: "[]", Implies: "[]"}
+	if err := tx.Create(tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// FindTagsByUserID returns the user's tags ordered by name
+func FindTagsByUserID(tx *pop.Connection, userID uuid.UUID) (Tags, error) {
+	tags := Tags{}
+	err := tx.Where("user_id = ?", userID).Order("name ASC").All(&tags)
+	return tags, err
+}
+
+// tagClipCount is a row of the per-tag clip counts
+type tagClipCount struct {
+	TagID uuid.UUID `db:"tag_id"`
+	Count int       `db:"count"`
+}
+
+// CountClipsByTag returns how many of the user's clips (trash excluded)
+// carry each of their tags
+func CountClipsByTag(tx *pop.Connection, userID uuid.UUID) (map[uuid.UUID]int, error) {
+	rows := []tagClipCount{}
+	err := tx.RawQuery(`SELECT clips_tags.tag_id, COUNT(*) AS count FROM clips_tags
+		JOIN clips ON clips.id = clips_tags.clip_id
+		WHERE clips.user_id = ? AND clips.deleted_at IS NULL
+		GROUP BY clips_tags.tag_id`, userID).All(&rows)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		counts[row.TagID] = row.Count
+	}
+	return counts, nil
+}
+
+// SetClipTags replaces the clip's tags with the given names
+func SetClipTags(tx *pop.Connection, clip *Clip, names []string) error {
+	names = CleanTagNames(names)
+
+	if err := tx.RawQuery("DELETE FROM clips_tags WHERE clip_id = ?", clip.ID).Exec(); err != nil {
+		return err
+	}
+	for _, name := range names {
+		tag, err := FindOrCreateTag(tx, clip.UserID, name)
+		if err != nil {
+			return err
+		}
+		link := &ClipTag{ID: uuid.Must(uuid.NewV4()), ClipID: clip.ID, TagID: tag.ID}
+		if err := tx.Create(link); err != nil {
+			return err
+		}
+	}
+
+	clip.Tags = names
+	return nil
+}
+
+// LoadTags fills in the Tags field of each clip
+func (c Clips) LoadTags(tx *pop.Connection) error {
+	if len(c) == 0 {
+		return nil
+	}
+
+	ids := make([]interface{}, len(c))
+	for i := range c {
+		ids[i] = c[i].ID
+	}
+
+	rows := []clipTagName{}
+	err := tx.Q().
+		Select("clips_tags.clip_id", "tags.name").
+		Join("tags", "tags.id = clips_tags.tag_id").
+		Where("clips_tags.clip_id IN (?)", ids...).
+		Order("clips_tags.created_at, tags.name").
+		All(&rows)
+	if err != nil {
+		return err
+	}
+
+	byClip := make(map[uuid.UUID][]string, len(c))
+	for _, row := range rows {
+		byClip[row.ClipID] = append(byClip[row.ClipID], row.Name)
+	}
+	for i := range c {
+		c[i].Tags = byClip[c[i].ID]
+	}
+	return nil
+}
+
+// LoadTags fills in the clip's Tags field
+func (c *Clip) LoadTags(tx *pop.Connection) error {
+	clips := Clips{*c}
+	if err := clips.LoadTags(tx); err != nil {
+		return err
+	}
+	c.Tags = clips[0].Tags
+	return nil
+}
+
+// AddClipTags attaches the named tags to the clip, keeping its existing tags
+func AddClipTags(tx *pop.Connection, clip *Clip, names []string) error {
+	if err := clip.LoadTags(tx); err != nil {
+		return err
+	}
+	return SetClipTags(tx, clip, append(clip.Tags, names...))
+}
+
+// RemoveClipTags detaches the named tags from the clip
+func RemoveClipTags(tx *pop.Connection, clip *Clip, names []string) error {
+	if err := clip.LoadTags(tx); err != nil {
+		return err
+	}
+	remove := make(map[string]bool, len(names))
+	for _, name := range CleanTagNames(names) {
+		remove[name] = true
+	}
+	kept := make([]string, 0, len(clip.Tags))
+	for _, name := range clip.Tags {
+		if !remove[name] {
+			kept = append(kept, name)
+		}
+	}
+	return SetClipTags(tx, clip, kept)
+}
@@ -0,0 +1,5 @@
+package models
+
+func (ms *ModelSuite) Test_CleanTagNames() {
+	ms.Equal([]string{"go", "web"}, CleanTagNames([]string{" go", "", "web", "go ", "  "}))
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// TaxonomyTag is a tag of the instance's controlled vocabulary (tags.taxonomy),
+// managed by admins and shared by all users
+type TaxonomyTag struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	Name        string       `json:"name" db:"name"`
+	Description nulls.String `json:"description" db:"description"`
+	Aliases     string       `json:"aliases" db:"aliases"` // JSON-encoded names mapped to this tag
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// TaxonomyTags is a slice of TaxonomyTag for collection operations
+type TaxonomyTags []TaxonomyTag
+
+// Validate validates the TaxonomyTag fields
+func (t *TaxonomyTag) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.StringIsPresent{Field: t.Name, Name: "Name"},
+	), nil
+}
+
+// AliasList returns the names mapped to the tag
+func (t *TaxonomyTag) AliasList() []string {
+	var aliases []string
+	json.Unmarshal([]byte(t.Aliases), &aliases)
+	return aliases
+}
+
+// SetAliasList replaces the names mapped to the tag
+func (t *TaxonomyTag) SetAliasList(aliases []string) {
+	data, _ := json.Marshal(CleanTagNames(aliases))
+	t.Aliases = string(data)
+}
+
+// FindTaxonomyTags returns the taxonomy ordered by name
+func FindTaxonomyTags(tx *pop.Connection) (TaxonomyTags, error) {
+	tags := TaxonomyTags{}
+	err := tx.Order("name ASC").All(&tags)
+	return tags, err
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// UploadSession is a capture uploaded in chunks. Its bytes are appended to a
+// file in the user's clip directory until the session is finalized into a clip.
+type UploadSession struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	ContentType string    `json:"content_type" db:"content_type"` // Of the assembled body, as for POST /api/v1/clips
+	Size        int64     `json:"size" db:"size"`                 // Bytes received so far
+	ExpiresAt   time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// UploadSessions is a slice of UploadSession for collection operations
+type UploadSessions []UploadSession
+
+// Validate validates the UploadSession fields
+func (s *UploadSession) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: s.UserID, Name: "UserID"},
+		&validators.StringIsPresent{Field: s.ContentType, Name: "ContentType"},
+		&validators.TimeIsPresent{Field: s.ExpiresAt, Name: "ExpiresAt"},
+	), nil
+}
+
+// FindUploadSessionByIDAndUser finds an unexpired upload session ensuring ownership
+func FindUploadSessionByIDAndUser(tx *pop.Connection, sessionID, userID uuid.UUID) (*UploadSession, error) {
+	session := &UploadSession{}
+	err := tx.Where("id = ? AND user_id = ? AND expires_at > ?", sessionID, userID, time.Now()).First(session)
+	return session, err
+}
+
+// FindExpiredUploadSessions returns upload sessions that expired before now
+func FindExpiredUploadSessions(tx *pop.Connection, now time.Time) (UploadSessions, error) {
+	sessions := UploadSessions{}
+	err := tx.Where("expires_at <= ?", now).All(&sessions)
+	return sessions, err
+}
@@ -17,9 +17,17 @@ type User struct {
 	Name          string       `json:"name" db:"name"`
 	OAuthID       string       `json:"oauth_id" db:"oauth_id"`
 	ClipDirectory nulls.String `json:"clip_directory" db:"clip_directory"`
+	KindleEmail   nulls.String `json:"kindle_email" db:"kindle_email"`
 	Disabled      bool         `json:"disabled" db:"disabled"`
 	CreatedAt     time.Time    `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time    `json:"updated_at" db:"updated_at"`
+
+	// Go template of the clips' frontmatter, instead of clips.frontmatter_template
+	FrontmatterTemplate nulls.String `json:"frontmatter_template" db:"frontmatter_template"`
+	// Go templates of new clips' folder and page file names, instead of
+	// clips.folder_template and clips.file_template
+	FolderTemplate nulls.String `json:"folder_template" db:"folder_template"`
+	FileTemplate   nulls.String `json:"file_template" db:"file_template"`
 }
 
 // Users is a slice of User objects.
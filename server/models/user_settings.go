@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// UserSettings are a user's preferences for their new clips. Null columns
+// leave the server's config in effect.
+type UserSettings struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	UserID      uuid.UUID    `json:"user_id" db:"user_id"`
+	DefaultMode nulls.String `json:"default_mode" db:"default_mode"` // Of clips sent without a mode
+	DefaultTags string       `json:"default_tags" db:"default_tags"` // JSON-encoded tags added to every clip
+
+	// Instead of images.convert_to_webp, images.preserve_original and
+	// images.max_dimension_px (which the user can only lower)
+	ConvertToWebP    nulls.Bool `json:"convert_to_webp" db:"convert_to_webp"`
+	PreserveOriginal nulls.Bool `json:"preserve_original" db:"preserve_original"`
+	MaxDimensionPx   nulls.Int  `json:"max_dimension_px" db:"max_dimension_px"`
+
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TableName overrides the default table name
+func (UserSettings) TableName() string {
+	return "user_settings"
+}
+
+// Validate validates the UserSettings fields
+func (s *UserSettings) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: s.UserID, Name: "UserID"},
+	), nil
+}
+
+// TagList returns the tags added to the user's clips
+func (s *UserSettings) TagList() []string {
+	var tags []string
+	json.Unmarshal([]byte(s.DefaultTags), &tags)
+	return tags
+}
+
+// SetTagList replaces the tags added to the user's clips
+func (s *UserSettings) SetTagList(tags []string) {
+	data, _ := json.Marshal(CleanTagNames(tags))
+	s.DefaultTags = string(data)
+}
+
+// FindUserSettings returns the user's settings, or new ones (not saved yet,
+// with a nil ID) when they have none
+func FindUserSettings(tx *pop.Connection, userID uuid.UUID) (*UserSettings, error) {
+	settings := &UserSettings{}
+	err := tx.Where("user_id = ?", userID).First(settings)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &UserSettings{UserID: userID, DefaultTags: "[]"}, nil
+	}
+	return settings, err
+}
+
+// SaveUserSettings creates or updates settings
+func SaveUserSettings(tx *pop.Connection, settings *UserSettings) (*validate.Errors, error) {
+	if settings.ID == uuid.Nil {
+		settings.ID = uuid.Must(uuid.NewV4())
+		return tx.ValidateAndCreate(settings)
+	}
+	return tx.ValidateAndUpdate(settings)
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/validate/v3"
+	"github.com/gobuffalo/validate/v3/validators"
+	"github.com/gofrs/uuid"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to
+var WebhookEvents = []string{EventClipCreated, EventClipUpdated}
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook is a user's outbound webhook endpoint
+type Webhook struct {
+	ID                      uuid.UUID    `json:"id" db:"id"`
+	UserID                  uuid.UUID    `json:"user_id" db:"user_id"`
+	URL                     string       `json:"url" db:"url"`
+	Events                  string       `json:"events" db:"events"` // Comma-separated
+	Secret                  string       `json:"-" db:"secret"`
+	PreviousSecret          nulls.String `json:"-" db:"previous_secret"` // Still signs deliveries until PreviousSecretExpiresAt
+	PreviousSecretExpiresAt nulls.Time   `json:"previous_secret_expires_at" db:"previous_secret_expires_at"`
+	CreatedAt               time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt               time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// Webhooks is a slice of Webhook for collection operations
+type Webhooks []Webhook
+
+// Validate validates the Webhook fields
+func (w *Webhook) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.Validate(
+		&validators.UUIDIsPresent{Field: w.UserID, Name: "UserID"},
+		&validators.URLIsPresent{Field: w.URL, Name: "URL"},
+		&validators.StringIsPresent{Field: w.Secret, Name: "Secret"},
+		&validators.StringIsPresent{Field: w.Events, Name: "Events"},
+	)
+	for _, event := range w.EventList() {
+		if !isWebhookEvent(event) {
+			verrs.Add("events", "unknown event: "+event)
+		}
+	}
+	return verrs, nil
+}
+
+// EventList returns the subscribed events
+func (w *Webhook) EventList() []string {
+	if w.Events == "" {
+		return []string{}
+	}
+	return strings.Split(w.Events, ",")
+}
+
+// Subscribes reports whether the webhook receives event
+func (w *Webhook) Subscribes(event string) bool {
+	for _, e := range w.EventList() {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Secrets returns the secrets deliveries are signed with at now: the
+// current one, then the previous one while it is in its grace period
+func (w *Webhook) Secrets(now time.Time) []string {
+	secrets := []string{w.Secret}
+	if w.Rotating(now) {
+		secrets = append(secrets, w.PreviousSecret.String)
+	}
+	return secrets
+}
+
+// Rotating reports whether a rotated-out secret is still in its grace period
+func (w *Webhook) Rotating(now time.Time) bool {
+	return w.PreviousSecret.Valid && w.PreviousSecretExpiresAt.Valid && now.Before(w.PreviousSecretExpiresAt.Time)
+}
+
+func isWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// FindWebhooksByUserID returns the user's webhooks, oldest first
+func FindWebhooksByUserID(tx *pop.Connection, userID uuid.UUID) (Webhooks, error) {
+	webhooks := Webhooks{}
+	err := tx.Where("user_id = ?", userID).Order("created_at ASC").All(&webhooks)
+	return webhooks, err
+}
+
+// FindWebhookByIDAndUser finds a webhook ensuring ownership
+func FindWebhookByIDAndUser(tx *pop.Connection, webhookID, userID uuid.UUID) (*Webhook, error) {
+	webhook := &Webhook{}
+	err := tx.Where("id = ? AND user_id = ?", webhookID, userID).First(webhook)
+	return webhook, err
+}
+
+// WebhookDelivery is one attempt to deliver an event to a webhook; replays
+// are new deliveries pointing at the one they retry
+type WebhookDelivery struct {
+	ID             uuid.UUID    `json:"id" db:"id"`
+	WebhookID      uuid.UUID    `json:"webhook_id" db:"webhook_id"`
+	Event          string       `json:"event" db:"event"`
+	Payload        string       `json:"payload" db:"payload"`
+	EventID        nulls.Int64  `json:"event_id" db:"event_id"`
+	Status         string       `json:"status" db:"status"` // pending, succeeded, failed
+	ResponseStatus nulls.Int    `json:"response_status" db:"response_status"`
+	Error          nulls.String `json:"error" db:"error"`
+	ReplayOf       nulls.UUID   `json:"replay_of" db:"replay_of"`
+	DeliveredAt    nulls.Time   `json:"delivered_at" db:"delivered_at"`
+	CreatedAt      time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookDeliveries is a slice of WebhookDelivery for collection operations
+type WebhookDeliveries []WebhookDelivery
+
+// Validate validates the WebhookDelivery fields
+func (d *WebhookDelivery) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.UUIDIsPresent{Field: d.WebhookID, Name: "WebhookID"},
+		&validators.StringIsPresent{Field: d.Event, Name: "Event"},
+		&validators.StringIsPresent{Field: d.Payload, Name: "Payload"},
+		&validators.StringInclusion{Field: d.Status, Name: "Status", List: []string{
+			WebhookDeliveryPending, WebhookDeliverySucceeded, WebhookDeliveryFailed,
+		}},
+	), nil
+}
+
+// FindWebhookDeliveries returns a webhook's most recent deliveries, newest
+// first, optionally only those with the given status
+func FindWebhookDeliveries(tx *pop.Connection, webhookID uuid.UUID, status string, limit int) (WebhookDeliveries, error) {
+	deliveries := WebhookDeliveries{}
+	q := tx.Where("webhook_id = ?", webhookID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	err := q.Order("created_at DESC").Limit(limit).All(&deliveries)
+	return deliveries, err
+}
+
+// FindStaleWebhookDeliveries returns deliveries still pending since before,
+// whose sender was lost (typically to a restart)
+func FindStaleWebhookDeliveries(tx *pop.Connection, before time.Time) (WebhookDeliveries, error) {
+	deliveries := WebhookDeliveries{}
+	err := tx.Where("status = ? AND updated_at < ?", WebhookDeliveryPending, before).Order("created_at ASC").All(&deliveries)
+	return deliveries, err
+}
+
+// FindWebhookDelivery finds a delivery of the given webhook
+func FindWebhookDelivery(tx *pop.Connection, deliveryID, webhookID uuid.UUID) (*WebhookDelivery, error) {
+	delivery := &WebhookDelivery{}
+	err := tx.Where("id = ? AND webhook_id = ?", deliveryID, webhookID).First(delivery)
+	return delivery, err
+}
+
+// FindRetryableWebhookDeliveries returns the failed deliveries updated since
+// the given time that were not replayed yet, oldest first
+func FindRetryableWebhookDeliveries(tx *pop.Connection, since time.Time) (WebhookDeliveries, error) {
+	deliveries := WebhookDeliveries{}
+	err := tx.Where("status = ? AND updated_at > ?", WebhookDeliveryFailed, since).
+		Where("NOT EXISTS (SELECT 1 FROM webhook_deliveries AS r WHERE r.replay_of = webhook_deliveries.id)").
+		Order("created_at ASC").All(&deliveries)
+	return deliveries, err
+}
+
+// DeleteFinishedWebhookDeliveries deletes the deliveries that succeeded or
+// failed before cutoff, and returns how many. Pending ones are left to
+// their sender.
+func DeleteFinishedWebhookDeliveries(tx *pop.Connection, cutoff time.Time) (int, error) {
+	n, err := tx.RawQuery("DELETE FROM webhook_deliveries WHERE status != ? AND updated_at < ?",
+		WebhookDeliveryPending, cutoff).ExecWithCount()
+	return int(n), err
+}
+
+// Attempts counts the delivery and the ones it replays
+func (d *WebhookDelivery) Attempts(tx *pop.Connection) (int, error) {
+	attempts := 1
+	for replayOf := d.ReplayOf; replayOf.Valid; attempts++ {
+		previous := &WebhookDelivery{}
+		if err := tx.Find(previous, replayOf.UUID); err != nil {
+			return 0, err
+		}
+		replayOf = previous.ReplayOf
+	}
+	return attempts, nil
+}
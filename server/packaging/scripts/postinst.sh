@@ -85,8 +85,8 @@ echo "   sudo systemctl status web-clipper"
 echo "   sudo journalctl -u web-clipper -f"
 echo ""
 echo "Manual migration (if needed):"
-echo "   sudo -u web-clipper MIGRATION_DIR=/usr/share/web-clipper/migrations /usr/bin/web-clipper migrate"
-echo "   sudo -u web-clipper MIGRATION_DIR=/usr/share/web-clipper/migrations /usr/bin/web-clipper migrate status"
+echo "   sudo -u web-clipper /usr/bin/web-clipper migrate"
+echo "   sudo -u web-clipper /usr/bin/web-clipper migrate status"
 echo ""
 echo "Admin Commands:"
 echo "   sudo -u web-clipper /usr/bin/web-clipper users list"